@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/template"
+
+	"gateway/internal/config"
+)
+
+//go:embed templates/config.yaml.tmpl
+var configTemplate string
+
+// knownService is a Project Zero service the generated config routes to.
+type knownService struct {
+	Key      string
+	Name     string
+	Host     string
+	Port     int
+	Prefix   string
+	AuthMode string
+	Timeout  string
+	Critical bool
+	URL      string
+}
+
+// knownServices lists the project's services as docker-compose.yml runs
+// them. The auth service comes first; it also verifies tokens.
+var knownServices = []knownService{
+	{Key: "auth", Name: "auth-service", Host: "auth-service", Port: 8001, Prefix: "auth", AuthMode: "none", Timeout: "10s", Critical: true},
+	{Key: "profile", Name: "user-profile-service", Host: "user-profile-service", Port: 8002, Prefix: "profile", AuthMode: "required", Timeout: "10s"},
+	{Key: "products", Name: "product-service", Host: "product-service", Port: 8004, Prefix: "products", AuthMode: "optional", Timeout: "10s", Critical: true},
+	{Key: "cart", Name: "cart-service", Host: "cart-service", Port: 8007, Prefix: "cart", AuthMode: "required", Timeout: "10s"},
+	{Key: "orders", Name: "order-service", Host: "order-service", Port: 8008, Prefix: "orders", AuthMode: "required", Timeout: "15s", Critical: true},
+	{Key: "payments", Name: "payment-service", Host: "payment-service", Port: 8009, Prefix: "payments", AuthMode: "required", Timeout: "30s"},
+	{Key: "notifications", Name: "notification-service", Host: "notification-service", Port: 8011, Prefix: "notifications", AuthMode: "required", Timeout: "10s"},
+}
+
+// runInit implements `gateway init`, which writes an example config for
+// the project's services that passes validation as generated.
+func runInit(args []string) error {
+	flags := flag.NewFlagSet("init", flag.ContinueOnError)
+	output := flags.String("o", "config/config.yaml", `file to write, or "-" for stdout`)
+	local := flags.Bool("local", false, "address services on localhost instead of their docker compose hostnames")
+	port := flags.Int("port", 8000, "gateway port")
+	force := flags.Bool("force", false, "overwrite an existing file")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	generated, err := renderConfig(*local, *port)
+	if err != nil {
+		return err
+	}
+	if err := validateGenerated(generated); err != nil {
+		return fmt.Errorf("generated config is invalid: %w", err)
+	}
+
+	if *output == "-" {
+		_, err := os.Stdout.Write(generated)
+		return err
+	}
+	if _, err := os.Stat(*output); err == nil && !*force {
+		return fmt.Errorf("%s already exists; use -force to overwrite it", *output)
+	} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(*output), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(*output, generated, 0o644); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %s\n", *output)
+	return nil
+}
+
+func renderConfig(local bool, port int) ([]byte, error) {
+	services := make([]knownService, len(knownServices))
+	for i, service := range knownServices {
+		host := service.Host
+		if local {
+			host = "localhost"
+		}
+		service.URL = fmt.Sprintf("http://%s:%d", host, service.Port)
+		services[i] = service
+	}
+
+	tmpl, err := template.New("config").Parse(configTemplate)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, map[string]interface{}{
+		"Local":    local,
+		"Port":     port,
+		"Services": services,
+	})
+	return buf.Bytes(), err
+}
+
+// validateGenerated loads the config the way the gateway will.
+func validateGenerated(generated []byte) error {
+	file, err := os.CreateTemp("", "gateway-init-*.yaml")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if _, err := io.Copy(file, bytes.NewReader(generated)); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	manager := config.NewManager()
+	if err := manager.LoadConfig(file.Name()); err != nil {
+		return err
+	}
+	return manager.ValidateConfig()
+}
@@ -2,22 +2,81 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
 	"syscall"
 	"time"
 
+	"gateway/internal/anomaly"
+	"gateway/internal/audit"
+	"gateway/internal/auth"
+	"gateway/internal/cache"
+	"gateway/internal/circuit"
 	"gateway/internal/config"
+	"gateway/internal/devauth"
+	"gateway/internal/dialer"
+	"gateway/internal/events"
+	"gateway/internal/fastpath"
+	"gateway/internal/forensics"
+	"gateway/internal/geoip"
+	"gateway/internal/handlers"
+	"gateway/internal/i18n"
+	"gateway/internal/ipaccess"
+	"gateway/internal/logfile"
+	"gateway/internal/logging"
+	"gateway/internal/maintenance"
+	"gateway/internal/middleware"
+	"gateway/internal/migration"
 	"gateway/internal/models"
+	"gateway/internal/oidc"
+	"gateway/internal/overload"
+	"gateway/internal/plans"
+	"gateway/internal/proxy"
+	"gateway/internal/quota"
+	"gateway/internal/ratelimit"
 	"gateway/internal/registry"
+	"gateway/internal/session"
+	"gateway/internal/slo"
+	"gateway/internal/snapshot"
+	"gateway/internal/store"
+	"gateway/internal/tlsconfig"
+	"gateway/internal/traffic"
+	"gateway/internal/watchdog"
+	"gateway/internal/workers"
+	"gateway/pkg/gateway"
 
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
 )
 
+const version = "1.0.0"
+
 func main() {
+	startedAt := time.Now()
+
+	if len(os.Args) > 1 && os.Args[1] == "init" {
+		if err := runInit(os.Args[2:]); err != nil {
+			log.Fatalf("init: %v", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "schema" {
+		if err := runSchema(os.Args[2:]); err != nil {
+			log.Fatalf("schema: %v", err)
+		}
+		return
+	}
+
+	devAuth := flag.Bool("dev-auth", false, "verify tokens with an embedded stub auth service for local development")
+	flag.Parse()
+
 	// Initialize configuration manager
 	configManager := config.NewManager()
 
@@ -31,14 +90,58 @@ func main() {
 		log.Fatalf("Invalid configuration: %v", err)
 	}
 
+	// Merge in the document kept in etcd or Consul KV. Without it the
+	// gateway runs with the files until a watched change can be read.
+	if remoteConfig := configManager.GetConfig().RemoteConfig; remoteConfig.Enabled {
+		source, err := store.NewRemoteSource(configManager.GetConfig())
+		if err != nil {
+			log.Fatalf("Failed to create remote config source: %v", err)
+		}
+		if err := configManager.UseRemote(source); err != nil {
+			log.Printf("Failed to load remote config, continuing with file configuration: %v", err)
+		} else {
+			log.Printf("Loaded remote config from %s", source)
+		}
+	}
+
 	cfg := configManager.GetConfig()
 
+	// Logs go to a rotated file when one is configured. Workers write
+	// files of their own, as rotating a shared one would race.
+	if cfg.Logging.OutputFile != "" {
+		path := cfg.Logging.OutputFile
+		if id := workers.ID(); id > 0 {
+			path = workerLogPath(path, id)
+		}
+		logFile, err := logfile.Open(path, cfg.Logging.MaxSize, cfg.Logging.MaxBackups)
+		if err != nil {
+			log.Fatalf("Failed to open log file: %v", err)
+		}
+		defer logFile.Close()
+		log.SetOutput(logFile)
+		gin.DefaultWriter = logFile
+		gin.DefaultErrorWriter = logFile
+	}
+
+	// With several workers this process only supervises them
+	if cfg.Server.Workers > 1 && workers.ID() == 0 {
+		if err := runSupervisor(cfg); err != nil {
+			log.Fatalf("Supervisor failed: %v", err)
+		}
+		return
+	}
+	if id := workers.ID(); id > 0 {
+		log.SetPrefix(fmt.Sprintf("[worker %d] ", id))
+	}
+
 	// Initialize service registry
 	serviceRegistry := registry.NewServiceRegistry()
 
 	// Register services from configuration
 	if len(cfg.Services) > 0 {
 		for name, serviceConfig := range cfg.Services {
+			// Routes refer to services by their config key
+			serviceConfig.Name = name
 			serviceRegistry.RegisterService(serviceConfig)
 			log.Printf("Registered service: %s at %s", name, serviceConfig.URL)
 		}
@@ -50,15 +153,75 @@ func main() {
 	if len(cfg.Routes) > 0 {
 		for _, routeConfig := range cfg.Routes {
 			serviceRegistry.RegisterRoute(routeConfig)
+			if routeConfig.IsMigration() {
+				log.Printf("Registered migration: %s -> %s", routeConfig.Path, routeConfig.MigrateTo)
+				continue
+			}
 			log.Printf("Registered route: %s -> %s", routeConfig.Path, routeConfig.ServiceName)
 		}
 	} else {
 		log.Println("No routes configured - only management endpoints available")
 	}
 
-	// Start health checking
-	serviceRegistry.StartHealthChecking(30 * time.Second)
-	log.Println("Health checker started with 30s interval")
+	// Load dynamic services and routes shared through etcd
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	reloads := []*config.ReloadStats{configManager.ReloadStats()}
+	if cfg.Etcd.Enabled {
+		etcdClient, err := store.NewEtcdClient(cfg.Etcd)
+		if err != nil {
+			log.Fatalf("Failed to create etcd client: %v", err)
+		}
+
+		routingStore := store.NewRoutingStore(etcdClient, cfg.Etcd.Prefix, serviceRegistry, configManager)
+		reloads = append(reloads, routingStore.ReloadStats())
+		if err := routingStore.Sync(backgroundCtx); err != nil {
+			log.Printf("Initial etcd sync failed, continuing with file configuration: %v", err)
+		}
+		go routingStore.Watch(backgroundCtx)
+		log.Printf("Watching etcd prefix %s for services and routes", cfg.Etcd.Prefix)
+	}
+
+	// Services and routes added through the admin API, saved in the
+	// routing file
+	runtimeRouting := store.NewRuntimeRouting(serviceRegistry, configManager, cfg.Admin.RoutingFile)
+	if err := runtimeRouting.Load(); err != nil {
+		log.Fatalf("Failed to load routing file: %v", err)
+	}
+
+	// Services and routes follow changes to the config file and SIGHUP
+	watchConfig(backgroundCtx, configManager, serviceRegistry)
+
+	// Record service status and SLA changes and notify webhooks about them.
+	// They are streamed live along with config reloads and rate limited
+	// requests.
+	eventLog := events.NewLog(cfg.Events.LogSize)
+	eventStream := events.NewStream(cfg.Events.MaxSubscribers)
+	var notifier *events.Notifier
+	if len(cfg.Events.Webhooks) > 0 {
+		notifier = events.NewNotifier(cfg.Events.Webhooks)
+		go notifier.Run(backgroundCtx)
+		log.Printf("Health event webhooks configured: %d", len(cfg.Events.Webhooks))
+	}
+	recordEvent := func(event models.HealthEvent) {
+		event = eventLog.Append(event)
+		eventStream.Publish(event)
+		log.Printf("Service %s", event.Summary())
+		// Initial results after startup or registration are not news
+		if notifier != nil && event.From != models.ServiceUnknown {
+			notifier.Notify(event)
+		}
+	}
+	serviceRegistry.OnHealthEvent(recordEvent)
+	for _, reload := range reloads {
+		reload.OnReload(eventStream.Publish)
+	}
+
+	// Start health checking; services may override the interval and live
+	// traffic feeds the passive signal
+	serviceRegistry.SetHealthCheckConfig(cfg.HealthCheck)
+	serviceRegistry.StartHealthChecking(cfg.HealthCheck.Interval)
+	log.Printf("Health checker started with %s default interval", cfg.HealthCheck.Interval)
 
 	// Set Gin mode
 	if cfg.Logging.Level == "debug" {
@@ -70,34 +233,83 @@ func main() {
 	// Create Gin router
 	router := gin.New()
 
-	// Add basic middleware
-	router.Use(gin.Logger())
-	router.Use(gin.Recovery())
+	// Client addresses come from X-Forwarded-For only when the peer is a
+	// trusted proxy; without a list every peer is
+	if len(cfg.Server.TrustedProxies) > 0 {
+		if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+			log.Fatalf("Failed to set trusted proxies: %v", err)
+		}
+	}
 
-	// Add CORS middleware
-	router.Use(func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization, X-Correlation-ID")
+	// Back off background work when the gateway itself is overloaded
+	var overloadMonitor *overload.Monitor
+	if cfg.Overload.Enabled {
+		overloadMonitor = overload.NewMonitor(cfg.Overload)
+		overloadMonitor.OnChange(func(overloaded bool) {
+			if overloaded {
+				serviceRegistry.SetHealthIntervalScale(cfg.Overload.HealthIntervalMultiplier)
+			} else {
+				serviceRegistry.SetHealthIntervalScale(1)
+			}
+		})
+		go overloadMonitor.Run(backgroundCtx)
+	}
+	// Warn with a goroutine dump before leaks take the gateway down
+	var leakWatchdog *watchdog.Watchdog
+	if cfg.Watchdog.Enabled {
+		leakWatchdog = watchdog.New(cfg.Watchdog)
+		go leakWatchdog.Run(backgroundCtx)
+	}
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
+	quietLogs := func() bool {
+		return cfg.Overload.QuietLogs && overloadMonitor.Overloaded()
+	}
+
+	// Error messages follow the client's Accept-Language
+	var catalog i18n.Catalog
+	if cfg.I18n.CatalogDir != "" {
+		loaded, err := i18n.LoadDir(cfg.I18n.CatalogDir)
+		if err != nil {
+			log.Fatalf("Failed to load message catalog: %v", err)
 		}
+		catalog = loaded
+		log.Printf("Loaded error message translations for %d locale(s)", len(loaded))
+	}
 
-		c.Next()
-	})
+	// Add basic middleware. Correlation IDs come first so every log line
+	// has one. Requests are counted and failed ones recorded outside
+	// Recovery so panics show up as the 500s they turn into.
+	router.Use(middleware.CorrelationID())
+	// The admin API changes the log level and debugs routes for a while
+	logOverrides := logging.NewOverrides(cfg.Logging.Level)
+	router.Use(middleware.AccessLog(cfg.Logging, logOverrides, gin.DefaultWriter, quietLogs))
+	router.Use(middleware.DebugRouteLogs(logOverrides, serviceRegistry))
+	requestCounters := traffic.NewCounters()
+	router.Use(middleware.CountRequests(requestCounters))
+	// Oversized headers and URLs are refused, in the client's language,
+	// before they are recorded, captured or any route sees them
+	router.Use(i18n.Middleware(i18n.NewLocalizer(catalog, cfg.I18n.DefaultLocale)))
+	router.Use(middleware.RequestLimits(cfg.Server.Limits))
+	router.Use(middleware.StreamRateLimits(eventStream, serviceRegistry))
+	var failureRecorder *forensics.Recorder
+	if cfg.Forensics.Enabled {
+		failureRecorder = forensics.NewRecorder(cfg.Forensics.Size)
+		router.Use(middleware.RecordFailures(failureRecorder, serviceRegistry, cfg.Forensics))
+	}
+	// Operators capture a route's requests on demand through the admin API
+	captures := forensics.NewCaptures(cfg.Forensics.Capture.Size)
+	router.Use(middleware.CaptureRequests(captures, serviceRegistry, cfg.Forensics))
+	router.Use(gin.Recovery())
 
-	// Health endpoints
-	router.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{
-			"status":    "healthy",
-			"timestamp": time.Now().Format(time.RFC3339),
-			"version":   "1.0.0",
-			"uptime":    "1m", // TODO: Calculate actual uptime
-		})
-	})
+	// Browsers may call the gateway from the configured origins; routes
+	// can override the policy
+	if cfg.CORS.Enabled && cfg.CORS.AnyOrigin() {
+		log.Println("CORS allows any origin; list allowed_origins to restrict browser access")
+	}
+	router.Use(middleware.CORS(cfg.CORS, serviceRegistry))
 
+	// Readiness depends on the services; /health, /health/live and
+	// /metrics are served on the fast path below
 	router.GET("/health/ready", func(c *gin.Context) {
 		services := serviceRegistry.GetAllServices()
 		allHealthy := true
@@ -134,10 +346,47 @@ func main() {
 		})
 	})
 
+	// Changes made through the admin endpoints are audited. Each worker
+	// keeps a file of its own, like its log file.
+	auditLog := audit.NewLog(cfg.Admin.Audit.LogSize)
+	if path := cfg.Admin.Audit.OutputFile; path != "" {
+		if id := workers.ID(); id > 0 {
+			path = workerLogPath(path, id)
+		}
+		var err error
+		if auditLog, err = audit.Open(path, cfg.Admin.Audit.LogSize); err != nil {
+			log.Fatalf("Failed to open audit file: %v", err)
+		}
+		defer auditLog.Close()
+		log.Printf("Auditing admin changes to %s", path)
+	}
+
+	// Administration endpoints require the admin token
+	adminAPI := router.Group("/gateway")
+	adminAPI.Use(middleware.AdminAuth(cfg.Admin), middleware.Audit(auditLog))
+	handlers.NewAuditHandler(auditLog).Register(adminAPI.Group("/audit"))
+	handlers.NewConfigHandler(configManager, cfg, serviceRegistry).Register(adminAPI.Group("/config"))
+	if failureRecorder != nil {
+		handlers.NewFailuresHandler(failureRecorder).Register(adminAPI.Group("/failures", middleware.RequireAdminScope(models.AdminScopeSecrets)))
+	}
+	handlers.NewCapturesHandler(captures, serviceRegistry, cfg.Forensics.Capture.MaxDuration).Register(adminAPI.Group("/debug/captures", middleware.RequireAdminScope(models.AdminScopeSecrets)))
+	handlers.NewLoggingHandler(logOverrides, serviceRegistry, cfg.Logging.MaxOverride).Register(adminAPI.Group("/logging"))
+	// Weighted canaries, rolled back when they fail more than stable
+	handlers.NewCanariesHandler(serviceRegistry).Register(adminAPI.Group("/canaries"))
+	handlers.NewServicesHandler(runtimeRouting).Register(adminAPI.Group("/services"))
+	// Disabling and draining services for backend deploys
+	handlers.NewTrafficHandler(serviceRegistry).Register(adminAPI.Group("/services"))
+	handlers.NewHealthCheckHandler(serviceRegistry).Register(adminAPI.Group("/services"))
+	handlers.NewRoutesHandler(runtimeRouting).Register(adminAPI.Group("/routes"))
+
 	// Gateway management endpoints
 	router.GET("/gateway/services", func(c *gin.Context) {
 		services := serviceRegistry.GetAllServices()
 		serviceList := make([]interface{}, 0, len(services))
+		held := make(map[string]registry.TrafficStatus)
+		for _, status := range serviceRegistry.HeldServices() {
+			held[status.Service] = status
+		}
 
 		for _, service := range services {
 			serviceData := map[string]interface{}{
@@ -149,6 +398,13 @@ func main() {
 			if service.ResponseTime > 0 {
 				serviceData["response_time"] = service.ResponseTime
 			}
+			if service.Flapping {
+				serviceData["flapping"] = true
+			}
+			if status, ok := held[service.Name]; ok {
+				serviceData["traffic"] = status.State
+				serviceData["in_flight"] = status.InFlight
+			}
 			serviceList = append(serviceList, serviceData)
 		}
 
@@ -173,8 +429,24 @@ func main() {
 			if route.StripPrefix {
 				routeData["strip_prefix"] = route.StripPrefix
 			}
-			if route.AuthRequired {
-				routeData["auth_required"] = route.AuthRequired
+			if mode := route.Auth(); mode != models.AuthModeNone {
+				routeData["auth_mode"] = mode
+				routeData["auth_required"] = mode == models.AuthModeRequired
+				if route.AuthProvider != "" {
+					routeData["auth_provider"] = route.AuthProvider
+				}
+			}
+			if route.ClientCert != nil {
+				routeData["client_cert"] = true
+			}
+			if route.OIDCLogin {
+				routeData["oidc_login"] = route.OIDCLogin
+			}
+			if route.WebSocket != nil {
+				routeData["websocket"] = route.WebSocket
+			}
+			if route.IsComposite() {
+				routeData["composite"] = route.Composite
 			}
 			routeList = append(routeList, routeData)
 		}
@@ -185,85 +457,1103 @@ func main() {
 		})
 	})
 
+	handlers.NewEventsHandler(eventLog, eventStream).Register(adminAPI.Group("/events", middleware.RequireAdminScope(models.AdminScopeRead)))
+
+	// Self-registration API for backends without a static config entry
+	if cfg.Registration.Enabled {
+		handlers.NewRegistrationHandler(serviceRegistry, configManager, cfg.Registration).Register(router.Group("/gateway/register"))
+		go serviceRegistry.RunLeaseReaper(backgroundCtx, time.Second)
+		log.Printf("Self-registration API enabled (default TTL %s)", cfg.Registration.DefaultTTL)
+	}
+
+	// Token bucket rate limiting for proxied routes, with adaptive penalties
+	// for clients that keep retrying into 429s
+	var limiter ratelimit.Taker
+	var penalties *ratelimit.PenaltyBox
+	if cfg.RateLimit.Enabled {
+		local := ratelimit.NewLimiter(cfg.RateLimit.GetRate(), cfg.RateLimit.Burst)
+		limiter = local
+		// Workers share the supervisor's buckets, holding their share of
+		// the limit while it cannot be reached
+		if socket := os.Getenv(workers.CoordinatorEnv); socket != "" {
+			workerCount := cfg.Server.Workers
+			burst := cfg.RateLimit.Burst / workerCount
+			if burst < 1 {
+				burst = 1
+			}
+			local = ratelimit.NewLimiter(cfg.RateLimit.GetRate()/float64(workerCount), burst)
+			limiter = ratelimit.NewClient(socket, local)
+		}
+		go local.Run(backgroundCtx, time.Minute)
+		if cfg.RateLimit.Penalty.Enabled {
+			penalties = ratelimit.NewPenaltyBox(cfg.RateLimit)
+			go penalties.Run(backgroundCtx, time.Minute)
+		}
+		log.Printf("Rate limiting enabled: %d requests per %s (%s)", cfg.RateLimit.Requests, cfg.RateLimit.Window, cfg.RateLimit.Scope)
+
+		// Support can look up and unblock clients
+		handlers.NewRateLimitsHandler(limiter, penalties).Register(adminAPI.Group("/ratelimits"))
+	}
+
+	// Proxy routes under /api, behind the pipeline assembled below
+	engine := gateway.New(gateway.WithRouter(router), gateway.WithRegistry(serviceRegistry))
+	proxyHandler := engine.Proxy()
+
+	// During maintenance proxied routes answer 503 before anything else
+	// sees them; health and admin endpoints are not behind the pipeline
+	maintenanceMode, err := maintenance.New(cfg.Maintenance)
+	if err != nil {
+		log.Fatalf("Failed to set up maintenance mode: %v", err)
+	}
+	if cfg.Maintenance.Enabled {
+		log.Println("Starting in maintenance mode")
+	}
+	engine.Use(middleware.Maintenance(maintenanceMode))
+	handlers.NewMaintenanceHandler(maintenanceMode).Register(adminAPI.Group("/maintenance"))
+
+	proxyHandler.SetValidationErrorMode(cfg.ValidationErrors)
+	var anomalies *anomaly.Detector
+	if cfg.Anomalies.Enabled {
+		anomalies = anomaly.NewDetector(cfg.Anomalies, recordEvent)
+		proxyHandler.SetAnomalyDetector(anomalies)
+		log.Printf("Sampling %.0f%% of upstream responses for content anomalies", cfg.Anomalies.SampleRate*100)
+	}
+	var breakers *circuit.Breakers
+	if cfg.CircuitBreaker.Enabled {
+		breakers = circuit.NewBreakers(cfg.CircuitBreaker, recordEvent)
+		// Operators can open a circuit for maintenance and close one that
+		// tripped by mistake
+		handlers.NewCircuitBreakersHandler(breakers, serviceRegistry).Register(adminAPI.Group("/circuit-breakers"))
+	}
+	// Which route and policies a request would get, for debugging routing
+	handlers.NewResolveHandler(serviceRegistry, cfg, breakers, maintenanceMode).Register(adminAPI.Group("/routes"))
+	// Snapshots of services, routes and overrides, to move the gateway's
+	// state between environments or restore it; they carry credentials
+	handlers.NewSnapshotHandler(&snapshot.Gateway{
+		Manager:        configManager,
+		Registry:       serviceRegistry,
+		Routing:        runtimeRouting,
+		Maintenance:    maintenanceMode,
+		Logging:        logOverrides,
+		MaxLogOverride: cfg.Logging.MaxOverride,
+		Breakers:       breakers,
+	}).Register(adminAPI.Group("", middleware.RequireAdminScope(models.AdminScopeSecrets)))
+	upstreamDialer := dialer.New(cfg.UpstreamDial, nil)
+	transport := proxyHandler.Transport()
+	transport.DialContext = upstreamDialer.DialContext
+	if leakWatchdog != nil {
+		transport.DialContext = leakWatchdog.CountDials(transport.DialContext)
+	}
+
+	// Profiling and runtime statistics for diagnosing production latency.
+	// Profiles can expose the command line, so they need the secrets scope.
+	if cfg.Debug.Enabled {
+		debugHandler := handlers.NewDebugHandler(upstreamDialer.Connections)
+		debugHandler.Register(adminAPI.Group("/debug"))
+		debugHandler.RegisterProfiles(router.Group("/debug/pprof", middleware.AdminAuth(cfg.Admin), middleware.RequireAdminScope(models.AdminScopeSecrets)))
+		log.Println("Debug endpoints enabled under /debug/pprof and /gateway/debug")
+	}
+
+	// Daily and monthly allowances of API keys, and usage plans
+	var quotas *quota.Quotas
+	var usagePlans *plans.Plans
+	if cfg.Quota.Enabled || cfg.Plans.Enabled {
+		var store quota.Store
+		if cfg.Quota.Store == models.QuotaStoreRedis {
+			store = quota.NewRedisStore(newRedisClient(cfg), cfg.Redis.KeyPrefix+"quota:")
+		} else {
+			memoryStore := quota.NewMemoryStore()
+			go memoryStore.Run(backgroundCtx, time.Hour)
+			store = memoryStore
+		}
+		if cfg.Quota.Enabled {
+			quotas = quota.New(cfg.Quota, store)
+			log.Printf("API key quotas enabled (%s store)", cfg.Quota.Store)
+		}
+		if cfg.Plans.Enabled {
+			var err error
+			if usagePlans, err = plans.New(cfg.Plans, cfg.Quota, store); err != nil {
+				log.Fatalf("Failed to set up usage plans: %v", err)
+			}
+			log.Printf("Usage plans enabled: %d tiers, %d API keys", len(cfg.Plans.Tiers), len(cfg.Plans.APIKeys))
+		}
+	}
+
+	// Client countries, for routes' geo_access rules and requests by country
+	var geoDatabase *geoip.Database
+	var geoCounts *geoip.Counts
+	if cfg.GeoIP.Enabled {
+		var err error
+		if geoDatabase, err = geoip.Open(cfg.GeoIP.Database); err != nil {
+			log.Fatalf("Failed to open GeoIP database: %v", err)
+		}
+		geoCounts = geoip.NewCounts()
+		watchGeoIP(backgroundCtx, geoDatabase)
+		log.Printf("GeoIP lookups enabled with %s database %s", geoDatabase.Type(), cfg.GeoIP.Database)
+	}
+
 	router.GET("/gateway/metrics", func(c *gin.Context) {
 		stats := serviceRegistry.GetServiceStats()
 
 		c.JSON(http.StatusOK, gin.H{
-			"timestamp": time.Now().Format(time.RFC3339),
-			"requests": gin.H{
-				"total":             0, // TODO: Implement request counting
-				"success":           0,
-				"errors":            0,
-				"avg_response_time": 0.0,
-			},
-			"rate_limits": gin.H{
-				"active_limiters":   0, // TODO: Implement rate limiting metrics
-				"blocked_requests":  0,
-			},
-			"circuit_breakers": gin.H{}, // TODO: Implement circuit breaker metrics
+			"timestamp":        time.Now().Format(time.RFC3339),
+			"requests":         requestCounters.Stats(),
+			"rate_limits":      rateLimitStats(limiter, penalties),
+			"circuit_breakers": circuitStats(breakers),
 			"services":         stats,
+			"health_checks":    serviceRegistry.HealthChecks(),
+			"overload":         overloadStats(overloadMonitor),
+			"watchdog":         watchdogStats(leakWatchdog),
+			"websockets":       proxyHandler.WebSockets().Stats(),
+			"bulkheads":        proxyHandler.Bulkhead().Stats(),
+			"retries":          proxyHandler.Retries().Stats(),
+			"route_limits":     proxyHandler.RouteLimits().Routes(),
+			"terminations":     proxyHandler.Terminations().Stats(),
+			"upstream_dialing": upstreamDialer.Stats(),
+			"config_reloads":   reloadStats(reloads),
+			"quotas":           quotaStats(quotas),
+			"plans":            planStats(usagePlans),
+			"anomalies":        anomalyStats(anomalies),
+			"geo":              geoStats(geoCounts),
 		})
 	})
+	routeLatencies := traffic.NewLatencies(traffic.DefaultLatencyWindow, traffic.DefaultLatencySlots)
+	handlers.NewLatencyHandler(routeLatencies).Register(router.Group("/gateway/metrics/latency"))
 
-	// Proxy routes - simplified implementation
-	router.Any("/api/*proxyPath", func(c *gin.Context) {
-		method := c.Request.Method
-		path := c.Request.URL.Path
+	// Route latencies include the time spent in the gateway's own middleware
+	engine.Use(middleware.TrackLatency(routeLatencies, serviceRegistry))
 
-		route, service := serviceRegistry.FindRoute(method, path)
-		if route == nil || service == nil {
-			c.JSON(http.StatusNotFound, gin.H{
-				"error":   "Route not found",
-				"message": fmt.Sprintf("No route found for %s %s", method, path),
-			})
-			return
+	// Availability and latency objectives of routes and services, judged
+	// like route latencies
+	sloTracker := slo.NewTracker(serviceRegistry)
+	handlers.NewSLOHandler(sloTracker).Register(router.Group("/gateway/slo"))
+	engine.Use(middleware.TrackSLOs(sloTracker, serviceRegistry))
+
+	// Routes' timeouts cover all of the middleware below as well as the
+	// upstream
+	engine.Use(middleware.RouteTimeout(serviceRegistry))
+
+	// Expensive routes take more than one token from rate limit buckets
+	if limiter != nil || cfg.Plans.Enabled {
+		engine.Use(middleware.RouteCost(serviceRegistry))
+	}
+	if limiter != nil && cfg.RateLimit.Scope != models.ScopePerUser {
+		engine.Use(middleware.RateLimit(cfg.RateLimit, limiter, penalties))
+	}
+
+	if quotas != nil {
+		engine.Use(middleware.Quota(quotas))
+	}
+
+	// Aliases for moved paths, counted until nobody uses them anymore
+	migrationTracker := migration.NewTracker()
+	engine.Use(middleware.Migrations(serviceRegistry, migrationTracker))
+	handlers.NewMigrationsHandler(migrationTracker).Register(adminAPI.Group("/migrations"))
+
+	// Allow and deny lists, for the gateway and per route, checked once
+	// the path a request ends up on is known
+	ipPolicies, err := ipaccess.NewPolicies(cfg.IPAccess)
+	if err != nil {
+		log.Fatalf("Failed to set up IP access lists: %v", err)
+	}
+	if len(cfg.Server.TrustedProxies) == 0 && ipAccessConfigured(cfg) {
+		log.Println("Warning: ip_access is configured without server.trusted_proxies, any client can pick its address with X-Forwarded-For")
+	}
+	engine.Use(middleware.IPAccess(ipPolicies, serviceRegistry))
+	if geoDatabase != nil {
+		if len(cfg.Server.TrustedProxies) == 0 {
+			log.Println("Warning: geoip is enabled without server.trusted_proxies, any client can pick its country with X-Forwarded-For")
 		}
+		engine.Use(middleware.GeoIP(geoDatabase, geoCounts, serviceRegistry))
+	}
 
-		// For now, just return a simple response indicating the route was found
-		// TODO: Implement actual reverse proxy logic
-		c.JSON(http.StatusOK, gin.H{
-			"message":     "Proxy endpoint found",
-			"route":       route.Path,
-			"service":     service.Name,
-			"target_url":  service.URL,
-			"method":      method,
-			"path":        path,
-		})
-	})
+	// Webhook ingress routes must prove they come from the provider
+	engine.Use(middleware.VerifySignatures(serviceRegistry))
+
+	// Partner routes authenticate with client certificates the listener
+	// verified instead of tokens
+	engine.Use(middleware.ClientCertAuth(serviceRegistry))
+
+	// Session subsystem shared by the browser login modes
+	var sessionManager *session.Manager
+	if cfg.BFF.Enabled || cfg.OIDC.Enabled || cfg.SessionAuth.Enabled {
+		var err error
+		if sessionManager, err = newSessionManager(cfg); err != nil {
+			log.Fatalf("Failed to initialize sessions: %v", err)
+		}
+
+		handlers.NewSessionHandler(sessionManager).Register(adminAPI.Group("/sessions"))
+
+		go func() {
+			ticker := time.NewTicker(5 * time.Minute)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					sessionManager.Cleanup()
+				case <-backgroundCtx.Done():
+					return
+				}
+			}
+		}()
+		log.Printf("Session store: %s", cfg.Session.Store)
+	}
+	authClient := auth.NewClient(cfg.Auth.ServiceURL, cfg.Auth.Timeout)
+
+	// Server-rendered frontends authenticate with the session cookie alone,
+	// next to bearer tokens on the same routes
+	if cfg.SessionAuth.Enabled {
+		handlers.NewSessionAuthHandler(sessionManager, authClient).Register(router.Group("/session"))
+		engine.Use(middleware.SessionAuth(sessionManager, serviceRegistry, cfg.SessionAuth))
+		log.Println("Session cookie authentication enabled")
+	}
+
+	// OIDC login for browser-facing routes; simple UIs get the identity as
+	// headers instead of implementing auth themselves
+	if cfg.OIDC.Enabled {
+		oidcClient := oidc.NewClient(cfg.OIDC)
+		handlers.NewOIDCHandler(oidcClient, sessionManager).Register(router.Group("/oidc"))
+		engine.Use(middleware.OIDCLogin(oidcClient, sessionManager, serviceRegistry))
+		log.Printf("OIDC login enabled (issuer %s)", cfg.OIDC.Issuer)
+	}
+
+	// BFF token relay: the SPA authenticates with a session cookie and the
+	// gateway attaches the access token held server-side
+	if cfg.BFF.Enabled {
+		handlers.NewBFFHandler(sessionManager, authClient).Register(router.Group("/bff"))
+		engine.Use(middleware.TokenRelay(sessionManager, authClient, cfg.BFF))
+		log.Println("BFF token relay enabled")
+	}
+
+	// Routes with auth_mode required need a token the auth service accepts,
+	// optional routes check one if sent; results are cached so every
+	// request does not cost a round trip
+	var verifier auth.Verifier = authClient
+	if *devAuth {
+		issuer, err := devauth.New([]byte(os.Getenv("GATEWAY_DEV_AUTH_SECRET")))
+		if err != nil {
+			log.Fatalf("Failed to start dev auth: %v", err)
+		}
+		handlers.NewDevAuthHandler(issuer).Register(router.Group("/dev/auth"))
+		verifier = issuer
+		log.Println("WARNING: dev auth enabled - anyone can get a token at POST /dev/auth/token; never use outside local development")
+	} else if cfg.Auth.CacheTTL > 0 {
+		verificationCache := auth.NewCachingVerifier(authClient, cfg.Auth.CacheTTL, cfg.Auth.NegativeCacheTTL, cfg.Auth.CacheSize)
+		handlers.NewAuthCacheHandler(verificationCache).Register(adminAPI.Group("/auth/cache"))
+		verifier = verificationCache
+		log.Printf("Token verification cache enabled (ttl %s)", cfg.Auth.CacheTTL)
+	}
+
+	// Routes naming an auth_provider are verified by that identity system
+	providers := auth.Providers{"": verifier}
+	for name, provider := range cfg.AuthProviders {
+		if *devAuth {
+			providers[name] = verifier
+			continue
+		}
+		providerClient := auth.NewClient(provider.ServiceURL, provider.Timeout)
+		if provider.VerifyPath != "" {
+			providerClient.SetVerifyPath(provider.VerifyPath)
+		}
+		providers[name] = providerClient
+		if provider.CacheTTL > 0 {
+			providerCache := auth.NewCachingVerifier(providerClient, provider.CacheTTL, provider.NegativeCacheTTL, provider.CacheSize)
+			handlers.NewAuthCacheHandler(providerCache).Register(adminAPI.Group("/auth/providers/" + name + "/cache"))
+			providers[name] = providerCache
+		}
+		log.Printf("Auth provider %s at %s", name, provider.ServiceURL)
+	}
+
+	// Revoked tokens are cut off before they expire, whichever provider
+	// or cache still accepts them
+	if revocation := cfg.Auth.Revocation; revocation.Enabled {
+		var source auth.RevocationSource
+		if revocation.Source == models.RevocationSourceRedis {
+			source = auth.NewRedisRevocationSource(newRedisClient(cfg), cfg.Redis.KeyPrefix)
+		} else {
+			source = auth.NewHTTPRevocationSource(cfg.Auth.ServiceURL, revocation.Path, cfg.Auth.Timeout)
+		}
+		denylist := auth.NewDenylist(source, revocation.Interval)
+		for name, verifier := range providers {
+			providers[name] = denylist.Wrap(verifier)
+		}
+		handlers.NewRevocationsHandler(denylist).Register(adminAPI.Group("/auth/revocations"))
+		go denylist.Run(backgroundCtx)
+		log.Printf("Token revocation checks enabled (%s, every %s)", revocation.Source, revocation.Interval)
+	}
+	engine.Use(middleware.AuthenticateProviders(providers, serviceRegistry, cfg.Auth))
+	// Per-user limits key on the identity, so they wait for it
+	if limiter != nil && cfg.RateLimit.Scope == models.ScopePerUser {
+		engine.Use(middleware.RateLimit(cfg.RateLimit, limiter, penalties))
+	}
+	// Plans can follow the user as well
+	if usagePlans != nil {
+		engine.Use(middleware.Plans(usagePlans))
+	}
+	engine.Use(middleware.PropagateIdentity(cfg.Auth.IdentityHeaders))
+
+	// Response cache for routes with a cache_ttl, warmed on schedule
+	var warmer *cache.Warmer
+	if cfg.Cache.Enabled {
+		responseCache := cache.New(cfg.Cache.MaxEntries)
+		engine.Use(middleware.ResponseCache(responseCache, serviceRegistry, cfg.Cache.MaxBodySize))
+		if len(cfg.Cache.Warmup.Paths) > 0 {
+			warmer = cache.NewWarmer(router, cfg.Cache.Warmup)
+		}
+		log.Printf("Response cache enabled (max %d entries)", cfg.Cache.MaxEntries)
+	}
+
+	// Last before the proxy, so cached answers are still served while a
+	// service's circuit is open
+	if breakers != nil {
+		engine.Use(middleware.CircuitBreaker(breakers, serviceRegistry))
+	}
+
+	// Open idle connections to critical upstreams before taking traffic
+	prewarmCtx, cancelPrewarm := context.WithTimeout(backgroundCtx, 10*time.Second)
+	if warmed := proxyHandler.Prewarm(prewarmCtx); warmed > 0 {
+		log.Printf("Pre-warmed %d upstream connections", warmed)
+	}
+	cancelPrewarm()
+	go proxyHandler.KeepWarm(backgroundCtx)
+
+	// Probes and scrapes bypass the gin pipeline entirely so they stay fast
+	// while it is under stress
+	probes := fastpath.New(engine.Handler())
+	probes.Handle("/health", fastpath.Health(version, startedAt))
+	probes.Handle("/health/live", fastpath.Live)
+	probes.Handle("/metrics", fastpath.Metrics(startedAt, func() []fastpath.Metric {
+		metrics := gatewayMetrics(serviceRegistry, limiter, leakWatchdog, proxyHandler.WebSockets(), reloads)
+		metrics = append(metrics, requestMetrics(requestCounters)...)
+		metrics = append(metrics, routeLimitMetrics(proxyHandler.RouteLimits())...)
+		metrics = append(metrics, terminationMetrics(proxyHandler.Terminations())...)
+		metrics = append(metrics, geoMetrics(geoCounts)...)
+		metrics = append(metrics, circuitMetrics(breakers)...)
+		metrics = append(metrics, retryMetrics(proxyHandler.Retries())...)
+		metrics = append(metrics, sloMetrics(sloTracker)...)
+		metrics = append(metrics, healthCheckMetrics(serviceRegistry)...)
+		return append(metrics, anomalyMetrics(anomalies)...)
+	}))
 
 	// Create HTTP server
 	server := &http.Server{
-		Addr:         configManager.GetServerAddress(),
-		Handler:      router,
-		ReadTimeout:  cfg.Server.ReadTimeout,
-		WriteTimeout: cfg.Server.WriteTimeout,
-		IdleTimeout:  cfg.Server.IdleTimeout,
+		Addr:           configManager.GetServerAddress(),
+		Handler:        probes,
+		ReadTimeout:    cfg.Server.ReadTimeout,
+		WriteTimeout:   cfg.Server.WriteTimeout,
+		IdleTimeout:    cfg.Server.IdleTimeout,
+		MaxHeaderBytes: maxHeaderBytes(cfg.Server.Limits),
+	}
+	if leakWatchdog != nil {
+		server.ConnState = leakWatchdog.TrackConnState
+	}
+
+	// Certificates come from an ACME authority, answering its challenges
+	// on the HTTPS listener and the plain HTTP port, or from files
+	var challengeServer *http.Server
+	if acme := cfg.Server.TLS.ACME; acme.Enabled {
+		certificates := tlsconfig.NewACME(acme)
+		tlsConfig, err := tlsconfig.ACMEServer(cfg.Server.TLS, certificates)
+		if err != nil {
+			log.Fatalf("Failed to configure TLS: %v", err)
+		}
+		server.TLSConfig = tlsConfig
+		log.Printf("Obtaining certificates for %s through ACME, cached in %s", strings.Join(acme.Domains, ", "), acme.CacheDir)
+		if acme.HTTPPort > 0 {
+			challengeServer = &http.Server{
+				Addr:         fmt.Sprintf("%s:%d", cfg.Server.Host, acme.HTTPPort),
+				Handler:      certificates.HTTPHandler(nil),
+				ReadTimeout:  cfg.Server.ReadTimeout,
+				WriteTimeout: cfg.Server.WriteTimeout,
+				IdleTimeout:  cfg.Server.IdleTimeout,
+			}
+			go func() {
+				log.Printf("ACME challenges and HTTPS redirects on %s", challengeServer.Addr)
+				if err := challengeServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+					log.Printf("ACME HTTP listener failed, only TLS-ALPN challenges are answered: %v", err)
+				}
+			}()
+		}
+	} else if cfg.Server.TLS.Enabled() {
+		certificate, err := tlsconfig.LoadCertificate(cfg.Server.TLS)
+		if err != nil {
+			log.Fatalf("Failed to configure TLS: %v", err)
+		}
+		tlsConfig, err := tlsconfig.Server(cfg.Server.TLS, certificate)
+		if err != nil {
+			log.Fatalf("Failed to configure TLS: %v", err)
+		}
+		server.TLSConfig = tlsConfig
+		watchCertificate(backgroundCtx, certificate)
+	}
+
+	// Workers share the port; the kernel spreads connections across them
+	listener, err := workers.Listen(server.Addr, workers.ID() > 0)
+	if err != nil {
+		log.Fatalf("Server failed to start: %v", err)
 	}
 
 	// Start server in a goroutine
 	go func() {
-		log.Printf("Server listening on %s", server.Addr)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Server failed to start: %v", err)
+		var err error
+		if server.TLSConfig != nil {
+			log.Printf("Server listening on %s (TLS)", server.Addr)
+			err = server.ServeTLS(listener, "", "")
+		} else {
+			log.Printf("Server listening on %s", server.Addr)
+			err = server.Serve(listener)
+		}
+		if err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Server failed: %v", err)
 		}
 	}()
 
+	if warmer != nil {
+		go warmer.Start(backgroundCtx)
+	}
+
 	// Wait for interrupt signal to gracefully shutdown the server
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	log.Println("Shutting down server...")
 
-	// Stop health checking
+	// Stop health checking and background watchers
 	serviceRegistry.StopHealthChecking()
+	stopBackground()
 
 	// Give server 30 seconds to shutdown gracefully
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	if challengeServer != nil {
+		challengeServer.Shutdown(ctx)
+	}
 	if err := server.Shutdown(ctx); err != nil {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
 
 	log.Println("Server exited")
-}
\ No newline at end of file
+}
+
+func newSessionManager(cfg *models.GatewayConfig) (*session.Manager, error) {
+	keys := cfg.Session.Keys
+	if len(keys) == 0 {
+		log.Println("WARNING: session.keys not set - generated an ephemeral key, sessions will not survive restarts")
+		key, err := session.GenerateKey()
+		if err != nil {
+			return nil, err
+		}
+		keys = []string{key}
+	}
+	keyring, err := session.NewKeyring(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	var store session.Store
+	switch cfg.Session.Store {
+	case models.SessionStoreMemory:
+		store = session.NewMemoryStore()
+	case models.SessionStoreRedis:
+		store = session.NewRedisStore(newRedisClient(cfg), cfg.Redis.KeyPrefix)
+	case models.SessionStoreCookie:
+		// Sessions travel sealed inside the cookie itself
+	}
+
+	sameSite := http.SameSiteLaxMode
+	switch strings.ToLower(cfg.Session.SameSite) {
+	case "strict":
+		sameSite = http.SameSiteStrictMode
+	case "none":
+		sameSite = http.SameSiteNoneMode
+	}
+
+	cookie := session.CookieOptions{
+		Name:     cfg.Session.CookieName,
+		Domain:   cfg.Session.CookieDomain,
+		Secure:   cfg.Session.CookieSecure,
+		SameSite: sameSite,
+	}
+	manager := session.NewManager(store, keyring, cookie, cfg.Session.TTL)
+	manager.SetMaxPerUser(cfg.Session.MaxPerUser)
+	return manager, nil
+}
+
+func newRedisClient(cfg *models.GatewayConfig) *redis.Client {
+	return redis.NewClient(&redis.Options{
+		Addr:     cfg.Redis.Address,
+		Password: cfg.Redis.Password,
+		DB:       cfg.Redis.DB,
+	})
+}
+
+// maxHeaderBytes is how much of a request's head the listener reads at
+// most. It leaves room for the URL and headers the limits allow, so a
+// request just over them gets the gateway's error body, while a larger one
+// is cut off before it is read in full. Without both limits net/http's
+// default applies.
+func maxHeaderBytes(limits models.RequestLimits) int {
+	if limits.MaxHeaderBytes == 0 || limits.MaxURLLength == 0 {
+		return 0
+	}
+	return limits.MaxURLLength + limits.MaxHeaderBytes
+}
+
+// ipAccessConfigured reports whether the gateway or any configured route
+// has IP allow or deny lists.
+func ipAccessConfigured(cfg *models.GatewayConfig) bool {
+	if len(cfg.IPAccess.Allow) > 0 || len(cfg.IPAccess.Deny) > 0 {
+		return true
+	}
+	for _, route := range cfg.Routes {
+		if route.IPAccess != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func gatewayMetrics(serviceRegistry *registry.ServiceRegistry, limiter ratelimit.Taker, leakWatchdog *watchdog.Watchdog, websockets *proxy.WebSocketTracker, reloads []*config.ReloadStats) []fastpath.Metric {
+	metrics := make([]fastpath.Metric, 0)
+	services := serviceRegistry.GetAllServices()
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		service := services[name]
+		up := 0.0
+		if service.IsHealthy() {
+			up = 1
+		}
+		metrics = append(metrics, fastpath.Metric{
+			Name:   "gateway_service_up",
+			Help:   "Whether the service is healthy (1) or not (0).",
+			Labels: map[string]string{"service": name, "status": string(service.Status)},
+			Value:  up,
+		})
+	}
+
+	if limiter != nil {
+		blocked, _ := limiter.Stats()["blocked_requests"].(int64)
+		metrics = append(metrics, fastpath.Metric{
+			Name:    "gateway_rate_limited_requests_total",
+			Help:    "Requests rejected by the rate limiter.",
+			Value:   float64(blocked),
+			Counter: true,
+		})
+	}
+
+	if leakWatchdog != nil {
+		stats := leakWatchdog.Stats()
+		if files := stats["open_files"].(int); files >= 0 {
+			metrics = append(metrics, fastpath.Metric{
+				Name:  "gateway_open_files",
+				Help:  "Open file descriptors at the last watchdog sample.",
+				Value: float64(files),
+			})
+		}
+		metrics = append(metrics,
+			fastpath.Metric{
+				Name:  "gateway_client_connections",
+				Help:  "Open client connections.",
+				Value: float64(stats["client_connections"].(int64)),
+			},
+			fastpath.Metric{
+				Name:  "gateway_upstream_connections",
+				Help:  "Open upstream connections.",
+				Value: float64(stats["upstream_connections"].(int64)),
+			},
+			fastpath.Metric{
+				Name:    "gateway_watchdog_warnings_total",
+				Help:    "Watchdog samples that exceeded a threshold.",
+				Value:   float64(stats["warnings"].(int64)),
+				Counter: true,
+			},
+		)
+	}
+
+	active := websockets.Active()
+	routes := make([]string, 0, len(active))
+	for route := range active {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+	for _, route := range routes {
+		metrics = append(metrics, fastpath.Metric{
+			Name:   "gateway_websocket_connections",
+			Help:   "Open proxied WebSocket connections.",
+			Labels: map[string]string{"route": route},
+			Value:  float64(active[route]),
+		})
+	}
+	stats := websockets.Stats()
+	metrics = append(metrics,
+		fastpath.Metric{
+			Name:    "gateway_websocket_rejected_total",
+			Help:    "WebSocket upgrades rejected by connection caps.",
+			Value:   float64(stats["rejected"].(int64)),
+			Counter: true,
+		},
+		fastpath.Metric{
+			Name:    "gateway_websocket_closed_total",
+			Help:    "WebSocket connections closed by the gateway.",
+			Labels:  map[string]string{"reason": "message_too_big"},
+			Value:   float64(stats["closed_too_big"].(int64)),
+			Counter: true,
+		},
+		fastpath.Metric{
+			Name:    "gateway_websocket_closed_total",
+			Help:    "WebSocket connections closed by the gateway.",
+			Labels:  map[string]string{"reason": "idle"},
+			Value:   float64(stats["closed_idle"].(int64)),
+			Counter: true,
+		},
+	)
+
+	// Samples of one metric have to be adjacent across sources
+	var configMetrics []fastpath.Metric
+	for _, reload := range reloads {
+		configMetrics = append(configMetrics, reloadMetrics(reload)...)
+	}
+	sort.SliceStable(configMetrics, func(i, j int) bool {
+		return configMetrics[i].Name < configMetrics[j].Name
+	})
+	return append(metrics, configMetrics...)
+}
+
+// reloadMetrics lets alerting catch gateways that stop picking up config
+// changes: failing reloads, a stale last success, or a hash that differs
+// from the rest of the fleet.
+func reloadMetrics(reload *config.ReloadStats) []fastpath.Metric {
+	stats := reload.Stats()
+	source := reload.Source()
+	metrics := []fastpath.Metric{
+		{
+			Name:    "gateway_config_reloads_total",
+			Help:    "Config reload attempts by outcome.",
+			Labels:  map[string]string{"source": source, "result": "success"},
+			Value:   float64(stats["successes"].(int64)),
+			Counter: true,
+		},
+		{
+			Name:    "gateway_config_reloads_total",
+			Help:    "Config reload attempts by outcome.",
+			Labels:  map[string]string{"source": source, "result": "load_error"},
+			Value:   float64(stats["load_failures"].(int64)),
+			Counter: true,
+		},
+		{
+			Name:    "gateway_config_reloads_total",
+			Help:    "Config reload attempts by outcome.",
+			Labels:  map[string]string{"source": source, "result": "validation_error"},
+			Value:   float64(stats["validation_failures"].(int64)),
+			Counter: true,
+		},
+		{
+			Name:    "gateway_config_reload_changes_total",
+			Help:    "Services, routes and settings changed by applied reloads.",
+			Labels:  map[string]string{"source": source},
+			Value:   float64(stats["changes"].(int64)),
+			Counter: true,
+		},
+		{
+			Name:   "gateway_config_last_reload_diff_size",
+			Help:   "Entries changed by the last applied reload.",
+			Labels: map[string]string{"source": source},
+			Value:  float64(stats["last_diff_size"].(int)),
+		},
+		{
+			Name:   "gateway_config_info",
+			Help:   "Hash of the active configuration.",
+			Labels: map[string]string{"source": source, "hash": stats["config_hash"].(string)},
+			Value:  1,
+		},
+	}
+	if success := reload.LastSuccess(); !success.IsZero() {
+		metrics = append(metrics, fastpath.Metric{
+			Name:   "gateway_config_last_reload_timestamp_seconds",
+			Help:   "Unix time of the last reload by outcome.",
+			Labels: map[string]string{"source": source, "result": "success"},
+			Value:  float64(success.Unix()),
+		})
+	}
+	if failure := reload.LastFailure(); !failure.IsZero() {
+		metrics = append(metrics, fastpath.Metric{
+			Name:   "gateway_config_last_reload_timestamp_seconds",
+			Help:   "Unix time of the last reload by outcome.",
+			Labels: map[string]string{"source": source, "result": "failure"},
+			Value:  float64(failure.Unix()),
+		})
+	}
+	return metrics
+}
+
+func reloadStats(reloads []*config.ReloadStats) gin.H {
+	stats := gin.H{}
+	for _, reload := range reloads {
+		stats[reload.Source()] = reload.Stats()
+	}
+	return stats
+}
+
+func rateLimitStats(limiter ratelimit.Taker, penalties *ratelimit.PenaltyBox) gin.H {
+	if limiter == nil {
+		return gin.H{"enabled": false}
+	}
+	stats := gin.H{"enabled": true}
+	for key, value := range limiter.Stats() {
+		stats[key] = value
+	}
+	if penalties != nil {
+		stats["penalties"] = penalties.Stats()
+	}
+	return stats
+}
+
+func watchdogStats(leakWatchdog *watchdog.Watchdog) gin.H {
+	if leakWatchdog == nil {
+		return gin.H{"enabled": false}
+	}
+	stats := gin.H{"enabled": true}
+	for key, value := range leakWatchdog.Stats() {
+		stats[key] = value
+	}
+	return stats
+}
+
+func geoStats(counts *geoip.Counts) gin.H {
+	if counts == nil {
+		return gin.H{"enabled": false}
+	}
+	stats := gin.H{"enabled": true}
+	for key, value := range counts.Stats() {
+		stats[key] = value
+	}
+	return stats
+}
+
+func quotaStats(quotas *quota.Quotas) gin.H {
+	if quotas == nil {
+		return gin.H{"enabled": false}
+	}
+	stats := gin.H{"enabled": true}
+	for key, value := range quotas.Stats() {
+		stats[key] = value
+	}
+	return stats
+}
+
+func planStats(usagePlans *plans.Plans) gin.H {
+	if usagePlans == nil {
+		return gin.H{"enabled": false}
+	}
+	return gin.H{"enabled": true, "tiers": usagePlans.Stats()}
+}
+
+// routeLimitMetrics reports the share of its configured limit each limited
+// route currently allows, which adaptive limits lower while the route's
+// service struggles.
+func routeLimitMetrics(routeLimits *ratelimit.RouteLimits) []fastpath.Metric {
+	routes := routeLimits.Routes()
+	metrics := make([]fastpath.Metric, 0, len(routes))
+	for _, route := range routes {
+		metrics = append(metrics, fastpath.Metric{
+			Name:   "gateway_route_limit_factor",
+			Help:   "Share of the route's configured rate limit currently allowed.",
+			Labels: map[string]string{"route": route.Route},
+			Value:  route.Factor,
+		})
+	}
+	return metrics
+}
+
+// requestMetrics reports the requests answered through the router, by
+// outcome, and the time spent answering them.
+func requestMetrics(counters *traffic.Counters) []fastpath.Metric {
+	snapshot := counters.Snapshot()
+	metrics := make([]fastpath.Metric, 0, 4)
+	for _, count := range []struct {
+		result string
+		value  int64
+	}{
+		{"success", snapshot.Success},
+		{"client_error", snapshot.ClientErrors},
+		{"server_error", snapshot.ServerErrors},
+	} {
+		metrics = append(metrics, fastpath.Metric{
+			Name:    "gateway_requests_total",
+			Help:    "Requests answered by the gateway, by result.",
+			Labels:  map[string]string{"result": count.result},
+			Value:   float64(count.value),
+			Counter: true,
+		})
+	}
+	return append(metrics, fastpath.Metric{
+		Name:    "gateway_request_duration_seconds_total",
+		Help:    "Time spent answering requests.",
+		Value:   snapshot.ResponseTime.Seconds(),
+		Counter: true,
+	})
+}
+
+// terminationMetrics reports failed proxied requests by why they failed
+// and the service they were for.
+func terminationMetrics(terminations *proxy.Terminations) []fastpath.Metric {
+	counts := terminations.Counts()
+	metrics := make([]fastpath.Metric, 0, len(counts))
+	for _, count := range counts {
+		labels := map[string]string{"reason": count.Reason}
+		if count.Service != "" {
+			labels["service"] = count.Service
+		}
+		metrics = append(metrics, fastpath.Metric{
+			Name:    "gateway_request_terminations_total",
+			Help:    "Proxied requests that ended with a 4xx or 5xx status, by reason.",
+			Labels:  labels,
+			Value:   float64(count.Count),
+			Counter: true,
+		})
+	}
+	return metrics
+}
+
+// geoMetrics reports requests by the client's country and whether they
+// succeeded, none without geoip.
+func geoMetrics(counts *geoip.Counts) []fastpath.Metric {
+	if counts == nil {
+		return nil
+	}
+	metrics := make([]fastpath.Metric, 0)
+	for _, count := range counts.Counts() {
+		metrics = append(metrics, fastpath.Metric{
+			Name:    "gateway_country_requests_total",
+			Help:    "Requests by the client's country and result.",
+			Labels:  map[string]string{"country": count.Country, "result": count.Result},
+			Value:   float64(count.Count),
+			Counter: true,
+		})
+	}
+	return metrics
+}
+
+// workerLogPath is the log file of a worker: gateway.log becomes
+// gateway.worker-1.log.
+func workerLogPath(path string, id int) string {
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s.worker-%d%s", strings.TrimSuffix(path, ext), id, ext)
+}
+
+func circuitStats(breakers *circuit.Breakers) gin.H {
+	if breakers == nil {
+		return gin.H{"enabled": false}
+	}
+	return breakers.Stats()
+}
+
+// circuitMetrics reports, per service called so far and route with a
+// circuit of its own, whether the circuit is turning requests away.
+func circuitMetrics(breakers *circuit.Breakers) []fastpath.Metric {
+	if breakers == nil {
+		return nil
+	}
+	circuits := breakers.Circuits()
+	metrics := make([]fastpath.Metric, 0, 2*len(circuits))
+	for _, c := range circuits {
+		open := 0.0
+		if c.State == models.CircuitOpen {
+			open = 1
+		}
+		metrics = append(metrics, fastpath.Metric{
+			Name:   "gateway_circuit_open",
+			Help:   "Whether the service's circuit breaker is open (1) or not (0).",
+			Labels: map[string]string{"service": c.ServiceName, "route": c.Route, "state": string(c.State)},
+			Value:  open,
+		})
+	}
+	for _, c := range circuits {
+		metrics = append(metrics, fastpath.Metric{
+			Name:    "gateway_circuit_rejected_total",
+			Help:    "Requests turned away by the service's open circuit.",
+			Labels:  map[string]string{"service": c.ServiceName, "route": c.Route},
+			Value:   float64(c.Rejected),
+			Counter: true,
+		})
+	}
+	return metrics
+}
+
+// retryMetrics reports, per service with a retry policy, the retries sent
+// and those held back by the service's circuit.
+func retryMetrics(retries *proxy.Retries) []fastpath.Metric {
+	counts := retries.Counts()
+	metrics := make([]fastpath.Metric, 0, 2*len(counts))
+	for _, count := range counts {
+		metrics = append(metrics, fastpath.Metric{
+			Name:    "gateway_upstream_retries_total",
+			Help:    "Retries of failed requests sent to the service.",
+			Labels:  map[string]string{"service": count.Service},
+			Value:   float64(count.Retried),
+			Counter: true,
+		})
+	}
+	for _, count := range counts {
+		metrics = append(metrics, fastpath.Metric{
+			Name:    "gateway_upstream_retries_blocked_total",
+			Help:    "Retries not sent because the service's circuit was not closed.",
+			Labels:  map[string]string{"service": count.Service},
+			Value:   float64(count.Blocked),
+			Counter: true,
+		})
+	}
+	return metrics
+}
+
+// sloMetrics exports each objective's compliance, remaining error budget
+// and burn rates, for alerting on fast burns from the gateway's side.
+func sloMetrics(tracker *slo.Tracker) []fastpath.Metric {
+	var compliance, budget, burn []fastpath.Metric
+	for _, status := range tracker.Statuses() {
+		for _, objective := range status.Objectives {
+			labels := map[string]string{"scope": status.Scope, "name": status.Name, "objective": objective.Type}
+			compliance = append(compliance, fastpath.Metric{
+				Name:   "gateway_slo_compliance_ratio",
+				Help:   "Share of requests meeting the objective over its window.",
+				Labels: labels,
+				Value:  objective.Compliance,
+			})
+			budget = append(budget, fastpath.Metric{
+				Name:   "gateway_slo_error_budget_remaining_ratio",
+				Help:   "Share of the objective's error budget left over its window.",
+				Labels: labels,
+				Value:  objective.ErrorBudgetRemaining,
+			})
+			for _, rate := range []struct {
+				window string
+				value  float64
+			}{{"5m", objective.BurnRate5m}, {"1h", objective.BurnRate1h}, {status.Window, objective.BurnRate}} {
+				burnLabels := map[string]string{"window": rate.window}
+				for name, value := range labels {
+					burnLabels[name] = value
+				}
+				burn = append(burn, fastpath.Metric{
+					Name:   "gateway_slo_burn_rate",
+					Help:   "How many times faster than sustainable the error budget is spent.",
+					Labels: burnLabels,
+					Value:  rate.value,
+				})
+			}
+		}
+	}
+	return append(append(compliance, budget...), burn...)
+}
+
+// healthCheckMetrics reports, per service, when it was last probed, how the
+// probes went and how often its status changed.
+func healthCheckMetrics(serviceRegistry *registry.ServiceRegistry) []fastpath.Metric {
+	var lastCheck, failures, responseTime, checks, transitions []fastpath.Metric
+	for _, stats := range serviceRegistry.HealthChecks() {
+		labels := map[string]string{"service": stats.Service}
+		if stats.LastChecked != nil {
+			lastCheck = append(lastCheck, fastpath.Metric{
+				Name:   "gateway_service_health_last_check_timestamp_seconds",
+				Help:   "Unix time of the service's last health check.",
+				Labels: labels,
+				Value:  float64(stats.LastChecked.UnixNano()) / 1e9,
+			})
+			responseTime = append(responseTime, fastpath.Metric{
+				Name:   "gateway_service_health_response_time_seconds",
+				Help:   "How long the service's last health check took.",
+				Labels: labels,
+				Value:  stats.ResponseTime / 1000,
+			})
+		}
+		failures = append(failures, fastpath.Metric{
+			Name:   "gateway_service_health_consecutive_failures",
+			Help:   "Health checks the service has failed in a row.",
+			Labels: labels,
+			Value:  float64(stats.ConsecutiveFailures),
+		})
+		for _, result := range []struct {
+			name  string
+			count int64
+		}{{"success", stats.Checks - stats.FailedChecks}, {"failure", stats.FailedChecks}} {
+			checks = append(checks, fastpath.Metric{
+				Name:    "gateway_service_health_checks_total",
+				Help:    "Health checks run against the service, by result.",
+				Labels:  map[string]string{"service": stats.Service, "result": result.name},
+				Value:   float64(result.count),
+				Counter: true,
+			})
+		}
+		for _, status := range []models.ServiceStatus{models.ServiceHealthy, models.ServiceDegraded, models.ServiceUnhealthy} {
+			transitions = append(transitions, fastpath.Metric{
+				Name:    "gateway_service_health_transitions_total",
+				Help:    "Times the service's status changed, by the status it moved to.",
+				Labels:  map[string]string{"service": stats.Service, "to": string(status)},
+				Value:   float64(stats.Transitions[status]),
+				Counter: true,
+			})
+		}
+	}
+	metrics := append(lastCheck, responseTime...)
+	metrics = append(metrics, failures...)
+	metrics = append(metrics, checks...)
+	return append(metrics, transitions...)
+}
+
+func anomalyStats(detector *anomaly.Detector) gin.H {
+	if detector == nil {
+		return gin.H{"enabled": false}
+	}
+	stats := gin.H{"enabled": true}
+	for key, value := range detector.Stats() {
+		stats[key] = value
+	}
+	return stats
+}
+
+// anomalyMetrics reports, per sampled route, whether its responses look
+// anomalous and how many have been sampled.
+func anomalyMetrics(detector *anomaly.Detector) []fastpath.Metric {
+	if detector == nil {
+		return nil
+	}
+	routes := detector.Routes()
+	metrics := make([]fastpath.Metric, 0, 2*len(routes))
+	for _, route := range routes {
+		anomalous := 0.0
+		if route.Anomalous {
+			anomalous = 1
+		}
+		metrics = append(metrics, fastpath.Metric{
+			Name:   "gateway_content_anomaly",
+			Help:   "Whether the route's sampled responses look anomalous (1) or not (0).",
+			Labels: map[string]string{"route": route.Route, "service": route.Service},
+			Value:  anomalous,
+		})
+	}
+	for _, route := range routes {
+		metrics = append(metrics, fastpath.Metric{
+			Name:    "gateway_content_samples_total",
+			Help:    "Upstream responses sampled for content anomaly detection.",
+			Labels:  map[string]string{"route": route.Route, "service": route.Service},
+			Value:   float64(route.Samples),
+			Counter: true,
+		})
+	}
+	return metrics
+}
+
+func overloadStats(monitor *overload.Monitor) gin.H {
+	if monitor == nil {
+		return gin.H{"enabled": false}
+	}
+	stats := gin.H{"enabled": true}
+	for key, value := range monitor.Stats() {
+		stats[key] = value
+	}
+	return stats
+}
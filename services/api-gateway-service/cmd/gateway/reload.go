@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"gateway/internal/config"
+	"gateway/internal/geoip"
+	"gateway/internal/models"
+	"gateway/internal/registry"
+	"gateway/internal/tlsconfig"
+)
+
+// configWatchDebounce gathers the file events of one save, as editors
+// often write a file in several steps.
+const configWatchDebounce = 500 * time.Millisecond
+
+// applyRouting swaps the services and routes of a configuration about to
+// take effect into the registry.
+func applyRouting(serviceRegistry *registry.ServiceRegistry) func(previous, current *models.GatewayConfig) error {
+	return func(previous, current *models.GatewayConfig) error {
+		return serviceRegistry.ReplaceRouting(previous.Services, current.Services, previous.Routes, current.Routes)
+	}
+}
+
+// watchConfig reloads the configuration on SIGHUP and, when it came from
+// files, whenever the file, its includes or overlay change, until ctx is
+// cancelled. A remote config document is watched the same way. The
+// services and routes are swapped in the registry in one step; a config
+// that fails validation, or whose routes the registry rejects, leaves
+// everything as it was. Other settings are only picked up on restart.
+func watchConfig(ctx context.Context, configManager *config.Manager, serviceRegistry *registry.ServiceRegistry) {
+	reload := func(trigger string) {
+		diff, err := configManager.ReloadWith(applyRouting(serviceRegistry))
+		if err != nil {
+			log.Printf("Config reload on %s failed, keeping the previous configuration: %v", trigger, err)
+			return
+		}
+		log.Printf("Config reloaded on %s: %d change(s)", trigger, diff.Size())
+		if len(diff.Sections) > 0 {
+			log.Printf("Changes to %s take effect after a restart", strings.Join(diff.Sections, ", "))
+		}
+	}
+
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(hangup)
+		for {
+			select {
+			case <-hangup:
+				reload("SIGHUP")
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	if remote := configManager.Remote(); remote != nil {
+		go remote.Watch(ctx, func() { reload("remote change") })
+		log.Printf("Watching %s for changes", remote)
+	}
+
+	if files := configManager.ConfigFiles(); len(files) > 0 {
+		go func() {
+			if err := config.WatchFiles(ctx, configManager.ConfigFiles, configWatchDebounce, func() { reload("file change") }); err != nil {
+				log.Printf("Not watching %s for changes: %v", strings.Join(files, ", "), err)
+			}
+		}()
+		log.Printf("Watching %s for changes", strings.Join(files, ", "))
+	}
+}
+
+// watchCertificate loads the listener's certificate again whenever its
+// files change, such as when cert-manager or certbot renews it, until ctx
+// is cancelled. New connections get the new certificate; a certificate
+// that fails to load leaves the previous one in use.
+func watchCertificate(ctx context.Context, certificate *tlsconfig.Certificate) {
+	files := certificate.Files()
+	if len(files) == 0 {
+		return
+	}
+	reload := func() {
+		if err := certificate.Reload(); err != nil {
+			log.Printf("TLS certificate reload failed, keeping the previous certificate: %v", err)
+			return
+		}
+		leaf := certificate.Leaf()
+		log.Printf("Reloaded TLS certificate for %s, valid until %s", leaf.Subject, leaf.NotAfter.Format(time.RFC3339))
+	}
+	go func() {
+		if err := config.WatchFiles(ctx, certificate.Files, configWatchDebounce, reload); err != nil {
+			log.Printf("Not watching %s for changes: %v", strings.Join(files, ", "), err)
+		}
+	}()
+	log.Printf("Watching %s for changes", strings.Join(files, ", "))
+}
+
+// watchGeoIP loads the GeoIP database again when its file is replaced,
+// such as by geoipupdate.
+func watchGeoIP(ctx context.Context, database *geoip.Database) {
+	reload := func() {
+		if err := database.Reload(); err != nil {
+			log.Printf("GeoIP database reload failed, keeping the previous database: %v", err)
+			return
+		}
+		log.Printf("Reloaded %s GeoIP database", database.Type())
+	}
+	go func() {
+		if err := config.WatchFiles(ctx, database.Files, configWatchDebounce, reload); err != nil {
+			log.Printf("Not watching %s for changes: %v", strings.Join(database.Files(), ", "), err)
+		}
+	}()
+	log.Printf("Watching %s for changes", strings.Join(database.Files(), ", "))
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"gateway/internal/config"
+)
+
+// runSchema implements `gateway schema`, which prints the JSON Schema of
+// config files, or with file arguments checks those files against it and
+// lists every problem found.
+func runSchema(args []string) error {
+	flags := flag.NewFlagSet("schema", flag.ContinueOnError)
+	output := flags.String("o", "-", `file to write the schema to, or "-" for stdout`)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if files := flags.Args(); len(files) > 0 {
+		var violations []config.Violation
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return err
+			}
+			violations = append(violations, config.CheckSchema(file, data)...)
+		}
+		if len(violations) > 0 {
+			return &config.SchemaError{Violations: violations}
+		}
+		fmt.Fprintf(os.Stderr, "%d file(s) match the schema\n", len(files))
+		return nil
+	}
+
+	schema, err := json.MarshalIndent(config.GatewaySchema(), "", "  ")
+	if err != nil {
+		return err
+	}
+	schema = append(schema, '\n')
+	if *output == "-" {
+		_, err := os.Stdout.Write(schema)
+		return err
+	}
+	return os.WriteFile(*output, schema, 0o644)
+}
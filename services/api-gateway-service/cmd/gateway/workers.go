@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"gateway/internal/models"
+	"gateway/internal/ratelimit"
+	"gateway/internal/workers"
+)
+
+// runSupervisor runs the configured number of workers, which share the
+// port, and serves them the rate limiter so a client's requests count
+// against one bucket whichever worker they land on.
+func runSupervisor(cfg *models.GatewayConfig) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	var env []string
+	if cfg.RateLimit.Enabled {
+		socket := filepath.Join(os.TempDir(), fmt.Sprintf("gateway-ratelimit-%d.sock", os.Getpid()))
+		listener, err := net.Listen("unix", socket)
+		if err != nil {
+			return fmt.Errorf("failed to open rate limit coordinator socket: %w", err)
+		}
+		limiter := ratelimit.NewLimiter(cfg.RateLimit.GetRate(), cfg.RateLimit.Burst)
+		go limiter.Run(ctx, time.Minute)
+		go func() {
+			if err := ratelimit.ServeCoordinator(ctx, listener, limiter); err != nil {
+				log.Printf("Rate limit coordinator stopped: %v", err)
+			}
+		}()
+		env = append(env, workers.CoordinatorEnv+"="+socket)
+		log.Printf("Rate limit coordinator listening on %s", socket)
+	}
+
+	// Workers reload their configuration on SIGHUP as a single process
+	// does
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	defer signal.Stop(reload)
+
+	log.Printf("Starting %d workers on %s:%d", cfg.Server.Workers, cfg.Server.Host, cfg.Server.Port)
+	workers.Supervise(ctx, cfg.Server.Workers, env, reload)
+	log.Println("Workers exited")
+	return nil
+}
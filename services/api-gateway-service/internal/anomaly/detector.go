@@ -0,0 +1,266 @@
+package anomaly
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"gateway/internal/models"
+)
+
+// unfamiliarShare is the baseline share below which a signature counts as
+// unlike the route's usual responses.
+const unfamiliarShare = 0.05
+
+// Detector learns what the successful responses of each route usually look
+// like from a sample of them: their media type, the top-level shape of JSON
+// bodies and their size. Samples are judged a window at a time, so the
+// verdict is reached lazily when the first sample of the next window
+// arrives. A window is anomalous when too many of its samples have a
+// signature the baseline has rarely seen, or when its mean size moved by
+// more than size_factor. Normal windows are folded into the baseline, with
+// older windows counting half as much at each fold; anomalous ones are not,
+// so a broken upstream does not become the new normal.
+type Detector struct {
+	config   models.AnomalyConfig
+	listener func(models.HealthEvent)
+
+	mutex  sync.Mutex
+	routes map[string]*profile
+}
+
+type profile struct {
+	service string
+
+	// Baseline learned from normal windows
+	signatures map[string]float64
+	samples    float64
+	bytes      float64
+
+	windowStart      time.Time
+	windowSignatures map[string]int
+	windowSamples    int
+	windowBytes      int64
+
+	total     int64
+	anomalies int64
+	anomalous bool
+	reason    string
+}
+
+// NewDetector builds a Detector that publishes content_anomaly and
+// content_recovered events to listener, which may be nil.
+func NewDetector(config models.AnomalyConfig, listener func(models.HealthEvent)) *Detector {
+	return &Detector{
+		config:   config,
+		listener: listener,
+		routes:   make(map[string]*profile),
+	}
+}
+
+// Sample reports whether a response should be inspected.
+func (d *Detector) Sample() bool {
+	return rand.Float64() < d.config.SampleRate
+}
+
+// Observe records a sampled response of route served by service.
+func (d *Detector) Observe(route, service, signature string, size int64) {
+	now := time.Now()
+
+	d.mutex.Lock()
+	p := d.routes[route]
+	if p == nil {
+		p = &profile{
+			service:          service,
+			signatures:       make(map[string]float64),
+			windowStart:      now,
+			windowSignatures: make(map[string]int),
+		}
+		d.routes[route] = p
+	}
+	var event *models.HealthEvent
+	if now.Sub(p.windowStart) >= d.config.Window {
+		event = d.roll(route, p, now)
+	}
+	p.service = service
+	p.windowSignatures[signature]++
+	p.windowSamples++
+	p.windowBytes += size
+	p.total++
+	d.mutex.Unlock()
+
+	if event != nil && d.listener != nil {
+		d.listener(*event)
+	}
+}
+
+// roll judges the window that just ended and starts the next one. It
+// returns the event to publish when the route's verdict changed.
+func (d *Detector) roll(route string, p *profile, now time.Time) *models.HealthEvent {
+	defer func() {
+		p.windowStart = now
+		p.windowSignatures = make(map[string]int)
+		p.windowSamples = 0
+		p.windowBytes = 0
+	}()
+
+	// Too few samples to say anything about the window, or the baseline is
+	// still being learned
+	if p.windowSamples < d.config.MinSamples {
+		if !p.anomalous {
+			p.fold()
+		}
+		return nil
+	}
+	if p.samples < float64(d.config.MinSamples) {
+		p.fold()
+		return nil
+	}
+
+	reason := d.judge(route, p)
+	if reason == "" {
+		p.fold()
+		if !p.anomalous {
+			return nil
+		}
+		p.anomalous = false
+		p.reason = ""
+		return &models.HealthEvent{
+			Type:      models.EventContentRecovered,
+			Service:   p.service,
+			Reason:    fmt.Sprintf("route %s: sampled responses look usual again", route),
+			Timestamp: now,
+		}
+	}
+
+	p.reason = reason
+	if p.anomalous {
+		return nil
+	}
+	p.anomalous = true
+	p.anomalies++
+	return &models.HealthEvent{
+		Type:      models.EventContentAnomaly,
+		Service:   p.service,
+		Reason:    reason,
+		Timestamp: now,
+	}
+}
+
+// judge returns why the current window is anomalous, or "" if it is not.
+func (d *Detector) judge(route string, p *profile) string {
+	unfamiliar := 0
+	for signature, count := range p.windowSignatures {
+		if p.signatures[signature]/p.samples < unfamiliarShare {
+			unfamiliar += count
+		}
+	}
+	if share := float64(unfamiliar) / float64(p.windowSamples); share >= d.config.MismatchRatio {
+		return fmt.Sprintf("route %s: %.0f%% of %d sampled responses were %s, usually %s",
+			route, share*100, p.windowSamples, dominantCount(p.windowSignatures), dominant(p.signatures))
+	}
+
+	if d.config.SizeFactor > 0 {
+		usual := p.bytes / p.samples
+		mean := float64(p.windowBytes) / float64(p.windowSamples)
+		// A byte of slack keeps empty bodies from dividing by zero
+		ratio := (mean + 1) / (usual + 1)
+		if ratio > d.config.SizeFactor || ratio < 1/d.config.SizeFactor {
+			return fmt.Sprintf("route %s: sampled responses averaged %.0f bytes, usually %.0f", route, mean, usual)
+		}
+	}
+	return ""
+}
+
+// fold adds the current window to the baseline, halving the weight of the
+// windows before it.
+func (p *profile) fold() {
+	for signature, count := range p.signatures {
+		if count /= 2; count < 0.01 {
+			delete(p.signatures, signature)
+		} else {
+			p.signatures[signature] = count
+		}
+	}
+	p.samples /= 2
+	p.bytes /= 2
+	for signature, count := range p.windowSignatures {
+		p.signatures[signature] += float64(count)
+	}
+	p.samples += float64(p.windowSamples)
+	p.bytes += float64(p.windowBytes)
+}
+
+func dominant(signatures map[string]float64) string {
+	best, bestCount := "", -1.0
+	for signature, count := range signatures {
+		if count > bestCount || count == bestCount && signature < best {
+			best, bestCount = signature, count
+		}
+	}
+	return best
+}
+
+func dominantCount(signatures map[string]int) string {
+	weighted := make(map[string]float64, len(signatures))
+	for signature, count := range signatures {
+		weighted[signature] = float64(count)
+	}
+	return dominant(weighted)
+}
+
+// RouteStatus is the detector's view of one route.
+type RouteStatus struct {
+	Route     string `json:"route"`
+	Service   string `json:"service"`
+	Samples   int64  `json:"samples"`
+	Learning  bool   `json:"learning"`
+	Anomalous bool   `json:"anomalous"`
+	Anomalies int64  `json:"anomalies"`
+	Usual     string `json:"usual,omitempty"`
+	MeanSize  int64  `json:"mean_size"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// Routes returns the status of every sampled route, sorted by path.
+func (d *Detector) Routes() []RouteStatus {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	statuses := make([]RouteStatus, 0, len(d.routes))
+	for route, p := range d.routes {
+		status := RouteStatus{
+			Route:     route,
+			Service:   p.service,
+			Samples:   p.total,
+			Learning:  p.samples < float64(d.config.MinSamples),
+			Anomalous: p.anomalous,
+			Anomalies: p.anomalies,
+			Usual:     dominant(p.signatures),
+			Reason:    p.reason,
+		}
+		if p.samples > 0 {
+			status.MeanSize = int64(p.bytes / p.samples)
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Route < statuses[j].Route })
+	return statuses
+}
+
+func (d *Detector) Stats() map[string]interface{} {
+	routes := d.Routes()
+	anomalous := 0
+	for _, route := range routes {
+		if route.Anomalous {
+			anomalous++
+		}
+	}
+	return map[string]interface{}{
+		"sample_rate": d.config.SampleRate,
+		"anomalous":   anomalous,
+		"routes":      routes,
+	}
+}
@@ -0,0 +1,119 @@
+package anomaly
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// maxShapeKeys bounds the keys named in an object's signature; objects with
+// more are most likely maps keyed by data rather than records.
+const maxShapeKeys = 32
+
+// Watch observes resp once its body has been relayed. Up to max_body_size
+// bytes are kept to learn the body's shape; bodies that are not read to
+// the end, such as those of clients that went away, are not observed.
+func (d *Detector) Watch(resp *http.Response, route, service string) {
+	if resp.Body == nil || resp.Body == http.NoBody {
+		d.Observe(route, service, Signature(resp.Header, nil, true), 0)
+		return
+	}
+	resp.Body = &sampledBody{
+		ReadCloser: resp.Body,
+		detector:   d,
+		route:      route,
+		service:    service,
+		header:     resp.Header,
+		limit:      d.config.MaxBodySize,
+	}
+}
+
+type sampledBody struct {
+	io.ReadCloser
+	detector *Detector
+	route    string
+	service  string
+	header   http.Header
+	limit    int64
+
+	captured bytes.Buffer
+	size     int64
+	once     sync.Once
+}
+
+func (b *sampledBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.size += int64(n)
+		if room := b.limit - int64(b.captured.Len()); room > 0 {
+			if int64(n) < room {
+				room = int64(n)
+			}
+			b.captured.Write(p[:room])
+		}
+	}
+	if err == io.EOF {
+		b.once.Do(func() {
+			complete := b.size <= b.limit
+			b.detector.Observe(b.route, b.service, Signature(b.header, b.captured.Bytes(), complete), b.size)
+		})
+	}
+	return n, err
+}
+
+func (b *sampledBody) Close() error {
+	b.once.Do(func() {})
+	return b.ReadCloser.Close()
+}
+
+// Signature describes a response by its media type and, for complete JSON
+// bodies, their top-level shape: "application/json {id,name}" for an
+// object, "application/json array" for an array and
+// "application/json invalid" for a body that does not parse.
+func Signature(header http.Header, body []byte, complete bool) string {
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(header.Get("Content-Type")))
+	}
+	if mediaType == "" {
+		mediaType = "none"
+	}
+	if encoding := header.Get("Content-Encoding"); encoding != "" && !strings.EqualFold(encoding, "identity") {
+		return mediaType + " " + strings.ToLower(encoding)
+	}
+	if !strings.HasSuffix(mediaType, "json") || !complete {
+		return mediaType
+	}
+	return mediaType + " " + jsonShape(body)
+}
+
+func jsonShape(body []byte) string {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(body, &object); err == nil {
+		if object == nil {
+			return "null"
+		}
+		if len(object) > maxShapeKeys {
+			return "{...}"
+		}
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		return "{" + strings.Join(keys, ",") + "}"
+	}
+	var array []json.RawMessage
+	if err := json.Unmarshal(body, &array); err == nil {
+		return "array"
+	}
+	if json.Valid(body) {
+		return "scalar"
+	}
+	return "invalid"
+}
@@ -0,0 +1,30 @@
+package audit
+
+import (
+	"encoding/json"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BeforeKey and AfterKey hold the state an admin endpoint changed, as it
+// was before the change and after it.
+const (
+	BeforeKey = "audit_before"
+	AfterKey  = "audit_after"
+)
+
+// Change records the state an admin endpoint changed in its audit record.
+// The state is encoded right away, so the record keeps it as it was even
+// if the values change afterwards.
+func Change(c *gin.Context, before, after interface{}) {
+	if before != nil {
+		if data, err := json.Marshal(before); err == nil {
+			c.Set(BeforeKey, json.RawMessage(data))
+		}
+	}
+	if after != nil {
+		if data, err := json.Marshal(after); err == nil {
+			c.Set(AfterKey, json.RawMessage(data))
+		}
+	}
+}
@@ -0,0 +1,163 @@
+// Package audit records the changes made through the gateway's admin
+// endpoints. Records are only ever appended: the log has no way to edit or
+// delete one, and the audit file is opened for appending only.
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"gateway/internal/models"
+)
+
+// Log keeps the most recent audit records in a fixed-size ring buffer and,
+// when it has a file, appends every record to it as a line of JSON.
+type Log struct {
+	records []models.AuditRecord
+	next    int
+	size    int
+	lastID  int64
+	last    string
+	file    *os.File
+	mutex   sync.RWMutex
+}
+
+func NewLog(capacity int) *Log {
+	return &Log{
+		records: make([]models.AuditRecord, capacity),
+	}
+}
+
+// Open is NewLog with records also appended to path. Records already in
+// the file are read back, so IDs and the hash chain carry on where the
+// last run stopped and the newest of them can still be listed.
+func Open(path string, capacity int) (*Log, error) {
+	l := NewLog(capacity)
+	if err := l.load(path); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("opening audit file: %w", err)
+	}
+	l.file = file
+	return l, nil
+}
+
+func (l *Log) load(path string) error {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading audit file: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64<<10), 16<<20)
+	for line := 1; scanner.Scan(); line++ {
+		var record models.AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return fmt.Errorf("reading audit file: line %d: %w", line, err)
+		}
+		l.store(record)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading audit file: %w", err)
+	}
+	return nil
+}
+
+// Append assigns the record the next ID, chains it to the previous record
+// and stores it. A record that cannot be written to the file is not kept
+// either, so the file never misses a record the log has.
+func (l *Log) Append(record models.AuditRecord) (models.AuditRecord, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	record.ID = l.lastID + 1
+	record.PreviousHash = l.last
+	record.Hash = Hash(record)
+	if l.file != nil {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return record, err
+		}
+		if _, err := l.file.Write(append(line, '\n')); err != nil {
+			return record, fmt.Errorf("writing audit file: %w", err)
+		}
+	}
+	l.store(record)
+	return record, nil
+}
+
+func (l *Log) store(record models.AuditRecord) {
+	l.lastID = record.ID
+	l.last = record.Hash
+	l.records[l.next] = record
+	l.next = (l.next + 1) % len(l.records)
+	if l.size < len(l.records) {
+		l.size++
+	}
+}
+
+// List returns records newer than afterID, oldest first, optionally limited
+// to one actor and to the newest limit records.
+func (l *Log) List(afterID int64, actor string, limit int) []models.AuditRecord {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	result := make([]models.AuditRecord, 0, l.size)
+	start := (l.next - l.size + len(l.records)) % len(l.records)
+	for i := 0; i < l.size; i++ {
+		record := l.records[(start+i)%len(l.records)]
+		if record.ID <= afterID || (actor != "" && record.Actor != actor) {
+			continue
+		}
+		result = append(result, record)
+	}
+
+	if limit > 0 && len(result) > limit {
+		result = result[len(result)-limit:]
+	}
+	return result
+}
+
+// Close closes the audit file. Appending after Close fails.
+func (l *Log) Close() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	return l.file.Close()
+}
+
+// Hash is the hex SHA-256 of the record with its Hash left out.
+func Hash(record models.AuditRecord) string {
+	record.Hash = ""
+	data, _ := json.Marshal(record)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Verify checks that consecutive records, such as the lines of an audit
+// file, were not edited and that none was removed from between them.
+func Verify(records []models.AuditRecord) error {
+	for i, record := range records {
+		if Hash(record) != record.Hash {
+			return fmt.Errorf("audit record %d was modified", record.ID)
+		}
+		if i > 0 && (record.PreviousHash != records[i-1].Hash || record.ID != records[i-1].ID+1) {
+			return fmt.Errorf("audit records before %d are missing", record.ID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package auth
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Verifier checks access tokens. Client verifies against the auth service;
+// CachingVerifier puts a cache in front of another verifier.
+type Verifier interface {
+	Verify(ctx context.Context, accessToken string) (*Identity, error)
+}
+
+// Providers are the verifiers of the configured auth providers by name.
+// The unnamed provider, "", is the auth service.
+type Providers map[string]Verifier
+
+// CachingVerifier remembers verification results in a bounded LRU keyed by
+// token hash, so raw tokens are never kept in memory. Accepted tokens are
+// cached for ttl and rejected ones for negativeTTL; auth service outages
+// are never cached.
+type CachingVerifier struct {
+	verifier    Verifier
+	ttl         time.Duration
+	negativeTTL time.Duration
+	maxEntries  int
+
+	entries map[string]*list.Element
+	order   *list.List
+	mutex   sync.Mutex
+
+	hits   int64
+	misses int64
+}
+
+type verification struct {
+	key       string
+	identity  *Identity
+	err       error
+	expiresAt time.Time
+}
+
+func NewCachingVerifier(verifier Verifier, ttl, negativeTTL time.Duration, maxEntries int) *CachingVerifier {
+	return &CachingVerifier{
+		verifier:    verifier,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		maxEntries:  maxEntries,
+		entries:     make(map[string]*list.Element),
+		order:       list.New(),
+	}
+}
+
+func (v *CachingVerifier) Verify(ctx context.Context, accessToken string) (*Identity, error) {
+	key := tokenKey(accessToken)
+	if result, found := v.lookup(key); found {
+		return result.identity, result.err
+	}
+
+	identity, err := v.verifier.Verify(ctx, accessToken)
+	switch {
+	case err == nil:
+		v.store(&verification{key: key, identity: identity, expiresAt: time.Now().Add(v.ttl)})
+	case errors.Is(err, ErrInvalidCredentials) || errors.Is(err, ErrForbidden):
+		if v.negativeTTL > 0 {
+			v.store(&verification{key: key, err: err, expiresAt: time.Now().Add(v.negativeTTL)})
+		}
+	}
+	return identity, err
+}
+
+func (v *CachingVerifier) lookup(key string) (*verification, bool) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	element, exists := v.entries[key]
+	if !exists {
+		v.misses++
+		return nil, false
+	}
+	result := element.Value.(*verification)
+	if time.Now().After(result.expiresAt) {
+		v.order.Remove(element)
+		delete(v.entries, key)
+		v.misses++
+		return nil, false
+	}
+
+	v.order.MoveToFront(element)
+	v.hits++
+	return result, true
+}
+
+func (v *CachingVerifier) store(result *verification) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	if element, exists := v.entries[result.key]; exists {
+		element.Value = result
+		v.order.MoveToFront(element)
+		return
+	}
+
+	v.entries[result.key] = v.order.PushFront(result)
+	for v.order.Len() > v.maxEntries {
+		oldest := v.order.Back()
+		v.order.Remove(oldest)
+		delete(v.entries, oldest.Value.(*verification).key)
+	}
+}
+
+// Invalidate forgets the result for one token.
+func (v *CachingVerifier) Invalidate(accessToken string) bool {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	key := tokenKey(accessToken)
+	element, exists := v.entries[key]
+	if !exists {
+		return false
+	}
+	v.order.Remove(element)
+	delete(v.entries, key)
+	return true
+}
+
+// InvalidateUser forgets every cached token of a user and returns how many
+// were removed.
+func (v *CachingVerifier) InvalidateUser(userID string) int {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	removed := 0
+	for key, element := range v.entries {
+		if identity := element.Value.(*verification).identity; identity != nil && identity.UserID == userID {
+			v.order.Remove(element)
+			delete(v.entries, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Flush empties the cache and returns how many entries were removed.
+func (v *CachingVerifier) Flush() int {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	removed := v.order.Len()
+	v.entries = make(map[string]*list.Element)
+	v.order.Init()
+	return removed
+}
+
+func (v *CachingVerifier) Stats() map[string]interface{} {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	return map[string]interface{}{
+		"entries": v.order.Len(),
+		"hits":    v.hits,
+		"misses":  v.misses,
+	}
+}
+
+func tokenKey(accessToken string) string {
+	sum := sha256.Sum256([]byte(accessToken))
+	return hex.EncodeToString(sum[:])
+}
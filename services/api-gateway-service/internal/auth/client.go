@@ -0,0 +1,241 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"gateway/internal/models"
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrForbidden means the token is genuine but its user may not use the
+	// platform, e.g. a deactivated account.
+	ErrForbidden = errors.New("access forbidden")
+)
+
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// Identity is the result of a successful token verification.
+type Identity struct {
+	UserID string
+	Email  string
+	Name   string
+	Scopes []string
+	// Claims holds the other scalar claims of the token, as strings
+	Claims map[string]string
+}
+
+// Claim returns a claim by name as it is passed to upstreams; scopes are
+// space-separated as in OAuth.
+func (i *Identity) Claim(name string) string {
+	switch name {
+	case "user_id", "sub":
+		return i.UserID
+	case "email":
+		return i.Email
+	case "name":
+		return i.Name
+	case "scopes", "scope":
+		return strings.Join(i.Scopes, " ")
+	}
+	return i.Claims[name]
+}
+
+type verifyResponse struct {
+	Valid     bool                       `json:"valid"`
+	UserID    json.RawMessage            `json:"user_id"`
+	Email     string                     `json:"email"`
+	Scopes    json.RawMessage            `json:"scopes"`
+	TokenData map[string]json.RawMessage `json:"token_data"`
+}
+
+// Client calls the central auth service on behalf of the gateway.
+type Client struct {
+	baseURL    string
+	verifyPath string
+	client     *http.Client
+}
+
+func NewClient(serviceURL string, timeout time.Duration) *Client {
+	return &Client{
+		baseURL:    strings.TrimSuffix(serviceURL, "/"),
+		verifyPath: "/auth/verify",
+		client: &http.Client{
+			Timeout: timeout,
+		},
+	}
+}
+
+// SetVerifyPath points Verify at an identity system whose verification
+// endpoint is not /auth/verify.
+func (c *Client) SetVerifyPath(path string) {
+	c.verifyPath = path
+}
+
+func (c *Client) Login(ctx context.Context, email, password string) (*TokenResponse, error) {
+	var tokens TokenResponse
+	err := c.postJSON(ctx, "/auth/login", "", map[string]string{
+		"email":    email,
+		"password": password,
+	}, &tokens)
+	if err != nil {
+		return nil, err
+	}
+	return &tokens, nil
+}
+
+func (c *Client) Refresh(ctx context.Context, refreshToken string) (*TokenResponse, error) {
+	var tokens TokenResponse
+	err := c.postJSON(ctx, "/auth/refresh", "", map[string]string{
+		"refresh_token": refreshToken,
+	}, &tokens)
+	if err != nil {
+		return nil, err
+	}
+	return &tokens, nil
+}
+
+func (c *Client) Logout(ctx context.Context, accessToken, refreshToken string) error {
+	return c.postJSON(ctx, "/auth/logout", accessToken, map[string]string{
+		"refresh_token": refreshToken,
+	}, nil)
+}
+
+// Verify checks an access token with the auth service.
+func (c *Client) Verify(ctx context.Context, accessToken string) (*Identity, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+c.verifyPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	setCorrelationID(req)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("auth service unavailable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, ErrInvalidCredentials
+	}
+	if resp.StatusCode == http.StatusForbidden {
+		return nil, ErrForbidden
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("auth service returned %d", resp.StatusCode)
+	}
+
+	var result verifyResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode auth service response: %w", err)
+	}
+	if !result.Valid {
+		return nil, ErrInvalidCredentials
+	}
+
+	// user_id is numeric in the auth service but treated as opaque here
+	identity := &Identity{
+		UserID: strings.Trim(string(result.UserID), `"`),
+		Email:  result.Email,
+		Scopes: parseScopes(result.Scopes),
+		Claims: make(map[string]string),
+	}
+	for name, raw := range result.TokenData {
+		var value interface{}
+		if err := json.Unmarshal(raw, &value); err != nil {
+			continue
+		}
+		switch v := value.(type) {
+		case string:
+			identity.Claims[name] = v
+		case float64, bool:
+			identity.Claims[name] = strings.Trim(string(raw), `"`)
+		}
+	}
+	if identity.Scopes == nil {
+		identity.Scopes = parseScopes(result.TokenData["scopes"])
+	}
+	if identity.Scopes == nil {
+		identity.Scopes = parseScopes(result.TokenData["scope"])
+	}
+	identity.Name = identity.Claims["name"]
+	return identity, nil
+}
+
+// parseScopes accepts scopes as a list or as an OAuth space-separated
+// string.
+func parseScopes(raw json.RawMessage) []string {
+	if len(raw) == 0 {
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(raw, &list); err == nil {
+		return list
+	}
+	var joined string
+	if err := json.Unmarshal(raw, &joined); err == nil {
+		return strings.Fields(joined)
+	}
+	return nil
+}
+
+func (c *Client) postJSON(ctx context.Context, path, accessToken string, payload interface{}, result interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	setCorrelationID(req)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("auth service unavailable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusBadRequest {
+		return ErrInvalidCredentials
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("auth service returned %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+
+	if result == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("failed to decode auth service response: %w", err)
+	}
+	return nil
+}
+
+// setCorrelationID passes on the correlation ID of the client request a
+// call to the auth service is made for.
+func setCorrelationID(req *http.Request) {
+	if id := models.CorrelationID(req.Context()); id != "" {
+		req.Header.Set(models.CorrelationIDHeader, id)
+	}
+}
@@ -0,0 +1,219 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrRevoked means the token is genuine but has been revoked before its
+// expiry. It is also an ErrInvalidCredentials.
+var ErrRevoked = fmt.Errorf("token revoked: %w", ErrInvalidCredentials)
+
+// RevocationSource returns the current denylist. Each entry is either the
+// hex SHA-256 of a revoked token or the jti claim of one.
+type RevocationSource interface {
+	Revoked(ctx context.Context) ([]string, error)
+}
+
+// HTTPRevocationSource pulls the denylist from the auth service, which
+// answers with {"revoked": [...]}.
+type HTTPRevocationSource struct {
+	url    string
+	client *http.Client
+}
+
+func NewHTTPRevocationSource(serviceURL, path string, timeout time.Duration) *HTTPRevocationSource {
+	return &HTTPRevocationSource{
+		url:    strings.TrimSuffix(serviceURL, "/") + path,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (s *HTTPRevocationSource) Revoked(ctx context.Context) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("auth service unavailable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("auth service returned %d", resp.StatusCode)
+	}
+	var result struct {
+		Revoked []string `json:"revoked"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode revocation list: %w", err)
+	}
+	return result.Revoked, nil
+}
+
+// RedisRevocationSource reads the denylist from a sorted set scored by when
+// each revoked token expires anyway. Entries past that are dropped.
+type RedisRevocationSource struct {
+	client *redis.Client
+	key    string
+}
+
+func NewRedisRevocationSource(client *redis.Client, prefix string) *RedisRevocationSource {
+	return &RedisRevocationSource{
+		client: client,
+		key:    prefix + "revoked_tokens",
+	}
+}
+
+func (s *RedisRevocationSource) Revoked(ctx context.Context) ([]string, error) {
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	if err := s.client.ZRemRangeByScore(ctx, s.key, "-inf", "("+now).Err(); err != nil {
+		return nil, err
+	}
+	return s.client.ZRangeByScore(ctx, s.key, &redis.ZRangeBy{Min: now, Max: "+inf"}).Result()
+}
+
+// Denylist holds the revoked tokens last pulled from a source. When a pull
+// fails the previous list stays in force, so an outage of the source does
+// not bring revoked tokens back.
+type Denylist struct {
+	source   RevocationSource
+	interval time.Duration
+
+	mutex       sync.RWMutex
+	entries     map[string]struct{}
+	lastRefresh time.Time
+	lastError   error
+
+	refreshes int64
+	failures  int64
+	rejected  int64
+}
+
+func NewDenylist(source RevocationSource, interval time.Duration) *Denylist {
+	return &Denylist{
+		source:   source,
+		interval: interval,
+		entries:  make(map[string]struct{}),
+	}
+}
+
+// Run pulls the denylist immediately and then every interval until ctx is
+// done.
+func (d *Denylist) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := d.Refresh(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Failed to refresh token revocation list: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Refresh replaces the denylist with the source's current one.
+func (d *Denylist) Refresh(ctx context.Context) error {
+	revoked, err := d.source.Revoked(ctx)
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if err != nil {
+		d.failures++
+		d.lastError = err
+		return err
+	}
+	entries := make(map[string]struct{}, len(revoked))
+	for _, entry := range revoked {
+		entries[strings.ToLower(strings.TrimSpace(entry))] = struct{}{}
+	}
+	d.entries = entries
+	d.lastRefresh = time.Now()
+	d.lastError = nil
+	d.refreshes++
+	return nil
+}
+
+// Revoked reports whether a token, or the jti of its identity, is on the
+// denylist.
+func (d *Denylist) Revoked(accessToken string, identity *Identity) bool {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	if len(d.entries) == 0 {
+		return false
+	}
+	if _, found := d.entries[tokenKey(accessToken)]; found {
+		return true
+	}
+	if identity != nil {
+		if jti := identity.Claims["jti"]; jti != "" {
+			_, found := d.entries[strings.ToLower(jti)]
+			return found
+		}
+	}
+	return false
+}
+
+// Wrap checks tokens verifier accepts against the denylist. It goes in
+// front of any cache, so a revocation takes effect on the next pull.
+func (d *Denylist) Wrap(verifier Verifier) Verifier {
+	return &revokingVerifier{verifier: verifier, denylist: d}
+}
+
+func (d *Denylist) Stats() map[string]interface{} {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	stats := map[string]interface{}{
+		"entries":   len(d.entries),
+		"refreshes": d.refreshes,
+		"failures":  d.failures,
+		"rejected":  d.rejected,
+	}
+	if !d.lastRefresh.IsZero() {
+		stats["last_refresh"] = d.lastRefresh.Format(time.RFC3339)
+	}
+	if d.lastError != nil {
+		stats["last_error"] = d.lastError.Error()
+	}
+	return stats
+}
+
+func (d *Denylist) recordRejection() {
+	d.mutex.Lock()
+	d.rejected++
+	d.mutex.Unlock()
+}
+
+type revokingVerifier struct {
+	verifier Verifier
+	denylist *Denylist
+}
+
+func (v *revokingVerifier) Verify(ctx context.Context, accessToken string) (*Identity, error) {
+	identity, err := v.verifier.Verify(ctx, accessToken)
+	if err != nil {
+		return identity, err
+	}
+	if v.denylist.Revoked(accessToken, identity) {
+		v.denylist.recordRejection()
+		return nil, ErrRevoked
+	}
+	return identity, nil
+}
@@ -0,0 +1,124 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Entry is a cached upstream response.
+type Entry struct {
+	Status    int
+	Header    http.Header
+	Body      []byte
+	ExpiresAt time.Time
+}
+
+// ResponseCache is a bounded in-memory LRU of upstream responses.
+type ResponseCache struct {
+	maxEntries int
+	entries    map[string]*list.Element
+	order      *list.List
+	mutex      sync.Mutex
+
+	hits   int64
+	misses int64
+}
+
+type item struct {
+	key   string
+	entry Entry
+}
+
+func New(maxEntries int) *ResponseCache {
+	return &ResponseCache{
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// Get returns a live entry for key.
+func (rc *ResponseCache) Get(key string) (Entry, bool) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	element, exists := rc.entries[key]
+	if !exists {
+		rc.misses++
+		return Entry{}, false
+	}
+
+	cached := element.Value.(*item)
+	if time.Now().After(cached.entry.ExpiresAt) {
+		rc.order.Remove(element)
+		delete(rc.entries, key)
+		rc.misses++
+		return Entry{}, false
+	}
+
+	rc.order.MoveToFront(element)
+	rc.hits++
+	return cached.entry, true
+}
+
+// Set stores an entry, evicting the least recently used one when full.
+func (rc *ResponseCache) Set(key string, entry Entry) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	if element, exists := rc.entries[key]; exists {
+		element.Value.(*item).entry = entry
+		rc.order.MoveToFront(element)
+		return
+	}
+
+	rc.entries[key] = rc.order.PushFront(&item{key: key, entry: entry})
+	for rc.order.Len() > rc.maxEntries {
+		oldest := rc.order.Back()
+		rc.order.Remove(oldest)
+		delete(rc.entries, oldest.Value.(*item).key)
+	}
+}
+
+// Purge drops every entry and returns how many were removed.
+func (rc *ResponseCache) Purge() int {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	removed := rc.order.Len()
+	rc.entries = make(map[string]*list.Element)
+	rc.order.Init()
+	return removed
+}
+
+func (rc *ResponseCache) Stats() map[string]interface{} {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	return map[string]interface{}{
+		"entries": rc.order.Len(),
+		"hits":    rc.hits,
+		"misses":  rc.misses,
+	}
+}
+
+// Key identifies a cacheable request.
+func Key(r *http.Request) string {
+	return r.Method + " " + r.URL.RequestURI()
+}
+
+type refreshKey struct{}
+
+// WithRefresh marks a request context so the cache skips lookups and
+// stores the fresh upstream response. Used by warmup runs.
+func WithRefresh(ctx context.Context) context.Context {
+	return context.WithValue(ctx, refreshKey{}, true)
+}
+
+func IsRefresh(ctx context.Context) bool {
+	refresh, _ := ctx.Value(refreshKey{}).(bool)
+	return refresh
+}
@@ -0,0 +1,159 @@
+package cache
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"gateway/internal/models"
+)
+
+// Warmer replays configured GET requests through the gateway handler so
+// their responses are cached before real traffic asks for them.
+type Warmer struct {
+	handler http.Handler
+	config  models.WarmupConfig
+}
+
+func NewWarmer(handler http.Handler, config models.WarmupConfig) *Warmer {
+	return &Warmer{
+		handler: handler,
+		config:  config,
+	}
+}
+
+// Run replays every configured path once, bypassing cached entries so they
+// are refreshed, and reports how many requests succeeded and failed.
+func (w *Warmer) Run(ctx context.Context) (warmed, failed int) {
+	concurrency := w.config.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, concurrency)
+
+	for _, path := range w.config.Paths {
+		if ctx.Err() != nil {
+			break
+		}
+
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(path string) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+
+			status := w.replay(ctx, path)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			if status == http.StatusOK {
+				warmed++
+			} else {
+				failed++
+				log.Printf("Cache warmup of %s returned %d", path, status)
+			}
+		}(path)
+	}
+
+	wg.Wait()
+	return warmed, failed
+}
+
+// Start runs warmups on startup and on schedule until ctx is cancelled.
+func (w *Warmer) Start(ctx context.Context) {
+	if w.config.OnStartup {
+		w.runAndLog(ctx)
+	}
+
+	for {
+		next := w.nextRun(time.Now())
+		if next.IsZero() {
+			return
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-timer.C:
+			w.runAndLog(ctx)
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+func (w *Warmer) runAndLog(ctx context.Context) {
+	start := time.Now()
+	warmed, failed := w.Run(ctx)
+	log.Printf("Cache warmup finished in %s: %d warmed, %d failed", time.Since(start).Round(time.Millisecond), warmed, failed)
+}
+
+// nextRun returns the earliest scheduled run after now, or the zero time
+// when no schedule is configured.
+func (w *Warmer) nextRun(now time.Time) time.Time {
+	var next time.Time
+	if w.config.Interval > 0 {
+		next = now.Add(w.config.Interval)
+	}
+
+	for _, at := range w.config.At {
+		hour, minute, err := models.ParseDailyTime(at)
+		if err != nil {
+			continue
+		}
+		candidate := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, now.Location())
+		if !candidate.After(now) {
+			candidate = candidate.AddDate(0, 0, 1)
+		}
+		if next.IsZero() || candidate.Before(next) {
+			next = candidate
+		}
+	}
+	return next
+}
+
+func (w *Warmer) replay(ctx context.Context, path string) int {
+	req, err := http.NewRequestWithContext(WithRefresh(ctx), http.MethodGet, path, nil)
+	if err != nil {
+		return 0
+	}
+	req.RequestURI = path
+	req.RemoteAddr = "127.0.0.1:0"
+
+	recorder := &discardWriter{header: make(http.Header)}
+	w.handler.ServeHTTP(recorder, req)
+	if recorder.status == 0 {
+		return http.StatusOK
+	}
+	return recorder.status
+}
+
+// discardWriter records only the status of a replayed request.
+type discardWriter struct {
+	header http.Header
+	status int
+}
+
+func (d *discardWriter) Header() http.Header {
+	return d.header
+}
+
+func (d *discardWriter) Write(data []byte) (int, error) {
+	if d.status == 0 {
+		d.status = http.StatusOK
+	}
+	return len(data), nil
+}
+
+func (d *discardWriter) WriteHeader(status int) {
+	if d.status == 0 {
+		d.status = status
+	}
+}
@@ -0,0 +1,261 @@
+// Package circuit keeps a circuit breaker per service, so a failing
+// service is given time to recover instead of every request waiting on it.
+package circuit
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"gateway/internal/models"
+)
+
+// Breakers holds the circuit of every service that was called, and of
+// every route with circuit breaker settings of its own, created closed on
+// first use.
+type Breakers struct {
+	settings models.CircuitBreakerSettings
+	listener func(models.HealthEvent)
+	circuits map[circuitKey]*circuit
+	mutex    sync.Mutex
+}
+
+// circuitKey names a circuit; route is empty for a service's circuit.
+type circuitKey struct {
+	service string
+	route   string
+}
+
+type circuit struct {
+	state    *models.CircuitBreakerState
+	rejected int64
+	opened   int64
+}
+
+// NewBreakers creates the breakers with the gateway-wide settings.
+// listener, if set, is told when a circuit opens and when it closes again;
+// it must not block.
+func NewBreakers(settings models.CircuitBreakerSettings, listener func(models.HealthEvent)) *Breakers {
+	return &Breakers{
+		settings: settings,
+		listener: listener,
+		circuits: make(map[circuitKey]*circuit),
+	}
+}
+
+// Settings returns the gateway-wide settings that services' and routes'
+// overrides apply to.
+func (b *Breakers) Settings() models.CircuitBreakerSettings {
+	return b.settings
+}
+
+// SettingsFor returns which circuit requests to service through route go
+// by, as the route argument of Allow, and that circuit's settings. A route
+// with overrides of its own has a circuit of its own; route may be nil.
+func (b *Breakers) SettingsFor(service *models.ServiceConfig, route *models.RouteConfig) (string, models.CircuitBreakerSettings) {
+	settings := service.CircuitBreaker.Apply(b.settings)
+	if route == nil || route.CircuitBreaker == nil {
+		return "", settings
+	}
+	return route.Path, route.CircuitBreaker.Apply(settings)
+}
+
+func (b *Breakers) circuit(key circuitKey, settings models.CircuitBreakerSettings) *circuit {
+	c, exists := b.circuits[key]
+	if !exists {
+		c = &circuit{state: models.NewCircuitBreakerState(key.service, settings)}
+		b.circuits[key] = c
+	}
+	// Settings changed by a config reload apply from now on
+	if c.state.Settings != settings {
+		c.state.Reconfigure(settings)
+	}
+	return c
+}
+
+// Allow reports whether a request to service may go through, and
+// otherwise how long the circuit stays open. route names a route with a
+// circuit of its own, or is empty for the service's circuit; settings are
+// the circuit's, with any overrides applied. A request let through must be
+// reported with Record or Release.
+func (b *Breakers) Allow(service, route string, settings models.CircuitBreakerSettings) (bool, time.Duration) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	c := b.circuit(circuitKey{service: service, route: route}, settings)
+	if c.state.CanRequest() {
+		return true, 0
+	}
+	c.rejected++
+	return false, c.state.RetryAfter()
+}
+
+// Record reports the outcome of a request let through, and how long it
+// took; an answer slower than the circuit's slow_call_duration counts as a
+// failure.
+func (b *Breakers) Record(service, route string, failed bool, elapsed time.Duration) {
+	b.mutex.Lock()
+	c, exists := b.circuits[circuitKey{service: service, route: route}]
+	if !exists {
+		b.mutex.Unlock()
+		return
+	}
+	from := c.state.State
+	switch {
+	case failed:
+		c.state.RecordFailure()
+	case c.state.Settings.IsSlow(elapsed):
+		c.state.RecordSlowCall()
+	default:
+		c.state.RecordSuccess()
+	}
+	to := c.state.State
+	if to == models.CircuitOpen && from != models.CircuitOpen {
+		c.opened++
+	}
+	slowCalls := c.state.Settings.SlowCallDuration > 0
+	b.mutex.Unlock()
+
+	if from == to {
+		return
+	}
+	switch {
+	case to == models.CircuitOpen && from == models.CircuitClosed:
+		reason := "failure rate reached the threshold"
+		if slowCalls {
+			reason = "failure and slow call rate reached the threshold"
+		}
+		b.notify(models.EventCircuitOpened, service, route, reason)
+	case to == models.CircuitClosed:
+		b.notify(models.EventCircuitClosed, service, route, "probes succeeded")
+	}
+}
+
+func (b *Breakers) notify(eventType models.HealthEventType, service, route, reason string) {
+	if b.listener == nil {
+		return
+	}
+	if route != "" {
+		reason = "route " + route + " " + reason
+	}
+	b.listener(models.HealthEvent{Type: eventType, Service: service, Reason: reason, Timestamp: time.Now()})
+}
+
+// Release hands back a request let through whose outcome says nothing
+// about the service.
+func (b *Breakers) Release(service, route string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if c, exists := b.circuits[circuitKey{service: service, route: route}]; exists {
+		c.state.Release()
+	}
+}
+
+// Closed reports whether a circuit lets every request through, as one
+// never used does.
+func (b *Breakers) Closed(service, route string) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	c, exists := b.circuits[circuitKey{service: service, route: route}]
+	return !exists || c.state.State == models.CircuitClosed
+}
+
+// ForceOpen opens a circuit by hand, such as while its service is under
+// maintenance, until the given time or, if it is zero, until Reset.
+func (b *Breakers) ForceOpen(service, route string, settings models.CircuitBreakerSettings, until time.Time) {
+	b.mutex.Lock()
+	c := b.circuit(circuitKey{service: service, route: route}, settings)
+	from := c.state.State
+	c.state.ForceOpen(until)
+	if from != models.CircuitOpen {
+		c.opened++
+	}
+	b.mutex.Unlock()
+
+	if from != models.CircuitOpen {
+		b.notify(models.EventCircuitOpened, service, route, "forced open")
+	}
+}
+
+// Reset closes a circuit and forgets its counts. It reports false if the
+// circuit was never used.
+func (b *Breakers) Reset(service, route string) bool {
+	b.mutex.Lock()
+	c, exists := b.circuits[circuitKey{service: service, route: route}]
+	if !exists {
+		b.mutex.Unlock()
+		return false
+	}
+	from := c.state.State
+	c.state.Reset()
+	b.mutex.Unlock()
+
+	if from != models.CircuitClosed {
+		b.notify(models.EventCircuitClosed, service, route, "reset")
+	}
+	return true
+}
+
+// CircuitStatus is the state of one service's or route's circuit.
+type CircuitStatus struct {
+	models.CircuitBreakerState
+	Route    string `json:"route,omitempty"`
+	Rejected int64  `json:"rejected"`
+	Opened   int64  `json:"opened"`
+}
+
+// Circuits returns the circuits by service name, each service's own
+// circuit before those of its routes.
+func (b *Breakers) Circuits() []CircuitStatus {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	statuses := make([]CircuitStatus, 0, len(b.circuits))
+	for key, c := range b.circuits {
+		statuses = append(statuses, c.status(key))
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].ServiceName != statuses[j].ServiceName {
+			return statuses[i].ServiceName < statuses[j].ServiceName
+		}
+		return statuses[i].Route < statuses[j].Route
+	})
+	return statuses
+}
+
+// Circuit returns the state of one circuit, if it was ever used.
+func (b *Breakers) Circuit(service, route string) (CircuitStatus, bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	key := circuitKey{service: service, route: route}
+	c, exists := b.circuits[key]
+	if !exists {
+		return CircuitStatus{}, false
+	}
+	return c.status(key), true
+}
+
+func (c *circuit) status(key circuitKey) CircuitStatus {
+	state := *c.state
+	// An open circuit past its timeout is as good as half-open
+	if state.State == models.CircuitOpen && state.RetryDue(time.Now()) {
+		state.State = models.CircuitHalfOpen
+	}
+	return CircuitStatus{CircuitBreakerState: state, Route: key.route, Rejected: c.rejected, Opened: c.opened}
+}
+
+func (b *Breakers) Stats() map[string]interface{} {
+	circuits := b.Circuits()
+	open := 0
+	for _, c := range circuits {
+		if c.State != models.CircuitClosed {
+			open++
+		}
+	}
+	return map[string]interface{}{
+		"enabled":  true,
+		"open":     open,
+		"circuits": circuits,
+	}
+}
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"gateway/internal/models"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DryRun loads candidate, a config file in YAML or JSON, the way a reload
+// would load the config file, and validates it, without applying
+// anything. Defaults, the environment overlay, the remote document and
+// environment variables apply as usual, and secret references are
+// resolved. On failure it returns the stage, ReloadLoad or
+// ReloadValidation, that failed.
+func (m *Manager) DryRun(candidate []byte) (*models.GatewayConfig, string, error) {
+	settings := make(map[string]interface{})
+	if err := yaml.Unmarshal(candidate, &settings); err != nil {
+		return nil, ReloadLoad, fmt.Errorf("failed to parse candidate config: %w", err)
+	}
+	if _, exists := settings[includeKey]; exists {
+		return nil, ReloadLoad, fmt.Errorf("include is not supported in a dry run, send the merged config")
+	}
+	if violations := CheckSchema("candidate", candidate); len(violations) > 0 {
+		return nil, ReloadLoad, &SchemaError{Violations: violations}
+	}
+
+	dry := NewManager()
+	if err := dry.viper.MergeConfigMap(settings); err != nil {
+		return nil, ReloadLoad, fmt.Errorf("failed to merge candidate config: %w", err)
+	}
+	if path, environment := m.ConfigFile(), os.Getenv(EnvironmentVar); path != "" && environment != "" {
+		overlay := overlayPath(path, environment)
+		if _, err := os.Stat(overlay); err == nil {
+			var files []string
+			var violations []Violation
+			overrides, err := readWithIncludes(overlay, make(map[string]bool), &files, &violations)
+			if err != nil {
+				return nil, ReloadLoad, err
+			}
+			if len(violations) > 0 {
+				return nil, ReloadLoad, &SchemaError{Violations: violations}
+			}
+			if err := dry.viper.MergeConfigMap(overrides); err != nil {
+				return nil, ReloadLoad, fmt.Errorf("failed to merge config files: %w", err)
+			}
+		}
+	}
+	if remote := m.Remote(); remote != nil {
+		settings, err := fetchRemote(remote)
+		if err != nil {
+			return nil, ReloadLoad, err
+		}
+		if err := dry.viper.MergeConfigMap(settings); err != nil {
+			return nil, ReloadLoad, fmt.Errorf("failed to merge remote config: %w", err)
+		}
+	}
+	if err := mergeEnvironment(dry.viper); err != nil {
+		return nil, ReloadLoad, err
+	}
+
+	config, _, err := dry.decode()
+	if err != nil {
+		return nil, ReloadLoad, err
+	}
+	if err := validate(config); err != nil {
+		return nil, ReloadValidation, err
+	}
+	return config, "", nil
+}
+
+// AffectedServices are the services a change from previous to current,
+// with diff between them, touches: those added, removed or changed, and
+// those of the routes added, removed or changed.
+func AffectedServices(previous, current *models.GatewayConfig, diff Diff) []string {
+	affected := make(map[string]bool)
+	for _, names := range [][]string{diff.ServicesAdded, diff.ServicesRemoved, diff.ServicesChanged} {
+		for _, name := range names {
+			affected[name] = true
+		}
+	}
+
+	changedRoutes := make(map[string]bool)
+	for _, keys := range [][]string{diff.RoutesAdded, diff.RoutesRemoved, diff.RoutesChanged} {
+		for _, key := range keys {
+			changedRoutes[key] = true
+		}
+	}
+	for _, routes := range [][]models.RouteConfig{previous.Routes, current.Routes} {
+		for _, route := range routes {
+			if changedRoutes[routeKey(route)] && route.ServiceName != "" {
+				affected[route.ServiceName] = true
+			}
+		}
+	}
+
+	names := make([]string, 0, len(affected))
+	for name := range affected {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,317 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gateway/internal/models"
+
+	"github.com/spf13/viper"
+)
+
+// EnvPrefix starts the names of the environment variables that set config
+// values: GATEWAY_ and the setting's path in capitals, with dots and
+// list indexes joined by underscores.
+const EnvPrefix = "GATEWAY_"
+
+// envAliases are variables read for a setting when its GATEWAY_ variable
+// is not set.
+var envAliases = map[string][]string{
+	"secrets.vault.address":   {"VAULT_ADDR"},
+	"secrets.vault.token":     {"VAULT_TOKEN"},
+	"secrets.vault.namespace": {"VAULT_NAMESPACE"},
+}
+
+// bindEnv binds every setting that holds a single value to its variable,
+// so server.port is read from GATEWAY_SERVER_PORT. Maps and lists, and
+// the settings inside them, are read by mergeEnvironment.
+func bindEnv(v *viper.Viper) {
+	walkValues(reflect.TypeOf(models.GatewayConfig{}), "", map[reflect.Type]bool{}, func(path string) {
+		v.BindEnv(append([]string{path, envName(path)}, envAliases[path]...)...)
+	})
+}
+
+func envName(path string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(path, ".", "_"))
+}
+
+func walkValues(t reflect.Type, path string, visiting map[reflect.Type]bool, bind func(path string)) {
+	t = indirect(t)
+	if isValue(t) {
+		bind(path)
+		return
+	}
+	if t.Kind() != reflect.Struct || visiting[t] {
+		return
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.IsExported() && field.Tag.Get("yaml") != "-" {
+			walkValues(field.Type, joinPath(path, configName(field)), visiting, bind)
+		}
+	}
+}
+
+func indirect(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+// isValue tells whether settings of type t hold a single value.
+func isValue(t reflect.Type) bool {
+	if t == reflect.TypeOf(time.Duration(0)) || t == reflect.TypeOf(time.Time{}) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		return false
+	}
+	return true
+}
+
+// envSetting is where in the config an environment variable goes.
+type envSetting struct {
+	name  string
+	value string
+	path  []interface{} // map keys and list indexes
+	leaf  reflect.Type
+}
+
+// mergeEnvironment merges the maps and lists set by environment variables
+// into v, over the config files and the remote document. Services, routes
+// and other maps and lists can be set whole, as JSON:
+//
+//	GATEWAY_SERVICES={"orders": {"url": "http://orders:8008"}}
+//	GATEWAY_ROUTES=[{"path": "/api/orders/*", "service_name": "orders"}]
+//
+// or one setting at a time, naming map entries by their key and list
+// entries by their index:
+//
+//	GATEWAY_SERVICES_ORDERS_URL=http://orders:8008
+//	GATEWAY_ROUTES_0_PATH=/api/orders/*
+//
+// Lists of single values may also be separated by commas. A map entry is
+// merged into the entry of the same key in the files; lists replace the
+// files' lists.
+func mergeEnvironment(v *viper.Viper) error {
+	settings, err := environmentSettings(os.Environ())
+	if err != nil {
+		return err
+	}
+	if len(settings) == 0 {
+		return nil
+	}
+	if err := v.MergeConfigMap(settings); err != nil {
+		return fmt.Errorf("failed to merge environment variables: %w", err)
+	}
+	return nil
+}
+
+func environmentSettings(environ []string) (map[string]interface{}, error) {
+	var found []envSetting
+	for _, entry := range environ {
+		name, value, _ := strings.Cut(entry, "=")
+		if !strings.HasPrefix(name, EnvPrefix) {
+			continue
+		}
+		tokens := strings.Split(strings.ToLower(strings.TrimPrefix(name, EnvPrefix)), "_")
+		path, leaf, inCollection, ok := resolveEnv(reflect.TypeOf(models.GatewayConfig{}), tokens, false)
+		// Single values outside maps and lists are bound by bindEnv
+		if !ok || (!inCollection && isValue(leaf)) {
+			continue
+		}
+		found = append(found, envSetting{name: name, value: value, path: path, leaf: leaf})
+	}
+	// Whole maps and lists first, so single settings go into them
+	sort.Slice(found, func(i, j int) bool {
+		if len(found[i].path) != len(found[j].path) {
+			return len(found[i].path) < len(found[j].path)
+		}
+		return found[i].name < found[j].name
+	})
+
+	var settings interface{} = map[string]interface{}{}
+	for _, setting := range found {
+		value, err := envValue(setting)
+		if err != nil {
+			return nil, err
+		}
+		if settings, err = setEnvValue(settings, setting.path, value); err != nil {
+			return nil, fmt.Errorf("%s: %w", setting.name, err)
+		}
+	}
+	if err := checkEnvLists(settings, ""); err != nil {
+		return nil, err
+	}
+	return settings.(map[string]interface{}), nil
+}
+
+// resolveEnv finds the setting tokens name in t. inCollection is set once
+// the path enters a map or list.
+func resolveEnv(t reflect.Type, tokens []string, inCollection bool) ([]interface{}, reflect.Type, bool, bool) {
+	t = indirect(t)
+	if len(tokens) == 0 {
+		return nil, t, inCollection, true
+	}
+	if isValue(t) {
+		return nil, nil, false, false
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() || field.Tag.Get("yaml") == "-" {
+				continue
+			}
+			name := configName(field)
+			nameTokens := strings.Split(name, "_")
+			if len(nameTokens) > len(tokens) || strings.Join(tokens[:len(nameTokens)], "_") != name {
+				continue
+			}
+			if path, leaf, nested, ok := resolveEnv(field.Type, tokens[len(nameTokens):], inCollection); ok {
+				return append([]interface{}{name}, path...), leaf, nested, true
+			}
+		}
+	case reflect.Map:
+		// Keys may contain underscores; the shortest key that leaves a
+		// setting of the entry wins
+		for i := 1; i <= len(tokens); i++ {
+			if path, leaf, _, ok := resolveEnv(t.Elem(), tokens[i:], true); ok {
+				return append([]interface{}{strings.Join(tokens[:i], "_")}, path...), leaf, true, true
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		index, err := strconv.Atoi(tokens[0])
+		if err != nil || index < 0 {
+			return nil, nil, false, false
+		}
+		if path, leaf, _, ok := resolveEnv(t.Elem(), tokens[1:], true); ok {
+			return append([]interface{}{index}, path...), leaf, true, true
+		}
+	}
+	return nil, nil, false, false
+}
+
+func envValue(setting envSetting) (interface{}, error) {
+	if isValue(setting.leaf) {
+		return setting.value, nil
+	}
+	trimmed := strings.TrimSpace(setting.value)
+	isList := setting.leaf.Kind() == reflect.Slice || setting.leaf.Kind() == reflect.Array
+	if isList && !strings.HasPrefix(trimmed, "[") && isValue(indirect(setting.leaf.Elem())) {
+		items := []interface{}{}
+		for _, item := range strings.Split(trimmed, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		return items, nil
+	}
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(trimmed), &value); err != nil {
+		return nil, fmt.Errorf("%s must be JSON: %w", setting.name, err)
+	}
+	_, isObject := value.(map[string]interface{})
+	_, isArray := value.([]interface{})
+	if isList && !isArray || !isList && !isObject {
+		kind := "an object"
+		if isList {
+			kind = "a list"
+		}
+		return nil, fmt.Errorf("%s must be %s in JSON", setting.name, kind)
+	}
+	return lowerKeys(value), nil
+}
+
+// lowerKeys lowercases the keys of JSON objects, as the config loader
+// does, so they meet the keys of single settings.
+func lowerKeys(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		lowered := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			lowered[strings.ToLower(key)] = lowerKeys(item)
+		}
+		return lowered
+	case []interface{}:
+		for i, item := range value {
+			value[i] = lowerKeys(item)
+		}
+	}
+	return value
+}
+
+func setEnvValue(node interface{}, path []interface{}, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	switch step := path[0].(type) {
+	case string:
+		entries, ok := node.(map[string]interface{})
+		if node == nil {
+			entries, ok = map[string]interface{}{}, true
+		}
+		if !ok {
+			return nil, fmt.Errorf("%s is not a map", step)
+		}
+		child, err := setEnvValue(entries[step], path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		entries[step] = child
+		return entries, nil
+	default:
+		index := step.(int)
+		items, ok := node.([]interface{})
+		if node == nil {
+			ok = true
+		}
+		if !ok {
+			return nil, fmt.Errorf("entry %d is not in a list", index)
+		}
+		for len(items) <= index {
+			items = append(items, nil)
+		}
+		child, err := setEnvValue(items[index], path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		items[index] = child
+		return items, nil
+	}
+}
+
+// checkEnvLists rejects lists with entries missing, as when GATEWAY_ROUTES_0_*
+// and GATEWAY_ROUTES_2_* are set but not GATEWAY_ROUTES_1_*.
+func checkEnvLists(node interface{}, path string) error {
+	switch node := node.(type) {
+	case map[string]interface{}:
+		for key, item := range node {
+			if err := checkEnvLists(item, joinPath(path, key)); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for i, item := range node {
+			if item == nil {
+				return fmt.Errorf("%s_%d is not set; number the entries of %s from 0", envName(path), i, path)
+			}
+			if err := checkEnvLists(item, joinPath(path, strconv.Itoa(i))); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
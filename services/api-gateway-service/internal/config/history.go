@@ -0,0 +1,153 @@
+package config
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"gateway/internal/models"
+)
+
+// What put a configuration version in force.
+const (
+	TriggerLoad     = "load"
+	TriggerReload   = "reload"
+	TriggerRollback = "rollback"
+)
+
+// defaultHistorySize applies until a config sets config_history.size.
+const defaultHistorySize = 10
+
+// ErrUnknownVersion is returned for a rollback to a version that is not,
+// or no longer, in the history.
+var ErrUnknownVersion = errors.New("unknown config version")
+
+// ConfigVersion is a configuration that was put in force.
+type ConfigVersion struct {
+	Version   int       `json:"version"`
+	Hash      string    `json:"hash"`
+	AppliedAt time.Time `json:"applied_at"`
+	Trigger   string    `json:"trigger"`
+	// RollbackOf is the version a rollback restored
+	RollbackOf int `json:"rollback_of,omitempty"`
+	// Changes is the size of the diff to the version before
+	Changes int `json:"changes"`
+
+	config      *models.GatewayConfig
+	secretPaths []string
+}
+
+// History keeps the last applied configurations, so a bad reload can be
+// rolled back. Versions are numbered from 1 and never reused, so a
+// rollback adds a version too.
+type History struct {
+	mutex    sync.Mutex
+	size     int
+	last     int
+	versions []ConfigVersion
+}
+
+func NewHistory(size int) *History {
+	if size < 1 {
+		size = 1
+	}
+	return &History{size: size}
+}
+
+// resize keeps the last size versions from now on.
+func (h *History) resize(size int) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if size < 1 {
+		size = 1
+	}
+	h.size = size
+	if len(h.versions) > h.size {
+		h.versions = h.versions[len(h.versions)-h.size:]
+	}
+}
+
+func (h *History) record(version ConfigVersion) ConfigVersion {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.last++
+	version.Version = h.last
+	version.AppliedAt = time.Now().UTC()
+	h.versions = append(h.versions, version)
+	if len(h.versions) > h.size {
+		h.versions = h.versions[len(h.versions)-h.size:]
+	}
+	return version
+}
+
+func (h *History) find(version int) (ConfigVersion, bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for _, entry := range h.versions {
+		if entry.Version == version {
+			return entry, true
+		}
+	}
+	return ConfigVersion{}, false
+}
+
+// Versions lists the kept versions, newest first.
+func (h *History) Versions() []ConfigVersion {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	versions := make([]ConfigVersion, len(h.versions))
+	for i, version := range h.versions {
+		versions[len(versions)-1-i] = version
+	}
+	return versions
+}
+
+// Current is the number of the version in force, or 0 before the first.
+func (h *History) Current() int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.last
+}
+
+// History lists the configurations applied since start.
+func (m *Manager) History() *History {
+	return m.history
+}
+
+// Rollback puts the configuration of version back in force, handing it to
+// apply first like ReloadWith. It is recorded as a new version, and as a
+// reload in ReloadStats. Config files are left as they are, so the next
+// reload applies them again.
+func (m *Manager) Rollback(version int, apply func(previous, current *models.GatewayConfig) error) (Diff, ConfigVersion, error) {
+	m.reloading.Lock()
+	defer m.reloading.Unlock()
+
+	target, found := m.history.find(version)
+	if !found {
+		return Diff{}, ConfigVersion{}, ErrUnknownVersion
+	}
+	previous := m.GetConfig()
+	if apply != nil {
+		if err := apply(previous, target.config); err != nil {
+			m.reloads.Failed(ReloadValidation, err)
+			return Diff{}, ConfigVersion{}, err
+		}
+	}
+
+	m.mutex.Lock()
+	m.config = target.config
+	m.secretPaths = target.secretPaths
+	m.mutex.Unlock()
+
+	diff := DiffConfigs(previous, target.config)
+	m.reloads.Applied(diff.Size(), target.Hash, nil)
+	applied := m.history.record(ConfigVersion{
+		Hash:        target.Hash,
+		Trigger:     TriggerRollback,
+		RollbackOf:  version,
+		Changes:     diff.Size(),
+		config:      target.config,
+		secretPaths: target.secretPaths,
+	})
+	return diff, applied, nil
+}
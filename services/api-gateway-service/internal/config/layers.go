@@ -0,0 +1,178 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvironmentVar names the environment whose overlay is merged over the
+// config file: with GATEWAY_ENV=staging, config.staging.yaml next to
+// config.yaml.
+const EnvironmentVar = "GATEWAY_ENV"
+
+// includeKey lists, in a config file, further files merged into it. Paths
+// are relative to the file and may be glob patterns.
+const includeKey = "include"
+
+// readLayers reads the config file at path with everything it includes
+// and, when environment is set and has an overlay file, the overlay with
+// its includes on top. It returns the merged settings and every file read,
+// in the order they were read.
+//
+// Included files add to the file that names them: maps are merged, lists
+// such as routes are appended in include order, and a setting given two
+// different values is an error rather than a matter of order. The overlay
+// then overrides: its maps are merged too, but its lists and values
+// replace the base's.
+//
+// Every file is checked against the schema, and a *SchemaError lists all
+// the problems found in any of them.
+func readLayers(path, environment string) (map[string]interface{}, []string, error) {
+	var files []string
+	var violations []Violation
+	seen := make(map[string]bool)
+	settings, err := readWithIncludes(path, seen, &files, &violations)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if environment != "" {
+		overlay := overlayPath(path, environment)
+		if _, err := os.Stat(overlay); err == nil {
+			overrides, err := readWithIncludes(overlay, seen, &files, &violations)
+			if err != nil {
+				return nil, nil, err
+			}
+			mergeOverlay(settings, overrides)
+		} else if !os.IsNotExist(err) {
+			return nil, nil, fmt.Errorf("reading %s overlay: %w", environment, err)
+		}
+	}
+	if len(violations) > 0 {
+		return nil, nil, &SchemaError{Violations: violations}
+	}
+	return settings, files, nil
+}
+
+// overlayPath is the overlay of environment for the config file at path,
+// e.g. config.staging.yaml for config.yaml.
+func overlayPath(path, environment string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + environment + ext
+}
+
+func readWithIncludes(path string, seen map[string]bool, files *[]string, violations *[]Violation) (map[string]interface{}, error) {
+	absolute, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if seen[absolute] {
+		return nil, fmt.Errorf("%s is included more than once", path)
+	}
+	seen[absolute] = true
+	*files = append(*files, path)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	settings := make(map[string]interface{})
+	if err := yaml.Unmarshal(data, &settings); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	*violations = append(*violations, CheckSchema(path, data)...)
+
+	patterns, err := includePatterns(settings[includeKey])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	delete(settings, includeKey)
+	for _, pattern := range patterns {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(path), pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid include %s: %w", path, pattern, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("%s: include %s matches no files", path, pattern)
+		}
+		sort.Strings(matches)
+		for _, match := range matches {
+			included, err := readWithIncludes(match, seen, files, violations)
+			if err != nil {
+				return nil, err
+			}
+			if err := mergeIncluded(settings, included, ""); err != nil {
+				return nil, fmt.Errorf("including %s: %w", match, err)
+			}
+		}
+	}
+	return settings, nil
+}
+
+// includePatterns accepts a single pattern or a list of them.
+func includePatterns(value interface{}) ([]string, error) {
+	switch value := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{value}, nil
+	case []interface{}:
+		patterns := make([]string, 0, len(value))
+		for _, item := range value {
+			pattern, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("include must list file paths")
+			}
+			patterns = append(patterns, pattern)
+		}
+		return patterns, nil
+	default:
+		return nil, fmt.Errorf("include must be a file path or a list of them")
+	}
+}
+
+func mergeIncluded(dst, src map[string]interface{}, prefix string) error {
+	for key, value := range src {
+		existing, exists := dst[key]
+		if !exists {
+			dst[key] = value
+			continue
+		}
+		existingMap, existingIsMap := existing.(map[string]interface{})
+		valueMap, valueIsMap := value.(map[string]interface{})
+		existingList, existingIsList := existing.([]interface{})
+		valueList, valueIsList := value.([]interface{})
+		switch {
+		case existingIsMap && valueIsMap:
+			if err := mergeIncluded(existingMap, valueMap, prefix+key+"."); err != nil {
+				return err
+			}
+		case existingIsList && valueIsList:
+			dst[key] = append(existingList, valueList...)
+		case !reflect.DeepEqual(existing, value):
+			return fmt.Errorf("%s%s is set to different values", prefix, key)
+		}
+	}
+	return nil
+}
+
+func mergeOverlay(dst, src map[string]interface{}) {
+	for key, value := range src {
+		existingMap, existingIsMap := dst[key].(map[string]interface{})
+		valueMap, valueIsMap := value.(map[string]interface{})
+		if existingIsMap && valueIsMap {
+			mergeOverlay(existingMap, valueMap)
+			continue
+		}
+		dst[key] = value
+	}
+}
@@ -1,19 +1,39 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/url"
 	"os"
+	"path"
+	"runtime"
 	"strings"
+	"sync"
 	"time"
 
+	"gateway/internal/ipaccess"
 	"gateway/internal/models"
+	"gateway/internal/secrets"
+	"gateway/internal/tlsconfig"
 
 	"github.com/spf13/viper"
 )
 
 type Manager struct {
-	config *models.GatewayConfig
-	viper  *viper.Viper
+	mutex   sync.RWMutex
+	config  *models.GatewayConfig
+	viper   *viper.Viper
+	reloads *ReloadStats
+	// reloading serializes reloads, which may be triggered by a signal
+	// and a file change at once
+	reloading sync.Mutex
+	// files are the config file, its includes and overlay as last read
+	files []string
+	// remote is merged over the files when set
+	remote RemoteSource
+	// secretPaths are the values of config resolved from secret references
+	secretPaths []string
+	history     *History
 }
 
 func NewManager() *Manager {
@@ -25,6 +45,13 @@ func NewManager() *Manager {
 	v.SetDefault("server.read_timeout", "30s")
 	v.SetDefault("server.write_timeout", "30s")
 	v.SetDefault("server.idle_timeout", "60s")
+	v.SetDefault("server.workers", 1)
+	v.SetDefault("server.limits.max_header_bytes", 64<<10)
+	v.SetDefault("server.limits.max_header_length", 16<<10)
+	v.SetDefault("server.limits.max_headers", 100)
+	v.SetDefault("server.limits.max_url_length", 8<<10)
+	v.SetDefault("server.tls.acme.cache_dir", "acme-cache")
+	v.SetDefault("server.tls.acme.http_port", 80)
 
 	v.SetDefault("rate_limit.name", "default")
 	v.SetDefault("rate_limit.requests", 100)
@@ -32,7 +59,15 @@ func NewManager() *Manager {
 	v.SetDefault("rate_limit.burst", 200)
 	v.SetDefault("rate_limit.scope", "per_ip")
 	v.SetDefault("rate_limit.enabled", true)
+	v.SetDefault("rate_limit.penalty.enabled", true)
+	v.SetDefault("rate_limit.penalty.threshold", 20)
+	v.SetDefault("rate_limit.penalty.half_life", "1m")
+	v.SetDefault("rate_limit.penalty.rate_factor", 0.25)
+	v.SetDefault("rate_limit.penalty.tarpit_delay", "250ms")
+	v.SetDefault("rate_limit.penalty.max_tarpit_delay", "2s")
 
+	v.SetDefault("circuit_breaker.enabled", true)
+	v.SetDefault("circuit_breaker.strategy", "fixed_window")
 	v.SetDefault("circuit_breaker.max_requests", 3)
 	v.SetDefault("circuit_breaker.interval", "60s")
 	v.SetDefault("circuit_breaker.timeout", "30s")
@@ -41,29 +76,185 @@ func NewManager() *Manager {
 	v.SetDefault("auth.service_url", "http://localhost:8001")
 	v.SetDefault("auth.timeout", "5s")
 	v.SetDefault("auth.cache_ttl", "5m")
+	v.SetDefault("auth.negative_cache_ttl", "30s")
+	v.SetDefault("auth.cache_size", 10000)
+	v.SetDefault("auth.identity_headers", map[string]string{
+		"user_id": "X-User-ID",
+		"email":   "X-User-Email",
+		"name":    "X-User-Name",
+		"scopes":  "X-Scopes",
+	})
+	v.SetDefault("auth.revocation.enabled", false)
+	v.SetDefault("auth.revocation.source", "auth_service")
+	v.SetDefault("auth.revocation.path", "/auth/revocations")
+	v.SetDefault("auth.revocation.interval", "30s")
 
 	v.SetDefault("logging.level", "info")
 	v.SetDefault("logging.format", "json")
+	v.SetDefault("logging.max_size", 100)
+	v.SetDefault("logging.max_backups", 5)
+	v.SetDefault("logging.max_override", "1h")
 
-	// Configure environment variable support (but not for complex structures)
-	v.SetEnvPrefix("GATEWAY")
-	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
-	// Don't use AutomaticEnv() as it can interfere with complex structures
-	// Instead, bind specific variables we want to support
-	v.BindEnv("server.host", "GATEWAY_SERVER_HOST")
-	v.BindEnv("server.port", "GATEWAY_SERVER_PORT")
-	v.BindEnv("rate_limit.requests", "GATEWAY_RATE_LIMIT_REQUESTS")
-	v.BindEnv("rate_limit.window", "GATEWAY_RATE_LIMIT_WINDOW")
-	v.BindEnv("rate_limit.burst", "GATEWAY_RATE_LIMIT_BURST")
-	v.BindEnv("auth.service_url", "GATEWAY_AUTH_SERVICE_URL")
-	v.BindEnv("logging.level", "GATEWAY_LOGGING_LEVEL")
+	v.SetDefault("etcd.enabled", false)
+	v.SetDefault("etcd.prefix", "/gateway")
+	v.SetDefault("etcd.dial_timeout", "5s")
+
+	v.SetDefault("remote_config.enabled", false)
+	v.SetDefault("remote_config.provider", "etcd")
+	v.SetDefault("remote_config.consul.address", "http://127.0.0.1:8500")
+	v.SetDefault("remote_config.consul.timeout", "5s")
+
+	v.SetDefault("config_history.size", 10)
+
+	v.SetDefault("secrets.vault.timeout", "5s")
+	v.SetDefault("secrets.vault.cache_ttl", "5m")
+
+	v.SetDefault("bff.enabled", false)
+	v.SetDefault("bff.refresh_before", "1m")
+	v.SetDefault("bff.csrf_header", "X-Requested-With")
+
+	v.SetDefault("oidc.enabled", false)
+	v.SetDefault("oidc.scopes", []string{"openid", "profile", "email"})
+	v.SetDefault("oidc.timeout", "10s")
+
+	v.SetDefault("registration.enabled", false)
+	v.SetDefault("registration.default_ttl", "30s")
+	v.SetDefault("registration.max_ttl", "5m")
+
+	v.SetDefault("session.store", "memory")
+	v.SetDefault("session.ttl", "24h")
+	v.SetDefault("session.cookie_name", "gw_session")
+	v.SetDefault("session.cookie_secure", true)
+	v.SetDefault("session.same_site", "lax")
+	v.SetDefault("session.max_per_user", 0)
+
+	v.SetDefault("upstream_dial.happy_eyeballs", true)
+	v.SetDefault("upstream_dial.attempt_delay", "250ms")
+	v.SetDefault("upstream_dial.connect_timeout", "10s")
+	v.SetDefault("upstream_dial.keep_alive", "30s")
+
+	v.SetDefault("session_auth.enabled", false)
+	v.SetDefault("session_auth.refresh_after", "5m")
+	v.SetDefault("session_auth.csrf_header", "X-Requested-With")
+
+	v.SetDefault("redis.address", "localhost:6379")
+	v.SetDefault("redis.key_prefix", "gateway:")
+
+	v.SetDefault("health_check.interval", "30s")
+	v.SetDefault("health_check.max_concurrency", 10)
+	v.SetDefault("health_check.jitter", 0.1)
+	v.SetDefault("health_check.rise", 2)
+	v.SetDefault("health_check.fall", 3)
+	v.SetDefault("health_check.flap_window", "5m")
+	v.SetDefault("health_check.flap_threshold", 4)
+	v.SetDefault("health_check.passive.enabled", true)
+	v.SetDefault("health_check.passive.window", "30s")
+	v.SetDefault("health_check.passive.min_requests", 10)
+	v.SetDefault("health_check.passive.failure_ratio", 0.5)
+	v.SetDefault("health_check.sla.window", "1m")
+	v.SetDefault("health_check.sla.min_requests", 20)
+	v.SetDefault("health_check.sla.alert_ratio", 0.1)
+
+	v.SetDefault("events.log_size", 500)
+	v.SetDefault("events.max_subscribers", 20)
+	v.SetDefault("admin.audit.log_size", 1000)
+
+	v.SetDefault("overload.enabled", false)
+	v.SetDefault("overload.check_interval", "5s")
+	v.SetDefault("overload.cpu_threshold", 0.9)
+	v.SetDefault("overload.memory_threshold", 0.85)
+	v.SetDefault("overload.cool_down", "30s")
+	v.SetDefault("overload.health_interval_multiplier", 4)
+	v.SetDefault("overload.quiet_logs", true)
+
+	v.SetDefault("watchdog.enabled", true)
+	v.SetDefault("watchdog.interval", "30s")
+	v.SetDefault("watchdog.max_goroutines", 10000)
+	v.SetDefault("watchdog.max_connections", 10000)
+	v.SetDefault("watchdog.max_upstream_connections", 5000)
+	v.SetDefault("watchdog.dump_interval", "15m")
+
+	v.SetDefault("i18n.default_locale", "en")
+	v.SetDefault("validation_errors", "passthrough")
+
+	v.SetDefault("forensics.enabled", true)
+	v.SetDefault("forensics.size", 100)
+	v.SetDefault("forensics.max_body_size", 4096)
+	v.SetDefault("forensics.capture.size", 200)
+	v.SetDefault("forensics.capture.max_duration", "1h")
+
+	v.SetDefault("quota.enabled", false)
+	v.SetDefault("quota.header", "X-API-Key")
+	v.SetDefault("quota.store", "memory")
+	v.SetDefault("anomalies.enabled", false)
+	v.SetDefault("anomalies.sample_rate", 0.1)
+	v.SetDefault("anomalies.window", "1m")
+	v.SetDefault("anomalies.min_samples", 20)
+	v.SetDefault("anomalies.mismatch_ratio", 0.5)
+	v.SetDefault("anomalies.size_factor", 5)
+	v.SetDefault("anomalies.max_body_size", 64<<10)
+	v.SetDefault("plans.enabled", false)
+	v.SetDefault("plans.claim", "plan")
+
+	v.SetDefault("cache.enabled", false)
+	v.SetDefault("cache.max_entries", 10000)
+	v.SetDefault("cache.max_body_size", 1<<20)
+	v.SetDefault("cache.warmup.concurrency", 4)
+
+	v.SetDefault("debug.enabled", false)
+
+	v.SetDefault("maintenance.enabled", false)
+	v.SetDefault("maintenance.content_type", "application/json")
+
+	v.SetDefault("cors.enabled", true)
+	v.SetDefault("cors.allowed_origins", []string{"*"})
+	v.SetDefault("cors.allowed_methods", []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"})
+	v.SetDefault("cors.allowed_headers", []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Correlation-ID", "X-Max-Wait"})
+	v.SetDefault("cors.exposed_headers", []string{"X-Correlation-ID"})
+
+	v.SetDefault("geoip.enabled", false)
+
+	// Every setting can be set with a GATEWAY_ environment variable; maps
+	// and lists are merged in by load
+	bindEnv(v)
 
 	return &Manager{
-		viper: v,
+		viper:   v,
+		reloads: NewReloadStats("file"),
+		history: NewHistory(defaultHistorySize),
 	}
 }
 
 func (m *Manager) LoadConfig(configPath string) error {
+	config, secretPaths, err := m.load(configPath)
+	if err != nil {
+		return err
+	}
+	return m.activate(config, secretPaths)
+}
+
+// activate puts config in force as the initial configuration, without
+// counting a reload. Secret references resolved on use read from the
+// Vault it configures, which reloads leave as it is.
+func (m *Manager) activate(config *models.GatewayConfig, secretPaths []string) error {
+	vault, err := secrets.NewVault(config.Secrets.Vault)
+	if err != nil {
+		return err
+	}
+	secrets.UseVault(vault)
+
+	m.mutex.Lock()
+	m.config = config
+	m.secretPaths = secretPaths
+	m.mutex.Unlock()
+	hash := Hash(config)
+	m.reloads.Activate(hash)
+	m.history.resize(config.ConfigHistory.Size)
+	m.history.record(ConfigVersion{Hash: hash, Trigger: TriggerLoad, config: config, secretPaths: secretPaths})
+	return nil
+}
+
+func (m *Manager) load(configPath string) (*models.GatewayConfig, []string, error) {
 	// Try to load from file if provided
 	if configPath != "" {
 		m.viper.SetConfigFile(configPath)
@@ -79,25 +270,71 @@ func (m *Manager) LoadConfig(configPath string) error {
 	// Read config file (optional)
 	if err := m.viper.ReadInConfig(); err != nil {
 		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
-			return fmt.Errorf("failed to read config file: %w", err)
+			return nil, nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		// Config file not found is not an error - we can use defaults and env vars.
+		// Start over from them, as a previous load may have merged settings.
+		if err := m.viper.ReadConfig(strings.NewReader("")); err != nil {
+			return nil, nil, fmt.Errorf("failed to reset config: %w", err)
+		}
+	}
+
+	// Merge in included files and the environment overlay
+	var files []string
+	if path := m.viper.ConfigFileUsed(); path != "" {
+		settings, read, err := readLayers(path, os.Getenv(EnvironmentVar))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		if len(read) > 1 {
+			if err := m.viper.MergeConfigMap(settings); err != nil {
+				return nil, nil, fmt.Errorf("failed to merge config files: %w", err)
+			}
 		}
-		// Config file not found is not an error - we can use defaults and env vars
+		files = read
 	}
+	m.mutex.Lock()
+	m.files = files
+	remote := m.remote
+	m.mutex.Unlock()
 
+	// The remote document overrides the files; environment variables still
+	// override both
+	if remote != nil {
+		settings, err := fetchRemote(remote)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := m.viper.MergeConfigMap(settings); err != nil {
+			return nil, nil, fmt.Errorf("failed to merge remote config: %w", err)
+		}
+	}
+	if err := mergeEnvironment(m.viper); err != nil {
+		return nil, nil, err
+	}
+
+	return m.decode()
+}
+
+// decode turns the settings read into a configuration, with secret
+// references resolved.
+func (m *Manager) decode() (*models.GatewayConfig, []string, error) {
 	// Unmarshal into our config struct
 	config := models.NewDefaultGatewayConfig()
 	if err := m.viper.Unmarshal(config); err != nil {
-		return fmt.Errorf("failed to unmarshal config: %w", err)
+		return nil, nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
 
-
 	// Parse duration strings
 	if err := m.parseDurations(config); err != nil {
-		return fmt.Errorf("failed to parse durations: %w", err)
+		return nil, nil, fmt.Errorf("failed to parse durations: %w", err)
 	}
 
-	m.config = config
-	return nil
+	secretPaths, err := resolveSecrets(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	return config, secretPaths, nil
 }
 
 func (m *Manager) parseDurations(config *models.GatewayConfig) error {
@@ -169,6 +406,9 @@ func (m *Manager) parseDurations(config *models.GatewayConfig) error {
 }
 
 func (m *Manager) GetConfig() *models.GatewayConfig {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
 	if m.config == nil {
 		return models.NewDefaultGatewayConfig()
 	}
@@ -176,21 +416,208 @@ func (m *Manager) GetConfig() *models.GatewayConfig {
 }
 
 func (m *Manager) GetServerAddress() string {
-	return fmt.Sprintf("%s:%d", m.config.Server.Host, m.config.Server.Port)
+	config := m.GetConfig()
+	return fmt.Sprintf("%s:%d", config.Server.Host, config.Server.Port)
 }
 
-func (m *Manager) Reload() error {
-	configFile := m.viper.ConfigFileUsed()
-	return m.LoadConfig(configFile)
+// Reload re-reads the config file. A config that fails to load or to
+// validate is not applied and the previous one stays in force; either way
+// the outcome is recorded in ReloadStats.
+func (m *Manager) Reload() (Diff, error) {
+	return m.ReloadWith(nil)
+}
+
+// ReloadWith is Reload that also hands a valid config to apply, with the
+// one in force, before it takes effect. If apply fails the previous config
+// stays in force and the reload counts as failing validation, so apply
+// must leave things as they were when it returns an error.
+func (m *Manager) ReloadWith(apply func(previous, current *models.GatewayConfig) error) (Diff, error) {
+	m.reloading.Lock()
+	defer m.reloading.Unlock()
+
+	config, secretPaths, err := m.load(m.viper.ConfigFileUsed())
+	if err != nil {
+		m.reloads.Failed(ReloadLoad, err)
+		return Diff{}, err
+	}
+	if err := validate(config); err != nil {
+		m.reloads.Failed(ReloadValidation, err)
+		return Diff{}, err
+	}
+	if apply != nil {
+		if err := apply(m.GetConfig(), config); err != nil {
+			m.reloads.Failed(ReloadValidation, err)
+			return Diff{}, err
+		}
+	}
+
+	m.mutex.Lock()
+	previous := m.config
+	m.config = config
+	m.secretPaths = secretPaths
+	m.mutex.Unlock()
+
+	var diff Diff
+	if previous != nil {
+		diff = DiffConfigs(previous, config)
+	}
+	hash := Hash(config)
+	m.reloads.Applied(diff.Size(), hash, nil)
+	m.history.record(ConfigVersion{Hash: hash, Trigger: TriggerReload, Changes: diff.Size(), config: config, secretPaths: secretPaths})
+	return diff, nil
+}
+
+// ConfigFile is the path of the config file in use, or "" when the
+// configuration came from defaults and the environment alone.
+func (m *Manager) ConfigFile() string {
+	return m.viper.ConfigFileUsed()
+}
+
+// SecretPaths are the paths, such as redis.password, of the config values
+// that were resolved from secret references.
+func (m *Manager) SecretPaths() []string {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return append([]string(nil), m.secretPaths...)
+}
+
+// ConfigFiles are the files the configuration was read from: the config
+// file, the files it includes and the environment overlay, in the order
+// they were read.
+func (m *Manager) ConfigFiles() []string {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return append([]string(nil), m.files...)
+}
+
+// ReloadStats tracks reloads of the config file.
+func (m *Manager) ReloadStats() *ReloadStats {
+	return m.reloads
 }
 
 func (m *Manager) ValidateConfig() error {
-	config := m.GetConfig()
+	return validate(m.GetConfig())
+}
+
+func validate(config *models.GatewayConfig) error {
 
 	// Validate server config
 	if config.Server.Port < 1000 || config.Server.Port > 65535 {
 		return fmt.Errorf("invalid server port: %d", config.Server.Port)
 	}
+	if config.Server.Workers < 0 {
+		return fmt.Errorf("server workers must not be negative")
+	}
+	if config.Server.Workers > 1 && runtime.GOOS != "linux" {
+		return fmt.Errorf("server workers need SO_REUSEPORT, which is only supported on linux")
+	}
+	limits := config.Server.Limits
+	for name, limit := range map[string]int{
+		"max_header_bytes":  limits.MaxHeaderBytes,
+		"max_header_length": limits.MaxHeaderLength,
+		"max_headers":       limits.MaxHeaders,
+		"max_url_length":    limits.MaxURLLength,
+	} {
+		if limit < 0 {
+			return fmt.Errorf("server limits %s must not be negative", name)
+		}
+	}
+	if acme := config.Server.TLS.ACME; acme.Enabled {
+		if err := validateACME(acme, config.Server); err != nil {
+			return err
+		}
+	}
+	if tls := config.Server.TLS; tls.CertFile != "" || tls.KeyFile != "" || tls.Cert != "" || tls.Key != "" || tls.ClientCAFile != "" || tls.MinVersion != "" || len(tls.CipherSuites) > 0 {
+		inline := tls.Cert != "" || tls.Key != ""
+		if tls.ACME.Enabled && (inline || tls.CertFile != "" || tls.KeyFile != "") {
+			return fmt.Errorf("server tls takes either acme or a certificate")
+		}
+		if inline && (tls.CertFile != "" || tls.KeyFile != "") {
+			return fmt.Errorf("server tls takes either cert and key or cert_file and key_file")
+		}
+		if inline && (tls.Cert == "" || tls.Key == "") {
+			return fmt.Errorf("server tls needs both cert and key")
+		}
+		if !inline && !tls.ACME.Enabled && (tls.CertFile == "" || tls.KeyFile == "") {
+			return fmt.Errorf("server tls needs both cert_file and key_file")
+		}
+		switch tls.ClientAuth {
+		case "", models.ClientAuthOptional, models.ClientAuthRequired:
+		default:
+			return fmt.Errorf("unsupported server tls client_auth: %s", tls.ClientAuth)
+		}
+		if tls.ClientAuth != "" && tls.ClientCAFile == "" {
+			return fmt.Errorf("server tls client_auth needs a client_ca_file")
+		}
+		if _, err := tlsconfig.MinVersion(tls.MinVersion); err != nil {
+			return fmt.Errorf("server tls min_version: %w", err)
+		}
+		if _, err := tlsconfig.CipherSuites(tls.CipherSuites); err != nil {
+			return fmt.Errorf("server tls cipher_suites: %w", err)
+		}
+	}
+
+	adminTokens := make(map[string]bool)
+	for i, token := range config.Admin.Tokens {
+		if token.Name == "" || token.Token == "" {
+			return fmt.Errorf("admin token %d needs a name and a token", i)
+		}
+		if adminTokens[token.Token] || token.Token == config.Admin.Token {
+			return fmt.Errorf("admin token %s reuses another admin token", token.Name)
+		}
+		adminTokens[token.Token] = true
+		if len(token.Scopes) == 0 {
+			return fmt.Errorf("admin token %s needs at least one scope", token.Name)
+		}
+		for _, scope := range token.Scopes {
+			switch scope {
+			case models.AdminScopeRead, models.AdminScopeMutate, models.AdminScopeSecrets:
+			default:
+				return fmt.Errorf("admin token %s has unsupported scope: %s", token.Name, scope)
+			}
+		}
+	}
+	if config.Admin.Audit.LogSize < 1 {
+		return fmt.Errorf("admin audit log_size must be at least 1")
+	}
+
+	switch config.Logging.Format {
+	case models.LogFormatJSON, models.LogFormatText, models.LogFormatCombined:
+		if config.Logging.Template != "" {
+			return fmt.Errorf("logging template requires the %s format", models.LogFormatCustom)
+		}
+	case models.LogFormatCustom:
+		if config.Logging.Template == "" {
+			return fmt.Errorf("logging format %s requires a template", models.LogFormatCustom)
+		}
+		if _, err := models.ParseLogTemplate(config.Logging.Template); err != nil {
+			return fmt.Errorf("invalid logging template: %w", err)
+		}
+	default:
+		return fmt.Errorf("unsupported logging format: %s", config.Logging.Format)
+	}
+	if !models.ValidLogLevel(config.Logging.Level) {
+		return fmt.Errorf("unsupported logging level: %s", config.Logging.Level)
+	}
+	if config.Logging.MaxSize < 0 || config.Logging.MaxBackups < 0 {
+		return fmt.Errorf("logging max_size and max_backups must not be negative")
+	}
+	if config.Logging.MaxOverride <= 0 {
+		return fmt.Errorf("logging max_override must be positive")
+	}
+	if sampling := config.Logging.Sampling; sampling != nil {
+		if sampling.SlowThreshold < 0 {
+			return fmt.Errorf("logging sampling slow_threshold must not be negative")
+		}
+		for i, rule := range sampling.Rules {
+			if rule.Rate < 0 || rule.Rate > 1 {
+				return fmt.Errorf("logging sampling rule %d rate must be between 0 and 1", i)
+			}
+			if !rule.ValidStatus() {
+				return fmt.Errorf("logging sampling rule %d has invalid status: %s", i, rule.Status)
+			}
+		}
+	}
 
 	// Validate rate limit config
 	if config.RateLimit.Enabled {
@@ -203,41 +630,928 @@ func (m *Manager) ValidateConfig() error {
 		if config.RateLimit.Window <= 0 {
 			return fmt.Errorf("rate limit window must be positive")
 		}
+		switch config.RateLimit.Scope {
+		case models.ScopeGlobal, models.ScopePerIP, models.ScopePerUser:
+		default:
+			return fmt.Errorf("unsupported rate limit scope: %s", config.RateLimit.Scope)
+		}
+		if penalty := config.RateLimit.Penalty; penalty.Enabled {
+			if penalty.Threshold < 1 || penalty.HalfLife <= 0 {
+				return fmt.Errorf("rate limit penalty threshold must be at least 1 and half_life positive")
+			}
+			if penalty.RateFactor <= 0 || penalty.RateFactor > 1 {
+				return fmt.Errorf("rate limit penalty rate_factor must be in (0, 1]")
+			}
+			if penalty.TarpitDelay < 0 || penalty.MaxTarpitDelay < penalty.TarpitDelay {
+				return fmt.Errorf("rate limit penalty max_tarpit_delay must be >= tarpit_delay")
+			}
+		}
 	}
 
 	// Validate circuit breaker config
 	if config.CircuitBreaker.FailureThreshold < 0 || config.CircuitBreaker.FailureThreshold > 1 {
 		return fmt.Errorf("circuit breaker failure threshold must be between 0 and 1")
 	}
+	if config.CircuitBreaker.Enabled {
+		if err := validateCircuitBreaker(config.CircuitBreaker); err != nil {
+			return err
+		}
+	}
 
 	// Validate services
 	for name, service := range config.Services {
-		if service.Name == "" {
-			return fmt.Errorf("service %s has empty name", name)
+		if err := ValidateService(name, service, config.CircuitBreaker); err != nil {
+			return err
+		}
+	}
+
+	// Validate health check config
+	if config.HealthCheck.Interval < time.Second {
+		return fmt.Errorf("health_check interval must be at least 1s")
+	}
+	if config.HealthCheck.MaxConcurrency < 1 {
+		return fmt.Errorf("health_check max_concurrency must be at least 1")
+	}
+	if config.HealthCheck.Jitter < 0 || config.HealthCheck.Jitter > 0.5 {
+		return fmt.Errorf("health_check jitter must be between 0 and 0.5")
+	}
+	if config.HealthCheck.Rise < 1 || config.HealthCheck.Fall < 1 {
+		return fmt.Errorf("health_check rise and fall must be at least 1")
+	}
+	if config.HealthCheck.FlapThreshold < 0 {
+		return fmt.Errorf("health_check flap_threshold must not be negative")
+	}
+	if config.HealthCheck.FlapThreshold > 0 && config.HealthCheck.FlapWindow <= 0 {
+		return fmt.Errorf("health_check flap_window must be positive when flap detection is enabled")
+	}
+	if passive := config.HealthCheck.Passive; passive.Enabled {
+		if passive.Window <= 0 {
+			return fmt.Errorf("passive health check window must be positive")
+		}
+		if passive.MinRequests < 1 {
+			return fmt.Errorf("passive health check min_requests must be at least 1")
+		}
+		if passive.FailureRatio <= 0 || passive.FailureRatio > 1 {
+			return fmt.Errorf("passive health check failure_ratio must be in (0, 1]")
+		}
+	}
+
+	if sla := config.HealthCheck.SLA; sla.Window <= 0 || sla.MinRequests < 1 || sla.AlertRatio < 0 || sla.AlertRatio > 1 {
+		return fmt.Errorf("health_check sla needs a positive window, min_requests of at least 1 and alert_ratio in [0, 1]")
+	}
+
+	switch config.ValidationErrors {
+	case models.ValidationErrorsPassthrough, models.ValidationErrorsNormalize:
+	default:
+		return fmt.Errorf("unsupported validation_errors mode: %s", config.ValidationErrors)
+	}
+
+	if config.Auth.CacheTTL < 0 || config.Auth.NegativeCacheTTL < 0 {
+		return fmt.Errorf("auth cache_ttl and negative_cache_ttl must not be negative")
+	}
+	if config.Auth.CacheTTL > 0 && config.Auth.CacheSize < 1 {
+		return fmt.Errorf("auth cache_size must be at least 1 when the cache is enabled")
+	}
+	if revocation := config.Auth.Revocation; revocation.Enabled {
+		switch revocation.Source {
+		case models.RevocationSourceAuthService:
+			if !strings.HasPrefix(revocation.Path, "/") {
+				return fmt.Errorf("auth revocation path must start with /")
+			}
+		case models.RevocationSourceRedis:
+			if config.Redis.Address == "" {
+				return fmt.Errorf("auth revocation from redis needs redis.address")
+			}
+		default:
+			return fmt.Errorf("unsupported auth revocation source: %s", revocation.Source)
+		}
+		if revocation.Interval <= 0 {
+			return fmt.Errorf("auth revocation interval must be positive")
+		}
+	}
+	for name, provider := range config.AuthProviders {
+		if name == "" {
+			return fmt.Errorf("auth provider names must not be empty")
+		}
+		if !strings.HasPrefix(provider.ServiceURL, "http://") && !strings.HasPrefix(provider.ServiceURL, "https://") {
+			return fmt.Errorf("auth provider %s must have an http(s) service_url", name)
+		}
+		if provider.VerifyPath != "" && !strings.HasPrefix(provider.VerifyPath, "/") {
+			return fmt.Errorf("auth provider %s verify_path must start with /", name)
+		}
+		if provider.Timeout < 0 || provider.CacheTTL < 0 || provider.NegativeCacheTTL < 0 {
+			return fmt.Errorf("auth provider %s durations must not be negative", name)
+		}
+		if provider.CacheTTL > 0 && provider.CacheSize < 1 {
+			return fmt.Errorf("auth provider %s cache_size must be at least 1 when the cache is enabled", name)
+		}
+	}
+
+	if config.Forensics.Enabled && (config.Forensics.Size < 1 || config.Forensics.MaxBodySize < 0) {
+		return fmt.Errorf("forensics size must be at least 1 and max_body_size not negative")
+	}
+	if config.Forensics.Capture.Size < 1 || config.Forensics.Capture.MaxDuration <= 0 {
+		return fmt.Errorf("forensics capture size must be at least 1 and max_duration positive")
+	}
+	if config.Forensics.MaxBodySize < 0 {
+		return fmt.Errorf("forensics max_body_size cannot be negative")
+	}
+
+	// Plans count their quotas in the quota store too
+	if config.Quota.Enabled || config.Plans.Enabled {
+		switch config.Quota.Store {
+		case models.QuotaStoreMemory:
+		case models.QuotaStoreRedis:
+			if config.Redis.Address == "" {
+				return fmt.Errorf("quota store redis needs redis.address")
+			}
+		default:
+			return fmt.Errorf("invalid quota store: %s", config.Quota.Store)
+		}
+		if config.Quota.Header == "" {
+			return fmt.Errorf("quota header must not be empty")
+		}
+		if config.Quota.Daily < 0 || config.Quota.Monthly < 0 {
+			return fmt.Errorf("quota allowances must not be negative")
+		}
+	}
+	if config.Plans.Enabled {
+		if err := validatePlans(config.Plans); err != nil {
+			return err
+		}
+	}
+	if anomalies := config.Anomalies; anomalies.Enabled {
+		if anomalies.SampleRate <= 0 || anomalies.SampleRate > 1 {
+			return fmt.Errorf("anomalies sample_rate must be in (0, 1]")
+		}
+		if anomalies.Window <= 0 || anomalies.MinSamples < 1 {
+			return fmt.Errorf("anomalies need a positive window and min_samples")
+		}
+		if anomalies.MaxBodySize < 0 {
+			return fmt.Errorf("anomalies max_body_size cannot be negative")
+		}
+		if anomalies.MismatchRatio <= 0 || anomalies.MismatchRatio > 1 {
+			return fmt.Errorf("anomalies mismatch_ratio must be in (0, 1]")
+		}
+		if anomalies.SizeFactor != 0 && anomalies.SizeFactor <= 1 {
+			return fmt.Errorf("anomalies size_factor must be above 1, or 0 to disable")
+		}
+	}
+
+	// Validate events config
+	if config.Events.LogSize < 1 {
+		return fmt.Errorf("events log_size must be at least 1")
+	}
+	if config.Events.MaxSubscribers < 1 {
+		return fmt.Errorf("events max_subscribers must be at least 1")
+	}
+	for i, webhook := range config.Events.Webhooks {
+		if !strings.HasPrefix(webhook.URL, "http://") && !strings.HasPrefix(webhook.URL, "https://") {
+			return fmt.Errorf("events webhook %d must have an http(s) url", i)
+		}
+		switch webhook.Format {
+		case "", models.WebhookGeneric, models.WebhookSlack:
+		default:
+			return fmt.Errorf("events webhook %d has unsupported format: %s", i, webhook.Format)
+		}
+	}
+
+	// Validate overload config
+	if overload := config.Overload; overload.Enabled {
+		if overload.CheckInterval <= 0 || overload.CoolDown < 0 {
+			return fmt.Errorf("overload check_interval must be positive and cool_down not negative")
+		}
+		if overload.CPUThreshold <= 0 || overload.CPUThreshold > 1 || overload.MemoryThreshold <= 0 || overload.MemoryThreshold > 1 {
+			return fmt.Errorf("overload thresholds must be in (0, 1]")
+		}
+		if overload.HealthIntervalMultiplier < 1 {
+			return fmt.Errorf("overload health_interval_multiplier must be at least 1")
+		}
+	}
+
+	// Validate maintenance config
+	if _, err := ipaccess.ParseNetworks(config.Maintenance.BypassIPs); err != nil {
+		return fmt.Errorf("maintenance bypass_ips: %w", err)
+	}
+
+	// Validate client address restrictions
+	if _, err := ipaccess.ParseNetworks(config.Server.TrustedProxies); err != nil {
+		return fmt.Errorf("server trusted_proxies: %w", err)
+	}
+	if _, err := ipaccess.New(config.IPAccess); err != nil {
+		return fmt.Errorf("ip_access %w", err)
+	}
+	if err := validateCORS(config.CORS); err != nil {
+		return err
+	}
+	if config.GeoIP.Enabled && config.GeoIP.Database == "" {
+		return fmt.Errorf("geoip database is required when enabled")
+	}
+	if config.Maintenance.RetryAfter < 0 {
+		return fmt.Errorf("maintenance retry_after must not be negative")
+	}
+	if config.Maintenance.Body != "" && config.Maintenance.ContentType == "" {
+		return fmt.Errorf("maintenance content_type is required with a body")
+	}
+
+	// Validate watchdog config
+	if watchdog := config.Watchdog; watchdog.Enabled {
+		if watchdog.Interval <= 0 || watchdog.DumpInterval < 0 {
+			return fmt.Errorf("watchdog interval must be positive and dump_interval not negative")
+		}
+		if watchdog.MaxGoroutines < 0 || watchdog.MaxOpenFiles < 0 || watchdog.MaxConnections < 0 || watchdog.MaxUpstreamConnections < 0 {
+			return fmt.Errorf("watchdog thresholds must not be negative")
+		}
+	}
+
+	// Validate cache config
+	if config.Cache.Enabled {
+		if config.Cache.MaxEntries < 1 {
+			return fmt.Errorf("cache max_entries must be at least 1")
+		}
+		if config.Cache.MaxBodySize < 1 {
+			return fmt.Errorf("cache max_body_size must be positive")
+		}
+		warmup := config.Cache.Warmup
+		if warmup.Concurrency < 1 {
+			return fmt.Errorf("cache warmup concurrency must be at least 1")
+		}
+		if warmup.Interval < 0 {
+			return fmt.Errorf("cache warmup interval must not be negative")
+		}
+		for _, at := range warmup.At {
+			if _, _, err := models.ParseDailyTime(at); err != nil {
+				return fmt.Errorf("invalid cache warmup time %q, expected HH:MM", at)
+			}
+		}
+		for _, path := range warmup.Paths {
+			if !strings.HasPrefix(path, "/") {
+				return fmt.Errorf("cache warmup path must start with /: %s", path)
+			}
+		}
+	}
+
+	// Validate etcd config
+	if config.Etcd.Enabled {
+		if len(config.Etcd.Endpoints) == 0 {
+			return fmt.Errorf("etcd is enabled but no endpoints are configured")
+		}
+		if !strings.HasPrefix(config.Etcd.Prefix, "/") {
+			return fmt.Errorf("etcd prefix must start with /")
+		}
+	}
+
+	if config.ConfigHistory.Size < 1 {
+		return fmt.Errorf("config_history size must be at least 1")
+	}
+
+	// Validate remote config
+	if config.RemoteConfig.Enabled {
+		if config.RemoteConfig.Key == "" {
+			return fmt.Errorf("remote config is enabled but no key is configured")
+		}
+		switch config.RemoteConfig.Provider {
+		case models.RemoteConfigEtcd:
+			if len(config.Etcd.Endpoints) == 0 {
+				return fmt.Errorf("remote config from etcd needs etcd endpoints")
+			}
+		case models.RemoteConfigConsul:
+			if config.RemoteConfig.Consul.Address == "" {
+				return fmt.Errorf("remote config from consul needs a consul address")
+			}
+		default:
+			return fmt.Errorf("invalid remote config provider: %s", config.RemoteConfig.Provider)
+		}
+	}
+
+	// Validate session config
+	switch config.Session.Store {
+	case models.SessionStoreMemory, models.SessionStoreCookie, models.SessionStoreRedis:
+	default:
+		return fmt.Errorf("invalid session store: %s", config.Session.Store)
+	}
+	if config.Session.CookieName == "" {
+		return fmt.Errorf("session cookie name must not be empty")
+	}
+	if config.Session.TTL <= 0 {
+		return fmt.Errorf("session ttl must be positive")
+	}
+	if config.Session.MaxPerUser < 0 {
+		return fmt.Errorf("session max_per_user must not be negative")
+	}
+	if config.Session.MaxPerUser > 0 && config.Session.Store == models.SessionStoreCookie {
+		return fmt.Errorf("session max_per_user needs the memory or redis store")
+	}
+	switch strings.ToLower(config.Session.SameSite) {
+	case "lax", "strict", "none":
+	default:
+		return fmt.Errorf("invalid session same_site: %s", config.Session.SameSite)
+	}
+
+	// RFC 8305 bounds the connection attempt delay to 10ms-2s
+	if dial := config.UpstreamDial; dial.ConnectTimeout <= 0 || (dial.HappyEyeballs && (dial.AttemptDelay < 10*time.Millisecond || dial.AttemptDelay > 2*time.Second)) {
+		return fmt.Errorf("upstream_dial needs a positive connect_timeout and an attempt_delay between 10ms and 2s")
+	}
+
+	if config.SessionAuth.Enabled && (config.SessionAuth.RefreshAfter < 0 || config.SessionAuth.RefreshAfter >= config.Session.TTL) {
+		return fmt.Errorf("session_auth refresh_after must be at least 0 and shorter than the session ttl")
+	}
+
+	// Validate OIDC config
+	if config.OIDC.Enabled {
+		if config.OIDC.Issuer == "" || config.OIDC.ClientID == "" || config.OIDC.RedirectURL == "" {
+			return fmt.Errorf("oidc requires issuer, client_id and redirect_url")
+		}
+		if !strings.HasSuffix(config.OIDC.RedirectURL, "/oidc/callback") {
+			return fmt.Errorf("oidc redirect_url must point at the gateway's /oidc/callback")
 		}
-		if service.URL == "" {
-			return fmt.Errorf("service %s has empty URL", name)
+	}
+
+	// Validate registration config
+	if config.Registration.Enabled {
+		if config.Registration.Token == "" {
+			return fmt.Errorf("registration token is required when registration is enabled")
 		}
-		if service.Timeout <= 0 {
-			return fmt.Errorf("service %s has invalid timeout", name)
+		if config.Registration.DefaultTTL <= 0 || config.Registration.MaxTTL < config.Registration.DefaultTTL {
+			return fmt.Errorf("registration ttl must be positive and default_ttl <= max_ttl")
 		}
 	}
 
 	// Validate routes (skip if no routes configured)
 	if len(config.Routes) > 0 {
 		for i, route := range config.Routes {
-			if route.Path == "" {
-				return fmt.Errorf("route %d has empty path", i)
+			if err := ValidateRoute(fmt.Sprintf("route %d", i), route, config); err != nil {
+				return err
 			}
-			if route.ServiceName == "" {
-				return fmt.Errorf("route %d has empty service name", i)
+		}
+	}
+
+	return nil
+}
+
+// ValidateRoute checks route against config, naming it label in errors.
+func ValidateRoute(label string, route models.RouteConfig, config *models.GatewayConfig) error {
+	if route.Path == "" {
+		return fmt.Errorf("%s has empty path", label)
+	}
+	if route.IsMigration() {
+		if strings.HasSuffix(route.Path, "/*") != strings.HasSuffix(route.MigrateTo, "/*") {
+			return fmt.Errorf("%s must use /* on both path and migrate_to or on neither", label)
+		}
+		switch route.MigrationMode {
+		case "", models.MigrationRedirect, models.MigrationRewrite:
+		default:
+			return fmt.Errorf("%s has unsupported migration_mode: %s", label, route.MigrationMode)
+		}
+		return nil
+	}
+	switch route.AuthMode {
+	case "", models.AuthModeRequired, models.AuthModeOptional, models.AuthModeNone:
+	default:
+		return fmt.Errorf("%s has unsupported auth_mode: %s", label, route.AuthMode)
+	}
+	if route.AuthRequired && route.AuthMode != "" && route.AuthMode != models.AuthModeRequired {
+		return fmt.Errorf("%s cannot combine auth_required with auth_mode %s", label, route.AuthMode)
+	}
+	if _, exists := config.AuthProviders[route.AuthProvider]; route.AuthProvider != "" && !exists {
+		return fmt.Errorf("%s references non-existent auth provider: %s", label, route.AuthProvider)
+	}
+	if route.Timeout < 0 {
+		return fmt.Errorf("%s has negative timeout", label)
+	}
+	if route.IPAccess != nil {
+		if _, err := ipaccess.New(*route.IPAccess); err != nil {
+			return fmt.Errorf("%s ip_access %w", label, err)
+		}
+	}
+	if route.CORS != nil {
+		if err := validateCORS(route.CORS.Apply(config.CORS)); err != nil {
+			return fmt.Errorf("%s %w", label, err)
+		}
+	}
+	if route.GeoAccess != nil {
+		if !config.GeoIP.Enabled {
+			return fmt.Errorf("%s geo_access needs geoip enabled", label)
+		}
+		if err := validateGeoAccess(route.GeoAccess); err != nil {
+			return fmt.Errorf("%s %w", label, err)
+		}
+	}
+	if route.SLO != nil {
+		if err := validateSLO(route.SLO); err != nil {
+			return fmt.Errorf("%s: %w", label, err)
+		}
+	}
+	if policy := route.CookiePolicy; policy != nil {
+		switch strings.ToLower(policy.SameSite) {
+		case "", "lax", "strict", "none":
+		default:
+			return fmt.Errorf("%s has invalid cookie_policy same_site: %s", label, policy.SameSite)
+		}
+		if policy.Secure != nil && !*policy.Secure && strings.EqualFold(policy.SameSite, "none") {
+			return fmt.Errorf("%s cookie_policy cannot use same_site none without secure", label)
+		}
+		if policy.HostOnly && policy.Domain != "" {
+			return fmt.Errorf("%s cookie_policy cannot set both domain and host_only", label)
+		}
+	}
+	if route.ClientCert != nil {
+		if err := validateClientCert(route.ClientCert, config.Server.TLS); err != nil {
+			return fmt.Errorf("%s: %w", label, err)
+		}
+	}
+	if route.Signature != nil {
+		if err := validateSignature(route.Signature); err != nil {
+			return fmt.Errorf("%s: %w", label, err)
+		}
+	}
+	if ws := route.WebSocket; ws != nil && (ws.MaxConnections < 0 || ws.MaxConnectionsPerClient < 0 || ws.MaxMessageSize < 0 || ws.IdleTimeout < 0) {
+		return fmt.Errorf("%s websocket limits must not be negative", label)
+	}
+	if route.MaxWait < 0 {
+		return fmt.Errorf("%s max_wait must not be negative", label)
+	}
+	if route.Composite != nil && route.Canary != nil {
+		return fmt.Errorf("%s cannot combine composite and canary", label)
+	}
+	if route.RateLimit != nil {
+		if err := validateRouteRateLimit(route); err != nil {
+			return fmt.Errorf("%s: %w", label, err)
+		}
+	}
+	if err := validateCost(route, config); err != nil {
+		return fmt.Errorf("%s: %w", label, err)
+	}
+	if route.Composite != nil {
+		if route.CircuitBreaker != nil || route.Fallback != nil {
+			return fmt.Errorf("%s: circuit_breaker and fallback are not supported on composite routes", label)
+		}
+		if err := validateComposite(route, config.Services); err != nil {
+			return fmt.Errorf("%s: %w", label, err)
+		}
+		return nil
+	}
+	if route.ServiceName == "" {
+		return fmt.Errorf("%s has empty service name", label)
+	}
+	if route.CacheTTL < 0 {
+		return fmt.Errorf("%s has negative cache_ttl", label)
+	}
+	service, exists := config.Services[route.ServiceName]
+	if !exists {
+		return fmt.Errorf("%s references non-existent service: %s", label, route.ServiceName)
+	}
+	if service.StrictRoutes {
+		if err := validateStrictRoute(route); err != nil {
+			return fmt.Errorf("%s to strict service %s: %w", label, route.ServiceName, err)
+		}
+	}
+	if route.Canary != nil {
+		if err := validateCanary(route, config.Services); err != nil {
+			return fmt.Errorf("%s: %w", label, err)
+		}
+	}
+	if route.CircuitBreaker != nil {
+		if err := validateCircuitBreakerOverride(route.CircuitBreaker, service.CircuitBreaker.Apply(config.CircuitBreaker)); err != nil {
+			return fmt.Errorf("%s: %w", label, err)
+		}
+	}
+	if route.Fallback != nil {
+		if err := validateFallback(route, config.Services); err != nil {
+			return fmt.Errorf("%s: %w", label, err)
+		}
+	}
+	return nil
+}
+
+// ValidateService checks the settings of the service name, with breaker
+// the gateway-wide circuit breaker settings it may override.
+func ValidateService(name string, service models.ServiceConfig, breaker models.CircuitBreakerSettings) error {
+	if service.Name == "" {
+		return fmt.Errorf("service %s has empty name", name)
+	}
+	if service.URL == "" {
+		return fmt.Errorf("service %s has empty URL", name)
+	}
+	if service.Timeout <= 0 {
+		return fmt.Errorf("service %s has invalid timeout", name)
+	}
+	if service.HealthInterval != 0 && service.HealthInterval < time.Second {
+		return fmt.Errorf("service %s health_interval must be at least 1s", name)
+	}
+	switch strings.ToUpper(service.HealthMethod) {
+	case "", "GET", "HEAD", "POST", "OPTIONS":
+	default:
+		return fmt.Errorf("service %s has unsupported health_method: %s", name, service.HealthMethod)
+	}
+	if service.PrewarmConnections < 0 {
+		return fmt.Errorf("service %s prewarm_connections must not be negative", name)
+	}
+	if service.LatencySLA < 0 {
+		return fmt.Errorf("service %s latency_sla must not be negative", name)
+	}
+	if service.MaxInFlight < 0 || service.QueueTimeout < 0 {
+		return fmt.Errorf("service %s max_in_flight and queue_timeout must not be negative", name)
+	}
+	switch service.ValidationErrors {
+	case "", models.ValidationErrorsPassthrough, models.ValidationErrorsNormalize:
+	default:
+		return fmt.Errorf("service %s has unsupported validation_errors mode: %s", name, service.ValidationErrors)
+	}
+	switch service.IPPreference {
+	case "", models.IPPreferenceAuto, models.IPPreferenceIPv4, models.IPPreferenceIPv6:
+	default:
+		return fmt.Errorf("service %s has unsupported ip_preference: %s", name, service.IPPreference)
+	}
+	for _, code := range service.HealthExpectedStatus {
+		if code < 100 || code > 599 {
+			return fmt.Errorf("service %s has invalid health_expected_status: %d", name, code)
+		}
+	}
+	if service.CircuitBreaker != nil {
+		if err := validateCircuitBreakerOverride(service.CircuitBreaker, breaker); err != nil {
+			return fmt.Errorf("service %s: %w", name, err)
+		}
+	}
+	if retry := service.Retry; retry != nil {
+		if retry.MaxRetries < 0 || retry.Backoff < 0 {
+			return fmt.Errorf("service %s retry max_retries and backoff must not be negative", name)
+		}
+		for _, code := range retry.RetryOn {
+			if code < 400 || code > 599 {
+				return fmt.Errorf("service %s has invalid retry_on status: %d", name, code)
 			}
-			if _, exists := config.Services[route.ServiceName]; !exists {
-				return fmt.Errorf("route %d references non-existent service: %s", i, route.ServiceName)
+		}
+	}
+	if service.SLO != nil {
+		if err := validateSLO(service.SLO); err != nil {
+			return fmt.Errorf("service %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func validatePlans(config models.PlansConfig) error {
+	if len(config.Tiers) == 0 {
+		return fmt.Errorf("plans need at least one tier")
+	}
+	if _, exists := config.Tiers[config.Default]; config.Default != "" && !exists {
+		return fmt.Errorf("plans default references non-existent tier: %s", config.Default)
+	}
+	for name, tier := range config.Tiers {
+		if limit := tier.RateLimit; limit != nil && (limit.Requests < 1 || limit.Window <= 0 || limit.Burst < 1) {
+			return fmt.Errorf("plan %s rate_limit needs positive requests, window and burst", name)
+		}
+		if tier.Daily < 0 || tier.Monthly < 0 {
+			return fmt.Errorf("plan %s allowances must not be negative", name)
+		}
+	}
+	names := make(map[string]bool, len(config.APIKeys))
+	for i, key := range config.APIKeys {
+		if key.Name == "" || names[key.Name] {
+			return fmt.Errorf("plan api key %d needs a unique name", i)
+		}
+		names[key.Name] = true
+		if _, exists := config.Tiers[key.Plan]; !exists {
+			return fmt.Errorf("plan api key %s references non-existent tier: %s", key.Name, key.Plan)
+		}
+		if _, err := secrets.Resolve(key.KeyRef); err != nil {
+			return fmt.Errorf("plan api key %s: %w", key.Name, err)
+		}
+	}
+	return nil
+}
+
+// validateCost checks that a route's cost fits in the buckets it is taken
+// from; a request costing more than a bucket holds could never pass.
+func validateCost(route models.RouteConfig, config *models.GatewayConfig) error {
+	if route.Cost < 0 {
+		return fmt.Errorf("cost must not be negative")
+	}
+	if config.RateLimit.Enabled && route.TokenCost() > config.RateLimit.Burst {
+		return fmt.Errorf("cost %d exceeds the rate_limit burst of %d", route.Cost, config.RateLimit.Burst)
+	}
+	if config.Plans.Enabled {
+		for name, tier := range config.Plans.Tiers {
+			if tier.RateLimit != nil && route.TokenCost() > tier.RateLimit.Burst {
+				return fmt.Errorf("cost %d exceeds the burst of plan %s", route.Cost, name)
 			}
 		}
 	}
+	return nil
+}
 
+// validateCircuitBreaker checks the settings a circuit ends up with.
+func validateCircuitBreaker(settings models.CircuitBreakerSettings) error {
+	if settings.FailureThreshold <= 0 || settings.FailureThreshold > 1 {
+		return fmt.Errorf("circuit breaker failure threshold must be above 0 and at most 1")
+	}
+	if settings.MaxRequests < 1 || settings.Timeout <= 0 {
+		return fmt.Errorf("circuit breaker needs positive max_requests and timeout")
+	}
+	if settings.SlowCallDuration < 0 {
+		return fmt.Errorf("circuit breaker slow_call_duration must not be negative")
+	}
+	switch settings.Strategy {
+	case models.CircuitFixedWindow:
+	case models.CircuitSlidingWindow:
+		if settings.Interval <= 0 {
+			return fmt.Errorf("circuit breaker sliding_window strategy needs a positive interval")
+		}
+	default:
+		return fmt.Errorf("unsupported circuit breaker strategy: %s", settings.Strategy)
+	}
+	return nil
+}
+
+// validateCircuitBreakerOverride checks a service's or route's circuit
+// breaker overrides, and the settings they make of those inherited.
+func validateCircuitBreakerOverride(override *models.CircuitBreakerOverride, inherited models.CircuitBreakerSettings) error {
+	if override.Interval < 0 || override.Timeout < 0 || override.SlowCallDuration < 0 {
+		return fmt.Errorf("circuit_breaker durations must not be negative")
+	}
+	if override.FailureThreshold < 0 || override.FailureThreshold > 1 {
+		return fmt.Errorf("circuit_breaker failure_threshold must be between 0 and 1")
+	}
+	if !inherited.Enabled {
+		return nil
+	}
+	if err := validateCircuitBreaker(override.Apply(inherited)); err != nil {
+		return fmt.Errorf("circuit_breaker: %w", err)
+	}
+	return nil
+}
+
+// validateSLO checks a route's or a service's objectives.
+func validateSLO(slo *models.SLOConfig) error {
+	if slo.Availability == 0 && slo.Latency == 0 {
+		return fmt.Errorf("slo needs an availability or a latency objective")
+	}
+	if slo.Availability < 0 || slo.Availability >= 1 || slo.LatencyTarget < 0 || slo.LatencyTarget >= 1 {
+		return fmt.Errorf("slo availability and latency_target must be between 0 and 1, exclusive")
+	}
+	if slo.Latency < 0 || (slo.LatencyTarget > 0 && slo.Latency == 0) {
+		return fmt.Errorf("slo latency_target needs a positive latency")
+	}
+	if slo.Window < 0 || slo.Window > models.MaxSLOWindow {
+		return fmt.Errorf("slo window must be at most %s", models.MaxSLOWindow)
+	}
+	return nil
+}
+
+// validateACME checks the settings of automatic certificates.
+func validateACME(acme models.ACMEConfig, server models.ServerConfig) error {
+	if len(acme.Domains) == 0 {
+		return fmt.Errorf("server tls acme needs domains")
+	}
+	for _, domain := range acme.Domains {
+		if domain == "" || strings.ContainsAny(domain, "*/: ") {
+			return fmt.Errorf("server tls acme domain %q must be a host name without wildcards", domain)
+		}
+	}
+	if acme.CacheDir == "" {
+		return fmt.Errorf("server tls acme needs a cache_dir")
+	}
+	if acme.DirectoryURL != "" {
+		if parsed, err := url.Parse(acme.DirectoryURL); err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			return fmt.Errorf("server tls acme directory_url %q is not a URL", acme.DirectoryURL)
+		}
+	}
+	if acme.HTTPPort < 0 || acme.HTTPPort > 65535 {
+		return fmt.Errorf("invalid server tls acme http_port: %d", acme.HTTPPort)
+	}
+	if acme.HTTPPort == server.Port {
+		return fmt.Errorf("server tls acme http_port must differ from the server port")
+	}
+	if acme.RenewBefore < 0 {
+		return fmt.Errorf("server tls acme renew_before must not be negative")
+	}
+	if server.Workers > 1 {
+		return fmt.Errorf("server tls acme does not support several workers")
+	}
+	return nil
+}
+
+// validateCORS checks a CORS policy, the gateway's or a route's with its
+// overrides applied.
+func validateCORS(cors models.CORSConfig) error {
+	if !cors.Enabled {
+		return nil
+	}
+	for _, origin := range cors.AllowedOrigins {
+		if origin == "*" {
+			continue
+		}
+		if !strings.Contains(origin, "://") || strings.HasSuffix(origin, "/") {
+			return fmt.Errorf("cors allowed_origins entry %q must be a scheme and host, such as https://shop.example.com", origin)
+		}
+		if _, err := path.Match(origin, ""); err != nil {
+			return fmt.Errorf("cors allowed_origins entry %q is not a valid pattern", origin)
+		}
+	}
+	if cors.AllowCredentials && cors.AnyOrigin() {
+		return fmt.Errorf("cors allow_credentials needs allowed_origins listed, not *")
+	}
+	if cors.MaxAge < 0 {
+		return fmt.Errorf("cors max_age must not be negative")
+	}
+	return nil
+}
+
+// validateGeoAccess checks a route's country codes.
+func validateGeoAccess(geo *models.GeoAccessConfig) error {
+	for _, list := range []struct {
+		name  string
+		codes []string
+	}{{"allow", geo.Allow}, {"deny", geo.Deny}} {
+		for _, code := range list.codes {
+			if len(code) != 2 || strings.Trim(strings.ToUpper(code), "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+				return fmt.Errorf("geo_access %s entry %q is not a two-letter country code", list.name, code)
+			}
+		}
+	}
+	if len(geo.Allow) == 0 && len(geo.Deny) == 0 {
+		return fmt.Errorf("geo_access needs allow or deny")
+	}
+	return nil
+}
+
+// validateFallback checks what a route answers while its circuit is open.
+func validateFallback(route models.RouteConfig, services map[string]models.ServiceConfig) error {
+	fallback := route.Fallback
+	switch fallback.Mode {
+	case models.FallbackStatic:
+		if fallback.Status != 0 && (fallback.Status < 200 || fallback.Status > 599) {
+			return fmt.Errorf("fallback has invalid status: %d", fallback.Status)
+		}
+		if !json.Valid([]byte(fallback.Body)) {
+			return fmt.Errorf("static fallback body must be JSON")
+		}
+	case models.FallbackLastGood:
+		if fallback.MaxAge < 0 {
+			return fmt.Errorf("fallback max_age must not be negative")
+		}
+	case models.FallbackService:
+		if _, exists := services[fallback.ServiceName]; !exists {
+			return fmt.Errorf("fallback references non-existent service: %s", fallback.ServiceName)
+		}
+		if fallback.ServiceName == route.ServiceName {
+			return fmt.Errorf("fallback service must differ from the route's")
+		}
+	default:
+		return fmt.Errorf("unsupported fallback mode: %s", fallback.Mode)
+	}
+	return nil
+}
+
+// validateStrictRoute checks that a route of a strict service names exactly
+// what it forwards.
+func validateStrictRoute(route models.RouteConfig) error {
+	if route.Method == "" || route.Method == "*" {
+		return fmt.Errorf("needs a method")
+	}
+	if route.IsWildcard() {
+		return fmt.Errorf("cannot use a /* path")
+	}
+	if route.StripPrefix && strings.Contains(route.Path, "/:") {
+		return fmt.Errorf("cannot combine strip_prefix with path parameters")
+	}
+	return nil
+}
+
+func validateRouteRateLimit(route models.RouteConfig) error {
+	limit := route.RateLimit
+	if limit.Requests <= 0 || limit.Window <= 0 {
+		return fmt.Errorf("rate_limit needs positive requests and window")
+	}
+	if limit.Burst < 0 {
+		return fmt.Errorf("rate_limit burst must not be negative")
+	}
+	adaptive := limit.Adaptive
+	if adaptive == nil {
+		return nil
+	}
+	if route.Composite != nil {
+		return fmt.Errorf("adaptive rate_limit is not supported on composite routes")
+	}
+	if adaptive.MaxP99Latency <= 0 && adaptive.MaxErrorRate <= 0 {
+		return fmt.Errorf("adaptive rate_limit needs max_p99_latency or max_error_rate")
+	}
+	if adaptive.MaxP99Latency < 0 {
+		return fmt.Errorf("adaptive rate_limit max_p99_latency must not be negative")
+	}
+	if adaptive.MaxErrorRate < 0 || adaptive.MaxErrorRate >= 1 {
+		return fmt.Errorf("adaptive rate_limit max_error_rate must be between 0 and 1")
+	}
+	if adaptive.Window < 0 || adaptive.MinRequests < 0 || adaptive.Increase < 0 {
+		return fmt.Errorf("adaptive rate_limit window, min_requests and increase must not be negative")
+	}
+	if adaptive.Decrease < 0 || adaptive.Decrease >= 1 {
+		return fmt.Errorf("adaptive rate_limit decrease must be between 0 and 1")
+	}
+	if adaptive.MinFactor < 0 || adaptive.MinFactor > 1 {
+		return fmt.Errorf("adaptive rate_limit min_factor must be between 0 and 1")
+	}
+	return nil
+}
+
+func validateCanary(route models.RouteConfig, services map[string]models.ServiceConfig) error {
+	canary := route.Canary
+	if canary.ServiceName == "" {
+		return fmt.Errorf("canary has empty service name")
+	}
+	if canary.ServiceName == route.ServiceName {
+		return fmt.Errorf("canary service must differ from the route's service")
+	}
+	if _, exists := services[canary.ServiceName]; !exists {
+		return fmt.Errorf("canary references non-existent service: %s", canary.ServiceName)
+	}
+	if canary.Weight < 0 || canary.Weight > 100 {
+		return fmt.Errorf("canary weight must be between 0 and 100")
+	}
+	if rollback := canary.Rollback; rollback != nil {
+		if rollback.MaxErrorRateIncrease < 0 || rollback.MaxErrorRateIncrease >= 1 {
+			return fmt.Errorf("canary rollback max_error_rate_increase must be between 0 and 1")
+		}
+		if rollback.Window < 0 || rollback.MinRequests < 0 {
+			return fmt.Errorf("canary rollback window and min_requests must not be negative")
+		}
+	}
+	return nil
+}
+
+func validateClientCert(policy *models.ClientCertPolicy, tls models.TLSConfig) error {
+	if tls.ClientCAFile == "" {
+		return fmt.Errorf("client_cert needs server tls with a client_ca_file")
+	}
+	if len(policy.Identities) == 0 {
+		return fmt.Errorf("client_cert needs at least one identity")
+	}
+	for j, identity := range policy.Identities {
+		if (identity.SAN == "") == (identity.Fingerprint == "") {
+			return fmt.Errorf("client_cert identity %d must match by either san or fingerprint", j)
+		}
+		if identity.UserID == "" {
+			return fmt.Errorf("client_cert identity %d has no user_id", j)
+		}
+	}
+	return nil
+}
+
+func validateComposite(route models.RouteConfig, services map[string]models.ServiceConfig) error {
+	switch route.Composite.Split {
+	case "", models.SplitEqual, models.SplitWeighted, models.SplitRolling:
+	default:
+		return fmt.Errorf("unsupported composite split: %s", route.Composite.Split)
+	}
+	switch route.Composite.Merge {
+	case "", models.MergeNested, models.MergeConcat, models.MergeFirstSuccess:
+	default:
+		return fmt.Errorf("unsupported composite merge: %s", route.Composite.Merge)
+	}
+	if len(route.Composite.Legs) == 0 {
+		return fmt.Errorf("composite route has no legs")
+	}
+
+	names := make(map[string]bool)
+	for _, leg := range route.Composite.Legs {
+		if leg.Name == "" || names[leg.Name] {
+			return fmt.Errorf("composite legs need unique names")
+		}
+		names[leg.Name] = true
+		if _, exists := services[leg.ServiceName]; !exists {
+			return fmt.Errorf("leg %s references non-existent service: %s", leg.Name, leg.ServiceName)
+		}
+		if !strings.HasPrefix(leg.Path, "/") {
+			return fmt.Errorf("leg %s path must start with /", leg.Name)
+		}
+		if leg.Weight < 0 || leg.Timeout < 0 {
+			return fmt.Errorf("leg %s has negative weight or timeout", leg.Name)
+		}
+	}
+	return nil
+}
+
+func validateSignature(signature *models.SignatureConfig) error {
+	if signature.Header == "" {
+		return fmt.Errorf("signature header must not be empty")
+	}
+	if signature.Hash() == nil {
+		return fmt.Errorf("unsupported signature algorithm: %s", signature.Algorithm)
+	}
+	switch signature.Scheme {
+	case "", models.SignaturePlain, models.SignatureStripe:
+	default:
+		return fmt.Errorf("unsupported signature scheme: %s", signature.Scheme)
+	}
+	switch signature.Encoding {
+	case "", "hex", "base64":
+	default:
+		return fmt.Errorf("unsupported signature encoding: %s", signature.Encoding)
+	}
+	if _, err := secrets.Resolve(signature.SecretRef); err != nil {
+		return fmt.Errorf("signature secret: %w", err)
+	}
 	return nil
 }
 
@@ -246,4 +1560,4 @@ func getEnv(key, defaultValue string) string {
 		return value
 	}
 	return defaultValue
-}
\ No newline at end of file
+}
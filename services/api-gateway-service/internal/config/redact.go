@@ -0,0 +1,155 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RedactedValue replaces a secret in a configuration dump.
+const RedactedValue = "[REDACTED]"
+
+// sensitiveKeys are substrings of map keys, such as header names, whose
+// values are redacted.
+var sensitiveKeys = []string{"authorization", "cookie", "token", "secret", "password", "key"}
+
+// Redact returns config as it is encoded to JSON, with durations written
+// like "30s", for operators to check what the gateway runs with. Secrets
+// are masked: fields that JSON leaves out, such as tokens and passwords,
+// are listed under their YAML name as [REDACTED] when set, as are values
+// of map entries with credential-like keys, such as an Authorization
+// header. Passwords in URLs are masked as well, and so are the values at
+// secretPaths, such as redis.password, which were read from secret
+// references.
+func Redact(config interface{}, secretPaths ...string) interface{} {
+	secret := make(map[string]bool, len(secretPaths))
+	for _, path := range secretPaths {
+		secret[path] = true
+	}
+	return redactValue(reflect.ValueOf(config), "", secret)
+}
+
+func redactValue(v reflect.Value, path string, secret map[string]bool) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	if secret[path] && v.Kind() == reflect.String {
+		return RedactedValue
+	}
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		return time.Duration(v.Int()).String()
+	}
+	if _, ok := v.Interface().(json.Marshaler); ok && v.Kind() != reflect.Pointer {
+		return v.Interface()
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return redactValue(v.Elem(), path, secret)
+	case reflect.Struct:
+		return redactStruct(v, path, secret)
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		result := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key := fmt.Sprint(iter.Key().Interface())
+			if iter.Value().Kind() == reflect.String && sensitiveKey(key) && iter.Value().Len() > 0 {
+				result[key] = RedactedValue
+				continue
+			}
+			result[key] = redactValue(iter.Value(), joinPath(path, key), secret)
+		}
+		return result
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		result := make([]interface{}, v.Len())
+		for i := range result {
+			result[i] = redactValue(v.Index(i), joinPath(path, strconv.Itoa(i)), secret)
+		}
+		return result
+	case reflect.String:
+		return redactURL(v.String())
+	default:
+		return v.Interface()
+	}
+}
+
+// redactStruct encodes a struct field by field under their JSON names,
+// honoring omitempty. Fields JSON leaves out are secrets and appear
+// masked under their YAML name when set.
+func redactStruct(v reflect.Value, path string, secret map[string]bool) map[string]interface{} {
+	result := make(map[string]interface{}, v.NumField())
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		value := v.Field(i)
+
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			yamlName, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+			if yamlName != "" && yamlName != "-" && !value.IsZero() {
+				result[yamlName] = RedactedValue
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if strings.Contains(options, "omitempty") && isEmpty(value) {
+			continue
+		}
+		result[name] = redactValue(value, joinPath(path, configName(field)), secret)
+	}
+	return result
+}
+
+// isEmpty is encoding/json's notion of an empty value for omitempty.
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Pointer, reflect.Interface:
+		return v.IsNil()
+	case reflect.Struct:
+		return false
+	default:
+		return v.IsZero()
+	}
+}
+
+func sensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactURL masks the password of a URL with credentials, as xxxxx, and
+// returns other strings as they are.
+func redactURL(value string) string {
+	if !strings.Contains(value, "@") || !strings.Contains(value, "://") {
+		return value
+	}
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return value
+	}
+	return parsed.Redacted()
+}
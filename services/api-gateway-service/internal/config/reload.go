@@ -0,0 +1,258 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gateway/internal/models"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Stages at which a reload can fail.
+const (
+	ReloadLoad       = "load"
+	ReloadValidation = "validation"
+)
+
+// ReloadStats tracks the reloads of one configuration source, so a gateway
+// that keeps failing to pick up changes shows up in its metrics.
+type ReloadStats struct {
+	source string
+
+	mutex              sync.Mutex
+	attempts           int64
+	successes          int64
+	loadFailures       int64
+	validationFailures int64
+	lastSuccess        time.Time
+	lastFailure        time.Time
+	lastError          string
+	lastDiff           int
+	changes            int64
+	hash               string
+	listener           func(models.HealthEvent)
+}
+
+func NewReloadStats(source string) *ReloadStats {
+	return &ReloadStats{source: source}
+}
+
+func (s *ReloadStats) Source() string {
+	return s.source
+}
+
+// Activate sets the hash of the configuration in force without counting a
+// reload, for the initial load.
+func (s *ReloadStats) Activate(hash string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.hash = hash
+}
+
+// OnReload registers a listener called with a config_reloaded or
+// config_reload_failed event after every reload. It replaces any previous
+// listener.
+func (s *ReloadStats) OnReload(listener func(models.HealthEvent)) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.listener = listener
+}
+
+// Applied records a reload that took effect with diff changed entries. A
+// non-nil rejected means some entries were left out for failing
+// validation, which also counts as a validation failure.
+func (s *ReloadStats) Applied(diff int, hash string, rejected error) {
+	s.mutex.Lock()
+	now := time.Now()
+	s.attempts++
+	s.successes++
+	s.lastSuccess = now
+	s.lastDiff = diff
+	s.changes += int64(diff)
+	s.hash = hash
+	if rejected != nil {
+		s.validationFailures++
+		s.lastFailure = now
+		s.lastError = rejected.Error()
+	}
+	listener := s.listener
+	s.mutex.Unlock()
+
+	if listener != nil {
+		reason := fmt.Sprintf("%d change(s) from %s", diff, s.source)
+		if rejected != nil {
+			reason += ", some entries rejected: " + rejected.Error()
+		}
+		listener(models.HealthEvent{Type: models.EventConfigReloaded, Reason: reason, Timestamp: now})
+	}
+}
+
+// Failed records a reload that was not applied at all.
+func (s *ReloadStats) Failed(stage string, err error) {
+	s.mutex.Lock()
+	now := time.Now()
+	s.attempts++
+	if stage == ReloadValidation {
+		s.validationFailures++
+	} else {
+		s.loadFailures++
+	}
+	s.lastFailure = now
+	s.lastError = err.Error()
+	listener := s.listener
+	s.mutex.Unlock()
+
+	if listener != nil {
+		reason := fmt.Sprintf("%s %s: %v", s.source, stage, err)
+		listener(models.HealthEvent{Type: models.EventConfigReloadFailed, Reason: reason, Timestamp: now})
+	}
+}
+
+func (s *ReloadStats) Stats() map[string]interface{} {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	stats := map[string]interface{}{
+		"source":              s.source,
+		"attempts":            s.attempts,
+		"successes":           s.successes,
+		"load_failures":       s.loadFailures,
+		"validation_failures": s.validationFailures,
+		"last_diff_size":      s.lastDiff,
+		"changes":             s.changes,
+		"config_hash":         s.hash,
+	}
+	if !s.lastSuccess.IsZero() {
+		stats["last_success"] = s.lastSuccess.Format(time.RFC3339)
+	}
+	if !s.lastFailure.IsZero() {
+		stats["last_failure"] = s.lastFailure.Format(time.RFC3339)
+		stats["last_error"] = s.lastError
+	}
+	return stats
+}
+
+// LastSuccess and LastFailure are zero until the first of each.
+func (s *ReloadStats) LastSuccess() time.Time {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.lastSuccess
+}
+
+func (s *ReloadStats) LastFailure() time.Time {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.lastFailure
+}
+
+// Hash identifies a configuration by its content. Secrets are included, so
+// rotating one changes the hash, but only a digest is exposed.
+func Hash(value interface{}) string {
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Diff lists what changed between two configurations. Services are matched
+// by name and routes by method and path.
+type Diff struct {
+	ServicesAdded   []string `json:"services_added,omitempty"`
+	ServicesRemoved []string `json:"services_removed,omitempty"`
+	ServicesChanged []string `json:"services_changed,omitempty"`
+	RoutesAdded     []string `json:"routes_added,omitempty"`
+	RoutesRemoved   []string `json:"routes_removed,omitempty"`
+	RoutesChanged   []string `json:"routes_changed,omitempty"`
+	// Sections are the other top-level settings that changed
+	Sections []string `json:"sections,omitempty"`
+}
+
+// Size is the number of changed entries.
+func (d Diff) Size() int {
+	return len(d.ServicesAdded) + len(d.ServicesRemoved) + len(d.ServicesChanged) +
+		len(d.RoutesAdded) + len(d.RoutesRemoved) + len(d.RoutesChanged) + len(d.Sections)
+}
+
+func DiffConfigs(previous, current *models.GatewayConfig) Diff {
+	diff := DiffRouting(previous.Services, current.Services, previous.Routes, current.Routes)
+
+	previousValue, currentValue := reflect.ValueOf(*previous), reflect.ValueOf(*current)
+	configType := previousValue.Type()
+	for i := 0; i < configType.NumField(); i++ {
+		field := configType.Field(i)
+		if field.Name == "Services" || field.Name == "Routes" {
+			continue
+		}
+		if !reflect.DeepEqual(previousValue.Field(i).Interface(), currentValue.Field(i).Interface()) {
+			diff.Sections = append(diff.Sections, yamlName(field))
+		}
+	}
+	return diff
+}
+
+// DiffRouting compares two sets of services and routes.
+func DiffRouting(previousServices, currentServices map[string]models.ServiceConfig, previousRoutes, currentRoutes []models.RouteConfig) Diff {
+	var diff Diff
+	for name, service := range currentServices {
+		if old, exists := previousServices[name]; !exists {
+			diff.ServicesAdded = append(diff.ServicesAdded, name)
+		} else if !reflect.DeepEqual(old, service) {
+			diff.ServicesChanged = append(diff.ServicesChanged, name)
+		}
+	}
+	for name := range previousServices {
+		if _, exists := currentServices[name]; !exists {
+			diff.ServicesRemoved = append(diff.ServicesRemoved, name)
+		}
+	}
+
+	previousByKey := make(map[string]models.RouteConfig, len(previousRoutes))
+	for _, route := range previousRoutes {
+		previousByKey[routeKey(route)] = route
+	}
+	currentByKey := make(map[string]bool, len(currentRoutes))
+	for _, route := range currentRoutes {
+		key := routeKey(route)
+		currentByKey[key] = true
+		if old, exists := previousByKey[key]; !exists {
+			diff.RoutesAdded = append(diff.RoutesAdded, key)
+		} else if !reflect.DeepEqual(old, route) {
+			diff.RoutesChanged = append(diff.RoutesChanged, key)
+		}
+	}
+	for _, route := range previousRoutes {
+		if key := routeKey(route); !currentByKey[key] {
+			diff.RoutesRemoved = append(diff.RoutesRemoved, key)
+		}
+	}
+
+	for _, names := range [][]string{diff.ServicesAdded, diff.ServicesRemoved, diff.ServicesChanged} {
+		sort.Strings(names)
+	}
+	return diff
+}
+
+func routeKey(route models.RouteConfig) string {
+	method := route.Method
+	if method == "" {
+		method = "*"
+	}
+	return method + " " + route.Path
+}
+
+func yamlName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
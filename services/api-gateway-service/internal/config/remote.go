@@ -0,0 +1,67 @@
+package config
+
+import (
+	"context"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RemoteSource serves a configuration document kept under a key in a
+// key-value store such as etcd or Consul KV.
+type RemoteSource interface {
+	// Fetch returns the document, or nil when the key does not exist.
+	Fetch(ctx context.Context) ([]byte, error)
+	// Watch calls changed whenever the document changes, or may have
+	// changed while the store was unreachable, until ctx is cancelled.
+	Watch(ctx context.Context, changed func())
+	// String names the store and key, for logs.
+	String() string
+}
+
+// UseRemote merges the document of source over the config files, now and
+// on every reload. When source cannot be read, or its document makes the
+// configuration invalid, the configuration stays as it was and the error
+// is returned; later reloads try source again.
+func (m *Manager) UseRemote(source RemoteSource) error {
+	m.reloading.Lock()
+	defer m.reloading.Unlock()
+
+	m.mutex.Lock()
+	m.remote = source
+	m.mutex.Unlock()
+
+	config, secretPaths, err := m.load(m.viper.ConfigFileUsed())
+	if err != nil {
+		return err
+	}
+	if err := validate(config); err != nil {
+		return err
+	}
+	return m.activate(config, secretPaths)
+}
+
+// Remote is the source set with UseRemote, or nil.
+func (m *Manager) Remote() RemoteSource {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.remote
+}
+
+// fetchRemote reads the settings of the remote document. A missing key
+// has none.
+func fetchRemote(source RemoteSource) (map[string]interface{}, error) {
+	data, err := source.Fetch(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read remote config from %s: %w", source, err)
+	}
+	settings := make(map[string]interface{})
+	if err := yaml.Unmarshal(data, &settings); err != nil {
+		return nil, fmt.Errorf("failed to parse remote config from %s: %w", source, err)
+	}
+	if violations := CheckSchema(source.String(), data); len(violations) > 0 {
+		return nil, &SchemaError{Violations: violations}
+	}
+	delete(settings, includeKey)
+	return settings, nil
+}
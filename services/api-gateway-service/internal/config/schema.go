@@ -0,0 +1,327 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gateway/internal/models"
+
+	"gopkg.in/yaml.v3"
+)
+
+// durationPattern matches the durations time.ParseDuration accepts.
+const durationPattern = `^-?([0-9]+(\.[0-9]*)?(ns|us|µs|ms|s|m|h))+$|^0$`
+
+var durationExpression = regexp.MustCompile(durationPattern)
+
+// Schema is the subset of JSON Schema the gateway's configuration needs.
+// AdditionalProperties is false for settings, which have a fixed set of
+// keys, or the *Schema of every value of a map.
+type Schema struct {
+	SchemaURI            string             `json:"$schema,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Description          string             `json:"description,omitempty"`
+}
+
+// GatewaySchema generates the JSON Schema of config files from
+// models.GatewayConfig, for editors and CI to check files against.
+func GatewaySchema() *Schema {
+	schema := schemaOf(reflect.TypeOf(models.GatewayConfig{}), map[reflect.Type]bool{})
+	schema.SchemaURI = "https://json-schema.org/draft/2020-12/schema"
+	schema.Title = "API gateway configuration"
+	schema.Properties[includeKey] = &Schema{
+		Description: "A file path or a list of them, relative to this file, merged into it",
+	}
+	return schema
+}
+
+func schemaOf(t reflect.Type, visiting map[reflect.Type]bool) *Schema {
+	switch t {
+	case reflect.TypeOf(time.Duration(0)):
+		return &Schema{Type: "string", Pattern: durationPattern, Description: `A duration such as "500ms", "30s" or "1h30m"`}
+	case reflect.TypeOf(time.Time{}):
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaOf(t.Elem(), visiting)
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: schemaOf(t.Elem(), visiting)}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: schemaOf(t.Elem(), visiting)}
+	case reflect.Struct:
+		// Recursive settings are described down to where they repeat
+		if visiting[t] {
+			return &Schema{Type: "object"}
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		schema := &Schema{Type: "object", Properties: make(map[string]*Schema), AdditionalProperties: false}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() || field.Tag.Get("yaml") == "-" {
+				continue
+			}
+			schema.Properties[configName(field)] = schemaOf(field.Type, visiting)
+		}
+		return schema
+	default:
+		return &Schema{}
+	}
+}
+
+// Violation is a place where a config file does not match the schema.
+type Violation struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s: %s", v.File, v.Line, v.Column, v.Path, v.Message)
+}
+
+// SchemaError lists every violation of the schema in the files of a
+// config, so all of them can be fixed in one go.
+type SchemaError struct {
+	Violations []Violation
+}
+
+func (e *SchemaError) Error() string {
+	lines := make([]string, len(e.Violations))
+	for i, violation := range e.Violations {
+		lines[i] = "  " + violation.String()
+	}
+	return fmt.Sprintf("config does not match the schema (%d problem(s)):\n%s", len(e.Violations), strings.Join(lines, "\n"))
+}
+
+// CheckSchema checks the config document data, named file in messages,
+// against the schema. It is meant for single files: settings are all
+// optional, so each of several files merged into a config can be checked
+// on its own, with the lines of that file.
+func CheckSchema(file string, data []byte) []Violation {
+	return checkDocument(file, data, GatewaySchema())
+}
+
+// CheckServiceSchema checks a service definition, such as one sent to the
+// admin API, against the schema of services.
+func CheckServiceSchema(file string, data []byte) []Violation {
+	return checkDocument(file, data, GatewaySchema().Properties["services"].AdditionalProperties.(*Schema))
+}
+
+// CheckRouteSchema checks a route definition against the schema of routes.
+func CheckRouteSchema(file string, data []byte) []Violation {
+	return checkDocument(file, data, GatewaySchema().Properties["routes"].Items)
+}
+
+func checkDocument(file string, data []byte, schema *Schema) []Violation {
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil || len(document.Content) == 0 {
+		// Parse errors are reported when the file is read
+		return nil
+	}
+	var violations []Violation
+	checkNode(document.Content[0], schema, "", func(node *yaml.Node, path, message string) {
+		if path == "" {
+			path = "(document)"
+		}
+		violations = append(violations, Violation{File: file, Line: node.Line, Column: node.Column, Path: path, Message: message})
+	})
+	return violations
+}
+
+func checkNode(node *yaml.Node, schema *Schema, path string, report func(node *yaml.Node, path, message string)) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return
+	}
+
+	switch schema.Type {
+	case "object":
+		if node.Kind != yaml.MappingNode {
+			report(node, path, "expected a mapping of settings, got "+describeNode(node))
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Value == "<<" {
+				continue
+			}
+			child := joinPath(path, key.Value)
+			if property, known := lookupProperty(schema.Properties, key.Value); known {
+				checkNode(value, property, child, report)
+				continue
+			}
+			switch additional := schema.AdditionalProperties.(type) {
+			case *Schema:
+				checkNode(value, additional, child, report)
+			case bool:
+				if !additional {
+					report(key, child, unknownSetting(key.Value, schema.Properties))
+				}
+			}
+		}
+	case "array":
+		// A single value becomes a one-element list, as the loader allows
+		if node.Kind == yaml.ScalarNode && schema.Items.Type != "object" && schema.Items.Type != "array" {
+			return
+		}
+		if node.Kind != yaml.SequenceNode {
+			report(node, path, "expected a list, got "+describeNode(node))
+			return
+		}
+		for i, item := range node.Content {
+			checkNode(item, schema.Items, joinPath(path, strconv.Itoa(i)), report)
+		}
+	case "string", "integer", "number", "boolean":
+		if node.Kind != yaml.ScalarNode {
+			report(node, path, fmt.Sprintf("expected %s, got %s", describeType(schema), describeNode(node)))
+			return
+		}
+		if message := checkScalar(node, schema); message != "" {
+			report(node, path, message)
+		}
+	}
+}
+
+// checkScalar is as lenient as the loader, which converts a quoted "8000"
+// to a port number and a number to a string.
+func checkScalar(node *yaml.Node, schema *Schema) string {
+	value := node.Value
+	switch schema.Type {
+	case "integer":
+		if _, err := strconv.ParseInt(value, 0, 64); err != nil {
+			return fmt.Sprintf("expected a whole number, got %q", value)
+		}
+	case "number":
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Sprintf("expected a number, got %q", value)
+		}
+	case "boolean":
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Sprintf("expected true or false, got %q", value)
+		}
+	case "string":
+		if schema.Pattern == durationPattern && !durationExpression.MatchString(value) {
+			if _, err := strconv.ParseFloat(value, 64); err == nil {
+				return fmt.Sprintf("duration %s needs a unit, like \"%ss\"", value, value)
+			}
+			return fmt.Sprintf("expected a duration like \"30s\" or \"1m30s\", got %q", value)
+		}
+	}
+	return ""
+}
+
+// lookupProperty finds a setting by name; the loader ignores case.
+func lookupProperty(properties map[string]*Schema, name string) (*Schema, bool) {
+	if property, exists := properties[name]; exists {
+		return property, true
+	}
+	for key, property := range properties {
+		if strings.EqualFold(key, name) {
+			return property, true
+		}
+	}
+	return nil, false
+}
+
+func unknownSetting(name string, properties map[string]*Schema) string {
+	best, bestDistance := "", 3
+	for key := range properties {
+		if distance := editDistance(strings.ToLower(name), key); distance < bestDistance || (distance == bestDistance && key < best) {
+			best, bestDistance = key, distance
+		}
+	}
+	if best != "" {
+		return fmt.Sprintf("unknown setting %q, did you mean %q?", name, best)
+	}
+	known := make([]string, 0, len(properties))
+	for key := range properties {
+		known = append(known, key)
+	}
+	sort.Strings(known)
+	return fmt.Sprintf("unknown setting %q; expected one of %s", name, strings.Join(known, ", "))
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min3(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+func describeType(schema *Schema) string {
+	switch schema.Type {
+	case "integer":
+		return "a whole number"
+	case "number":
+		return "a number"
+	case "boolean":
+		return "true or false"
+	default:
+		if schema.Pattern == durationPattern {
+			return "a duration"
+		}
+		return "a string"
+	}
+}
+
+func describeNode(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "a mapping"
+	case yaml.SequenceNode:
+		return "a list"
+	default:
+		return fmt.Sprintf("%q", node.Value)
+	}
+}
@@ -0,0 +1,103 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gateway/internal/models"
+	"gateway/internal/secrets"
+)
+
+// resolveSecrets replaces the secret references among config's values
+// with the secrets, and returns the paths of the values replaced, such as
+// redis.password. Fields ending in _ref hold references that are resolved
+// on use and stay as they are. The Vault settings are resolved first, as
+// other references may point into Vault.
+func resolveSecrets(config *models.GatewayConfig) ([]string, error) {
+	var paths []string
+	if err := resolveValue(reflect.ValueOf(&config.Secrets.Vault).Elem(), "secrets.vault", nil, &paths); err != nil {
+		return nil, err
+	}
+	vault, err := secrets.NewVault(config.Secrets.Vault)
+	if err != nil {
+		return nil, err
+	}
+	if err := resolveValue(reflect.ValueOf(config).Elem(), "", vault, &paths); err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
+
+func resolveValue(v reflect.Value, path string, vault *secrets.Vault, paths *[]string) error {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return nil
+		}
+		return resolveValue(v.Elem(), path, vault, paths)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name := configName(field)
+			if strings.HasSuffix(name, "_ref") {
+				continue
+			}
+			if err := resolveValue(v.Field(i), joinPath(path, name), vault, paths); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := resolveValue(v.Index(i), joinPath(path, strconv.Itoa(i)), vault, paths); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			// Map values cannot be set in place
+			value := reflect.New(iter.Value().Type()).Elem()
+			value.Set(iter.Value())
+			resolved := len(*paths)
+			if err := resolveValue(value, joinPath(path, fmt.Sprint(iter.Key().Interface())), vault, paths); err != nil {
+				return err
+			}
+			if len(*paths) > resolved {
+				v.SetMapIndex(iter.Key(), value)
+			}
+		}
+	case reflect.String:
+		if !secrets.IsReference(v.String()) {
+			return nil
+		}
+		secret, err := secrets.ResolveWith(v.String(), vault)
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s: %w", path, err)
+		}
+		v.SetString(secret)
+		*paths = append(*paths, path)
+	}
+	return nil
+}
+
+// configName is the name of a field in the config file.
+func configName(field reflect.StructField) string {
+	for _, tag := range []string{"yaml", "mapstructure"} {
+		if name, _, _ := strings.Cut(field.Tag.Get(tag), ","); name != "" && name != "-" {
+			return name
+		}
+	}
+	return strings.ToLower(field.Name)
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
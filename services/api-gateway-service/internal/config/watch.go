@@ -0,0 +1,94 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// WatchFiles calls reload whenever the content of the files listed by
+// files changes, until ctx is cancelled. files is asked again after every
+// change, as a reload may include other files. The files' directories are
+// watched rather than the files themselves, so editors that replace a file
+// and Kubernetes ConfigMap updates, which swap a symlink, are noticed too.
+// Events are collected for debounce before the files are read, and writes
+// that leave the content as it was do not trigger a reload.
+func WatchFiles(ctx context.Context, files func() []string, debounce time.Duration, reload func()) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to watch config files: %w", err)
+	}
+	defer watcher.Close()
+
+	watched := make(map[string]bool)
+	watch := func(paths []string) error {
+		for _, path := range paths {
+			dir := filepath.Dir(path)
+			if watched[dir] {
+				continue
+			}
+			if err := watcher.Add(dir); err != nil {
+				return fmt.Errorf("failed to watch config files: %w", err)
+			}
+			watched[dir] = true
+		}
+		return nil
+	}
+	paths := files()
+	if err := watch(paths); err != nil {
+		return err
+	}
+
+	// A file that cannot be read yet is picked up once it can
+	last := readFiles(paths)
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case _, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			timer.Reset(debounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			// Such as a queue overflow: look at the files in case a change
+			// was among the events lost
+			log.Printf("Config file watch error: %v", err)
+			timer.Reset(debounce)
+		case <-timer.C:
+			content := readFiles(paths)
+			if bytes.Equal(content, last) {
+				continue
+			}
+			reload()
+			paths = files()
+			if err := watch(paths); err != nil {
+				log.Printf("Config file watch error: %v", err)
+			}
+			last = readFiles(paths)
+		}
+	}
+}
+
+// readFiles concatenates the names and contents of paths, to tell whether
+// any of them changed. Files that cannot be read count as empty.
+func readFiles(paths []string) []byte {
+	var content bytes.Buffer
+	for _, path := range paths {
+		data, _ := os.ReadFile(path)
+		fmt.Fprintf(&content, "%s %d\n", path, len(data))
+		content.Write(data)
+	}
+	return content.Bytes()
+}
@@ -0,0 +1,114 @@
+package devauth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"gateway/internal/auth"
+)
+
+// Issuer is the embedded stand-in for the auth service enabled with
+// --dev-auth. It signs HS256 JWTs with a process secret and verifies them
+// in-process, so the gateway runs auth_required routes without the real
+// auth service. Dev tokens prove nothing about who holds them.
+type Issuer struct {
+	secret []byte
+}
+
+// Claims are the claims of a dev token. Extra claims become token data,
+// as the auth service reports them.
+type Claims struct {
+	Issuer  string            `json:"iss"`
+	Subject string            `json:"sub"`
+	Email   string            `json:"email,omitempty"`
+	Name    string            `json:"name,omitempty"`
+	Scope   string            `json:"scope,omitempty"`
+	Expiry  int64             `json:"exp"`
+	Extra   map[string]string `json:"ext,omitempty"`
+}
+
+const issuerName = "gateway-dev-auth"
+
+var header = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// New returns an issuer signing with secret, or with a random secret when
+// it is empty, in which case tokens do not survive a restart.
+func New(secret []byte) (*Issuer, error) {
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+	}
+	return &Issuer{secret: secret}, nil
+}
+
+// Issue signs a token for claims valid for ttl.
+func (i *Issuer) Issue(claims Claims, ttl time.Duration) (string, time.Time, error) {
+	expiresAt := time.Now().Add(ttl)
+	claims.Issuer = issuerName
+	claims.Expiry = expiresAt.Unix()
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + i.sign(unsigned), expiresAt, nil
+}
+
+// Verify implements auth.Verifier for dev tokens.
+func (i *Issuer) Verify(ctx context.Context, token string) (*auth.Identity, error) {
+	claims, err := i.Parse(token)
+	if err != nil {
+		return nil, err
+	}
+
+	identity := &auth.Identity{
+		UserID: claims.Subject,
+		Email:  claims.Email,
+		Name:   claims.Name,
+		Scopes: strings.Fields(claims.Scope),
+		Claims: make(map[string]string, len(claims.Extra)),
+	}
+	for name, value := range claims.Extra {
+		identity.Claims[name] = value
+	}
+	return identity, nil
+}
+
+// Parse checks a dev token's signature and expiry and returns its claims.
+func (i *Issuer) Parse(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != header {
+		return nil, auth.ErrInvalidCredentials
+	}
+	if !hmac.Equal([]byte(parts[2]), []byte(i.sign(parts[0]+"."+parts[1]))) {
+		return nil, auth.ErrInvalidCredentials
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, auth.ErrInvalidCredentials
+	}
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, auth.ErrInvalidCredentials
+	}
+	if claims.Issuer != issuerName || claims.Subject == "" || time.Now().Unix() >= claims.Expiry {
+		return nil, auth.ErrInvalidCredentials
+	}
+	return &claims, nil
+}
+
+func (i *Issuer) sign(unsigned string) string {
+	mac := hmac.New(sha256.New, i.secret)
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
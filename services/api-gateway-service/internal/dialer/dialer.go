@@ -0,0 +1,242 @@
+package dialer
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gateway/internal/models"
+)
+
+// Resolver looks up the addresses of an upstream host. net.DefaultResolver
+// satisfies it.
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+type preferenceKey struct{}
+
+// WithPreference makes dials under ctx try pref's address family first.
+func WithPreference(ctx context.Context, pref models.IPPreference) context.Context {
+	return context.WithValue(ctx, preferenceKey{}, pref)
+}
+
+func preferenceFrom(ctx context.Context) models.IPPreference {
+	pref, _ := ctx.Value(preferenceKey{}).(models.IPPreference)
+	return pref
+}
+
+// Dialer opens upstream connections, racing the addresses of dual-stack
+// hosts as described in RFC 8305 so a family that blackholes SYNs costs
+// one attempt delay rather than a full connect timeout.
+type Dialer struct {
+	config   models.DialConfig
+	resolver Resolver
+	dialer   *net.Dialer
+
+	dials     int64
+	fallbacks int64
+	failures  int64
+	// open counts the open connections to each upstream address
+	open sync.Map
+}
+
+// New builds a Dialer. A nil resolver means net.DefaultResolver.
+func New(config models.DialConfig, resolver Resolver) *Dialer {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	return &Dialer{
+		config:   config,
+		resolver: resolver,
+		dialer: &net.Dialer{
+			Timeout:   config.ConnectTimeout,
+			KeepAlive: config.KeepAlive,
+		},
+	}
+}
+
+// DialContext has the signature of http.Transport.DialContext.
+func (d *Dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	atomic.AddInt64(&d.dials, 1)
+	conn, err := d.dial(ctx, network, address)
+	if err != nil {
+		atomic.AddInt64(&d.failures, 1)
+		return nil, err
+	}
+	value, _ := d.open.LoadOrStore(address, new(int64))
+	open := value.(*int64)
+	atomic.AddInt64(open, 1)
+	return &countedConn{Conn: conn, open: open}, nil
+}
+
+type countedConn struct {
+	net.Conn
+	open   *int64
+	closed int32
+}
+
+func (c *countedConn) Close() error {
+	if atomic.CompareAndSwapInt32(&c.closed, 0, 1) {
+		atomic.AddInt64(c.open, -1)
+	}
+	return c.Conn.Close()
+}
+
+// Connections returns the number of open connections to each upstream
+// address that has any.
+func (d *Dialer) Connections() map[string]int64 {
+	connections := make(map[string]int64)
+	d.open.Range(func(address, value interface{}) bool {
+		if open := atomic.LoadInt64(value.(*int64)); open > 0 {
+			connections[address.(string)] = open
+		}
+		return true
+	})
+	return connections
+}
+
+func (d *Dialer) dial(ctx context.Context, network, address string) (net.Conn, error) {
+	if !d.config.HappyEyeballs {
+		return d.dialer.DialContext(ctx, network, address)
+	}
+
+	host, port, err := net.SplitHostPort(address)
+	if err != nil || net.ParseIP(host) != nil {
+		return d.dialer.DialContext(ctx, network, address)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, d.config.ConnectTimeout)
+	defer cancel()
+
+	addrs, err := d.resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	addrs = sortAddrs(addrs, preferenceFrom(ctx))
+	if len(addrs) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	if len(addrs) == 1 {
+		return d.dialer.DialContext(ctx, network, net.JoinHostPort(addrs[0].String(), port))
+	}
+	return d.race(ctx, network, port, addrs)
+}
+
+type attempt struct {
+	conn  net.Conn
+	err   error
+	index int
+}
+
+// race starts an attempt per address, each one AttemptDelay after the
+// previous or as soon as the previous fails, and keeps the first
+// connection to complete.
+func (d *Dialer) race(ctx context.Context, network, port string, addrs []net.IPAddr) (net.Conn, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan attempt, len(addrs))
+	start := func(i int) {
+		go func() {
+			conn, err := d.dialer.DialContext(ctx, network, net.JoinHostPort(addrs[i].String(), port))
+			results <- attempt{conn: conn, err: err, index: i}
+		}()
+	}
+
+	next, pending := 1, 1
+	start(0)
+	delay := time.NewTimer(d.config.AttemptDelay)
+	defer delay.Stop()
+
+	var firstErr error
+	for pending > 0 {
+		select {
+		case <-delay.C:
+			if next < len(addrs) {
+				start(next)
+				next++
+				pending++
+				delay.Reset(d.config.AttemptDelay)
+			}
+		case result := <-results:
+			pending--
+			if result.err == nil {
+				if result.index > 0 {
+					atomic.AddInt64(&d.fallbacks, 1)
+				}
+				cancel()
+				go closeLosers(results, pending)
+				return result.conn, nil
+			}
+			if firstErr == nil {
+				firstErr = result.err
+			}
+			if next < len(addrs) {
+				if !delay.Stop() {
+					select {
+					case <-delay.C:
+					default:
+					}
+				}
+				start(next)
+				next++
+				pending++
+				delay.Reset(d.config.AttemptDelay)
+			}
+		}
+	}
+	if firstErr == nil {
+		firstErr = errors.New("no addresses to dial")
+	}
+	return nil, firstErr
+}
+
+// closeLosers drops connections that completed after the race was won.
+func closeLosers(results <-chan attempt, pending int) {
+	for ; pending > 0; pending-- {
+		if result := <-results; result.conn != nil {
+			result.conn.Close()
+		}
+	}
+}
+
+// sortAddrs interleaves the two families, starting with the preferred one.
+// Auto prefers IPv6, as RFC 8305 does.
+func sortAddrs(addrs []net.IPAddr, pref models.IPPreference) []net.IPAddr {
+	var v4, v6 []net.IPAddr
+	for _, addr := range addrs {
+		if addr.IP.To4() != nil {
+			v4 = append(v4, addr)
+		} else {
+			v6 = append(v6, addr)
+		}
+	}
+	first, second := v6, v4
+	if pref == models.IPPreferenceIPv4 {
+		first, second = v4, v6
+	}
+
+	sorted := make([]net.IPAddr, 0, len(addrs))
+	for i := 0; i < len(first) || i < len(second); i++ {
+		if i < len(first) {
+			sorted = append(sorted, first[i])
+		}
+		if i < len(second) {
+			sorted = append(sorted, second[i])
+		}
+	}
+	return sorted
+}
+
+func (d *Dialer) Stats() map[string]interface{} {
+	return map[string]interface{}{
+		"happy_eyeballs": d.config.HappyEyeballs,
+		"dials":          atomic.LoadInt64(&d.dials),
+		"fallbacks":      atomic.LoadInt64(&d.fallbacks),
+		"failures":       atomic.LoadInt64(&d.failures),
+	}
+}
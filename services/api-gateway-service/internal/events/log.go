@@ -0,0 +1,59 @@
+package events
+
+import (
+	"sync"
+
+	"gateway/internal/models"
+)
+
+// Log keeps the most recent health events in a fixed-size ring buffer.
+type Log struct {
+	events []models.HealthEvent
+	next   int
+	size   int
+	lastID int64
+	mutex  sync.RWMutex
+}
+
+func NewLog(capacity int) *Log {
+	return &Log{
+		events: make([]models.HealthEvent, capacity),
+	}
+}
+
+// Append stores an event, assigning it the next sequence ID.
+func (l *Log) Append(event models.HealthEvent) models.HealthEvent {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.lastID++
+	event.ID = l.lastID
+	l.events[l.next] = event
+	l.next = (l.next + 1) % len(l.events)
+	if l.size < len(l.events) {
+		l.size++
+	}
+	return event
+}
+
+// List returns events newer than afterID, oldest first, optionally limited
+// to one service and to the newest limit events.
+func (l *Log) List(afterID int64, service string, limit int) []models.HealthEvent {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	result := make([]models.HealthEvent, 0, l.size)
+	start := (l.next - l.size + len(l.events)) % len(l.events)
+	for i := 0; i < l.size; i++ {
+		event := l.events[(start+i)%len(l.events)]
+		if event.ID <= afterID || (service != "" && event.Service != service) {
+			continue
+		}
+		result = append(result, event)
+	}
+
+	if limit > 0 && len(result) > limit {
+		result = result[len(result)-limit:]
+	}
+	return result
+}
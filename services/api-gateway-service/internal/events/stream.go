@@ -0,0 +1,128 @@
+package events
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gateway/internal/models"
+)
+
+// subscriptionBuffer is how many events a subscriber may fall behind by
+// before it starts missing them.
+const subscriptionBuffer = 64
+
+// ErrTooManySubscribers is returned by Subscribe when the stream already
+// has as many subscribers as it allows.
+var ErrTooManySubscribers = errors.New("events: too many subscribers")
+
+// Stream fans gateway activity out to live subscribers, such as the ops
+// dashboard. Publishing never blocks: a subscriber that cannot keep up
+// misses events instead of holding up the gateway.
+type Stream struct {
+	subscribers    map[*Subscription]struct{}
+	maxSubscribers int
+	mutex          sync.RWMutex
+}
+
+// NewStream returns a stream that allows up to maxSubscribers subscribers
+// at once, or any number when maxSubscribers is 0.
+func NewStream(maxSubscribers int) *Stream {
+	return &Stream{
+		subscribers:    make(map[*Subscription]struct{}),
+		maxSubscribers: maxSubscribers,
+	}
+}
+
+// Subscription receives the published events of the requested types, or
+// of every type when none were requested.
+type Subscription struct {
+	stream  *Stream
+	events  chan models.HealthEvent
+	types   map[models.HealthEventType]bool
+	service string
+	missed  int64
+	once    sync.Once
+}
+
+// Subscribe starts receiving events of types, optionally only those about
+// service. The subscription must be closed when done with.
+func (s *Stream) Subscribe(types []models.HealthEventType, service string) (*Subscription, error) {
+	subscription := &Subscription{
+		stream:  s,
+		events:  make(chan models.HealthEvent, subscriptionBuffer),
+		service: service,
+	}
+	if len(types) > 0 {
+		subscription.types = make(map[models.HealthEventType]bool, len(types))
+		for _, eventType := range types {
+			subscription.types[eventType] = true
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.maxSubscribers > 0 && len(s.subscribers) >= s.maxSubscribers {
+		return nil, ErrTooManySubscribers
+	}
+	s.subscribers[subscription] = struct{}{}
+	return subscription, nil
+}
+
+// Publish hands event to every subscriber that wants it.
+func (s *Stream) Publish(event models.HealthEvent) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if len(s.subscribers) == 0 {
+		return
+	}
+
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	for subscription := range s.subscribers {
+		if !subscription.wants(event) {
+			continue
+		}
+		select {
+		case subscription.events <- event:
+		default:
+			atomic.AddInt64(&subscription.missed, 1)
+		}
+	}
+}
+
+// Subscribers is the number of open subscriptions.
+func (s *Stream) Subscribers() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return len(s.subscribers)
+}
+
+func (s *Subscription) wants(event models.HealthEvent) bool {
+	if s.types != nil && !s.types[event.Type] {
+		return false
+	}
+	return s.service == "" || event.Service == s.service
+}
+
+// Events delivers the subscription's events until it is closed.
+func (s *Subscription) Events() <-chan models.HealthEvent {
+	return s.events
+}
+
+// Missed returns how many events were dropped because the subscriber fell
+// behind, and resets the count.
+func (s *Subscription) Missed() int64 {
+	return atomic.SwapInt64(&s.missed, 0)
+}
+
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.stream.mutex.Lock()
+		defer s.stream.mutex.Unlock()
+		delete(s.stream.subscribers, s)
+		close(s.events)
+	})
+}
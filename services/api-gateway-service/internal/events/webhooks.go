@@ -0,0 +1,105 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"gateway/internal/models"
+)
+
+// Notifier delivers health events to webhooks from a single background
+// worker, so slow receivers never hold up health checking.
+type Notifier struct {
+	webhooks []models.WebhookConfig
+	queue    chan models.HealthEvent
+	client   *http.Client
+}
+
+func NewNotifier(webhooks []models.WebhookConfig) *Notifier {
+	return &Notifier{
+		webhooks: webhooks,
+		queue:    make(chan models.HealthEvent, 256),
+		client:   &http.Client{},
+	}
+}
+
+// Notify queues an event for delivery, dropping it if the queue is full.
+func (n *Notifier) Notify(event models.HealthEvent) {
+	select {
+	case n.queue <- event:
+	default:
+		log.Printf("Webhook queue full, dropping event for %s", event.Service)
+	}
+}
+
+// Run delivers queued events until ctx is cancelled.
+func (n *Notifier) Run(ctx context.Context) {
+	for {
+		select {
+		case event := <-n.queue:
+			for _, webhook := range n.webhooks {
+				if err := n.deliver(ctx, webhook, event); err != nil {
+					log.Printf("Webhook delivery to %s failed: %v", webhook.URL, err)
+				}
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (n *Notifier) deliver(ctx context.Context, webhook models.WebhookConfig, event models.HealthEvent) error {
+	payload, err := json.Marshal(webhookPayload(webhook.Format, event))
+	if err != nil {
+		return err
+	}
+
+	timeout := webhook.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range webhook.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func webhookPayload(format models.WebhookFormat, event models.HealthEvent) interface{} {
+	if format != models.WebhookSlack {
+		return event
+	}
+
+	icon := ":large_yellow_circle:"
+	switch {
+	case event.Type == models.EventSLABreached:
+		icon = ":snail:"
+	case event.Type == models.EventSLARecovered || event.To == models.ServiceHealthy:
+		icon = ":large_green_circle:"
+	case event.To == models.ServiceUnhealthy:
+		icon = ":red_circle:"
+	}
+	return map[string]string{"text": icon + " " + event.Summary()}
+}
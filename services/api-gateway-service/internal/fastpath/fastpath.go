@@ -0,0 +1,126 @@
+package fastpath
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"sort"
+	"time"
+)
+
+// Handler answers probe endpoints before any gin middleware runs and hands
+// every other request to next. Probes then skip auth, rate limiting,
+// logging and body processing, so their latency stays flat while the main
+// pipeline is under stress.
+type Handler struct {
+	next   http.Handler
+	routes map[string]http.HandlerFunc
+}
+
+func New(next http.Handler) *Handler {
+	return &Handler{
+		next:   next,
+		routes: make(map[string]http.HandlerFunc),
+	}
+}
+
+// Handle serves GET and HEAD requests for path with handler.
+func (h *Handler) Handle(path string, handler http.HandlerFunc) {
+	h.routes[path] = handler
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		if handler, exists := h.routes[r.URL.Path]; exists {
+			handler(w, r)
+			return
+		}
+	}
+	h.next.ServeHTTP(w, r)
+}
+
+// Health reports that the gateway process is up.
+func Health(version string, startedAt time.Time) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]string{
+			"status":    "healthy",
+			"timestamp": time.Now().Format(time.RFC3339),
+			"version":   version,
+			"uptime":    time.Since(startedAt).Round(time.Second).String(),
+		})
+	}
+}
+
+// Live is the liveness probe: answering at all is the signal.
+func Live(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]string{"status": "alive"})
+}
+
+// Metric is one sample in the Prometheus text format. Samples of one
+// metric must be adjacent.
+type Metric struct {
+	Name    string
+	Help    string
+	Labels  map[string]string
+	Value   float64
+	Counter bool
+}
+
+// Metrics serves the samples returned by gather in the Prometheus text
+// format. gather runs on every scrape and should only read counters.
+func Metrics(startedAt time.Time, gather func() []Metric) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		metrics := []Metric{
+			{Name: "gateway_uptime_seconds", Help: "Seconds since the gateway started.", Value: time.Since(startedAt).Seconds()},
+			{Name: "gateway_goroutines", Help: "Number of goroutines.", Value: float64(runtime.NumGoroutine())},
+		}
+		metrics = append(metrics, gather()...)
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		writeMetrics(w, metrics)
+	}
+}
+
+func writeMetrics(w io.Writer, metrics []Metric) {
+	described := make(map[string]bool)
+	for _, metric := range metrics {
+		if !described[metric.Name] {
+			kind := "gauge"
+			if metric.Counter {
+				kind = "counter"
+			}
+			fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", metric.Name, metric.Help, metric.Name, kind)
+			described[metric.Name] = true
+		}
+		fmt.Fprintf(w, "%s%s %g\n", metric.Name, formatLabels(metric.Labels), metric.Value)
+	}
+}
+
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	formatted := "{"
+	for i, name := range names {
+		if i > 0 {
+			formatted += ","
+		}
+		formatted += fmt.Sprintf("%s=%q", name, labels[name])
+	}
+	return formatted + "}"
+}
+
+func writeJSON(w http.ResponseWriter, body interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,81 @@
+package forensics
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"gateway/internal/models"
+)
+
+// DefaultCaptureDuration is how long a capture runs when no duration is
+// asked for.
+const DefaultCaptureDuration = 5 * time.Minute
+
+// Captures records every request to the routes an operator is capturing,
+// successful or not, until each capture expires.
+type Captures struct {
+	recorder *Recorder
+	routes   map[string]time.Time
+	mutex    sync.RWMutex
+}
+
+func NewCaptures(capacity int) *Captures {
+	return &Captures{
+		recorder: NewRecorder(capacity),
+		routes:   make(map[string]time.Time),
+	}
+}
+
+// Recorder holds the captured requests.
+func (c *Captures) Recorder() *Recorder {
+	return c.recorder
+}
+
+// Start captures requests to route until until, replacing any capture of
+// the route already running. It returns the capture it replaced.
+func (c *Captures) Start(route string, until time.Time) (models.CaptureSession, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	previous, running := c.routes[route]
+	c.routes[route] = until
+	return models.CaptureSession{Route: route, Until: previous}, running && time.Now().Before(previous)
+}
+
+// Stop ends the capture of route, reporting whether one was running.
+func (c *Captures) Stop(route string) (models.CaptureSession, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	until, running := c.routes[route]
+	delete(c.routes, route)
+	return models.CaptureSession{Route: route, Until: until}, running && time.Now().Before(until)
+}
+
+// Capturing reports whether requests to route are being captured.
+func (c *Captures) Capturing(route string) bool {
+	c.mutex.RLock()
+	until, running := c.routes[route]
+	c.mutex.RUnlock()
+	return running && time.Now().Before(until)
+}
+
+// Active returns the captures that have not expired, by route, and forgets
+// those that have.
+func (c *Captures) Active() []models.CaptureSession {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+	sessions := make([]models.CaptureSession, 0, len(c.routes))
+	for route, until := range c.routes {
+		if !now.Before(until) {
+			delete(c.routes, route)
+			continue
+		}
+		sessions = append(sessions, models.CaptureSession{Route: route, Until: until})
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].Route < sessions[j].Route })
+	return sessions
+}
@@ -0,0 +1,69 @@
+package forensics
+
+import (
+	"sync"
+
+	"gateway/internal/models"
+)
+
+// Recorder keeps the most recent failed requests in a fixed-size ring
+// buffer, so they can be inspected even when the log pipeline lags.
+type Recorder struct {
+	requests []models.RecordedRequest
+	next     int
+	size     int
+	lastID   int64
+	mutex    sync.RWMutex
+}
+
+func NewRecorder(capacity int) *Recorder {
+	return &Recorder{
+		requests: make([]models.RecordedRequest, capacity),
+	}
+}
+
+// Append stores a request, assigning it the next sequence ID.
+func (r *Recorder) Append(request models.RecordedRequest) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.lastID++
+	request.ID = r.lastID
+	r.requests[r.next] = request
+	r.next = (r.next + 1) % len(r.requests)
+	if r.size < len(r.requests) {
+		r.size++
+	}
+}
+
+// List returns recorded requests newer than afterID, newest first,
+// optionally limited to one status code and to limit entries.
+func (r *Recorder) List(afterID int64, status int, limit int) []models.RecordedRequest {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	result := make([]models.RecordedRequest, 0, r.size)
+	for i := 1; i <= r.size; i++ {
+		request := r.requests[(r.next-i+len(r.requests))%len(r.requests)]
+		if request.ID <= afterID || (status != 0 && request.Status != status) {
+			continue
+		}
+		result = append(result, request)
+		if limit > 0 && len(result) == limit {
+			break
+		}
+	}
+	return result
+}
+
+// Clear drops every recorded request and returns how many there were.
+func (r *Recorder) Clear() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	cleared := r.size
+	r.requests = make([]models.RecordedRequest, len(r.requests))
+	r.next = 0
+	r.size = 0
+	return cleared
+}
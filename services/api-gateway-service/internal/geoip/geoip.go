@@ -0,0 +1,164 @@
+// Package geoip tells which country client addresses are in, from a
+// MaxMind DB file such as GeoLite2-Country or GeoIP2-City.
+package geoip
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// CountryKey is the context key the client's country is stored under,
+// empty when it is unknown.
+const CountryKey = "geoip_country"
+
+// Unknown labels the requests of clients whose country is unknown, such
+// as those from private addresses.
+const Unknown = "unknown"
+
+// Locator finds the country of an address, as an ISO 3166-1 alpha-2 code.
+type Locator interface {
+	Country(ip net.IP) (string, bool)
+}
+
+// Database is a MaxMind DB file loaded in memory. Reload replaces it, so
+// the weekly updates geoipupdate downloads apply without a restart.
+type Database struct {
+	path   string
+	reader atomic.Pointer[maxminddb.Reader]
+}
+
+type record struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	RegisteredCountry struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"registered_country"`
+}
+
+// Open loads the database at path.
+func Open(path string) (*Database, error) {
+	database := &Database{path: path}
+	if err := database.Reload(); err != nil {
+		return nil, err
+	}
+	return database, nil
+}
+
+// Reload reads the database again. If it cannot be read, the current one
+// stays in use. The file is read whole rather than mapped, so lookups in
+// flight are not cut short by the swap.
+func (d *Database) Reload() error {
+	data, err := os.ReadFile(d.path)
+	if err != nil {
+		return fmt.Errorf("failed to read GeoIP database: %w", err)
+	}
+	reader, err := maxminddb.FromBytes(data)
+	if err != nil {
+		return fmt.Errorf("failed to open GeoIP database %s: %w", d.path, err)
+	}
+	d.reader.Store(reader)
+	return nil
+}
+
+// Files is the database file, to watch for updates.
+func (d *Database) Files() []string {
+	return []string{d.path}
+}
+
+// Type is the database type from its metadata, such as GeoLite2-Country.
+func (d *Database) Type() string {
+	return d.reader.Load().Metadata.DatabaseType
+}
+
+// Country returns the country ip is located in or, failing that, the one
+// its network is registered in.
+func (d *Database) Country(ip net.IP) (string, bool) {
+	if ip == nil {
+		return "", false
+	}
+	var found record
+	if err := d.reader.Load().Lookup(ip, &found); err != nil {
+		return "", false
+	}
+	if found.Country.ISOCode != "" {
+		return found.Country.ISOCode, true
+	}
+	return found.RegisteredCountry.ISOCode, found.RegisteredCountry.ISOCode != ""
+}
+
+// Counts tallies requests by the country of the client and their result.
+type Counts struct {
+	counts map[countKey]int64
+	mutex  sync.Mutex
+}
+
+type countKey struct {
+	country string
+	result  string
+}
+
+// Count is the number of requests from a country with a result: success,
+// client_error or server_error.
+type Count struct {
+	Country string `json:"country"`
+	Result  string `json:"result"`
+	Count   int64  `json:"count"`
+}
+
+func NewCounts() *Counts {
+	return &Counts{counts: make(map[countKey]int64)}
+}
+
+// Record counts a request from country answered with status.
+func (c *Counts) Record(country string, status int) {
+	if country == "" {
+		country = Unknown
+	}
+	result := "success"
+	switch {
+	case status >= 500:
+		result = "server_error"
+	case status >= 400:
+		result = "client_error"
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.counts[countKey{country: country, result: result}]++
+}
+
+// Counts returns the counts ordered by country, then result.
+func (c *Counts) Counts() []Count {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	counts := make([]Count, 0, len(c.counts))
+	for key, count := range c.counts {
+		counts = append(counts, Count{Country: key.country, Result: key.result, Count: count})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Country != counts[j].Country {
+			return counts[i].Country < counts[j].Country
+		}
+		return counts[i].Result < counts[j].Result
+	})
+	return counts
+}
+
+func (c *Counts) Stats() map[string]interface{} {
+	counts := c.Counts()
+	byCountry := make(map[string]int64)
+	for _, count := range counts {
+		byCountry[count.Country] += count.Count
+	}
+	return map[string]interface{}{
+		"by_country": byCountry,
+		"counts":     counts,
+	}
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"gateway/internal/audit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuditHandler exposes the audit log of changes made through the admin
+// endpoints. Records cannot be changed or removed through it.
+type AuditHandler struct {
+	log *audit.Log
+}
+
+func NewAuditHandler(auditLog *audit.Log) *AuditHandler {
+	return &AuditHandler{log: auditLog}
+}
+
+func (h *AuditHandler) Register(group *gin.RouterGroup) {
+	group.GET("", h.List)
+}
+
+// List returns records oldest first. ?after=<id> returns only newer
+// records, ?actor= filters by admin token name and ?limit= keeps the
+// newest N (default 100).
+func (h *AuditHandler) List(c *gin.Context) {
+	afterID, err := strconv.ParseInt(c.DefaultQuery("after", "0"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": "after must be a record id",
+		})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": "limit must be a positive integer",
+		})
+		return
+	}
+
+	result := h.log.List(afterID, c.Query("actor"), limit)
+	c.JSON(http.StatusOK, gin.H{
+		"records": result,
+		"total":   len(result),
+	})
+}
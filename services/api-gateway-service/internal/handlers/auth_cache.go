@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"net/http"
+
+	"gateway/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuthCacheHandler lets admins inspect and flush the token verification
+// cache, e.g. after a logout or a leaked token.
+type AuthCacheHandler struct {
+	cache *auth.CachingVerifier
+}
+
+type evictTokenRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+func NewAuthCacheHandler(cache *auth.CachingVerifier) *AuthCacheHandler {
+	return &AuthCacheHandler{cache: cache}
+}
+
+func (h *AuthCacheHandler) Register(group *gin.RouterGroup) {
+	group.GET("", h.Stats)
+	group.DELETE("", h.Flush)
+	group.POST("/evict", h.Evict)
+}
+
+func (h *AuthCacheHandler) Stats(c *gin.Context) {
+	c.JSON(http.StatusOK, h.cache.Stats())
+}
+
+// Flush empties the cache, or only drops the tokens of the user given in
+// ?user_id=.
+func (h *AuthCacheHandler) Flush(c *gin.Context) {
+	if userID := c.Query("user_id"); userID != "" {
+		c.JSON(http.StatusOK, gin.H{"flushed": h.cache.InvalidateUser(userID)})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"flushed": h.cache.Flush()})
+}
+
+// Evict drops a single token, given in the body so it stays out of logs.
+func (h *AuthCacheHandler) Evict(c *gin.Context) {
+	var req evictTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": "token is required",
+		})
+		return
+	}
+
+	evicted := 0
+	if h.cache.Invalidate(req.Token) {
+		evicted = 1
+	}
+	c.JSON(http.StatusOK, gin.H{"flushed": evicted})
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"gateway/internal/auth"
+	"gateway/internal/i18n"
+	"gateway/internal/session"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BFFHandler serves the session endpoints used by the SPA when the gateway
+// relays tokens on its behalf.
+type BFFHandler struct {
+	sessions *session.Manager
+	client   *auth.Client
+}
+
+type loginRequest struct {
+	Email    string `json:"email" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+func NewBFFHandler(sessions *session.Manager, client *auth.Client) *BFFHandler {
+	return &BFFHandler{
+		sessions: sessions,
+		client:   client,
+	}
+}
+
+func (h *BFFHandler) Register(group *gin.RouterGroup) {
+	group.POST("/login", h.Login)
+	group.POST("/logout", h.Logout)
+	group.GET("/session", h.Session)
+}
+
+func (h *BFFHandler) Login(c *gin.Context) {
+	var req loginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		i18n.Error(c, http.StatusBadRequest, "Invalid request", i18n.LoginInvalidRequest)
+		return
+	}
+
+	tokens, err := h.client.Login(c.Request.Context(), req.Email, req.Password)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidCredentials) {
+			i18n.Error(c, http.StatusUnauthorized, "Invalid credentials", i18n.LoginInvalid)
+			return
+		}
+		log.Printf("BFF login failed: %v", err)
+		i18n.Error(c, http.StatusBadGateway, "Auth service unavailable", i18n.LoginUnavailable)
+		return
+	}
+
+	sess, err := h.sessions.New()
+	if err != nil {
+		i18n.Error(c, http.StatusInternalServerError, "Session error", i18n.SessionCreateFailed)
+		return
+	}
+	sess.SetTokens(tokens.AccessToken, tokens.RefreshToken, tokens.ExpiresIn)
+
+	// The user ID lets admins find and end a user's sessions
+	if identity, err := h.client.Verify(c.Request.Context(), tokens.AccessToken); err == nil {
+		sess.UserID = identity.UserID
+	}
+
+	if err := h.sessions.Save(c.Writer, c.Request, sess); err != nil {
+		log.Printf("BFF failed to store session: %v", err)
+		i18n.Error(c, http.StatusInternalServerError, "Session error", i18n.SessionStoreFailed)
+		return
+	}
+
+	// Logging in past the per-user session limit ends the oldest sessions
+	if evicted, err := h.sessions.EnforceUserLimit(c.Request.Context(), sess); err != nil {
+		log.Printf("BFF failed to enforce session limit: %v", err)
+	} else if evicted > 0 {
+		log.Printf("BFF ended %d older session(s) of user %s", evicted, sess.UserID)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"authenticated": true,
+		"expires_at":    sess.ExpiresAt.Format(time.RFC3339),
+	})
+}
+
+func (h *BFFHandler) Logout(c *gin.Context) {
+	if sess, err := h.sessions.Load(c.Request); err == nil {
+		// Best effort: the local session is dropped even if the auth
+		// service cannot be reached.
+		if err := h.client.Logout(c.Request.Context(), sess.Get(session.ValueAccessToken), sess.Get(session.ValueRefreshToken)); err != nil {
+			log.Printf("BFF logout could not revoke tokens: %v", err)
+		}
+	}
+
+	if err := h.sessions.Destroy(c.Writer, c.Request); err != nil {
+		log.Printf("BFF failed to delete session: %v", err)
+	}
+	c.JSON(http.StatusOK, gin.H{"authenticated": false})
+}
+
+func (h *BFFHandler) Session(c *gin.Context) {
+	sess, err := h.sessions.Load(c.Request)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"authenticated": false})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"authenticated": true,
+		"expires_at":    sess.ExpiresAt.Format(time.RFC3339),
+	})
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"net/http"
+
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CanariesHandler shows how the canaries of weighted routes compare to
+// their stable services and resumes canaries that were rolled back.
+type CanariesHandler struct {
+	registry *registry.ServiceRegistry
+}
+
+func NewCanariesHandler(serviceRegistry *registry.ServiceRegistry) *CanariesHandler {
+	return &CanariesHandler{registry: serviceRegistry}
+}
+
+func (h *CanariesHandler) Register(group *gin.RouterGroup) {
+	group.GET("", h.List)
+	group.POST("/resume", h.Resume)
+}
+
+func (h *CanariesHandler) List(c *gin.Context) {
+	canaries := h.registry.Canaries()
+	c.JSON(http.StatusOK, gin.H{
+		"canaries": canaries,
+		"total":    len(canaries),
+	})
+}
+
+// Resume sends traffic to the canary of ?route= again, once whatever made
+// it fail has been fixed.
+func (h *CanariesHandler) Resume(c *gin.Context) {
+	route := c.Query("route")
+	if !h.registry.ResumeCanary(route) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Not found",
+			"message": "no canary on route " + route,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"route": route, "resumed": true})
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"gateway/internal/audit"
+	"gateway/internal/forensics"
+	"gateway/internal/models"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CapturesHandler starts and stops captures of a route's requests and
+// lists what they recorded, for debugging a route without turning on
+// logging for everything.
+type CapturesHandler struct {
+	captures    *forensics.Captures
+	registry    *registry.ServiceRegistry
+	maxDuration time.Duration
+}
+
+func NewCapturesHandler(captures *forensics.Captures, serviceRegistry *registry.ServiceRegistry, maxDuration time.Duration) *CapturesHandler {
+	return &CapturesHandler{captures: captures, registry: serviceRegistry, maxDuration: maxDuration}
+}
+
+func (h *CapturesHandler) Register(group *gin.RouterGroup) {
+	group.GET("", h.List)
+	group.POST("/start", h.Start)
+	group.POST("/stop", h.Stop)
+}
+
+// List returns the running captures and the captured requests newest
+// first. ?after=<id> returns only newer requests, ?status= filters by
+// status code and ?limit= caps the result (default 50).
+func (h *CapturesHandler) List(c *gin.Context) {
+	afterID, err := strconv.ParseInt(c.DefaultQuery("after", "0"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": "after must be a request id",
+		})
+		return
+	}
+
+	status, err := strconv.Atoi(c.DefaultQuery("status", "0"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": "status must be an HTTP status code",
+		})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": "limit must be a positive integer",
+		})
+		return
+	}
+
+	result := h.captures.Recorder().List(afterID, status, limit)
+	c.JSON(http.StatusOK, gin.H{
+		"captures": h.captures.Active(),
+		"requests": result,
+		"total":    len(result),
+	})
+}
+
+// Start captures the requests to ?route= for ?duration=, five minutes by
+// default and at most the configured maximum.
+func (h *CapturesHandler) Start(c *gin.Context) {
+	route := c.Query("route")
+	if !h.routeExists(route) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Not found",
+			"message": "no route " + route,
+		})
+		return
+	}
+
+	duration := forensics.DefaultCaptureDuration
+	if raw := c.Query("duration"); raw != "" {
+		var err error
+		if duration, err = time.ParseDuration(raw); err != nil || duration <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Bad request",
+				"message": "duration must be a positive duration such as 10m",
+			})
+			return
+		}
+	}
+	if duration > h.maxDuration {
+		duration = h.maxDuration
+	}
+
+	session := models.CaptureSession{Route: route, Until: time.Now().Add(duration)}
+	previous, running := h.captures.Start(route, session.Until)
+	if running {
+		audit.Change(c, previous, session)
+	} else {
+		audit.Change(c, nil, session)
+	}
+	c.JSON(http.StatusOK, session)
+}
+
+// Stop ends the capture of ?route= before it expires. What it captured is
+// kept.
+func (h *CapturesHandler) Stop(c *gin.Context) {
+	route := c.Query("route")
+	session, running := h.captures.Stop(route)
+	if !running {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Not found",
+			"message": "no capture of route " + route,
+		})
+		return
+	}
+	audit.Change(c, session, nil)
+	c.JSON(http.StatusOK, gin.H{"route": route, "stopped": true})
+}
+
+func (h *CapturesHandler) routeExists(path string) bool {
+	for _, route := range h.registry.GetRoutes() {
+		if route.Path == path {
+			return true
+		}
+	}
+	return false
+}
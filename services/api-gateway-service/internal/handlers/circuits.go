@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"gateway/internal/audit"
+	"gateway/internal/circuit"
+	"gateway/internal/models"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CircuitBreakersHandler shows the circuit of every service, opens one by
+// hand for maintenance and closes one that should not have tripped.
+type CircuitBreakersHandler struct {
+	breakers *circuit.Breakers
+	registry *registry.ServiceRegistry
+}
+
+func NewCircuitBreakersHandler(breakers *circuit.Breakers, serviceRegistry *registry.ServiceRegistry) *CircuitBreakersHandler {
+	return &CircuitBreakersHandler{breakers: breakers, registry: serviceRegistry}
+}
+
+func (h *CircuitBreakersHandler) Register(group *gin.RouterGroup) {
+	group.GET("", h.List)
+	group.POST("/open", h.Open)
+	group.POST("/reset", h.Reset)
+}
+
+// List returns every circuit, including the closed ones of services that
+// were not called yet. ?service= keeps one service's circuits.
+func (h *CircuitBreakersHandler) List(c *gin.Context) {
+	only := c.Query("service")
+	circuits := make([]circuit.CircuitStatus, 0)
+	used := make(map[string]bool)
+	for _, status := range h.breakers.Circuits() {
+		if status.Route == "" {
+			used[status.ServiceName] = true
+		}
+		if only == "" || status.ServiceName == only {
+			circuits = append(circuits, status)
+		}
+	}
+	for name, service := range h.registry.GetAllServices() {
+		if used[name] || (only != "" && name != only) {
+			continue
+		}
+		_, settings := h.breakers.SettingsFor(&service, nil)
+		circuits = append(circuits, circuit.CircuitStatus{CircuitBreakerState: *models.NewCircuitBreakerState(name, settings)})
+	}
+	sort.Slice(circuits, func(i, j int) bool {
+		if circuits[i].ServiceName != circuits[j].ServiceName {
+			return circuits[i].ServiceName < circuits[j].ServiceName
+		}
+		return circuits[i].Route < circuits[j].Route
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"circuits": circuits,
+		"total":    len(circuits),
+	})
+}
+
+// Open forces the circuit of ?service=, or of its ?route= with a circuit
+// of its own, open. It stays open for ?duration=, or until it is reset.
+func (h *CircuitBreakersHandler) Open(c *gin.Context) {
+	service, route, ok := h.lookup(c)
+	if !ok {
+		return
+	}
+	var until time.Time
+	if raw := c.Query("duration"); raw != "" {
+		duration, err := time.ParseDuration(raw)
+		if err != nil || duration <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Bad request",
+				"message": "duration must be a positive duration such as 30m",
+			})
+			return
+		}
+		until = time.Now().Add(duration)
+	}
+
+	circuitRoute, settings := h.breakers.SettingsFor(service, route)
+	before, exists := h.breakers.Circuit(service.Name, circuitRoute)
+	h.breakers.ForceOpen(service.Name, circuitRoute, settings, until)
+	status, _ := h.breakers.Circuit(service.Name, circuitRoute)
+	if !exists {
+		before = circuit.CircuitStatus{CircuitBreakerState: *models.NewCircuitBreakerState(service.Name, settings), Route: circuitRoute}
+	}
+	audit.Change(c, before, status)
+	c.JSON(http.StatusOK, status)
+}
+
+// Reset closes the circuit of ?service=, or of its ?route=, and forgets
+// its counts.
+func (h *CircuitBreakersHandler) Reset(c *gin.Context) {
+	service, route, ok := h.lookup(c)
+	if !ok {
+		return
+	}
+	circuitRoute, settings := h.breakers.SettingsFor(service, route)
+	status, exists := h.breakers.Circuit(service.Name, circuitRoute)
+	if !exists {
+		status = circuit.CircuitStatus{CircuitBreakerState: *models.NewCircuitBreakerState(service.Name, settings), Route: circuitRoute}
+	}
+	before := status
+	if h.breakers.Reset(service.Name, circuitRoute) {
+		status, _ = h.breakers.Circuit(service.Name, circuitRoute)
+	}
+	audit.Change(c, before, status)
+	c.JSON(http.StatusOK, status)
+}
+
+// lookup finds the service and route a request names, answering it if
+// they do not exist.
+func (h *CircuitBreakersHandler) lookup(c *gin.Context) (*models.ServiceConfig, *models.RouteConfig, bool) {
+	name := c.Query("service")
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad request",
+			"message": "service is required",
+		})
+		return nil, nil, false
+	}
+	service, exists := h.registry.GetService(name)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Not found",
+			"message": "no service " + name,
+		})
+		return nil, nil, false
+	}
+
+	path := c.Query("route")
+	if path == "" {
+		return service, nil, true
+	}
+	for _, route := range h.registry.GetRoutes() {
+		if route.Path == path && route.ServiceName == name && route.CircuitBreaker != nil {
+			return service, &route, true
+		}
+	}
+	c.JSON(http.StatusNotFound, gin.H{
+		"error":   "Not found",
+		"message": "no route " + path + " of " + name + " with a circuit of its own",
+	})
+	return nil, nil, false
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"gateway/internal/config"
+	"gateway/internal/models"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ConfigHandler shows operators the configuration the gateway runs with,
+// with secrets masked, and the versions applied before it, which can be
+// rolled back to. Candidate configs can be checked against it before they
+// are rolled out.
+type ConfigHandler struct {
+	manager  *config.Manager
+	started  *models.GatewayConfig
+	registry *registry.ServiceRegistry
+}
+
+// maxCandidateSize bounds the config files accepted for a dry run.
+const maxCandidateSize = 4 << 20
+
+// NewConfigHandler serves manager's configuration. started is the one the
+// gateway started with, to tell which reloaded settings wait for a restart.
+// Rollbacks swap services and routes in serviceRegistry as reloads do, and
+// dry runs check them against it; with a nil registry they are left out.
+func NewConfigHandler(manager *config.Manager, started *models.GatewayConfig, serviceRegistry *registry.ServiceRegistry) *ConfigHandler {
+	return &ConfigHandler{manager: manager, started: started, registry: serviceRegistry}
+}
+
+func (h *ConfigHandler) Register(group *gin.RouterGroup) {
+	group.GET("", h.Show)
+	group.GET("/history", h.History)
+	group.POST("/rollback/:version", h.Rollback)
+	group.POST("/dry-run", h.DryRun)
+	group.GET("/schema", h.Schema)
+}
+
+// Show returns the configuration in force, with defaults, the config file
+// with its includes and overlay, and environment variables merged.
+// restart_required lists the settings reloads changed that only take
+// effect on restart; until then the gateway runs with the values it
+// started with.
+func (h *ConfigHandler) Show(c *gin.Context) {
+	current := h.manager.GetConfig()
+	c.JSON(http.StatusOK, gin.H{
+		"config":           config.Redact(current, h.manager.SecretPaths()...),
+		"config_file":      h.manager.ConfigFile(),
+		"config_files":     h.manager.ConfigFiles(),
+		"config_hash":      config.Hash(current),
+		"restart_required": h.restartRequired(current),
+	})
+}
+
+// History lists the configuration versions kept, newest first.
+func (h *ConfigHandler) History(c *gin.Context) {
+	history := h.manager.History()
+	versions := history.Versions()
+	c.JSON(http.StatusOK, gin.H{
+		"current":  history.Current(),
+		"versions": versions,
+		"total":    len(versions),
+	})
+}
+
+// Rollback puts a version from the history back in force. It fails with
+// 409 when the registry rejects the version's routes, for instance because
+// a service they need is now registered by someone else.
+func (h *ConfigHandler) Rollback(c *gin.Context) {
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad request",
+			"message": "version must be a number",
+		})
+		return
+	}
+
+	var apply func(previous, current *models.GatewayConfig) error
+	if h.registry != nil {
+		apply = func(previous, current *models.GatewayConfig) error {
+			return h.registry.ReplaceRouting(previous.Services, current.Services, previous.Routes, current.Routes)
+		}
+	}
+	diff, applied, err := h.manager.Rollback(version, apply)
+	if errors.Is(err, config.ErrUnknownVersion) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Not found",
+			"message": fmt.Sprintf("config version %d is not in the history", version),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Conflict",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"version":          applied,
+		"changes":          diff,
+		"restart_required": h.restartRequired(h.manager.GetConfig()),
+	})
+}
+
+// DryRun checks a candidate config file, sent as the body in YAML or
+// JSON, as a reload of the config file would, and reports what applying
+// it would change, without applying anything. A candidate that fails is
+// reported with the stage that rejected it: load, validation, or routing
+// when the routing table would refuse its services and routes. When it
+// does not match the schema, violations lists every problem with its line.
+func (h *ConfigHandler) DryRun(c *gin.Context) {
+	candidate, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxCandidateSize))
+	if err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error":   "Request entity too large",
+			"message": fmt.Sprintf("candidate config must not exceed %d bytes", maxCandidateSize),
+		})
+		return
+	}
+	if len(bytes.TrimSpace(candidate)) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad request",
+			"message": "send the candidate config as the request body",
+		})
+		return
+	}
+
+	current := h.manager.GetConfig()
+	result, stage, err := h.manager.DryRun(candidate)
+	if err == nil && h.registry != nil {
+		if err = h.registry.CheckRouting(current.Services, result.Services, current.Routes, result.Routes); err != nil {
+			stage = "routing"
+		}
+	}
+	if err != nil {
+		response := gin.H{
+			"valid":   false,
+			"stage":   stage,
+			"message": err.Error(),
+		}
+		var schemaErr *config.SchemaError
+		if errors.As(err, &schemaErr) {
+			response["violations"] = schemaErr.Violations
+		}
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	diff := config.DiffConfigs(current, result)
+	c.JSON(http.StatusOK, gin.H{
+		"valid":             true,
+		"config_hash":       config.Hash(result),
+		"changes":           diff,
+		"total_changes":     diff.Size(),
+		"services_affected": config.AffectedServices(current, result, diff),
+		"restart_required":  h.restartRequired(result),
+	})
+}
+
+// Schema returns the JSON Schema config files are checked against.
+func (h *ConfigHandler) Schema(c *gin.Context) {
+	c.JSON(http.StatusOK, config.GatewaySchema())
+}
+
+// restartRequired lists the settings of cfg that differ from those the
+// gateway started with and so wait for a restart.
+func (h *ConfigHandler) restartRequired(cfg *models.GatewayConfig) []string {
+	sections := config.DiffConfigs(h.started, cfg).Sections
+	if sections == nil {
+		sections = []string{}
+	}
+	return sections
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DebugHandler serves the Go profiler and a summary of the runtime, for
+// diagnosing latency in production: goroutines piling up, GC pauses, or
+// connections to one upstream growing.
+type DebugHandler struct {
+	// connections returns the open connections per upstream address
+	connections func() map[string]int64
+	started     time.Time
+}
+
+func NewDebugHandler(connections func() map[string]int64) *DebugHandler {
+	return &DebugHandler{connections: connections, started: time.Now()}
+}
+
+// RegisterProfiles serves net/http/pprof on group, which must be mounted
+// at /debug/pprof as the index links there.
+func (h *DebugHandler) RegisterProfiles(group *gin.RouterGroup) {
+	group.GET("/*name", func(c *gin.Context) {
+		switch c.Param("name") {
+		case "/cmdline":
+			pprof.Cmdline(c.Writer, c.Request)
+		case "/profile":
+			pprof.Profile(c.Writer, c.Request)
+		case "/symbol":
+			pprof.Symbol(c.Writer, c.Request)
+		case "/trace":
+			pprof.Trace(c.Writer, c.Request)
+		default:
+			// The index and the named profiles, such as heap and goroutine
+			pprof.Index(c.Writer, c.Request)
+		}
+	})
+	group.POST("/symbol", gin.WrapF(pprof.Symbol))
+}
+
+func (h *DebugHandler) Register(group *gin.RouterGroup) {
+	group.GET("/runtime", h.Runtime)
+}
+
+// Runtime reports goroutines, memory and GC statistics, and the open
+// connections to each upstream. Reading the memory statistics briefly
+// stops the world.
+func (h *DebugHandler) Runtime(c *gin.Context) {
+	var memory runtime.MemStats
+	runtime.ReadMemStats(&memory)
+
+	gc := gin.H{
+		"cycles":         memory.NumGC,
+		"forced_cycles":  memory.NumForcedGC,
+		"pause_total_ms": float64(memory.PauseTotalNs) / 1e6,
+		"cpu_fraction":   memory.GCCPUFraction,
+		"next_gc_bytes":  memory.NextGC,
+	}
+	if memory.NumGC > 0 {
+		gc["last_pause_ms"] = float64(memory.PauseNs[(memory.NumGC+255)%256]) / 1e6
+		gc["last_run"] = time.Unix(0, int64(memory.LastGC)).UTC().Format(time.RFC3339Nano)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"go_version": runtime.Version(),
+		"uptime":     time.Since(h.started).Round(time.Second).String(),
+		"goroutines": runtime.NumGoroutine(),
+		"gomaxprocs": runtime.GOMAXPROCS(0),
+		"cpus":       runtime.NumCPU(),
+		"memory": gin.H{
+			"heap_alloc_bytes":   memory.HeapAlloc,
+			"heap_in_use_bytes":  memory.HeapInuse,
+			"heap_objects":       memory.HeapObjects,
+			"stack_in_use_bytes": memory.StackInuse,
+			"sys_bytes":          memory.Sys,
+		},
+		"gc":                   gc,
+		"upstream_connections": h.connections(),
+	})
+}
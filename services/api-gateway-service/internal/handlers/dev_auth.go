@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"gateway/internal/auth"
+	"gateway/internal/devauth"
+
+	"github.com/gin-gonic/gin"
+)
+
+const defaultDevTokenTTL = 8 * time.Hour
+
+// DevAuthHandler issues and verifies dev tokens for --dev-auth. Anyone
+// can get a token for any user, which is the point in local development
+// and why the mode must never run elsewhere.
+type DevAuthHandler struct {
+	issuer *devauth.Issuer
+}
+
+type devTokenRequest struct {
+	UserID string            `json:"user_id"`
+	Email  string            `json:"email"`
+	Name   string            `json:"name"`
+	Scopes []string          `json:"scopes"`
+	TTL    string            `json:"ttl"`
+	Claims map[string]string `json:"claims"`
+}
+
+func NewDevAuthHandler(issuer *devauth.Issuer) *DevAuthHandler {
+	return &DevAuthHandler{issuer: issuer}
+}
+
+func (h *DevAuthHandler) Register(group *gin.RouterGroup) {
+	group.POST("/token", h.Token)
+	group.GET("/verify", h.Verify)
+}
+
+// Token issues a token for the user in the body, "dev-user" by default.
+func (h *DevAuthHandler) Token(c *gin.Context) {
+	var req devTokenRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid request",
+				"message": err.Error(),
+			})
+			return
+		}
+	}
+
+	ttl := defaultDevTokenTTL
+	if req.TTL != "" {
+		var err error
+		if ttl, err = time.ParseDuration(req.TTL); err != nil || ttl <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid request",
+				"message": "ttl must be a positive duration",
+			})
+			return
+		}
+	}
+	if req.UserID == "" {
+		req.UserID = "dev-user"
+	}
+
+	token, expiresAt, err := h.issuer.Issue(devauth.Claims{
+		Subject: req.UserID,
+		Email:   req.Email,
+		Name:    req.Name,
+		Scope:   strings.Join(req.Scopes, " "),
+		Extra:   req.Claims,
+	}, ttl)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Token could not be issued"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"access_token": token,
+		"token_type":   "Bearer",
+		"expires_in":   int(time.Until(expiresAt).Seconds()),
+	})
+}
+
+// Verify answers like the auth service's /auth/verify, for services that
+// check tokens themselves.
+func (h *DevAuthHandler) Verify(c *gin.Context) {
+	scheme, token, _ := strings.Cut(c.GetHeader("Authorization"), " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		c.Status(http.StatusUnauthorized)
+		return
+	}
+
+	identity, err := h.issuer.Verify(c.Request.Context(), strings.TrimSpace(token))
+	if errors.Is(err, auth.ErrInvalidCredentials) {
+		c.Status(http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	tokenData := gin.H{"scope": strings.Join(identity.Scopes, " ")}
+	if identity.Name != "" {
+		tokenData["name"] = identity.Name
+	}
+	for name, value := range identity.Claims {
+		tokenData[name] = value
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"valid":      true,
+		"user_id":    identity.UserID,
+		"email":      identity.Email,
+		"token_data": tokenData,
+	})
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gateway/internal/events"
+	"gateway/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// streamKeepAlive is how often an idle event stream sends a comment, so
+// proxies in between do not close it.
+const streamKeepAlive = 15 * time.Second
+
+// EventsHandler exposes the health event log so on-call can see which
+// services changed state and when, and the live event stream when there is
+// one.
+type EventsHandler struct {
+	log    *events.Log
+	stream *events.Stream
+}
+
+func NewEventsHandler(eventLog *events.Log, stream *events.Stream) *EventsHandler {
+	return &EventsHandler{log: eventLog, stream: stream}
+}
+
+func (h *EventsHandler) Register(group *gin.RouterGroup) {
+	group.GET("", h.List)
+	if h.stream != nil {
+		group.GET("/stream", h.Stream)
+	}
+}
+
+// List returns events oldest first. ?after=<id> returns only newer events,
+// ?service= filters by service and ?limit= keeps the newest N (default 100).
+func (h *EventsHandler) List(c *gin.Context) {
+	afterID, err := strconv.ParseInt(c.DefaultQuery("after", "0"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": "after must be an event id",
+		})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": "limit must be a positive integer",
+		})
+		return
+	}
+
+	result := h.log.List(afterID, c.Query("service"), limit)
+	c.JSON(http.StatusOK, gin.H{
+		"events": result,
+		"total":  len(result),
+	})
+}
+
+// Stream pushes events as they happen as server-sent events, named after
+// their type. ?types= takes a comma-separated list of event types to
+// receive and ?service= filters by service. Events a slow client missed are
+// reported in a "missed" event. Clients beyond the stream's subscriber limit
+// get 503.
+func (h *EventsHandler) Stream(c *gin.Context) {
+	var types []models.HealthEventType
+	if param := c.Query("types"); param != "" {
+		for _, name := range strings.Split(param, ",") {
+			eventType := models.HealthEventType(strings.TrimSpace(name))
+			if !knownEventType(eventType) {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":   "Invalid request",
+					"message": fmt.Sprintf("unknown event type %q", eventType),
+				})
+				return
+			}
+			types = append(types, eventType)
+		}
+	}
+
+	subscription, err := h.stream.Subscribe(types, c.Query("service"))
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":   "Service unavailable",
+			"message": "the event stream has too many subscribers",
+		})
+		return
+	}
+	defer subscription.Close()
+
+	// The stream outlives the server's write timeout
+	http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	io.WriteString(c.Writer, ": connected\n\n")
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case event, open := <-subscription.Events():
+			if !open {
+				return
+			}
+			if missed := subscription.Missed(); missed > 0 {
+				c.SSEvent("missed", gin.H{"count": missed})
+			}
+			if event.ID > 0 {
+				fmt.Fprintf(c.Writer, "id: %d\n", event.ID)
+			}
+			c.SSEvent(string(event.Type), event)
+		case <-keepAlive.C:
+			io.WriteString(c.Writer, ": keep-alive\n\n")
+		case <-c.Request.Context().Done():
+			return
+		}
+		c.Writer.Flush()
+	}
+}
+
+func knownEventType(eventType models.HealthEventType) bool {
+	for _, known := range models.HealthEventTypes {
+		if eventType == known {
+			return true
+		}
+	}
+	return false
+}
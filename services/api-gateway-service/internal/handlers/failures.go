@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"gateway/internal/forensics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FailuresHandler lets on-call inspect the most recent failed requests.
+type FailuresHandler struct {
+	recorder *forensics.Recorder
+}
+
+func NewFailuresHandler(recorder *forensics.Recorder) *FailuresHandler {
+	return &FailuresHandler{recorder: recorder}
+}
+
+func (h *FailuresHandler) Register(group *gin.RouterGroup) {
+	group.GET("", h.List)
+	group.DELETE("", h.Clear)
+}
+
+// List returns failed requests newest first. ?after=<id> returns only newer
+// ones, ?status= filters by status code and ?limit= caps the result
+// (default 50).
+func (h *FailuresHandler) List(c *gin.Context) {
+	afterID, err := strconv.ParseInt(c.DefaultQuery("after", "0"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": "after must be a request id",
+		})
+		return
+	}
+
+	status, err := strconv.Atoi(c.DefaultQuery("status", "0"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": "status must be an HTTP status code",
+		})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": "limit must be a positive integer",
+		})
+		return
+	}
+
+	result := h.recorder.List(afterID, status, limit)
+	c.JSON(http.StatusOK, gin.H{
+		"requests": result,
+		"total":    len(result),
+	})
+}
+
+func (h *FailuresHandler) Clear(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"cleared": h.recorder.Clear()})
+}
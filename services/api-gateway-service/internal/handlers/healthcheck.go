@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HealthCheckHandler probes services on demand, so operators confirming a
+// fix need not wait for the next scheduled check.
+type HealthCheckHandler struct {
+	registry *registry.ServiceRegistry
+}
+
+func NewHealthCheckHandler(serviceRegistry *registry.ServiceRegistry) *HealthCheckHandler {
+	return &HealthCheckHandler{registry: serviceRegistry}
+}
+
+func (h *HealthCheckHandler) Register(group *gin.RouterGroup) {
+	group.POST("/:name/healthcheck", h.Check)
+}
+
+// Check probes the service now and answers with the probe's result and
+// the service's status after it. The status follows the configured rise
+// and fall thresholds, so one passing probe may not make an unhealthy
+// service healthy yet.
+func (h *HealthCheckHandler) Check(c *gin.Context) {
+	name := c.Param("name")
+	previous, exists := h.registry.GetService(name)
+	var result registry.ProbeResult
+	if exists {
+		result, exists = h.registry.CheckHealth(name)
+	}
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Service not found",
+			"message": fmt.Sprintf("No service %s", name),
+		})
+		return
+	}
+	response := gin.H{
+		"probe":           result,
+		"previous_status": previous.Status,
+		"status":          previous.Status,
+	}
+	if service, exists := h.registry.GetService(name); exists {
+		response["status"] = service.Status
+		response["flapping"] = service.Flapping
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"gateway/internal/traffic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultSlowestRoutes is how many routes the latency report lists when
+// ?limit= is not given.
+const DefaultSlowestRoutes = 10
+
+// LatencyHandler reports the routes with the slowest p99 latency over the
+// last few minutes.
+type LatencyHandler struct {
+	latencies *traffic.Latencies
+}
+
+func NewLatencyHandler(latencies *traffic.Latencies) *LatencyHandler {
+	return &LatencyHandler{latencies: latencies}
+}
+
+func (h *LatencyHandler) Register(group *gin.RouterGroup) {
+	group.GET("", h.Slowest)
+}
+
+// Slowest lists the ?limit= slowest routes, slowest first.
+func (h *LatencyHandler) Slowest(c *gin.Context) {
+	limit := DefaultSlowestRoutes
+	if value := c.Query("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Bad request",
+				"message": "limit must be a positive number",
+			})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"window": h.latencies.Window().String(),
+		"routes": h.latencies.Slowest(limit),
+	})
+}
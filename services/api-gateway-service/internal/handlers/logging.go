@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"gateway/internal/audit"
+	"gateway/internal/logging"
+	"gateway/internal/models"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LoggingHandler changes the access log level and debug logging of routes
+// on a live gateway, for a while, without a reload.
+type LoggingHandler struct {
+	overrides   *logging.Overrides
+	registry    *registry.ServiceRegistry
+	maxDuration time.Duration
+}
+
+func NewLoggingHandler(overrides *logging.Overrides, serviceRegistry *registry.ServiceRegistry, maxDuration time.Duration) *LoggingHandler {
+	return &LoggingHandler{overrides: overrides, registry: serviceRegistry, maxDuration: maxDuration}
+}
+
+func (h *LoggingHandler) Register(group *gin.RouterGroup) {
+	group.GET("", h.Show)
+	group.PUT("", h.Set)
+	group.DELETE("", h.Reset)
+}
+
+// loggingOverride is the body of PUT /gateway/logging.
+type loggingOverride struct {
+	Level       string   `json:"level"`
+	DebugRoutes []string `json:"debug_routes"`
+	Duration    string   `json:"duration"`
+}
+
+func (h *LoggingHandler) Show(c *gin.Context) {
+	c.JSON(http.StatusOK, h.overrides.Status())
+}
+
+// Set replaces the override in effect. It lasts for the body's duration,
+// fifteen minutes by default and at most the configured maximum, and then
+// reverts to the configured level without debug logging.
+func (h *LoggingHandler) Set(c *gin.Context) {
+	var body loggingOverride
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad request",
+			"message": err.Error(),
+		})
+		return
+	}
+	if body.Level == "" && len(body.DebugRoutes) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad request",
+			"message": "set a level, debug_routes or both",
+		})
+		return
+	}
+	if body.Level != "" && !models.ValidLogLevel(body.Level) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad request",
+			"message": "level must be debug, info, warn or error",
+		})
+		return
+	}
+	for _, route := range body.DebugRoutes {
+		if !h.routeExists(route) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Not found",
+				"message": "no route " + route,
+			})
+			return
+		}
+	}
+
+	duration := logging.DefaultOverrideDuration
+	if body.Duration != "" {
+		var err error
+		if duration, err = time.ParseDuration(body.Duration); err != nil || duration <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Bad request",
+				"message": "duration must be a positive duration such as 10m",
+			})
+			return
+		}
+	}
+	if duration > h.maxDuration {
+		duration = h.maxDuration
+	}
+
+	before := h.overrides.Status()
+	status := h.overrides.Set(body.Level, body.DebugRoutes, time.Now().Add(duration))
+	audit.Change(c, before, status)
+	c.JSON(http.StatusOK, status)
+}
+
+// Reset reverts to the configured level without debug logging now.
+func (h *LoggingHandler) Reset(c *gin.Context) {
+	before := h.overrides.Status()
+	status := h.overrides.Reset()
+	audit.Change(c, before, status)
+	c.JSON(http.StatusOK, status)
+}
+
+func (h *LoggingHandler) routeExists(path string) bool {
+	for _, route := range h.registry.GetRoutes() {
+		if route.Path == path {
+			return true
+		}
+	}
+	return false
+}
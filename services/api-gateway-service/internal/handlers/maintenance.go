@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"gateway/internal/audit"
+	"gateway/internal/maintenance"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MaintenanceHandler turns the gateway's maintenance mode on and off.
+type MaintenanceHandler struct {
+	mode *maintenance.Mode
+}
+
+func NewMaintenanceHandler(mode *maintenance.Mode) *MaintenanceHandler {
+	return &MaintenanceHandler{mode: mode}
+}
+
+func (h *MaintenanceHandler) Register(group *gin.RouterGroup) {
+	group.GET("", h.Show)
+	group.POST("/enable", h.Enable)
+	group.POST("/disable", h.Disable)
+}
+
+func (h *MaintenanceHandler) Show(c *gin.Context) {
+	c.JSON(http.StatusOK, h.mode.Status())
+}
+
+// Enable turns maintenance mode on. The optional JSON body sets a message
+// and retry_after for this maintenance window instead of the configured
+// ones.
+func (h *MaintenanceHandler) Enable(c *gin.Context) {
+	var req struct {
+		Message    string `json:"message"`
+		RetryAfter string `json:"retry_after"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad request",
+			"message": err.Error(),
+		})
+		return
+	}
+	var retryAfter time.Duration
+	if req.RetryAfter != "" {
+		var err error
+		if retryAfter, err = time.ParseDuration(req.RetryAfter); err != nil || retryAfter <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Bad request",
+				"message": "retry_after must be a positive duration such as 30m",
+			})
+			return
+		}
+	}
+
+	before := h.mode.Status()
+	status := h.mode.Enable(req.Message, retryAfter)
+	audit.Change(c, before, status)
+	c.JSON(http.StatusOK, status)
+}
+
+func (h *MaintenanceHandler) Disable(c *gin.Context) {
+	before := h.mode.Status()
+	status := h.mode.Disable()
+	audit.Change(c, before, status)
+	c.JSON(http.StatusOK, status)
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"net/http"
+
+	"gateway/internal/migration"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MigrationsHandler reports the traffic still using migrated paths.
+type MigrationsHandler struct {
+	tracker *migration.Tracker
+}
+
+func NewMigrationsHandler(tracker *migration.Tracker) *MigrationsHandler {
+	return &MigrationsHandler{tracker: tracker}
+}
+
+func (h *MigrationsHandler) Register(group *gin.RouterGroup) {
+	group.GET("", h.List)
+	group.DELETE("", h.Reset)
+}
+
+func (h *MigrationsHandler) List(c *gin.Context) {
+	migrations := h.tracker.Snapshot()
+	c.JSON(http.StatusOK, gin.H{
+		"migrations": migrations,
+		"total":      len(migrations),
+	})
+}
+
+// Reset clears the counters, e.g. after the last known client was updated.
+func (h *MigrationsHandler) Reset(c *gin.Context) {
+	h.tracker.Reset()
+	c.JSON(http.StatusOK, gin.H{"reset": true})
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"strings"
+
+	"gateway/internal/i18n"
+	"gateway/internal/oidc"
+	"gateway/internal/session"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OIDCHandler completes logins started by the OIDCLogin middleware and
+// ends them again.
+type OIDCHandler struct {
+	client   *oidc.Client
+	sessions *session.Manager
+}
+
+func NewOIDCHandler(client *oidc.Client, sessions *session.Manager) *OIDCHandler {
+	return &OIDCHandler{
+		client:   client,
+		sessions: sessions,
+	}
+}
+
+func (h *OIDCHandler) Register(group *gin.RouterGroup) {
+	group.GET("/callback", h.Callback)
+	group.GET("/logout", h.Logout)
+	group.POST("/logout", h.Logout)
+}
+
+func (h *OIDCHandler) Callback(c *gin.Context) {
+	pending, err := h.sessions.Load(c.Request)
+	if err != nil || !validState(pending, c.Query("state")) {
+		i18n.Error(c, http.StatusBadRequest, "Login expired", i18n.LoginExpired)
+		return
+	}
+
+	if reason := c.Query("error"); reason != "" {
+		log.Printf("OIDC login denied by identity provider: %s %s", reason, c.Query("error_description"))
+		i18n.Error(c, http.StatusUnauthorized, "Login denied", i18n.LoginDenied)
+		return
+	}
+
+	tokens, err := h.client.Exchange(c.Request.Context(), c.Query("code"), pending.Get(session.ValueOIDCVerifier))
+	if err != nil {
+		log.Printf("OIDC code exchange failed: %v", err)
+		i18n.Error(c, http.StatusBadGateway, "Login unavailable", i18n.LoginUnavailable)
+		return
+	}
+	claims, err := h.client.ParseIDToken(tokens.IDToken, pending.Get(session.ValueOIDCNonce))
+	if err != nil {
+		log.Printf("OIDC login rejected: %v", err)
+		i18n.Error(c, http.StatusUnauthorized, "Login denied", i18n.LoginDenied)
+		return
+	}
+
+	// A fresh session ID on login keeps a session planted before it from
+	// inheriting the identity
+	sess, err := h.sessions.New()
+	if err != nil {
+		i18n.Error(c, http.StatusInternalServerError, "Session error", i18n.SessionCreateFailed)
+		return
+	}
+	returnTo := pending.Get(session.ValueOIDCReturnTo)
+	for key, value := range pending.Values {
+		if !strings.HasPrefix(key, "oidc_") {
+			sess.Set(key, value)
+		}
+	}
+	h.sessions.Invalidate(c.Request.Context(), pending.ID)
+
+	sess.UserID = claims.Subject
+	sess.Set(session.ValueIDToken, tokens.IDToken)
+	sess.Set(session.ValueEmail, claims.Email)
+	if name := claims.Name; name != "" {
+		sess.Set(session.ValueName, name)
+	} else {
+		sess.Set(session.ValueName, claims.PreferredUsername)
+	}
+
+	if err := h.sessions.Save(c.Writer, c.Request, sess); err != nil {
+		log.Printf("OIDC failed to store session: %v", err)
+		i18n.Error(c, http.StatusInternalServerError, "Session error", i18n.SessionStoreFailed)
+		return
+	}
+	if evicted, err := h.sessions.EnforceUserLimit(c.Request.Context(), sess); err != nil {
+		log.Printf("OIDC failed to enforce session limit: %v", err)
+	} else if evicted > 0 {
+		log.Printf("OIDC login ended %d older session(s) of user %s", evicted, sess.UserID)
+	}
+
+	c.Redirect(http.StatusFound, localPath(returnTo))
+}
+
+func (h *OIDCHandler) Logout(c *gin.Context) {
+	var idToken string
+	if sess, err := h.sessions.Load(c.Request); err == nil {
+		idToken = sess.Get(session.ValueIDToken)
+	}
+	if err := h.sessions.Destroy(c.Writer, c.Request); err != nil {
+		log.Printf("OIDC failed to delete session: %v", err)
+	}
+
+	// Without ending the provider session the next login would be silent
+	if logoutURL := h.client.LogoutURL(c.Request.Context(), idToken); logoutURL != "" {
+		c.Redirect(http.StatusFound, logoutURL)
+		return
+	}
+	c.Redirect(http.StatusFound, "/")
+}
+
+func validState(pending *session.Session, state string) bool {
+	expected := pending.Get(session.ValueOIDCState)
+	return expected != "" && subtle.ConstantTimeCompare([]byte(expected), []byte(state)) == 1
+}
+
+// localPath only lets the login return to paths on the gateway itself.
+func localPath(path string) string {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.HasPrefix(path, "/\\") {
+		return "/"
+	}
+	return path
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gateway/internal/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultBucketListLimit = 100
+	maxBucketListLimit     = 1000
+)
+
+// RateLimitsHandler lists the rate limiter's active buckets and resets the
+// bucket of a client that was blocked by mistake.
+type RateLimitsHandler struct {
+	limiter   ratelimit.Taker
+	penalties *ratelimit.PenaltyBox
+}
+
+// NewRateLimitsHandler builds the handler; penalties may be nil.
+func NewRateLimitsHandler(limiter ratelimit.Taker, penalties *ratelimit.PenaltyBox) *RateLimitsHandler {
+	return &RateLimitsHandler{limiter: limiter, penalties: penalties}
+}
+
+func (h *RateLimitsHandler) Register(group *gin.RouterGroup) {
+	group.GET("", h.List)
+	group.DELETE("", h.Reset)
+}
+
+// List returns the active buckets, the most blocked first. ?key= keeps the
+// keys starting with it, ?blocked=true those that rejected requests, and
+// ?limit= caps how many are returned.
+func (h *RateLimitsHandler) List(c *gin.Context) {
+	buckets, err := h.limiter.Buckets()
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error":   "Bad gateway",
+			"message": "rate limit coordinator unavailable: " + err.Error(),
+		})
+		return
+	}
+
+	limit := defaultBucketListLimit
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Bad request",
+				"message": "limit must be a positive number",
+			})
+			return
+		}
+		limit = parsed
+	}
+	if limit > maxBucketListLimit {
+		limit = maxBucketListLimit
+	}
+
+	prefix := c.Query("key")
+	onlyBlocked := c.Query("blocked") == "true"
+	matching := make([]ratelimit.BucketStatus, 0)
+	for _, bucket := range buckets {
+		if !strings.HasPrefix(bucket.Key, prefix) || (onlyBlocked && bucket.Blocked == 0) {
+			continue
+		}
+		matching = append(matching, bucket)
+	}
+	total := len(matching)
+	if len(matching) > limit {
+		matching = matching[:limit]
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"buckets": matching,
+		"total":   total,
+	})
+}
+
+// Reset gives the bucket of ?key= its full burst again and clears the
+// penalties of its clients.
+func (h *RateLimitsHandler) Reset(c *gin.Context) {
+	key := c.Query("key")
+	if key == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad request",
+			"message": "key is required",
+		})
+		return
+	}
+
+	existed, err := h.limiter.Reset(key)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error":   "Bad gateway",
+			"message": "rate limit coordinator unavailable: " + err.Error(),
+		})
+		return
+	}
+	forgiven := 0
+	if h.penalties != nil {
+		forgiven = h.penalties.Forgive(key)
+	}
+	if !existed && forgiven == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Not found",
+			"message": "no active rate limit bucket for " + key,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"key":                key,
+		"reset":              existed,
+		"penalties_forgiven": forgiven,
+	})
+}
@@ -0,0 +1,293 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"gateway/internal/config"
+	"gateway/internal/models"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+)
+
+const RegistrationTokenHeader = "X-Registration-Token"
+
+// RegistrationHandler lets backend instances register themselves with a
+// TTL and keep their registration alive with heartbeats.
+type RegistrationHandler struct {
+	registry *registry.ServiceRegistry
+	manager  *config.Manager
+	config   models.RegistrationConfig
+
+	// mutex keeps registrations from passing their checks side by side
+	mutex sync.Mutex
+}
+
+type registrationRoute struct {
+	Path         string            `json:"path" binding:"required"`
+	Method       string            `json:"method"`
+	StripPrefix  bool              `json:"strip_prefix"`
+	Headers      map[string]string `json:"headers"`
+	AuthRequired bool              `json:"auth_required"`
+	AuthMode     models.AuthMode   `json:"auth_mode"`
+	AuthProvider string            `json:"auth_provider"`
+}
+
+type registrationRequest struct {
+	Name                 string              `json:"name" binding:"required"`
+	URL                  string              `json:"url" binding:"required"`
+	HealthPath           string              `json:"health_path"`
+	HealthInterval       string              `json:"health_interval"`
+	HealthMethod         string              `json:"health_method"`
+	HealthExpectedStatus []int               `json:"health_expected_status"`
+	HealthExpectedBody   string              `json:"health_expected_body"`
+	LatencySLA           string              `json:"latency_sla"`
+	Timeout              string              `json:"timeout"`
+	TTL                  string              `json:"ttl"`
+	Headers              map[string]string   `json:"headers"`
+	Routes               []registrationRoute `json:"routes"`
+}
+
+func NewRegistrationHandler(serviceRegistry *registry.ServiceRegistry, manager *config.Manager, config models.RegistrationConfig) *RegistrationHandler {
+	return &RegistrationHandler{
+		registry: serviceRegistry,
+		manager:  manager,
+		config:   config,
+	}
+}
+
+func (h *RegistrationHandler) Register(group *gin.RouterGroup) {
+	group.Use(h.requireToken)
+	group.GET("", h.List)
+	group.POST("", h.Create)
+	group.PUT("/:name/heartbeat", h.Heartbeat)
+	group.DELETE("/:name", h.Delete)
+}
+
+// requireToken refuses calls without the shared token. Config validation
+// requires one, but without it every call is refused rather than allowed.
+func (h *RegistrationHandler) requireToken(c *gin.Context) {
+	provided := c.GetHeader(RegistrationTokenHeader)
+	if h.config.Token == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(h.config.Token)) != 1 {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": "Missing or invalid " + RegistrationTokenHeader + " header",
+		})
+		c.Abort()
+		return
+	}
+	c.Next()
+}
+
+func (h *RegistrationHandler) List(c *gin.Context) {
+	leases := h.registry.GetLeases()
+	c.JSON(http.StatusOK, gin.H{
+		"registrations": leases,
+		"total":         len(leases),
+	})
+}
+
+func (h *RegistrationHandler) Create(c *gin.Context) {
+	var req registrationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	service, routes, ttl, err := h.buildRegistration(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid registration",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	// Self-registration must not take over statically configured services
+	if _, exists := h.registry.GetService(service.Name); exists && !h.registry.HasLease(service.Name) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Service already exists",
+			"message": fmt.Sprintf("Service %s is statically configured and cannot be registered", service.Name),
+		})
+		return
+	}
+	if err := h.validate(service, routes); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid registration",
+			"message": err.Error(),
+		})
+		return
+	}
+	if conflicts := h.conflicts(service.Name, routes); len(conflicts) > 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":     "Route conflict",
+			"message":   fmt.Sprintf("Routes of %s overlap %s", service.Name, strings.Join(conflicts, ", ")),
+			"conflicts": conflicts,
+		})
+		return
+	}
+
+	lease := h.registry.RegisterWithLease(service, routes, ttl)
+	c.JSON(http.StatusCreated, gin.H{
+		"service":    lease.ServiceName,
+		"ttl":        lease.TTL.String(),
+		"expires_at": lease.ExpiresAt.Format(time.RFC3339),
+		"routes":     len(lease.Routes),
+	})
+}
+
+func (h *RegistrationHandler) Heartbeat(c *gin.Context) {
+	name := c.Param("name")
+
+	lease, exists := h.registry.Heartbeat(name)
+	if !exists {
+		// Tell the instance to register again, e.g. after a gateway restart
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Registration not found",
+			"message": fmt.Sprintf("No active registration for %s, register again", name),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"service":    lease.ServiceName,
+		"expires_at": lease.ExpiresAt.Format(time.RFC3339),
+	})
+}
+
+func (h *RegistrationHandler) Delete(c *gin.Context) {
+	name := c.Param("name")
+
+	if !h.registry.Deregister(name) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Registration not found",
+			"message": fmt.Sprintf("No active registration for %s", name),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"service":      name,
+		"deregistered": true,
+	})
+}
+
+// validate checks the service and its routes as those of the config file
+// are checked, against the services they will be registered with.
+func (h *RegistrationHandler) validate(service models.ServiceConfig, routes []models.RouteConfig) error {
+	candidate := *h.manager.GetConfig()
+	if err := config.ValidateService(service.Name, service, candidate.CircuitBreaker); err != nil {
+		return err
+	}
+	candidate.Services = h.registry.GetAllServices()
+	candidate.Services[service.Name] = service
+	for _, route := range routes {
+		if err := config.ValidateRoute("route "+route.Method+" "+route.Path, route, &candidate); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// conflicts lists the routes that the registration's routes overlap,
+// leaving out those of its own earlier registration, which they replace.
+func (h *RegistrationHandler) conflicts(name string, routes []models.RouteConfig) []string {
+	renewed := h.registry.HasLease(name)
+	var conflicts []string
+	for i, route := range routes {
+		for _, existing := range h.registry.RouteConflicts(route, nil) {
+			if renewed && existing.ServiceName == name {
+				continue
+			}
+			conflicts = append(conflicts, existing.Method+" "+existing.Path)
+		}
+		for _, other := range routes[:i] {
+			if registry.RoutesOverlap(route, other) {
+				conflicts = append(conflicts, other.Method+" "+other.Path)
+			}
+		}
+	}
+	return conflicts
+}
+
+func (h *RegistrationHandler) buildRegistration(req registrationRequest) (models.ServiceConfig, []models.RouteConfig, time.Duration, error) {
+	parsed, err := url.Parse(req.URL)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return models.ServiceConfig{}, nil, 0, fmt.Errorf("url must be an absolute http(s) URL")
+	}
+
+	timeout := 30 * time.Second
+	if req.Timeout != "" {
+		if timeout, err = time.ParseDuration(req.Timeout); err != nil || timeout <= 0 {
+			return models.ServiceConfig{}, nil, 0, fmt.Errorf("invalid timeout: %s", req.Timeout)
+		}
+	}
+
+	ttl := h.config.DefaultTTL
+	if req.TTL != "" {
+		if ttl, err = time.ParseDuration(req.TTL); err != nil || ttl <= 0 {
+			return models.ServiceConfig{}, nil, 0, fmt.Errorf("invalid ttl: %s", req.TTL)
+		}
+	}
+	if ttl > h.config.MaxTTL {
+		ttl = h.config.MaxTTL
+	}
+
+	service := *models.NewServiceConfig(req.Name, req.URL, timeout)
+	if req.HealthPath != "" {
+		service.HealthPath = req.HealthPath
+	}
+	if req.HealthInterval != "" {
+		if service.HealthInterval, err = time.ParseDuration(req.HealthInterval); err != nil || service.HealthInterval < time.Second {
+			return models.ServiceConfig{}, nil, 0, fmt.Errorf("invalid health_interval: %s", req.HealthInterval)
+		}
+	}
+	if req.HealthMethod != "" {
+		service.HealthMethod = strings.ToUpper(req.HealthMethod)
+	}
+	service.HealthExpectedStatus = req.HealthExpectedStatus
+	service.HealthExpectedBody = req.HealthExpectedBody
+	if req.LatencySLA != "" {
+		if service.LatencySLA, err = time.ParseDuration(req.LatencySLA); err != nil || service.LatencySLA <= 0 {
+			return models.ServiceConfig{}, nil, 0, fmt.Errorf("invalid latency_sla: %s", req.LatencySLA)
+		}
+	}
+	for key, value := range req.Headers {
+		service.Headers[key] = value
+	}
+
+	routes := make([]models.RouteConfig, 0, len(req.Routes))
+	for _, r := range req.Routes {
+		route := models.NewRouteConfig(r.Path, req.Name)
+		if r.Method != "" {
+			route.Method = r.Method
+		}
+		route.StripPrefix = r.StripPrefix
+		route.AuthRequired = r.AuthRequired
+		switch r.AuthMode {
+		case "", models.AuthModeRequired, models.AuthModeOptional, models.AuthModeNone:
+			route.AuthMode = r.AuthMode
+		default:
+			return models.ServiceConfig{}, nil, 0, fmt.Errorf("invalid auth_mode: %s", r.AuthMode)
+		}
+		route.AuthProvider = r.AuthProvider
+		for key, value := range r.Headers {
+			route.Headers[key] = value
+		}
+		routes = append(routes, *route)
+	}
+
+	return service, routes, ttl, nil
+}
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"gateway/internal/circuit"
+	"gateway/internal/config"
+	"gateway/internal/maintenance"
+	"gateway/internal/models"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ResolveHandler tells how the gateway would handle a request, without
+// sending one: the route it matches, the service and upstream path it
+// goes to, and the policies applied on the way, for debugging routing
+// config.
+type ResolveHandler struct {
+	registry    *registry.ServiceRegistry
+	config      *models.GatewayConfig
+	breakers    *circuit.Breakers
+	maintenance *maintenance.Mode
+}
+
+// NewResolveHandler resolves requests against serviceRegistry. cfg is the
+// configuration the gateway runs with; breakers is nil when circuit
+// breakers are off.
+func NewResolveHandler(serviceRegistry *registry.ServiceRegistry, cfg *models.GatewayConfig, breakers *circuit.Breakers, mode *maintenance.Mode) *ResolveHandler {
+	return &ResolveHandler{registry: serviceRegistry, config: cfg, breakers: breakers, maintenance: mode}
+}
+
+func (h *ResolveHandler) Register(group *gin.RouterGroup) {
+	group.GET("/resolve", h.Resolve)
+}
+
+// Resolve resolves ?method=, GET by default, and ?path=. Migration aliases
+// are followed first, as the gateway does. skipped lists the routes whose
+// path covers the request but that were passed over, and why.
+func (h *ResolveHandler) Resolve(c *gin.Context) {
+	method := strings.ToUpper(c.DefaultQuery("method", http.MethodGet))
+	path := c.Query("path")
+	if !strings.HasPrefix(path, "/") {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad request",
+			"message": "path must be an absolute request path, like /api/orders/123",
+		})
+		return
+	}
+
+	response := gin.H{
+		"method":      method,
+		"path":        path,
+		"matched":     false,
+		"maintenance": h.maintenance.Status().Enabled,
+	}
+	if alias := h.registry.FindMigration(method, path); alias != nil {
+		target := alias.MigratedPath(path)
+		mode := alias.MigrationMode
+		if mode != models.MigrationRewrite {
+			mode = models.MigrationRedirect
+		}
+		response["migration"] = gin.H{
+			"from": alias.Path,
+			"to":   target,
+			"mode": mode,
+		}
+		if mode == models.MigrationRedirect {
+			// The client is redirected and asks again for the new path
+			c.JSON(http.StatusOK, response)
+			return
+		}
+		path = target
+	}
+
+	route, service, skipped := h.registry.ResolveRoute(method, path)
+	response["skipped"] = skipped
+	if route == nil {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	response["matched"] = true
+	response["route"] = config.Redact(*route)
+	response["policies"] = h.policies(route, service, path)
+	if service != nil {
+		upstreamPath := route.ExtractProxyPath(path)
+		traffic, _ := h.registry.Traffic(service.Name)
+		response["service"] = gin.H{
+			"name":    service.Name,
+			"url":     service.URL,
+			"status":  service.Status,
+			"traffic": traffic.State,
+		}
+		response["upstream"] = gin.H{
+			"path": upstreamPath,
+			"url":  strings.TrimSuffix(service.URL, "/") + upstreamPath,
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// policies describes what the gateway applies to requests to route on
+// their way to service, which is nil for composite routes.
+func (h *ResolveHandler) policies(route *models.RouteConfig, service *models.ServiceConfig, path string) gin.H {
+	authentication := gin.H{"mode": route.Auth()}
+	if route.Auth() != models.AuthModeNone {
+		if h.config.Auth.Skips(path) {
+			authentication["mode"] = models.AuthModeNone
+			authentication["skipped_by"] = "auth.skip_paths"
+		} else if route.AuthProvider != "" {
+			authentication["provider"] = route.AuthProvider
+		}
+	}
+	if route.ClientCert != nil {
+		authentication["client_cert"] = config.Redact(route.ClientCert)
+	}
+	if route.OIDCLogin {
+		authentication["oidc_login"] = true
+	}
+	if route.Signature != nil {
+		authentication["signature"] = true
+	}
+
+	rateLimit := gin.H{"enabled": h.config.RateLimit.Enabled}
+	if h.config.RateLimit.Enabled {
+		rateLimit["scope"] = h.config.RateLimit.Scope
+		rateLimit["requests"] = h.config.RateLimit.Requests
+		rateLimit["window"] = h.config.RateLimit.Window.String()
+		rateLimit["cost"] = route.TokenCost()
+	}
+	if route.RateLimit != nil {
+		rateLimit["route"] = config.Redact(route.RateLimit)
+	}
+	if h.config.Plans.Enabled {
+		rateLimit["plans"] = true
+	}
+	if h.config.Quota.Enabled {
+		rateLimit["quota_header"] = h.config.Quota.Header
+	}
+
+	policies := gin.H{
+		"auth":       authentication,
+		"rate_limit": rateLimit,
+	}
+	ipAccess := gin.H{}
+	if len(h.config.IPAccess.Allow) > 0 || len(h.config.IPAccess.Deny) > 0 {
+		ipAccess["gateway"] = h.config.IPAccess
+	}
+	if route.IPAccess != nil {
+		ipAccess["route"] = route.IPAccess
+	}
+	if len(ipAccess) > 0 {
+		policies["ip_access"] = ipAccess
+	}
+	if cors := route.CORS.Apply(h.config.CORS); cors.Enabled {
+		policies["cors"] = cors
+	}
+	if route.GeoAccess != nil {
+		policies["geo_access"] = route.GeoAccess
+	}
+	if route.Timeout > 0 {
+		policies["timeout"] = route.Timeout.String()
+	}
+	if route.IsComposite() {
+		policies["composite"] = config.Redact(route.Composite)
+		return policies
+	}
+
+	breaker := gin.H{"enabled": h.breakers != nil}
+	if h.breakers != nil {
+		circuitRoute, settings := h.breakers.SettingsFor(service, route)
+		breaker["settings"] = config.Redact(settings)
+		breaker["state"] = models.CircuitClosed
+		if circuitRoute != "" {
+			breaker["route"] = circuitRoute
+		}
+		if status, exists := h.breakers.Circuit(service.Name, circuitRoute); exists {
+			breaker["state"] = status.State
+		}
+		if route.Fallback != nil {
+			breaker["fallback"] = config.Redact(route.Fallback)
+		}
+	}
+	policies["circuit_breaker"] = breaker
+	policies["service_timeout"] = service.Timeout.String()
+	if h.config.Cache.Enabled && route.CacheTTL > 0 {
+		policies["cache_ttl"] = route.CacheTTL.String()
+	}
+	if route.Canary != nil {
+		policies["canary"] = config.Redact(route.Canary)
+	}
+	return policies
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"net/http"
+
+	"gateway/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RevocationsHandler shows the token denylist and lets admins pull it
+// right away instead of waiting for the next interval.
+type RevocationsHandler struct {
+	denylist *auth.Denylist
+}
+
+func NewRevocationsHandler(denylist *auth.Denylist) *RevocationsHandler {
+	return &RevocationsHandler{denylist: denylist}
+}
+
+func (h *RevocationsHandler) Register(group *gin.RouterGroup) {
+	group.GET("", h.Stats)
+	group.POST("/refresh", h.Refresh)
+}
+
+func (h *RevocationsHandler) Stats(c *gin.Context) {
+	c.JSON(http.StatusOK, h.denylist.Stats())
+}
+
+func (h *RevocationsHandler) Refresh(c *gin.Context) {
+	if err := h.denylist.Refresh(c.Request.Context()); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error":   "Refresh failed",
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, h.denylist.Stats())
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"gateway/internal/audit"
+	"gateway/internal/config"
+	"gateway/internal/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RoutesHandler adds, replaces and removes routes of the running gateway.
+// Routes of the config file, etcd and self-registration are managed there
+// and left alone.
+type RoutesHandler struct {
+	runtime *store.RuntimeRouting
+}
+
+func NewRoutesHandler(runtime *store.RuntimeRouting) *RoutesHandler {
+	return &RoutesHandler{runtime: runtime}
+}
+
+func (h *RoutesHandler) Register(group *gin.RouterGroup) {
+	group.POST("", h.Create)
+	group.PUT("", h.Replace)
+	group.DELETE("", h.Delete)
+}
+
+// Create adds a route, defined by the body in YAML or JSON as in the
+// config file. It fails with 409 when the route overlaps another.
+func (h *RoutesHandler) Create(c *gin.Context) {
+	definition, ok := readDefinition(c)
+	if !ok {
+		return
+	}
+	route, err := h.runtime.CreateRoute(definition)
+	if err != nil {
+		routeError(c, err)
+		return
+	}
+	audit.Change(c, nil, config.Redact(route))
+	c.JSON(http.StatusCreated, gin.H{
+		"route":   config.Redact(route),
+		"created": true,
+	})
+}
+
+// Replace adds a route or replaces the one added through the admin API
+// with the same method and path in a single step.
+func (h *RoutesHandler) Replace(c *gin.Context) {
+	definition, ok := readDefinition(c)
+	if !ok {
+		return
+	}
+	previous, route, err := h.runtime.PutRoute(definition)
+	if err != nil {
+		routeError(c, err)
+		return
+	}
+	status := http.StatusCreated
+	if previous != nil {
+		audit.Change(c, config.Redact(*previous), config.Redact(route))
+		status = http.StatusOK
+	} else {
+		audit.Change(c, nil, config.Redact(route))
+	}
+	c.JSON(status, gin.H{
+		"route":   config.Redact(route),
+		"created": previous == nil,
+	})
+}
+
+// Delete removes the route added through the admin API with the method,
+// "*" by default, and path of the query.
+func (h *RoutesHandler) Delete(c *gin.Context) {
+	path := c.Query("path")
+	if path == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad request",
+			"message": "path is required",
+		})
+		return
+	}
+	route, err := h.runtime.DeleteRoute(c.Query("method"), path)
+	if err != nil {
+		routeError(c, err)
+		return
+	}
+	audit.Change(c, config.Redact(route), nil)
+	c.JSON(http.StatusOK, gin.H{
+		"method":  route.Method,
+		"path":    route.Path,
+		"deleted": true,
+	})
+}
+
+func routeError(c *gin.Context, err error) {
+	var conflict *store.RouteConflictError
+	switch {
+	case errors.As(err, &conflict):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":     "Route conflict",
+			"message":   err.Error(),
+			"conflicts": conflict.Conflicts,
+		})
+	case errors.Is(err, store.ErrInvalidRoute):
+		response := gin.H{
+			"error":   "Invalid route",
+			"message": err.Error(),
+		}
+		var schemaErr *config.SchemaError
+		if errors.As(err, &schemaErr) {
+			response["violations"] = schemaErr.Violations
+		}
+		c.JSON(http.StatusBadRequest, response)
+	case errors.Is(err, store.ErrRouteNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Route not found",
+			"message": err.Error(),
+		})
+	case errors.Is(err, store.ErrNotRuntime):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Route not managed here",
+			"message": "The route comes from the config file, etcd or self-registration; change it there",
+		})
+	case errors.Is(err, store.ErrRoutingRefused):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Conflict",
+			"message": err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal server error",
+			"message": fmt.Sprintf("failed to change routes: %v", err),
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"gateway/internal/audit"
+	"gateway/internal/config"
+	"gateway/internal/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxServiceDefinitionSize bounds the service definitions accepted.
+const maxServiceDefinitionSize = 64 << 10
+
+// ServicesHandler adds, replaces and removes services of the running
+// gateway. Services of the config file, etcd and self-registration are
+// managed there and left alone.
+type ServicesHandler struct {
+	runtime *store.RuntimeRouting
+}
+
+func NewServicesHandler(runtime *store.RuntimeRouting) *ServicesHandler {
+	return &ServicesHandler{runtime: runtime}
+}
+
+func (h *ServicesHandler) Register(group *gin.RouterGroup) {
+	group.POST("/:name", h.Create)
+	group.PUT("/:name", h.Replace)
+	group.DELETE("/:name", h.Delete)
+}
+
+// Create adds a service, defined by the body in YAML or JSON as in the
+// config file.
+func (h *ServicesHandler) Create(c *gin.Context) {
+	definition, ok := readDefinition(c)
+	if !ok {
+		return
+	}
+	name := c.Param("name")
+	service, err := h.runtime.Create(name, definition)
+	if err != nil {
+		serviceError(c, name, err)
+		return
+	}
+	audit.Change(c, nil, config.Redact(service))
+	c.JSON(http.StatusCreated, gin.H{
+		"service": config.Redact(service),
+		"created": true,
+	})
+}
+
+// Replace adds a service or replaces one added through the admin API. Its
+// health is kept unless its URL changed.
+func (h *ServicesHandler) Replace(c *gin.Context) {
+	definition, ok := readDefinition(c)
+	if !ok {
+		return
+	}
+	name := c.Param("name")
+	previous, service, err := h.runtime.Put(name, definition)
+	if err != nil {
+		serviceError(c, name, err)
+		return
+	}
+	status := http.StatusCreated
+	if previous != nil {
+		audit.Change(c, config.Redact(*previous), config.Redact(service))
+		status = http.StatusOK
+	} else {
+		audit.Change(c, nil, config.Redact(service))
+	}
+	c.JSON(status, gin.H{
+		"service": config.Redact(service),
+		"created": previous == nil,
+	})
+}
+
+// Delete removes a service added through the admin API. Routes must stop
+// using it first.
+func (h *ServicesHandler) Delete(c *gin.Context) {
+	name := c.Param("name")
+	service, err := h.runtime.Delete(name)
+	if err != nil {
+		serviceError(c, name, err)
+		return
+	}
+	audit.Change(c, config.Redact(service), nil)
+	c.JSON(http.StatusOK, gin.H{
+		"service": name,
+		"deleted": true,
+	})
+}
+
+func readDefinition(c *gin.Context) ([]byte, bool) {
+	definition, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxServiceDefinitionSize))
+	if err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error":   "Request entity too large",
+			"message": fmt.Sprintf("definition must not exceed %d bytes", maxServiceDefinitionSize),
+		})
+		return nil, false
+	}
+	return definition, true
+}
+
+func serviceError(c *gin.Context, name string, err error) {
+	switch {
+	case errors.Is(err, store.ErrInvalidService):
+		response := gin.H{
+			"error":   "Invalid service",
+			"message": err.Error(),
+		}
+		var schemaErr *config.SchemaError
+		if errors.As(err, &schemaErr) {
+			response["violations"] = schemaErr.Violations
+		}
+		c.JSON(http.StatusBadRequest, response)
+	case errors.Is(err, store.ErrServiceNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Service not found",
+			"message": fmt.Sprintf("No service %s", name),
+		})
+	case errors.Is(err, store.ErrServiceExists):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Service already exists",
+			"message": fmt.Sprintf("Service %s already exists; use PUT to replace a service added through this API", name),
+		})
+	case errors.Is(err, store.ErrNotRuntime):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Service not managed here",
+			"message": fmt.Sprintf("Service %s comes from the config file, etcd or self-registration; change it there", name),
+		})
+	case errors.Is(err, store.ErrRoutingRefused):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Conflict",
+			"message": err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal server error",
+			"message": err.Error(),
+		})
+	}
+}
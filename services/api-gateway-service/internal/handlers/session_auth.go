@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"gateway/internal/auth"
+	"gateway/internal/i18n"
+	"gateway/internal/session"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SessionAuthHandler logs server-rendered frontends in with a session
+// cookie that maps to the user's identity. Unlike BFF sessions these hold
+// no tokens: the session itself is the credential.
+type SessionAuthHandler struct {
+	sessions *session.Manager
+	client   *auth.Client
+}
+
+func NewSessionAuthHandler(sessions *session.Manager, client *auth.Client) *SessionAuthHandler {
+	return &SessionAuthHandler{
+		sessions: sessions,
+		client:   client,
+	}
+}
+
+func (h *SessionAuthHandler) Register(group *gin.RouterGroup) {
+	group.POST("/login", h.Login)
+	group.POST("/logout", h.Logout)
+	group.GET("", h.Session)
+}
+
+func (h *SessionAuthHandler) Login(c *gin.Context) {
+	var req loginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		i18n.Error(c, http.StatusBadRequest, "Invalid request", i18n.LoginInvalidRequest)
+		return
+	}
+
+	ctx := c.Request.Context()
+	tokens, err := h.client.Login(ctx, req.Email, req.Password)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidCredentials) {
+			i18n.Error(c, http.StatusUnauthorized, "Invalid credentials", i18n.LoginInvalid)
+			return
+		}
+		log.Printf("Session login failed: %v", err)
+		i18n.Error(c, http.StatusBadGateway, "Auth service unavailable", i18n.LoginUnavailable)
+		return
+	}
+	identity, err := h.client.Verify(ctx, tokens.AccessToken)
+	if err != nil {
+		log.Printf("Session login could not verify the issued token: %v", err)
+		i18n.Error(c, http.StatusBadGateway, "Auth service unavailable", i18n.LoginUnavailable)
+		return
+	}
+	// The tokens are not kept, so they should not outlive the login
+	if err := h.client.Logout(ctx, tokens.AccessToken, tokens.RefreshToken); err != nil {
+		log.Printf("Session login could not revoke the issued tokens: %v", err)
+	}
+
+	sess, err := h.sessions.New()
+	if err != nil {
+		i18n.Error(c, http.StatusInternalServerError, "Session error", i18n.SessionCreateFailed)
+		return
+	}
+	sess.UserID = identity.UserID
+	sess.Set(session.ValueEmail, identity.Email)
+	sess.Set(session.ValueName, identity.Name)
+	sess.Set(session.ValueScopes, strings.Join(identity.Scopes, " "))
+	sess.Set(session.ValueSource, session.SourceSessionLogin)
+
+	if err := h.sessions.Save(c.Writer, c.Request, sess); err != nil {
+		log.Printf("Session login failed to store session: %v", err)
+		i18n.Error(c, http.StatusInternalServerError, "Session error", i18n.SessionStoreFailed)
+		return
+	}
+	if evicted, err := h.sessions.EnforceUserLimit(ctx, sess); err != nil {
+		log.Printf("Session login failed to enforce session limit: %v", err)
+	} else if evicted > 0 {
+		log.Printf("Session login ended %d older session(s) of user %s", evicted, sess.UserID)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"authenticated": true,
+		"user_id":       sess.UserID,
+		"expires_at":    sess.ExpiresAt.Format(time.RFC3339),
+	})
+}
+
+func (h *SessionAuthHandler) Logout(c *gin.Context) {
+	if err := h.sessions.Destroy(c.Writer, c.Request); err != nil {
+		log.Printf("Session logout failed to delete session: %v", err)
+	}
+	c.JSON(http.StatusOK, gin.H{"authenticated": false})
+}
+
+// Session tells the frontend who is logged in.
+func (h *SessionAuthHandler) Session(c *gin.Context) {
+	sess, err := h.sessions.Load(c.Request)
+	if err != nil || sess.UserID == "" {
+		c.JSON(http.StatusOK, gin.H{"authenticated": false})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"authenticated": true,
+		"user_id":       sess.UserID,
+		"email":         sess.Get(session.ValueEmail),
+		"name":          sess.Get(session.ValueName),
+		"expires_at":    sess.ExpiresAt.Format(time.RFC3339),
+	})
+}
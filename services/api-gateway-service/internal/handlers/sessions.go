@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"gateway/internal/session"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SessionHandler exposes session listing and invalidation to admins.
+// Sessions are addressed by handle, never by their ID.
+type SessionHandler struct {
+	manager *session.Manager
+}
+
+func NewSessionHandler(manager *session.Manager) *SessionHandler {
+	return &SessionHandler{manager: manager}
+}
+
+func (h *SessionHandler) Register(group *gin.RouterGroup) {
+	group.GET("", h.List)
+	group.DELETE("", h.InvalidateUser)
+	group.DELETE("/:handle", h.Invalidate)
+}
+
+func (h *SessionHandler) List(c *gin.Context) {
+	sessions, err := h.manager.List(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error":   "Session store unavailable",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	userID := c.Query("user_id")
+	result := make([]gin.H, 0, len(sessions))
+	for _, sess := range sessions {
+		if userID != "" && sess.UserID != userID {
+			continue
+		}
+		result = append(result, gin.H{
+			"handle":     sess.Handle(),
+			"user_id":    sess.UserID,
+			"created_at": sess.CreatedAt.Format(time.RFC3339),
+			"expires_at": sess.ExpiresAt.Format(time.RFC3339),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"sessions": result,
+		"total":    len(result),
+	})
+}
+
+func (h *SessionHandler) Invalidate(c *gin.Context) {
+	handle := c.Param("handle")
+
+	sessions, err := h.manager.List(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error":   "Session store unavailable",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	for _, sess := range sessions {
+		if sess.Handle() == handle {
+			if err := h.manager.Invalidate(c.Request.Context(), sess.ID); err != nil {
+				c.JSON(http.StatusBadGateway, gin.H{
+					"error":   "Session store unavailable",
+					"message": err.Error(),
+				})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"invalidated": 1})
+			return
+		}
+	}
+
+	c.JSON(http.StatusNotFound, gin.H{
+		"error":   "Session not found",
+		"message": "No live session with handle " + handle,
+	})
+}
+
+// InvalidateUser ends every session of the user given in ?user_id=.
+func (h *SessionHandler) InvalidateUser(c *gin.Context) {
+	userID := c.Query("user_id")
+	if userID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": "user_id query parameter is required",
+		})
+		return
+	}
+
+	sessions, err := h.manager.List(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error":   "Session store unavailable",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	invalidated := 0
+	for _, sess := range sessions {
+		if sess.UserID == userID {
+			if err := h.manager.Invalidate(c.Request.Context(), sess.ID); err == nil {
+				invalidated++
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"invalidated": invalidated})
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"net/http"
+
+	"gateway/internal/slo"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SLOHandler reports how routes and services are doing against their
+// service level objectives.
+type SLOHandler struct {
+	tracker *slo.Tracker
+}
+
+func NewSLOHandler(tracker *slo.Tracker) *SLOHandler {
+	return &SLOHandler{tracker: tracker}
+}
+
+func (h *SLOHandler) Register(group *gin.RouterGroup) {
+	group.GET("", h.List)
+}
+
+// List returns every SLO, routes first. ?scope= keeps routes or services.
+func (h *SLOHandler) List(c *gin.Context) {
+	scope := c.Query("scope")
+	switch scope {
+	case "", slo.ScopeRoute, slo.ScopeService:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": "scope must be route or service",
+		})
+		return
+	}
+
+	statuses := make([]slo.Status, 0)
+	for _, status := range h.tracker.Statuses() {
+		if scope == "" || status.Scope == scope {
+			statuses = append(statuses, status)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"slos":  statuses,
+		"total": len(statuses),
+	})
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"gateway/internal/audit"
+	"gateway/internal/snapshot"
+	"gateway/internal/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxSnapshotSize bounds the snapshots accepted for import.
+const maxSnapshotSize = 16 << 20
+
+// SnapshotHandler exports the state of the gateway and imports it into
+// another, to move it between environments or restore it after a loss.
+type SnapshotHandler struct {
+	gateway *snapshot.Gateway
+}
+
+func NewSnapshotHandler(gateway *snapshot.Gateway) *SnapshotHandler {
+	return &SnapshotHandler{gateway: gateway}
+}
+
+func (h *SnapshotHandler) Register(group *gin.RouterGroup) {
+	group.GET("/export", h.Export)
+	group.POST("/import", h.Import)
+}
+
+// Export returns a snapshot, as an attachment with ?download=true.
+func (h *SnapshotHandler) Export(c *gin.Context) {
+	exported := h.gateway.Export()
+	if c.Query("download") == "true" {
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"gateway-%s.json\"", exported.ExportedAt.UTC().Format("20060102-150405")))
+	}
+	c.JSON(http.StatusOK, exported)
+}
+
+// Import applies the snapshot sent as the body. Services and routes that
+// are refused leave the gateway as it was; overrides that no longer apply
+// are reported as warnings.
+func (h *SnapshotHandler) Import(c *gin.Context) {
+	var imported snapshot.Snapshot
+	decoder := json.NewDecoder(http.MaxBytesReader(c.Writer, c.Request.Body, maxSnapshotSize))
+	if err := decoder.Decode(&imported); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"error":   "Request entity too large",
+				"message": fmt.Sprintf("snapshot must not exceed %d bytes", maxSnapshotSize),
+			})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad request",
+			"message": "send a snapshot from GET /gateway/export as the request body: " + err.Error(),
+		})
+		return
+	}
+
+	before := h.gateway.Export()
+	result, err := h.gateway.Import(imported)
+	switch {
+	case err == nil:
+	case errors.Is(err, snapshot.ErrVersion):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad request",
+			"message": err.Error(),
+		})
+		return
+	case errors.Is(err, store.ErrInvalidService):
+		serviceError(c, "", err)
+		return
+	case errors.Is(err, store.ErrNotRuntime):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Service not managed here",
+			"message": err.Error(),
+		})
+		return
+	default:
+		routeError(c, err)
+		return
+	}
+	audit.Change(c, importedState(before), importedState(h.gateway.Export()))
+	c.JSON(http.StatusOK, result)
+}
+
+// importedState is the part of a snapshot an import changes, for the
+// audit log. Services and routes are named rather than recorded, as their
+// definitions may carry credentials.
+func importedState(s snapshot.Snapshot) gin.H {
+	services := make([]string, 0, len(s.Services))
+	for name := range s.Services {
+		services = append(services, name)
+	}
+	sort.Strings(services)
+	routes := make([]string, 0, len(s.Routes))
+	for _, definition := range s.Routes {
+		if route, ok := definition.(map[string]interface{}); ok {
+			method, _ := route["method"].(string)
+			if method == "" {
+				method = "*"
+			}
+			path, _ := route["path"].(string)
+			routes = append(routes, strings.ToUpper(method)+" "+path)
+		}
+	}
+	return gin.H{
+		"services":  services,
+		"routes":    routes,
+		"overrides": s.Overrides,
+	}
+}
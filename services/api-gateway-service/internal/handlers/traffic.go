@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"gateway/internal/audit"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxDrainWait bounds how long a drain request waits for the service to
+// finish its requests in flight.
+const maxDrainWait = 5 * time.Minute
+
+// TrafficHandler takes services out of traffic and puts them back, for
+// backend deploys: disabled services get no new requests right away,
+// draining ones neither, and report once their requests in flight are
+// done.
+type TrafficHandler struct {
+	registry *registry.ServiceRegistry
+}
+
+func NewTrafficHandler(serviceRegistry *registry.ServiceRegistry) *TrafficHandler {
+	return &TrafficHandler{registry: serviceRegistry}
+}
+
+func (h *TrafficHandler) Register(group *gin.RouterGroup) {
+	group.GET("/:name/traffic", h.Show)
+	group.POST("/:name/disable", h.Disable)
+	group.POST("/:name/drain", h.Drain)
+	group.POST("/:name/enable", h.Enable)
+}
+
+// Show reports whether the service takes new requests and how many it has
+// in flight.
+func (h *TrafficHandler) Show(c *gin.Context) {
+	status, ok := h.registry.Traffic(c.Param("name"))
+	if !ok {
+		h.notFound(c)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+func (h *TrafficHandler) Disable(c *gin.Context) {
+	h.change(c, h.registry.DisableService)
+}
+
+func (h *TrafficHandler) Enable(c *gin.Context) {
+	h.change(c, h.registry.EnableService)
+}
+
+// Drain stops new requests to the service. With ?wait= it answers once the
+// requests in flight are done, with 200, or when the wait is over with 202
+// and the service still draining; without, it answers right away.
+func (h *TrafficHandler) Drain(c *gin.Context) {
+	var wait time.Duration
+	if value := c.Query("wait"); value != "" {
+		var err error
+		if wait, err = time.ParseDuration(value); err != nil || wait < 0 || wait > maxDrainWait {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Bad request",
+				"message": fmt.Sprintf("wait must be a duration up to %s, like \"30s\"", maxDrainWait),
+			})
+			return
+		}
+	}
+
+	before, ok := h.registry.Traffic(c.Param("name"))
+	if !ok {
+		h.notFound(c)
+		return
+	}
+	status, ok := h.registry.DrainService(c.Param("name"))
+	if !ok {
+		h.notFound(c)
+		return
+	}
+	audit.Change(c, before, status)
+
+	if wait > 0 && status.State == registry.TrafficDraining {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), wait)
+		defer cancel()
+		h.registry.WaitIdle(ctx, status.Service)
+		status, _ = h.registry.Traffic(status.Service)
+	}
+	code := http.StatusOK
+	if status.State == registry.TrafficDraining {
+		code = http.StatusAccepted
+	}
+	c.JSON(code, status)
+}
+
+func (h *TrafficHandler) change(c *gin.Context, apply func(name string) (registry.TrafficStatus, bool)) {
+	before, ok := h.registry.Traffic(c.Param("name"))
+	if !ok {
+		h.notFound(c)
+		return
+	}
+	status, ok := apply(c.Param("name"))
+	if !ok {
+		h.notFound(c)
+		return
+	}
+	audit.Change(c, before, status)
+	c.JSON(http.StatusOK, status)
+}
+
+func (h *TrafficHandler) notFound(c *gin.Context) {
+	c.JSON(http.StatusNotFound, gin.H{
+		"error":   "Service not found",
+		"message": fmt.Sprintf("No service %s", c.Param("name")),
+	})
+}
@@ -0,0 +1,56 @@
+package i18n
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Catalog supplies translated message templates. Templates use fmt verbs;
+// translations may reorder arguments with explicit indexes such as %[2]s.
+type Catalog interface {
+	Locales() []string
+	Lookup(locale, key string) (string, bool)
+}
+
+// MapCatalog is an in-memory catalog keyed by locale, then message key.
+type MapCatalog map[string]map[string]string
+
+func (m MapCatalog) Locales() []string {
+	locales := make([]string, 0, len(m))
+	for locale := range m {
+		locales = append(locales, locale)
+	}
+	return locales
+}
+
+func (m MapCatalog) Lookup(locale, key string) (string, bool) {
+	message, found := m[locale][key]
+	return message, found
+}
+
+// LoadDir reads a catalog from a directory of <locale>.json files, each a
+// flat object of message keys to templates.
+func LoadDir(dir string) (MapCatalog, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	catalog := make(MapCatalog)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var messages map[string]string
+		if err := json.Unmarshal(data, &messages); err != nil {
+			return nil, fmt.Errorf("invalid message catalog %s: %w", file, err)
+		}
+		locale := strings.ToLower(strings.TrimSuffix(filepath.Base(file), ".json"))
+		catalog[locale] = messages
+	}
+	return catalog, nil
+}
@@ -0,0 +1,126 @@
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gateway/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const localizerKey = "i18n.localizer"
+
+// Localizer renders gateway error messages in the locale a client asks for
+// through Accept-Language, falling back to the built-in English messages.
+type Localizer struct {
+	catalog       Catalog
+	defaultLocale string
+	supported     map[string]bool
+}
+
+// NewLocalizer serves the locales of catalog, plus English. defaultLocale
+// is used when the client accepts none of them.
+func NewLocalizer(catalog Catalog, defaultLocale string) *Localizer {
+	if catalog == nil {
+		catalog = MapCatalog{}
+	}
+	supported := map[string]bool{DefaultLocale: true}
+	for _, locale := range catalog.Locales() {
+		supported[strings.ToLower(locale)] = true
+	}
+	if defaultLocale = strings.ToLower(defaultLocale); !supported[defaultLocale] {
+		defaultLocale = DefaultLocale
+	}
+
+	return &Localizer{
+		catalog:       catalog,
+		defaultLocale: defaultLocale,
+		supported:     supported,
+	}
+}
+
+// Negotiate picks the best supported locale for an Accept-Language header.
+// A regional tag such as fr-CA falls back to its base language.
+func (l *Localizer) Negotiate(acceptLanguage string) string {
+	type weighted struct {
+		tag     string
+		quality float64
+	}
+
+	var tags []weighted
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		quality := 1.0
+		if q, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			if parsed, err := strconv.ParseFloat(q, 64); err == nil {
+				quality = parsed
+			}
+		}
+		if tag != "" && quality > 0 {
+			tags = append(tags, weighted{strings.ToLower(tag), quality})
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].quality > tags[j].quality })
+
+	for _, candidate := range tags {
+		if l.supported[candidate.tag] {
+			return candidate.tag
+		}
+		if base, _, found := strings.Cut(candidate.tag, "-"); found && l.supported[base] {
+			return base
+		}
+	}
+	return l.defaultLocale
+}
+
+// Message renders key in locale and returns it with the locale it is
+// actually in, which is English when the catalog lacks the translation.
+func (l *Localizer) Message(locale, key string, args ...interface{}) (string, string) {
+	template, found := l.catalog.Lookup(locale, key)
+	if !found {
+		template, locale = defaultMessages[key], DefaultLocale
+	}
+	return fmt.Sprintf(template, args...), locale
+}
+
+// Middleware makes the localizer available to Error for the rest of the
+// request.
+func Middleware(localizer *Localizer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(localizerKey, localizer)
+		c.Next()
+	}
+}
+
+// Error writes the gateway's error body with the message for key rendered
+// in the client's locale. Without the middleware, messages are in English.
+// The body carries the request's correlation ID, so a client can quote it
+// when reporting the error.
+func Error(c *gin.Context, status int, title, key string, args ...interface{}) {
+	ErrorWith(c, status, title, nil, key, args...)
+}
+
+// ErrorWith is Error with extra machine-readable fields in the body.
+func ErrorWith(c *gin.Context, status int, title string, fields gin.H, key string, args ...interface{}) {
+	message, locale := fmt.Sprintf(defaultMessages[key], args...), DefaultLocale
+	if value, exists := c.Get(localizerKey); exists {
+		localizer := value.(*Localizer)
+		message, locale = localizer.Message(localizer.Negotiate(c.GetHeader("Accept-Language")), key, args...)
+	}
+	c.Header("Content-Language", locale)
+
+	body := gin.H{
+		"error":   title,
+		"message": message,
+	}
+	if id := c.GetHeader(models.CorrelationIDHeader); id != "" {
+		body["correlation_id"] = id
+	}
+	for name, value := range fields {
+		body[name] = value
+	}
+	c.JSON(status, body)
+}
@@ -0,0 +1,78 @@
+package i18n
+
+// Keys of gateway error messages shown to API clients.
+const (
+	RouteNotFound        = "route_not_found"
+	ServiceMisconfigured = "service_misconfigured"
+	ServiceTimeout       = "service_timeout"
+	BudgetExceeded       = "budget_exceeded"
+	RouteTimeout         = "route_timeout"
+	ServiceUnavailable   = "service_unavailable"
+	ServiceAtCapacity    = "service_at_capacity"
+	CircuitOpen          = "circuit_open"
+	RateLimited          = "rate_limited"
+	QuotaExhausted       = "quota_exhausted"
+	MissingToken         = "missing_token"
+	InvalidToken         = "invalid_token"
+	TokenForbidden       = "token_forbidden"
+	TokenRevoked         = "token_revoked"
+	AuthUnavailable      = "auth_unavailable"
+	CSRFHeaderMissing    = "csrf_header_missing"
+	LoginInvalidRequest  = "login_invalid_request"
+	LoginInvalid         = "login_invalid_credentials"
+	LoginUnavailable     = "login_unavailable"
+	SessionCreateFailed  = "session_create_failed"
+	SessionStoreFailed   = "session_store_failed"
+	LoginRequired        = "login_required"
+	LoginExpired         = "login_expired"
+	LoginDenied          = "login_denied"
+	SignatureInvalid     = "signature_invalid"
+	ClientCertRequired   = "client_cert_required"
+	ClientCertForbidden  = "client_cert_forbidden"
+	WebSocketLimit       = "websocket_limit"
+	Maintenance          = "maintenance"
+	IPDenied             = "ip_denied"
+	GeoDenied            = "geo_denied"
+	HeadersTooLarge      = "headers_too_large"
+	URLTooLong           = "url_too_long"
+)
+
+// DefaultLocale is the locale of the built-in messages, used whenever a
+// catalog has no translation.
+const DefaultLocale = "en"
+
+var defaultMessages = map[string]string{
+	RouteNotFound:        "No route found for %s %s",
+	ServiceMisconfigured: "Service %s is misconfigured",
+	ServiceTimeout:       "Service %s did not respond within %s",
+	BudgetExceeded:       "Service %s did not respond within the requested %s",
+	RouteTimeout:         "Route %s did not complete within %s",
+	ServiceUnavailable:   "Service %s is unavailable",
+	ServiceAtCapacity:    "Service %s is handling too many requests, retry shortly",
+	CircuitOpen:          "Service %s is failing, retry after %d seconds",
+	RateLimited:          "Too many requests, retry after %d seconds",
+	QuotaExhausted:       "The %s quota of %d requests for this API key is used up",
+	MissingToken:         "Missing or malformed bearer token",
+	InvalidToken:         "Invalid or expired token",
+	TokenForbidden:       "Token is not allowed to access this resource",
+	TokenRevoked:         "Token has been revoked",
+	AuthUnavailable:      "Unable to verify credentials",
+	CSRFHeaderMissing:    "Missing %s header on session-authenticated request",
+	LoginInvalidRequest:  "email and password are required",
+	LoginInvalid:         "Email or password is incorrect",
+	LoginUnavailable:     "Unable to complete login",
+	SessionCreateFailed:  "Unable to create session",
+	SessionStoreFailed:   "Unable to store session",
+	LoginRequired:        "Log in to access this resource",
+	LoginExpired:         "The login attempt expired, please try again",
+	LoginDenied:          "The identity provider did not complete the login",
+	SignatureInvalid:     "Missing or invalid %s signature",
+	ClientCertRequired:   "A client certificate is required for this resource",
+	ClientCertForbidden:  "The client certificate is not authorized for this resource",
+	WebSocketLimit:       "Too many open WebSocket connections, try again later",
+	Maintenance:          "The service is down for maintenance, try again later",
+	IPDenied:             "Access from your network address is not allowed",
+	GeoDenied:            "Access from your country is not allowed",
+	HeadersTooLarge:      "The request headers are too large",
+	URLTooLong:           "The request URL is too long",
+}
@@ -0,0 +1,144 @@
+package ipaccess
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+
+	"gateway/internal/models"
+)
+
+// ParseNetworks parses addresses and CIDR ranges. An address stands for
+// itself alone.
+func ParseNetworks(entries []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if strings.Contains(entry, "/") {
+			_, network, err := net.ParseCIDR(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CIDR range %q", entry)
+			}
+			networks = append(networks, network)
+			continue
+		}
+		ip := net.ParseIP(entry)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address %q", entry)
+		}
+		bits := 8 * net.IPv4len
+		if ip.To4() == nil {
+			bits = 8 * net.IPv6len
+		}
+		networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+	}
+	return networks, nil
+}
+
+// Lists are the allow and deny lists of an ip_access config. Deny wins:
+// a client in it is refused, and with an allow list so is one outside it.
+type Lists struct {
+	allow []*net.IPNet
+	deny  []*net.IPNet
+}
+
+func New(config models.IPAccessConfig) (*Lists, error) {
+	allow, err := ParseNetworks(config.Allow)
+	if err != nil {
+		return nil, fmt.Errorf("allow: %w", err)
+	}
+	deny, err := ParseNetworks(config.Deny)
+	if err != nil {
+		return nil, fmt.Errorf("deny: %w", err)
+	}
+	return &Lists{allow: allow, deny: deny}, nil
+}
+
+// Empty reports whether the lists let every client through.
+func (l *Lists) Empty() bool {
+	return len(l.allow) == 0 && len(l.deny) == 0
+}
+
+// Check tells whether the client at clientIP may pass, and if not why.
+func (l *Lists) Check(clientIP string) (string, bool) {
+	ip := net.ParseIP(clientIP)
+	if ip == nil {
+		if len(l.allow) > 0 {
+			return fmt.Sprintf("client address %q is not an IP address", clientIP), false
+		}
+		return "", true
+	}
+	for _, network := range l.deny {
+		if network.Contains(ip) {
+			return fmt.Sprintf("%s is in deny list entry %s", clientIP, network), false
+		}
+	}
+	if len(l.allow) == 0 {
+		return "", true
+	}
+	for _, network := range l.allow {
+		if network.Contains(ip) {
+			return "", true
+		}
+	}
+	return fmt.Sprintf("%s is not in the allow list", clientIP), false
+}
+
+// Policies checks clients against the gateway's lists and those of the
+// route they ask for.
+type Policies struct {
+	global *Lists
+	// Lists of routes by path, rebuilt when a route's ip_access changes
+	routes sync.Map
+}
+
+type routeLists struct {
+	config models.IPAccessConfig
+	lists  *Lists
+}
+
+func NewPolicies(global models.IPAccessConfig) (*Policies, error) {
+	lists, err := New(global)
+	if err != nil {
+		return nil, err
+	}
+	return &Policies{global: lists}, nil
+}
+
+// Check tells whether the client at clientIP may send requests to route,
+// nil if none matched, and if not why. A route whose lists do not parse,
+// which validation prevents, refuses everyone.
+func (p *Policies) Check(clientIP string, route *models.RouteConfig) (string, bool) {
+	if reason, ok := p.global.Check(clientIP); !ok {
+		return reason, false
+	}
+	if route == nil || route.IPAccess == nil {
+		return "", true
+	}
+	lists, err := p.routeLists(route)
+	if err != nil {
+		log.Printf("Refusing requests to route %s, invalid ip_access: %v", route.Path, err)
+		return "route " + route.Path + " has invalid ip_access", false
+	}
+	if reason, ok := lists.Check(clientIP); !ok {
+		return reason + " of route " + route.Path, false
+	}
+	return "", true
+}
+
+// routeLists returns route's lists, replacing those of an earlier version
+// of the route whose ip_access differed.
+func (p *Policies) routeLists(route *models.RouteConfig) (*Lists, error) {
+	if entry, ok := p.routes.Load(route.Path); ok && reflect.DeepEqual(entry.(*routeLists).config, *route.IPAccess) {
+		return entry.(*routeLists).lists, nil
+	}
+	lists, err := New(*route.IPAccess)
+	if err != nil {
+		return nil, err
+	}
+	p.routes.Store(route.Path, &routeLists{config: *route.IPAccess, lists: lists})
+	return lists, nil
+}
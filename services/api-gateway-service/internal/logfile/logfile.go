@@ -0,0 +1,147 @@
+// Package logfile writes the gateway's logs to a file that is rotated once
+// it reaches a size limit, so a gateway on a VM can log to disk without an
+// external agent rotating its files.
+package logfile
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// DefaultMaxSize is the size in megabytes a file is rotated at when none
+// is configured.
+const DefaultMaxSize = 100
+
+// Writer appends to a file and rotates it before a write would take it
+// past its size limit. Rotated files are kept next to it as path.1 (the
+// newest) to path.N. Writes are not split, so a log line never straddles
+// two files.
+type Writer struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	mutex sync.Mutex
+	// file is nil after a rotation failed to open a new file, which is
+	// tried again on the next write
+	file   *os.File
+	size   int64
+	closed bool
+}
+
+// Open opens path for appending, creating it if needed. Files are rotated
+// at maxSize megabytes, or DefaultMaxSize if it is not positive, and
+// maxBackups rotated files are kept; zero keeps them all.
+func Open(path string, maxSize, maxBackups int) (*Writer, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+	w := &Writer{path: path, maxSize: int64(maxSize) << 20, maxBackups: maxBackups}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *Writer) open() error {
+	file, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("opening log file: %w", err)
+	}
+	w.file = file
+	w.size = info.Size()
+	return nil
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+	if w.file == nil {
+		if err := w.open(); err != nil {
+			return 0, err
+		}
+	}
+	// A write larger than the limit gets a file of its own
+	if w.size > 0 && w.size+int64(len(p)) > w.maxSize {
+		if err := w.rotate(); err != nil {
+			if w.file == nil {
+				return 0, err
+			}
+			// Keep logging to the unrotated file rather than losing lines
+			fmt.Fprintf(os.Stderr, "log rotation failed: %v\n", err)
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// rotate renames the current file to path.1, shifting older backups up
+// and removing those beyond maxBackups, and starts a new file.
+func (w *Writer) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	w.file = nil
+
+	highest := w.maxBackups
+	if highest == 0 {
+		highest = 1
+		for exists(w.backup(highest)) {
+			highest++
+		}
+	} else {
+		// Backups beyond the limit, such as after it was lowered, go too
+		for n := highest; exists(w.backup(n)); n++ {
+			os.Remove(w.backup(n))
+		}
+	}
+	var renameErr error
+	for n := highest - 1; n >= 1; n-- {
+		if exists(w.backup(n)) {
+			if err := os.Rename(w.backup(n), w.backup(n+1)); err != nil {
+				renameErr = err
+			}
+		}
+	}
+	if err := os.Rename(w.path, w.backup(1)); err != nil {
+		renameErr = err
+	}
+
+	if err := w.open(); err != nil {
+		return err
+	}
+	return renameErr
+}
+
+func (w *Writer) backup(n int) string {
+	return fmt.Sprintf("%s.%d", w.path, n)
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// Close closes the current file. Writes after Close fail.
+func (w *Writer) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.closed = true
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
@@ -0,0 +1,142 @@
+package logging
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultOverrideDuration is how long an override lasts when no duration
+// is asked for.
+const DefaultOverrideDuration = 15 * time.Minute
+
+// Overrides changes the access log level and turns on debug logging of
+// some routes on a live gateway, for a while: an override reverts on its
+// own when it expires, so debug logging is not left on by mistake.
+type Overrides struct {
+	mutex      sync.RWMutex
+	configured string
+
+	// The override in effect, if level or routes is set
+	level  string
+	routes map[string]bool
+	since  time.Time
+	until  time.Time
+	revert *time.Timer
+}
+
+// Status describes the logging overrides for the admin API.
+type Status struct {
+	Level           string     `json:"level"`
+	ConfiguredLevel string     `json:"configured_level"`
+	DebugRoutes     []string   `json:"debug_routes"`
+	Since           *time.Time `json:"since,omitempty"`
+	Until           *time.Time `json:"until,omitempty"`
+}
+
+// NewOverrides starts without overrides, logging at the configured level.
+func NewOverrides(configured string) *Overrides {
+	return &Overrides{configured: configured}
+}
+
+// Set replaces the override in effect with level, or the configured level
+// if empty, and debug logging of routes, until until.
+func (o *Overrides) Set(level string, routes []string, until time.Time) Status {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if o.revert != nil {
+		o.revert.Stop()
+	}
+	o.level = level
+	o.routes = make(map[string]bool, len(routes))
+	for _, route := range routes {
+		o.routes[route] = true
+	}
+	o.since = time.Now()
+	o.until = until
+
+	var revert *time.Timer
+	revert = time.AfterFunc(time.Until(until), func() {
+		o.mutex.Lock()
+		defer o.mutex.Unlock()
+		// A later override has stopped this timer, unless it fired first
+		if o.revert == revert {
+			o.clear()
+			log.Printf("Logging override expired, back to level %s", o.configured)
+		}
+	})
+	o.revert = revert
+	return o.status()
+}
+
+// Reset ends the override in effect before it expires.
+func (o *Overrides) Reset() Status {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if o.revert != nil {
+		o.revert.Stop()
+	}
+	o.clear()
+	return o.status()
+}
+
+func (o *Overrides) Status() Status {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+	return o.status()
+}
+
+// Level is the access log level in effect.
+func (o *Overrides) Level() string {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+	if o.level != "" {
+		return o.level
+	}
+	return o.configured
+}
+
+// DebugsRoutes reports whether debug logging is on for any route, which
+// spares looking up the route of every request when it is not.
+func (o *Overrides) DebugsRoutes() bool {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+	return len(o.routes) > 0
+}
+
+// Debugs reports whether debug logging is on for the route with path.
+func (o *Overrides) Debugs(route string) bool {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+	return o.routes[route]
+}
+
+// clear drops the override. The caller holds the lock.
+func (o *Overrides) clear() {
+	o.level = ""
+	o.routes = nil
+	o.since = time.Time{}
+	o.until = time.Time{}
+	o.revert = nil
+}
+
+// status describes the overrides. The caller holds the lock.
+func (o *Overrides) status() Status {
+	status := Status{Level: o.configured, ConfiguredLevel: o.configured, DebugRoutes: make([]string, 0, len(o.routes))}
+	if o.level != "" {
+		status.Level = o.level
+	}
+	for route := range o.routes {
+		status.DebugRoutes = append(status.DebugRoutes, route)
+	}
+	sort.Strings(status.DebugRoutes)
+	if !o.until.IsZero() {
+		since, until := o.since, o.until
+		status.Since = &since
+		status.Until = &until
+	}
+	return status
+}
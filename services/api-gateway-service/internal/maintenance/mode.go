@@ -0,0 +1,147 @@
+package maintenance
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"gateway/internal/ipaccess"
+	"gateway/internal/models"
+)
+
+// Mode is the gateway's maintenance switch. While it is on, proxied
+// requests are answered with a maintenance notice instead, except those
+// from bypass addresses.
+type Mode struct {
+	mutex  sync.RWMutex
+	config models.MaintenanceConfig
+	bypass []*net.IPNet
+
+	// The current maintenance window; message and retryAfter override the
+	// configured ones until it ends
+	enabled    bool
+	since      time.Time
+	message    string
+	retryAfter time.Duration
+}
+
+// Notice is what requests turned away during maintenance are answered
+// with. An empty Body means a JSON error carrying Message, or the
+// localized default when that is empty too.
+type Notice struct {
+	Message     string
+	RetryAfter  time.Duration
+	Body        string
+	ContentType string
+}
+
+// Status describes maintenance mode for the admin API.
+type Status struct {
+	Enabled    bool       `json:"enabled"`
+	Since      *time.Time `json:"since,omitempty"`
+	Message    string     `json:"message,omitempty"`
+	RetryAfter string     `json:"retry_after,omitempty"`
+	BypassIPs  []string   `json:"bypass_ips,omitempty"`
+}
+
+// New sets up maintenance mode from config, on if config enables it.
+func New(config models.MaintenanceConfig) (*Mode, error) {
+	bypass, err := ipaccess.ParseNetworks(config.BypassIPs)
+	if err != nil {
+		return nil, err
+	}
+	m := &Mode{config: config, bypass: bypass}
+	if config.Enabled {
+		m.enabled = true
+		m.since = time.Now()
+	}
+	return m, nil
+}
+
+// Enable turns maintenance mode on. A message or retryAfter given replace
+// the configured ones until it is turned off; enabling it while on only
+// updates them.
+func (m *Mode) Enable(message string, retryAfter time.Duration) Status {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if !m.enabled {
+		m.enabled = true
+		m.since = time.Now()
+	}
+	m.message = message
+	m.retryAfter = retryAfter
+	return m.status()
+}
+
+// Disable turns maintenance mode off.
+func (m *Mode) Disable() Status {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.enabled = false
+	m.since = time.Time{}
+	m.message = ""
+	m.retryAfter = 0
+	return m.status()
+}
+
+func (m *Mode) Status() Status {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.status()
+}
+
+// Blocks tells whether a request from clientIP is turned away, and with
+// what.
+func (m *Mode) Blocks(clientIP string) (Notice, bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if !m.enabled {
+		return Notice{}, false
+	}
+	if ip := net.ParseIP(clientIP); ip != nil {
+		for _, network := range m.bypass {
+			if network.Contains(ip) {
+				return Notice{}, false
+			}
+		}
+	}
+	return m.notice(), true
+}
+
+// notice is the current maintenance notice. The caller holds the lock.
+func (m *Mode) notice() Notice {
+	notice := Notice{
+		Message:     m.config.Message,
+		RetryAfter:  m.config.RetryAfter,
+		Body:        m.config.Body,
+		ContentType: m.config.ContentType,
+	}
+	if m.message != "" {
+		// A message for this window takes the place of a custom body too
+		notice.Message = m.message
+		notice.Body = ""
+	}
+	if m.retryAfter > 0 {
+		notice.RetryAfter = m.retryAfter
+	}
+	return notice
+}
+
+// status describes the mode. The caller holds the lock.
+func (m *Mode) status() Status {
+	status := Status{Enabled: m.enabled, BypassIPs: m.config.BypassIPs}
+	if !m.enabled {
+		return status
+	}
+	since := m.since
+	status.Since = &since
+	notice := m.notice()
+	status.Message = notice.Message
+	if notice.RetryAfter > 0 {
+		status.RetryAfter = notice.RetryAfter.String()
+	}
+	return status
+}
@@ -0,0 +1,308 @@
+package middleware
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"gateway/internal/auth"
+	"gateway/internal/logging"
+	"gateway/internal/models"
+	"gateway/internal/proxy"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AccessLog writes a line per request to output, as JSON when the config's
+// format is "json", in the Apache combined format when it is "combined",
+// laid out by the config's template when it is "custom" and in gin's text
+// layout otherwise. Lines below the
+// config's level are left out: successful requests log at info, 4xx at
+// warn and 5xx at error. Of the rest, the config's sampling keeps only a
+// share of some. While quiet reports true only failed requests (status >=
+// 400) are written. overrides, if not nil, replaces the config's level at
+// runtime; requests DebugRouteLogs marks are always written, with their
+// headers where the format has room for them.
+func AccessLog(config models.LoggingConfig, overrides *logging.Overrides, output io.Writer, quiet func() bool) gin.HandlerFunc {
+	level := func() string { return config.Level }
+	if overrides != nil {
+		level = overrides.Level
+	}
+	skip := func(status int, path string, elapsed time.Duration, debug bool) bool {
+		if debug {
+			return false
+		}
+		if status < http.StatusBadRequest && quiet() {
+			return true
+		}
+		if !models.LogLevelEnabled(level(), models.StatusLogLevel(status)) {
+			return true
+		}
+		return config.Sampling != nil && !config.Sampling.Sample(path, status, elapsed, rand.Float64)
+	}
+	switch config.Format {
+	case models.LogFormatJSON:
+		return entryAccessLog(output, skip, appendJSONLine)
+	case models.LogFormatCombined:
+		return entryAccessLog(output, skip, appendCombinedLine)
+	case models.LogFormatCustom:
+		tmpl, err := models.ParseLogTemplate(config.Template)
+		if err != nil {
+			// Validation rejects such templates, so this is not expected
+			log.Printf("Invalid access log template, logging JSON instead: %v", err)
+			return entryAccessLog(output, skip, appendJSONLine)
+		}
+		return entryAccessLog(output, skip, templateLine(tmpl))
+	}
+	return textAccessLog(output, skip)
+}
+
+// DebugLogKey marks requests to routes with debug logging on, which the
+// access log writes whatever its level and sampling.
+const DebugLogKey = "debug_log"
+
+// DebugRouteLogs marks the requests to the routes overrides turns debug
+// logging on for.
+func DebugRouteLogs(overrides *logging.Overrides, serviceRegistry *registry.ServiceRegistry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if overrides.DebugsRoutes() {
+			route, _ := serviceRegistry.FindRoute(c.Request.Method, c.Request.URL.Path)
+			if route != nil && overrides.Debugs(route.Path) {
+				c.Set(DebugLogKey, true)
+			}
+		}
+		c.Next()
+	}
+}
+
+// skipFunc tells whether the access log leaves a request out.
+type skipFunc func(status int, path string, elapsed time.Duration, debug bool) bool
+
+// textAccessLog is gin's request logger. Requests of an authenticated user
+// end in the user's ID, and failed requests in their termination reason.
+func textAccessLog(output io.Writer, skip skipFunc) gin.HandlerFunc {
+	return gin.LoggerWithConfig(gin.LoggerConfig{
+		Output: output,
+		Formatter: func(params gin.LogFormatterParams) string {
+			debug, _ := params.Keys[DebugLogKey].(bool)
+			if skip(params.StatusCode, params.Path, params.Latency, debug) {
+				return ""
+			}
+
+			latency := params.Latency
+			if latency > time.Minute {
+				latency = latency.Truncate(time.Second)
+			}
+			user := ""
+			if identity, ok := params.Keys[IdentityKey].(*auth.Identity); ok {
+				user = " | user " + identity.UserID
+			}
+			reason := ""
+			if termination := proxy.TerminationOf(params.Keys, params.StatusCode); termination != "" {
+				reason = " | " + termination
+			}
+			return fmt.Sprintf("[GIN] %v | %3d | %13v | %15s | %-7s %#v%s%s\n%s",
+				params.TimeStamp.Format("2006/01/02 - 15:04:05"),
+				params.StatusCode,
+				latency,
+				params.ClientIP,
+				params.Method,
+				params.Path,
+				user,
+				reason,
+				params.ErrorMessage,
+			)
+		},
+	})
+}
+
+// accessLine is a pooled entry with a buffer grown to fit typical lines, so
+// logging a request allocates nothing once the pool is warm.
+type accessLine struct {
+	entry models.RequestLogEntry
+	buf   []byte
+}
+
+var accessLines = sync.Pool{
+	New: func() interface{} {
+		return &accessLine{buf: make([]byte, 0, 512)}
+	},
+}
+
+var correlationIDKey = http.CanonicalHeaderKey(CorrelationIDHeader)
+
+// logClientIP is c.ClientIP without its allocations for the common case of
+// a client that is not behind a proxy.
+func logClientIP(c *gin.Context) string {
+	header := c.Request.Header
+	if header.Get("X-Forwarded-For") == "" && header.Get("X-Real-Ip") == "" {
+		if host, _, err := net.SplitHostPort(strings.TrimSpace(c.Request.RemoteAddr)); err == nil {
+			return host
+		}
+	}
+	return c.ClientIP()
+}
+
+// lineFormat appends the access log line of a request, described by entry,
+// to dst and returns the extended buffer. The line ends in a newline.
+type lineFormat func(dst []byte, entry *models.RequestLogEntry, req *http.Request) []byte
+
+// entryAccessLog fills a pooled RequestLogEntry for every request and
+// writes it out in format.
+func entryAccessLog(output io.Writer, skip skipFunc, format lineFormat) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		elapsed := time.Since(start)
+		status := c.Writer.Status()
+		debug := c.GetBool(DebugLogKey)
+		if skip(status, c.Request.URL.Path, elapsed, debug) {
+			return
+		}
+
+		line := accessLines.Get().(*accessLine)
+		entry := &line.entry
+		entry.Reset()
+		entry.Timestamp = start
+		entry.CorrelationID = c.Request.Header.Get(correlationIDKey)
+		entry.Method = c.Request.Method
+		entry.Path = c.Request.URL.Path
+		entry.ClientIP = logClientIP(c)
+		if service, ok := c.Get(proxy.ServiceKey); ok {
+			entry.ServiceName = service.(*models.ServiceConfig).Name
+		}
+		if value, exists := c.Get(IdentityKey); exists {
+			if identity, ok := value.(*auth.Identity); ok {
+				entry.UserID = identity.UserID
+			}
+		}
+		if c.Request.ContentLength > 0 {
+			entry.RequestSize = c.Request.ContentLength
+		}
+		entry.SetResponse(status, elapsed, int64(c.Writer.Size()))
+		if entry.ResponseSize < 0 {
+			entry.ResponseSize = 0
+		}
+		if err := c.Errors.Last(); err != nil {
+			entry.Error = err.Error()
+		}
+		entry.TerminationReason = proxy.TerminationOf(c.Keys, status)
+		if debug {
+			addHeaders(entry, c.Request.Header)
+		}
+
+		line.buf = format(line.buf[:0], entry, c.Request)
+		output.Write(line.buf)
+
+		// Don't let one huge line pin its buffer in the pool
+		if cap(line.buf) <= 64<<10 {
+			accessLines.Put(line)
+		}
+	}
+}
+
+// addHeaders copies the request headers into entry, by name, leaving out
+// those AddHeader considers sensitive and the admin token.
+func addHeaders(entry *models.RequestLogEntry, header http.Header) {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		if name != AdminTokenHeader {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range header[name] {
+			entry.AddHeader(name, value)
+		}
+	}
+}
+
+func appendJSONLine(dst []byte, entry *models.RequestLogEntry, _ *http.Request) []byte {
+	return entry.AppendJSON(dst)
+}
+
+// appendCombinedLine appends the line in the Apache combined format:
+// client, identity (always "-"), user, time, request line, status,
+// response size, referer and user agent. Quoted fields are escaped as
+// Apache does, so a client cannot forge a line.
+func appendCombinedLine(dst []byte, entry *models.RequestLogEntry, req *http.Request) []byte {
+	dst = append(dst, entry.ClientIP...)
+	dst = append(dst, " - "...)
+	dst = appendCombinedField(dst, entry.UserID)
+	dst = append(dst, " ["...)
+	dst = entry.Timestamp.AppendFormat(dst, "02/Jan/2006:15:04:05 -0700")
+	dst = append(dst, `] "`...)
+	dst = appendCombinedEscaped(dst, req.Method)
+	dst = append(dst, ' ')
+	dst = appendCombinedEscaped(dst, req.URL.RequestURI())
+	dst = append(dst, ' ')
+	dst = appendCombinedEscaped(dst, req.Proto)
+	dst = append(dst, `" `...)
+	dst = strconv.AppendInt(dst, int64(entry.StatusCode), 10)
+	dst = append(dst, ' ')
+	if entry.ResponseSize > 0 {
+		dst = strconv.AppendInt(dst, entry.ResponseSize, 10)
+	} else {
+		dst = append(dst, '-')
+	}
+	dst = append(dst, ` "`...)
+	dst = appendCombinedEscaped(dst, req.Header.Get("Referer"))
+	dst = append(dst, `" "`...)
+	dst = appendCombinedEscaped(dst, req.Header.Get("User-Agent"))
+	return append(dst, "\"\n"...)
+}
+
+// appendCombinedField appends an unquoted field, "-" when empty.
+func appendCombinedField(dst []byte, value string) []byte {
+	if value == "" {
+		return append(dst, '-')
+	}
+	return appendCombinedEscaped(dst, strings.ReplaceAll(value, " ", "%20"))
+}
+
+// appendCombinedEscaped escapes quotes and backslashes with a backslash and
+// other non-printable bytes as \xhh.
+func appendCombinedEscaped(dst []byte, value string) []byte {
+	const hexDigits = "0123456789abcdef"
+	for i := 0; i < len(value); i++ {
+		b := value[i]
+		switch {
+		case b == '"' || b == '\\':
+			dst = append(dst, '\\', b)
+		case b < 0x20 || b == 0x7f:
+			dst = append(dst, '\\', 'x', hexDigits[b>>4], hexDigits[b&0xf])
+		default:
+			dst = append(dst, b)
+		}
+	}
+	return dst
+}
+
+// templateLine lays lines out with tmpl, adding the newline it leaves out.
+// A line the template fails on is logged as JSON rather than lost.
+func templateLine(tmpl *template.Template) lineFormat {
+	return func(dst []byte, entry *models.RequestLogEntry, _ *http.Request) []byte {
+		buf := bytes.NewBuffer(dst)
+		if err := tmpl.Execute(buf, entry); err != nil {
+			return entry.AppendJSON(dst)
+		}
+		line := buf.Bytes()
+		if len(line) == 0 || line[len(line)-1] != '\n' {
+			line = append(line, '\n')
+		}
+		return line
+	}
+}
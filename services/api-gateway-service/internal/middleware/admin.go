@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"gateway/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	AdminTokenHeader = "X-Admin-Token"
+
+	// AdminKey holds the models.AdminToken that authenticated an admin
+	// request
+	AdminKey = "admin_token"
+)
+
+// AdminAuth guards administration endpoints with the configured admin
+// tokens. With no token configured the endpoints are disabled rather than
+// left open. Reading needs any scope and other methods need mutate.
+func AdminAuth(config models.AdminConfig) gin.HandlerFunc {
+	tokens := config.Tokens
+	if config.Token != "" {
+		tokens = append([]models.AdminToken{{
+			Name:   "admin",
+			Token:  config.Token,
+			Scopes: []models.AdminScope{models.AdminScopeRead, models.AdminScopeMutate, models.AdminScopeSecrets},
+		}}, tokens...)
+	}
+
+	return func(c *gin.Context) {
+		if len(tokens) == 0 {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":   "Admin API disabled",
+				"message": "Set admin.token or admin.tokens to enable administration endpoints",
+			})
+			c.Abort()
+			return
+		}
+
+		token, found := matchAdminToken(tokens, c.GetHeader(AdminTokenHeader))
+		if !found {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error":   "Unauthorized",
+				"message": "Missing or invalid " + AdminTokenHeader + " header",
+			})
+			c.Abort()
+			return
+		}
+		c.Set(AdminKey, token)
+
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			if len(token.Scopes) == 0 {
+				rejectAdminScope(c, models.AdminScopeRead)
+				return
+			}
+		default:
+			if !token.HasScope(models.AdminScopeMutate) {
+				rejectAdminScope(c, models.AdminScopeMutate)
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// RequireAdminScope additionally requires scope of the admin token, for
+// endpoints behind AdminAuth that need more than the method implies.
+func RequireAdminScope(scope models.AdminScope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, _ := c.Get(AdminKey)
+		if token, ok := value.(models.AdminToken); !ok || !token.HasScope(scope) {
+			rejectAdminScope(c, scope)
+			return
+		}
+		c.Next()
+	}
+}
+
+// matchAdminToken compares provided against every token, so the time taken
+// does not tell which one came close.
+func matchAdminToken(tokens []models.AdminToken, provided string) (models.AdminToken, bool) {
+	var match models.AdminToken
+	found := false
+	for _, token := range tokens {
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token.Token)) == 1 && !found {
+			match, found = token, true
+		}
+	}
+	return match, found
+}
+
+func rejectAdminScope(c *gin.Context, scope models.AdminScope) {
+	c.JSON(http.StatusForbidden, gin.H{
+		"error":   "Forbidden",
+		"message": "The admin token lacks the " + string(scope) + " scope",
+	})
+	c.Abort()
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"gateway/internal/audit"
+	"gateway/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Audit records every change made through the admin endpoints after it in
+// auditLog, whether it succeeded or not: requests other than reads that
+// AdminAuth let through. Endpoints that report the state they changed
+// with audit.Change have it recorded too.
+func Audit(auditLog *audit.Log) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		c.Next()
+
+		record := models.AuditRecord{
+			Timestamp:     time.Now().UTC(),
+			ClientIP:      c.ClientIP(),
+			Method:        c.Request.Method,
+			Path:          c.Request.URL.Path,
+			Query:         c.Request.URL.RawQuery,
+			Status:        c.Writer.Status(),
+			CorrelationID: c.Request.Header.Get(CorrelationIDHeader),
+		}
+		if value, exists := c.Get(AdminKey); exists {
+			record.Actor = value.(models.AdminToken).Name
+		}
+		if before, exists := c.Get(audit.BeforeKey); exists {
+			record.Before = before.(json.RawMessage)
+		}
+		if after, exists := c.Get(audit.AfterKey); exists {
+			record.After = after.(json.RawMessage)
+		}
+		if _, err := auditLog.Append(record); err != nil {
+			log.Printf("Failed to record admin change %s %s by %s: %v", record.Method, record.Path, record.Actor, err)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"gateway/internal/auth"
+	"gateway/internal/i18n"
+	"gateway/internal/models"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IdentityKey is the gin context key holding the *auth.Identity of an
+// authenticated request.
+const IdentityKey = "identity"
+
+// Authenticate verifies the bearer token of requests to routes with
+// auth_mode required against the auth service, usually through a
+// CachingVerifier. On optional routes a token is verified when sent, but
+// requests without a valid one go on anonymously. Paths listed in
+// skip_paths, and requests already identified by a client certificate or
+// OIDC login, are never checked.
+func Authenticate(verifier auth.Verifier, serviceRegistry *registry.ServiceRegistry, config models.AuthConfig) gin.HandlerFunc {
+	return AuthenticateProviders(auth.Providers{"": verifier}, serviceRegistry, config)
+}
+
+// AuthenticateProviders is Authenticate for gateways with several
+// identity systems: each route's token is checked by the provider it
+// names in auth_provider.
+func AuthenticateProviders(providers auth.Providers, serviceRegistry *registry.ServiceRegistry, config models.AuthConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, identified := c.Get(IdentityKey); identified || config.Skips(c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+
+		route, _ := serviceRegistry.FindRoute(c.Request.Method, c.Request.URL.Path)
+		if route == nil || route.Auth() == models.AuthModeNone {
+			c.Next()
+			return
+		}
+
+		verifier := providers[route.AuthProvider]
+		if verifier == nil {
+			log.Printf("Route %s names unknown auth provider %q", route.Path, route.AuthProvider)
+			i18n.Error(c, http.StatusServiceUnavailable, "Auth service unavailable", i18n.AuthUnavailable)
+			c.Abort()
+			return
+		}
+
+		token, ok := bearerToken(c.GetHeader("Authorization"))
+		if route.Auth() == models.AuthModeOptional {
+			if ok {
+				identifyOptionally(c, verifier, token)
+			}
+			c.Next()
+			return
+		}
+		if !ok {
+			rejectUnauthorized(c, i18n.MissingToken)
+			return
+		}
+
+		identity, err := verifier.Verify(c.Request.Context(), token)
+		switch {
+		case err == nil:
+			c.Set(IdentityKey, identity)
+			c.Next()
+		case errors.Is(err, auth.ErrRevoked):
+			rejectUnauthorized(c, i18n.TokenRevoked)
+		case errors.Is(err, auth.ErrInvalidCredentials):
+			rejectUnauthorized(c, i18n.InvalidToken)
+		case errors.Is(err, auth.ErrForbidden):
+			i18n.Error(c, http.StatusForbidden, "Forbidden", i18n.TokenForbidden)
+			c.Abort()
+		default:
+			log.Printf("Token verification failed: %v", err)
+			i18n.Error(c, http.StatusServiceUnavailable, "Auth service unavailable", i18n.AuthUnavailable)
+			c.Abort()
+		}
+	}
+}
+
+// identifyOptionally attaches the identity of a valid token. A token that
+// is rejected, or cannot be checked, leaves the request anonymous.
+func identifyOptionally(c *gin.Context, verifier auth.Verifier, token string) {
+	identity, err := verifier.Verify(c.Request.Context(), token)
+	switch {
+	case err == nil:
+		c.Set(IdentityKey, identity)
+	case errors.Is(err, auth.ErrInvalidCredentials), errors.Is(err, auth.ErrForbidden):
+	default:
+		log.Printf("Token verification failed, serving anonymously: %v", err)
+	}
+}
+
+func bearerToken(header string) (string, bool) {
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+func rejectUnauthorized(c *gin.Context, key string) {
+	c.Header("WWW-Authenticate", `Bearer realm="gateway"`)
+	i18n.Error(c, http.StatusUnauthorized, "Unauthorized", key)
+	c.Abort()
+}
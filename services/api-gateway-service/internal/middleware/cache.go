@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"time"
+
+	"gateway/internal/cache"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+)
+
+const CacheStatusHeader = "X-Cache"
+
+// ResponseCache serves GET requests for routes with a cache_ttl from the
+// response cache. Requests carrying credentials, cookies included, bypass
+// the cache, as do those an earlier middleware identified from a session
+// it has already stripped. Only 200 responses that are not private, set no
+// cookies and fit in maxBodySize are stored.
+func ResponseCache(responseCache *cache.ResponseCache, serviceRegistry *registry.ServiceRegistry, maxBodySize int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		_, identified := c.Get(IdentityKey)
+		if c.Request.Method != http.MethodGet || identified || c.GetHeader("Authorization") != "" || c.GetHeader("Cookie") != "" {
+			c.Next()
+			return
+		}
+
+		route, _ := serviceRegistry.FindRoute(c.Request.Method, c.Request.URL.Path)
+		if route == nil || route.CacheTTL <= 0 {
+			c.Next()
+			return
+		}
+
+		key := cache.Key(c.Request)
+		if !cache.IsRefresh(c.Request.Context()) {
+			if entry, found := responseCache.Get(key); found {
+				for name, values := range entry.Header {
+					c.Writer.Header()[name] = append([]string(nil), values...)
+				}
+				c.Header(CacheStatusHeader, "HIT")
+				c.Data(entry.Status, entry.Header.Get("Content-Type"), entry.Body)
+				c.Abort()
+				return
+			}
+		}
+
+		writer := &capturingWriter{ResponseWriter: c.Writer, limit: maxBodySize}
+		c.Writer = writer
+		c.Header(CacheStatusHeader, "MISS")
+
+		c.Next()
+
+		if writer.Status() != http.StatusOK || writer.overflow || !cacheableResponse(writer.Header()) {
+			return
+		}
+
+		header := writer.Header().Clone()
+		header.Del(CacheStatusHeader)
+		responseCache.Set(key, cache.Entry{
+			Status:    writer.Status(),
+			Header:    header,
+			Body:      writer.body.Bytes(),
+			ExpiresAt: time.Now().Add(route.CacheTTL),
+		})
+	}
+}
+
+func cacheableResponse(header http.Header) bool {
+	if header.Get("Set-Cookie") != "" {
+		return false
+	}
+
+	cacheControl := strings.ToLower(header.Get("Cache-Control"))
+	if strings.Contains(cacheControl, "no-store") || strings.Contains(cacheControl, "private") {
+		return false
+	}
+
+	// Responses varying on anything but encoding cannot share one entry
+	for _, vary := range header.Values("Vary") {
+		for _, field := range strings.Split(vary, ",") {
+			if field = strings.TrimSpace(field); field != "" && !strings.EqualFold(field, "Accept-Encoding") {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// capturingWriter copies the response body while it is written, giving up
+// once it grows past limit.
+type capturingWriter struct {
+	gin.ResponseWriter
+	body     bytes.Buffer
+	limit    int64
+	overflow bool
+}
+
+func (w *capturingWriter) Write(data []byte) (int, error) {
+	w.capture(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *capturingWriter) WriteString(data string) (int, error) {
+	w.capture([]byte(data))
+	return w.ResponseWriter.WriteString(data)
+}
+
+func (w *capturingWriter) capture(data []byte) {
+	if w.overflow {
+		return
+	}
+	if int64(w.body.Len()+len(data)) > w.limit {
+		w.overflow = true
+		w.body.Reset()
+		return
+	}
+	w.body.Write(data)
+}
@@ -0,0 +1,183 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gateway/internal/cache"
+	"gateway/internal/circuit"
+	"gateway/internal/i18n"
+	"gateway/internal/models"
+	"gateway/internal/proxy"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FallbackHeader names the fallback that answered a request while its
+// circuit was open.
+const FallbackHeader = "X-Fallback"
+
+// Routes with last_good fallbacks keep up to lastGoodEntries responses of
+// at most lastGoodMaxBodySize bytes.
+const (
+	lastGoodEntries     = 1000
+	lastGoodMaxBodySize = 1 << 20
+)
+
+// CircuitBreaker turns requests to a service whose circuit is open away
+// with 503 and Retry-After, unless their route has a fallback, and reports
+// how the others went to the service's circuit, once per request however
+// many times the proxy retried it. Only outcomes that reflect
+// on the service count: 5xx answers, timeouts and connection failures are
+// failures, other answers successes unless slower than the circuit's
+// slow_call_duration. Requests the client abandoned or the gateway turned
+// away are not counted. Composite routes are left to their legs' timeouts.
+//
+// A service's circuit_breaker overrides apply on top of the gateway-wide
+// settings, and a route with overrides of its own gets a circuit separate
+// from its service's.
+func CircuitBreaker(breakers *circuit.Breakers, serviceRegistry *registry.ServiceRegistry) gin.HandlerFunc {
+	lastGood := cache.New(lastGoodEntries)
+
+	return func(c *gin.Context) {
+		route, service := serviceRegistry.FindRoute(c.Request.Method, c.Request.URL.Path)
+		if route == nil || service == nil || route.IsComposite() {
+			c.Next()
+			return
+		}
+
+		name := service.Name
+		circuitRoute, settings := breakers.SettingsFor(service, route)
+		if allowed, retryAfter := breakers.Allow(name, circuitRoute, settings); !allowed {
+			if route.Fallback != nil && serveFallback(c, route.Fallback, lastGood, serviceRegistry) {
+				return
+			}
+			seconds := int(math.Ceil(retryAfter.Seconds()))
+			if seconds < 1 {
+				seconds = 1
+			}
+			c.Header("Retry-After", strconv.Itoa(seconds))
+			proxy.SetTermination(c, proxy.TerminationBreakerOpen)
+			i18n.Error(c, http.StatusServiceUnavailable, "Service unavailable", i18n.CircuitOpen, name, seconds)
+			c.Abort()
+			return
+		}
+
+		// A panicking handler, such as a proxy whose client went away
+		// mid-body, must not keep a half-open probe forever
+		reported := false
+		defer func() {
+			if !reported {
+				breakers.Release(name, circuitRoute)
+			}
+		}()
+		var writer *capturingWriter
+		if route.Fallback != nil && route.Fallback.Mode == models.FallbackLastGood && anonymousGet(c) {
+			writer = &capturingWriter{ResponseWriter: c.Writer, limit: lastGoodMaxBodySize}
+			c.Writer = writer
+		}
+		// Retries go out only while the circuit stays closed, so they
+		// neither pile onto a failing service nor add to a half-open
+		// circuit's probes
+		c.Set(proxy.RetryGateKey, func(target string) bool {
+			return target != name || breakers.Closed(name, circuitRoute)
+		})
+		start := time.Now()
+		c.Next()
+		elapsed := time.Since(start)
+
+		// Canaries and requests that never reached the proxy say nothing
+		// about this service
+		proxied, ok := c.Get(proxy.ServiceKey)
+		if !ok || proxied.(*models.ServiceConfig).Name != name {
+			return
+		}
+		if writer != nil && writer.Status() == http.StatusOK && !writer.overflow && cacheableResponse(writer.Header()) {
+			maxAge := route.Fallback.MaxAge
+			if maxAge <= 0 {
+				maxAge = models.DefaultFallbackMaxAge
+			}
+			header := writer.Header().Clone()
+			header.Del(CacheStatusHeader)
+			lastGood.Set(cache.Key(c.Request), cache.Entry{
+				Status:    http.StatusOK,
+				Header:    header,
+				Body:      writer.body.Bytes(),
+				ExpiresAt: time.Now().Add(maxAge),
+			})
+		}
+		switch proxy.Termination(c) {
+		case proxy.TerminationUpstream5xx, proxy.TerminationUpstreamTimeout, proxy.TerminationUpstreamUnreachable:
+			breakers.Record(name, circuitRoute, true, elapsed)
+		case "", proxy.TerminationUpstream4xx:
+			breakers.Record(name, circuitRoute, false, elapsed)
+		default:
+			return
+		}
+		reported = true
+	}
+}
+
+// serveFallback answers a request whose circuit is open with its route's
+// fallback, and reports false if the fallback has no answer for it.
+func serveFallback(c *gin.Context, fallback *models.FallbackConfig, lastGood *cache.ResponseCache, serviceRegistry *registry.ServiceRegistry) bool {
+	switch fallback.Mode {
+	case models.FallbackStatic:
+		status := fallback.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		if status >= http.StatusBadRequest {
+			proxy.SetTermination(c, proxy.TerminationBreakerOpen)
+		}
+		c.Header(FallbackHeader, string(fallback.Mode))
+		c.Data(status, "application/json; charset=utf-8", []byte(fallback.Body))
+		c.Abort()
+		return true
+
+	case models.FallbackLastGood:
+		if !anonymousGet(c) {
+			return false
+		}
+		entry, found := lastGood.Get(cache.Key(c.Request))
+		if !found {
+			return false
+		}
+		// Headers the gateway set for this request, such as rate limit
+		// headers, are more current than the stored ones
+		for name, values := range entry.Header {
+			if _, set := c.Writer.Header()[name]; !set {
+				c.Writer.Header()[name] = append([]string(nil), values...)
+			}
+		}
+		c.Header(FallbackHeader, string(fallback.Mode))
+		c.Data(entry.Status, entry.Header.Get("Content-Type"), entry.Body)
+		c.Abort()
+		return true
+
+	case models.FallbackService:
+		degraded, exists := serviceRegistry.GetService(fallback.ServiceName)
+		if !exists {
+			return false
+		}
+		c.Header(FallbackHeader, string(fallback.Mode))
+		c.Set(proxy.RerouteKey, degraded)
+		c.Next()
+		return true
+	}
+	return false
+}
+
+// anonymousGet reports whether a request may be answered with a response
+// stored for another client. Session middleware strips the cookie it
+// identified a request by, so the identity is checked as well.
+func anonymousGet(c *gin.Context) bool {
+	r := c.Request
+	if _, identified := c.Get(IdentityKey); identified {
+		return false
+	}
+	return r.Method == http.MethodGet && r.Header.Get("Authorization") == "" && r.Header.Get("Cookie") == ""
+}
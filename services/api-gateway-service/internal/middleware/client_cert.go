@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"net/http"
+
+	"gateway/internal/auth"
+	"gateway/internal/i18n"
+	"gateway/internal/models"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ClientCertAuth authenticates requests to routes with a client_cert
+// policy by the TLS client certificate the listener verified. A matching
+// certificate stands in for a bearer token, so Authenticate lets the
+// request through with its identity.
+func ClientCertAuth(serviceRegistry *registry.ServiceRegistry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route, _ := serviceRegistry.FindRoute(c.Request.Method, c.Request.URL.Path)
+		if route == nil || route.ClientCert == nil {
+			c.Next()
+			return
+		}
+
+		state := c.Request.TLS
+		if state == nil || len(state.VerifiedChains) == 0 {
+			i18n.Error(c, http.StatusUnauthorized, "Unauthorized", i18n.ClientCertRequired)
+			c.Abort()
+			return
+		}
+
+		cert := state.VerifiedChains[0][0]
+		identity := route.ClientCert.Identify(cert)
+		if identity == nil {
+			i18n.Error(c, http.StatusForbidden, "Forbidden", i18n.ClientCertForbidden)
+			c.Abort()
+			return
+		}
+
+		c.Set(IdentityKey, &auth.Identity{
+			UserID: identity.UserID,
+			Name:   identity.Name,
+			Scopes: identity.Scopes,
+			Claims: map[string]string{
+				"cert_subject":     cert.Subject.String(),
+				"cert_fingerprint": models.CertFingerprint(cert),
+			},
+		})
+		c.Next()
+	}
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"gateway/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CorrelationIDHeader carries the ID that ties the log lines of a request
+// together across services.
+const CorrelationIDHeader = models.CorrelationIDHeader
+
+// CorrelationIDKey holds the request's correlation ID.
+const CorrelationIDKey = "correlation_id"
+
+// maxCorrelationIDLength bounds the IDs accepted from clients, which end up
+// in every log line of the request.
+const maxCorrelationIDLength = 128
+
+// CorrelationID gives every request a correlation ID: the client's
+// X-Correlation-ID if it sent a usable one, a new random one otherwise. The
+// ID is set on the request, so upstreams receive it, on the request's
+// context, for the gateway's own calls such as token verification, and on
+// the response.
+func CorrelationID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Request.Header.Get(CorrelationIDHeader)
+		if !validCorrelationID(id) {
+			id = newCorrelationID()
+			c.Request.Header.Set(CorrelationIDHeader, id)
+		}
+		c.Set(CorrelationIDKey, id)
+		c.Request = c.Request.WithContext(models.WithCorrelationID(c.Request.Context(), id))
+		c.Header(CorrelationIDHeader, id)
+		c.Next()
+	}
+}
+
+// validCorrelationID accepts IDs of visible ASCII characters, so a client
+// cannot break log lines or headers with its ID.
+func validCorrelationID(id string) bool {
+	if id == "" || len(id) > maxCorrelationIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// newCorrelationID returns a random UUID (version 4).
+func newCorrelationID() string {
+	var uuid [16]byte
+	rand.Read(uuid[:])
+	uuid[6] = uuid[6]&0x0f | 0x40
+	uuid[8] = uuid[8]&0x3f | 0x80
+
+	var buf [36]byte
+	hex.Encode(buf[0:8], uuid[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], uuid[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], uuid[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], uuid[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], uuid[10:])
+	return string(buf[:])
+}
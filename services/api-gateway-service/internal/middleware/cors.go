@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gateway/internal/models"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CORS applies the gateway's CORS policy, with the overrides of the route
+// a request is for. Allowed origins get the Access-Control headers, others
+// none, so browsers keep their scripts from reading the answer. Preflight
+// requests are answered here with 204 and go no further.
+func CORS(config models.CORSConfig, serviceRegistry *registry.ServiceRegistry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		requestedMethod := c.GetHeader("Access-Control-Request-Method")
+		preflight := c.Request.Method == http.MethodOptions && origin != "" && requestedMethod != ""
+
+		method := c.Request.Method
+		if preflight {
+			method = requestedMethod
+		}
+		policy := config
+		if route, _ := serviceRegistry.FindRoute(method, c.Request.URL.Path); route != nil {
+			policy = route.CORS.Apply(config)
+		}
+		if !policy.Enabled {
+			c.Next()
+			return
+		}
+
+		header := c.Writer.Header()
+		if !policy.AnyOrigin() || policy.AllowCredentials {
+			header.Add("Vary", "Origin")
+		}
+		if origin != "" && policy.AllowsOrigin(origin) {
+			if policy.AnyOrigin() && !policy.AllowCredentials {
+				header.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				header.Set("Access-Control-Allow-Origin", origin)
+			}
+			if policy.AllowCredentials {
+				header.Set("Access-Control-Allow-Credentials", "true")
+			}
+			if preflight {
+				header.Set("Access-Control-Allow-Methods", strings.Join(policy.AllowedMethods, ", "))
+				if policy.AnyHeader() {
+					if requested := c.GetHeader("Access-Control-Request-Headers"); requested != "" {
+						header.Add("Vary", "Access-Control-Request-Headers")
+						header.Set("Access-Control-Allow-Headers", requested)
+					}
+				} else if len(policy.AllowedHeaders) > 0 {
+					header.Set("Access-Control-Allow-Headers", strings.Join(policy.AllowedHeaders, ", "))
+				}
+				if policy.MaxAge > 0 {
+					header.Set("Access-Control-Max-Age", strconv.Itoa(int(policy.MaxAge.Seconds())))
+				}
+			} else if len(policy.ExposedHeaders) > 0 {
+				header.Set("Access-Control-Expose-Headers", strings.Join(policy.ExposedHeaders, ", "))
+			}
+		}
+
+		if preflight {
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		c.Next()
+	}
+}
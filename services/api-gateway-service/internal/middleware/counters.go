@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"time"
+
+	"gateway/internal/traffic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CountRequests tallies every request the router answers in counters, by
+// outcome and response time.
+func CountRequests(counters *traffic.Counters) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		counters.Record(c.Writer.Status(), time.Since(start))
+	}
+}
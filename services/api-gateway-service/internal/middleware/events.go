@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"time"
+
+	"gateway/internal/events"
+	"gateway/internal/models"
+	"gateway/internal/proxy"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StreamRateLimits publishes a rate_limited event to stream for every
+// request a gateway or route rate limit turned away. Upstream 429s are the
+// service's business and are left out.
+func StreamRateLimits(stream *events.Stream, serviceRegistry *registry.ServiceRegistry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if stream.Subscribers() == 0 || proxy.Termination(c) != proxy.TerminationRateLimited {
+			return
+		}
+		event := models.HealthEvent{
+			Type:      models.EventRateLimited,
+			Reason:    c.Request.Method + " " + c.Request.URL.Path + " from " + c.ClientIP(),
+			Timestamp: time.Now(),
+		}
+		if route, _ := serviceRegistry.FindRoute(c.Request.Method, c.Request.URL.Path); route != nil {
+			event.Service = route.ServiceName
+		}
+		stream.Publish(event)
+	}
+}
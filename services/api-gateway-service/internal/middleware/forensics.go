@@ -0,0 +1,165 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"time"
+
+	"gateway/internal/forensics"
+	"gateway/internal/models"
+	"gateway/internal/proxy"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RecordFailures keeps requests answered with a 5xx status in recorder,
+// with redacted headers and bodies truncated to the configured size. Bodies
+// are captured while they stream through, so successful requests cost no
+// more than a bounded copy.
+func RecordFailures(recorder *forensics.Recorder, serviceRegistry *registry.ServiceRegistry, config models.ForensicsConfig) gin.HandlerFunc {
+	redacted := redactedHeaders(config)
+
+	return func(c *gin.Context) {
+		tap := startTap(c, config.MaxBodySize)
+		c.Next()
+
+		if tap.writer.Status() < http.StatusInternalServerError {
+			return
+		}
+		request := tap.record(c, redacted)
+		if route, service := serviceRegistry.FindRoute(c.Request.Method, c.Request.URL.Path); service != nil {
+			request.Route = route.Path
+			request.Service = service.Name
+		}
+		recorder.Append(request)
+	}
+}
+
+// CaptureRequests records every request to the routes captures is
+// capturing, whatever its status, with the same redaction and body limit
+// as RecordFailures. Other requests pass straight through.
+func CaptureRequests(captures *forensics.Captures, serviceRegistry *registry.ServiceRegistry, config models.ForensicsConfig) gin.HandlerFunc {
+	redacted := redactedHeaders(config)
+
+	return func(c *gin.Context) {
+		route, service := serviceRegistry.FindRoute(c.Request.Method, c.Request.URL.Path)
+		if route == nil || !captures.Capturing(route.Path) {
+			c.Next()
+			return
+		}
+
+		tap := startTap(c, config.MaxBodySize)
+		c.Next()
+
+		request := tap.record(c, redacted)
+		request.Route = route.Path
+		if service != nil {
+			request.Service = service.Name
+		}
+		captures.Recorder().Append(request)
+	}
+}
+
+// redactedHeaders are always redacted, whatever the config adds.
+func redactedHeaders(config models.ForensicsConfig) []string {
+	redacted := []string{"Authorization", "Cookie", "Set-Cookie", "Proxy-Authorization", AdminTokenHeader}
+	return append(redacted, config.RedactHeaders...)
+}
+
+// requestTap copies the start of a request's and its response's bodies as
+// they stream through.
+type requestTap struct {
+	start          time.Time
+	requestHeaders http.Header
+	requestBody    *prefixBuffer
+	writer         *recordingWriter
+}
+
+func startTap(c *gin.Context, maxBodySize int) *requestTap {
+	tap := &requestTap{
+		start:          time.Now(),
+		requestHeaders: c.Request.Header.Clone(),
+		requestBody:    &prefixBuffer{limit: maxBodySize},
+	}
+	if c.Request.Body != nil && c.Request.Body != http.NoBody {
+		c.Request.Body = &tappedBody{ReadCloser: c.Request.Body, tap: tap.requestBody}
+	}
+	tap.writer = &recordingWriter{ResponseWriter: c.Writer, body: &prefixBuffer{limit: maxBodySize}}
+	c.Writer = tap.writer
+	return tap
+}
+
+func (t *requestTap) record(c *gin.Context, redacted []string) models.RecordedRequest {
+	status := t.writer.Status()
+	return models.RecordedRequest{
+		Timestamp:             t.start,
+		Method:                c.Request.Method,
+		Path:                  c.Request.URL.Path,
+		Query:                 c.Request.URL.RawQuery,
+		ClientIP:              c.ClientIP(),
+		Status:                status,
+		TerminationReason:     proxy.TerminationOf(c.Keys, status),
+		Duration:              time.Since(t.start),
+		RequestHeaders:        redactHeaders(t.requestHeaders, redacted),
+		RequestBody:           t.requestBody.buf.String(),
+		RequestBodyTruncated:  t.requestBody.truncated,
+		ResponseHeaders:       redactHeaders(t.writer.Header().Clone(), redacted),
+		ResponseBody:          t.writer.body.buf.String(),
+		ResponseBodyTruncated: t.writer.body.truncated,
+	}
+}
+
+func redactHeaders(header http.Header, names []string) http.Header {
+	for _, name := range names {
+		if _, present := header[http.CanonicalHeaderKey(name)]; present {
+			header.Set(name, "[REDACTED]")
+		}
+	}
+	return header
+}
+
+// prefixBuffer keeps the first limit bytes written to it.
+type prefixBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (p *prefixBuffer) Write(data []byte) {
+	if room := p.limit - p.buf.Len(); len(data) > room {
+		p.truncated = true
+		if room <= 0 {
+			return
+		}
+		data = data[:room]
+	}
+	p.buf.Write(data)
+}
+
+type tappedBody struct {
+	io.ReadCloser
+	tap *prefixBuffer
+}
+
+func (b *tappedBody) Read(data []byte) (int, error) {
+	n, err := b.ReadCloser.Read(data)
+	b.tap.Write(data[:n])
+	return n, err
+}
+
+type recordingWriter struct {
+	gin.ResponseWriter
+	body *prefixBuffer
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) WriteString(data string) (int, error) {
+	w.body.Write([]byte(data))
+	return w.ResponseWriter.WriteString(data)
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"log"
+	"net"
+	"net/http"
+
+	"gateway/internal/geoip"
+	"gateway/internal/i18n"
+	"gateway/internal/proxy"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GeoIP looks up the client's country, keeps it in the context under
+// geoip.CountryKey and refuses requests the route's geo_access rules keep
+// out, logging why. Each request is counted by country once answered.
+func GeoIP(locator geoip.Locator, counts *geoip.Counts, serviceRegistry *registry.ServiceRegistry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		country := ""
+		if ip := net.ParseIP(c.ClientIP()); ip != nil {
+			country, _ = locator.Country(ip)
+		}
+		c.Set(geoip.CountryKey, country)
+		defer func() { counts.Record(country, c.Writer.Status()) }()
+
+		route, _ := serviceRegistry.FindRoute(c.Request.Method, c.Request.URL.Path)
+		if route == nil || route.GeoAccess == nil {
+			c.Next()
+			return
+		}
+		reason, ok := route.GeoAccess.Check(country)
+		if ok {
+			c.Next()
+			return
+		}
+
+		log.Printf("Denied %s %s: %s", c.Request.Method, c.Request.URL.Path, reason)
+		proxy.SetTermination(c, proxy.TerminationGeoDenied)
+		i18n.Error(c, http.StatusForbidden, "Forbidden", i18n.GeoDenied)
+		c.Abort()
+	}
+}
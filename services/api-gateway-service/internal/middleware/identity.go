@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"gateway/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PropagateIdentity passes the claims of the authenticated user to
+// upstreams in the configured headers (claim -> header). Client-supplied
+// values of those headers are always dropped, so backends can trust them.
+func PropagateIdentity(headers map[string]string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, header := range headers {
+			if header != "" {
+				c.Request.Header.Del(header)
+			}
+		}
+
+		if value, exists := c.Get(IdentityKey); exists {
+			identity := value.(*auth.Identity)
+			for claim, header := range headers {
+				if header == "" {
+					continue
+				}
+				if claimValue := identity.Claim(claim); claimValue != "" {
+					c.Request.Header.Set(header, claimValue)
+				}
+			}
+		}
+		c.Next()
+	}
+}
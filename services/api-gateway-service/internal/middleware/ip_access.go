@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+
+	"gateway/internal/i18n"
+	"gateway/internal/ipaccess"
+	"gateway/internal/proxy"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IPAccess refuses requests from clients the gateway's or the route's
+// ip_access lists keep out, logging why. The client address is the one
+// gin resolves, which only believes X-Forwarded-For from trusted proxies.
+func IPAccess(policies *ipaccess.Policies, serviceRegistry *registry.ServiceRegistry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route, _ := serviceRegistry.FindRoute(c.Request.Method, c.Request.URL.Path)
+		reason, ok := policies.Check(c.ClientIP(), route)
+		if ok {
+			c.Next()
+			return
+		}
+
+		log.Printf("Denied %s %s: %s", c.Request.Method, c.Request.URL.Path, reason)
+		proxy.SetTermination(c, proxy.TerminationIPDenied)
+		i18n.Error(c, http.StatusForbidden, "Forbidden", i18n.IPDenied)
+		c.Abort()
+	}
+}
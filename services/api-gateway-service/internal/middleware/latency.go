@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"time"
+
+	"gateway/internal/registry"
+	"gateway/internal/traffic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TrackLatency records how long requests to each route take in latencies,
+// from the first of the route's middleware to the last byte of the
+// response. Requests that match no route are not recorded.
+func TrackLatency(latencies *traffic.Latencies, serviceRegistry *registry.ServiceRegistry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route, _ := serviceRegistry.FindRoute(c.Request.Method, c.Request.URL.Path)
+		if route == nil {
+			c.Next()
+			return
+		}
+		start := time.Now()
+		c.Next()
+		latencies.Record(route.Path, route.ServiceName, time.Since(start))
+	}
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"gateway/internal/i18n"
+	"gateway/internal/maintenance"
+	"gateway/internal/proxy"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Maintenance answers proxied requests with 503 and the maintenance notice
+// while maintenance mode is on, before they reach anything else, except
+// those from bypass addresses.
+func Maintenance(mode *maintenance.Mode) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		notice, blocked := mode.Blocks(c.ClientIP())
+		if !blocked {
+			c.Next()
+			return
+		}
+
+		proxy.SetTermination(c, proxy.TerminationMaintenance)
+		if notice.RetryAfter > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(notice.RetryAfter.Seconds()))))
+		}
+		if notice.Body != "" {
+			c.Data(http.StatusServiceUnavailable, notice.ContentType, []byte(notice.Body))
+		} else {
+			fields := gin.H{"maintenance": true}
+			if notice.Message != "" {
+				fields["message"] = notice.Message
+			}
+			i18n.ErrorWith(c, http.StatusServiceUnavailable, "Service unavailable", fields, i18n.Maintenance)
+		}
+		c.Abort()
+	}
+}
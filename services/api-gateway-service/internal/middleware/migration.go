@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"net/http"
+
+	"gateway/internal/migration"
+	"gateway/internal/models"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Migrations handles requests to migrated paths: by default they are
+// answered with a 308 to the new path, in rewrite mode they continue down
+// the chain as if the new path had been requested. Either way the request
+// is counted and the response marks the old path as deprecated.
+func Migrations(serviceRegistry *registry.ServiceRegistry, tracker *migration.Tracker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := serviceRegistry.FindMigration(c.Request.Method, c.Request.URL.Path)
+		if route == nil {
+			c.Next()
+			return
+		}
+
+		tracker.Record(route, c.ClientIP(), c.Request.UserAgent())
+
+		target := *c.Request.URL
+		target.Path = route.MigratedPath(c.Request.URL.Path)
+		target.RawPath = ""
+		c.Header("Deprecation", "true")
+		c.Header("Link", "<"+target.Path+">; rel=\"successor-version\"")
+
+		if route.MigrationMode == models.MigrationRewrite {
+			c.Request.URL.Path = target.Path
+			c.Request.URL.RawPath = ""
+			c.Next()
+			return
+		}
+
+		c.Redirect(http.StatusPermanentRedirect, target.RequestURI())
+		c.Abort()
+	}
+}
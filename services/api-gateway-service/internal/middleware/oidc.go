@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"gateway/internal/auth"
+	"gateway/internal/i18n"
+	"gateway/internal/oidc"
+	"gateway/internal/registry"
+	"gateway/internal/session"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OIDCLogin guards routes with oidc_login. Requests with a logged-in
+// session carry its identity on to PropagateIdentity; browsers without one
+// are redirected to the identity provider, while other clients get a 401
+// they can act on.
+func OIDCLogin(client *oidc.Client, sessions *session.Manager, serviceRegistry *registry.ServiceRegistry) gin.HandlerFunc {
+	cookie := sessions.Cookie()
+
+	return func(c *gin.Context) {
+		route, _ := serviceRegistry.FindRoute(c.Request.Method, c.Request.URL.Path)
+		if route == nil || !route.OIDCLogin {
+			c.Next()
+			return
+		}
+		// Session auth may already have identified the session
+		if _, identified := c.Get(IdentityKey); identified {
+			c.Next()
+			return
+		}
+
+		sess, err := sessions.Load(c.Request)
+		cookie.Strip(c.Request)
+
+		if err == nil && sess.UserID != "" && sess.Get(session.ValueIDToken) != "" {
+			c.Set(IdentityKey, &auth.Identity{
+				UserID: sess.UserID,
+				Email:  sess.Get(session.ValueEmail),
+				Name:   sess.Get(session.ValueName),
+			})
+			c.Next()
+			return
+		}
+
+		// Only page loads can follow a redirect through the login pages
+		if c.Request.Method != http.MethodGet || !strings.Contains(c.GetHeader("Accept"), "text/html") {
+			i18n.Error(c, http.StatusUnauthorized, "Unauthorized", i18n.LoginRequired)
+			c.Abort()
+			return
+		}
+
+		if err != nil {
+			if sess, err = sessions.New(); err != nil {
+				i18n.Error(c, http.StatusInternalServerError, "Session error", i18n.SessionCreateFailed)
+				c.Abort()
+				return
+			}
+		}
+
+		loginURL, err := startLogin(c, client, sess)
+		if err != nil {
+			log.Printf("OIDC login could not start: %v", err)
+			i18n.Error(c, http.StatusBadGateway, "Login unavailable", i18n.LoginUnavailable)
+			c.Abort()
+			return
+		}
+		if err := sessions.Save(c.Writer, c.Request, sess); err != nil {
+			log.Printf("OIDC failed to store session: %v", err)
+			i18n.Error(c, http.StatusInternalServerError, "Session error", i18n.SessionStoreFailed)
+			c.Abort()
+			return
+		}
+
+		c.Redirect(http.StatusFound, loginURL)
+		c.Abort()
+	}
+}
+
+// startLogin records the state, nonce and PKCE verifier the callback will
+// check in the session and returns the identity provider's login URL.
+func startLogin(c *gin.Context, client *oidc.Client, sess *session.Session) (string, error) {
+	values := make([]string, 3)
+	for i := range values {
+		value, err := oidc.RandomString()
+		if err != nil {
+			return "", err
+		}
+		values[i] = value
+	}
+	state, nonce, verifier := values[0], values[1], values[2]
+
+	loginURL, err := client.AuthCodeURL(c.Request.Context(), state, nonce, verifier)
+	if err != nil {
+		return "", err
+	}
+
+	sess.Set(session.ValueOIDCState, state)
+	sess.Set(session.ValueOIDCNonce, nonce)
+	sess.Set(session.ValueOIDCVerifier, verifier)
+	sess.Set(session.ValueOIDCReturnTo, c.Request.URL.RequestURI())
+	return loginURL, nil
+}
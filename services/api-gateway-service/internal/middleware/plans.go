@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"gateway/internal/auth"
+	"gateway/internal/plans"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PlanHeader names the usage plan a response was served under.
+const PlanHeader = "X-Plan"
+
+// Plans holds each client to the rate limit and quotas of its usage plan.
+// It runs after authentication, since plans can follow the user.
+func Plans(usagePlans *plans.Plans) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var identity *auth.Identity
+		if value, exists := c.Get(IdentityKey); exists {
+			identity = value.(*auth.Identity)
+		}
+		plan, subject := usagePlans.Resolve(c.GetHeader(usagePlans.Header()), identity, c.ClientIP())
+		if plan == nil {
+			c.Next()
+			return
+		}
+		c.Header(PlanHeader, plan.Name)
+
+		if plan.Limiter != nil {
+			quota := plan.Limiter.TakeN(subject, requestCost(c))
+			setRateLimitHeaders(c, quota)
+			if !quota.Allowed {
+				rejectRateLimited(c, quota.RetryAfter)
+				return
+			}
+		}
+		if plan.Quotas != nil && !enforceQuota(c, plan.Quotas, subject) {
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gateway/internal/i18n"
+	"gateway/internal/proxy"
+	"gateway/internal/quota"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Quota counts requests carrying an API key against the key's daily and
+// monthly allowances and rejects them once one is used up. Requests without
+// a key are left to authentication. Every counted response reports the
+// binding period in X-Quota headers.
+func Quota(quotas *quota.Quotas) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		apiKey := c.GetHeader(quotas.Header())
+		if apiKey == "" {
+			c.Next()
+			return
+		}
+
+		if enforceQuota(c, quotas, apiKey) {
+			c.Next()
+		}
+	}
+}
+
+// enforceQuota counts the request against subject's allowances and reports
+// whether it may proceed; otherwise the request has been rejected.
+func enforceQuota(c *gin.Context, quotas *quota.Quotas, subject string) bool {
+	usages, allowed, err := quotas.Consume(c.Request.Context(), subject)
+	if err != nil {
+		log.Printf("Quota store unavailable, allowing request: %v", err)
+		return true
+	}
+
+	usage, found := quota.Binding(usages)
+	if !found {
+		return true
+	}
+	resetIn := int(math.Ceil(time.Until(usage.Reset).Seconds()))
+	c.Header("X-Quota-Period", string(usage.Period))
+	c.Header("X-Quota-Limit", strconv.FormatInt(usage.Limit, 10))
+	c.Header("X-Quota-Remaining", strconv.FormatInt(usage.Remaining(), 10))
+	c.Header("X-Quota-Reset", strconv.Itoa(resetIn))
+
+	if !allowed {
+		c.Header("Retry-After", strconv.Itoa(resetIn))
+		proxy.SetTermination(c, proxy.TerminationQuotaExhausted)
+		i18n.ErrorWith(c, http.StatusTooManyRequests, "Quota exceeded",
+			gin.H{"reason": "quota_exhausted", "period": usage.Period, "reset": usage.Reset.Format(time.RFC3339)},
+			i18n.QuotaExhausted, usage.Period, usage.Limit)
+		c.Abort()
+		return false
+	}
+	return true
+}
@@ -0,0 +1,161 @@
+package middleware
+
+import (
+	"hash/fnv"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gateway/internal/auth"
+	"gateway/internal/i18n"
+	"gateway/internal/models"
+	"gateway/internal/proxy"
+	"gateway/internal/ratelimit"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// CostKey holds the tokens a request takes from rate limit buckets
+	CostKey = "rate_limit_cost"
+	// RateLimitCostHeader reports the cost of requests costing more than
+	// one token
+	RateLimitCostHeader = "X-RateLimit-Cost"
+)
+
+// RouteCost looks up the cost of the route a request is for, so the rate
+// limits after it take that many tokens from the caller's bucket.
+func RouteCost(serviceRegistry *registry.ServiceRegistry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if route, _ := serviceRegistry.FindRoute(c.Request.Method, c.Request.URL.Path); route != nil && route.TokenCost() > 1 {
+			c.Set(CostKey, route.TokenCost())
+			c.Header(RateLimitCostHeader, strconv.Itoa(route.TokenCost()))
+		}
+		c.Next()
+	}
+}
+
+// requestCost returns the tokens the request costs, one unless RouteCost
+// said otherwise.
+func requestCost(c *gin.Context) int {
+	if cost, ok := c.Get(CostKey); ok {
+		return cost.(int)
+	}
+	return 1
+}
+
+// RateLimit enforces policy with one token bucket per scope key. When
+// penalties is set, clients (identified by IP and User-Agent) that keep
+// receiving 429s, from the gateway or from upstreams, are held to a
+// stricter limit and have their rejections tarpitted. Every response
+// carries the X-RateLimit headers of the bucket the request counted
+// against.
+func RateLimit(policy models.RateLimitPolicy, limiter ratelimit.Taker, penalties *ratelimit.PenaltyBox) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		client := clientFingerprint(c, policy.Scope)
+		cost := requestCost(c)
+
+		var strict *ratelimit.Quota
+		if penalties != nil {
+			if penalized, _ := penalties.Penalty(client); penalized {
+				quota := penalties.TakeN(client, cost)
+				if !quota.Allowed {
+					setRateLimitHeaders(c, quota)
+					rejectPenalized(c, penalties, client, quota.RetryAfter)
+					return
+				}
+				strict = &quota
+			}
+		}
+
+		quota := limiter.TakeN(rateLimitKey(c, policy.Scope), cost)
+		// A penalized client sees whichever bucket runs out first
+		if strict != nil && quota.Allowed && strict.Remaining < quota.Remaining {
+			setRateLimitHeaders(c, *strict)
+		} else {
+			setRateLimitHeaders(c, quota)
+		}
+		if !quota.Allowed {
+			if penalties != nil {
+				rejectPenalized(c, penalties, client, quota.RetryAfter)
+				return
+			}
+			rejectRateLimited(c, quota.RetryAfter)
+			return
+		}
+
+		c.Next()
+
+		// Upstream 429s count too: a client ignoring them is hammering
+		if penalties != nil && c.Writer.Status() == http.StatusTooManyRequests {
+			penalties.Strike(client)
+		}
+	}
+}
+
+// setRateLimitHeaders reports quota to the client. X-RateLimit-Reset is the
+// number of seconds until the bucket is full again.
+func setRateLimitHeaders(c *gin.Context, quota ratelimit.Quota) {
+	c.Header("X-RateLimit-Limit", strconv.Itoa(quota.Limit))
+	c.Header("X-RateLimit-Remaining", strconv.Itoa(quota.Remaining))
+	c.Header("X-RateLimit-Reset", strconv.Itoa(int(math.Ceil(quota.Reset.Seconds()))))
+}
+
+// rejectPenalized counts the rejection against client and, once the client
+// is penalized, holds the response back before sending it.
+func rejectPenalized(c *gin.Context, penalties *ratelimit.PenaltyBox, client string, retryAfter time.Duration) {
+	penalties.Strike(client)
+	if penalized, delay := penalties.Penalty(client); penalized {
+		penalties.Tarpit(c.Request.Context(), delay)
+	}
+	rejectRateLimited(c, retryAfter)
+}
+
+func rejectRateLimited(c *gin.Context, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	proxy.SetTermination(c, proxy.TerminationRateLimited)
+	i18n.Error(c, http.StatusTooManyRequests, "Rate limit exceeded", i18n.RateLimited, seconds)
+	c.Abort()
+}
+
+// rateLimitKey returns the bucket a request counts against. per_user limits
+// key on the authenticated user, so they must run after authentication;
+// anonymous requests fall back to the client IP.
+func rateLimitKey(c *gin.Context, scope models.LimitScope) string {
+	switch scope {
+	case models.ScopeGlobal:
+		return "global"
+	case models.ScopePerUser:
+		if userID := limitedUser(c); userID != "" {
+			return "user:" + userID
+		}
+	}
+	return c.ClientIP()
+}
+
+func limitedUser(c *gin.Context) string {
+	if identity, ok := c.Get(IdentityKey); ok {
+		return identity.(*auth.Identity).UserID
+	}
+	return ""
+}
+
+// clientFingerprint tells apart clients sharing an IP address, such as
+// several retry loops behind one NAT, by their User-Agent. Under per_user
+// limits an authenticated user is one client whatever its address.
+func clientFingerprint(c *gin.Context, scope models.LimitScope) string {
+	if scope == models.ScopePerUser {
+		if userID := limitedUser(c); userID != "" {
+			return "user:" + userID
+		}
+	}
+	hash := fnv.New64a()
+	hash.Write([]byte(c.Request.UserAgent()))
+	return c.ClientIP() + "/" + strconv.FormatUint(hash.Sum64(), 36)
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"net/http"
+
+	"gateway/internal/i18n"
+	"gateway/internal/models"
+	"gateway/internal/proxy"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestLimits refuses requests whose URL is longer than limits allow
+// with a 414, and those with too many or too large headers with a 431.
+// The body names the limit exceeded, so a client can tell which one.
+func RequestLimits(limits models.RequestLimits) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if limits.MaxURLLength > 0 && len(c.Request.RequestURI) > limits.MaxURLLength {
+			proxy.SetTermination(c, proxy.TerminationURLTooLong)
+			i18n.ErrorWith(c, http.StatusRequestURITooLong, "URI too long",
+				gin.H{"limit": "max_url_length", "max": limits.MaxURLLength}, i18n.URLTooLong)
+			c.Abort()
+			return
+		}
+		if fields := exceededHeaderLimit(c.Request, limits); fields != nil {
+			proxy.SetTermination(c, proxy.TerminationHeadersTooLarge)
+			i18n.ErrorWith(c, http.StatusRequestHeaderFieldsTooLarge, "Request header fields too large", fields, i18n.HeadersTooLarge)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// exceededHeaderLimit returns the error fields of the first header limit
+// the request exceeds, or nil. Lines are measured as sent, "Name: value"
+// and its line break.
+func exceededHeaderLimit(r *http.Request, limits models.RequestLimits) gin.H {
+	count, size := 0, 0
+	if r.Host != "" {
+		count, size = 1, len("Host: \r\n")+len(r.Host)
+	}
+	for name, values := range r.Header {
+		for _, value := range values {
+			length := len(name) + len(value) + len(": \r\n")
+			if limits.MaxHeaderLength > 0 && length > limits.MaxHeaderLength {
+				return gin.H{"limit": "max_header_length", "max": limits.MaxHeaderLength, "header": name}
+			}
+			count++
+			size += length
+		}
+	}
+	if limits.MaxHeaders > 0 && count > limits.MaxHeaders {
+		return gin.H{"limit": "max_headers", "max": limits.MaxHeaders}
+	}
+	if limits.MaxHeaderBytes > 0 && size > limits.MaxHeaderBytes {
+		return gin.H{"limit": "max_header_bytes", "max": limits.MaxHeaderBytes}
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"gateway/internal/session"
+
+	"github.com/gin-gonic/gin"
+)
+
+const ContextSessionKey = "session"
+
+// LoadSession makes the caller's session (if any) available to later
+// handlers through SessionFromContext. Sessions still sealed with a retired
+// key are re-sealed on the way through.
+func LoadSession(manager *session.Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sess, err := manager.Load(c.Request)
+		if err == nil {
+			if sess.Stale() {
+				manager.Save(c.Writer, c.Request, sess)
+			}
+			c.Set(ContextSessionKey, sess)
+		}
+		c.Next()
+	}
+}
+
+func SessionFromContext(c *gin.Context) (*session.Session, bool) {
+	value, exists := c.Get(ContextSessionKey)
+	if !exists {
+		return nil, false
+	}
+	sess, ok := value.(*session.Session)
+	return sess, ok
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"gateway/internal/auth"
+	"gateway/internal/i18n"
+	"gateway/internal/models"
+	"gateway/internal/registry"
+	"gateway/internal/session"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SessionAuth identifies requests by their session cookie, for
+// server-rendered frontends that have no token to send. The identity then
+// satisfies auth_mode required like a verified token would. Only
+// sessions created by /session/login are accepted: OIDC and BFF sessions
+// are handled by their own middleware. The identity comes from the default
+// auth service, so routes naming another auth_provider still need that
+// provider's token. Requests with an Authorization header keep using
+// bearer auth.
+func SessionAuth(sessions *session.Manager, serviceRegistry *registry.ServiceRegistry, config models.SessionAuthConfig) gin.HandlerFunc {
+	cookie := sessions.Cookie()
+
+	return func(c *gin.Context) {
+		if _, identified := c.Get(IdentityKey); identified || c.GetHeader("Authorization") != "" {
+			c.Next()
+			return
+		}
+		if route, _ := serviceRegistry.FindRoute(c.Request.Method, c.Request.URL.Path); route != nil && route.AuthProvider != "" {
+			c.Next()
+			return
+		}
+
+		sess, err := sessions.Load(c.Request)
+		if err != nil || sess.UserID == "" || sess.Get(session.ValueSource) != session.SourceSessionLogin {
+			c.Next()
+			return
+		}
+		cookie.Strip(c.Request)
+
+		// Cookies are sent automatically by browsers, so state-changing
+		// requests must prove they came from our own frontend code.
+		if !isSafeMethod(c.Request.Method) && config.CSRFHeader != "" && c.GetHeader(config.CSRFHeader) == "" {
+			i18n.Error(c, http.StatusForbidden, "CSRF check failed", i18n.CSRFHeaderMissing, config.CSRFHeader)
+			c.Abort()
+			return
+		}
+
+		// Sliding expiration, without a store write on every request
+		if time.Until(sess.ExpiresAt) < sessions.TTL()-config.RefreshAfter || sess.Stale() {
+			if err := sessions.Touch(c.Writer, c.Request, sess); err != nil {
+				log.Printf("Session auth failed to extend session: %v", err)
+			}
+		}
+
+		c.Set(IdentityKey, &auth.Identity{
+			UserID: sess.UserID,
+			Email:  sess.Get(session.ValueEmail),
+			Name:   sess.Get(session.ValueName),
+			Scopes: strings.Fields(sess.Get(session.ValueScopes)),
+		})
+		c.Next()
+	}
+}
@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"time"
+
+	"gateway/internal/registry"
+	"gateway/internal/slo"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TrackSLOs counts the requests of routes and services with an SLO in
+// tracker, timed like TrackLatency.
+func TrackSLOs(tracker *slo.Tracker, serviceRegistry *registry.ServiceRegistry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route, service := serviceRegistry.FindRoute(c.Request.Method, c.Request.URL.Path)
+		if (route == nil || route.SLO == nil) && (service == nil || service.SLO == nil) {
+			c.Next()
+			return
+		}
+		start := time.Now()
+		c.Next()
+		tracker.Record(route, service, c.Writer.Status(), time.Since(start))
+	}
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"context"
+
+	"gateway/internal/proxy"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RouteTimeout bounds the whole handling of a request to a route with a
+// timeout, unlike the server's read and write timeouts, which apply to the
+// connection. The upstream request is cancelled once the route's timeout
+// passes, and the client gets a 504 with X-Timeout-Reason: route_timeout
+// rather than one of the upstream's making. Composite routes already derive
+// their legs' timeouts from theirs, and WebSockets outlive any timeout.
+func RouteTimeout(serviceRegistry *registry.ServiceRegistry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route, _ := serviceRegistry.FindRoute(c.Request.Method, c.Request.URL.Path)
+		if route == nil || route.Timeout <= 0 || route.IsComposite() || proxy.IsWebSocket(c.Request) {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), route.Timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		c.Set(proxy.RouteDeadlineKey, ctx)
+		c.Next()
+
+		// Handlers that gave up on the deadline without answering are
+		// answered for
+		if !c.Writer.Written() && proxy.RouteTimedOut(c) {
+			proxy.RespondRouteTimeout(c, route)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"gateway/internal/auth"
+	"gateway/internal/i18n"
+	"gateway/internal/models"
+	"gateway/internal/session"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TokenRelay attaches the access token held in the caller's BFF session to
+// the proxied request, refreshing it first when it is about to expire.
+// Requests that already carry an Authorization header pass through untouched.
+func TokenRelay(sessions *session.Manager, client *auth.Client, config models.BFFConfig) gin.HandlerFunc {
+	refreshes := &refreshFlights{calls: make(map[string]*refreshCall)}
+	cookie := sessions.Cookie()
+
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") != "" {
+			c.Next()
+			return
+		}
+
+		sess, err := sessions.Load(c.Request)
+		// The session cookie is a gateway credential, never an upstream one
+		cookie.Strip(c.Request)
+		// OIDC login sessions hold an identity but no token to relay
+		if err != nil || sess.Get(session.ValueAccessToken) == "" {
+			c.Next()
+			return
+		}
+
+		// Cookies are sent automatically by browsers, so state-changing
+		// requests must prove they came from our own frontend code.
+		if !isSafeMethod(c.Request.Method) && config.CSRFHeader != "" && c.GetHeader(config.CSRFHeader) == "" {
+			i18n.Error(c, http.StatusForbidden, "CSRF check failed", i18n.CSRFHeaderMissing, config.CSRFHeader)
+			c.Abort()
+			return
+		}
+
+		if time.Until(sess.AccessTokenExpiry()) < config.RefreshBefore {
+			refreshed, err := refreshes.do(sess.ID, func() (*session.Session, error) {
+				return refreshSession(c, sessions, client, sess, config.RefreshBefore)
+			})
+
+			if err != nil {
+				log.Printf("BFF token refresh failed for session: %v", err)
+				sessions.Invalidate(c.Request.Context(), sess.ID)
+				cookie.Clear(c.Writer)
+				c.Next()
+				return
+			}
+			sess = refreshed
+		} else if sess.Stale() {
+			sessions.Save(c.Writer, c.Request, sess)
+		}
+
+		c.Request.Header.Set("Authorization", "Bearer "+sess.Get(session.ValueAccessToken))
+		c.Next()
+	}
+}
+
+// refreshFlights runs one token refresh per session at a time. Requests
+// that need a refresh while one is running wait for it and share its
+// result, so a refresh token is never spent twice.
+type refreshFlights struct {
+	mutex sync.Mutex
+	calls map[string]*refreshCall
+}
+
+type refreshCall struct {
+	done chan struct{}
+	sess *session.Session
+	err  error
+}
+
+func (f *refreshFlights) do(id string, refresh func() (*session.Session, error)) (*session.Session, error) {
+	f.mutex.Lock()
+	if call, running := f.calls[id]; running {
+		f.mutex.Unlock()
+		<-call.done
+		return call.sess, call.err
+	}
+	call := &refreshCall{done: make(chan struct{})}
+	f.calls[id] = call
+	f.mutex.Unlock()
+
+	call.sess, call.err = refresh()
+
+	f.mutex.Lock()
+	delete(f.calls, id)
+	f.mutex.Unlock()
+	close(call.done)
+	return call.sess, call.err
+}
+
+// refreshSession re-reads server-side sessions first, so a request that
+// arrives just after another refreshed the token reuses it.
+func refreshSession(c *gin.Context, sessions *session.Manager, client *auth.Client, sess *session.Session, refreshBefore time.Duration) (*session.Session, error) {
+	if current, err := sessions.Get(c.Request.Context(), sess.ID); err == nil {
+		sess = current
+	}
+	if time.Until(sess.AccessTokenExpiry()) >= refreshBefore {
+		return sess, nil
+	}
+
+	tokens, err := client.Refresh(c.Request.Context(), sess.Get(session.ValueRefreshToken))
+	if err != nil {
+		return nil, err
+	}
+
+	sess.SetTokens(tokens.AccessToken, tokens.RefreshToken, tokens.ExpiresIn)
+	if err := sessions.Save(c.Writer, c.Request, sess); err != nil {
+		return nil, err
+	}
+	return sess, nil
+}
+
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
@@ -0,0 +1,110 @@
+package migration
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"gateway/internal/models"
+)
+
+// maxClientsPerRoute bounds memory per migrated route; further clients are
+// only counted in OtherRequests.
+const maxClientsPerRoute = 1000
+
+// Tracker counts requests still using migrated paths, per client, so it is
+// clear when an alias has no users left and can be deleted.
+type Tracker struct {
+	routes map[string]*routeTraffic
+	mutex  sync.Mutex
+}
+
+type routeTraffic struct {
+	RouteTraffic
+	clients map[string]*ClientTraffic
+}
+
+// RouteTraffic is the traffic seen on one migrated path.
+type RouteTraffic struct {
+	From          string               `json:"from"`
+	To            string               `json:"to"`
+	Mode          models.MigrationMode `json:"mode"`
+	Requests      int64                `json:"requests"`
+	OtherRequests int64                `json:"other_requests,omitempty"`
+	LastSeen      time.Time            `json:"last_seen"`
+	Clients       []ClientTraffic      `json:"clients"`
+}
+
+// ClientTraffic identifies a client by IP and User-Agent, which usually
+// points at the app version still calling the old path.
+type ClientTraffic struct {
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	Requests  int64     `json:"requests"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+func NewTracker() *Tracker {
+	return &Tracker{routes: make(map[string]*routeTraffic)}
+}
+
+func (t *Tracker) Record(route *models.RouteConfig, ip, userAgent string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := time.Now()
+	traffic, exists := t.routes[route.Path]
+	if !exists {
+		traffic = &routeTraffic{
+			RouteTraffic: RouteTraffic{From: route.Path},
+			clients:      make(map[string]*ClientTraffic),
+		}
+		t.routes[route.Path] = traffic
+	}
+	traffic.To = route.MigrateTo
+	traffic.Mode = route.MigrationMode
+	traffic.Requests++
+	traffic.LastSeen = now
+
+	key := ip + " " + userAgent
+	client, exists := traffic.clients[key]
+	if !exists {
+		if len(traffic.clients) >= maxClientsPerRoute {
+			traffic.OtherRequests++
+			return
+		}
+		client = &ClientTraffic{IP: ip, UserAgent: userAgent, FirstSeen: now}
+		traffic.clients[key] = client
+	}
+	client.Requests++
+	client.LastSeen = now
+}
+
+// Snapshot returns the traffic per migrated path, busiest clients first.
+func (t *Tracker) Snapshot() []RouteTraffic {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	result := make([]RouteTraffic, 0, len(t.routes))
+	for _, traffic := range t.routes {
+		snapshot := traffic.RouteTraffic
+		snapshot.Clients = make([]ClientTraffic, 0, len(traffic.clients))
+		for _, client := range traffic.clients {
+			snapshot.Clients = append(snapshot.Clients, *client)
+		}
+		sort.Slice(snapshot.Clients, func(i, j int) bool {
+			return snapshot.Clients[i].Requests > snapshot.Clients[j].Requests
+		})
+		result = append(result, snapshot)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].From < result[j].From })
+	return result
+}
+
+func (t *Tracker) Reset() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.routes = make(map[string]*routeTraffic)
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// AnomalyConfig samples successful upstream responses per route and flags
+// windows whose content no longer looks like the route's usual responses,
+// such as an HTML error page served with a 200 where JSON is expected.
+type AnomalyConfig struct {
+	Enabled bool `json:"enabled" yaml:"enabled" mapstructure:"enabled"`
+	// SampleRate is the share of 2xx responses inspected
+	SampleRate float64       `json:"sample_rate" yaml:"sample_rate" mapstructure:"sample_rate"`
+	Window     time.Duration `json:"window" yaml:"window" mapstructure:"window"`
+	// MinSamples is the number of samples a window, and the baseline
+	// learned from earlier windows, need before a window is judged
+	MinSamples int `json:"min_samples" yaml:"min_samples" mapstructure:"min_samples"`
+	// MismatchRatio is the share of a window's samples that may look
+	// unlike the baseline before the window is anomalous
+	MismatchRatio float64 `json:"mismatch_ratio" yaml:"mismatch_ratio" mapstructure:"mismatch_ratio"`
+	// SizeFactor flags windows whose mean body size grew or shrank by more
+	// than this factor; 0 disables the size check
+	SizeFactor float64 `json:"size_factor" yaml:"size_factor" mapstructure:"size_factor"`
+	// MaxBodySize is the most of a body read to learn its shape; larger
+	// bodies are only sized
+	MaxBodySize int64 `json:"max_body_size" yaml:"max_body_size" mapstructure:"max_body_size"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditRecord is a change made through an admin endpoint: which admin
+// token made it, what it asked for and how it was answered. Before and
+// After hold the state the endpoint changed, when it reports one.
+//
+// Each record's Hash covers the record and the Hash of the one before it,
+// so a record edited or removed from the audit file breaks the chain.
+type AuditRecord struct {
+	ID            int64           `json:"id"`
+	Timestamp     time.Time       `json:"timestamp"`
+	Actor         string          `json:"actor"`
+	ClientIP      string          `json:"client_ip"`
+	Method        string          `json:"method"`
+	Path          string          `json:"path"`
+	Query         string          `json:"query,omitempty"`
+	Status        int             `json:"status"`
+	Before        json.RawMessage `json:"before,omitempty"`
+	After         json.RawMessage `json:"after,omitempty"`
+	CorrelationID string          `json:"correlation_id,omitempty"`
+	PreviousHash  string          `json:"previous_hash"`
+	Hash          string          `json:"hash"`
+}
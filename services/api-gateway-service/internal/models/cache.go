@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+)
+
+// CacheConfig controls the in-memory response cache. Only routes with a
+// cache_ttl are cached.
+type CacheConfig struct {
+	Enabled     bool         `json:"enabled" yaml:"enabled" mapstructure:"enabled"`
+	MaxEntries  int          `json:"max_entries" yaml:"max_entries" mapstructure:"max_entries"`
+	MaxBodySize int64        `json:"max_body_size" yaml:"max_body_size" mapstructure:"max_body_size"`
+	Warmup      WarmupConfig `json:"warmup" yaml:"warmup" mapstructure:"warmup"`
+}
+
+// WarmupConfig lists GET requests replayed through the cache so popular
+// pages are hot before traffic arrives. Runs happen on startup, every
+// Interval, and/or daily at the local times in At ("06:30").
+type WarmupConfig struct {
+	Paths       []string      `json:"paths" yaml:"paths" mapstructure:"paths"`
+	OnStartup   bool          `json:"on_startup" yaml:"on_startup" mapstructure:"on_startup"`
+	Interval    time.Duration `json:"interval,omitempty" yaml:"interval,omitempty" mapstructure:"interval"`
+	At          []string      `json:"at,omitempty" yaml:"at,omitempty" mapstructure:"at"`
+	Concurrency int           `json:"concurrency" yaml:"concurrency" mapstructure:"concurrency"`
+}
+
+// ParseDailyTime parses an "HH:MM" warmup time.
+func ParseDailyTime(value string) (hour, minute int, err error) {
+	parsed, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, 0, err
+	}
+	return parsed.Hour(), parsed.Minute(), nil
+}
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// CanaryConfig sends a share of a route's requests to another service,
+// typically a new version of the route's own, while the rest keep going to
+// the stable one.
+type CanaryConfig struct {
+	ServiceName string `json:"service_name" yaml:"service_name" mapstructure:"service_name"`
+	// Weight is the percentage of requests sent to the canary
+	Weight   int             `json:"weight" yaml:"weight" mapstructure:"weight"`
+	Rollback *CanaryRollback `json:"rollback,omitempty" yaml:"rollback,omitempty" mapstructure:"rollback"`
+}
+
+// CanaryRollback shifts all of a route's traffic back to the stable service
+// once the canary's error rate exceeds the stable service's by more than
+// MaxErrorRateIncrease within one window. Failures are 5xx responses,
+// timeouts and connection errors.
+type CanaryRollback struct {
+	MaxErrorRateIncrease float64       `json:"max_error_rate_increase" yaml:"max_error_rate_increase" mapstructure:"max_error_rate_increase"`
+	Window               time.Duration `json:"window" yaml:"window" mapstructure:"window"`
+	// MinRequests is the canary traffic a window needs before it is judged
+	MinRequests int `json:"min_requests" yaml:"min_requests" mapstructure:"min_requests"`
+}
+
+const (
+	DefaultCanaryWindow      = time.Minute
+	DefaultCanaryMinRequests = 20
+)
+
+// Defaults fills in the window and minimum traffic left unset.
+func (r CanaryRollback) Defaults() CanaryRollback {
+	if r.Window <= 0 {
+		r.Window = DefaultCanaryWindow
+	}
+	if r.MinRequests <= 0 {
+		r.MinRequests = DefaultCanaryMinRequests
+	}
+	return r
+}
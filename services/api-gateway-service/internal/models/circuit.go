@@ -12,25 +12,117 @@ const (
 	CircuitHalfOpen CircuitState = "half_open"
 )
 
+// CircuitStrategy decides which outcomes a closed circuit weighs: those
+// since the start of the current Interval, or those of the last Interval.
+type CircuitStrategy string
+
+const (
+	// CircuitFixedWindow counts outcomes in back-to-back intervals, so a
+	// new interval starts from nothing
+	CircuitFixedWindow CircuitStrategy = "fixed_window"
+	// CircuitSlidingWindow counts the outcomes of the last interval, in
+	// CircuitWindowBuckets buckets that expire one by one
+	CircuitSlidingWindow CircuitStrategy = "sliding_window"
+)
+
+// CircuitWindowBuckets is how many parts a sliding window's interval is
+// kept in.
+const CircuitWindowBuckets = 10
+
+// CircuitBreakerSettings decide when a service's circuit opens. Once at
+// least MaxRequests outcomes were counted within an Interval and the share
+// of failures reaches FailureThreshold, requests are turned away for
+// Timeout. Then MaxRequests probes are let through: if all succeed the
+// circuit closes, a failing one opens it again. With SlowCallDuration set,
+// answers slower than it count as failures too.
 type CircuitBreakerSettings struct {
-	MaxRequests      uint32        `json:"max_requests" yaml:"max_requests"`
-	Interval         time.Duration `json:"interval" yaml:"interval"`
-	Timeout          time.Duration `json:"timeout" yaml:"timeout"`
-	FailureThreshold float64       `json:"failure_threshold" yaml:"failure_threshold"`
+	Enabled          bool            `json:"enabled" yaml:"enabled" mapstructure:"enabled"`
+	Strategy         CircuitStrategy `json:"strategy" yaml:"strategy" mapstructure:"strategy"`
+	MaxRequests      uint32          `json:"max_requests" yaml:"max_requests" mapstructure:"max_requests"`
+	Interval         time.Duration   `json:"interval" yaml:"interval" mapstructure:"interval"`
+	Timeout          time.Duration   `json:"timeout" yaml:"timeout" mapstructure:"timeout"`
+	FailureThreshold float64         `json:"failure_threshold" yaml:"failure_threshold" mapstructure:"failure_threshold"`
+	SlowCallDuration time.Duration   `json:"slow_call_duration,omitempty" yaml:"slow_call_duration,omitempty" mapstructure:"slow_call_duration"`
+}
+
+// IsSlow reports whether a call that took elapsed counts as a failure.
+func (s CircuitBreakerSettings) IsSlow(elapsed time.Duration) bool {
+	return s.SlowCallDuration > 0 && elapsed > s.SlowCallDuration
+}
+
+// CircuitBreakerOverride replaces some of the gateway-wide circuit breaker
+// settings for one service or route, so a critical service can trip sooner
+// and stay open longer than the rest. Unset fields are inherited.
+type CircuitBreakerOverride struct {
+	Strategy         CircuitStrategy `json:"strategy,omitempty" yaml:"strategy,omitempty" mapstructure:"strategy"`
+	MaxRequests      uint32          `json:"max_requests,omitempty" yaml:"max_requests,omitempty" mapstructure:"max_requests"`
+	Interval         time.Duration   `json:"interval,omitempty" yaml:"interval,omitempty" mapstructure:"interval"`
+	Timeout          time.Duration   `json:"timeout,omitempty" yaml:"timeout,omitempty" mapstructure:"timeout"`
+	FailureThreshold float64         `json:"failure_threshold,omitempty" yaml:"failure_threshold,omitempty" mapstructure:"failure_threshold"`
+	SlowCallDuration time.Duration   `json:"slow_call_duration,omitempty" yaml:"slow_call_duration,omitempty" mapstructure:"slow_call_duration"`
+}
+
+// Apply returns settings with the fields set in the override replaced. A
+// nil override changes nothing.
+func (o *CircuitBreakerOverride) Apply(settings CircuitBreakerSettings) CircuitBreakerSettings {
+	if o == nil {
+		return settings
+	}
+	if o.Strategy != "" {
+		settings.Strategy = o.Strategy
+	}
+	if o.MaxRequests > 0 {
+		settings.MaxRequests = o.MaxRequests
+	}
+	if o.Interval > 0 {
+		settings.Interval = o.Interval
+	}
+	if o.Timeout > 0 {
+		settings.Timeout = o.Timeout
+	}
+	if o.FailureThreshold > 0 {
+		settings.FailureThreshold = o.FailureThreshold
+	}
+	if o.SlowCallDuration > 0 {
+		settings.SlowCallDuration = o.SlowCallDuration
+	}
+	return settings
 }
 
 type CircuitBreakerState struct {
-	ServiceName  string                  `json:"service_name"`
-	State        CircuitState            `json:"state"`
-	FailureCount int                     `json:"failure_count"`
-	SuccessCount int                     `json:"success_count"`
-	LastFailure  time.Time               `json:"last_failure"`
-	NextRetry    time.Time               `json:"next_retry"`
-	Settings     CircuitBreakerSettings  `json:"settings"`
+	ServiceName  string                 `json:"service_name"`
+	State        CircuitState           `json:"state"`
+	FailureCount int                    `json:"failure_count"`
+	SuccessCount int                    `json:"success_count"`
+	LastFailure  time.Time              `json:"last_failure"`
+	NextRetry    time.Time              `json:"next_retry"`
+	Settings     CircuitBreakerSettings `json:"settings"`
+
+	// WindowStart is when the current Interval of a closed circuit began
+	WindowStart time.Time `json:"window_start"`
+	// Probes are the half-open requests still in flight
+	Probes int `json:"probes"`
+	// SlowCallCount are the failures counted for being slow
+	SlowCallCount int `json:"slow_call_count"`
+	// Forced is set on a circuit opened by hand. It stays open until
+	// NextRetry, or until it is reset if NextRetry is zero.
+	Forced bool `json:"forced,omitempty"`
+
+	// buckets hold a sliding window's outcomes, oldest first
+	buckets []circuitBucket
+}
+
+type circuitBucket struct {
+	start     time.Time
+	failures  int
+	successes int
+	slow      int
 }
 
 func NewCircuitBreakerSettings(maxRequests uint32, interval, timeout time.Duration, failureThreshold float64) *CircuitBreakerSettings {
 	return &CircuitBreakerSettings{
+		Enabled:          true,
+		Strategy:         CircuitFixedWindow,
 		MaxRequests:      maxRequests,
 		Interval:         interval,
 		Timeout:          timeout,
@@ -43,17 +135,46 @@ func NewCircuitBreakerState(serviceName string, settings CircuitBreakerSettings)
 		ServiceName: serviceName,
 		State:       CircuitClosed,
 		Settings:    settings,
+		WindowStart: time.Now(),
+	}
+}
+
+// Reconfigure switches the circuit to new settings, such as after a config
+// reload. A closed circuit whose window changed starts counting afresh.
+func (c *CircuitBreakerState) Reconfigure(settings CircuitBreakerSettings) {
+	windowChanged := settings.Strategy != c.Settings.Strategy || settings.Interval != c.Settings.Interval
+	c.Settings = settings
+	if windowChanged && c.State == CircuitClosed {
+		c.reset()
+		c.WindowStart = time.Now()
 	}
 }
 
+// CanRequest reports whether a request may go through. An open circuit
+// whose timeout has passed turns half-open, and a half-open circuit lets
+// through as many probes as it still needs successes. A request let
+// through must be followed by RecordSuccess, RecordFailure or Release.
 func (c *CircuitBreakerState) CanRequest() bool {
+	now := time.Now()
 	switch c.State {
 	case CircuitClosed:
+		c.roll(now)
 		return true
 	case CircuitOpen:
-		return time.Now().After(c.NextRetry)
+		if !c.RetryDue(now) {
+			return false
+		}
+		c.State = CircuitHalfOpen
+		c.Forced = false
+		c.reset()
+		c.Probes = 0
+		fallthrough
 	case CircuitHalfOpen:
-		return c.SuccessCount < int(c.Settings.MaxRequests)
+		if c.SuccessCount+c.Probes >= c.probes() {
+			return false
+		}
+		c.Probes++
+		return true
 	default:
 		return false
 	}
@@ -62,34 +183,97 @@ func (c *CircuitBreakerState) CanRequest() bool {
 func (c *CircuitBreakerState) RecordSuccess() {
 	switch c.State {
 	case CircuitClosed:
-		c.FailureCount = 0
+		now := time.Now()
+		c.roll(now)
+		c.SuccessCount++
+		if bucket := c.bucket(now); bucket != nil {
+			bucket.successes++
+		}
 	case CircuitHalfOpen:
+		c.release()
 		c.SuccessCount++
-		if c.SuccessCount >= int(c.Settings.MaxRequests) {
-			c.State = CircuitClosed
-			c.FailureCount = 0
-			c.SuccessCount = 0
+		if c.SuccessCount >= c.probes() {
+			c.close(time.Now())
 		}
 	}
 }
 
 func (c *CircuitBreakerState) RecordFailure() {
-	c.FailureCount++
-	c.LastFailure = time.Now()
+	c.recordFailure(false)
+}
+
+// RecordSlowCall records an answer that took longer than SlowCallDuration,
+// which counts as a failure.
+func (c *CircuitBreakerState) RecordSlowCall() {
+	c.recordFailure(true)
+}
+
+func (c *CircuitBreakerState) recordFailure(slow bool) {
+	now := time.Now()
+	c.LastFailure = now
 
 	switch c.State {
 	case CircuitClosed:
+		c.roll(now)
+		c.FailureCount++
+		if slow {
+			c.SlowCallCount++
+		}
+		if bucket := c.bucket(now); bucket != nil {
+			bucket.failures++
+			if slow {
+				bucket.slow++
+			}
+		}
 		if c.shouldOpenCircuit() {
-			c.State = CircuitOpen
-			c.NextRetry = time.Now().Add(c.Settings.Timeout)
+			c.open(now)
 		}
 	case CircuitHalfOpen:
-		c.State = CircuitOpen
-		c.NextRetry = time.Now().Add(c.Settings.Timeout)
-		c.SuccessCount = 0
+		c.open(now)
+	}
+}
+
+// Release hands back a request let through whose outcome says nothing
+// about the service, such as one the client abandoned.
+func (c *CircuitBreakerState) Release() {
+	if c.State == CircuitHalfOpen {
+		c.release()
 	}
 }
 
+// ForceOpen opens the circuit by hand, such as for maintenance of the
+// service, until the given time or, if it is zero, until Reset.
+func (c *CircuitBreakerState) ForceOpen(until time.Time) {
+	c.open(time.Now())
+	c.NextRetry = until
+	c.Forced = true
+}
+
+// Reset closes the circuit and forgets its counts.
+func (c *CircuitBreakerState) Reset() {
+	c.close(time.Now())
+}
+
+// RetryDue reports whether an open circuit is due to let probes through.
+func (c *CircuitBreakerState) RetryDue(now time.Time) bool {
+	if c.Forced && c.NextRetry.IsZero() {
+		return false
+	}
+	return !now.Before(c.NextRetry)
+}
+
+// RetryAfter is how long an open circuit keeps turning requests away. A
+// circuit forced open until reset suggests its Timeout.
+func (c *CircuitBreakerState) RetryAfter() time.Duration {
+	if c.State != CircuitOpen {
+		return 0
+	}
+	if c.Forced && c.NextRetry.IsZero() {
+		return c.Settings.Timeout
+	}
+	return time.Until(c.NextRetry)
+}
+
 func (c *CircuitBreakerState) shouldOpenCircuit() bool {
 	totalRequests := c.FailureCount + c.SuccessCount
 	if totalRequests < int(c.Settings.MaxRequests) {
@@ -98,4 +282,89 @@ func (c *CircuitBreakerState) shouldOpenCircuit() bool {
 
 	failureRate := float64(c.FailureCount) / float64(totalRequests)
 	return failureRate >= c.Settings.FailureThreshold
-}
\ No newline at end of file
+}
+
+// roll forgets the outcomes that fell out of the counting window: all of
+// them once a fixed window's Interval has passed, or a sliding window's
+// buckets older than Interval.
+func (c *CircuitBreakerState) roll(now time.Time) {
+	if c.Settings.Interval <= 0 {
+		return
+	}
+	if c.Settings.Strategy != CircuitSlidingWindow {
+		if now.Sub(c.WindowStart) >= c.Settings.Interval {
+			c.reset()
+			c.WindowStart = now
+		}
+		return
+	}
+
+	expired := 0
+	for _, bucket := range c.buckets {
+		if now.Sub(bucket.start) < c.Settings.Interval {
+			break
+		}
+		c.FailureCount -= bucket.failures
+		c.SuccessCount -= bucket.successes
+		c.SlowCallCount -= bucket.slow
+		expired++
+	}
+	c.buckets = c.buckets[expired:]
+	if len(c.buckets) > 0 {
+		c.WindowStart = c.buckets[0].start
+	} else {
+		c.WindowStart = now
+	}
+}
+
+// bucket returns the sliding window bucket outcomes at now go in, or nil
+// for a fixed window.
+func (c *CircuitBreakerState) bucket(now time.Time) *circuitBucket {
+	if c.Settings.Strategy != CircuitSlidingWindow || c.Settings.Interval <= 0 {
+		return nil
+	}
+	width := c.Settings.Interval / CircuitWindowBuckets
+	if last := len(c.buckets) - 1; last >= 0 && now.Sub(c.buckets[last].start) < width {
+		return &c.buckets[last]
+	}
+	c.buckets = append(c.buckets, circuitBucket{start: now})
+	return &c.buckets[len(c.buckets)-1]
+}
+
+func (c *CircuitBreakerState) reset() {
+	c.FailureCount = 0
+	c.SuccessCount = 0
+	c.SlowCallCount = 0
+	c.buckets = nil
+}
+
+func (c *CircuitBreakerState) open(now time.Time) {
+	c.State = CircuitOpen
+	c.Forced = false
+	c.NextRetry = now.Add(c.Settings.Timeout)
+	c.SuccessCount = 0
+	c.Probes = 0
+	c.buckets = nil
+}
+
+func (c *CircuitBreakerState) close(now time.Time) {
+	c.State = CircuitClosed
+	c.Forced = false
+	c.reset()
+	c.Probes = 0
+	c.WindowStart = now
+}
+
+func (c *CircuitBreakerState) release() {
+	if c.Probes > 0 {
+		c.Probes--
+	}
+}
+
+// probes is how many successful probes close a half-open circuit.
+func (c *CircuitBreakerState) probes() int {
+	if c.Settings.MaxRequests < 1 {
+		return 1
+	}
+	return int(c.Settings.MaxRequests)
+}
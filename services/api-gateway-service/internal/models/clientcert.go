@@ -0,0 +1,77 @@
+package models
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"strings"
+)
+
+// ClientCertPolicy authenticates a route's clients by their TLS client
+// certificate instead of a token. The certificate must chain to the
+// server's client_ca_file; the first of Identities it matches is the
+// identity passed upstream.
+type ClientCertPolicy struct {
+	Identities []CertIdentity `json:"identities" yaml:"identities" mapstructure:"identities"`
+}
+
+// CertIdentity matches certificates by one of their subject alternative
+// names (DNS name, URI, email or IP address) or by the SHA-256 fingerprint
+// of the certificate, in hex with or without colons.
+type CertIdentity struct {
+	SAN         string   `json:"san,omitempty" yaml:"san,omitempty" mapstructure:"san"`
+	Fingerprint string   `json:"fingerprint,omitempty" yaml:"fingerprint,omitempty" mapstructure:"fingerprint"`
+	UserID      string   `json:"user_id" yaml:"user_id" mapstructure:"user_id"`
+	Name        string   `json:"name,omitempty" yaml:"name,omitempty" mapstructure:"name"`
+	Scopes      []string `json:"scopes,omitempty" yaml:"scopes,omitempty" mapstructure:"scopes"`
+}
+
+// Identify returns the identity cert authenticates on the route, or nil.
+func (p *ClientCertPolicy) Identify(cert *x509.Certificate) *CertIdentity {
+	fingerprint := CertFingerprint(cert)
+	for i := range p.Identities {
+		identity := &p.Identities[i]
+		if identity.Fingerprint != "" && normalizeFingerprint(identity.Fingerprint) == fingerprint {
+			return identity
+		}
+		if identity.SAN != "" && hasSAN(cert, identity.SAN) {
+			return identity
+		}
+	}
+	return nil
+}
+
+// CertFingerprint is the lowercase hex SHA-256 of the certificate.
+func CertFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+func normalizeFingerprint(fingerprint string) string {
+	fingerprint = strings.TrimPrefix(strings.ToLower(fingerprint), "sha256:")
+	return strings.ReplaceAll(fingerprint, ":", "")
+}
+
+func hasSAN(cert *x509.Certificate, san string) bool {
+	for _, name := range cert.DNSNames {
+		if strings.EqualFold(name, san) {
+			return true
+		}
+	}
+	for _, email := range cert.EmailAddresses {
+		if strings.EqualFold(email, san) {
+			return true
+		}
+	}
+	for _, uri := range cert.URIs {
+		if uri.String() == san {
+			return true
+		}
+	}
+	for _, ip := range cert.IPAddresses {
+		if ip.String() == san {
+			return true
+		}
+	}
+	return false
+}
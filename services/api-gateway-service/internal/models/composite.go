@@ -0,0 +1,96 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+// BudgetSplit decides how a composite route's timeout is shared between
+// its stages.
+type BudgetSplit string
+
+const (
+	// SplitEqual gives every stage the same share of the budget
+	SplitEqual BudgetSplit = "equal"
+	// SplitWeighted shares the budget in proportion to stage weights
+	SplitWeighted BudgetSplit = "weighted"
+	// SplitRolling shares whatever is left when a stage starts between it
+	// and the stages after it, so time saved by fast stages carries over
+	SplitRolling BudgetSplit = "rolling"
+)
+
+// MergeStrategy decides how leg responses become one response.
+type MergeStrategy string
+
+const (
+	// MergeNested puts each leg's response under the leg's name
+	MergeNested MergeStrategy = "nested"
+	// MergeConcat concatenates the arrays returned by the legs
+	MergeConcat MergeStrategy = "concat"
+	// MergeFirstSuccess answers with the first leg that succeeds; later
+	// stages only fire while no earlier leg has, acting as fallbacks
+	MergeFirstSuccess MergeStrategy = "first_success"
+)
+
+// CompositeConfig turns a route into an aggregation of several upstream
+// calls ("legs") answered as one response. Legs with the same stage run
+// concurrently; stages run in ascending order. Merged responses list legs
+// in the order they are declared here.
+type CompositeConfig struct {
+	Split BudgetSplit    `json:"split,omitempty" yaml:"split,omitempty" mapstructure:"split"`
+	Merge MergeStrategy  `json:"merge,omitempty" yaml:"merge,omitempty" mapstructure:"merge"`
+	Legs  []CompositeLeg `json:"legs" yaml:"legs" mapstructure:"legs"`
+	// PartialResults answers with whatever legs succeeded, wrapped in an
+	// envelope with per-leg status, instead of failing the whole response.
+	PartialResults bool `json:"partial_results,omitempty" yaml:"partial_results,omitempty" mapstructure:"partial_results"`
+}
+
+type CompositeLeg struct {
+	Name        string `json:"name" yaml:"name" mapstructure:"name"`
+	ServiceName string `json:"service_name" yaml:"service_name" mapstructure:"service_name"`
+	Path        string `json:"path" yaml:"path" mapstructure:"path"`
+	Stage       int    `json:"stage,omitempty" yaml:"stage,omitempty" mapstructure:"stage"`
+	// Weight is the leg's claim on the budget under weighted and rolling
+	// splits; a stage weighs as much as its heaviest leg. Defaults to 1.
+	Weight float64 `json:"weight,omitempty" yaml:"weight,omitempty" mapstructure:"weight"`
+	// Timeout caps the leg below its derived share of the budget.
+	Timeout time.Duration `json:"timeout,omitempty" yaml:"timeout,omitempty" mapstructure:"timeout"`
+	// Required legs fail the response even with partial results.
+	Required bool `json:"required,omitempty" yaml:"required,omitempty" mapstructure:"required"`
+}
+
+// CompositeStage is a group of legs fired together.
+type CompositeStage struct {
+	Legs   []CompositeLeg
+	Weight float64
+}
+
+// Stages groups the legs by stage, in execution order.
+func (c *CompositeConfig) Stages() []CompositeStage {
+	byStage := make(map[int]*CompositeStage)
+	order := make([]int, 0)
+	for _, leg := range c.Legs {
+		stage, exists := byStage[leg.Stage]
+		if !exists {
+			stage = &CompositeStage{}
+			byStage[leg.Stage] = stage
+			order = append(order, leg.Stage)
+		}
+		stage.Legs = append(stage.Legs, leg)
+
+		weight := leg.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		if weight > stage.Weight {
+			stage.Weight = weight
+		}
+	}
+
+	sort.Ints(order)
+	stages := make([]CompositeStage, len(order))
+	for i, number := range order {
+		stages[i] = *byStage[number]
+	}
+	return stages
+}
@@ -1,17 +1,51 @@
 package models
 
 import (
+	"strings"
 	"time"
 )
 
 type GatewayConfig struct {
-	Server         ServerConfig               `json:"server" yaml:"server"`
-	Services       map[string]ServiceConfig   `json:"services" yaml:"services"`
-	Routes         []RouteConfig              `json:"routes" yaml:"routes"`
-	RateLimit      RateLimitPolicy            `json:"rate_limit" yaml:"rate_limit"`
-	CircuitBreaker CircuitBreakerSettings     `json:"circuit_breaker" yaml:"circuit_breaker"`
-	Auth           AuthConfig                 `json:"auth" yaml:"auth"`
-	Logging        LoggingConfig              `json:"logging" yaml:"logging"`
+	Server         ServerConfig             `json:"server" yaml:"server"`
+	Services       map[string]ServiceConfig `json:"services" yaml:"services"`
+	Routes         []RouteConfig            `json:"routes" yaml:"routes"`
+	RateLimit      RateLimitPolicy          `json:"rate_limit" yaml:"rate_limit" mapstructure:"rate_limit"`
+	CircuitBreaker CircuitBreakerSettings   `json:"circuit_breaker" yaml:"circuit_breaker" mapstructure:"circuit_breaker"`
+	Auth           AuthConfig               `json:"auth" yaml:"auth"`
+	// AuthProviders are further identity systems routes can name in
+	// auth_provider; routes without one use Auth
+	AuthProviders map[string]AuthProviderConfig `json:"auth_providers,omitempty" yaml:"auth_providers,omitempty" mapstructure:"auth_providers"`
+	Logging       LoggingConfig                 `json:"logging" yaml:"logging"`
+	Etcd          EtcdConfig                    `json:"etcd" yaml:"etcd" mapstructure:"etcd"`
+	RemoteConfig  RemoteConfig                  `json:"remote_config" yaml:"remote_config" mapstructure:"remote_config"`
+	Secrets       SecretsConfig                 `json:"secrets" yaml:"secrets" mapstructure:"secrets"`
+	ConfigHistory ConfigHistoryConfig           `json:"config_history" yaml:"config_history" mapstructure:"config_history"`
+	BFF           BFFConfig                     `json:"bff" yaml:"bff" mapstructure:"bff"`
+	OIDC          OIDCConfig                    `json:"oidc" yaml:"oidc" mapstructure:"oidc"`
+	Registration  RegistrationConfig            `json:"registration" yaml:"registration" mapstructure:"registration"`
+	Session       SessionConfig                 `json:"session" yaml:"session" mapstructure:"session"`
+	SessionAuth   SessionAuthConfig             `json:"session_auth" yaml:"session_auth" mapstructure:"session_auth"`
+	Redis         RedisConfig                   `json:"redis" yaml:"redis" mapstructure:"redis"`
+	Admin         AdminConfig                   `json:"admin" yaml:"admin" mapstructure:"admin"`
+	HealthCheck   HealthCheckConfig             `json:"health_check" yaml:"health_check" mapstructure:"health_check"`
+	Cache         CacheConfig                   `json:"cache" yaml:"cache" mapstructure:"cache"`
+	Events        EventsConfig                  `json:"events" yaml:"events" mapstructure:"events"`
+	Overload      OverloadConfig                `json:"overload" yaml:"overload" mapstructure:"overload"`
+	Watchdog      WatchdogConfig                `json:"watchdog" yaml:"watchdog" mapstructure:"watchdog"`
+	I18n          I18nConfig                    `json:"i18n" yaml:"i18n" mapstructure:"i18n"`
+	Forensics     ForensicsConfig               `json:"forensics" yaml:"forensics" mapstructure:"forensics"`
+	UpstreamDial  DialConfig                    `json:"upstream_dial" yaml:"upstream_dial" mapstructure:"upstream_dial"`
+	Quota         QuotaConfig                   `json:"quota" yaml:"quota" mapstructure:"quota"`
+	Plans         PlansConfig                   `json:"plans" yaml:"plans" mapstructure:"plans"`
+	Anomalies     AnomalyConfig                 `json:"anomalies" yaml:"anomalies" mapstructure:"anomalies"`
+	Debug         DebugConfig                   `json:"debug" yaml:"debug" mapstructure:"debug"`
+	Maintenance   MaintenanceConfig             `json:"maintenance" yaml:"maintenance" mapstructure:"maintenance"`
+	IPAccess      IPAccessConfig                `json:"ip_access" yaml:"ip_access" mapstructure:"ip_access"`
+	CORS          CORSConfig                    `json:"cors" yaml:"cors" mapstructure:"cors"`
+	GeoIP         GeoIPConfig                   `json:"geoip" yaml:"geoip" mapstructure:"geoip"`
+	// ValidationErrors is the default for services without their own
+	// validation_errors mode
+	ValidationErrors ValidationErrorMode `json:"validation_errors" yaml:"validation_errors" mapstructure:"validation_errors"`
 }
 
 type ServerConfig struct {
@@ -20,21 +54,489 @@ type ServerConfig struct {
 	ReadTimeout  time.Duration `json:"read_timeout" yaml:"read_timeout"`
 	WriteTimeout time.Duration `json:"write_timeout" yaml:"write_timeout"`
 	IdleTimeout  time.Duration `json:"idle_timeout" yaml:"idle_timeout"`
+	TLS          TLSConfig     `json:"tls" yaml:"tls" mapstructure:"tls"`
+	// Workers above one runs that many gateway processes sharing the port
+	// with SO_REUSEPORT, for hosts one process cannot keep busy. Linux only.
+	Workers int `json:"workers,omitempty" yaml:"workers,omitempty" mapstructure:"workers"`
+	// TrustedProxies are the addresses and CIDR ranges whose
+	// X-Forwarded-For and X-Real-IP headers tell the client's address.
+	// Unset, every peer is trusted.
+	TrustedProxies []string `json:"trusted_proxies,omitempty" yaml:"trusted_proxies,omitempty" mapstructure:"trusted_proxies"`
+	// Limits bound the size of request headers and URLs
+	Limits RequestLimits `json:"limits" yaml:"limits" mapstructure:"limits"`
+}
+
+// RequestLimits bound what a client may send before its request reaches
+// a route. Zero leaves a limit off.
+type RequestLimits struct {
+	// MaxHeaderBytes bounds the size of all header lines together, names,
+	// values and Host included
+	MaxHeaderBytes int `json:"max_header_bytes" yaml:"max_header_bytes" mapstructure:"max_header_bytes"`
+	// MaxHeaderLength bounds the size of a single header line
+	MaxHeaderLength int `json:"max_header_length" yaml:"max_header_length" mapstructure:"max_header_length"`
+	// MaxHeaders bounds the number of header lines
+	MaxHeaders int `json:"max_headers" yaml:"max_headers" mapstructure:"max_headers"`
+	// MaxURLLength bounds the request target, path and query, as sent
+	MaxURLLength int `json:"max_url_length" yaml:"max_url_length" mapstructure:"max_url_length"`
+}
+
+// ClientAuthMode decides when the listener asks for client certificates.
+type ClientAuthMode string
+
+const (
+	// ClientAuthOptional verifies certificates clients present, leaving it
+	// to routes with a client_cert policy to require one
+	ClientAuthOptional ClientAuthMode = "optional"
+	ClientAuthRequired ClientAuthMode = "required"
+)
+
+// TLSConfig serves HTTPS when CertFile and KeyFile are set. With
+// ClientCAFile, client certificates are verified against it during the
+// handshake. Certificate files are loaded again when they change.
+type TLSConfig struct {
+	CertFile string `json:"cert_file,omitempty" yaml:"cert_file,omitempty" mapstructure:"cert_file"`
+	KeyFile  string `json:"key_file,omitempty" yaml:"key_file,omitempty" mapstructure:"key_file"`
+	// Cert and Key hold the PEM themselves instead, usually as secret
+	// references such as vault://secret/data/gateway/tls#key
+	Cert         string         `json:"cert,omitempty" yaml:"cert,omitempty" mapstructure:"cert"`
+	Key          string         `json:"-" yaml:"key,omitempty" mapstructure:"key"`
+	ClientCAFile string         `json:"client_ca_file,omitempty" yaml:"client_ca_file,omitempty" mapstructure:"client_ca_file"`
+	ClientAuth   ClientAuthMode `json:"client_auth,omitempty" yaml:"client_auth,omitempty" mapstructure:"client_auth"`
+	// MinVersion is the oldest TLS version accepted, 1.2 when empty
+	MinVersion string `json:"min_version,omitempty" yaml:"min_version,omitempty" mapstructure:"min_version"`
+	// CipherSuites restricts the suites of TLS 1.2 and older connections,
+	// by name; Go's defaults when empty
+	CipherSuites []string `json:"cipher_suites,omitempty" yaml:"cipher_suites,omitempty" mapstructure:"cipher_suites"`
+	// ACME obtains the certificate instead of cert_file and key_file
+	ACME ACMEConfig `json:"acme" yaml:"acme" mapstructure:"acme"`
+}
+
+// Enabled reports whether the listener serves HTTPS, with a certificate
+// of its own or one obtained through ACME.
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != "" || t.Cert != "" || t.ACME.Enabled
+}
+
+// ACMEConfig obtains and renews certificates for Domains from an ACME
+// certificate authority, Let's Encrypt unless DirectoryURL names another.
+// Challenges are answered over TLS-ALPN-01 on the HTTPS listener and, with
+// HTTPPort set, over HTTP-01 on a plain listener on that port, which
+// redirects other requests to HTTPS. Keys and certificates are kept in
+// CacheDir so restarts do not ask for new ones.
+type ACMEConfig struct {
+	Enabled      bool     `json:"enabled" yaml:"enabled" mapstructure:"enabled"`
+	Domains      []string `json:"domains,omitempty" yaml:"domains,omitempty" mapstructure:"domains"`
+	Email        string   `json:"email,omitempty" yaml:"email,omitempty" mapstructure:"email"`
+	CacheDir     string   `json:"cache_dir" yaml:"cache_dir" mapstructure:"cache_dir"`
+	DirectoryURL string   `json:"directory_url,omitempty" yaml:"directory_url,omitempty" mapstructure:"directory_url"`
+	HTTPPort     int      `json:"http_port" yaml:"http_port" mapstructure:"http_port"`
+	// RenewBefore is how long before expiry certificates are renewed, 30
+	// days when zero
+	RenewBefore time.Duration `json:"renew_before,omitempty" yaml:"renew_before,omitempty" mapstructure:"renew_before"`
 }
 
 type AuthConfig struct {
-	ServiceURL string        `json:"service_url" yaml:"service_url" validate:"required,url"`
-	Timeout    time.Duration `json:"timeout" yaml:"timeout"`
-	CacheTTL   time.Duration `json:"cache_ttl" yaml:"cache_ttl"`
-	SkipPaths  []string      `json:"skip_paths,omitempty" yaml:"skip_paths,omitempty"`
+	ServiceURL string        `json:"service_url" yaml:"service_url" mapstructure:"service_url" validate:"required,url"`
+	Timeout    time.Duration `json:"timeout" yaml:"timeout" mapstructure:"timeout"`
+	SkipPaths  []string      `json:"skip_paths,omitempty" yaml:"skip_paths,omitempty" mapstructure:"skip_paths"`
+
+	// Verification results are cached for CacheTTL, rejections for
+	// NegativeCacheTTL. A zero CacheTTL disables the cache.
+	CacheTTL         time.Duration `json:"cache_ttl" yaml:"cache_ttl" mapstructure:"cache_ttl"`
+	NegativeCacheTTL time.Duration `json:"negative_cache_ttl" yaml:"negative_cache_ttl" mapstructure:"negative_cache_ttl"`
+	CacheSize        int           `json:"cache_size" yaml:"cache_size" mapstructure:"cache_size"`
+
+	// IdentityHeaders maps claims of the authenticated user to the
+	// headers upstreams receive them in. Clients cannot set these headers
+	// themselves. An empty header name turns a claim off.
+	IdentityHeaders map[string]string `json:"identity_headers" yaml:"identity_headers" mapstructure:"identity_headers"`
+
+	Revocation RevocationConfig `json:"revocation" yaml:"revocation" mapstructure:"revocation"`
+}
+
+// AuthProviderConfig is an identity system verifying tokens the way the
+// auth service does: a GET of VerifyPath with the bearer token.
+type AuthProviderConfig struct {
+	ServiceURL       string        `json:"service_url" yaml:"service_url" mapstructure:"service_url"`
+	VerifyPath       string        `json:"verify_path,omitempty" yaml:"verify_path,omitempty" mapstructure:"verify_path"`
+	Timeout          time.Duration `json:"timeout" yaml:"timeout" mapstructure:"timeout"`
+	CacheTTL         time.Duration `json:"cache_ttl" yaml:"cache_ttl" mapstructure:"cache_ttl"`
+	NegativeCacheTTL time.Duration `json:"negative_cache_ttl" yaml:"negative_cache_ttl" mapstructure:"negative_cache_ttl"`
+	CacheSize        int           `json:"cache_size" yaml:"cache_size" mapstructure:"cache_size"`
+}
+
+// Skips reports whether path is exempt from authentication. Skip paths
+// match exactly, or by prefix when they end in /*.
+func (a *AuthConfig) Skips(path string) bool {
+	for _, skip := range a.SkipPaths {
+		if strings.HasSuffix(skip, "/*") {
+			if strings.HasPrefix(path, strings.TrimSuffix(skip, "*")) {
+				return true
+			}
+		} else if path == skip {
+			return true
+		}
+	}
+	return false
 }
 
 type LoggingConfig struct {
-	Level      string `json:"level" yaml:"level"`
-	Format     string `json:"format" yaml:"format"`
-	OutputFile string `json:"output_file,omitempty" yaml:"output_file,omitempty"`
-	MaxSize    int    `json:"max_size,omitempty" yaml:"max_size,omitempty"`
-	MaxBackups int    `json:"max_backups,omitempty" yaml:"max_backups,omitempty"`
+	Level string `json:"level" yaml:"level"`
+	// Format is one of the LogFormat values
+	Format string `json:"format" yaml:"format"`
+	// Template lays out access log lines with the "custom" format, as a
+	// text/template executed on the request's RequestLogEntry
+	Template string `json:"template,omitempty" yaml:"template,omitempty" mapstructure:"template"`
+	// OutputFile sends the logs to a file instead of stdout and stderr,
+	// rotated at MaxSize megabytes with MaxBackups rotated files kept
+	// (zero keeps them all)
+	OutputFile string `json:"output_file,omitempty" yaml:"output_file,omitempty" mapstructure:"output_file"`
+	MaxSize    int    `json:"max_size,omitempty" yaml:"max_size,omitempty" mapstructure:"max_size"`
+	MaxBackups int    `json:"max_backups,omitempty" yaml:"max_backups,omitempty" mapstructure:"max_backups"`
+	// Sampling writes only a share of the access log lines of some
+	// requests, for gateways serving too many to log them all
+	Sampling *LogSampling `json:"sampling,omitempty" yaml:"sampling,omitempty" mapstructure:"sampling"`
+	// MaxOverride caps how long a level or debug logging change made
+	// through the admin API lasts before it reverts
+	MaxOverride time.Duration `json:"max_override" yaml:"max_override" mapstructure:"max_override"`
+}
+
+type EtcdConfig struct {
+	Enabled     bool          `json:"enabled" yaml:"enabled" mapstructure:"enabled"`
+	Endpoints   []string      `json:"endpoints" yaml:"endpoints" mapstructure:"endpoints"`
+	Prefix      string        `json:"prefix" yaml:"prefix" mapstructure:"prefix"`
+	Username    string        `json:"username,omitempty" yaml:"username,omitempty" mapstructure:"username"`
+	Password    string        `json:"-" yaml:"password,omitempty" mapstructure:"password"`
+	DialTimeout time.Duration `json:"dial_timeout" yaml:"dial_timeout" mapstructure:"dial_timeout"`
+}
+
+type RemoteConfigProvider string
+
+const (
+	RemoteConfigEtcd   RemoteConfigProvider = "etcd"
+	RemoteConfigConsul RemoteConfigProvider = "consul"
+)
+
+// RemoteConfig loads a configuration document, in YAML or JSON, from Key in
+// etcd or Consul KV. It is merged over the config file and watched, so
+// replicas pick up changes without a redeploy. The etcd provider connects
+// with the endpoints and credentials of the etcd section.
+type RemoteConfig struct {
+	Enabled  bool                 `json:"enabled" yaml:"enabled" mapstructure:"enabled"`
+	Provider RemoteConfigProvider `json:"provider,omitempty" yaml:"provider,omitempty" mapstructure:"provider"`
+	Key      string               `json:"key,omitempty" yaml:"key,omitempty" mapstructure:"key"`
+	Consul   ConsulConfig         `json:"consul" yaml:"consul" mapstructure:"consul"`
+}
+
+// ConsulConfig locates a Consul agent's HTTP API.
+type ConsulConfig struct {
+	Address    string        `json:"address,omitempty" yaml:"address,omitempty" mapstructure:"address"`
+	Token      string        `json:"-" yaml:"token,omitempty" mapstructure:"token"`
+	Datacenter string        `json:"datacenter,omitempty" yaml:"datacenter,omitempty" mapstructure:"datacenter"`
+	Timeout    time.Duration `json:"timeout" yaml:"timeout" mapstructure:"timeout"`
+}
+
+// SecretsConfig says where secret references point. Config values written
+// as env://NAME, file:///path or vault://path#field are replaced with the
+// secret when the config loads; vault:// needs Vault to be configured.
+type SecretsConfig struct {
+	Vault VaultConfig `json:"vault" yaml:"vault" mapstructure:"vault"`
+}
+
+// VaultConfig locates a Vault server and the token to read secrets with.
+// Secrets read are cached for CacheTTL.
+type VaultConfig struct {
+	Address   string        `json:"address,omitempty" yaml:"address,omitempty" mapstructure:"address"`
+	Token     string        `json:"-" yaml:"token,omitempty" mapstructure:"token"`
+	Namespace string        `json:"namespace,omitempty" yaml:"namespace,omitempty" mapstructure:"namespace"`
+	Timeout   time.Duration `json:"timeout" yaml:"timeout" mapstructure:"timeout"`
+	CacheTTL  time.Duration `json:"cache_ttl" yaml:"cache_ttl" mapstructure:"cache_ttl"`
+}
+
+// ConfigHistoryConfig sets how many applied configurations are kept in
+// memory to roll back to.
+type ConfigHistoryConfig struct {
+	Size int `json:"size" yaml:"size" mapstructure:"size"`
+}
+
+// HealthCheckConfig holds registry-wide health checking settings. Probe
+// details (path, method, expectations) are configured per service.
+//
+// Rise and Fall are the consecutive probe successes/failures needed to
+// change a service's status. A service changing status FlapThreshold times
+// within FlapWindow is considered flapping and held unhealthy until it has
+// been stable for a whole window; a zero FlapThreshold disables this.
+//
+// Probes run on MaxConcurrency workers, and each service's next probe is
+// shifted by a random amount of up to Jitter (a fraction of its interval).
+type HealthCheckConfig struct {
+	Interval       time.Duration       `json:"interval" yaml:"interval" mapstructure:"interval"`
+	MaxConcurrency int                 `json:"max_concurrency" yaml:"max_concurrency" mapstructure:"max_concurrency"`
+	Jitter         float64             `json:"jitter" yaml:"jitter" mapstructure:"jitter"`
+	Rise           int                 `json:"rise" yaml:"rise" mapstructure:"rise"`
+	Fall           int                 `json:"fall" yaml:"fall" mapstructure:"fall"`
+	FlapWindow     time.Duration       `json:"flap_window" yaml:"flap_window" mapstructure:"flap_window"`
+	FlapThreshold  int                 `json:"flap_threshold" yaml:"flap_threshold" mapstructure:"flap_threshold"`
+	Passive        PassiveHealthConfig `json:"passive" yaml:"passive" mapstructure:"passive"`
+	SLA            LatencySLAConfig    `json:"sla" yaml:"sla" mapstructure:"sla"`
+}
+
+// PassiveHealthConfig derives service health from live proxy traffic. A
+// service whose share of failed requests (5xx or transport errors) within
+// Window reaches FailureRatio is marked degraded, even while its health
+// endpoint still answers. MinRequests keeps a handful of errors on a quiet
+// service from tripping it.
+type PassiveHealthConfig struct {
+	Enabled      bool          `json:"enabled" yaml:"enabled" mapstructure:"enabled"`
+	Window       time.Duration `json:"window" yaml:"window" mapstructure:"window"`
+	MinRequests  int           `json:"min_requests" yaml:"min_requests" mapstructure:"min_requests"`
+	FailureRatio float64       `json:"failure_ratio" yaml:"failure_ratio" mapstructure:"failure_ratio"`
+}
+
+// LatencySLAConfig controls tracking of per-service latency_sla. Proxied
+// responses slower than their service's SLA are tagged, and a service whose
+// share of slow responses within Window reaches AlertRatio (with at least
+// MinRequests requests) raises an sla_breached event. A zero AlertRatio
+// keeps the tagging and rates but disables the events.
+type LatencySLAConfig struct {
+	Window      time.Duration `json:"window" yaml:"window" mapstructure:"window"`
+	MinRequests int           `json:"min_requests" yaml:"min_requests" mapstructure:"min_requests"`
+	AlertRatio  float64       `json:"alert_ratio" yaml:"alert_ratio" mapstructure:"alert_ratio"`
+}
+
+// OverloadConfig controls self-throttling. While the gateway's own CPU or
+// memory use is above its threshold, health check intervals are multiplied
+// by HealthIntervalMultiplier and, with QuietLogs, successful requests are
+// left out of the access log. MemoryLimit defaults to GOMEMLIMIT.
+type OverloadConfig struct {
+	Enabled                  bool          `json:"enabled" yaml:"enabled" mapstructure:"enabled"`
+	CheckInterval            time.Duration `json:"check_interval" yaml:"check_interval" mapstructure:"check_interval"`
+	CPUThreshold             float64       `json:"cpu_threshold" yaml:"cpu_threshold" mapstructure:"cpu_threshold"`
+	MemoryLimit              int64         `json:"memory_limit,omitempty" yaml:"memory_limit,omitempty" mapstructure:"memory_limit"`
+	MemoryThreshold          float64       `json:"memory_threshold" yaml:"memory_threshold" mapstructure:"memory_threshold"`
+	CoolDown                 time.Duration `json:"cool_down" yaml:"cool_down" mapstructure:"cool_down"`
+	HealthIntervalMultiplier float64       `json:"health_interval_multiplier" yaml:"health_interval_multiplier" mapstructure:"health_interval_multiplier"`
+	QuietLogs                bool          `json:"quiet_logs" yaml:"quiet_logs" mapstructure:"quiet_logs"`
+}
+
+// WatchdogConfig controls leak detection. Every Interval the gateway
+// samples its goroutines, open files and connections; a sample over its
+// threshold logs a warning and, at most once per DumpInterval, a goroutine
+// dump, written to DumpDir when set. A zero threshold turns its check off,
+// except MaxOpenFiles, which then defaults to 80% of the process limit.
+type WatchdogConfig struct {
+	Enabled                bool          `json:"enabled" yaml:"enabled" mapstructure:"enabled"`
+	Interval               time.Duration `json:"interval" yaml:"interval" mapstructure:"interval"`
+	MaxGoroutines          int           `json:"max_goroutines" yaml:"max_goroutines" mapstructure:"max_goroutines"`
+	MaxOpenFiles           int           `json:"max_open_files,omitempty" yaml:"max_open_files,omitempty" mapstructure:"max_open_files"`
+	MaxConnections         int           `json:"max_connections" yaml:"max_connections" mapstructure:"max_connections"`
+	MaxUpstreamConnections int           `json:"max_upstream_connections" yaml:"max_upstream_connections" mapstructure:"max_upstream_connections"`
+	DumpInterval           time.Duration `json:"dump_interval" yaml:"dump_interval" mapstructure:"dump_interval"`
+	DumpDir                string        `json:"dump_dir,omitempty" yaml:"dump_dir,omitempty" mapstructure:"dump_dir"`
+}
+
+// I18nConfig controls localization of gateway error messages. CatalogDir
+// holds one <locale>.json file of message templates per locale; English is
+// built in.
+type I18nConfig struct {
+	DefaultLocale string `json:"default_locale" yaml:"default_locale" mapstructure:"default_locale"`
+	CatalogDir    string `json:"catalog_dir,omitempty" yaml:"catalog_dir,omitempty" mapstructure:"catalog_dir"`
+}
+
+// BFFConfig controls the backend-for-frontend token relay, where the gateway
+// keeps access/refresh tokens in a server-side session and the SPA only
+// holds an opaque session cookie.
+type BFFConfig struct {
+	Enabled       bool          `json:"enabled" yaml:"enabled" mapstructure:"enabled"`
+	RefreshBefore time.Duration `json:"refresh_before" yaml:"refresh_before" mapstructure:"refresh_before"`
+	CSRFHeader    string        `json:"csrf_header" yaml:"csrf_header" mapstructure:"csrf_header"`
+}
+
+// OIDCConfig enables the authorization-code login flow for routes with
+// oidc_login. The gateway acts as a confidential client of Issuer; the
+// identity provider must redirect back to RedirectURL, which is served by
+// the gateway at /oidc/callback.
+type OIDCConfig struct {
+	Enabled      bool     `json:"enabled" yaml:"enabled" mapstructure:"enabled"`
+	Issuer       string   `json:"issuer" yaml:"issuer" mapstructure:"issuer"`
+	ClientID     string   `json:"client_id" yaml:"client_id" mapstructure:"client_id"`
+	ClientSecret string   `json:"-" yaml:"client_secret,omitempty" mapstructure:"client_secret"`
+	RedirectURL  string   `json:"redirect_url" yaml:"redirect_url" mapstructure:"redirect_url"`
+	Scopes       []string `json:"scopes" yaml:"scopes" mapstructure:"scopes"`
+	// PostLogoutRedirectURL is where the identity provider sends users
+	// after /oidc/logout ended their session there.
+	PostLogoutRedirectURL string        `json:"post_logout_redirect_url,omitempty" yaml:"post_logout_redirect_url,omitempty" mapstructure:"post_logout_redirect_url"`
+	Timeout               time.Duration `json:"timeout" yaml:"timeout" mapstructure:"timeout"`
+}
+
+type SessionStoreType string
+
+const (
+	SessionStoreMemory SessionStoreType = "memory"
+	SessionStoreCookie SessionStoreType = "cookie"
+	SessionStoreRedis  SessionStoreType = "redis"
+)
+
+// SessionConfig configures the session subsystem shared by the browser
+// login modes. Keys are base64-encoded 32-byte AES keys; the first one seals
+// new sessions and the rest are accepted for decryption during rotation.
+type SessionConfig struct {
+	Store        SessionStoreType `json:"store" yaml:"store" mapstructure:"store"`
+	Keys         []string         `json:"-" yaml:"keys,omitempty" mapstructure:"keys"`
+	TTL          time.Duration    `json:"ttl" yaml:"ttl" mapstructure:"ttl"`
+	CookieName   string           `json:"cookie_name" yaml:"cookie_name" mapstructure:"cookie_name"`
+	CookieDomain string           `json:"cookie_domain,omitempty" yaml:"cookie_domain,omitempty" mapstructure:"cookie_domain"`
+	CookieSecure bool             `json:"cookie_secure" yaml:"cookie_secure" mapstructure:"cookie_secure"`
+	SameSite     string           `json:"same_site" yaml:"same_site" mapstructure:"same_site"`
+	// MaxPerUser caps concurrent sessions per account; the oldest are
+	// ended when a new login exceeds it. 0 means unlimited.
+	MaxPerUser int `json:"max_per_user" yaml:"max_per_user" mapstructure:"max_per_user"`
+}
+
+// SessionAuthConfig lets server-rendered frontends authenticate with the
+// session cookie alone. Sessions slide forward to the full session ttl as
+// they are used, saved at most once per RefreshAfter.
+type SessionAuthConfig struct {
+	Enabled      bool          `json:"enabled" yaml:"enabled" mapstructure:"enabled"`
+	RefreshAfter time.Duration `json:"refresh_after" yaml:"refresh_after" mapstructure:"refresh_after"`
+	CSRFHeader   string        `json:"csrf_header" yaml:"csrf_header" mapstructure:"csrf_header"`
+}
+
+type RedisConfig struct {
+	Address   string `json:"address" yaml:"address" mapstructure:"address"`
+	Password  string `json:"-" yaml:"password,omitempty" mapstructure:"password"`
+	DB        int    `json:"db" yaml:"db" mapstructure:"db"`
+	KeyPrefix string `json:"key_prefix" yaml:"key_prefix" mapstructure:"key_prefix"`
+}
+
+// AdminConfig protects gateway administration endpoints. Admin endpoints
+// are disabled when no token is configured. Token grants every scope;
+// Tokens hand out narrower ones.
+type AdminConfig struct {
+	Token  string       `json:"-" yaml:"token,omitempty" mapstructure:"token"`
+	Tokens []AdminToken `json:"tokens,omitempty" yaml:"tokens,omitempty" mapstructure:"tokens"`
+	Audit  AuditConfig  `json:"audit" yaml:"audit" mapstructure:"audit"`
+	// RoutingFile keeps the services and routes added through the admin
+	// API, so they are registered again after a restart
+	RoutingFile string `json:"routing_file,omitempty" yaml:"routing_file,omitempty" mapstructure:"routing_file"`
+}
+
+// AuditConfig keeps the LogSize most recent changes made through admin
+// endpoints in memory. With OutputFile set every change is also appended
+// to it, and the records it holds are read back at startup.
+type AuditConfig struct {
+	LogSize    int    `json:"log_size" yaml:"log_size" mapstructure:"log_size"`
+	OutputFile string `json:"output_file,omitempty" yaml:"output_file,omitempty" mapstructure:"output_file"`
+}
+
+// DebugConfig serves the Go profiler under /debug/pprof and runtime
+// statistics under /gateway/debug/runtime, both behind the admin token.
+type DebugConfig struct {
+	Enabled bool `json:"enabled" yaml:"enabled" mapstructure:"enabled"`
+}
+
+// IPAccessConfig restricts proxied requests by client address. Allow and
+// Deny hold addresses and CIDR ranges: a client in Deny is refused, and
+// with Allow set so is a client outside it.
+type IPAccessConfig struct {
+	Allow []string `json:"allow,omitempty" yaml:"allow,omitempty" mapstructure:"allow"`
+	Deny  []string `json:"deny,omitempty" yaml:"deny,omitempty" mapstructure:"deny"`
+}
+
+// GeoIPConfig looks up the country of clients in a MaxMind DB file, such
+// as GeoLite2-Country, for routes' geo_access rules and the requests by
+// country metric. The file is loaded again when it changes.
+type GeoIPConfig struct {
+	Enabled  bool   `json:"enabled" yaml:"enabled" mapstructure:"enabled"`
+	Database string `json:"database,omitempty" yaml:"database,omitempty" mapstructure:"database"`
+}
+
+// GeoAccessConfig restricts a route to clients from some countries, given
+// as ISO 3166-1 alpha-2 codes such as DE. A client from a country in Deny
+// is refused, and with Allow set so is a client from elsewhere. Clients
+// whose country is unknown, such as those from private addresses, are
+// refused when Allow is set unless AllowUnknown is.
+type GeoAccessConfig struct {
+	Allow        []string `json:"allow,omitempty" yaml:"allow,omitempty" mapstructure:"allow"`
+	Deny         []string `json:"deny,omitempty" yaml:"deny,omitempty" mapstructure:"deny"`
+	AllowUnknown bool     `json:"allow_unknown,omitempty" yaml:"allow_unknown,omitempty" mapstructure:"allow_unknown"`
+}
+
+// Check tells whether a client from country, empty when unknown, may send
+// requests, and if not why.
+func (g *GeoAccessConfig) Check(country string) (string, bool) {
+	if country == "" {
+		if len(g.Allow) > 0 && !g.AllowUnknown {
+			return "country unknown", false
+		}
+		return "", true
+	}
+	for _, denied := range g.Deny {
+		if strings.EqualFold(denied, country) {
+			return "country " + country + " is denied", false
+		}
+	}
+	if len(g.Allow) == 0 {
+		return "", true
+	}
+	for _, allowed := range g.Allow {
+		if strings.EqualFold(allowed, country) {
+			return "", true
+		}
+	}
+	return "country " + country + " is not allowed", false
+}
+
+// MaintenanceConfig puts the gateway in maintenance mode at startup; the
+// admin API turns it on and off at runtime. Proxied routes then answer 503
+// while health and admin endpoints keep working. The answer is Body, sent
+// as ContentType, or else a JSON error carrying Message, localized when
+// empty. Clients from BypassIPs, addresses or CIDR ranges, are proxied as
+// usual, to check a deploy before opening up.
+type MaintenanceConfig struct {
+	Enabled     bool          `json:"enabled" yaml:"enabled" mapstructure:"enabled"`
+	Message     string        `json:"message,omitempty" yaml:"message,omitempty" mapstructure:"message"`
+	RetryAfter  time.Duration `json:"retry_after,omitempty" yaml:"retry_after,omitempty" mapstructure:"retry_after"`
+	Body        string        `json:"body,omitempty" yaml:"body,omitempty" mapstructure:"body"`
+	ContentType string        `json:"content_type" yaml:"content_type" mapstructure:"content_type"`
+	BypassIPs   []string      `json:"bypass_ips,omitempty" yaml:"bypass_ips,omitempty" mapstructure:"bypass_ips"`
+}
+
+// AdminScope is a permission of an admin token. Any scope allows reading
+// admin endpoints; changes need mutate, and endpoints exposing request
+// data or credentials need secrets.
+type AdminScope string
+
+const (
+	AdminScopeRead    AdminScope = "read"
+	AdminScopeMutate  AdminScope = "mutate"
+	AdminScopeSecrets AdminScope = "secrets"
+)
+
+// AdminToken is a named admin token limited to Scopes.
+type AdminToken struct {
+	Name   string       `json:"name" yaml:"name" mapstructure:"name"`
+	Token  string       `json:"-" yaml:"token" mapstructure:"token"`
+	Scopes []AdminScope `json:"scopes" yaml:"scopes" mapstructure:"scopes"`
+}
+
+// HasScope reports whether the token was granted scope.
+func (t AdminToken) HasScope(scope AdminScope) bool {
+	for _, granted := range t.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// RegistrationConfig controls the self-registration API backends use to
+// join the registry without a static config entry.
+type RegistrationConfig struct {
+	Enabled    bool          `json:"enabled" yaml:"enabled" mapstructure:"enabled"`
+	Token      string        `json:"-" yaml:"token,omitempty" mapstructure:"token"`
+	DefaultTTL time.Duration `json:"default_ttl" yaml:"default_ttl" mapstructure:"default_ttl"`
+	MaxTTL     time.Duration `json:"max_ttl" yaml:"max_ttl" mapstructure:"max_ttl"`
 }
 
 func NewDefaultGatewayConfig() *GatewayConfig {
@@ -45,6 +547,19 @@ func NewDefaultGatewayConfig() *GatewayConfig {
 			ReadTimeout:  30 * time.Second,
 			WriteTimeout: 30 * time.Second,
 			IdleTimeout:  60 * time.Second,
+			Workers:      1,
+			Limits: RequestLimits{
+				MaxHeaderBytes:  64 << 10,
+				MaxHeaderLength: 16 << 10,
+				MaxHeaders:      100,
+				MaxURLLength:    8 << 10,
+			},
+			TLS: TLSConfig{
+				ACME: ACMEConfig{
+					CacheDir: "acme-cache",
+					HTTPPort: 80,
+				},
+			},
 		},
 		Services: make(map[string]ServiceConfig),
 		Routes:   []RouteConfig{},
@@ -55,17 +570,35 @@ func NewDefaultGatewayConfig() *GatewayConfig {
 			Burst:    200,
 			Scope:    ScopePerIP,
 			Enabled:  true,
+			Penalty: PenaltyConfig{
+				Enabled:        true,
+				Threshold:      20,
+				HalfLife:       time.Minute,
+				RateFactor:     0.25,
+				TarpitDelay:    250 * time.Millisecond,
+				MaxTarpitDelay: 2 * time.Second,
+			},
 		},
 		CircuitBreaker: CircuitBreakerSettings{
+			Enabled:          true,
+			Strategy:         CircuitFixedWindow,
 			MaxRequests:      3,
 			Interval:         60 * time.Second,
 			Timeout:          30 * time.Second,
 			FailureThreshold: 0.6,
 		},
 		Auth: AuthConfig{
-			ServiceURL: "http://localhost:8001",
-			Timeout:    5 * time.Second,
-			CacheTTL:   5 * time.Minute,
+			ServiceURL:       "http://localhost:8001",
+			Timeout:          5 * time.Second,
+			CacheTTL:         5 * time.Minute,
+			NegativeCacheTTL: 30 * time.Second,
+			CacheSize:        10000,
+			IdentityHeaders: map[string]string{
+				"user_id": "X-User-ID",
+				"email":   "X-User-Email",
+				"name":    "X-User-Name",
+				"scopes":  "X-Scopes",
+			},
 			SkipPaths: []string{
 				"/health",
 				"/health/ready",
@@ -73,10 +606,161 @@ func NewDefaultGatewayConfig() *GatewayConfig {
 				"/gateway/routes",
 				"/gateway/metrics",
 			},
+			Revocation: RevocationConfig{
+				Source:   RevocationSourceAuthService,
+				Path:     "/auth/revocations",
+				Interval: 30 * time.Second,
+			},
 		},
 		Logging: LoggingConfig{
-			Level:  "info",
-			Format: "json",
+			Level:       "info",
+			Format:      "json",
+			MaxSize:     100,
+			MaxBackups:  5,
+			MaxOverride: time.Hour,
+		},
+		Etcd: EtcdConfig{
+			Prefix:      "/gateway",
+			DialTimeout: 5 * time.Second,
+		},
+		RemoteConfig: RemoteConfig{
+			Provider: RemoteConfigEtcd,
+			Consul: ConsulConfig{
+				Address: "http://127.0.0.1:8500",
+				Timeout: 5 * time.Second,
+			},
+		},
+		ConfigHistory: ConfigHistoryConfig{
+			Size: 10,
+		},
+		Secrets: SecretsConfig{
+			Vault: VaultConfig{
+				Timeout:  5 * time.Second,
+				CacheTTL: 5 * time.Minute,
+			},
+		},
+		BFF: BFFConfig{
+			RefreshBefore: time.Minute,
+			CSRFHeader:    "X-Requested-With",
+		},
+		OIDC: OIDCConfig{
+			Scopes:  []string{"openid", "profile", "email"},
+			Timeout: 10 * time.Second,
+		},
+		Session: SessionConfig{
+			Store:        SessionStoreMemory,
+			TTL:          24 * time.Hour,
+			CookieName:   "gw_session",
+			CookieSecure: true,
+			SameSite:     "lax",
+		},
+		UpstreamDial: DialConfig{
+			HappyEyeballs:  true,
+			AttemptDelay:   250 * time.Millisecond,
+			ConnectTimeout: 10 * time.Second,
+			KeepAlive:      30 * time.Second,
+		},
+		SessionAuth: SessionAuthConfig{
+			RefreshAfter: 5 * time.Minute,
+			CSRFHeader:   "X-Requested-With",
+		},
+		Redis: RedisConfig{
+			Address:   "localhost:6379",
+			KeyPrefix: "gateway:",
+		},
+		Registration: RegistrationConfig{
+			DefaultTTL: 30 * time.Second,
+			MaxTTL:     5 * time.Minute,
+		},
+		HealthCheck: HealthCheckConfig{
+			Interval:       30 * time.Second,
+			MaxConcurrency: 10,
+			Jitter:         0.1,
+			Rise:           2,
+			Fall:           3,
+			FlapWindow:     5 * time.Minute,
+			FlapThreshold:  4,
+			Passive: PassiveHealthConfig{
+				Enabled:      true,
+				Window:       30 * time.Second,
+				MinRequests:  10,
+				FailureRatio: 0.5,
+			},
+			SLA: LatencySLAConfig{
+				Window:      time.Minute,
+				MinRequests: 20,
+				AlertRatio:  0.1,
+			},
+		},
+		Overload: OverloadConfig{
+			CheckInterval:            5 * time.Second,
+			CPUThreshold:             0.9,
+			MemoryThreshold:          0.85,
+			CoolDown:                 30 * time.Second,
+			HealthIntervalMultiplier: 4,
+			QuietLogs:                true,
+		},
+		Watchdog: WatchdogConfig{
+			Enabled:                true,
+			Interval:               30 * time.Second,
+			MaxGoroutines:          10000,
+			MaxConnections:         10000,
+			MaxUpstreamConnections: 5000,
+			DumpInterval:           15 * time.Minute,
+		},
+		Admin: AdminConfig{
+			Audit: AuditConfig{
+				LogSize: 1000,
+			},
+		},
+		Events: EventsConfig{
+			LogSize:        500,
+			MaxSubscribers: 20,
+		},
+		I18n: I18nConfig{
+			DefaultLocale: "en",
+		},
+		ValidationErrors: ValidationErrorsPassthrough,
+		Forensics: ForensicsConfig{
+			Enabled:     true,
+			Size:        100,
+			MaxBodySize: 4096,
+			Capture: CaptureConfig{
+				Size:        200,
+				MaxDuration: time.Hour,
+			},
+		},
+		Quota: QuotaConfig{
+			Header: "X-API-Key",
+			Store:  QuotaStoreMemory,
+		},
+		Plans: PlansConfig{
+			Claim: "plan",
+		},
+		Anomalies: AnomalyConfig{
+			SampleRate:    0.1,
+			Window:        time.Minute,
+			MinSamples:    20,
+			MismatchRatio: 0.5,
+			SizeFactor:    5,
+			MaxBodySize:   64 << 10,
+		},
+		Cache: CacheConfig{
+			MaxEntries:  10000,
+			MaxBodySize: 1 << 20,
+			Warmup: WarmupConfig{
+				Concurrency: 4,
+			},
+		},
+		Maintenance: MaintenanceConfig{
+			ContentType: "application/json",
+		},
+		CORS: CORSConfig{
+			Enabled:        true,
+			AllowedOrigins: []string{"*"},
+			AllowedMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+			AllowedHeaders: []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Correlation-ID", "X-Max-Wait"},
+			ExposedHeaders: []string{"X-Correlation-ID"},
 		},
 	}
-}
\ No newline at end of file
+}
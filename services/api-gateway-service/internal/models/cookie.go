@@ -0,0 +1,90 @@
+package models
+
+import (
+	"net/http"
+	"strings"
+)
+
+// CookiePolicy rewrites the attributes of Set-Cookie headers sent by a
+// route's upstream, for services whose cookies do not suit the frontend
+// in front of them. Unset fields leave the attribute as the upstream sent
+// it. SameSite=None always implies Secure, as browsers require.
+type CookiePolicy struct {
+	Secure   *bool  `json:"secure,omitempty" yaml:"secure,omitempty" mapstructure:"secure"`
+	HTTPOnly *bool  `json:"http_only,omitempty" yaml:"http_only,omitempty" mapstructure:"http_only"`
+	SameSite string `json:"same_site,omitempty" yaml:"same_site,omitempty" mapstructure:"same_site"`
+	Path     string `json:"path,omitempty" yaml:"path,omitempty" mapstructure:"path"`
+	Domain   string `json:"domain,omitempty" yaml:"domain,omitempty" mapstructure:"domain"`
+	// HostOnly drops the Domain attribute so the cookie belongs to the
+	// gateway's host
+	HostOnly bool `json:"host_only,omitempty" yaml:"host_only,omitempty" mapstructure:"host_only"`
+}
+
+// RewriteHeaders applies the policy to every Set-Cookie header.
+func (p *CookiePolicy) RewriteHeaders(header http.Header) {
+	cookies := header["Set-Cookie"]
+	for i, cookie := range cookies {
+		cookies[i] = p.Rewrite(cookie)
+	}
+}
+
+// Rewrite applies the policy to one Set-Cookie value. Attributes the policy
+// does not cover, such as Expires or Max-Age, are kept as they are.
+func (p *CookiePolicy) Rewrite(setCookie string) string {
+	sameSite := strings.ToLower(p.SameSite)
+	secure := p.Secure != nil && *p.Secure || sameSite == "none"
+
+	parts := strings.Split(setCookie, ";")
+	attributes := []string{strings.TrimSpace(parts[0])}
+	for _, attribute := range parts[1:] {
+		attribute = strings.TrimSpace(attribute)
+		name, _, _ := strings.Cut(attribute, "=")
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "":
+			continue
+		case "secure":
+			if p.Secure != nil || secure {
+				continue
+			}
+		case "httponly":
+			if p.HTTPOnly != nil {
+				continue
+			}
+		case "samesite":
+			if sameSite != "" {
+				continue
+			}
+		case "path":
+			if p.Path != "" {
+				continue
+			}
+		case "domain":
+			if p.Domain != "" || p.HostOnly {
+				continue
+			}
+		}
+		attributes = append(attributes, attribute)
+	}
+
+	if p.Path != "" {
+		attributes = append(attributes, "Path="+p.Path)
+	}
+	if p.Domain != "" && !p.HostOnly {
+		attributes = append(attributes, "Domain="+p.Domain)
+	}
+	if secure {
+		attributes = append(attributes, "Secure")
+	}
+	if p.HTTPOnly != nil && *p.HTTPOnly {
+		attributes = append(attributes, "HttpOnly")
+	}
+	switch sameSite {
+	case "lax":
+		attributes = append(attributes, "SameSite=Lax")
+	case "strict":
+		attributes = append(attributes, "SameSite=Strict")
+	case "none":
+		attributes = append(attributes, "SameSite=None")
+	}
+	return strings.Join(attributes, "; ")
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"path"
+	"strings"
+	"time"
+)
+
+// CORSConfig decides which browser origins may call the gateway. Allowed
+// origins are exact, such as https://shop.example.com, or patterns with *
+// standing for part of the host, such as https://*.example.com; * alone
+// allows any origin, which credentialed requests cannot use. With
+// AllowedHeaders set to * the headers a preflight asks for are allowed.
+type CORSConfig struct {
+	Enabled          bool          `json:"enabled" yaml:"enabled" mapstructure:"enabled"`
+	AllowedOrigins   []string      `json:"allowed_origins" yaml:"allowed_origins" mapstructure:"allowed_origins"`
+	AllowedMethods   []string      `json:"allowed_methods" yaml:"allowed_methods" mapstructure:"allowed_methods"`
+	AllowedHeaders   []string      `json:"allowed_headers" yaml:"allowed_headers" mapstructure:"allowed_headers"`
+	ExposedHeaders   []string      `json:"exposed_headers,omitempty" yaml:"exposed_headers,omitempty" mapstructure:"exposed_headers"`
+	AllowCredentials bool          `json:"allow_credentials" yaml:"allow_credentials" mapstructure:"allow_credentials"`
+	MaxAge           time.Duration `json:"max_age,omitempty" yaml:"max_age,omitempty" mapstructure:"max_age"`
+}
+
+// AnyOrigin reports whether every origin is allowed.
+func (c CORSConfig) AnyOrigin() bool {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsOrigin reports whether origin may call the gateway.
+func (c CORSConfig) AllowsOrigin(origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, allowed := range c.AllowedOrigins {
+		allowed = strings.ToLower(allowed)
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+		if strings.Contains(allowed, "*") {
+			if matched, _ := path.Match(allowed, origin); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// AnyHeader reports whether preflights may ask for any header.
+func (c CORSConfig) AnyHeader() bool {
+	for _, allowed := range c.AllowedHeaders {
+		if allowed == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// CORSOverride replaces some of the gateway-wide CORS settings for one
+// route, so a route used by a single frontend can allow credentials from
+// it alone. Unset fields are inherited.
+type CORSOverride struct {
+	Enabled          *bool         `json:"enabled,omitempty" yaml:"enabled,omitempty" mapstructure:"enabled"`
+	AllowedOrigins   []string      `json:"allowed_origins,omitempty" yaml:"allowed_origins,omitempty" mapstructure:"allowed_origins"`
+	AllowedMethods   []string      `json:"allowed_methods,omitempty" yaml:"allowed_methods,omitempty" mapstructure:"allowed_methods"`
+	AllowedHeaders   []string      `json:"allowed_headers,omitempty" yaml:"allowed_headers,omitempty" mapstructure:"allowed_headers"`
+	ExposedHeaders   []string      `json:"exposed_headers,omitempty" yaml:"exposed_headers,omitempty" mapstructure:"exposed_headers"`
+	AllowCredentials *bool         `json:"allow_credentials,omitempty" yaml:"allow_credentials,omitempty" mapstructure:"allow_credentials"`
+	MaxAge           time.Duration `json:"max_age,omitempty" yaml:"max_age,omitempty" mapstructure:"max_age"`
+}
+
+// Apply returns config with the fields set in the override replaced. A
+// nil override changes nothing.
+func (o *CORSOverride) Apply(config CORSConfig) CORSConfig {
+	if o == nil {
+		return config
+	}
+	if o.Enabled != nil {
+		config.Enabled = *o.Enabled
+	}
+	if len(o.AllowedOrigins) > 0 {
+		config.AllowedOrigins = o.AllowedOrigins
+	}
+	if len(o.AllowedMethods) > 0 {
+		config.AllowedMethods = o.AllowedMethods
+	}
+	if len(o.AllowedHeaders) > 0 {
+		config.AllowedHeaders = o.AllowedHeaders
+	}
+	if len(o.ExposedHeaders) > 0 {
+		config.ExposedHeaders = o.ExposedHeaders
+	}
+	if o.AllowCredentials != nil {
+		config.AllowCredentials = *o.AllowCredentials
+	}
+	if o.MaxAge > 0 {
+		config.MaxAge = o.MaxAge
+	}
+	return config
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// IPPreference decides which address family is tried first for upstreams
+// with both A and AAAA records. Auto prefers IPv6, as RFC 8305 does.
+type IPPreference string
+
+const (
+	IPPreferenceAuto IPPreference = "auto"
+	IPPreferenceIPv4 IPPreference = "ipv4"
+	IPPreferenceIPv6 IPPreference = "ipv6"
+)
+
+// DialConfig controls how upstream connections are opened. With
+// HappyEyeballs, the resolved addresses of both families are tried
+// interleaved, each attempt getting AttemptDelay before the next one
+// starts alongside it, so an unreachable family costs a short delay
+// instead of a full ConnectTimeout.
+type DialConfig struct {
+	HappyEyeballs  bool          `json:"happy_eyeballs" yaml:"happy_eyeballs" mapstructure:"happy_eyeballs"`
+	AttemptDelay   time.Duration `json:"attempt_delay" yaml:"attempt_delay" mapstructure:"attempt_delay"`
+	ConnectTimeout time.Duration `json:"connect_timeout" yaml:"connect_timeout" mapstructure:"connect_timeout"`
+	KeepAlive      time.Duration `json:"keep_alive" yaml:"keep_alive" mapstructure:"keep_alive"`
+}
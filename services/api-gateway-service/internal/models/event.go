@@ -0,0 +1,113 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+type HealthEventType string
+
+const (
+	EventStatusChanged HealthEventType = "status_changed"
+	EventSLABreached   HealthEventType = "sla_breached"
+	EventSLARecovered  HealthEventType = "sla_recovered"
+	// EventCanaryRolledBack is published for the canary service when its
+	// route's traffic was shifted back to the stable service
+	EventCanaryRolledBack HealthEventType = "canary_rolled_back"
+	// EventContentAnomaly and EventContentRecovered are published for the
+	// service behind a route whose sampled responses stopped, or started
+	// again, looking like they usually do
+	EventContentAnomaly   HealthEventType = "content_anomaly"
+	EventContentRecovered HealthEventType = "content_recovered"
+	// EventCircuitOpened and EventCircuitClosed are published when a
+	// service's circuit breaker starts turning requests away, and when its
+	// probes succeeded
+	EventCircuitOpened HealthEventType = "circuit_opened"
+	EventCircuitClosed HealthEventType = "circuit_closed"
+	// EventConfigReloaded and EventConfigReloadFailed are published after
+	// each attempt to reload the configuration from a file or etcd
+	EventConfigReloaded     HealthEventType = "config_reloaded"
+	EventConfigReloadFailed HealthEventType = "config_reload_failed"
+	// EventRateLimited is published for every request a rate limit turned
+	// away. It is only streamed, never logged or sent to webhooks.
+	EventRateLimited HealthEventType = "rate_limited"
+)
+
+// HealthEventTypes lists every event type, for validating filters.
+var HealthEventTypes = []HealthEventType{
+	EventStatusChanged, EventSLABreached, EventSLARecovered, EventCanaryRolledBack,
+	EventContentAnomaly, EventContentRecovered, EventCircuitOpened, EventCircuitClosed,
+	EventConfigReloaded, EventConfigReloadFailed, EventRateLimited,
+}
+
+// HealthEvent records a change in a service's status, latency SLA
+// compliance, response content or circuit, or a canary rollback. From and To are
+// only set for status changes. Config reloads and rate limited requests are
+// described the same way on the live event stream; reloads have no service.
+type HealthEvent struct {
+	ID        int64           `json:"id"`
+	Type      HealthEventType `json:"type"`
+	Service   string          `json:"service"`
+	From      ServiceStatus   `json:"from,omitempty"`
+	To        ServiceStatus   `json:"to,omitempty"`
+	Reason    string          `json:"reason,omitempty"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+// Summary describes the event in one line for logs and chat messages.
+func (e HealthEvent) Summary() string {
+	var summary string
+	switch e.Type {
+	case EventSLABreached:
+		summary = fmt.Sprintf("%s is breaching its latency SLA", e.Service)
+	case EventSLARecovered:
+		summary = fmt.Sprintf("%s is meeting its latency SLA again", e.Service)
+	case EventCanaryRolledBack:
+		summary = fmt.Sprintf("%s canary was rolled back", e.Service)
+	case EventContentAnomaly:
+		summary = fmt.Sprintf("%s responses look unusual", e.Service)
+	case EventContentRecovered:
+		summary = fmt.Sprintf("%s responses look usual again", e.Service)
+	case EventCircuitOpened:
+		summary = fmt.Sprintf("%s circuit opened", e.Service)
+	case EventCircuitClosed:
+		summary = fmt.Sprintf("%s circuit closed", e.Service)
+	case EventConfigReloaded:
+		summary = "configuration reloaded"
+	case EventConfigReloadFailed:
+		summary = "configuration reload failed"
+	case EventRateLimited:
+		summary = "request rate limited"
+		if e.Service != "" {
+			summary = e.Service + " " + summary
+		}
+	default:
+		summary = fmt.Sprintf("%s is now %s (was %s)", e.Service, e.To, e.From)
+	}
+	if e.Reason != "" {
+		summary += ": " + e.Reason
+	}
+	return summary
+}
+
+type WebhookFormat string
+
+const (
+	WebhookGeneric WebhookFormat = "generic"
+	WebhookSlack   WebhookFormat = "slack"
+)
+
+// EventsConfig sizes the in-memory event log, caps the live stream's
+// subscribers and lists the webhooks fired on service status changes.
+type EventsConfig struct {
+	LogSize        int             `json:"log_size" yaml:"log_size" mapstructure:"log_size"`
+	MaxSubscribers int             `json:"max_subscribers" yaml:"max_subscribers" mapstructure:"max_subscribers"`
+	Webhooks       []WebhookConfig `json:"webhooks,omitempty" yaml:"webhooks,omitempty" mapstructure:"webhooks"`
+}
+
+type WebhookConfig struct {
+	URL     string            `json:"url" yaml:"url" mapstructure:"url"`
+	Format  WebhookFormat     `json:"format" yaml:"format" mapstructure:"format"`
+	Headers map[string]string `json:"-" yaml:"headers,omitempty" mapstructure:"headers"`
+	Timeout time.Duration     `json:"timeout" yaml:"timeout" mapstructure:"timeout"`
+}
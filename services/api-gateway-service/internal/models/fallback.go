@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// FallbackMode decides what a route answers while its circuit is open.
+type FallbackMode string
+
+const (
+	// FallbackStatic answers with the configured status and JSON body
+	FallbackStatic FallbackMode = "static"
+	// FallbackLastGood answers with the last 200 the route served for the
+	// same URL, to anonymous GET requests only
+	FallbackLastGood FallbackMode = "last_good"
+	// FallbackService sends the requests to a degraded-mode service
+	FallbackService FallbackMode = "service"
+)
+
+// DefaultFallbackMaxAge is how long last_good responses are kept by
+// default.
+const DefaultFallbackMaxAge = 24 * time.Hour
+
+// FallbackConfig is what a route answers instead of 503 while its circuit
+// is open. Requests a fallback cannot answer, such as a last_good request
+// without a stored response, still get the 503.
+type FallbackConfig struct {
+	Mode FallbackMode `json:"mode" yaml:"mode" mapstructure:"mode"`
+	// Status and Body are the static answer; Status defaults to 200
+	Status int    `json:"status,omitempty" yaml:"status,omitempty" mapstructure:"status"`
+	Body   string `json:"body,omitempty" yaml:"body,omitempty" mapstructure:"body"`
+	// MaxAge is how long a last_good response may be served
+	MaxAge time.Duration `json:"max_age,omitempty" yaml:"max_age,omitempty" mapstructure:"max_age"`
+	// ServiceName serves the route's requests in degraded mode
+	ServiceName string `json:"service_name,omitempty" yaml:"service_name,omitempty" mapstructure:"service_name"`
+}
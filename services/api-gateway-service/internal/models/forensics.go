@@ -0,0 +1,55 @@
+package models
+
+import (
+	"net/http"
+	"time"
+)
+
+// RecordedRequest is a failed or captured request kept for inspection.
+// Bodies are truncated to the configured size and sensitive headers are
+// redacted before recording.
+type RecordedRequest struct {
+	ID                    int64         `json:"id"`
+	Timestamp             time.Time     `json:"timestamp"`
+	Method                string        `json:"method"`
+	Path                  string        `json:"path"`
+	Query                 string        `json:"query,omitempty"`
+	ClientIP              string        `json:"client_ip"`
+	Route                 string        `json:"route,omitempty"`
+	Service               string        `json:"service,omitempty"`
+	Status                int           `json:"status"`
+	TerminationReason     string        `json:"termination_reason,omitempty"`
+	Duration              time.Duration `json:"duration"`
+	RequestHeaders        http.Header   `json:"request_headers"`
+	RequestBody           string        `json:"request_body,omitempty"`
+	RequestBodyTruncated  bool          `json:"request_body_truncated,omitempty"`
+	ResponseHeaders       http.Header   `json:"response_headers"`
+	ResponseBody          string        `json:"response_body,omitempty"`
+	ResponseBodyTruncated bool          `json:"response_body_truncated,omitempty"`
+}
+
+// ForensicsConfig controls the in-memory record of the last Size requests
+// that failed with a 5xx status. RedactHeaders are added to the headers
+// that are always redacted (Authorization, Cookie, Set-Cookie and the
+// admin token header). Captures apply the same body limit and redaction.
+type ForensicsConfig struct {
+	Enabled       bool          `json:"enabled" yaml:"enabled" mapstructure:"enabled"`
+	Size          int           `json:"size" yaml:"size" mapstructure:"size"`
+	MaxBodySize   int           `json:"max_body_size" yaml:"max_body_size" mapstructure:"max_body_size"`
+	RedactHeaders []string      `json:"redact_headers,omitempty" yaml:"redact_headers,omitempty" mapstructure:"redact_headers"`
+	Capture       CaptureConfig `json:"capture" yaml:"capture" mapstructure:"capture"`
+}
+
+// CaptureConfig bounds the captures operators start through the admin API:
+// the last Size captured requests are kept, and no capture runs longer
+// than MaxDuration.
+type CaptureConfig struct {
+	Size        int           `json:"size" yaml:"size" mapstructure:"size"`
+	MaxDuration time.Duration `json:"max_duration" yaml:"max_duration" mapstructure:"max_duration"`
+}
+
+// CaptureSession is a route whose requests are captured until Until.
+type CaptureSession struct {
+	Route string    `json:"route"`
+	Until time.Time `json:"until"`
+}
@@ -1,23 +1,131 @@
 package models
 
 import (
+	"context"
+	"io"
+	"strconv"
+	"strings"
+	"text/template"
 	"time"
+	"unicode/utf8"
 )
 
+// CorrelationIDHeader carries the ID that ties the log lines of a request
+// together across services.
+const CorrelationIDHeader = "X-Correlation-ID"
+
+type correlationIDKey struct{}
+
+// WithCorrelationID returns ctx carrying a request's correlation ID.
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, id)
+}
+
+// CorrelationID returns the correlation ID ctx carries, or "".
+func CorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIDKey{}).(string)
+	return id
+}
+
+// Log levels, from the most verbose. Access log lines are at info, warn
+// or error by their status; see StatusLogLevel.
+const (
+	LogLevelDebug = "debug"
+	LogLevelInfo  = "info"
+	LogLevelWarn  = "warn"
+	LogLevelError = "error"
+)
+
+var logLevelRanks = map[string]int{LogLevelDebug: 0, LogLevelInfo: 1, LogLevelWarn: 2, LogLevelError: 3}
+
+// ValidLogLevel reports whether level is one of the log levels.
+func ValidLogLevel(level string) bool {
+	_, ok := logLevelRanks[level]
+	return ok
+}
+
+// LogLevelEnabled reports whether lines at level are written when logging
+// at configured. An unset configured level logs at info.
+func LogLevelEnabled(configured, level string) bool {
+	minimum, ok := logLevelRanks[configured]
+	if !ok {
+		minimum = logLevelRanks[LogLevelInfo]
+	}
+	return logLevelRanks[level] >= minimum
+}
+
+// StatusLogLevel is the level of the access log line of a request answered
+// with status: error for 5xx, warn for 4xx and info otherwise.
+func StatusLogLevel(status int) string {
+	switch {
+	case status >= 500:
+		return LogLevelError
+	case status >= 400:
+		return LogLevelWarn
+	default:
+		return LogLevelInfo
+	}
+}
+
+// Access log formats.
+const (
+	LogFormatJSON = "json"
+	LogFormatText = "text"
+	// LogFormatCombined is the Apache/NCSA combined log format
+	LogFormatCombined = "combined"
+	// LogFormatCustom lays out lines with LoggingConfig.Template
+	LogFormatCustom = "custom"
+)
+
+// logTemplateFuncs are the functions access log templates can call besides
+// text/template's own.
+var logTemplateFuncs = template.FuncMap{
+	// ms turns a duration into milliseconds
+	"ms": func(d time.Duration) float64 { return float64(d.Microseconds()) / 1000 },
+	// quote quotes a string with Go escapes, for values that may hold
+	// spaces or control characters
+	"quote": strconv.Quote,
+}
+
+// ParseLogTemplate parses an access log template and tries it on an empty
+// entry, so that unknown fields are reported when the config is loaded
+// rather than on the first request.
+func ParseLogTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("access_log").Funcs(logTemplateFuncs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if err := tmpl.Execute(io.Discard, &RequestLogEntry{}); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// RequestLogEntry is one access log line. Entries are meant to be reused:
+// Reset keeps the header slice's capacity and AppendJSON encodes into a
+// caller-owned buffer, so a warmed-up entry logs without allocating.
 type RequestLogEntry struct {
-	Timestamp     time.Time         `json:"timestamp"`
-	CorrelationID string            `json:"correlation_id"`
-	Method        string            `json:"method"`
-	Path          string            `json:"path"`
-	ServiceName   string            `json:"service_name,omitempty"`
-	ClientIP      string            `json:"client_ip"`
-	UserID        string            `json:"user_id,omitempty"`
-	StatusCode    int               `json:"status_code"`
-	Duration      time.Duration     `json:"duration"`
-	RequestSize   int64             `json:"request_size"`
-	ResponseSize  int64             `json:"response_size"`
-	Error         string            `json:"error,omitempty"`
-	Headers       map[string]string `json:"headers,omitempty"`
+	Timestamp         time.Time     `json:"timestamp"`
+	Level             string        `json:"level"`
+	CorrelationID     string        `json:"correlation_id"`
+	Method            string        `json:"method"`
+	Path              string        `json:"path"`
+	ServiceName       string        `json:"service_name,omitempty"`
+	ClientIP          string        `json:"client_ip"`
+	UserID            string        `json:"user_id,omitempty"`
+	StatusCode        int           `json:"status_code"`
+	Duration          time.Duration `json:"duration"`
+	RequestSize       int64         `json:"request_size"`
+	ResponseSize      int64         `json:"response_size"`
+	Error             string        `json:"error,omitempty"`
+	TerminationReason string        `json:"termination_reason,omitempty"`
+	Headers           []LogHeader   `json:"headers,omitempty"`
+}
+
+// LogHeader is a request header copied into a log entry.
+type LogHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
 }
 
 func NewRequestLogEntry(correlationID, method, path, clientIP string) *RequestLogEntry {
@@ -27,12 +135,20 @@ func NewRequestLogEntry(correlationID, method, path, clientIP string) *RequestLo
 		Method:        method,
 		Path:          path,
 		ClientIP:      clientIP,
-		Headers:       make(map[string]string),
 	}
 }
 
+// Reset clears the entry for reuse.
+func (r *RequestLogEntry) Reset() {
+	headers := r.Headers[:0]
+	*r = RequestLogEntry{Headers: headers}
+}
+
+// SetResponse records how the request was answered, which also sets the
+// entry's level.
 func (r *RequestLogEntry) SetResponse(statusCode int, duration time.Duration, responseSize int64) {
 	r.StatusCode = statusCode
+	r.Level = StatusLogLevel(statusCode)
 	r.Duration = duration
 	r.ResponseSize = responseSize
 }
@@ -49,12 +165,180 @@ func (r *RequestLogEntry) SetUser(userID string) {
 	r.UserID = userID
 }
 
+// sensitiveLogHeaders carry credentials and are never logged.
+var sensitiveLogHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"X-Api-Key":           true,
+}
+
 func (r *RequestLogEntry) AddHeader(key, value string) {
-	if r.Headers == nil {
-		r.Headers = make(map[string]string)
-	}
 	// Don't log sensitive headers
-	if key != "Authorization" && key != "Cookie" {
-		r.Headers[key] = value
+	if !sensitiveLogHeaders[key] {
+		r.Headers = append(r.Headers, LogHeader{Name: key, Value: value})
+	}
+}
+
+// AppendJSON appends the entry as one line of JSON, with the duration in
+// milliseconds, and returns the extended buffer.
+func (r *RequestLogEntry) AppendJSON(dst []byte) []byte {
+	dst = append(dst, `{"timestamp":"`...)
+	dst = r.Timestamp.UTC().AppendFormat(dst, "2006-01-02T15:04:05.000Z07:00")
+	dst = append(dst, `","level":`...)
+	dst = appendJSONString(dst, r.Level)
+	dst = append(dst, `,"correlation_id":`...)
+	dst = appendJSONString(dst, r.CorrelationID)
+	dst = append(dst, `,"method":`...)
+	dst = appendJSONString(dst, r.Method)
+	dst = append(dst, `,"path":`...)
+	dst = appendJSONString(dst, r.Path)
+	if r.ServiceName != "" {
+		dst = append(dst, `,"service_name":`...)
+		dst = appendJSONString(dst, r.ServiceName)
+	}
+	dst = append(dst, `,"client_ip":`...)
+	dst = appendJSONString(dst, r.ClientIP)
+	if r.UserID != "" {
+		dst = append(dst, `,"user_id":`...)
+		dst = appendJSONString(dst, r.UserID)
+	}
+	dst = append(dst, `,"status_code":`...)
+	dst = strconv.AppendInt(dst, int64(r.StatusCode), 10)
+	dst = append(dst, `,"duration":`...)
+	dst = strconv.AppendFloat(dst, float64(r.Duration.Microseconds())/1000, 'f', -1, 64)
+	dst = append(dst, `,"request_size":`...)
+	dst = strconv.AppendInt(dst, r.RequestSize, 10)
+	dst = append(dst, `,"response_size":`...)
+	dst = strconv.AppendInt(dst, r.ResponseSize, 10)
+	if r.Error != "" {
+		dst = append(dst, `,"error":`...)
+		dst = appendJSONString(dst, r.Error)
+	}
+	if r.TerminationReason != "" {
+		dst = append(dst, `,"termination_reason":`...)
+		dst = appendJSONString(dst, r.TerminationReason)
 	}
-}
\ No newline at end of file
+	if len(r.Headers) > 0 {
+		dst = append(dst, `,"headers":[`...)
+		for i, header := range r.Headers {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = append(dst, `{"name":`...)
+			dst = appendJSONString(dst, header.Name)
+			dst = append(dst, `,"value":`...)
+			dst = appendJSONString(dst, header.Value)
+			dst = append(dst, '}')
+		}
+		dst = append(dst, ']')
+	}
+	return append(dst, "}\n"...)
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendJSONString appends s as a quoted JSON string. Control characters
+// and invalid UTF-8 are escaped the way encoding/json does.
+func appendJSONString(dst []byte, s string) []byte {
+	dst = append(dst, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= 0x20 && b != '"' && b != '\\' {
+				i++
+				continue
+			}
+			dst = append(dst, s[start:i]...)
+			switch b {
+			case '"', '\\':
+				dst = append(dst, '\\', b)
+			case '\n':
+				dst = append(dst, '\\', 'n')
+			case '\r':
+				dst = append(dst, '\\', 'r')
+			case '\t':
+				dst = append(dst, '\\', 't')
+			default:
+				dst = append(dst, '\\', 'u', '0', '0', hexDigits[b>>4], hexDigits[b&0xf])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, `\ufffd`...)
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	dst = append(dst, s[start:]...)
+	return append(dst, '"')
+}
+
+// LogSampling decides which share of requests gets an access log line.
+// Requests no rule matches are all logged, and so are requests slower than
+// SlowThreshold whatever the rules say.
+type LogSampling struct {
+	// Rules are tried in order and the first that matches decides
+	Rules         []LogSamplingRule `json:"rules" yaml:"rules" mapstructure:"rules"`
+	SlowThreshold time.Duration     `json:"slow_threshold,omitempty" yaml:"slow_threshold,omitempty" mapstructure:"slow_threshold"`
+}
+
+// LogSamplingRule logs Rate (0 to 1) of the requests to paths under Path,
+// written as a route's path is, answered with a status matching Status:
+// a class such as "2xx" or a single status such as "404". An empty Path
+// or Status matches every request.
+type LogSamplingRule struct {
+	Path   string  `json:"path,omitempty" yaml:"path,omitempty" mapstructure:"path"`
+	Status string  `json:"status,omitempty" yaml:"status,omitempty" mapstructure:"status"`
+	Rate   float64 `json:"rate" yaml:"rate" mapstructure:"rate"`
+}
+
+// ValidStatus reports whether the rule's status is a class from 1xx to 5xx
+// or a status from 100 to 599.
+func (r LogSamplingRule) ValidStatus() bool {
+	if r.Status == "" {
+		return true
+	}
+	if len(r.Status) == 3 && r.Status[0] >= '1' && r.Status[0] <= '5' && strings.EqualFold(r.Status[1:], "xx") {
+		return true
+	}
+	status, err := strconv.Atoi(r.Status)
+	return err == nil && status >= 100 && status <= 599
+}
+
+// Matches reports whether the rule covers a request to path answered with
+// status.
+func (r LogSamplingRule) Matches(path string, status int) bool {
+	if r.Status != "" {
+		if strings.EqualFold(r.Status[1:], "xx") {
+			if int(r.Status[0]-'0') != status/100 {
+				return false
+			}
+		} else if r.Status != strconv.Itoa(status) {
+			return false
+		}
+	}
+	prefix := strings.TrimSuffix(r.Path, "/*")
+	return strings.HasPrefix(path, prefix)
+}
+
+// Sample reports whether a request to path answered with status after
+// elapsed is logged, drawing with random, which returns a number in
+// [0, 1), for rules that log only a share.
+func (s *LogSampling) Sample(path string, status int, elapsed time.Duration, random func() float64) bool {
+	if s.SlowThreshold > 0 && elapsed >= s.SlowThreshold {
+		return true
+	}
+	for _, rule := range s.Rules {
+		if rule.Matches(path, status) {
+			return rule.Rate >= 1 || (rule.Rate > 0 && random() < rule.Rate)
+		}
+	}
+	return true
+}
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// PlansConfig assigns clients to usage plans, such as free, pro and
+// internal tiers, each with its own rate limit and quotas. An API key
+// listed in APIKeys uses its plan; otherwise an authenticated user's Claim
+// names the plan, and everyone else is on Default. API keys are read from
+// the quota header and counted in the quota store.
+type PlansConfig struct {
+	Enabled bool   `json:"enabled" yaml:"enabled" mapstructure:"enabled"`
+	Claim   string `json:"claim" yaml:"claim" mapstructure:"claim"`
+	Default string `json:"default,omitempty" yaml:"default,omitempty" mapstructure:"default"`
+	// Tiers are the plans by name
+	Tiers   map[string]PlanConfig `json:"tiers,omitempty" yaml:"tiers,omitempty" mapstructure:"tiers"`
+	APIKeys []PlanAPIKey          `json:"api_keys,omitempty" yaml:"api_keys,omitempty" mapstructure:"api_keys"`
+}
+
+// PlanConfig is what one plan allows. A plan without a rate limit or
+// without allowances is not limited in that respect.
+type PlanConfig struct {
+	RateLimit *PlanRateLimit `json:"rate_limit,omitempty" yaml:"rate_limit,omitempty" mapstructure:"rate_limit"`
+	Daily     int64          `json:"daily,omitempty" yaml:"daily,omitempty" mapstructure:"daily"`
+	Monthly   int64          `json:"monthly,omitempty" yaml:"monthly,omitempty" mapstructure:"monthly"`
+}
+
+type PlanRateLimit struct {
+	Requests int           `json:"requests" yaml:"requests" mapstructure:"requests"`
+	Window   time.Duration `json:"window" yaml:"window" mapstructure:"window"`
+	Burst    int           `json:"burst" yaml:"burst" mapstructure:"burst"`
+}
+
+// PlanAPIKey puts the API key KeyRef points at, e.g. env:ACME_API_KEY, on
+// a plan.
+type PlanAPIKey struct {
+	Name   string `json:"name" yaml:"name" mapstructure:"name"`
+	KeyRef string `json:"key_ref" yaml:"key_ref" mapstructure:"key_ref"`
+	Plan   string `json:"plan" yaml:"plan" mapstructure:"plan"`
+}
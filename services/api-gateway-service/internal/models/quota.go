@@ -0,0 +1,29 @@
+package models
+
+type QuotaStoreType string
+
+const (
+	QuotaStoreMemory QuotaStoreType = "memory"
+	QuotaStoreRedis  QuotaStoreType = "redis"
+)
+
+// QuotaPeriod is the span a quota allowance covers. Periods follow the UTC
+// calendar: daily allowances renew at midnight, monthly ones on the 1st.
+type QuotaPeriod string
+
+const (
+	QuotaDaily   QuotaPeriod = "daily"
+	QuotaMonthly QuotaPeriod = "monthly"
+)
+
+// QuotaConfig caps the requests each API key, sent in Header, may make per
+// day and per month. Unlike rate limits, quotas are meant to be kept in a
+// shared store so they survive restarts and hold across replicas. A zero
+// allowance means no cap for that period.
+type QuotaConfig struct {
+	Enabled bool           `json:"enabled" yaml:"enabled" mapstructure:"enabled"`
+	Header  string         `json:"header" yaml:"header" mapstructure:"header"`
+	Store   QuotaStoreType `json:"store" yaml:"store" mapstructure:"store"`
+	Daily   int64          `json:"daily" yaml:"daily" mapstructure:"daily"`
+	Monthly int64          `json:"monthly" yaml:"monthly" mapstructure:"monthly"`
+}
@@ -19,6 +19,21 @@ type RateLimitPolicy struct {
 	Burst    int           `json:"burst" yaml:"burst" validate:"required,min=1"`
 	Scope    LimitScope    `json:"scope" yaml:"scope"`
 	Enabled  bool          `json:"enabled" yaml:"enabled"`
+	Penalty  PenaltyConfig `json:"penalty" yaml:"penalty" mapstructure:"penalty"`
+}
+
+// PenaltyConfig controls adaptive throttling of clients that keep hitting
+// 429s, from the gateway's own limit or from upstreams. Each 429 is a
+// strike; strikes decay with HalfLife. A client with Threshold strikes is
+// held to the limit scaled by RateFactor, and its rejections are delayed
+// by TarpitDelay per Threshold strikes, up to MaxTarpitDelay.
+type PenaltyConfig struct {
+	Enabled        bool          `json:"enabled" yaml:"enabled" mapstructure:"enabled"`
+	Threshold      int           `json:"threshold" yaml:"threshold" mapstructure:"threshold"`
+	HalfLife       time.Duration `json:"half_life" yaml:"half_life" mapstructure:"half_life"`
+	RateFactor     float64       `json:"rate_factor" yaml:"rate_factor" mapstructure:"rate_factor"`
+	TarpitDelay    time.Duration `json:"tarpit_delay" yaml:"tarpit_delay" mapstructure:"tarpit_delay"`
+	MaxTarpitDelay time.Duration `json:"max_tarpit_delay" yaml:"max_tarpit_delay" mapstructure:"max_tarpit_delay"`
 }
 
 func NewRateLimitPolicy(name string, requests int, window time.Duration, burst int) *RateLimitPolicy {
@@ -38,4 +53,58 @@ func (r *RateLimitPolicy) GetRate() float64 {
 
 func (r *RateLimitPolicy) IsValid() bool {
 	return r.Requests > 0 && r.Window > 0 && r.Burst >= r.Requests
-}
\ No newline at end of file
+}
+
+// RouteRateLimit caps the requests a route forwards to its service, across
+// all clients, to protect the service rather than share it fairly.
+type RouteRateLimit struct {
+	Requests int           `json:"requests" yaml:"requests" mapstructure:"requests"`
+	Window   time.Duration `json:"window" yaml:"window" mapstructure:"window"`
+	// Burst defaults to Requests
+	Burst    int            `json:"burst,omitempty" yaml:"burst,omitempty" mapstructure:"burst"`
+	Adaptive *AdaptiveLimit `json:"adaptive,omitempty" yaml:"adaptive,omitempty" mapstructure:"adaptive"`
+}
+
+// AdaptiveLimit scales a route's limit with the health of its upstream.
+// After each window whose p99 latency exceeds MaxP99Latency or whose error
+// rate exceeds MaxErrorRate the limit is multiplied by Decrease, down to
+// MinFactor of the configured one; after each healthy window it grows back
+// by Increase of the configured limit. Windows with fewer than MinRequests
+// requests count as healthy, so a tightened limit can always recover.
+type AdaptiveLimit struct {
+	MaxP99Latency time.Duration `json:"max_p99_latency,omitempty" yaml:"max_p99_latency,omitempty" mapstructure:"max_p99_latency"`
+	MaxErrorRate  float64       `json:"max_error_rate,omitempty" yaml:"max_error_rate,omitempty" mapstructure:"max_error_rate"`
+	Window        time.Duration `json:"window,omitempty" yaml:"window,omitempty" mapstructure:"window"`
+	MinRequests   int           `json:"min_requests,omitempty" yaml:"min_requests,omitempty" mapstructure:"min_requests"`
+	Decrease      float64       `json:"decrease,omitempty" yaml:"decrease,omitempty" mapstructure:"decrease"`
+	Increase      float64       `json:"increase,omitempty" yaml:"increase,omitempty" mapstructure:"increase"`
+	MinFactor     float64       `json:"min_factor,omitempty" yaml:"min_factor,omitempty" mapstructure:"min_factor"`
+}
+
+const (
+	DefaultAdaptiveWindow      = 10 * time.Second
+	DefaultAdaptiveMinRequests = 20
+	DefaultAdaptiveDecrease    = 0.5
+	DefaultAdaptiveIncrease    = 0.1
+	DefaultAdaptiveMinFactor   = 0.1
+)
+
+// Defaults fills in the settings left unset.
+func (a AdaptiveLimit) Defaults() AdaptiveLimit {
+	if a.Window <= 0 {
+		a.Window = DefaultAdaptiveWindow
+	}
+	if a.MinRequests <= 0 {
+		a.MinRequests = DefaultAdaptiveMinRequests
+	}
+	if a.Decrease <= 0 {
+		a.Decrease = DefaultAdaptiveDecrease
+	}
+	if a.Increase <= 0 {
+		a.Increase = DefaultAdaptiveIncrease
+	}
+	if a.MinFactor <= 0 {
+		a.MinFactor = DefaultAdaptiveMinFactor
+	}
+	return a
+}
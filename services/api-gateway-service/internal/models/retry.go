@@ -0,0 +1,57 @@
+package models
+
+import (
+	"net/http"
+	"time"
+)
+
+// RetryPolicy sends an idempotent request to a service again when the
+// service could not be reached or answered with one of the RetryOn
+// statuses. Only requests without a body are retried, and all attempts
+// share the service's timeout.
+type RetryPolicy struct {
+	// MaxRetries is how many times a request is tried again after the
+	// first attempt
+	MaxRetries int `json:"max_retries" yaml:"max_retries" mapstructure:"max_retries"`
+	// Backoff is the wait before the first retry, doubled before each
+	// following one
+	Backoff time.Duration `json:"backoff,omitempty" yaml:"backoff,omitempty" mapstructure:"backoff"`
+	RetryOn []int         `json:"retry_on,omitempty" yaml:"retry_on,omitempty" mapstructure:"retry_on"`
+}
+
+const DefaultRetryBackoff = 50 * time.Millisecond
+
+// DefaultRetryOn are the statuses retried when retry_on is not set: those
+// that usually mean the request never reached a working instance.
+var DefaultRetryOn = []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+
+// Defaults fills in the backoff and statuses left unset.
+func (r RetryPolicy) Defaults() RetryPolicy {
+	if r.Backoff <= 0 {
+		r.Backoff = DefaultRetryBackoff
+	}
+	if len(r.RetryOn) == 0 {
+		r.RetryOn = DefaultRetryOn
+	}
+	return r
+}
+
+// Retries reports whether an answer with status is worth another attempt.
+func (r RetryPolicy) Retries(status int) bool {
+	for _, retryOn := range r.RetryOn {
+		if status == retryOn {
+			return true
+		}
+	}
+	return false
+}
+
+// IsIdempotent reports whether requests with method may safely be sent
+// twice.
+func IsIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
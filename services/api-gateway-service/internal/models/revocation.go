@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+type RevocationSourceType string
+
+const (
+	RevocationSourceAuthService RevocationSourceType = "auth_service"
+	RevocationSourceRedis       RevocationSourceType = "redis"
+)
+
+// RevocationConfig makes the gateway reject revoked tokens before they
+// expire, even while the verification cache or a provider still accepts
+// them. The denylist is pulled every Interval, either from Path on the
+// auth service or from the redis sorted set <key_prefix>revoked_tokens.
+type RevocationConfig struct {
+	Enabled  bool                 `json:"enabled" yaml:"enabled" mapstructure:"enabled"`
+	Source   RevocationSourceType `json:"source" yaml:"source" mapstructure:"source"`
+	Path     string               `json:"path,omitempty" yaml:"path,omitempty" mapstructure:"path"`
+	Interval time.Duration        `json:"interval" yaml:"interval" mapstructure:"interval"`
+}
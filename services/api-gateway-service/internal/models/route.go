@@ -1,5 +1,10 @@
 package models
 
+import (
+	"strings"
+	"time"
+)
+
 type RouteConfig struct {
 	Path         string            `json:"path" yaml:"path" mapstructure:"path" validate:"required"`
 	Method       string            `json:"method" yaml:"method" mapstructure:"method"`
@@ -7,8 +12,85 @@ type RouteConfig struct {
 	StripPrefix  bool              `json:"strip_prefix" yaml:"strip_prefix" mapstructure:"strip_prefix"`
 	Headers      map[string]string `json:"headers,omitempty" yaml:"headers,omitempty" mapstructure:"headers"`
 	AuthRequired bool              `json:"auth_required" yaml:"auth_required" mapstructure:"auth_required"`
+	// AuthMode supersedes AuthRequired; see Auth.
+	AuthMode AuthMode `json:"auth_mode,omitempty" yaml:"auth_mode,omitempty" mapstructure:"auth_mode"`
+	// AuthProvider names the auth_providers entry verifying the route's
+	// tokens; empty means the auth service
+	AuthProvider string        `json:"auth_provider,omitempty" yaml:"auth_provider,omitempty" mapstructure:"auth_provider"`
+	CacheTTL     time.Duration `json:"cache_ttl,omitempty" yaml:"cache_ttl,omitempty" mapstructure:"cache_ttl"`
+	// ClientCert authenticates clients by their TLS certificate instead of
+	// a token, for partner integrations
+	ClientCert *ClientCertPolicy `json:"client_cert,omitempty" yaml:"client_cert,omitempty" mapstructure:"client_cert"`
+	// OIDCLogin sends browsers without a session through the OIDC login
+	// flow instead of expecting a bearer token.
+	OIDCLogin bool `json:"oidc_login,omitempty" yaml:"oidc_login,omitempty" mapstructure:"oidc_login"`
+	// IPAccess restricts the route to client addresses, on top of the
+	// gateway's ip_access lists
+	IPAccess *IPAccessConfig `json:"ip_access,omitempty" yaml:"ip_access,omitempty" mapstructure:"ip_access"`
+	// CORS replaces some of the gateway's CORS settings for the route
+	CORS *CORSOverride `json:"cors,omitempty" yaml:"cors,omitempty" mapstructure:"cors"`
+	// GeoAccess restricts the route to clients from some countries
+	GeoAccess *GeoAccessConfig `json:"geo_access,omitempty" yaml:"geo_access,omitempty" mapstructure:"geo_access"`
+
+	// CookiePolicy rewrites the Set-Cookie headers of the upstream
+	CookiePolicy *CookiePolicy `json:"cookie_policy,omitempty" yaml:"cookie_policy,omitempty" mapstructure:"cookie_policy"`
+	// Signature requires an HMAC of the body, for webhook ingress routes
+	Signature *SignatureConfig `json:"signature,omitempty" yaml:"signature,omitempty" mapstructure:"signature"`
+	// WebSocket limits the sockets upgraded on the route
+	WebSocket *WebSocketPolicy `json:"websocket,omitempty" yaml:"websocket,omitempty" mapstructure:"websocket"`
+
+	// Timeout is the route's overall budget, from the first middleware to
+	// the last byte of the upstream's answer. Composite routes derive the
+	// timeouts of their legs from it.
+	Timeout   time.Duration    `json:"timeout,omitempty" yaml:"timeout,omitempty" mapstructure:"timeout"`
+	Composite *CompositeConfig `json:"composite,omitempty" yaml:"composite,omitempty" mapstructure:"composite"`
+	// MaxWait lets clients shorten the upstream deadline with X-Max-Wait,
+	// up to this long
+	MaxWait time.Duration `json:"max_wait,omitempty" yaml:"max_wait,omitempty" mapstructure:"max_wait"`
+	// Canary sends a weighted share of the route's requests to another
+	// service
+	Canary *CanaryConfig `json:"canary,omitempty" yaml:"canary,omitempty" mapstructure:"canary"`
+	// RateLimit caps the requests forwarded to the service across all
+	// clients, optionally adapting to the service's health
+	RateLimit *RouteRateLimit `json:"rate_limit,omitempty" yaml:"rate_limit,omitempty" mapstructure:"rate_limit"`
+	// Cost is how many tokens a request takes from the caller's rate limit
+	// bucket, so expensive endpoints run out sooner. It defaults to 1.
+	Cost int `json:"cost,omitempty" yaml:"cost,omitempty" mapstructure:"cost"`
+	// CircuitBreaker gives the route a circuit of its own, with the
+	// service's circuit breaker settings overridden, so a fragile endpoint
+	// does not take the rest of the service down with it
+	CircuitBreaker *CircuitBreakerOverride `json:"circuit_breaker,omitempty" yaml:"circuit_breaker,omitempty" mapstructure:"circuit_breaker"`
+	// Fallback answers the route's requests while its circuit is open
+	Fallback *FallbackConfig `json:"fallback,omitempty" yaml:"fallback,omitempty" mapstructure:"fallback"`
+	// SLO tracks objectives for the route's requests
+	SLO *SLOConfig `json:"slo,omitempty" yaml:"slo,omitempty" mapstructure:"slo"`
+
+	// A route with MigrateTo is an alias for a moved path prefix rather
+	// than a route to a service.
+	MigrateTo     string        `json:"migrate_to,omitempty" yaml:"migrate_to,omitempty" mapstructure:"migrate_to"`
+	MigrationMode MigrationMode `json:"migration_mode,omitempty" yaml:"migration_mode,omitempty" mapstructure:"migration_mode"`
 }
 
+// AuthMode decides whether a route needs a bearer token. Optional routes
+// verify a token when one is sent, so the request carries the user, but
+// also serve anonymous clients.
+type AuthMode string
+
+const (
+	AuthModeRequired AuthMode = "required"
+	AuthModeOptional AuthMode = "optional"
+	AuthModeNone     AuthMode = "none"
+)
+
+// MigrationMode decides how requests to a migrated path are handled: a 308
+// redirect the client can learn from, or a transparent rewrite.
+type MigrationMode string
+
+const (
+	MigrationRedirect MigrationMode = "redirect"
+	MigrationRewrite  MigrationMode = "rewrite"
+)
+
 func NewRouteConfig(path, serviceName string) *RouteConfig {
 	return &RouteConfig{
 		Path:        path,
@@ -39,6 +121,78 @@ func (r *RouteConfig) Matches(method, path string) bool {
 	return false
 }
 
+// MatchesExactly is how routes of strict services match: only the route's
+// own method, and a path equal to the route's segment by segment, where a
+// :name segment stands for any one segment.
+func (r *RouteConfig) MatchesExactly(method, path string) bool {
+	if r.Method != method {
+		return false
+	}
+
+	routePath := r.Path
+	for {
+		routeSegment, routeRest, routeMore := strings.Cut(routePath, "/")
+		segment, rest, more := strings.Cut(path, "/")
+		if routeMore != more {
+			return false
+		}
+		if strings.HasPrefix(routeSegment, ":") {
+			if segment == "" {
+				return false
+			}
+		} else if routeSegment != segment {
+			return false
+		}
+		if !more {
+			return true
+		}
+		routePath, path = routeRest, rest
+	}
+}
+
+// IsWildcard reports whether the route covers everything under its path.
+func (r *RouteConfig) IsWildcard() bool {
+	return strings.HasSuffix(r.Path, "/*")
+}
+
+// Auth returns the route's auth mode. Routes without auth_mode keep the
+// meaning of auth_required.
+func (r *RouteConfig) Auth() AuthMode {
+	if r.AuthMode != "" {
+		return r.AuthMode
+	}
+	if r.AuthRequired {
+		return AuthModeRequired
+	}
+	return AuthModeNone
+}
+
+// IsComposite reports whether the route aggregates several upstream calls
+// instead of proxying to one service.
+func (r *RouteConfig) IsComposite() bool {
+	return r.Composite != nil && len(r.Composite.Legs) > 0
+}
+
+// TokenCost returns the tokens a request to the route costs, at least one.
+func (r *RouteConfig) TokenCost() int {
+	if r.Cost < 1 {
+		return 1
+	}
+	return r.Cost
+}
+
+func (r *RouteConfig) IsMigration() bool {
+	return r.MigrateTo != ""
+}
+
+// MigratedPath maps a request path under the route onto MigrateTo, e.g.
+// /api/cart/items onto /api/checkout/items.
+func (r *RouteConfig) MigratedPath(requestPath string) string {
+	from := strings.TrimSuffix(r.Path, "/*")
+	to := strings.TrimSuffix(r.MigrateTo, "/*")
+	return to + strings.TrimPrefix(requestPath, from)
+}
+
 func (r *RouteConfig) ExtractProxyPath(requestPath string) string {
 	if !r.StripPrefix {
 		return requestPath
@@ -55,4 +209,4 @@ func (r *RouteConfig) ExtractProxyPath(requestPath string) string {
 	}
 
 	return "/"
-}
\ No newline at end of file
+}
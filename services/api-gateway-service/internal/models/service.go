@@ -1,6 +1,7 @@
 package models
 
 import (
+	"bytes"
 	"time"
 )
 
@@ -10,29 +11,68 @@ const (
 	ServiceHealthy   ServiceStatus = "healthy"
 	ServiceUnhealthy ServiceStatus = "unhealthy"
 	ServiceUnknown   ServiceStatus = "unknown"
+	// ServiceDegraded means the health endpoint answers but live traffic
+	// is failing.
+	ServiceDegraded ServiceStatus = "degraded"
+)
+
+// ValidationErrorMode decides what happens to upstream 422 responses:
+// passed through as they are, or normalized into the gateway's
+// problem+json envelope.
+type ValidationErrorMode string
+
+const (
+	ValidationErrorsPassthrough ValidationErrorMode = "passthrough"
+	ValidationErrorsNormalize   ValidationErrorMode = "normalize"
 )
 
 type ServiceConfig struct {
-	Name        string            `json:"name" yaml:"name" validate:"required"`
-	URL         string            `json:"url" yaml:"url" validate:"required,url"`
-	Timeout     time.Duration     `json:"timeout" yaml:"timeout" validate:"required"`
-	HealthPath  string            `json:"health_path" yaml:"health_path"`
-	Headers     map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
-	Enabled     bool              `json:"enabled" yaml:"enabled"`
-	LastChecked time.Time         `json:"last_checked"`
-	Status      ServiceStatus     `json:"status"`
-	ResponseTime float64          `json:"response_time,omitempty"`
+	Name                 string              `json:"name" yaml:"name" validate:"required"`
+	URL                  string              `json:"url" yaml:"url" validate:"required,url"`
+	Timeout              time.Duration       `json:"timeout" yaml:"timeout" validate:"required"`
+	HealthPath           string              `json:"health_path" yaml:"health_path" mapstructure:"health_path"`
+	HealthInterval       time.Duration       `json:"health_interval,omitempty" yaml:"health_interval" mapstructure:"health_interval"`
+	HealthMethod         string              `json:"health_method,omitempty" yaml:"health_method" mapstructure:"health_method"`
+	HealthExpectedStatus []int               `json:"health_expected_status,omitempty" yaml:"health_expected_status" mapstructure:"health_expected_status"`
+	HealthExpectedBody   string              `json:"health_expected_body,omitempty" yaml:"health_expected_body" mapstructure:"health_expected_body"`
+	PrewarmConnections   int                 `json:"prewarm_connections,omitempty" yaml:"prewarm_connections" mapstructure:"prewarm_connections"`
+	LatencySLA           time.Duration       `json:"latency_sla,omitempty" yaml:"latency_sla" mapstructure:"latency_sla"`
+	ValidationErrors     ValidationErrorMode `json:"validation_errors,omitempty" yaml:"validation_errors,omitempty" mapstructure:"validation_errors"`
+	// MaxInFlight caps the service's concurrent proxied requests; those
+	// beyond it wait up to QueueTimeout for a slot before getting a 503
+	MaxInFlight  int           `json:"max_in_flight,omitempty" yaml:"max_in_flight,omitempty" mapstructure:"max_in_flight"`
+	QueueTimeout time.Duration `json:"queue_timeout,omitempty" yaml:"queue_timeout,omitempty" mapstructure:"queue_timeout"`
+	// StrictRoutes forwards only the exact paths and methods of routes
+	StrictRoutes bool `json:"strict_routes,omitempty" yaml:"strict_routes,omitempty" mapstructure:"strict_routes"`
+	// CircuitBreaker overrides the gateway-wide circuit breaker settings
+	CircuitBreaker *CircuitBreakerOverride `json:"circuit_breaker,omitempty" yaml:"circuit_breaker,omitempty" mapstructure:"circuit_breaker"`
+	// Retry sends failed idempotent requests to the service again
+	Retry *RetryPolicy `json:"retry,omitempty" yaml:"retry,omitempty" mapstructure:"retry"`
+	// SLO tracks objectives for all of the service's proxied requests
+	SLO *SLOConfig `json:"slo,omitempty" yaml:"slo,omitempty" mapstructure:"slo"`
+	// IPPreference overrides which address family is dialed first
+	IPPreference IPPreference      `json:"ip_preference,omitempty" yaml:"ip_preference,omitempty" mapstructure:"ip_preference"`
+	Headers      map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	Enabled      bool              `json:"enabled" yaml:"enabled"`
+	// Health state, reported by the gateway rather than configured
+	LastChecked      time.Time     `json:"last_checked" yaml:"-"`
+	Status           ServiceStatus `json:"status" yaml:"-"`
+	Flapping         bool          `json:"flapping,omitempty" yaml:"-"`
+	ResponseTime     float64       `json:"response_time,omitempty" yaml:"-"`
+	SLAViolationRate float64       `json:"sla_violation_rate,omitempty" yaml:"-"`
+	SLABreached      bool          `json:"sla_breached,omitempty" yaml:"-"`
 }
 
 func NewServiceConfig(name, url string, timeout time.Duration) *ServiceConfig {
 	return &ServiceConfig{
-		Name:       name,
-		URL:        url,
-		Timeout:    timeout,
-		HealthPath: "/health",
-		Enabled:    true,
-		Status:     ServiceUnknown,
-		Headers:    make(map[string]string),
+		Name:         name,
+		URL:          url,
+		Timeout:      timeout,
+		HealthPath:   "/health",
+		HealthMethod: "GET",
+		Enabled:      true,
+		Status:       ServiceUnknown,
+		Headers:      make(map[string]string),
 	}
 }
 
@@ -44,4 +84,29 @@ func (s *ServiceConfig) UpdateStatus(status ServiceStatus, responseTime float64)
 	s.Status = status
 	s.LastChecked = time.Now()
 	s.ResponseTime = responseTime
-}
\ No newline at end of file
+}
+
+// HealthCheckPassed reports whether a health probe response counts as
+// healthy: any 2xx unless specific status codes are configured, and the
+// body must contain HealthExpectedBody when set.
+func (s *ServiceConfig) HealthCheckPassed(statusCode int, body []byte) bool {
+	if len(s.HealthExpectedStatus) > 0 {
+		matched := false
+		for _, expected := range s.HealthExpectedStatus {
+			if statusCode == expected {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	} else if statusCode < 200 || statusCode >= 300 {
+		return false
+	}
+
+	if s.HealthExpectedBody != "" {
+		return bytes.Contains(body, []byte(s.HealthExpectedBody))
+	}
+	return true
+}
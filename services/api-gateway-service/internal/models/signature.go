@@ -0,0 +1,50 @@
+package models
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"hash"
+	"time"
+)
+
+// SignatureScheme is how a webhook provider lays out its signature header.
+type SignatureScheme string
+
+const (
+	// SignaturePlain headers hold the HMAC of the body, optionally after a
+	// prefix such as GitHub's "sha256="
+	SignaturePlain SignatureScheme = "plain"
+	// SignatureStripe headers look like "t=<unix>,v1=<hmac>", signing
+	// "<unix>.<body>" so old deliveries cannot be replayed
+	SignatureStripe SignatureScheme = "stripe"
+)
+
+// SignatureConfig requires requests to a route to carry an HMAC of their
+// body made with a secret shared with the sender. SecretRef points at the
+// secret (env:NAME or file:/path) instead of holding it.
+type SignatureConfig struct {
+	Header    string          `json:"header" yaml:"header" mapstructure:"header"`
+	Algorithm string          `json:"algorithm,omitempty" yaml:"algorithm,omitempty" mapstructure:"algorithm"`
+	SecretRef string          `json:"secret_ref" yaml:"secret_ref" mapstructure:"secret_ref"`
+	Scheme    SignatureScheme `json:"scheme,omitempty" yaml:"scheme,omitempty" mapstructure:"scheme"`
+	// Encoding of the digest in plain headers: hex (default) or base64
+	Encoding string `json:"encoding,omitempty" yaml:"encoding,omitempty" mapstructure:"encoding"`
+	Prefix   string `json:"prefix,omitempty" yaml:"prefix,omitempty" mapstructure:"prefix"`
+	// Tolerance bounds the age of stripe-scheme timestamps; default 5m
+	Tolerance   time.Duration `json:"tolerance,omitempty" yaml:"tolerance,omitempty" mapstructure:"tolerance"`
+	MaxBodySize int64         `json:"max_body_size,omitempty" yaml:"max_body_size,omitempty" mapstructure:"max_body_size"`
+}
+
+// Hash returns the HMAC hash function, or nil for unsupported algorithms.
+func (s *SignatureConfig) Hash() func() hash.Hash {
+	switch s.Algorithm {
+	case "", "sha256":
+		return sha256.New
+	case "sha1":
+		return sha1.New
+	case "sha512":
+		return sha512.New
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// SLOConfig sets service level objectives for a route's or a service's
+// requests, measured at the gateway. Availability is the share of requests
+// to answer without a 5xx; LatencyTarget is the share to answer within
+// Latency. Compliance is judged over the rolling Window.
+type SLOConfig struct {
+	Availability  float64       `json:"availability,omitempty" yaml:"availability,omitempty" mapstructure:"availability"`
+	Latency       time.Duration `json:"latency,omitempty" yaml:"latency,omitempty" mapstructure:"latency"`
+	LatencyTarget float64       `json:"latency_target,omitempty" yaml:"latency_target,omitempty" mapstructure:"latency_target"`
+	Window        time.Duration `json:"window,omitempty" yaml:"window,omitempty" mapstructure:"window"`
+}
+
+const (
+	DefaultSLOWindow = 24 * time.Hour
+	// MaxSLOWindow bounds how far back compliance is kept in memory
+	MaxSLOWindow = 30 * 24 * time.Hour
+	// DefaultSLOLatencyTarget applies when only a latency is set
+	DefaultSLOLatencyTarget = 0.99
+)
+
+// Defaults fills in the window and latency target left unset.
+func (s SLOConfig) Defaults() SLOConfig {
+	if s.Window <= 0 {
+		s.Window = DefaultSLOWindow
+	}
+	if s.Latency > 0 && s.LatencyTarget == 0 {
+		s.LatencyTarget = DefaultSLOLatencyTarget
+	}
+	return s
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// WebSocketPolicy limits the WebSocket connections proxied on a route.
+// MaxConnections caps the route's open sockets, MaxConnectionsPerClient
+// those of one client IP. Messages larger than MaxMessageSize bytes and
+// sockets silent for IdleTimeout in both directions are closed. Zero
+// values mean no limit.
+type WebSocketPolicy struct {
+	MaxConnections          int           `json:"max_connections,omitempty" yaml:"max_connections,omitempty" mapstructure:"max_connections"`
+	MaxConnectionsPerClient int           `json:"max_connections_per_client,omitempty" yaml:"max_connections_per_client,omitempty" mapstructure:"max_connections_per_client"`
+	MaxMessageSize          int64         `json:"max_message_size,omitempty" yaml:"max_message_size,omitempty" mapstructure:"max_message_size"`
+	IdleTimeout             time.Duration `json:"idle_timeout,omitempty" yaml:"idle_timeout,omitempty" mapstructure:"idle_timeout"`
+}
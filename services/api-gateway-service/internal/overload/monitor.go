@@ -0,0 +1,177 @@
+package overload
+
+import (
+	"context"
+	"log"
+	"math"
+	"runtime/debug"
+	"runtime/metrics"
+	"sync"
+	"time"
+
+	"gateway/internal/models"
+)
+
+const (
+	metricCPUTotal    = "/cpu/classes/total:cpu-seconds"
+	metricCPUIdle     = "/cpu/classes/idle:cpu-seconds"
+	metricMemoryTotal = "/memory/classes/total:bytes"
+)
+
+// Monitor watches the gateway's own CPU and memory use through runtime
+// metrics and reports when it is overloaded, so background work can back
+// off in favour of user traffic. CPU use is relative to GOMAXPROCS.
+type Monitor struct {
+	config models.OverloadConfig
+
+	mutex      sync.RWMutex
+	overloaded bool
+	cpu        float64
+	memory     float64
+	calmSince  time.Time
+	listeners  []func(overloaded bool)
+
+	samples   []metrics.Sample
+	lastTotal float64
+	lastIdle  float64
+}
+
+func NewMonitor(config models.OverloadConfig) *Monitor {
+	return &Monitor{
+		config: config,
+		samples: []metrics.Sample{
+			{Name: metricCPUTotal},
+			{Name: metricCPUIdle},
+			{Name: metricMemoryTotal},
+		},
+	}
+}
+
+// Overloaded reports the current state; a nil Monitor is never overloaded.
+func (m *Monitor) Overloaded() bool {
+	if m == nil {
+		return false
+	}
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.overloaded
+}
+
+// OnChange registers a callback for entering and leaving overload.
+func (m *Monitor) OnChange(listener func(overloaded bool)) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.listeners = append(m.listeners, listener)
+}
+
+func (m *Monitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.config.CheckInterval)
+	defer ticker.Stop()
+
+	m.sample()
+	for {
+		select {
+		case <-ticker.C:
+			m.Check()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (m *Monitor) Stats() map[string]interface{} {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return map[string]interface{}{
+		"overloaded":   m.overloaded,
+		"cpu_usage":    math.Round(m.cpu*1000) / 1000,
+		"memory_usage": math.Round(m.memory*1000) / 1000,
+	}
+}
+
+// Check samples the runtime metrics and updates the state. CPU use is
+// measured since the previous sample.
+func (m *Monitor) Check() {
+	m.Observe(m.sample())
+}
+
+// Observe updates the state from a reading of CPU and memory use, each a
+// fraction of what is available. Zero memory use means it is not tracked.
+func (m *Monitor) Observe(cpu, memory float64) {
+	m.mutex.Lock()
+	m.cpu, m.memory = cpu, memory
+	m.mutex.Unlock()
+
+	m.evaluate(cpu >= m.config.CPUThreshold || (memory > 0 && memory >= m.config.MemoryThreshold))
+}
+
+// sample reads the runtime metrics and returns CPU and memory use.
+func (m *Monitor) sample() (float64, float64) {
+	metrics.Read(m.samples)
+
+	total := m.samples[0].Value.Float64()
+	idle := m.samples[1].Value.Float64()
+	cpu := 0.0
+	if elapsed := total - m.lastTotal; elapsed > 0 {
+		cpu = 1 - (idle-m.lastIdle)/elapsed
+	}
+	m.lastTotal, m.lastIdle = total, idle
+
+	memory := 0.0
+	if limit := m.memoryLimit(); limit > 0 {
+		memory = float64(m.samples[2].Value.Uint64()) / float64(limit)
+	}
+	return cpu, memory
+}
+
+// memoryLimit is the configured limit, falling back to GOMEMLIMIT. Zero
+// means memory pressure is not tracked.
+func (m *Monitor) memoryLimit() int64 {
+	if m.config.MemoryLimit > 0 {
+		return m.config.MemoryLimit
+	}
+	if limit := debug.SetMemoryLimit(-1); limit != math.MaxInt64 {
+		return limit
+	}
+	return 0
+}
+
+// evaluate enters overload immediately and leaves it only after resource
+// use has stayed below the thresholds for the cool-down period.
+func (m *Monitor) evaluate(pressure bool) {
+	m.mutex.Lock()
+	changed := false
+	now := time.Now()
+	switch {
+	case pressure:
+		m.calmSince = time.Time{}
+		if !m.overloaded {
+			m.overloaded, changed = true, true
+		}
+	case m.overloaded:
+		if m.calmSince.IsZero() {
+			m.calmSince = now
+		}
+		if now.Sub(m.calmSince) >= m.config.CoolDown {
+			m.overloaded, changed = false, true
+		}
+	}
+	overloaded := m.overloaded
+	cpu, memory := m.cpu, m.memory
+	listeners := make([]func(bool), len(m.listeners))
+	copy(listeners, m.listeners)
+	m.mutex.Unlock()
+
+	if !changed {
+		return
+	}
+	if overloaded {
+		log.Printf("Gateway overloaded (cpu %.0f%%, memory %.0f%%): throttling background work", cpu*100, memory*100)
+	} else {
+		log.Println("Gateway load back to normal: restoring background work")
+	}
+	for _, listener := range listeners {
+		listener(overloaded)
+	}
+}
@@ -0,0 +1,132 @@
+package plans
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync/atomic"
+
+	"gateway/internal/auth"
+	"gateway/internal/models"
+	"gateway/internal/quota"
+	"gateway/internal/ratelimit"
+	"gateway/internal/secrets"
+)
+
+// Plan holds the limiters of one usage plan. Limiter and Quotas are nil
+// when the plan is not limited in that respect.
+type Plan struct {
+	Name    string
+	Limiter *ratelimit.Limiter
+	Quotas  *quota.Quotas
+
+	requests int64
+}
+
+// Plans resolves clients to their usage plans.
+type Plans struct {
+	config models.PlansConfig
+	header string
+	tiers  map[string]*Plan
+	// keys maps the hex SHA-256 of each listed API key to its plan
+	keys map[string]*Plan
+}
+
+// New builds the plans of config. API key references are resolved once,
+// so a rotated key applies after a restart. Quotas of all plans share
+// store, keyed by client, so a client moving to another plan keeps its
+// usage for the period.
+func New(config models.PlansConfig, quotaConfig models.QuotaConfig, store quota.Store) (*Plans, error) {
+	p := &Plans{
+		config: config,
+		header: quotaConfig.Header,
+		tiers:  make(map[string]*Plan, len(config.Tiers)),
+		keys:   make(map[string]*Plan, len(config.APIKeys)),
+	}
+	for name, tier := range config.Tiers {
+		plan := &Plan{Name: name}
+		if limit := tier.RateLimit; limit != nil {
+			rate := float64(limit.Requests) / limit.Window.Seconds()
+			plan.Limiter = ratelimit.NewLimiter(rate, limit.Burst)
+		}
+		if tier.Daily > 0 || tier.Monthly > 0 {
+			plan.Quotas = quota.New(models.QuotaConfig{
+				Header:  quotaConfig.Header,
+				Daily:   tier.Daily,
+				Monthly: tier.Monthly,
+			}, store)
+		}
+		p.tiers[name] = plan
+	}
+	for _, key := range config.APIKeys {
+		plan, exists := p.tiers[key.Plan]
+		if !exists {
+			return nil, fmt.Errorf("api key %s references non-existent plan: %s", key.Name, key.Plan)
+		}
+		value, err := secrets.Resolve(key.KeyRef)
+		if err != nil {
+			return nil, fmt.Errorf("api key %s: %w", key.Name, err)
+		}
+		p.keys[hash(value)] = plan
+	}
+	return p, nil
+}
+
+// Header is the request header carrying API keys.
+func (p *Plans) Header() string {
+	return p.header
+}
+
+// Resolve returns the plan of a client and the key its usage is counted
+// under. A listed API key decides the plan and is counted on its own; an
+// authenticated user's plan claim comes next, and everyone else is on the
+// default plan. Keys that are not listed count as their user or clientIP,
+// so made-up keys do not get fresh limits. It returns nil when none of
+// these names a plan.
+func (p *Plans) Resolve(apiKey string, identity *auth.Identity, clientIP string) (*Plan, string) {
+	if apiKey != "" {
+		keyHash := hash(apiKey)
+		if plan, exists := p.keys[keyHash]; exists {
+			return p.count(plan), "key:" + keyHash
+		}
+	}
+
+	subject := "ip:" + clientIP
+	if identity != nil {
+		subject = "user:" + identity.UserID
+		if plan, exists := p.tiers[identity.Claim(p.config.Claim)]; exists {
+			return p.count(plan), subject
+		}
+	}
+	if plan, exists := p.tiers[p.config.Default]; exists {
+		return p.count(plan), subject
+	}
+	return nil, ""
+}
+
+func (p *Plans) count(plan *Plan) *Plan {
+	atomic.AddInt64(&plan.requests, 1)
+	return plan
+}
+
+func hash(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])
+}
+
+func (p *Plans) Stats() map[string]interface{} {
+	stats := make(map[string]interface{}, len(p.tiers))
+	for name, plan := range p.tiers {
+		planStats := map[string]interface{}{
+			"requests": atomic.LoadInt64(&plan.requests),
+		}
+		if plan.Limiter != nil {
+			planStats["rate_limit"] = plan.Limiter.Stats()
+		}
+		if plan.Quotas != nil {
+			planStats["quota"] = plan.Quotas.Stats()
+		}
+		stats[name] = planStats
+	}
+	return stats
+}
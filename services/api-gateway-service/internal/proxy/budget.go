@@ -0,0 +1,79 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gateway/internal/i18n"
+	"gateway/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// MaxWaitHeader carries how long a client is willing to wait, in
+	// milliseconds or as a duration such as "1.5s"
+	MaxWaitHeader = "X-Max-Wait"
+	// TimeoutReasonHeader tells which deadline a 504 ran into
+	TimeoutReasonHeader = "X-Timeout-Reason"
+
+	TimeoutReasonClientBudget = "client_budget"
+	TimeoutReasonService      = "service_timeout"
+	TimeoutReasonRoute        = "route_timeout"
+)
+
+const budgetKey = "proxy_client_budget"
+
+// RouteDeadlineKey holds the context.Context carrying a request's route
+// timeout.
+const RouteDeadlineKey = "proxy_route_deadline"
+
+// RouteTimedOut reports whether the request ran out of its route's
+// timeout.
+func RouteTimedOut(c *gin.Context) bool {
+	value, exists := c.Get(RouteDeadlineKey)
+	return exists && errors.Is(value.(context.Context).Err(), context.DeadlineExceeded)
+}
+
+// RespondRouteTimeout answers a request that ran out of its route's
+// timeout with a 504 that says so, telling it apart from a 504 passed on
+// from the service.
+func RespondRouteTimeout(c *gin.Context, route *models.RouteConfig) {
+	c.Header(TimeoutReasonHeader, TimeoutReasonRoute)
+	SetTermination(c, TerminationRouteTimeout)
+	fields := gin.H{
+		"reason":  TimeoutReasonRoute,
+		"timeout": route.Timeout.String(),
+	}
+	i18n.ErrorWith(c, http.StatusGatewayTimeout, "Gateway timeout", fields, i18n.RouteTimeout, route.Path, route.Timeout)
+}
+
+// clientBudget returns the wait the client asked for, capped by the route's
+// max_wait. Routes without max_wait and unusable values yield zero.
+func clientBudget(r *http.Request, route *models.RouteConfig) time.Duration {
+	value := strings.TrimSpace(r.Header.Get(MaxWaitHeader))
+	if route.MaxWait <= 0 || value == "" {
+		return 0
+	}
+
+	var budget time.Duration
+	if millis, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if millis > int64(route.MaxWait/time.Millisecond) {
+			return route.MaxWait
+		}
+		budget = time.Duration(millis) * time.Millisecond
+	} else if parsed, err := time.ParseDuration(value); err == nil {
+		budget = parsed
+	}
+	if budget <= 0 {
+		return 0
+	}
+	if budget > route.MaxWait {
+		return route.MaxWait
+	}
+	return budget
+}
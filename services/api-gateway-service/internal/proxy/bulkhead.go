@@ -0,0 +1,99 @@
+package proxy
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"gateway/internal/models"
+)
+
+// Bulkhead caps the requests in flight to each service at the service's
+// max_in_flight, so a slow upstream cannot tie up every goroutine and
+// connection of the gateway. Requests beyond the cap wait up to the
+// service's queue_timeout for a slot.
+type Bulkhead struct {
+	mutex    sync.Mutex
+	services map[string]*compartment
+}
+
+type compartment struct {
+	slots    chan struct{}
+	queued   int64
+	rejected int64
+}
+
+func NewBulkhead() *Bulkhead {
+	return &Bulkhead{
+		services: make(map[string]*compartment),
+	}
+}
+
+// Acquire claims a slot for a request to service. It returns false when
+// none became free in time or ctx ended first; otherwise release must be
+// called once the request is done.
+func (b *Bulkhead) Acquire(ctx context.Context, service *models.ServiceConfig) (release func(), ok bool) {
+	if service.MaxInFlight <= 0 {
+		return func() {}, true
+	}
+	slots := b.compartment(service)
+
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, true
+	default:
+	}
+
+	if service.QueueTimeout > 0 {
+		b.count(service.Name, func(c *compartment) { c.queued++ })
+		timer := time.NewTimer(service.QueueTimeout)
+		defer timer.Stop()
+		select {
+		case slots <- struct{}{}:
+			return func() { <-slots }, true
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+	}
+	b.count(service.Name, func(c *compartment) { c.rejected++ })
+	return nil, false
+}
+
+// compartment returns the slots of service, replacing them when the cap
+// has changed. Requests holding slots of replaced ones release them there.
+func (b *Bulkhead) compartment(service *models.ServiceConfig) chan struct{} {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	c, exists := b.services[service.Name]
+	if !exists {
+		c = &compartment{}
+		b.services[service.Name] = c
+	}
+	if cap(c.slots) != service.MaxInFlight {
+		c.slots = make(chan struct{}, service.MaxInFlight)
+	}
+	return c.slots
+}
+
+func (b *Bulkhead) count(serviceName string, update func(c *compartment)) {
+	b.mutex.Lock()
+	update(b.services[serviceName])
+	b.mutex.Unlock()
+}
+
+func (b *Bulkhead) Stats() map[string]interface{} {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	stats := make(map[string]interface{}, len(b.services))
+	for name, c := range b.services {
+		stats[name] = map[string]interface{}{
+			"max_in_flight": cap(c.slots),
+			"in_flight":     len(c.slots),
+			"queued":        c.queued,
+			"rejected":      c.rejected,
+		}
+	}
+	return stats
+}
@@ -0,0 +1,282 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"gateway/internal/dialer"
+	"gateway/internal/i18n"
+	"gateway/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxLegBodySize bounds how much of a leg response is buffered for merging.
+const maxLegBodySize = 8 << 20
+
+var errLegUnavailable = errors.New("service unavailable")
+
+// hopHeaders are not forwarded to composite legs. Accept-Encoding is left
+// to the transport so leg bodies arrive decoded and can be merged.
+var hopHeaders = []string{
+	"Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization", "Proxy-Connection",
+	"Te", "Trailer", "Transfer-Encoding", "Upgrade", "Content-Length", "Content-Type", "Accept-Encoding",
+}
+
+// legResult is the outcome of one composite leg. Legs that never ran,
+// because an earlier stage failed or used up the budget, are not fired.
+type legResult struct {
+	leg      models.CompositeLeg
+	status   int
+	body     []byte
+	header   http.Header
+	err      error
+	timeout  time.Duration
+	duration time.Duration
+	fired    bool
+}
+
+func (r legResult) ok() bool {
+	return r.fired && r.err == nil && r.status >= 200 && r.status < 300
+}
+
+// handleComposite fires the legs of a composite route and merges their
+// responses.
+func (p *Proxy) handleComposite(c *gin.Context, route *models.RouteConfig) {
+	results := p.aggregate(c.Request, route)
+	if failure := compositeFailure(route.Composite, results); failure != nil {
+		p.compositeError(c, *failure)
+		return
+	}
+
+	ordered := declarationOrder(route.Composite.Legs, results)
+	data := mergeLegs(route.Composite.Merge, ordered)
+	if !route.Composite.PartialResults {
+		c.Data(http.StatusOK, "application/json; charset=utf-8", data)
+		return
+	}
+
+	partial := isPartial(route.Composite.Merge, ordered)
+	if partial {
+		c.Header(PartialResponseHeader, "true")
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", envelope(data, partial, ordered))
+}
+
+// aggregate fires the legs stage by stage, giving each stage its share of
+// the route budget so one slow leg cannot starve the stages after it.
+// Results are in execution order.
+func (p *Proxy) aggregate(r *http.Request, route *models.RouteConfig) []legResult {
+	ctx := r.Context()
+	if route.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, route.Timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	stages := route.Composite.Stages()
+	results := make([]legResult, 0, len(route.Composite.Legs))
+	done := false
+
+	for i, stage := range stages {
+		if done {
+			for _, leg := range stage.Legs {
+				results = append(results, legResult{leg: leg})
+			}
+			continue
+		}
+
+		budget := stageBudget(route.Composite.Split, route.Timeout, route.Timeout-time.Since(start), stages, i)
+		if route.Timeout > 0 && budget <= 0 {
+			// Earlier stages used up the budget; firing now would only
+			// fail at the upstream's expense
+			for _, leg := range stage.Legs {
+				results = append(results, legResult{leg: leg, err: context.DeadlineExceeded, timeout: route.Timeout})
+			}
+			continue
+		}
+
+		stageResults := make([]legResult, len(stage.Legs))
+		var wg sync.WaitGroup
+		for j, leg := range stage.Legs {
+			wg.Add(1)
+			go func(j int, leg models.CompositeLeg) {
+				defer wg.Done()
+				stageResults[j] = p.callLeg(ctx, r, leg, budget)
+			}(j, leg)
+		}
+		wg.Wait()
+
+		results = append(results, stageResults...)
+		done = compositeDone(route.Composite, results)
+	}
+	return results
+}
+
+// compositeDone reports whether later stages can be skipped: once a
+// fallback chain has an answer, or once the response has failed anyway.
+func compositeDone(config *models.CompositeConfig, results []legResult) bool {
+	for _, result := range results {
+		switch {
+		case config.Merge == models.MergeFirstSuccess:
+			if result.ok() {
+				return true
+			}
+		case !result.ok() && (result.leg.Required || !config.PartialResults):
+			return true
+		}
+	}
+	return false
+}
+
+// stageBudget returns how long stage i may run, or 0 when the route has no
+// budget. remaining is what is left of the budget when the stage starts.
+func stageBudget(split models.BudgetSplit, total, remaining time.Duration, stages []models.CompositeStage, i int) time.Duration {
+	if total <= 0 {
+		return 0
+	}
+
+	var share time.Duration
+	switch split {
+	case models.SplitEqual:
+		share = total / time.Duration(len(stages))
+	case models.SplitWeighted:
+		share = weightedShare(total, stages[i].Weight, stages)
+	default:
+		share = weightedShare(remaining, stages[i].Weight, stages[i:])
+	}
+
+	if share > remaining {
+		share = remaining
+	}
+	if share < 0 {
+		share = 0
+	}
+	return share
+}
+
+func weightedShare(budget time.Duration, weight float64, stages []models.CompositeStage) time.Duration {
+	total := 0.0
+	for _, stage := range stages {
+		total += stage.Weight
+	}
+	return time.Duration(float64(budget) * weight / total)
+}
+
+func (p *Proxy) callLeg(ctx context.Context, r *http.Request, leg models.CompositeLeg, budget time.Duration) legResult {
+	result := legResult{leg: leg, fired: true}
+
+	service, exists := p.registry.GetService(leg.ServiceName)
+	if !exists || !service.Enabled {
+		result.err = errLegUnavailable
+		return result
+	}
+	done, admitted := p.registry.Admit(service.Name)
+	if !admitted {
+		result.err = errLegUnavailable
+		return result
+	}
+	defer done()
+	release, ok := p.bulkhead.Acquire(ctx, service)
+	if !ok {
+		result.err = errLegUnavailable
+		return result
+	}
+	defer release()
+
+	if service.IPPreference != "" {
+		ctx = dialer.WithPreference(ctx, service.IPPreference)
+	}
+
+	result.timeout = shortestTimeout(budget, leg.Timeout, service.Timeout)
+	if result.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, result.timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, service.URL+leg.Path, nil)
+	if err != nil {
+		result.err = err
+		return result
+	}
+	req.Header = r.Header.Clone()
+	for _, name := range hopHeaders {
+		req.Header.Del(name)
+	}
+	if clientIP, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		if prior := r.Header.Get("X-Forwarded-For"); prior != "" {
+			clientIP = prior + ", " + clientIP
+		}
+		req.Header.Set("X-Forwarded-For", clientIP)
+	}
+	for key, value := range service.Headers {
+		req.Header.Set(key, value)
+	}
+
+	start := time.Now()
+	resp, err := p.transport.RoundTrip(req)
+	result.duration = time.Since(start)
+	if err != nil {
+		result.err = err
+		if !errors.Is(err, context.Canceled) {
+			p.registry.RecordProxyResult(service.Name, true)
+		}
+		return result
+	}
+	defer resp.Body.Close()
+
+	p.registry.RecordProxyResult(service.Name, resp.StatusCode >= http.StatusInternalServerError)
+	p.registry.RecordLatency(service.Name, result.duration)
+
+	result.status = resp.StatusCode
+	result.header = resp.Header
+	result.body, result.err = io.ReadAll(io.LimitReader(resp.Body, maxLegBodySize))
+	return result
+}
+
+func (p *Proxy) compositeError(c *gin.Context, result legResult) {
+	service := result.leg.ServiceName
+	switch {
+	case errors.Is(result.err, context.Canceled):
+		SetTermination(c, TerminationClientCancel)
+		c.Status(499)
+	case errors.Is(result.err, context.DeadlineExceeded):
+		SetTermination(c, TerminationUpstreamTimeout)
+		i18n.Error(c, http.StatusGatewayTimeout, "Gateway timeout", i18n.ServiceTimeout, service, result.timeout)
+	case !result.fired:
+		SetTermination(c, TerminationUpstreamUnreachable)
+		i18n.Error(c, http.StatusBadGateway, "Bad gateway", i18n.ServiceUnavailable, service)
+	case result.err != nil || result.status >= http.StatusInternalServerError:
+		if result.err != nil {
+			log.Printf("Composite leg %s (%s) failed: %v", result.leg.Name, service, result.err)
+			SetTermination(c, TerminationUpstreamUnreachable)
+		} else {
+			SetTermination(c, TerminationUpstream5xx)
+		}
+		i18n.Error(c, http.StatusBadGateway, "Bad gateway", i18n.ServiceUnavailable, service)
+	default:
+		// Client errors such as a rejected token are the caller's to see
+		SetTermination(c, TerminationUpstream4xx)
+		c.Data(result.status, result.header.Get("Content-Type"), result.body)
+	}
+}
+
+// shortestTimeout returns the smallest positive timeout, or 0 if none is
+// set.
+func shortestTimeout(timeouts ...time.Duration) time.Duration {
+	var shortest time.Duration
+	for _, timeout := range timeouts {
+		if timeout > 0 && (shortest == 0 || timeout < shortest) {
+			shortest = timeout
+		}
+	}
+	return shortest
+}
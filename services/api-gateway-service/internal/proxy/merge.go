@@ -0,0 +1,183 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+
+	"gateway/internal/models"
+)
+
+// PartialResponseHeader marks composite responses missing some legs
+const PartialResponseHeader = "X-Partial-Response"
+
+// Leg states reported in partial-result envelopes
+const (
+	legOK      = "ok"
+	legError   = "error"
+	legTimeout = "timeout"
+	legSkipped = "skipped"
+)
+
+type legStatus struct {
+	Status     string `json:"status"`
+	Code       int    `json:"code,omitempty"`
+	DurationMS int64  `json:"duration_ms,omitempty"`
+}
+
+// compositeFailure returns the leg that fails the whole response, if any.
+func compositeFailure(config *models.CompositeConfig, results []legResult) *legResult {
+	if config.Merge == models.MergeFirstSuccess {
+		for _, result := range results {
+			if result.ok() {
+				return nil
+			}
+		}
+		for i := range results {
+			if results[i].fired || results[i].err != nil {
+				return &results[i]
+			}
+		}
+		return &results[0]
+	}
+
+	for i, result := range results {
+		if !result.ok() && (result.leg.Required || !config.PartialResults) {
+			return &results[i]
+		}
+	}
+	return nil
+}
+
+// declarationOrder returns the results in the order the legs are
+// configured, whatever order they ran in.
+func declarationOrder(legs []models.CompositeLeg, results []legResult) []legResult {
+	byName := make(map[string]legResult, len(results))
+	for _, result := range results {
+		byName[result.leg.Name] = result
+	}
+	ordered := make([]legResult, 0, len(legs))
+	for _, leg := range legs {
+		ordered = append(ordered, byName[leg.Name])
+	}
+	return ordered
+}
+
+func mergeLegs(strategy models.MergeStrategy, results []legResult) json.RawMessage {
+	switch strategy {
+	case models.MergeConcat:
+		items := make([]json.RawMessage, 0)
+		for _, result := range results {
+			if !result.ok() {
+				continue
+			}
+			var array []json.RawMessage
+			if err := json.Unmarshal(result.body, &array); err == nil {
+				items = append(items, array...)
+			} else {
+				items = append(items, legJSON(result.body))
+			}
+		}
+		merged, _ := json.Marshal(items)
+		return merged
+	case models.MergeFirstSuccess:
+		for _, result := range results {
+			if result.ok() {
+				return legJSON(result.body)
+			}
+		}
+		return json.RawMessage("null")
+	default:
+		keys := make([]string, len(results))
+		values := make([]json.RawMessage, len(results))
+		for i, result := range results {
+			keys[i] = result.leg.Name
+			values[i] = json.RawMessage("null")
+			if result.ok() {
+				values[i] = legJSON(result.body)
+			}
+		}
+		return orderedObject(keys, values)
+	}
+}
+
+// isPartial reports whether legs the client expects are missing. Fallbacks
+// skipped after a first success are not missed.
+func isPartial(strategy models.MergeStrategy, results []legResult) bool {
+	for _, result := range results {
+		if strategy == models.MergeFirstSuccess {
+			if result.ok() {
+				return false
+			}
+		} else if !result.ok() {
+			return true
+		}
+	}
+	return strategy == models.MergeFirstSuccess
+}
+
+// envelope wraps merged data with the status of every leg so clients can
+// render what arrived and degrade the rest.
+func envelope(data json.RawMessage, partial bool, results []legResult) json.RawMessage {
+	keys := make([]string, len(results))
+	values := make([]json.RawMessage, len(results))
+	for i, result := range results {
+		status := legStatus{Status: legState(result), Code: result.status}
+		if result.fired {
+			status.DurationMS = result.duration.Milliseconds()
+		}
+		keys[i] = result.leg.Name
+		values[i], _ = json.Marshal(status)
+	}
+
+	partialJSON, _ := json.Marshal(partial)
+	return orderedObject(
+		[]string{"data", "partial", "legs"},
+		[]json.RawMessage{data, partialJSON, orderedObject(keys, values)},
+	)
+}
+
+func legState(result legResult) string {
+	switch {
+	case result.ok():
+		return legOK
+	case errors.Is(result.err, context.DeadlineExceeded):
+		return legTimeout
+	case !result.fired:
+		return legSkipped
+	default:
+		return legError
+	}
+}
+
+// orderedObject encodes a JSON object keeping the given key order, which
+// encoding/json does not do for maps.
+func orderedObject(keys []string, values []json.RawMessage) json.RawMessage {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(key)
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(values[i])
+	}
+	buf.WriteByte('}')
+	return buf.Bytes()
+}
+
+// legJSON embeds a leg body in the merged response, as a string when it is
+// not JSON itself.
+func legJSON(body []byte) json.RawMessage {
+	if len(body) == 0 {
+		return json.RawMessage("null")
+	}
+	if json.Valid(body) {
+		return body
+	}
+	encoded, _ := json.Marshal(string(body))
+	return encoded
+}
@@ -0,0 +1,192 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gateway/internal/models"
+)
+
+const problemContentType = "application/problem+json"
+
+// maxValidationBody bounds how much of an upstream 422 body is buffered for
+// normalization; larger bodies are passed through untouched.
+const maxValidationBody = 1 << 20
+
+// Problem is the gateway's RFC 7807 error envelope for validation errors.
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Service  string       `json:"service,omitempty"`
+	Errors   []FieldError `json:"errors"`
+}
+
+type FieldError struct {
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+	Code    string `json:"code,omitempty"`
+}
+
+// normalizeValidationError rewrites an upstream 422 response into a
+// Problem, keeping its field errors. Responses that are already
+// problem+json, compressed or too large are left alone.
+func normalizeValidationError(resp *http.Response, service string) error {
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), problemContentType) {
+		return nil
+	}
+	if encoding := resp.Header.Get("Content-Encoding"); encoding != "" && encoding != "identity" {
+		return nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxValidationBody+1))
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	if len(body) > maxValidationBody {
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		return nil
+	}
+
+	problem := parseValidationBody(body)
+	problem.Service = service
+	problem.Instance = resp.Request.URL.Path
+
+	normalized, err := json.Marshal(problem)
+	if err != nil {
+		return err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(normalized))
+	resp.ContentLength = int64(len(normalized))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(normalized)))
+	resp.Header.Set("Content-Type", problemContentType)
+	return nil
+}
+
+// parseValidationBody understands the validation error shapes used by our
+// services: FastAPI's {"detail": [{"loc", "msg", "type"}]}, Express-style
+// {"message", "errors": [{"field", "message"}]} and field maps such as
+// {"errors": {"email": ["is invalid"]}}.
+func parseValidationBody(body []byte) Problem {
+	problem := Problem{
+		Type:   "about:blank",
+		Title:  "Validation failed",
+		Status: http.StatusUnprocessableEntity,
+		Errors: []FieldError{},
+	}
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		if text := strings.TrimSpace(string(body)); text != "" && len(text) <= 512 {
+			problem.Detail = text
+		}
+		return problem
+	}
+
+	if message, ok := payload["message"].(string); ok {
+		problem.Detail = message
+	} else if detail, ok := payload["detail"].(string); ok {
+		problem.Detail = detail
+	}
+
+	for _, key := range []string{"detail", "errors", "details"} {
+		switch value := payload[key].(type) {
+		case []interface{}:
+			problem.Errors = append(problem.Errors, fieldErrorList(value)...)
+		case map[string]interface{}:
+			problem.Errors = append(problem.Errors, fieldErrorMap(value)...)
+		}
+	}
+
+	if problem.Detail == "" {
+		problem.Detail = "Request validation failed"
+	}
+	return problem
+}
+
+func fieldErrorList(items []interface{}) []FieldError {
+	var fieldErrors []FieldError
+	for _, item := range items {
+		switch entry := item.(type) {
+		case string:
+			fieldErrors = append(fieldErrors, FieldError{Message: entry})
+		case map[string]interface{}:
+			fieldError := FieldError{
+				Field:   firstString(entry, "field", "path", "param", "property"),
+				Message: firstString(entry, "message", "msg"),
+				Code:    firstString(entry, "code", "type"),
+			}
+			if loc, ok := entry["loc"].([]interface{}); ok {
+				fieldError.Field = locationField(loc)
+			}
+			fieldErrors = append(fieldErrors, fieldError)
+		}
+	}
+	return fieldErrors
+}
+
+func fieldErrorMap(fields map[string]interface{}) []FieldError {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var fieldErrors []FieldError
+	for _, name := range names {
+		switch messages := fields[name].(type) {
+		case string:
+			fieldErrors = append(fieldErrors, FieldError{Field: name, Message: messages})
+		case []interface{}:
+			for _, message := range messages {
+				if text, ok := message.(string); ok {
+					fieldErrors = append(fieldErrors, FieldError{Field: name, Message: text})
+				}
+			}
+		}
+	}
+	return fieldErrors
+}
+
+// locationField turns a FastAPI location such as ["body", "items", 0,
+// "sku"] into "items.0.sku".
+func locationField(loc []interface{}) string {
+	parts := make([]string, 0, len(loc))
+	for i, part := range loc {
+		switch value := part.(type) {
+		case string:
+			if i == 0 && (value == "body" || value == "query" || value == "path" || value == "header" || value == "cookie") {
+				continue
+			}
+			parts = append(parts, value)
+		case float64:
+			parts = append(parts, strconv.Itoa(int(value)))
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+func firstString(entry map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		if value, ok := entry[key].(string); ok && value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func normalizesValidationErrors(global models.ValidationErrorMode, service *models.ServiceConfig) bool {
+	mode := global
+	if service.ValidationErrors != "" {
+		mode = service.ValidationErrors
+	}
+	return mode == models.ValidationErrorsNormalize
+}
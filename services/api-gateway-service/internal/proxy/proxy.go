@@ -0,0 +1,433 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gateway/internal/anomaly"
+	"gateway/internal/dialer"
+	"gateway/internal/i18n"
+	"gateway/internal/models"
+	"gateway/internal/ratelimit"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// SLAHeader marks responses slower than their service's latency_sla
+	SLAHeader     = "X-Upstream-SLA"
+	LatencyHeader = "X-Upstream-Latency"
+
+	// ServiceKey holds the *models.ServiceConfig a request was proxied to
+	ServiceKey = "proxy_service"
+	// RerouteKey holds a *models.ServiceConfig to proxy a request to
+	// instead of the route's, such as a degraded-mode fallback
+	RerouteKey = "proxy_reroute"
+)
+
+// Proxy forwards requests to the service owning the matching route and
+// reports every outcome back to the registry as a passive health signal.
+type Proxy struct {
+	registry       *registry.ServiceRegistry
+	transport      *http.Transport
+	validationMode models.ValidationErrorMode
+	websockets     *WebSocketTracker
+	bulkhead       *Bulkhead
+	routeLimits    *ratelimit.RouteLimits
+	anomalies      *anomaly.Detector
+	terminations   *Terminations
+	retries        *Retries
+}
+
+func New(serviceRegistry *registry.ServiceRegistry) *Proxy {
+	return &Proxy{
+		registry:     serviceRegistry,
+		websockets:   NewWebSocketTracker(),
+		bulkhead:     NewBulkhead(),
+		routeLimits:  ratelimit.NewRouteLimits(),
+		terminations: NewTerminations(),
+		retries:      NewRetries(),
+		transport: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   10 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			MaxIdleConns:          200,
+			MaxIdleConnsPerHost:   50,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: time.Second,
+		},
+	}
+}
+
+// SetValidationErrorMode sets how upstream 422 responses are handled for
+// services that do not choose themselves.
+func (p *Proxy) SetValidationErrorMode(mode models.ValidationErrorMode) {
+	p.validationMode = mode
+}
+
+// SetAnomalyDetector samples successful responses into d. A nil detector
+// turns sampling off.
+func (p *Proxy) SetAnomalyDetector(d *anomaly.Detector) {
+	p.anomalies = d
+}
+
+// Transport exposes the shared upstream transport.
+func (p *Proxy) Transport() *http.Transport {
+	return p.transport
+}
+
+// WebSockets exposes the tracker of proxied WebSocket connections.
+func (p *Proxy) WebSockets() *WebSocketTracker {
+	return p.websockets
+}
+
+// Bulkhead exposes the per-service caps on requests in flight.
+func (p *Proxy) Bulkhead() *Bulkhead {
+	return p.bulkhead
+}
+
+// RouteLimits exposes the routes' own rate limits.
+func (p *Proxy) RouteLimits() *ratelimit.RouteLimits {
+	return p.routeLimits
+}
+
+// Terminations exposes the counts of requests by why they failed.
+func (p *Proxy) Terminations() *Terminations {
+	return p.terminations
+}
+
+// Retries exposes the counts of retried requests.
+func (p *Proxy) Retries() *Retries {
+	return p.retries
+}
+
+// Handle is the gin handler for proxied routes.
+func (p *Proxy) Handle(c *gin.Context) {
+	method := c.Request.Method
+	path := c.Request.URL.Path
+
+	route, service := p.registry.FindRoute(method, path)
+	if route != nil && !p.allowRoute(c, route) {
+		return
+	}
+	if route != nil && route.IsComposite() {
+		p.handleComposite(c, route)
+		return
+	}
+	if route == nil || service == nil {
+		SetTermination(c, TerminationRouteNotFound)
+		i18n.Error(c, http.StatusNotFound, "Route not found", i18n.RouteNotFound, method, path)
+		return
+	}
+
+	if reroute, ok := c.Get(RerouteKey); ok {
+		service = reroute.(*models.ServiceConfig)
+	} else if canary := p.registry.PickCanary(route); canary != nil {
+		service = canary
+	}
+
+	target, err := url.Parse(service.URL)
+	if err != nil {
+		log.Printf("Invalid URL for service %s: %v", service.Name, err)
+		i18n.Error(c, http.StatusBadGateway, "Bad gateway", i18n.ServiceMisconfigured, service.Name)
+		return
+	}
+
+	c.Set(ServiceKey, service)
+	// A disabled or draining service takes no new requests, and those it
+	// takes are counted until they are done
+	done, admitted := p.registry.Admit(service.Name)
+	if !admitted {
+		SetTermination(c, TerminationServiceDisabled)
+		i18n.Error(c, http.StatusServiceUnavailable, "Service unavailable", i18n.ServiceUnavailable, service.Name)
+		return
+	}
+	defer done()
+
+	if service.IPPreference != "" {
+		c.Request = c.Request.WithContext(dialer.WithPreference(c.Request.Context(), service.IPPreference))
+	}
+
+	start := time.Now()
+	reverseProxy := p.reverseProxy(c, route, service, target, start)
+	if IsWebSocket(c.Request) {
+		// The service timeout would cut sockets off mid-conversation; the
+		// route's websocket idle_timeout bounds them instead
+		p.serveWebSocket(c, route, reverseProxy)
+		return
+	}
+
+	// A full service answers right away instead of piling up requests;
+	// that says nothing about its health
+	release, ok := p.bulkhead.Acquire(c.Request.Context(), service)
+	if !ok && RouteTimedOut(c) {
+		// It was the route's timeout that ran out while waiting
+		RespondRouteTimeout(c, route)
+		return
+	}
+	if !ok {
+		c.Header("Retry-After", "1")
+		SetTermination(c, TerminationServiceAtCapacity)
+		i18n.Error(c, http.StatusServiceUnavailable, "Service at capacity", i18n.ServiceAtCapacity, service.Name)
+		return
+	}
+	defer release()
+
+	// A client budget may only shorten the service's timeout
+	timeout := service.Timeout
+	if budget := clientBudget(c.Request, route); budget > 0 && (timeout <= 0 || budget < timeout) {
+		timeout = budget
+		c.Set(budgetKey, budget)
+	}
+	if timeout > 0 {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+	}
+	reverseProxy.ServeHTTP(c.Writer, c.Request)
+}
+
+func (p *Proxy) reverseProxy(c *gin.Context, route *models.RouteConfig, service *models.ServiceConfig, target *url.URL, start time.Time) *httputil.ReverseProxy {
+	return &httputil.ReverseProxy{
+		Transport: p.transportFor(c, service),
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.Out.URL.Path = route.ExtractProxyPath(pr.In.URL.Path)
+			pr.Out.URL.RawPath = ""
+			pr.SetURL(target)
+			pr.SetXForwarded()
+
+			for key, value := range service.Headers {
+				pr.Out.Header.Set(key, value)
+			}
+			for key, value := range route.Headers {
+				pr.Out.Header.Set(key, value)
+			}
+		},
+		ModifyResponse: func(resp *http.Response) error {
+			// Latency up to the response headers, which is what callers wait on
+			latency := time.Since(start)
+			p.recordResult(route, service, latency, resp.StatusCode >= http.StatusInternalServerError)
+			if resp.StatusCode >= http.StatusBadRequest {
+				SetTermination(c, upstreamTermination(resp.StatusCode))
+			}
+			if p.registry.RecordLatency(service.Name, latency) {
+				resp.Header.Set(SLAHeader, "exceeded")
+				resp.Header.Set(LatencyHeader, strconv.FormatInt(latency.Milliseconds(), 10))
+			}
+
+			// The gateway already answers with the request's correlation
+			// ID; an upstream echoing it would repeat the header
+			if c.Writer.Header().Get(models.CorrelationIDHeader) != "" {
+				resp.Header.Del(models.CorrelationIDHeader)
+			}
+			if route.CookiePolicy != nil {
+				route.CookiePolicy.RewriteHeaders(resp.Header)
+			}
+
+			if p.anomalies != nil && resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices &&
+				resp.StatusCode != http.StatusNoContent && resp.Request.Method != http.MethodHead && p.anomalies.Sample() {
+				p.anomalies.Watch(resp, route.Path, service.Name)
+			}
+
+			if resp.StatusCode == http.StatusUnprocessableEntity && normalizesValidationErrors(p.validationMode, service) {
+				return normalizeValidationError(resp, service.Name)
+			}
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			p.handleError(c, route, service, start, err)
+		},
+	}
+}
+
+// serveWebSocket proxies an upgrade within the caps of the route's
+// websocket policy.
+func (p *Proxy) serveWebSocket(c *gin.Context, route *models.RouteConfig, reverseProxy *httputil.ReverseProxy) {
+	client := c.ClientIP()
+	if ok, perClient := p.websockets.Acquire(route.Path, client, route.WebSocket); !ok {
+		status, reason := http.StatusServiceUnavailable, TerminationServiceAtCapacity
+		if perClient {
+			status, reason = http.StatusTooManyRequests, TerminationRateLimited
+		}
+		SetTermination(c, reason)
+		i18n.Error(c, status, "Too many connections", i18n.WebSocketLimit)
+		return
+	}
+	defer p.websockets.Release(route.Path, client)
+
+	writer := &websocketWriter{ResponseWriter: c.Writer, policy: route.WebSocket, tracker: p.websockets}
+	reverseProxy.ServeHTTP(writer, c.Request)
+}
+
+// allowRoute counts the request against the route's rate_limit and turns
+// it away with a 429 when the limit, possibly tightened because the service
+// struggles, is used up.
+func (p *Proxy) allowRoute(c *gin.Context, route *models.RouteConfig) bool {
+	quota := p.routeLimits.Take(route)
+	if quota.Allowed {
+		return true
+	}
+	seconds := int(math.Ceil(quota.RetryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	SetTermination(c, TerminationRateLimited)
+	i18n.ErrorWith(c, http.StatusTooManyRequests, "Rate limit exceeded", gin.H{"reason": "route_limit"},
+		i18n.RateLimited, seconds)
+	return false
+}
+
+// recordResult reports the outcome of a proxied request to the service's
+// passive health, to the route's adaptive limit and, on canary routes, to
+// the canary comparison.
+func (p *Proxy) recordResult(route *models.RouteConfig, service *models.ServiceConfig, latency time.Duration, failed bool) {
+	p.registry.RecordProxyResult(service.Name, failed)
+	p.registry.RecordCanaryResult(route, service.Name, failed)
+	p.routeLimits.Record(route, latency, failed)
+}
+
+func (p *Proxy) handleError(c *gin.Context, route *models.RouteConfig, service *models.ServiceConfig, start time.Time, err error) {
+	// A client that went away says nothing about the upstream
+	if errors.Is(err, context.Canceled) {
+		SetTermination(c, TerminationClientCancel)
+		c.Status(499)
+		return
+	}
+
+	// Nor does running out of the route's timeout, which covers more than
+	// the upstream's part
+	if RouteTimedOut(c) {
+		RespondRouteTimeout(c, route)
+		return
+	}
+
+	// Running out of a budget the client chose says nothing about the
+	// upstream either
+	if budget, exists := c.Get(budgetKey); exists && errors.Is(err, context.DeadlineExceeded) {
+		c.Header(TimeoutReasonHeader, TimeoutReasonClientBudget)
+		SetTermination(c, TerminationGatewayTimeout)
+		i18n.ErrorWith(c, http.StatusGatewayTimeout, "Gateway timeout", gin.H{"reason": TimeoutReasonClientBudget},
+			i18n.BudgetExceeded, service.Name, budget)
+		return
+	}
+
+	p.recordResult(route, service, time.Since(start), true)
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		c.Header(TimeoutReasonHeader, TimeoutReasonService)
+		SetTermination(c, TerminationUpstreamTimeout)
+		i18n.ErrorWith(c, http.StatusGatewayTimeout, "Gateway timeout", gin.H{"reason": TimeoutReasonService},
+			i18n.ServiceTimeout, service.Name, service.Timeout)
+		return
+	}
+
+	log.Printf("Proxy error for service %s: %v", service.Name, err)
+	SetTermination(c, TerminationUpstreamUnreachable)
+	i18n.Error(c, http.StatusBadGateway, "Bad gateway", i18n.ServiceUnavailable, service.Name)
+}
+
+// Prewarm opens prewarm_connections connections (including TLS handshakes)
+// to every service that asks for them and leaves them idle in the pool, so
+// the first requests after a deploy do not pay for connection setup. It
+// returns the number of connections established.
+func (p *Proxy) Prewarm(ctx context.Context) int {
+	var wg sync.WaitGroup
+	var warmed int64
+
+	for _, service := range p.registry.GetAllServices() {
+		count := service.PrewarmConnections
+		if count <= 0 || !service.Enabled {
+			continue
+		}
+		if count > p.transport.MaxIdleConnsPerHost {
+			count = p.transport.MaxIdleConnsPerHost
+		}
+
+		wg.Add(1)
+		go func(svc models.ServiceConfig, count int) {
+			defer wg.Done()
+			atomic.AddInt64(&warmed, int64(p.warm(ctx, svc, count)))
+		}(service, count)
+	}
+
+	wg.Wait()
+	return int(warmed)
+}
+
+// KeepWarm re-runs Prewarm well within the idle connection timeout so
+// pre-warmed connections are not reaped while traffic is quiet.
+func (p *Proxy) KeepWarm(ctx context.Context) {
+	ticker := time.NewTicker(p.transport.IdleConnTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.Prewarm(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// warm sends count concurrent probes to the service's health endpoint. All
+// responses are held open until every probe has completed, forcing one
+// connection per probe instead of reusing the first one to come back.
+func (p *Proxy) warm(ctx context.Context, service models.ServiceConfig, count int) int {
+	ctx, cancel := context.WithTimeout(ctx, service.Timeout)
+	defer cancel()
+	if service.IPPreference != "" {
+		ctx = dialer.WithPreference(ctx, service.IPPreference)
+	}
+
+	method := service.HealthMethod
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	responses := make(chan *http.Response, count)
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, err := http.NewRequestWithContext(ctx, method, service.URL+service.HealthPath, nil)
+			if err != nil {
+				return
+			}
+			resp, err := p.transport.RoundTrip(req)
+			if err != nil {
+				log.Printf("Pre-warming %s failed: %v", service.Name, err)
+				return
+			}
+			responses <- resp
+		}()
+	}
+	wg.Wait()
+	close(responses)
+
+	warmed := 0
+	for resp := range responses {
+		// Draining the body hands the connection back to the idle pool
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		warmed++
+	}
+	return warmed
+}
@@ -0,0 +1,164 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"gateway/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RetriesHeader tells the client how many times its request was retried
+// before the answer it got.
+const RetriesHeader = "X-Upstream-Retries"
+
+// RetryGateKey holds a func(service string) bool reporting whether a
+// request to service may still be retried. The circuit breaker sets it so
+// that retries stop once the service's circuit is no longer closed.
+const RetryGateKey = "proxy_retry_gate"
+
+// RetryCount is how a service's retry policy has fared.
+type RetryCount struct {
+	Service string `json:"service"`
+	// Retried are the retries sent
+	Retried int64 `json:"retried"`
+	// Recovered are the requests answered after at least one retry
+	Recovered int64 `json:"recovered"`
+	// Exhausted are the requests that failed on every attempt
+	Exhausted int64 `json:"exhausted"`
+	// Blocked are the retries not sent because the circuit was not closed
+	Blocked int64 `json:"blocked"`
+}
+
+// Retries counts the retries of requests to services with a retry policy.
+type Retries struct {
+	counts map[string]*RetryCount
+	mutex  sync.Mutex
+}
+
+func NewRetries() *Retries {
+	return &Retries{counts: make(map[string]*RetryCount)}
+}
+
+func (r *Retries) count(service string, update func(c *RetryCount)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	c, exists := r.counts[service]
+	if !exists {
+		c = &RetryCount{Service: service}
+		r.counts[service] = c
+	}
+	update(c)
+}
+
+// Counts returns the counts ordered by service.
+func (r *Retries) Counts() []RetryCount {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	counts := make([]RetryCount, 0, len(r.counts))
+	for _, c := range r.counts {
+		counts = append(counts, *c)
+	}
+	sort.Slice(counts, func(i, j int) bool { return counts[i].Service < counts[j].Service })
+	return counts
+}
+
+func (r *Retries) Stats() map[string]interface{} {
+	counts := r.Counts()
+	var retried int64
+	for _, c := range counts {
+		retried += c.Retried
+	}
+	return map[string]interface{}{
+		"retried":  retried,
+		"services": counts,
+	}
+}
+
+// transportFor returns the transport of a request to service: the shared
+// one, wrapped in the service's retry policy if it has one.
+func (p *Proxy) transportFor(c *gin.Context, service *models.ServiceConfig) http.RoundTripper {
+	if service.Retry == nil || service.Retry.MaxRetries <= 0 {
+		return p.transport
+	}
+	t := &retryTransport{
+		next:    p.transport,
+		policy:  service.Retry.Defaults(),
+		service: service.Name,
+		retries: p.retries,
+	}
+	if gate, ok := c.Get(RetryGateKey); ok {
+		t.gate = gate.(func(string) bool)
+	}
+	return t
+}
+
+// retryTransport sends a request again while its attempts fail, it has
+// retries left and its gate lets it. Only the last attempt is answered
+// with, so passive health and the circuit breaker hear about the request
+// once, however many attempts it took.
+type retryTransport struct {
+	next    http.RoundTripper
+	policy  models.RetryPolicy
+	service string
+	gate    func(service string) bool
+	retries *Retries
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A body has been read by the first attempt and cannot be sent again
+	if !models.IsIdempotent(req.Method) || (req.Body != nil && req.Body != http.NoBody) {
+		return t.next.RoundTrip(req)
+	}
+
+	backoff := t.policy.Backoff
+	for attempt := 0; ; attempt++ {
+		resp, err := t.next.RoundTrip(req)
+		if attempt > 0 && resp != nil {
+			resp.Header.Set(RetriesHeader, strconv.Itoa(attempt))
+		}
+		if !t.failed(req, resp, err) {
+			if attempt > 0 && err == nil {
+				t.retries.count(t.service, func(c *RetryCount) { c.Recovered++ })
+			}
+			return resp, err
+		}
+		if attempt == t.policy.MaxRetries {
+			t.retries.count(t.service, func(c *RetryCount) { c.Exhausted++ })
+			return resp, err
+		}
+		if t.gate != nil && !t.gate(t.service) {
+			t.retries.count(t.service, func(c *RetryCount) { c.Blocked++ })
+			return resp, err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		}
+		backoff *= 2
+		t.retries.count(t.service, func(c *RetryCount) { c.Retried++ })
+	}
+}
+
+// failed reports whether an attempt is worth retrying. Attempts cut short
+// by the request's own deadline or cancellation are not.
+func (t *retryTransport) failed(req *http.Request, resp *http.Response, err error) bool {
+	if err != nil {
+		return req.Context().Err() == nil
+	}
+	return t.policy.Retries(resp.StatusCode)
+}
@@ -0,0 +1,167 @@
+package proxy
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+
+	"gateway/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TerminationKey holds why a request ended with a 4xx or 5xx status, when
+// the part of the gateway that ended it knows better than the status does.
+const TerminationKey = "proxy_termination"
+
+// Termination reasons. Requests without one set are put down to the
+// gateway by their status.
+const (
+	TerminationClientCancel        = "client_cancel"
+	TerminationGatewayTimeout      = "gateway_timeout"
+	TerminationRouteTimeout        = "route_timeout"
+	TerminationUpstreamTimeout     = "upstream_timeout"
+	TerminationBreakerOpen         = "breaker_open"
+	TerminationRateLimited         = "rate_limited"
+	TerminationQuotaExhausted      = "quota_exhausted"
+	TerminationServiceAtCapacity   = "service_at_capacity"
+	TerminationServiceDisabled     = "service_disabled"
+	TerminationMaintenance         = "maintenance"
+	TerminationIPDenied            = "ip_denied"
+	TerminationGeoDenied           = "geo_denied"
+	TerminationHeadersTooLarge     = "headers_too_large"
+	TerminationURLTooLong          = "url_too_long"
+	TerminationRouteNotFound       = "route_not_found"
+	TerminationUpstreamUnreachable = "upstream_unreachable"
+	TerminationUpstream4xx         = "upstream_4xx"
+	TerminationUpstream5xx         = "upstream_5xx"
+	TerminationUnauthorized        = "unauthorized"
+	TerminationRejected            = "rejected"
+	TerminationGatewayError        = "gateway_error"
+)
+
+// SetTermination records why the request is about to be ended.
+func SetTermination(c *gin.Context, reason string) {
+	c.Set(TerminationKey, reason)
+}
+
+// Termination returns why c ended with a 4xx or 5xx status, or "" if it
+// did not. It is meant to be called after c.Next, for instance by tracing
+// middleware that annotates its span with it.
+func Termination(c *gin.Context) string {
+	return TerminationOf(c.Keys, c.Writer.Status())
+}
+
+// TerminationOf is Termination for a request's keys and status, as
+// loggers are handed them.
+func TerminationOf(keys map[string]interface{}, status int) string {
+	if status < http.StatusBadRequest {
+		return ""
+	}
+	if reason, ok := keys[TerminationKey].(string); ok && reason != "" {
+		return reason
+	}
+	switch {
+	case status == 499:
+		return TerminationClientCancel
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return TerminationUnauthorized
+	case status == http.StatusTooManyRequests:
+		return TerminationRateLimited
+	case status < http.StatusInternalServerError:
+		return TerminationRejected
+	default:
+		return TerminationGatewayError
+	}
+}
+
+// upstreamTermination is the reason of a response passed on from a
+// service.
+func upstreamTermination(status int) string {
+	if status >= http.StatusInternalServerError {
+		return TerminationUpstream5xx
+	}
+	return TerminationUpstream4xx
+}
+
+// TerminationCount is how many requests to a service ended for a reason.
+// Service is empty for requests that matched no route.
+type TerminationCount struct {
+	Reason  string `json:"reason"`
+	Service string `json:"service,omitempty"`
+	Count   int64  `json:"count"`
+}
+
+type terminationKey struct {
+	reason  string
+	service string
+}
+
+// Terminations counts proxied requests by why they ended with a 4xx or 5xx
+// status.
+type Terminations struct {
+	counts map[terminationKey]int64
+	mutex  sync.Mutex
+}
+
+func NewTerminations() *Terminations {
+	return &Terminations{counts: make(map[terminationKey]int64)}
+}
+
+func (t *Terminations) Record(reason, service string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.counts[terminationKey{reason: reason, service: service}]++
+}
+
+// Counts returns the counts ordered by reason, then service.
+func (t *Terminations) Counts() []TerminationCount {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	counts := make([]TerminationCount, 0, len(t.counts))
+	for key, count := range t.counts {
+		counts = append(counts, TerminationCount{Reason: key.reason, Service: key.service, Count: count})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Reason != counts[j].Reason {
+			return counts[i].Reason < counts[j].Reason
+		}
+		return counts[i].Service < counts[j].Service
+	})
+	return counts
+}
+
+func (t *Terminations) Stats() map[string]interface{} {
+	counts := t.Counts()
+	byReason := make(map[string]int64)
+	var total int64
+	for _, count := range counts {
+		byReason[count.Reason] += count.Count
+		total += count.Count
+	}
+	return map[string]interface{}{
+		"total":     total,
+		"by_reason": byReason,
+		"counts":    counts,
+	}
+}
+
+// TrackTerminations counts the requests that the rest of the pipeline ends
+// with a 4xx or 5xx status. It goes in front of the proxied routes'
+// middleware, so rejections by rate limits and authentication count too.
+func (p *Proxy) TrackTerminations(c *gin.Context) {
+	c.Next()
+
+	reason := Termination(c)
+	if reason == "" {
+		return
+	}
+	service := ""
+	if value, ok := c.Get(ServiceKey); ok {
+		service = value.(*models.ServiceConfig).Name
+	} else if _, matched := p.registry.FindRoute(c.Request.Method, c.Request.URL.Path); matched != nil {
+		service = matched.Name
+	}
+	p.terminations.Record(reason, service)
+}
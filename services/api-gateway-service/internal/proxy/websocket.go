@@ -0,0 +1,359 @@
+package proxy
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gateway/internal/models"
+)
+
+const (
+	closeGoingAway     = 1001
+	closeMessageTooBig = 1009
+)
+
+var (
+	errMessageTooBig = errors.New("websocket message too big")
+	errIdle          = errors.New("websocket idle")
+)
+
+// IsWebSocket reports whether r asks to upgrade to a WebSocket.
+func IsWebSocket(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket") &&
+		headerContainsToken(r.Header, "Connection", "upgrade")
+}
+
+func headerContainsToken(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// WebSocketTracker counts the open WebSocket connections of each route and
+// client and enforces the caps of the route's websocket policy.
+type WebSocketTracker struct {
+	mutex   sync.Mutex
+	routes  map[string]int
+	clients map[string]int
+
+	accepted     int64
+	rejected     int64
+	closedTooBig int64
+	closedIdle   int64
+}
+
+func NewWebSocketTracker() *WebSocketTracker {
+	return &WebSocketTracker{
+		routes:  make(map[string]int),
+		clients: make(map[string]int),
+	}
+}
+
+// Acquire claims a connection slot for client on route. It returns false,
+// and whether the per-client cap was the one hit, when the policy is full.
+func (t *WebSocketTracker) Acquire(route, client string, policy *models.WebSocketPolicy) (ok, perClient bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	clientKey := route + "|" + client
+	if policy != nil {
+		if policy.MaxConnectionsPerClient > 0 && t.clients[clientKey] >= policy.MaxConnectionsPerClient {
+			t.rejected++
+			return false, true
+		}
+		if policy.MaxConnections > 0 && t.routes[route] >= policy.MaxConnections {
+			t.rejected++
+			return false, false
+		}
+	}
+	t.routes[route]++
+	t.clients[clientKey]++
+	t.accepted++
+	return true, false
+}
+
+// Release frees the slot of a connection that has ended.
+func (t *WebSocketTracker) Release(route, client string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	clientKey := route + "|" + client
+	if t.routes[route]--; t.routes[route] <= 0 {
+		delete(t.routes, route)
+	}
+	if t.clients[clientKey]--; t.clients[clientKey] <= 0 {
+		delete(t.clients, clientKey)
+	}
+}
+
+func (t *WebSocketTracker) recordClose(err error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	switch {
+	case errors.Is(err, errMessageTooBig):
+		t.closedTooBig++
+	case errors.Is(err, errIdle):
+		t.closedIdle++
+	}
+}
+
+// Active returns the open connections by route.
+func (t *WebSocketTracker) Active() map[string]int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	active := make(map[string]int, len(t.routes))
+	for route, count := range t.routes {
+		active[route] = count
+	}
+	return active
+}
+
+func (t *WebSocketTracker) Stats() map[string]interface{} {
+	active := t.Active()
+	total := 0
+	for _, count := range active {
+		total += count
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return map[string]interface{}{
+		"active":          total,
+		"active_by_route": active,
+		"accepted":        t.accepted,
+		"rejected":        t.rejected,
+		"closed_too_big":  t.closedTooBig,
+		"closed_idle":     t.closedIdle,
+	}
+}
+
+// websocketWriter hands the reverse proxy a client connection that watches
+// the frames passing through once the upgrade has been hijacked.
+type websocketWriter struct {
+	http.ResponseWriter
+	policy  *models.WebSocketPolicy
+	tracker *WebSocketTracker
+}
+
+func (w *websocketWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *websocketWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	return newWebSocketConn(conn, w.policy, w.tracker), rw, nil
+}
+
+// websocketConn is the client side of a proxied WebSocket. Reads carry
+// client frames to the upstream and are checked against max_message_size;
+// writes carry upstream frames and are only followed, so a close frame can
+// be slipped in between two of them.
+type websocketConn struct {
+	net.Conn
+	tracker *WebSocketTracker
+
+	incoming frameScanner
+
+	writeMutex sync.Mutex
+	outgoing   frameScanner
+
+	idleTimeout  time.Duration
+	lastActivity int64
+	idleTimer    *time.Timer
+
+	closeOnce sync.Once
+}
+
+func newWebSocketConn(conn net.Conn, policy *models.WebSocketPolicy, tracker *WebSocketTracker) *websocketConn {
+	wc := &websocketConn{
+		Conn:         conn,
+		tracker:      tracker,
+		lastActivity: time.Now().UnixNano(),
+	}
+	if policy != nil {
+		wc.incoming.maxMessage = policy.MaxMessageSize
+		wc.idleTimeout = policy.IdleTimeout
+	}
+	if wc.idleTimeout > 0 {
+		wc.idleTimer = time.AfterFunc(wc.idleTimeout, wc.checkIdle)
+	}
+	return wc
+}
+
+func (c *websocketConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		atomic.StoreInt64(&c.lastActivity, time.Now().UnixNano())
+		if scanErr := c.incoming.scan(p[:n]); scanErr != nil {
+			c.terminate(closeMessageTooBig, scanErr)
+			return 0, scanErr
+		}
+	}
+	return n, err
+}
+
+func (c *websocketConn) Write(p []byte) (int, error) {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	n, err := c.Conn.Write(p)
+	if n > 0 {
+		atomic.StoreInt64(&c.lastActivity, time.Now().UnixNano())
+		c.outgoing.scan(p[:n])
+	}
+	return n, err
+}
+
+func (c *websocketConn) Close() error {
+	if c.idleTimer != nil {
+		c.idleTimer.Stop()
+	}
+	return c.Conn.Close()
+}
+
+func (c *websocketConn) checkIdle() {
+	idle := time.Since(time.Unix(0, atomic.LoadInt64(&c.lastActivity)))
+	if idle < c.idleTimeout {
+		c.idleTimer.Reset(c.idleTimeout - idle)
+		return
+	}
+	c.terminate(closeGoingAway, errIdle)
+}
+
+// terminate tells the client why its socket ends, unless an upstream frame
+// is half written, and closes the connection. The reverse proxy then closes
+// the upstream side.
+func (c *websocketConn) terminate(code uint16, reason error) {
+	c.closeOnce.Do(func() {
+		c.tracker.recordClose(reason)
+
+		// A write stuck on a client that stopped reading holds the lock;
+		// such a client gets no close frame
+		if c.writeMutex.TryLock() {
+			if c.outgoing.atBoundary() {
+				c.Conn.SetWriteDeadline(time.Now().Add(time.Second))
+				c.Conn.Write(closeFrame(code, reason.Error()))
+			}
+			c.writeMutex.Unlock()
+		}
+		c.Close()
+	})
+}
+
+// closeFrame is an unmasked server close frame.
+func closeFrame(code uint16, reason string) []byte {
+	if len(reason) > 123 {
+		reason = reason[:123]
+	}
+	frame := []byte{0x88, byte(2 + len(reason))}
+	frame = binary.BigEndian.AppendUint16(frame, code)
+	return append(frame, reason...)
+}
+
+// frameScanner follows the frames of one direction of a WebSocket across
+// arbitrary read boundaries without buffering payloads.
+type frameScanner struct {
+	maxMessage int64
+
+	header    [14]byte
+	headerLen int
+	remaining int64
+	message   int64
+}
+
+func (s *frameScanner) scan(p []byte) error {
+	for len(p) > 0 {
+		if s.remaining > 0 {
+			n := int64(len(p))
+			if n > s.remaining {
+				n = s.remaining
+			}
+			s.remaining -= n
+			p = p[n:]
+			continue
+		}
+
+		s.header[s.headerLen] = p[0]
+		s.headerLen++
+		p = p[1:]
+		if s.headerLen < s.headerSize() {
+			continue
+		}
+		if err := s.frame(); err != nil {
+			return err
+		}
+		s.headerLen = 0
+	}
+	return nil
+}
+
+func (s *frameScanner) atBoundary() bool {
+	return s.headerLen == 0 && s.remaining == 0
+}
+
+func (s *frameScanner) headerSize() int {
+	if s.headerLen < 2 {
+		return 2
+	}
+	size := 2
+	switch s.header[1] & 0x7f {
+	case 126:
+		size += 2
+	case 127:
+		size += 8
+	}
+	if s.header[1]&0x80 != 0 {
+		size += 4
+	}
+	return size
+}
+
+// frame accounts for a complete frame header. Data frames start a message
+// and continuation frames add to it; control frames are bounded by the
+// protocol.
+func (s *frameScanner) frame() error {
+	opcode := s.header[0] & 0x0f
+	length := int64(s.header[1] & 0x7f)
+	switch length {
+	case 126:
+		length = int64(binary.BigEndian.Uint16(s.header[2:4]))
+	case 127:
+		length = int64(binary.BigEndian.Uint64(s.header[2:10]) & math.MaxInt64)
+	}
+	s.remaining = length
+
+	if opcode < 0x8 {
+		if opcode != 0 {
+			s.message = 0
+		}
+		s.message += length
+		if s.maxMessage > 0 && s.message > s.maxMessage {
+			return errMessageTooBig
+		}
+	}
+	return nil
+}
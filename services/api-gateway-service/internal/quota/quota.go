@@ -0,0 +1,139 @@
+package quota
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync/atomic"
+	"time"
+
+	"gateway/internal/models"
+)
+
+// Usage is where an API key stands in one quota period.
+type Usage struct {
+	Period models.QuotaPeriod
+	Limit  int64
+	Used   int64
+	// Reset is when the period ends and the allowance renews
+	Reset time.Time
+}
+
+func (u Usage) Remaining() int64 {
+	if u.Used >= u.Limit {
+		return 0
+	}
+	return u.Limit - u.Used
+}
+
+// Quotas counts the requests of API keys against their daily and monthly
+// allowances. Keys are stored hashed, so the store never holds credentials.
+type Quotas struct {
+	config models.QuotaConfig
+	store  Store
+
+	allowed     int64
+	exhausted   int64
+	storeErrors int64
+}
+
+func New(config models.QuotaConfig, store Store) *Quotas {
+	return &Quotas{
+		config: config,
+		store:  store,
+	}
+}
+
+// Header is the request header carrying the API key.
+func (q *Quotas) Header() string {
+	return q.config.Header
+}
+
+// Consume counts a request by apiKey against every period with an
+// allowance. When one of them is exhausted it returns false and takes the
+// request back, so rejected requests do not use up the other periods. A
+// store error is returned with true: quotas fail open.
+func (q *Quotas) Consume(ctx context.Context, apiKey string) ([]Usage, bool, error) {
+	now := time.Now().UTC()
+	hash := keyHash(apiKey)
+
+	usages := make([]Usage, 0, 2)
+	keys := make([]string, 0, 2)
+	for _, period := range []models.QuotaPeriod{models.QuotaDaily, models.QuotaMonthly} {
+		limit := q.limit(period)
+		if limit <= 0 {
+			continue
+		}
+		label, reset := window(period, now)
+		key := string(period) + ":" + label + ":" + hash
+		used, err := q.store.Increment(ctx, key, 1, reset)
+		if err != nil {
+			atomic.AddInt64(&q.storeErrors, 1)
+			return nil, true, err
+		}
+		usages = append(usages, Usage{Period: period, Limit: limit, Used: used, Reset: reset})
+		keys = append(keys, key)
+	}
+
+	for _, usage := range usages {
+		if usage.Used > usage.Limit {
+			atomic.AddInt64(&q.exhausted, 1)
+			for i, key := range keys {
+				q.store.Increment(ctx, key, -1, usages[i].Reset)
+				usages[i].Used--
+			}
+			return usages, false, nil
+		}
+	}
+	atomic.AddInt64(&q.allowed, 1)
+	return usages, true, nil
+}
+
+func (q *Quotas) limit(period models.QuotaPeriod) int64 {
+	if period == models.QuotaDaily {
+		return q.config.Daily
+	}
+	return q.config.Monthly
+}
+
+// window names the UTC calendar period containing now and returns when it
+// ends.
+func window(period models.QuotaPeriod, now time.Time) (string, time.Time) {
+	if period == models.QuotaDaily {
+		start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		return start.Format("2006-01-02"), start.AddDate(0, 0, 1)
+	}
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return start.Format("2006-01"), start.AddDate(0, 1, 0)
+}
+
+func keyHash(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:16])
+}
+
+// Binding picks the usage a client should be told about: the period with
+// the least remaining, or of those the one renewing last.
+func Binding(usages []Usage) (Usage, bool) {
+	if len(usages) == 0 {
+		return Usage{}, false
+	}
+	binding := usages[0]
+	for _, usage := range usages[1:] {
+		if usage.Remaining() < binding.Remaining() ||
+			usage.Remaining() == binding.Remaining() && usage.Reset.After(binding.Reset) {
+			binding = usage
+		}
+	}
+	return binding, true
+}
+
+func (q *Quotas) Stats() map[string]interface{} {
+	return map[string]interface{}{
+		"daily":        q.config.Daily,
+		"monthly":      q.config.Monthly,
+		"allowed":      atomic.LoadInt64(&q.allowed),
+		"exhausted":    atomic.LoadInt64(&q.exhausted),
+		"store_errors": atomic.LoadInt64(&q.storeErrors),
+	}
+}
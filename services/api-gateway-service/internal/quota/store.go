@@ -0,0 +1,97 @@
+package quota
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Store keeps the request counters of quota periods. Counters expire at
+// the end of their period.
+type Store interface {
+	// Increment adds delta to key's counter and returns the new count
+	Increment(ctx context.Context, key string, delta int64, expiresAt time.Time) (int64, error)
+}
+
+type MemoryStore struct {
+	counters map[string]*memoryCounter
+	mutex    sync.Mutex
+}
+
+type memoryCounter struct {
+	count     int64
+	expiresAt time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		counters: make(map[string]*memoryCounter),
+	}
+}
+
+func (m *MemoryStore) Increment(ctx context.Context, key string, delta int64, expiresAt time.Time) (int64, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := time.Now()
+	counter, exists := m.counters[key]
+	if !exists || now.After(counter.expiresAt) {
+		counter = &memoryCounter{expiresAt: expiresAt}
+		m.counters[key] = counter
+	}
+	counter.count += delta
+	return counter.count, nil
+}
+
+// Sweep drops counters of periods that have ended.
+func (m *MemoryStore) Sweep() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := time.Now()
+	for key, counter := range m.counters {
+		if now.After(counter.expiresAt) {
+			delete(m.counters, key)
+		}
+	}
+}
+
+// Run sweeps ended periods every interval until ctx is done.
+func (m *MemoryStore) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.Sweep()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// RedisStore shares counters between gateway replicas.
+type RedisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+func NewRedisStore(client *redis.Client, prefix string) *RedisStore {
+	return &RedisStore{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+func (r *RedisStore) Increment(ctx context.Context, key string, delta int64, expiresAt time.Time) (int64, error) {
+	pipe := r.client.TxPipeline()
+	count := pipe.IncrBy(ctx, r.prefix+key, delta)
+	pipe.ExpireAt(ctx, r.prefix+key, expiresAt)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return count.Val(), nil
+}
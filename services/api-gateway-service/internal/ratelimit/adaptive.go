@@ -0,0 +1,206 @@
+package ratelimit
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"gateway/internal/models"
+)
+
+// maxLatencySamples bounds the latencies kept per window; busier windows
+// keep a uniform sample of them.
+const maxLatencySamples = 1024
+
+// RouteLimits enforces the rate_limit of each route with one bucket shared
+// by all of its clients. Adaptive limits are rescaled at the end of each
+// window from the p99 latency and error rate the route's upstream showed:
+// tightened multiplicatively while it struggles and relaxed additively as
+// it recovers.
+type RouteLimits struct {
+	mutex  sync.Mutex
+	routes map[string]*routeLimit
+}
+
+type routeLimit struct {
+	policy   models.RouteRateLimit
+	adaptive models.AdaptiveLimit
+	limiter  *Limiter
+	factor   float64
+
+	windowStart time.Time
+	requests    int
+	failures    int
+	latencies   []time.Duration
+
+	lastP99       time.Duration
+	lastErrorRate float64
+	tightened     int64
+	rejected      int64
+}
+
+func NewRouteLimits() *RouteLimits {
+	return &RouteLimits{routes: make(map[string]*routeLimit)}
+}
+
+// Take counts a request against route's limit. Routes without a rate_limit
+// always allow.
+func (r *RouteLimits) Take(route *models.RouteConfig) Quota {
+	if route.RateLimit == nil {
+		return Quota{Allowed: true}
+	}
+
+	r.mutex.Lock()
+	limit := r.limit(route, time.Now())
+	r.mutex.Unlock()
+
+	quota := limit.limiter.Take("route")
+	if !quota.Allowed {
+		r.mutex.Lock()
+		limit.rejected++
+		r.mutex.Unlock()
+	}
+	return quota
+}
+
+// Record feeds the latency up to the response headers and the outcome of a
+// request proxied on route into its adaptive limit. Failures are 5xx
+// responses, timeouts and connection errors.
+func (r *RouteLimits) Record(route *models.RouteConfig, latency time.Duration, failed bool) {
+	if route.RateLimit == nil || route.RateLimit.Adaptive == nil {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	limit := r.limit(route, time.Now())
+	limit.requests++
+	if failed {
+		limit.failures++
+	}
+	if len(limit.latencies) < maxLatencySamples {
+		limit.latencies = append(limit.latencies, latency)
+	} else if i := rand.Intn(limit.requests); i < maxLatencySamples {
+		limit.latencies[i] = latency
+	}
+}
+
+// limit returns route's state, rebuilt when the route's policy changed and
+// rescaled when its window has ended.
+func (r *RouteLimits) limit(route *models.RouteConfig, now time.Time) *routeLimit {
+	limit, exists := r.routes[route.Path]
+	if !exists || !reflect.DeepEqual(limit.policy, *route.RateLimit) {
+		limit = newRouteLimit(*route.RateLimit, now)
+		r.routes[route.Path] = limit
+	}
+	if limit.policy.Adaptive != nil && now.Sub(limit.windowStart) >= limit.adaptive.Window {
+		limit.adapt(now)
+	}
+	return limit
+}
+
+func newRouteLimit(policy models.RouteRateLimit, now time.Time) *routeLimit {
+	burst := policy.Burst
+	if burst <= 0 {
+		burst = policy.Requests
+	}
+	limit := &routeLimit{
+		policy:      policy,
+		limiter:     NewLimiter(float64(policy.Requests)/policy.Window.Seconds(), burst),
+		factor:      1,
+		windowStart: now,
+	}
+	if policy.Adaptive != nil {
+		limit.adaptive = policy.Adaptive.Defaults()
+	}
+	return limit
+}
+
+// adapt judges the window that just ended and starts the next one.
+func (l *routeLimit) adapt(now time.Time) {
+	factor := l.factor
+	l.lastP99, l.lastErrorRate = 0, 0
+	if l.requests >= l.adaptive.MinRequests {
+		l.lastP99 = percentile(l.latencies, 0.99)
+		l.lastErrorRate = float64(l.failures) / float64(l.requests)
+	}
+
+	slow := l.adaptive.MaxP99Latency > 0 && l.lastP99 > l.adaptive.MaxP99Latency
+	failing := l.adaptive.MaxErrorRate > 0 && l.lastErrorRate > l.adaptive.MaxErrorRate
+	if slow || failing {
+		factor = math.Max(l.adaptive.MinFactor, factor*l.adaptive.Decrease)
+		if factor < l.factor {
+			l.tightened++
+		}
+	} else {
+		factor = math.Min(1, factor+l.adaptive.Increase)
+	}
+	// Windows that passed without any traffic count as healthy
+	if idle := int(now.Sub(l.windowStart)/l.adaptive.Window) - 1; idle > 0 {
+		factor = math.Min(1, factor+float64(idle)*l.adaptive.Increase)
+	}
+	if factor != l.factor {
+		l.factor = factor
+		l.limiter.Scale(factor)
+	}
+
+	l.windowStart = now
+	l.requests, l.failures = 0, 0
+	l.latencies = l.latencies[:0]
+}
+
+func percentile(latencies []time.Duration, p float64) time.Duration {
+	if len(latencies) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[int(math.Ceil(p*float64(len(sorted))))-1]
+}
+
+// RouteLimitStatus is the state of one route's limit.
+type RouteLimitStatus struct {
+	Route    string  `json:"route"`
+	Requests int     `json:"requests"`
+	Window   string  `json:"window"`
+	Adaptive bool    `json:"adaptive"`
+	Factor   float64 `json:"factor"`
+	// Effective is the number of requests per window currently allowed
+	Effective     float64 `json:"effective"`
+	LastP99       string  `json:"last_p99,omitempty"`
+	LastErrorRate float64 `json:"last_error_rate"`
+	Tightened     int64   `json:"tightened"`
+	Rejected      int64   `json:"rejected"`
+}
+
+// Routes returns the state of every limited route that has seen traffic,
+// sorted by path.
+func (r *RouteLimits) Routes() []RouteLimitStatus {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	statuses := make([]RouteLimitStatus, 0, len(r.routes))
+	for path, limit := range r.routes {
+		status := RouteLimitStatus{
+			Route:         path,
+			Requests:      limit.policy.Requests,
+			Window:        limit.policy.Window.String(),
+			Adaptive:      limit.policy.Adaptive != nil,
+			Factor:        limit.factor,
+			Effective:     float64(limit.policy.Requests) * limit.factor,
+			LastErrorRate: limit.lastErrorRate,
+			Tightened:     limit.tightened,
+			Rejected:      limit.rejected,
+		}
+		if limit.lastP99 > 0 {
+			status.LastP99 = limit.lastP99.String()
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Route < statuses[j].Route })
+	return statuses
+}
@@ -0,0 +1,262 @@
+package ratelimit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Taker counts requests against token buckets keyed by client, and lets
+// operators look at and reset them. A Limiter is one; a Client shares a
+// Limiter served by another process.
+type Taker interface {
+	Take(key string) Quota
+	TakeN(key string, n int) Quota
+	Buckets() ([]BucketStatus, error)
+	Reset(key string) (bool, error)
+	Stats() map[string]interface{}
+}
+
+// ServeCoordinator answers the requests of worker processes from limiter,
+// so all of them count against the same buckets, until ctx is done. Each
+// request is one line:
+//
+//	"key" [n]  TakeN, n defaulting to 1; answered with "allowed limit
+//	           remaining reset retry_after", durations in nanoseconds
+//	L          Buckets; answered with a JSON array
+//	R "key"    Reset; answered with 1 if key had a bucket, else 0
+func ServeCoordinator(ctx context.Context, listener net.Listener, limiter *Limiter) error {
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		go serveTakes(ctx, conn, limiter)
+	}
+}
+
+func serveTakes(ctx context.Context, conn net.Conn, limiter *Limiter) {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		conn.Close()
+	}()
+
+	reader := bufio.NewReader(conn)
+	writer := bufio.NewWriter(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		if err := answer(writer, limiter, line[:len(line)-1]); err != nil {
+			return
+		}
+		if err := writer.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func answer(writer *bufio.Writer, limiter *Limiter, request string) error {
+	switch {
+	case request == "L":
+		buckets, _ := limiter.Buckets()
+		encoded, err := json.Marshal(buckets)
+		if err != nil {
+			return err
+		}
+		writer.Write(encoded)
+		writer.WriteByte('\n')
+	case strings.HasPrefix(request, "R "):
+		key, err := strconv.Unquote(request[2:])
+		if err != nil {
+			return err
+		}
+		existed, _ := limiter.Reset(key)
+		if existed {
+			writer.WriteString("1\n")
+		} else {
+			writer.WriteString("0\n")
+		}
+	default:
+		quoted, err := strconv.QuotedPrefix(request)
+		if err != nil {
+			return err
+		}
+		key, _ := strconv.Unquote(quoted)
+		n := 1
+		if rest := strings.TrimSpace(request[len(quoted):]); rest != "" {
+			if n, err = strconv.Atoi(rest); err != nil {
+				return err
+			}
+		}
+		quota := limiter.TakeN(key, n)
+		allowed := 0
+		if quota.Allowed {
+			allowed = 1
+		}
+		fmt.Fprintf(writer, "%d %d %d %d %d\n", allowed, quota.Limit, quota.Remaining, quota.Reset, quota.RetryAfter)
+	}
+	return nil
+}
+
+// Client takes tokens from a coordinator over a local socket. When the
+// coordinator cannot be reached it takes them from fallback instead, which
+// should hold the worker's share of the limit.
+type Client struct {
+	socket   string
+	fallback *Limiter
+	timeout  time.Duration
+	conns    chan *coordinatorConn
+
+	blocked int64
+	errors  int64
+
+	errorMutex sync.Mutex
+	lastError  error
+}
+
+type coordinatorConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func NewClient(socket string, fallback *Limiter) *Client {
+	return &Client{
+		socket:   socket,
+		fallback: fallback,
+		timeout:  50 * time.Millisecond,
+		conns:    make(chan *coordinatorConn, 64),
+	}
+}
+
+func (c *Client) Take(key string) Quota {
+	return c.TakeN(key, 1)
+}
+
+func (c *Client) TakeN(key string, n int) Quota {
+	quota, err := c.take(key, n)
+	if err != nil {
+		atomic.AddInt64(&c.errors, 1)
+		c.errorMutex.Lock()
+		c.lastError = err
+		c.errorMutex.Unlock()
+		quota = c.fallback.TakeN(key, n)
+	}
+	if !quota.Allowed {
+		atomic.AddInt64(&c.blocked, 1)
+	}
+	return quota
+}
+
+func (c *Client) take(key string, n int) (Quota, error) {
+	request := strconv.Quote(key)
+	if n > 1 {
+		request += " " + strconv.Itoa(n)
+	}
+	line, err := c.call(request, c.timeout)
+	if err != nil {
+		return Quota{}, err
+	}
+	var allowed int
+	var quota Quota
+	if _, err := fmt.Sscanf(line, "%d %d %d %d %d\n", &allowed, &quota.Limit, &quota.Remaining, &quota.Reset, &quota.RetryAfter); err != nil {
+		return Quota{}, fmt.Errorf("malformed coordinator answer: %w", err)
+	}
+	quota.Allowed = allowed == 1
+	return quota, nil
+}
+
+// Buckets lists the coordinator's buckets. Unlike Take it does not fall
+// back: the worker's own share says nothing about other workers' clients.
+func (c *Client) Buckets() ([]BucketStatus, error) {
+	line, err := c.call("L", adminTimeout)
+	if err != nil {
+		return nil, err
+	}
+	var buckets []BucketStatus
+	if err := json.Unmarshal([]byte(line), &buckets); err != nil {
+		return nil, fmt.Errorf("malformed coordinator answer: %w", err)
+	}
+	return buckets, nil
+}
+
+// Reset resets key at the coordinator and in the fallback.
+func (c *Client) Reset(key string) (bool, error) {
+	c.fallback.Reset(key)
+	line, err := c.call("R "+strconv.Quote(key), adminTimeout)
+	if err != nil {
+		return false, err
+	}
+	return line == "1\n", nil
+}
+
+// adminTimeout bounds the coordinator calls of operators, which may carry
+// every bucket.
+const adminTimeout = 5 * time.Second
+
+// call sends request to the coordinator on a pooled connection and returns
+// the answer's line.
+func (c *Client) call(request string, timeout time.Duration) (string, error) {
+	var conn *coordinatorConn
+	select {
+	case conn = <-c.conns:
+	default:
+		raw, err := net.DialTimeout("unix", c.socket, timeout)
+		if err != nil {
+			return "", err
+		}
+		conn = &coordinatorConn{Conn: raw, reader: bufio.NewReader(raw)}
+	}
+
+	conn.SetDeadline(time.Now().Add(timeout))
+	_, err := conn.Write([]byte(request + "\n"))
+	var line string
+	if err == nil {
+		line, err = conn.reader.ReadString('\n')
+	}
+	if err != nil {
+		conn.Close()
+		return "", err
+	}
+	select {
+	case c.conns <- conn:
+	default:
+		conn.Close()
+	}
+	return line, nil
+}
+
+func (c *Client) Stats() map[string]interface{} {
+	stats := map[string]interface{}{
+		"coordinator":        c.socket,
+		"blocked_requests":   atomic.LoadInt64(&c.blocked),
+		"coordinator_errors": atomic.LoadInt64(&c.errors),
+	}
+	c.errorMutex.Lock()
+	defer c.errorMutex.Unlock()
+	if c.lastError != nil {
+		stats["last_error"] = c.lastError.Error()
+	}
+	return stats
+}
@@ -0,0 +1,212 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Limiter is a set of token buckets keyed by client. Buckets refill at rate
+// tokens per second up to burst and are dropped once they are full again.
+type Limiter struct {
+	rate    float64
+	burst   float64
+	buckets map[string]*bucket
+	mutex   sync.Mutex
+
+	// The configured rate and burst, which Scale is relative to
+	baseRate  float64
+	baseBurst float64
+
+	blocked int64
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	seen    time.Time
+	blocked int64
+}
+
+func NewLimiter(rate float64, burst int) *Limiter {
+	return &Limiter{
+		rate:      rate,
+		burst:     float64(burst),
+		buckets:   make(map[string]*bucket),
+		baseRate:  rate,
+		baseBurst: float64(burst),
+	}
+}
+
+// Scale sets the rate and burst to factor times the configured ones. A
+// burst is never below one token, and buckets holding more tokens than the
+// new burst are cut down to it.
+func (l *Limiter) Scale(factor float64) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	for _, b := range l.buckets {
+		l.refill(b, now)
+	}
+	l.rate = l.baseRate * factor
+	l.burst = math.Max(1, math.Round(l.baseBurst*factor))
+	for _, b := range l.buckets {
+		b.tokens = math.Min(b.tokens, l.burst)
+	}
+}
+
+// Quota is the state of a bucket after a request was counted against it.
+type Quota struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until the next token, for a rejected request.
+	RetryAfter time.Duration
+}
+
+// Allow takes a token from key's bucket. When none is left it returns false
+// and how long until the next token is available.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	quota := l.Take(key)
+	return quota.Allowed, quota.RetryAfter
+}
+
+// Take is Allow reporting the bucket's quota as well.
+func (l *Limiter) Take(key string) Quota {
+	return l.TakeN(key, 1)
+}
+
+// TakeN is Take for a request that costs n tokens. The request is rejected,
+// and takes nothing, unless all n are left.
+func (l *Limiter) TakeN(key string, n int) Quota {
+	if n < 1 {
+		n = 1
+	}
+	cost := float64(n)
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	b, exists := l.buckets[key]
+	if !exists {
+		b = &bucket{tokens: l.burst, updated: now}
+		l.buckets[key] = b
+	}
+	l.refill(b, now)
+	b.seen = now
+
+	quota := Quota{Limit: int(l.burst)}
+	if b.tokens >= cost {
+		b.tokens -= cost
+		quota.Allowed = true
+	} else {
+		l.blocked++
+		b.blocked++
+		quota.RetryAfter = l.until(cost - b.tokens)
+	}
+	quota.Remaining = int(b.tokens)
+	quota.Reset = l.until(l.burst - b.tokens)
+	return quota
+}
+
+// until is how long the bucket takes to refill tokens.
+func (l *Limiter) until(tokens float64) time.Duration {
+	return time.Duration(math.Ceil(tokens / l.rate * float64(time.Second)))
+}
+
+func (l *Limiter) refill(b *bucket, now time.Time) {
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.updated).Seconds()*l.rate)
+	b.updated = now
+}
+
+// Sweep drops buckets that have refilled completely; a new bucket for the
+// same key would start out identical.
+func (l *Limiter) Sweep() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	for key, b := range l.buckets {
+		if l.refill(b, now); b.tokens >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Run sweeps idle buckets every interval until ctx is done.
+func (l *Limiter) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			l.Sweep()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// BucketStatus is the state of one key's bucket.
+type BucketStatus struct {
+	Key       string    `json:"key"`
+	Remaining int       `json:"remaining"`
+	Limit     int       `json:"limit"`
+	Blocked   int64     `json:"blocked"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// Buckets returns the state of every active bucket, the most blocked
+// first. A Limiter never fails to.
+func (l *Limiter) Buckets() ([]BucketStatus, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	statuses := make([]BucketStatus, 0, len(l.buckets))
+	for key, b := range l.buckets {
+		tokens := math.Min(l.burst, b.tokens+now.Sub(b.updated).Seconds()*l.rate)
+		statuses = append(statuses, BucketStatus{
+			Key:       key,
+			Remaining: int(tokens),
+			Limit:     int(l.burst),
+			Blocked:   b.blocked,
+			LastSeen:  b.seen,
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].Blocked != statuses[j].Blocked {
+			return statuses[i].Blocked > statuses[j].Blocked
+		}
+		return statuses[i].Key < statuses[j].Key
+	})
+	return statuses, nil
+}
+
+// Reset gives key a full bucket again and forgets its blocks. It reports
+// whether key had a bucket.
+func (l *Limiter) Reset(key string) (bool, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	_, exists := l.buckets[key]
+	delete(l.buckets, key)
+	return exists, nil
+}
+
+func (l *Limiter) Stats() map[string]interface{} {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return map[string]interface{}{
+		"active_limiters":  len(l.buckets),
+		"blocked_requests": l.blocked,
+	}
+}
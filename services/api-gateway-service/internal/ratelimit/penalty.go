@@ -0,0 +1,195 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"gateway/internal/models"
+)
+
+// PenaltyBox tracks clients that keep getting 429 responses. Every 429 adds
+// a strike to the client's score, which halves every half_life. Clients
+// whose score reaches the threshold are penalized: they get a stricter
+// limit and their rejections are delayed (tarpitted) to slow down tight
+// retry loops.
+type PenaltyBox struct {
+	config   models.PenaltyConfig
+	strict   *Limiter
+	scores   map[string]*score
+	mutex    sync.Mutex
+	tarpits  int64
+	maxScore float64
+}
+
+type score struct {
+	value   float64
+	updated time.Time
+}
+
+// NewPenaltyBox builds a penalty box for clients of policy, whose stricter
+// limit is the policy's rate and burst scaled by rate_factor.
+func NewPenaltyBox(policy models.RateLimitPolicy) *PenaltyBox {
+	config := policy.Penalty
+	burst := int(math.Max(1, float64(policy.Burst)*config.RateFactor))
+	return &PenaltyBox{
+		config: config,
+		strict: NewLimiter(policy.GetRate()*config.RateFactor, burst),
+		scores: make(map[string]*score),
+		// Scores are capped so a client that stops retrying is released
+		// within a bounded number of half-lives
+		maxScore: float64(config.Threshold) * 8,
+	}
+}
+
+// Strike records a 429 for client.
+func (p *PenaltyBox) Strike(client string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	s, exists := p.scores[client]
+	if !exists {
+		s = &score{}
+		p.scores[client] = s
+	}
+	p.decay(s, time.Now())
+	s.value = math.Min(p.maxScore, s.value+1)
+}
+
+// Penalty reports whether client is penalized and how long its rejections
+// should be delayed.
+func (p *PenaltyBox) Penalty(client string) (bool, time.Duration) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	s, exists := p.scores[client]
+	if !exists {
+		return false, 0
+	}
+	p.decay(s, time.Now())
+	if !p.penalized(s) {
+		return false, 0
+	}
+
+	delay := time.Duration(float64(p.config.TarpitDelay) * s.value / float64(p.config.Threshold))
+	if delay > p.config.MaxTarpitDelay {
+		delay = p.config.MaxTarpitDelay
+	}
+	return true, delay
+}
+
+// Allow applies the stricter limit for penalized clients.
+func (p *PenaltyBox) Allow(client string) (bool, time.Duration) {
+	return p.strict.Allow(client)
+}
+
+// Take is Allow reporting the stricter bucket's quota as well.
+func (p *PenaltyBox) Take(client string) Quota {
+	return p.strict.Take(client)
+}
+
+// TakeN is Take for a request that costs n tokens.
+func (p *PenaltyBox) TakeN(client string, n int) Quota {
+	return p.strict.TakeN(client, n)
+}
+
+// Tarpit waits for delay before a rejection is sent, returning early if
+// the client goes away.
+func (p *PenaltyBox) Tarpit(ctx context.Context, delay time.Duration) {
+	if delay <= 0 {
+		return
+	}
+
+	p.mutex.Lock()
+	p.tarpits++
+	p.mutex.Unlock()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
+
+func (p *PenaltyBox) decay(s *score, now time.Time) {
+	if !s.updated.IsZero() {
+		s.value *= math.Pow(0.5, now.Sub(s.updated).Seconds()/p.config.HalfLife.Seconds())
+	}
+	s.updated = now
+}
+
+// penalized compares the rounded score, so strikes in quick succession
+// count in full despite the decay between them.
+func (p *PenaltyBox) penalized(s *score) bool {
+	return math.Round(s.value) >= float64(p.config.Threshold)
+}
+
+// Forgive clears the strikes and stricter bucket of every client of key,
+// the key of the bucket the clients share: a client IP or "user:" and a
+// user ID. It returns the number of clients forgiven.
+func (p *PenaltyBox) Forgive(key string) int {
+	p.mutex.Lock()
+	var forgiven []string
+	for client := range p.scores {
+		if client == key || strings.HasPrefix(client, key+"/") {
+			delete(p.scores, client)
+			forgiven = append(forgiven, client)
+		}
+	}
+	p.mutex.Unlock()
+
+	for _, client := range forgiven {
+		p.strict.Reset(client)
+	}
+	return len(forgiven)
+}
+
+// Sweep forgets clients whose score has decayed to almost nothing.
+func (p *PenaltyBox) Sweep() {
+	p.mutex.Lock()
+	now := time.Now()
+	for client, s := range p.scores {
+		if p.decay(s, now); s.value < 0.1 {
+			delete(p.scores, client)
+		}
+	}
+	p.mutex.Unlock()
+
+	p.strict.Sweep()
+}
+
+// Run sweeps decayed clients every interval until ctx is done.
+func (p *PenaltyBox) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.Sweep()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (p *PenaltyBox) Stats() map[string]interface{} {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	penalized := 0
+	now := time.Now()
+	for _, s := range p.scores {
+		if p.decay(s, now); p.penalized(s) {
+			penalized++
+		}
+	}
+	return map[string]interface{}{
+		"tracked_clients":   len(p.scores),
+		"penalized_clients": penalized,
+		"tarpitted":         p.tarpits,
+	}
+}
@@ -0,0 +1,189 @@
+package registry
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+
+	"gateway/internal/models"
+)
+
+// canaryState compares the canary of one route against its stable service
+// within the current observation window. Once rolled back, the route sends
+// nothing to the canary until it is resumed.
+type canaryState struct {
+	route      string
+	canary     string
+	start      time.Time
+	stable     canaryWindow
+	candidate  canaryWindow
+	rolledBack time.Time
+	reason     string
+}
+
+type canaryWindow struct {
+	requests int
+	failures int
+}
+
+func (w canaryWindow) rate() float64 {
+	if w.requests == 0 {
+		return 0
+	}
+	return float64(w.failures) / float64(w.requests)
+}
+
+// CanaryStatus describes a route's canary for the admin API.
+type CanaryStatus struct {
+	Route           string     `json:"route"`
+	Service         string     `json:"service"`
+	Canary          string     `json:"canary"`
+	Weight          int        `json:"weight"`
+	EffectiveWeight int        `json:"effective_weight"`
+	RolledBack      bool       `json:"rolled_back"`
+	RolledBackAt    *time.Time `json:"rolled_back_at,omitempty"`
+	Reason          string     `json:"reason,omitempty"`
+	StableRequests  int        `json:"stable_requests"`
+	StableErrors    int        `json:"stable_errors"`
+	CanaryRequests  int        `json:"canary_requests"`
+	CanaryErrors    int        `json:"canary_errors"`
+}
+
+func canaryKey(route *models.RouteConfig) string {
+	return route.Path + "|" + route.Canary.ServiceName
+}
+
+// PickCanary decides whether a request to route goes to its canary and
+// returns a copy of the canary service if so. Routes whose canary was
+// rolled back, or whose canary service is missing, disabled or draining,
+// keep all traffic on the stable service.
+func (sr *ServiceRegistry) PickCanary(route *models.RouteConfig) *models.ServiceConfig {
+	if route.Canary == nil || route.Canary.Weight <= 0 {
+		return nil
+	}
+	if route.Canary.Weight < 100 && rand.Intn(100) >= route.Canary.Weight {
+		return nil
+	}
+
+	sr.mutex.RLock()
+	defer sr.mutex.RUnlock()
+
+	if state, exists := sr.canaries[canaryKey(route)]; exists && !state.rolledBack.IsZero() {
+		return nil
+	}
+	service, exists := sr.services[route.Canary.ServiceName]
+	if !exists || !service.Enabled || sr.held(service.Name) {
+		return nil
+	}
+	serviceCopy := *service
+	return &serviceCopy
+}
+
+// RecordCanaryResult feeds the outcome of a request to a canary route,
+// served by serviceName, into the comparison of canary and stable. failed
+// has the meaning it has for RecordProxyResult. Routes without a rollback
+// policy are ignored.
+func (sr *ServiceRegistry) RecordCanaryResult(route *models.RouteConfig, serviceName string, failed bool) {
+	if route.Canary == nil || route.Canary.Rollback == nil {
+		return
+	}
+	policy := route.Canary.Rollback.Defaults()
+
+	sr.mutex.Lock()
+	now := time.Now()
+	key := canaryKey(route)
+	state, exists := sr.canaries[key]
+	if !exists {
+		state = &canaryState{route: route.Path, canary: route.Canary.ServiceName, start: now}
+		sr.canaries[key] = state
+	}
+	if !state.rolledBack.IsZero() {
+		sr.mutex.Unlock()
+		return
+	}
+	if now.Sub(state.start) >= policy.Window {
+		state.start = now
+		state.stable = canaryWindow{}
+		state.candidate = canaryWindow{}
+	}
+
+	window := &state.stable
+	if serviceName == route.Canary.ServiceName {
+		window = &state.candidate
+	}
+	window.requests++
+	if failed {
+		window.failures++
+	}
+
+	var event *models.HealthEvent
+	if state.candidate.requests >= policy.MinRequests &&
+		state.candidate.rate()-state.stable.rate() > policy.MaxErrorRateIncrease {
+		state.rolledBack = now
+		state.reason = fmt.Sprintf("route %s: %.0f%% of %d canary requests failed against %.0f%% of %d for %s",
+			route.Path, state.candidate.rate()*100, state.candidate.requests,
+			state.stable.rate()*100, state.stable.requests, route.ServiceName)
+		event = &models.HealthEvent{
+			Type:      models.EventCanaryRolledBack,
+			Service:   route.Canary.ServiceName,
+			Reason:    state.reason,
+			Timestamp: now,
+		}
+	}
+	sr.mutex.Unlock()
+
+	sr.publish(event)
+}
+
+// ResumeCanary sends traffic to a rolled back canary again, with a fresh
+// window. It reports whether routePath has a canary.
+func (sr *ServiceRegistry) ResumeCanary(routePath string) bool {
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+
+	found := false
+	for _, route := range sr.routes {
+		if route.Path == routePath && route.Canary != nil {
+			delete(sr.canaries, canaryKey(route))
+			found = true
+		}
+	}
+	return found
+}
+
+// Canaries returns the status of every route with a canary, by path.
+func (sr *ServiceRegistry) Canaries() []CanaryStatus {
+	sr.mutex.RLock()
+	defer sr.mutex.RUnlock()
+
+	var statuses []CanaryStatus
+	for _, route := range sr.routes {
+		if route.Canary == nil {
+			continue
+		}
+		status := CanaryStatus{
+			Route:   route.Path,
+			Service: route.ServiceName,
+			Canary:  route.Canary.ServiceName,
+			Weight:  route.Canary.Weight,
+		}
+		status.EffectiveWeight = status.Weight
+		if state, exists := sr.canaries[canaryKey(route)]; exists {
+			if !state.rolledBack.IsZero() {
+				rolledBackAt := state.rolledBack
+				status.RolledBack = true
+				status.RolledBackAt = &rolledBackAt
+				status.Reason = state.reason
+				status.EffectiveWeight = 0
+			}
+			status.StableRequests = state.stable.requests
+			status.StableErrors = state.stable.failures
+			status.CanaryRequests = state.candidate.requests
+			status.CanaryErrors = state.candidate.failures
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Route < statuses[j].Route })
+	return statuses
+}
@@ -0,0 +1,55 @@
+package registry
+
+import (
+	"time"
+
+	"gateway/internal/models"
+)
+
+// EventListener is notified after a service's status or SLA compliance
+// changes. Listeners run on the goroutine that observed the change and must
+// not block.
+type EventListener func(event models.HealthEvent)
+
+func (sr *ServiceRegistry) OnHealthEvent(listener EventListener) {
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+
+	sr.listeners = append(sr.listeners, listener)
+}
+
+// statusChange returns the event to publish when a service's status moved
+// away from previous. Callers hold the lock and publish after releasing it.
+func (sr *ServiceRegistry) statusChange(service *models.ServiceConfig, previous models.ServiceStatus, reason string) *models.HealthEvent {
+	if service.Status == previous {
+		return nil
+	}
+	state := sr.healthState(service.Name)
+	if state.statusChanges == nil {
+		state.statusChanges = make(map[models.ServiceStatus]int64)
+	}
+	state.statusChanges[service.Status]++
+	return &models.HealthEvent{
+		Type:      models.EventStatusChanged,
+		Service:   service.Name,
+		From:      previous,
+		To:        service.Status,
+		Reason:    reason,
+		Timestamp: time.Now(),
+	}
+}
+
+func (sr *ServiceRegistry) publish(event *models.HealthEvent) {
+	if event == nil {
+		return
+	}
+
+	sr.mutex.RLock()
+	listeners := make([]EventListener, len(sr.listeners))
+	copy(listeners, sr.listeners)
+	sr.mutex.RUnlock()
+
+	for _, listener := range listeners {
+		listener(*event)
+	}
+}
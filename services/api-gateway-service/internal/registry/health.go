@@ -0,0 +1,440 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"gateway/internal/models"
+)
+
+// healthSchedulerTick is the resolution of per-service health check
+// intervals.
+const healthSchedulerTick = time.Second
+
+// defaultHealthConcurrency is the worker pool size when max_concurrency is
+// not configured; healthQueueSize bounds checks waiting for a worker.
+const (
+	defaultHealthConcurrency = 10
+	healthQueueSize          = 1024
+)
+
+// maxHealthBodySize caps how much of a health response is read when
+// matching health_expected_body.
+const maxHealthBodySize = 64 * 1024
+
+// StartHealthChecking probes every enabled service on its own
+// health_interval, falling back to defaultInterval when none is set. Probes
+// run on a fixed pool of max_concurrency workers.
+func (sr *ServiceRegistry) StartHealthChecking(defaultInterval time.Duration) {
+	sr.mutex.Lock()
+	if sr.isRunning {
+		sr.mutex.Unlock()
+		return
+	}
+	sr.isRunning = true
+	if defaultInterval > 0 {
+		sr.healthInterval = defaultInterval
+	}
+	workers := sr.healthConfig.MaxConcurrency
+	if workers < 1 {
+		workers = defaultHealthConcurrency
+	}
+	stop := sr.stopChan
+	sr.mutex.Unlock()
+
+	jobs := make(chan models.ServiceConfig, healthQueueSize)
+	for i := 0; i < workers; i++ {
+		go sr.healthWorker(jobs, stop)
+	}
+	go sr.healthCheckLoop(jobs, stop)
+}
+
+// SetHealthIntervalScale stretches every health check interval by scale,
+// letting an overloaded gateway probe less often. A scale of 1 restores
+// the configured intervals.
+func (sr *ServiceRegistry) SetHealthIntervalScale(scale float64) {
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+
+	if scale < 1 {
+		scale = 1
+	}
+	sr.healthScale = scale
+}
+
+func (sr *ServiceRegistry) StopHealthChecking() {
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+
+	if sr.isRunning {
+		close(sr.stopChan)
+		sr.isRunning = false
+		sr.stopChan = make(chan struct{})
+		// Queued probes are dropped with the workers
+		sr.checking = make(map[string]bool)
+	}
+}
+
+func (sr *ServiceRegistry) healthCheckLoop(jobs chan<- models.ServiceConfig, stop <-chan struct{}) {
+	ticker := time.NewTicker(healthSchedulerTick)
+	defer ticker.Stop()
+
+	// Initial health check
+	sr.scheduleDueHealthChecks(time.Now(), jobs)
+
+	for {
+		select {
+		case now := <-ticker.C:
+			sr.scheduleDueHealthChecks(now, jobs)
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (sr *ServiceRegistry) healthWorker(jobs <-chan models.ServiceConfig, stop <-chan struct{}) {
+	for {
+		select {
+		case service := <-jobs:
+			sr.checkServiceHealth(&service)
+
+			sr.mutex.Lock()
+			delete(sr.checking, service.Name)
+			sr.mutex.Unlock()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// scheduleDueHealthChecks queues a check for every service whose interval
+// has elapsed. A service is never queued again while its previous check is
+// pending, so slow services cannot pile up requests. Each service's next
+// check is jittered so services registered together drift apart instead of
+// probing in lockstep.
+func (sr *ServiceRegistry) scheduleDueHealthChecks(now time.Time, jobs chan<- models.ServiceConfig) {
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+
+	for name, service := range sr.services {
+		if !service.Enabled || sr.checking[name] {
+			continue
+		}
+
+		interval := service.HealthInterval
+		if interval <= 0 {
+			interval = sr.healthInterval
+		}
+		interval = time.Duration(float64(interval) * sr.healthScale)
+		spread := time.Duration(float64(interval) * sr.healthConfig.Jitter)
+
+		next, scheduled := sr.nextHealthCheck[name]
+		if !scheduled {
+			// Spread the first checks of services that appear together
+			next = now.Add(randomDuration(0, spread))
+			sr.nextHealthCheck[name] = next
+		}
+		if now.Before(next) {
+			continue
+		}
+
+		select {
+		case jobs <- *service:
+			sr.checking[name] = true
+			sr.nextHealthCheck[name] = now.Add(interval + randomDuration(-spread, spread))
+		default:
+			// Queue full: the service stays due and is retried next tick
+		}
+	}
+
+	// Forget schedules of services that have been removed
+	for name := range sr.nextHealthCheck {
+		if _, exists := sr.services[name]; !exists {
+			delete(sr.nextHealthCheck, name)
+		}
+	}
+}
+
+// randomDuration returns a uniformly random duration in [min, max].
+func randomDuration(min, max time.Duration) time.Duration {
+	if max <= min {
+		return min
+	}
+	return min + time.Duration(rand.Int63n(int64(max-min)+1))
+}
+
+func (sr *ServiceRegistry) checkServiceHealth(service *models.ServiceConfig) ProbeResult {
+	result := sr.probe(service)
+	status := models.ServiceUnhealthy
+	if result.Passed {
+		status = models.ServiceHealthy
+	}
+	sr.updateServiceStatus(service.Name, status, result.ResponseTime, result.detail)
+	return result
+}
+
+// ProbeResult is the outcome of one active health probe of a service.
+type ProbeResult struct {
+	Service    string    `json:"service"`
+	Method     string    `json:"method"`
+	URL        string    `json:"url"`
+	Passed     bool      `json:"passed"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	CheckedAt  time.Time `json:"checked_at"`
+	// ResponseTime is in milliseconds
+	ResponseTime float64 `json:"response_time"`
+
+	// detail describes the outcome for health events
+	detail string
+}
+
+// probe sends service its health request and judges the answer, without
+// recording anything.
+func (sr *ServiceRegistry) probe(service *models.ServiceConfig) ProbeResult {
+	start := time.Now()
+	result := ProbeResult{
+		Service:   service.Name,
+		Method:    service.HealthMethod,
+		URL:       service.URL + service.HealthPath,
+		CheckedAt: start,
+	}
+	if result.Method == "" {
+		result.Method = http.MethodGet
+	}
+	failed := func(err string) ProbeResult {
+		result.Error = err
+		result.detail = err
+		return result
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), service.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, result.Method, result.URL, nil)
+	if err != nil {
+		return failed(err.Error())
+	}
+
+	// Add any custom headers
+	for key, value := range service.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := sr.client.Do(req)
+	if err != nil {
+		result.ResponseTime = float64(time.Since(start).Nanoseconds()) / 1e6 // Convert to milliseconds
+		return failed(err.Error())
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxHealthBodySize))
+	result.ResponseTime = float64(time.Since(start).Nanoseconds()) / 1e6
+	result.StatusCode = resp.StatusCode
+	if err != nil && service.HealthExpectedBody != "" {
+		return failed("reading health response: " + err.Error())
+	}
+
+	result.detail = fmt.Sprintf("%s %s returned %d", result.Method, service.HealthPath, resp.StatusCode)
+	result.Passed = service.HealthCheckPassed(resp.StatusCode, body)
+	if !result.Passed {
+		result.Error = fmt.Sprintf("unexpected status %d", resp.StatusCode)
+		// With the expected body the status alone passes
+		if service.HealthCheckPassed(resp.StatusCode, []byte(service.HealthExpectedBody)) {
+			result.Error = fmt.Sprintf("response does not contain %q", service.HealthExpectedBody)
+		}
+	}
+	return result
+}
+
+// CheckHealth probes the service right away, whether or not it is
+// enabled, and records the result as scheduled checks do: rise and fall
+// thresholds still apply to its status. It returns false if no service
+// of that name is registered.
+func (sr *ServiceRegistry) CheckHealth(name string) (ProbeResult, bool) {
+	service, exists := sr.GetService(name)
+	if !exists {
+		return ProbeResult{}, false
+	}
+	return sr.checkServiceHealth(service), true
+}
+
+// SetHealthCheckConfig configures rise/fall thresholds, flap suppression
+// and how proxied traffic feeds service health.
+func (sr *ServiceRegistry) SetHealthCheckConfig(config models.HealthCheckConfig) {
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+
+	sr.healthConfig = config
+}
+
+// updateServiceStatus records an active probe result and recomputes the
+// service status from it and the passive signal. detail describes the
+// probe outcome for health events.
+func (sr *ServiceRegistry) updateServiceStatus(serviceName string, result models.ServiceStatus, responseTime float64, detail string) {
+	sr.mutex.Lock()
+	service, exists := sr.services[serviceName]
+	if !exists {
+		sr.mutex.Unlock()
+		return
+	}
+
+	now := time.Now()
+	previous := service.Status
+	state := sr.healthState(serviceName)
+	sr.recordProbe(serviceName, state, result, now)
+	sr.rollPassiveWindow(state, now)
+
+	service.UpdateStatus(state.active, responseTime)
+	service.Status = state.combined()
+	service.Flapping = state.flapping
+
+	reason := detail
+	switch {
+	case service.Status == models.ServiceDegraded:
+		reason = "live traffic failing"
+	case previous == models.ServiceDegraded:
+		reason = "live traffic recovered"
+	case state.flapping:
+		reason = detail + " (flapping)"
+	}
+	event := sr.statusChange(service, previous, reason)
+	sr.mutex.Unlock()
+
+	sr.publish(event)
+}
+
+// serviceHealth combines the debounced active probe status with passive
+// observations of proxied traffic in the current window.
+type serviceHealth struct {
+	active models.ServiceStatus
+
+	// Active probe streaks and flap detection
+	consecutiveSuccesses int
+	consecutiveFailures  int
+	passingSince         time.Time
+	transitions          []time.Time
+	flapping             bool
+
+	// Totals since the service was registered, for metrics
+	checks        int64
+	failedChecks  int64
+	statusChanges map[models.ServiceStatus]int64
+
+	// Passive observations in the current window
+	windowStart     time.Time
+	passiveRequests int
+	passiveFailures int
+	failing         bool
+}
+
+// combined reports the service status: an unhealthy probe always wins,
+// otherwise failing live traffic marks the service degraded.
+func (h *serviceHealth) combined() models.ServiceStatus {
+	if h.failing && h.active != models.ServiceUnhealthy {
+		return models.ServiceDegraded
+	}
+	return h.active
+}
+
+func (sr *ServiceRegistry) healthState(serviceName string) *serviceHealth {
+	state, exists := sr.health[serviceName]
+	if !exists {
+		state = &serviceHealth{
+			active:      models.ServiceUnknown,
+			windowStart: time.Now(),
+		}
+		sr.health[serviceName] = state
+	}
+	return state
+}
+
+// recordProbe applies a probe result to the active status. The first probe
+// decides immediately; after that the status only changes after rise
+// consecutive successes or fall consecutive failures. A service that
+// changes state flap_threshold times within flap_window is held unhealthy
+// until it has passed every probe for a whole window.
+func (sr *ServiceRegistry) recordProbe(serviceName string, state *serviceHealth, result models.ServiceStatus, now time.Time) {
+	config := sr.healthConfig
+
+	state.checks++
+	if result != models.ServiceHealthy {
+		state.failedChecks++
+	}
+	if result == models.ServiceHealthy {
+		if state.consecutiveSuccesses == 0 {
+			state.passingSince = now
+		}
+		state.consecutiveSuccesses++
+		state.consecutiveFailures = 0
+	} else {
+		state.consecutiveFailures++
+		state.consecutiveSuccesses = 0
+	}
+
+	next := state.active
+	switch {
+	case state.active == models.ServiceUnknown:
+		next = result
+	case result == models.ServiceHealthy && state.active != models.ServiceHealthy:
+		if state.consecutiveSuccesses < atLeastOne(config.Rise) {
+			break
+		}
+		if state.flapping && now.Sub(state.passingSince) < config.FlapWindow {
+			break
+		}
+		next = models.ServiceHealthy
+	case result != models.ServiceHealthy && state.active == models.ServiceHealthy:
+		if state.consecutiveFailures >= atLeastOne(config.Fall) {
+			next = models.ServiceUnhealthy
+		}
+	}
+
+	if next == state.active {
+		return
+	}
+
+	previous := state.active
+	state.active = next
+	if next == models.ServiceHealthy && state.flapping {
+		// Stable for a whole window: forget the flapping history
+		state.flapping = false
+		state.transitions = nil
+	}
+	if previous != models.ServiceUnknown {
+		sr.noteTransition(serviceName, state, now)
+	}
+}
+
+// noteTransition tracks recent state changes for flap detection.
+func (sr *ServiceRegistry) noteTransition(serviceName string, state *serviceHealth, now time.Time) {
+	config := sr.healthConfig
+	if config.FlapThreshold <= 0 {
+		return
+	}
+
+	recent := state.transitions[:0]
+	for _, at := range state.transitions {
+		if now.Sub(at) < config.FlapWindow {
+			recent = append(recent, at)
+		}
+	}
+	state.transitions = append(recent, now)
+
+	if len(state.transitions) >= config.FlapThreshold && !state.flapping {
+		state.flapping = true
+		log.Printf("Service %s is flapping, holding it unhealthy until stable for %s", serviceName, config.FlapWindow)
+	}
+}
+
+func atLeastOne(n int) int {
+	if n < 1 {
+		return 1
+	}
+	return n
+}
@@ -0,0 +1,56 @@
+package registry
+
+import (
+	"sort"
+	"time"
+
+	"gateway/internal/models"
+)
+
+// HealthCheckStats describes what the health checks of a service have seen,
+// for metrics. Counts are totals since the service was registered.
+type HealthCheckStats struct {
+	Service              string                         `json:"service"`
+	Status               models.ServiceStatus           `json:"status"`
+	LastChecked          *time.Time                     `json:"last_checked,omitempty"`
+	ResponseTime         float64                        `json:"response_time_ms"`
+	ConsecutiveFailures  int                            `json:"consecutive_failures"`
+	ConsecutiveSuccesses int                            `json:"consecutive_successes"`
+	Checks               int64                          `json:"checks"`
+	FailedChecks         int64                          `json:"failed_checks"`
+	Transitions          map[models.ServiceStatus]int64 `json:"transitions"`
+}
+
+// HealthChecks returns the health check stats of every service, by name.
+// Transitions counts status changes by the status the service moved to,
+// whether the change came from a probe or from proxied traffic.
+func (sr *ServiceRegistry) HealthChecks() []HealthCheckStats {
+	sr.mutex.RLock()
+	defer sr.mutex.RUnlock()
+
+	stats := make([]HealthCheckStats, 0, len(sr.services))
+	for name, service := range sr.services {
+		entry := HealthCheckStats{
+			Service:      name,
+			Status:       service.Status,
+			ResponseTime: service.ResponseTime,
+			Transitions:  make(map[models.ServiceStatus]int64),
+		}
+		if !service.LastChecked.IsZero() {
+			lastChecked := service.LastChecked
+			entry.LastChecked = &lastChecked
+		}
+		if state, exists := sr.health[name]; exists {
+			entry.ConsecutiveFailures = state.consecutiveFailures
+			entry.ConsecutiveSuccesses = state.consecutiveSuccesses
+			entry.Checks = state.checks
+			entry.FailedChecks = state.failedChecks
+			for status, count := range state.statusChanges {
+				entry.Transitions[status] = count
+			}
+		}
+		stats = append(stats, entry)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Service < stats[j].Service })
+	return stats
+}
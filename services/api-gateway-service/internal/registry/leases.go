@@ -0,0 +1,141 @@
+package registry
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"gateway/internal/models"
+)
+
+// Lease tracks a service that registered itself through the registration
+// API and must keep heartbeating to stay in the registry.
+type Lease struct {
+	ServiceName  string               `json:"service_name"`
+	TTL          time.Duration        `json:"ttl"`
+	RegisteredAt time.Time            `json:"registered_at"`
+	ExpiresAt    time.Time            `json:"expires_at"`
+	Routes       []models.RouteConfig `json:"routes,omitempty"`
+}
+
+// RegisterWithLease registers a service together with its routes and keeps
+// them only as long as heartbeats arrive within ttl. Registering an existing
+// lease replaces its service and routes.
+func (sr *ServiceRegistry) RegisterWithLease(service models.ServiceConfig, routes []models.RouteConfig, ttl time.Duration) Lease {
+	sr.mutex.Lock()
+	previous, renewed := sr.leases[service.Name]
+	sr.mutex.Unlock()
+
+	if renewed {
+		for _, route := range previous.Routes {
+			sr.RemoveRoute(route.Path, route.ServiceName)
+		}
+	}
+
+	sr.RegisterService(service)
+	for _, route := range routes {
+		sr.RegisterRoute(route)
+	}
+
+	now := time.Now()
+	lease := &Lease{
+		ServiceName:  service.Name,
+		TTL:          ttl,
+		RegisteredAt: now,
+		ExpiresAt:    now.Add(ttl),
+		Routes:       routes,
+	}
+
+	sr.mutex.Lock()
+	sr.leases[service.Name] = lease
+	sr.mutex.Unlock()
+
+	return *lease
+}
+
+// Heartbeat extends a lease by its TTL.
+func (sr *ServiceRegistry) Heartbeat(serviceName string) (Lease, bool) {
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+
+	lease, exists := sr.leases[serviceName]
+	if !exists {
+		return Lease{}, false
+	}
+	lease.ExpiresAt = time.Now().Add(lease.TTL)
+	return *lease, true
+}
+
+// Deregister removes a leased service and its routes immediately.
+func (sr *ServiceRegistry) Deregister(serviceName string) bool {
+	sr.mutex.Lock()
+	lease, exists := sr.leases[serviceName]
+	delete(sr.leases, serviceName)
+	sr.mutex.Unlock()
+
+	if !exists {
+		return false
+	}
+
+	for _, route := range lease.Routes {
+		sr.RemoveRoute(route.Path, route.ServiceName)
+	}
+	sr.RemoveService(serviceName)
+	return true
+}
+
+func (sr *ServiceRegistry) HasLease(serviceName string) bool {
+	sr.mutex.RLock()
+	defer sr.mutex.RUnlock()
+
+	_, exists := sr.leases[serviceName]
+	return exists
+}
+
+func (sr *ServiceRegistry) GetLeases() []Lease {
+	sr.mutex.RLock()
+	defer sr.mutex.RUnlock()
+
+	result := make([]Lease, 0, len(sr.leases))
+	for _, lease := range sr.leases {
+		result = append(result, *lease)
+	}
+	return result
+}
+
+// ExpireLeases deregisters every service whose lease has lapsed and returns
+// their names.
+func (sr *ServiceRegistry) ExpireLeases() []string {
+	now := time.Now()
+
+	sr.mutex.RLock()
+	expired := make([]string, 0)
+	for name, lease := range sr.leases {
+		if now.After(lease.ExpiresAt) {
+			expired = append(expired, name)
+		}
+	}
+	sr.mutex.RUnlock()
+
+	for _, name := range expired {
+		if sr.Deregister(name) {
+			log.Printf("Lease expired, removed service: %s", name)
+		}
+	}
+	return expired
+}
+
+// RunLeaseReaper expires lapsed leases every interval until ctx is done.
+func (sr *ServiceRegistry) RunLeaseReaper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			sr.ExpireLeases()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
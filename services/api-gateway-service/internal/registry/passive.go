@@ -0,0 +1,70 @@
+package registry
+
+import (
+	"fmt"
+	"time"
+)
+
+// RecordProxyResult feeds the outcome of a proxied request into the
+// service's passive health. failed should be true for 5xx responses,
+// timeouts and connection errors.
+func (sr *ServiceRegistry) RecordProxyResult(serviceName string, failed bool) {
+	sr.mutex.Lock()
+	if !sr.healthConfig.Passive.Enabled {
+		sr.mutex.Unlock()
+		return
+	}
+	service, exists := sr.services[serviceName]
+	if !exists {
+		sr.mutex.Unlock()
+		return
+	}
+
+	previous := service.Status
+	state := sr.healthState(serviceName)
+	sr.rollPassiveWindow(state, time.Now())
+	state.passiveRequests++
+	if failed {
+		state.passiveFailures++
+	}
+	if sr.passiveThresholdReached(state) {
+		state.failing = true
+	}
+
+	service.Status = state.combined()
+	reason := "live traffic recovered"
+	if state.failing {
+		reason = fmt.Sprintf("%d of %d proxied requests failed", state.passiveFailures, state.passiveRequests)
+	}
+	event := sr.statusChange(service, previous, reason)
+	sr.mutex.Unlock()
+
+	sr.publish(event)
+}
+
+// rollPassiveWindow starts a new observation window once the current one
+// has elapsed. The finished window decides whether the service is still
+// failing; a window without enough traffic clears the passive signal.
+func (sr *ServiceRegistry) rollPassiveWindow(state *serviceHealth, now time.Time) {
+	if !sr.healthConfig.Passive.Enabled {
+		state.failing = false
+		return
+	}
+
+	elapsed := now.Sub(state.windowStart)
+	if elapsed < sr.healthConfig.Passive.Window {
+		return
+	}
+
+	state.failing = elapsed < 2*sr.healthConfig.Passive.Window && sr.passiveThresholdReached(state)
+	state.windowStart = now
+	state.passiveRequests = 0
+	state.passiveFailures = 0
+}
+
+func (sr *ServiceRegistry) passiveThresholdReached(state *serviceHealth) bool {
+	if state.passiveRequests < sr.healthConfig.Passive.MinRequests {
+		return false
+	}
+	return float64(state.passiveFailures)/float64(state.passiveRequests) >= sr.healthConfig.Passive.FailureRatio
+}
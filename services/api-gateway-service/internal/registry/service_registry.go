@@ -1,9 +1,10 @@
 package registry
 
 import (
-	"context"
 	"fmt"
 	"net/http"
+	"reflect"
+	"strings"
 	"sync"
 	"time"
 
@@ -13,20 +14,52 @@ import (
 type ServiceRegistry struct {
 	services  map[string]*models.ServiceConfig
 	routes    []*models.RouteConfig
+	leases    map[string]*Lease
 	mutex     sync.RWMutex
 	client    *http.Client
 	stopChan  chan struct{}
 	isRunning bool
+
+	// Health check scheduling, keyed by service name
+	healthInterval  time.Duration
+	healthScale     float64
+	nextHealthCheck map[string]time.Time
+	checking        map[string]bool
+
+	// Active and passive health signals, keyed by service name
+	healthConfig models.HealthCheckConfig
+	health       map[string]*serviceHealth
+	sla          map[string]*slaWindow
+	listeners    []EventListener
+
+	// Canary comparisons, keyed by route path and canary service
+	canaries map[string]*canaryState
+
+	// Requests in flight and services held back from new ones, keyed by
+	// service name
+	trafficMutex sync.Mutex
+	inFlight     map[string]int
+	holds        map[string]*trafficHold
 }
 
 func NewServiceRegistry() *ServiceRegistry {
 	return &ServiceRegistry{
 		services: make(map[string]*models.ServiceConfig),
 		routes:   make([]*models.RouteConfig, 0),
+		leases:   make(map[string]*Lease),
 		client: &http.Client{
 			Timeout: 10 * time.Second,
 		},
-		stopChan: make(chan struct{}),
+		stopChan:        make(chan struct{}),
+		healthInterval:  30 * time.Second,
+		healthScale:     1,
+		nextHealthCheck: make(map[string]time.Time),
+		checking:        make(map[string]bool),
+		health:          make(map[string]*serviceHealth),
+		sla:             make(map[string]*slaWindow),
+		canaries:        make(map[string]*canaryState),
+		inFlight:        make(map[string]int),
+		holds:           make(map[string]*trafficHold),
 	}
 }
 
@@ -34,7 +67,19 @@ func (sr *ServiceRegistry) RegisterService(config models.ServiceConfig) {
 	sr.mutex.Lock()
 	defer sr.mutex.Unlock()
 
-	// Create a copy to avoid race conditions
+	sr.services[config.Name] = newServiceEntry(config)
+}
+
+func (sr *ServiceRegistry) RegisterRoute(config models.RouteConfig) {
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+
+	sr.routes = append(sr.routes, newRouteEntry(config))
+}
+
+// newServiceEntry copies config, to avoid race conditions, with defaults
+// filled in.
+func newServiceEntry(config models.ServiceConfig) *models.ServiceConfig {
 	serviceCopy := config
 	if serviceCopy.Headers == nil {
 		serviceCopy.Headers = make(map[string]string)
@@ -42,18 +87,18 @@ func (sr *ServiceRegistry) RegisterService(config models.ServiceConfig) {
 	if serviceCopy.HealthPath == "" {
 		serviceCopy.HealthPath = "/health"
 	}
+	if serviceCopy.HealthMethod == "" {
+		serviceCopy.HealthMethod = http.MethodGet
+	}
 	if serviceCopy.Status == "" {
 		serviceCopy.Status = models.ServiceUnknown
 	}
-
-	sr.services[config.Name] = &serviceCopy
+	return &serviceCopy
 }
 
-func (sr *ServiceRegistry) RegisterRoute(config models.RouteConfig) {
-	sr.mutex.Lock()
-	defer sr.mutex.Unlock()
-
-	// Create a copy to avoid race conditions
+// newRouteEntry copies config, to avoid race conditions, with defaults
+// filled in.
+func newRouteEntry(config models.RouteConfig) *models.RouteConfig {
 	routeCopy := config
 	if routeCopy.Headers == nil {
 		routeCopy.Headers = make(map[string]string)
@@ -61,8 +106,7 @@ func (sr *ServiceRegistry) RegisterRoute(config models.RouteConfig) {
 	if routeCopy.Method == "" {
 		routeCopy.Method = "*"
 	}
-
-	sr.routes = append(sr.routes, &routeCopy)
+	return &routeCopy
 }
 
 func (sr *ServiceRegistry) GetService(name string) (*models.ServiceConfig, bool) {
@@ -101,140 +145,152 @@ func (sr *ServiceRegistry) GetRoutes() []models.RouteConfig {
 	return result
 }
 
+// FindRoute returns copies of the first route matching the request and its
+// service. Composite routes have no service of their own and are returned
+// with a nil service. Routes of strict services only match exactly.
 func (sr *ServiceRegistry) FindRoute(method, path string) (*models.RouteConfig, *models.ServiceConfig) {
-	sr.mutex.RLock()
-	defer sr.mutex.RUnlock()
+	return sr.findRoute(method, path, nil)
+}
 
-	// Find matching route
-	for _, route := range sr.routes {
-		if route.Matches(method, path) {
-			// Get the associated service
-			if service, exists := sr.services[route.ServiceName]; exists && service.Enabled {
-				return route, service
-			}
-		}
-	}
+// SkippedRoute is a route whose path covers a request that went
+// elsewhere, and why.
+type SkippedRoute struct {
+	Method  string `json:"method"`
+	Path    string `json:"path"`
+	Service string `json:"service,omitempty"`
+	Reason  string `json:"reason"`
+}
 
-	return nil, nil
+// ResolveRoute finds the route of a request as FindRoute does, and also
+// lists the routes ahead of it, or all of them when none matched, whose
+// path covers the request but that were passed over.
+func (sr *ServiceRegistry) ResolveRoute(method, path string) (*models.RouteConfig, *models.ServiceConfig, []SkippedRoute) {
+	skipped := make([]SkippedRoute, 0)
+	route, service := sr.findRoute(method, path, func(route *models.RouteConfig, reason string) {
+		skipped = append(skipped, SkippedRoute{Method: route.Method, Path: route.Path, Service: route.ServiceName, Reason: reason})
+	})
+	return route, service, skipped
 }
 
-func (sr *ServiceRegistry) GetHealthyServices() map[string]models.ServiceConfig {
+// findRoute returns copies of the first route matching the request and
+// its service. skip, when set, is told about the routes passed over whose
+// path covers the request.
+func (sr *ServiceRegistry) findRoute(method, path string, skip func(route *models.RouteConfig, reason string)) (*models.RouteConfig, *models.ServiceConfig) {
 	sr.mutex.RLock()
 	defer sr.mutex.RUnlock()
 
-	result := make(map[string]models.ServiceConfig)
-	for name, service := range sr.services {
-		if service.IsHealthy() {
-			result[name] = *service
+	for _, route := range sr.routes {
+		if route.IsComposite() {
+			if route.Matches(method, path) {
+				routeCopy := *route
+				return &routeCopy, nil
+			}
+			continue
 		}
-	}
-	return result
-}
 
-func (sr *ServiceRegistry) StartHealthChecking(interval time.Duration) {
-	sr.mutex.Lock()
-	if sr.isRunning {
-		sr.mutex.Unlock()
-		return
+		service, exists := sr.services[route.ServiceName]
+		var reason string
+		switch {
+		case !exists:
+			reason = "service " + route.ServiceName + " is not registered"
+		case !service.Enabled:
+			reason = "service " + route.ServiceName + " is disabled"
+		case service.StrictRoutes:
+			if !route.MatchesExactly(method, path) {
+				reason = "service " + route.ServiceName + " has strict routes, which match method and path exactly"
+			}
+		case !route.Matches(method, path):
+			reason = "method " + method + " is not " + route.Method
+		}
+		if reason != "" {
+			// Migration aliases are resolved before routes
+			if skip != nil && !route.IsMigration() && coversPath(route, path) {
+				skip(route, reason)
+			}
+			continue
+		}
+
+		// Return copies to avoid race conditions
+		routeCopy := *route
+		serviceCopy := *service
+		return &routeCopy, &serviceCopy
 	}
-	sr.isRunning = true
-	sr.mutex.Unlock()
 
-	go sr.healthCheckLoop(interval)
+	return nil, nil
 }
 
-func (sr *ServiceRegistry) StopHealthChecking() {
-	sr.mutex.Lock()
-	defer sr.mutex.Unlock()
-
-	if sr.isRunning {
-		close(sr.stopChan)
-		sr.isRunning = false
-		sr.stopChan = make(chan struct{})
-	}
+// coversPath tells whether route's path matches path for some method.
+func coversPath(route *models.RouteConfig, path string) bool {
+	anyMethod := *route
+	anyMethod.Method = "*"
+	return anyMethod.Matches(http.MethodGet, path)
 }
 
-func (sr *ServiceRegistry) healthCheckLoop(interval time.Duration) {
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
-
-	// Initial health check
-	sr.performHealthChecks()
+// RouteConflicts lists the routes that overlap route: those it would take
+// requests from, or that would take its requests, for a method they
+// share. Routes of different sources are not ordered against each other,
+// so either way one of them would stop seeing requests it expects. The
+// route except, such as one being replaced, is left out.
+func (sr *ServiceRegistry) RouteConflicts(route models.RouteConfig, except *models.RouteConfig) []models.RouteConfig {
+	sr.mutex.RLock()
+	defer sr.mutex.RUnlock()
 
-	for {
-		select {
-		case <-ticker.C:
-			sr.performHealthChecks()
-		case <-sr.stopChan:
-			return
+	var conflicts []models.RouteConfig
+	for _, existing := range sr.routes {
+		if except != nil && existing.Method == except.Method && existing.Path == except.Path && existing.ServiceName == except.ServiceName {
+			continue
 		}
-	}
-}
-
-func (sr *ServiceRegistry) performHealthChecks() {
-	sr.mutex.RLock()
-	services := make([]*models.ServiceConfig, 0, len(sr.services))
-	for _, service := range sr.services {
-		if service.Enabled {
-			services = append(services, service)
+		if RoutesOverlap(route, *existing) {
+			conflicts = append(conflicts, *existing)
 		}
 	}
-	sr.mutex.RUnlock()
-
-	// Perform health checks concurrently
-	var wg sync.WaitGroup
-	for _, service := range services {
-		wg.Add(1)
-		go func(svc *models.ServiceConfig) {
-			defer wg.Done()
-			sr.checkServiceHealth(svc)
-		}(service)
-	}
-	wg.Wait()
+	return conflicts
 }
 
-func (sr *ServiceRegistry) checkServiceHealth(service *models.ServiceConfig) {
-	start := time.Now()
-	healthURL := service.URL + service.HealthPath
-
-	ctx, cancel := context.WithTimeout(context.Background(), service.Timeout)
-	defer cancel()
+// RoutesOverlap tells whether a request could match both a and b.
+func RoutesOverlap(a, b models.RouteConfig) bool {
+	return shadows(a, b) || shadows(b, a)
+}
 
-	req, err := http.NewRequestWithContext(ctx, "GET", healthURL, nil)
-	if err != nil {
-		sr.updateServiceStatus(service.Name, models.ServiceUnhealthy, 0)
-		return
+// shadows tells whether a matches every request b matches for some method.
+func shadows(a, b models.RouteConfig) bool {
+	if a.Method != "*" && b.Method != "*" && a.Method != b.Method {
+		return false
 	}
-
-	// Add any custom headers
-	for key, value := range service.Headers {
-		req.Header.Set(key, value)
+	// As Matches does, a trailing /* is left out of the prefix
+	prefix := a.Path
+	if len(prefix) > 2 && strings.HasSuffix(prefix, "/*") {
+		prefix = prefix[:len(prefix)-2]
 	}
+	return strings.HasPrefix(b.Path, prefix)
+}
 
-	resp, err := sr.client.Do(req)
-	responseTime := float64(time.Since(start).Nanoseconds()) / 1e6 // Convert to milliseconds
-
-	if err != nil {
-		sr.updateServiceStatus(service.Name, models.ServiceUnhealthy, responseTime)
-		return
-	}
-	defer resp.Body.Close()
+// FindMigration returns a copy of the first migration route matching the
+// request.
+func (sr *ServiceRegistry) FindMigration(method, path string) *models.RouteConfig {
+	sr.mutex.RLock()
+	defer sr.mutex.RUnlock()
 
-	// Consider 2xx status codes as healthy
-	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		sr.updateServiceStatus(service.Name, models.ServiceHealthy, responseTime)
-	} else {
-		sr.updateServiceStatus(service.Name, models.ServiceUnhealthy, responseTime)
+	for _, route := range sr.routes {
+		if route.IsMigration() && route.Matches(method, path) {
+			routeCopy := *route
+			return &routeCopy
+		}
 	}
+	return nil
 }
 
-func (sr *ServiceRegistry) updateServiceStatus(serviceName string, status models.ServiceStatus, responseTime float64) {
-	sr.mutex.Lock()
-	defer sr.mutex.Unlock()
+func (sr *ServiceRegistry) GetHealthyServices() map[string]models.ServiceConfig {
+	sr.mutex.RLock()
+	defer sr.mutex.RUnlock()
 
-	if service, exists := sr.services[serviceName]; exists {
-		service.UpdateStatus(status, responseTime)
+	result := make(map[string]models.ServiceConfig)
+	for name, service := range sr.services {
+		if service.IsHealthy() {
+			result[name] = *service
+		}
 	}
+	return result
 }
 
 func (sr *ServiceRegistry) RemoveService(name string) {
@@ -242,6 +298,9 @@ func (sr *ServiceRegistry) RemoveService(name string) {
 	defer sr.mutex.Unlock()
 
 	delete(sr.services, name)
+	delete(sr.health, name)
+	delete(sr.sla, name)
+	sr.releaseHold(name)
 }
 
 func (sr *ServiceRegistry) RemoveRoute(path, serviceName string) {
@@ -258,13 +317,108 @@ func (sr *ServiceRegistry) RemoveRoute(path, serviceName string) {
 	}
 }
 
+// ReplaceRouting swaps the services and routes of one source, such as the
+// config file, from the previous set to the current one in a single step,
+// so no request sees a half-applied table. Entries of other sources are
+// kept, and the current routes take the place of the previous ones ahead
+// of them. Changed services keep their health unless their URL changed.
+// Requests already routed carry on with the copies they were given. If
+// the resulting table references unknown services nothing is changed.
+func (sr *ServiceRegistry) ReplaceRouting(previousServices, currentServices map[string]models.ServiceConfig, previousRoutes, currentRoutes []models.RouteConfig) error {
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+
+	services, routes, reset := sr.replacedRouting(previousServices, currentServices, previousRoutes, currentRoutes)
+	if err := validateRouting(services, routes); err != nil {
+		return err
+	}
+
+	for name := range sr.services {
+		if _, exists := services[name]; !exists {
+			reset = append(reset, name)
+			sr.releaseHold(name)
+		}
+	}
+	for _, name := range reset {
+		delete(sr.health, name)
+		delete(sr.sla, name)
+		delete(sr.nextHealthCheck, name)
+	}
+	sr.services = services
+	sr.routes = routes
+	return nil
+}
+
+// CheckRouting tells whether ReplaceRouting would accept the change,
+// without making it.
+func (sr *ServiceRegistry) CheckRouting(previousServices, currentServices map[string]models.ServiceConfig, previousRoutes, currentRoutes []models.RouteConfig) error {
+	sr.mutex.RLock()
+	defer sr.mutex.RUnlock()
+
+	services, routes, _ := sr.replacedRouting(previousServices, currentServices, previousRoutes, currentRoutes)
+	return validateRouting(services, routes)
+}
+
+// replacedRouting builds the table ReplaceRouting would swap in, and the
+// services whose health starts over. The caller holds the lock.
+func (sr *ServiceRegistry) replacedRouting(previousServices, currentServices map[string]models.ServiceConfig, previousRoutes, currentRoutes []models.RouteConfig) (map[string]*models.ServiceConfig, []*models.RouteConfig, []string) {
+	services := make(map[string]*models.ServiceConfig, len(sr.services))
+	for name, service := range sr.services {
+		if _, owned := previousServices[name]; !owned {
+			services[name] = service
+		}
+	}
+	var reset []string
+	for name, config := range currentServices {
+		existing, exists := sr.services[name]
+		if previous, owned := previousServices[name]; exists && owned && reflect.DeepEqual(previous, config) {
+			services[name] = existing
+			continue
+		}
+
+		config.Name = name
+		service := newServiceEntry(config)
+		if exists && existing.URL == service.URL {
+			service.Status = existing.Status
+			service.LastChecked = existing.LastChecked
+			service.ResponseTime = existing.ResponseTime
+			service.Flapping = existing.Flapping
+			service.SLAViolationRate = existing.SLAViolationRate
+			service.SLABreached = existing.SLABreached
+		} else {
+			service.Status = models.ServiceUnknown
+			reset = append(reset, name)
+		}
+		services[name] = service
+	}
+
+	routes := make([]*models.RouteConfig, 0, len(sr.routes)+len(currentRoutes))
+	for _, config := range currentRoutes {
+		routes = append(routes, newRouteEntry(config))
+	}
+	owned := make(map[string]int, len(previousRoutes))
+	for _, route := range previousRoutes {
+		owned[route.Path+" "+route.ServiceName]++
+	}
+	for _, route := range sr.routes {
+		if key := route.Path + " " + route.ServiceName; owned[key] > 0 {
+			owned[key]--
+			continue
+		}
+		routes = append(routes, route)
+	}
+	return services, routes, reset
+}
+
 func (sr *ServiceRegistry) GetServiceStats() map[string]interface{} {
 	sr.mutex.RLock()
 	defer sr.mutex.RUnlock()
 
 	healthy := 0
 	unhealthy := 0
+	degraded := 0
 	unknown := 0
+	slaBreached := 0
 	total := len(sr.services)
 
 	for _, service := range sr.services {
@@ -273,30 +427,60 @@ func (sr *ServiceRegistry) GetServiceStats() map[string]interface{} {
 			healthy++
 		case models.ServiceUnhealthy:
 			unhealthy++
+		case models.ServiceDegraded:
+			degraded++
 		case models.ServiceUnknown:
 			unknown++
 		}
+		if service.SLABreached {
+			slaBreached++
+		}
 	}
 
 	return map[string]interface{}{
-		"total":     total,
-		"healthy":   healthy,
-		"unhealthy": unhealthy,
-		"unknown":   unknown,
-		"routes":    len(sr.routes),
+		"total":        total,
+		"healthy":      healthy,
+		"unhealthy":    unhealthy,
+		"degraded":     degraded,
+		"unknown":      unknown,
+		"sla_breached": slaBreached,
+		"routes":       len(sr.routes),
 	}
 }
 
 func (sr *ServiceRegistry) ValidateConfiguration() error {
 	sr.mutex.RLock()
 	defer sr.mutex.RUnlock()
+	return validateRouting(sr.services, sr.routes)
+}
 
-	// Check that all routes reference existing services
-	for i, route := range sr.routes {
-		if _, exists := sr.services[route.ServiceName]; !exists {
+// validateRouting checks that all routes reference existing services.
+func validateRouting(services map[string]*models.ServiceConfig, routes []*models.RouteConfig) error {
+	for i, route := range routes {
+		if route.IsMigration() {
+			continue
+		}
+		if route.IsComposite() {
+			for _, leg := range route.Composite.Legs {
+				if _, exists := services[leg.ServiceName]; !exists {
+					return fmt.Errorf("route %d leg %s references non-existent service: %s", i, leg.Name, leg.ServiceName)
+				}
+			}
+			continue
+		}
+		service, exists := services[route.ServiceName]
+		if !exists {
 			return fmt.Errorf("route %d references non-existent service: %s", i, route.ServiceName)
 		}
+		if service.StrictRoutes && (route.Method == "*" || route.IsWildcard()) {
+			return fmt.Errorf("route %d to strict service %s needs a method and an exact path", i, route.ServiceName)
+		}
+		if route.Canary != nil {
+			if _, exists := services[route.Canary.ServiceName]; !exists {
+				return fmt.Errorf("route %d canary references non-existent service: %s", i, route.Canary.ServiceName)
+			}
+		}
 	}
 
 	return nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,97 @@
+package registry
+
+import (
+	"fmt"
+	"time"
+
+	"gateway/internal/models"
+)
+
+// slaWindow counts responses slower than a service's latency SLA within
+// the current observation window.
+type slaWindow struct {
+	start      time.Time
+	requests   int
+	violations int
+}
+
+func (w *slaWindow) rate() float64 {
+	if w.requests == 0 {
+		return 0
+	}
+	return float64(w.violations) / float64(w.requests)
+}
+
+// RecordLatency feeds the latency of a proxied response into the service's
+// SLA tracking and reports whether it exceeded the service's latency_sla.
+// Services without an SLA are ignored.
+func (sr *ServiceRegistry) RecordLatency(serviceName string, latency time.Duration) bool {
+	sr.mutex.Lock()
+	service, exists := sr.services[serviceName]
+	if !exists || service.LatencySLA <= 0 {
+		sr.mutex.Unlock()
+		return false
+	}
+
+	now := time.Now()
+	window, tracked := sr.sla[serviceName]
+	if !tracked {
+		window = &slaWindow{start: now}
+		sr.sla[serviceName] = window
+	}
+	event := sr.rollSLAWindow(service, window, now)
+
+	exceeded := latency > service.LatencySLA
+	window.requests++
+	if exceeded {
+		window.violations++
+	}
+	service.SLAViolationRate = window.rate()
+
+	if !service.SLABreached && sr.slaThresholdReached(window) {
+		service.SLABreached = true
+		event = sr.slaEvent(service, models.EventSLABreached, fmt.Sprintf("%d of %d responses slower than %s", window.violations, window.requests, service.LatencySLA))
+	}
+	sr.mutex.Unlock()
+
+	sr.publish(event)
+	return exceeded
+}
+
+// rollSLAWindow starts a new window once the current one has elapsed. A
+// breached service recovers when the finished window stayed below the alert
+// ratio, or when it saw no traffic for a whole window.
+func (sr *ServiceRegistry) rollSLAWindow(service *models.ServiceConfig, window *slaWindow, now time.Time) *models.HealthEvent {
+	elapsed := now.Sub(window.start)
+	if elapsed < sr.healthConfig.SLA.Window {
+		return nil
+	}
+
+	var event *models.HealthEvent
+	if service.SLABreached && (elapsed >= 2*sr.healthConfig.SLA.Window || !sr.slaThresholdReached(window)) {
+		service.SLABreached = false
+		event = sr.slaEvent(service, models.EventSLARecovered, fmt.Sprintf("%.0f%% of responses slower than %s", window.rate()*100, service.LatencySLA))
+	}
+
+	*window = slaWindow{start: now}
+	return event
+}
+
+// slaThresholdReached reports whether the window breaches the SLA. A zero
+// alert_ratio disables breach detection.
+func (sr *ServiceRegistry) slaThresholdReached(window *slaWindow) bool {
+	config := sr.healthConfig.SLA
+	if config.AlertRatio <= 0 || window.requests < config.MinRequests {
+		return false
+	}
+	return window.rate() >= config.AlertRatio
+}
+
+func (sr *ServiceRegistry) slaEvent(service *models.ServiceConfig, eventType models.HealthEventType, reason string) *models.HealthEvent {
+	return &models.HealthEvent{
+		Type:      eventType,
+		Service:   service.Name,
+		Reason:    reason,
+		Timestamp: time.Now(),
+	}
+}
@@ -0,0 +1,218 @@
+package registry
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+)
+
+// Traffic states of a service, as TrafficStatus reports them.
+const (
+	TrafficServing  = "serving"
+	TrafficDisabled = "disabled"
+	TrafficDraining = "draining"
+	TrafficDrained  = "drained"
+)
+
+// trafficHold keeps new requests away from a disabled or draining service
+// until it is enabled again. A draining service is idle once the requests
+// it had in flight are done; idle is closed then.
+type trafficHold struct {
+	draining  bool
+	since     time.Time
+	idleSince time.Time
+	idle      chan struct{}
+}
+
+// TrafficStatus describes whether a service takes new requests, for the
+// admin API.
+type TrafficStatus struct {
+	Service   string     `json:"service"`
+	State     string     `json:"state"`
+	InFlight  int        `json:"in_flight"`
+	Since     *time.Time `json:"since,omitempty"`
+	IdleSince *time.Time `json:"idle_since,omitempty"`
+}
+
+// Admit counts a request to serviceName in flight, unless the service was
+// disabled or is draining. done must be called once the request is over.
+func (sr *ServiceRegistry) Admit(serviceName string) (done func(), ok bool) {
+	sr.trafficMutex.Lock()
+	defer sr.trafficMutex.Unlock()
+
+	if _, held := sr.holds[serviceName]; held {
+		return nil, false
+	}
+	sr.inFlight[serviceName]++
+	return func() { sr.finish(serviceName) }, true
+}
+
+func (sr *ServiceRegistry) finish(serviceName string) {
+	sr.trafficMutex.Lock()
+	defer sr.trafficMutex.Unlock()
+
+	sr.inFlight[serviceName]--
+	if sr.inFlight[serviceName] > 0 {
+		return
+	}
+	delete(sr.inFlight, serviceName)
+	if hold, held := sr.holds[serviceName]; held && hold.draining && hold.idleSince.IsZero() {
+		sr.markIdle(hold)
+		log.Printf("Service %s drained after %s", serviceName, hold.idleSince.Sub(hold.since).Round(time.Millisecond))
+	}
+}
+
+// markIdle records that a draining service has nothing in flight. The
+// caller holds the traffic lock.
+func (sr *ServiceRegistry) markIdle(hold *trafficHold) {
+	hold.idleSince = time.Now()
+	close(hold.idle)
+}
+
+// DisableService stops sending new requests to the service right away.
+// Requests in flight are not cut off. It returns false if no service of
+// that name is registered.
+func (sr *ServiceRegistry) DisableService(name string) (TrafficStatus, bool) {
+	return sr.hold(name, false)
+}
+
+// DrainService stops sending new requests to the service and lets those
+// in flight complete; TrafficStatus and WaitIdle tell when they have. It
+// returns false if no service of that name is registered.
+func (sr *ServiceRegistry) DrainService(name string) (TrafficStatus, bool) {
+	return sr.hold(name, true)
+}
+
+func (sr *ServiceRegistry) hold(name string, draining bool) (TrafficStatus, bool) {
+	if _, exists := sr.GetService(name); !exists {
+		return TrafficStatus{}, false
+	}
+
+	sr.trafficMutex.Lock()
+	defer sr.trafficMutex.Unlock()
+
+	hold, held := sr.holds[name]
+	if !held {
+		hold = &trafficHold{since: time.Now(), idle: make(chan struct{})}
+		sr.holds[name] = hold
+	}
+	if draining && !hold.draining {
+		hold.draining = true
+		if sr.inFlight[name] == 0 && hold.idleSince.IsZero() {
+			sr.markIdle(hold)
+		}
+	} else if !draining {
+		hold.draining = false
+	}
+	return sr.trafficStatus(name), true
+}
+
+// EnableService sends new requests to a disabled or draining service
+// again. It returns false if no service of that name is registered.
+func (sr *ServiceRegistry) EnableService(name string) (TrafficStatus, bool) {
+	if _, exists := sr.GetService(name); !exists {
+		return TrafficStatus{}, false
+	}
+
+	sr.trafficMutex.Lock()
+	defer sr.trafficMutex.Unlock()
+
+	delete(sr.holds, name)
+	return sr.trafficStatus(name), true
+}
+
+// Traffic reports whether the service takes new requests and how many it
+// has in flight. It returns false if no service of that name is
+// registered.
+func (sr *ServiceRegistry) Traffic(name string) (TrafficStatus, bool) {
+	if _, exists := sr.GetService(name); !exists {
+		return TrafficStatus{}, false
+	}
+
+	sr.trafficMutex.Lock()
+	defer sr.trafficMutex.Unlock()
+
+	return sr.trafficStatus(name), true
+}
+
+// HeldServices reports the services that were disabled or are draining,
+// by name.
+func (sr *ServiceRegistry) HeldServices() []TrafficStatus {
+	sr.trafficMutex.Lock()
+	defer sr.trafficMutex.Unlock()
+
+	statuses := make([]TrafficStatus, 0, len(sr.holds))
+	for name := range sr.holds {
+		statuses = append(statuses, sr.trafficStatus(name))
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Service < statuses[j].Service })
+	return statuses
+}
+
+// WaitIdle waits until the draining service has no requests in flight, or
+// ctx ends. It returns whether the service is drained; services that are
+// not draining never are.
+func (sr *ServiceRegistry) WaitIdle(ctx context.Context, name string) bool {
+	sr.trafficMutex.Lock()
+	hold, held := sr.holds[name]
+	sr.trafficMutex.Unlock()
+	if !held {
+		return false
+	}
+
+	select {
+	case <-hold.idle:
+	case <-ctx.Done():
+		return false
+	}
+
+	// The service may have been enabled, or disabled, in the meantime
+	sr.trafficMutex.Lock()
+	defer sr.trafficMutex.Unlock()
+	return sr.holds[name] == hold && hold.draining
+}
+
+// held tells whether the service takes no new requests.
+func (sr *ServiceRegistry) held(name string) bool {
+	sr.trafficMutex.Lock()
+	defer sr.trafficMutex.Unlock()
+	_, held := sr.holds[name]
+	return held
+}
+
+// trafficStatus describes the service name. The caller holds the traffic
+// lock.
+func (sr *ServiceRegistry) trafficStatus(name string) TrafficStatus {
+	status := TrafficStatus{
+		Service:  name,
+		State:    TrafficServing,
+		InFlight: sr.inFlight[name],
+	}
+	hold, held := sr.holds[name]
+	if !held {
+		return status
+	}
+
+	since := hold.since
+	status.Since = &since
+	switch {
+	case !hold.draining:
+		status.State = TrafficDisabled
+	case hold.idleSince.IsZero():
+		status.State = TrafficDraining
+	default:
+		status.State = TrafficDrained
+		idleSince := hold.idleSince
+		status.IdleSince = &idleSince
+	}
+	return status
+}
+
+// releaseHold forgets that a removed service was disabled or draining, so
+// a service registered later under its name takes traffic.
+func (sr *ServiceRegistry) releaseHold(name string) {
+	sr.trafficMutex.Lock()
+	delete(sr.holds, name)
+	sr.trafficMutex.Unlock()
+}
@@ -0,0 +1,75 @@
+package secrets
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Resolve returns the secret a reference points at, so secrets stay out of
+// the configuration file:
+//
+//   - env:NAME or env://NAME reads the environment variable NAME
+//   - file:/path or file:///path reads a file, e.g. a mounted Docker or
+//     Kubernetes secret
+//   - vault://path#field reads a field of a secret in Vault, once one is
+//     set with UseVault
+//
+// References are resolved on use, so rotated secrets apply without a
+// restart.
+func Resolve(ref string) (string, error) {
+	return ResolveWith(ref, currentVault())
+}
+
+// ResolveWith is Resolve reading vault:// references from vault.
+func ResolveWith(ref string, vault *Vault) (string, error) {
+	scheme, target, found := strings.Cut(ref, "://")
+	if !found {
+		scheme, target, found = strings.Cut(ref, ":")
+	}
+	if !found || target == "" {
+		return "", fmt.Errorf("invalid secret reference %q", ref)
+	}
+
+	switch scheme {
+	case "env":
+		value, exists := os.LookupEnv(target)
+		if !exists || value == "" {
+			return "", fmt.Errorf("secret environment variable %s is not set", target)
+		}
+		return value, nil
+	case "file":
+		data, err := os.ReadFile(target)
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret file: %w", err)
+		}
+		value := strings.TrimRight(string(data), "\r\n")
+		if value == "" {
+			return "", fmt.Errorf("secret file %s is empty", target)
+		}
+		return value, nil
+	case "vault":
+		if vault == nil {
+			return "", fmt.Errorf("secret reference %q needs secrets.vault to be configured", ref)
+		}
+		path, field, found := strings.Cut(target, "#")
+		if !found || path == "" || field == "" {
+			return "", fmt.Errorf("vault secret reference %q needs a path and #field", ref)
+		}
+		return vault.Read(path, field)
+	default:
+		return "", fmt.Errorf("unsupported secret reference scheme %q", scheme)
+	}
+}
+
+// IsReference reports whether value is a secret reference written as a
+// URL: env://NAME, file:///path or vault://path#field. The config loader
+// replaces such values with the secret.
+func IsReference(value string) bool {
+	for _, scheme := range []string{"env://", "file://", "vault://"} {
+		if strings.HasPrefix(value, scheme) {
+			return true
+		}
+	}
+	return false
+}
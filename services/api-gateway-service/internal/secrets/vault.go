@@ -0,0 +1,146 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"gateway/internal/models"
+)
+
+// Vault reads secrets from HashiCorp Vault's KV secrets engine, version 1
+// or 2, over its HTTP API. Secrets are cached for the configured TTL, as
+// references resolved on use would otherwise ask Vault on every request.
+type Vault struct {
+	address   string
+	token     string
+	namespace string
+	cacheTTL  time.Duration
+	client    *http.Client
+
+	mutex sync.Mutex
+	cache map[string]cachedSecret
+}
+
+type cachedSecret struct {
+	data    map[string]interface{}
+	fetched time.Time
+}
+
+var (
+	vaultMutex sync.RWMutex
+	vault      *Vault
+)
+
+// UseVault sets the Vault that Resolve reads vault:// references from;
+// nil leaves them unresolvable.
+func UseVault(v *Vault) {
+	vaultMutex.Lock()
+	defer vaultMutex.Unlock()
+	vault = v
+}
+
+func currentVault() *Vault {
+	vaultMutex.RLock()
+	defer vaultMutex.RUnlock()
+	return vault
+}
+
+// NewVault returns a client for the Vault of config, or nil when no
+// address is configured.
+func NewVault(config models.VaultConfig) (*Vault, error) {
+	address := strings.TrimSuffix(config.Address, "/")
+	if address == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(address, "http://") && !strings.HasPrefix(address, "https://") {
+		return nil, fmt.Errorf("vault address must be an http or https URL: %s", address)
+	}
+	if config.Token == "" {
+		return nil, fmt.Errorf("vault token is not set")
+	}
+
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	return &Vault{
+		address:   address,
+		token:     config.Token,
+		namespace: config.Namespace,
+		cacheTTL:  config.CacheTTL,
+		client:    &http.Client{Timeout: timeout},
+		cache:     make(map[string]cachedSecret),
+	}, nil
+}
+
+// Read returns field of the secret at path, the API path below /v1/, such
+// as secret/data/gateway for a KV version 2 engine mounted at secret/.
+func (v *Vault) Read(path, field string) (string, error) {
+	path = strings.Trim(path, "/")
+	data, err := v.secret(path)
+	if err != nil {
+		return "", err
+	}
+	value, exists := data[field]
+	if !exists {
+		return "", fmt.Errorf("vault secret %s has no field %s", path, field)
+	}
+	secret, ok := value.(string)
+	if !ok || secret == "" {
+		return "", fmt.Errorf("vault secret %s field %s is not a non-empty string", path, field)
+	}
+	return secret, nil
+}
+
+func (v *Vault) secret(path string) (map[string]interface{}, error) {
+	v.mutex.Lock()
+	cached, exists := v.cache[path]
+	v.mutex.Unlock()
+	if exists && time.Since(cached.fetched) < v.cacheTTL {
+		return cached.data, nil
+	}
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, v.address+"/v1/"+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+	if v.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.namespace)
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("vault: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("vault: reading %s returned %d: %s", path, resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+
+	var response struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("vault: failed to decode %s: %w", path, err)
+	}
+	data := response.Data
+	// KV version 2 nests the secret under data, next to its metadata
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		if _, versioned := data["metadata"]; versioned {
+			data = nested
+		}
+	}
+
+	v.mutex.Lock()
+	v.cache[path] = cachedSecret{data: data, fetched: time.Now()}
+	v.mutex.Unlock()
+	return data, nil
+}
@@ -0,0 +1,105 @@
+package session
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+var ErrDecrypt = errors.New("session: unable to decrypt payload")
+
+// Cipher seals session payloads with AES-256-GCM.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewCipher accepts a base64-encoded 32-byte key.
+func NewCipher(encodedKey string) (*Cipher, error) {
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return nil, fmt.Errorf("session: key must be base64 encoded: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("session: key must be 32 bytes, got %d", len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+// GenerateKey returns a random base64-encoded key suitable for NewCipher.
+func GenerateKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+func (c *Cipher) Encrypt(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return c.aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func (c *Cipher) Decrypt(ciphertext []byte) ([]byte, error) {
+	nonceSize := c.aead.NonceSize()
+	if len(ciphertext) < nonceSize {
+		return nil, ErrDecrypt
+	}
+	plaintext, err := c.aead.Open(nil, ciphertext[:nonceSize], ciphertext[nonceSize:], nil)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+// Keyring supports key rotation: the first key seals new payloads and every
+// key is tried when opening, so sessions sealed with a retired key keep
+// working until they are re-sealed or expire.
+type Keyring struct {
+	ciphers []*Cipher
+}
+
+func NewKeyring(encodedKeys []string) (*Keyring, error) {
+	if len(encodedKeys) == 0 {
+		return nil, fmt.Errorf("session: at least one key is required")
+	}
+
+	ciphers := make([]*Cipher, 0, len(encodedKeys))
+	for i, encodedKey := range encodedKeys {
+		c, err := NewCipher(encodedKey)
+		if err != nil {
+			return nil, fmt.Errorf("session: key %d: %w", i, err)
+		}
+		ciphers = append(ciphers, c)
+	}
+	return &Keyring{ciphers: ciphers}, nil
+}
+
+func (k *Keyring) Encrypt(plaintext []byte) ([]byte, error) {
+	return k.ciphers[0].Encrypt(plaintext)
+}
+
+// Decrypt reports whether the payload was sealed with the current primary
+// key so callers can re-seal payloads that still use a retired key.
+func (k *Keyring) Decrypt(ciphertext []byte) ([]byte, bool, error) {
+	for i, c := range k.ciphers {
+		if plaintext, err := c.Decrypt(ciphertext); err == nil {
+			return plaintext, i == 0, nil
+		}
+	}
+	return nil, false, ErrDecrypt
+}
@@ -0,0 +1,79 @@
+package session
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+type CookieOptions struct {
+	Name     string
+	Domain   string
+	Path     string
+	Secure   bool
+	SameSite http.SameSite
+}
+
+func (o CookieOptions) Write(w http.ResponseWriter, value string, expiresAt time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     o.Name,
+		Value:    value,
+		Domain:   o.Domain,
+		Path:     o.cookiePath(),
+		Expires:  expiresAt,
+		MaxAge:   int(time.Until(expiresAt).Seconds()),
+		Secure:   o.Secure,
+		HttpOnly: true,
+		SameSite: o.SameSite,
+	})
+}
+
+func (o CookieOptions) Clear(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     o.Name,
+		Value:    "",
+		Domain:   o.Domain,
+		Path:     o.cookiePath(),
+		MaxAge:   -1,
+		Secure:   o.Secure,
+		HttpOnly: true,
+		SameSite: o.SameSite,
+	})
+}
+
+func (o CookieOptions) Read(r *http.Request) string {
+	cookie, err := r.Cookie(o.Name)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+// Strip removes the session cookie from the request so it is never
+// forwarded to upstream services.
+func (o CookieOptions) Strip(r *http.Request) {
+	cookies := r.Cookies()
+	if len(cookies) == 0 {
+		return
+	}
+
+	kept := make([]string, 0, len(cookies))
+	for _, cookie := range cookies {
+		if cookie.Name != o.Name {
+			kept = append(kept, cookie.Name+"="+cookie.Value)
+		}
+	}
+
+	if len(kept) == 0 {
+		r.Header.Del("Cookie")
+	} else {
+		r.Header.Set("Cookie", strings.Join(kept, "; "))
+	}
+}
+
+func (o CookieOptions) cookiePath() string {
+	if o.Path == "" {
+		return "/"
+	}
+	return o.Path
+}
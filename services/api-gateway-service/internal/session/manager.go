@@ -0,0 +1,338 @@
+package session
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrNoUserLimit is returned when a per-user limit is set for sessions kept
+// in cookies, which cannot be counted.
+var ErrNoUserLimit = errors.New("per-user session limits need a server-side store")
+
+// Manager ties a session transport (the cookie) to a persistence mode:
+//
+//   - with a Store, the cookie carries only the session ID and the sealed
+//     session lives server-side (memory or Redis)
+//   - without a Store, the sealed session is the cookie value itself
+//
+// Cookie-mode sessions cannot be enumerated across replicas, so listing and
+// invalidation only cover sessions issued by this process.
+type Manager struct {
+	store  Store
+	keys   *Keyring
+	cookie CookieOptions
+	ttl    time.Duration
+
+	// maxPerUser caps concurrent sessions per user; 0 means unlimited
+	maxPerUser int
+
+	mutex   sync.Mutex
+	issued  map[string]Session
+	revoked map[string]time.Time
+}
+
+func NewManager(store Store, keys *Keyring, cookie CookieOptions, ttl time.Duration) *Manager {
+	return &Manager{
+		store:   store,
+		keys:    keys,
+		cookie:  cookie,
+		ttl:     ttl,
+		issued:  make(map[string]Session),
+		revoked: make(map[string]time.Time),
+	}
+}
+
+// SetMaxPerUser limits how many live sessions a single user may hold.
+// Zero disables the limit. Sessions are counted in the store, so replicas
+// sharing a Redis store share the limit; cookie sessions cannot be
+// limited.
+func (m *Manager) SetMaxPerUser(max int) {
+	m.maxPerUser = max
+}
+
+func (m *Manager) Cookie() CookieOptions {
+	return m.cookie
+}
+
+// TTL is how long new sessions live.
+func (m *Manager) TTL() time.Duration {
+	return m.ttl
+}
+
+func (m *Manager) New() (*Session, error) {
+	return New(m.ttl)
+}
+
+// Load returns the session referenced by the request cookie.
+func (m *Manager) Load(r *http.Request) (*Session, error) {
+	value := m.cookie.Read(r)
+	if value == "" {
+		return nil, ErrNotFound
+	}
+
+	if m.store == nil {
+		sealed, err := base64.RawURLEncoding.DecodeString(value)
+		if err != nil {
+			return nil, ErrNotFound
+		}
+		sess, err := m.open(sealed)
+		if err != nil {
+			return nil, err
+		}
+		if m.isRevoked(sess.ID) {
+			return nil, ErrNotFound
+		}
+		return sess, nil
+	}
+
+	return m.Get(r.Context(), value)
+}
+
+// Get loads a server-side session by ID, re-sealing it with the primary key
+// if it was sealed with a retired one.
+func (m *Manager) Get(ctx context.Context, id string) (*Session, error) {
+	if m.store == nil {
+		return nil, ErrNotFound
+	}
+
+	sealed, err := m.store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	sess, err := m.open(sealed)
+	if err != nil {
+		return nil, err
+	}
+	if sess.ID != id {
+		return nil, ErrNotFound
+	}
+
+	if sess.stale {
+		if err := m.persist(ctx, sess); err == nil {
+			sess.stale = false
+		}
+	}
+	return sess, nil
+}
+
+// Save persists the session and writes its cookie.
+func (m *Manager) Save(w http.ResponseWriter, r *http.Request, sess *Session) error {
+	if m.store == nil {
+		sealed, err := m.seal(sess)
+		if err != nil {
+			return err
+		}
+		m.track(sess)
+		m.cookie.Write(w, base64.RawURLEncoding.EncodeToString(sealed), sess.ExpiresAt)
+		return nil
+	}
+
+	if err := m.persist(r.Context(), sess); err != nil {
+		return err
+	}
+	m.cookie.Write(w, sess.ID, sess.ExpiresAt)
+	return nil
+}
+
+// Touch extends the session to a full ttl from now and saves it, for
+// sliding expiration.
+func (m *Manager) Touch(w http.ResponseWriter, r *http.Request, sess *Session) error {
+	sess.ExpiresAt = time.Now().Add(m.ttl)
+	return m.Save(w, r, sess)
+}
+
+// Destroy invalidates the request's session and clears its cookie.
+func (m *Manager) Destroy(w http.ResponseWriter, r *http.Request) error {
+	defer m.cookie.Clear(w)
+
+	sess, err := m.Load(r)
+	if err != nil {
+		return nil
+	}
+	return m.Invalidate(r.Context(), sess.ID)
+}
+
+// Invalidate ends a session by ID.
+func (m *Manager) Invalidate(ctx context.Context, id string) error {
+	if m.store == nil {
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+
+		expiresAt := time.Now().Add(m.ttl)
+		if sess, exists := m.issued[id]; exists {
+			expiresAt = sess.ExpiresAt
+			delete(m.issued, id)
+		}
+		m.revoked[id] = expiresAt
+		return nil
+	}
+
+	return m.store.Delete(ctx, id)
+}
+
+// EnforceUserLimit ends the oldest sessions of keep's user until at most
+// maxPerUser remain. keep itself is never evicted. It returns the number of
+// sessions ended. Sessions are found through the store's per-user index,
+// without opening any of them.
+func (m *Manager) EnforceUserLimit(ctx context.Context, keep *Session) (int, error) {
+	if m.maxPerUser <= 0 || keep.UserID == "" {
+		return 0, nil
+	}
+	if m.store == nil {
+		return 0, ErrNoUserLimit
+	}
+
+	ids, err := m.store.UserSessions(ctx, userKey(keep.UserID))
+	if err != nil {
+		return 0, err
+	}
+	others := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if id != keep.ID {
+			others = append(others, id)
+		}
+	}
+
+	excess := len(others) + 1 - m.maxPerUser
+	if excess <= 0 {
+		return 0, nil
+	}
+
+	evicted := 0
+	for _, id := range others[:excess] {
+		if err := m.Invalidate(ctx, id); err != nil {
+			return evicted, err
+		}
+		evicted++
+	}
+	return evicted, nil
+}
+
+// List returns live sessions without their values.
+func (m *Manager) List(ctx context.Context) ([]Session, error) {
+	if m.store == nil {
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+
+		result := make([]Session, 0, len(m.issued))
+		for _, sess := range m.issued {
+			if !sess.IsExpired() {
+				result = append(result, sess)
+			}
+		}
+		return result, nil
+	}
+
+	ids, err := m.store.IDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Session, 0, len(ids))
+	for _, id := range ids {
+		sess, err := m.Get(ctx, id)
+		if err != nil {
+			continue
+		}
+		sess.Values = nil
+		result = append(result, *sess)
+	}
+	return result, nil
+}
+
+// Cleanup forgets expired bookkeeping and expired in-memory sessions.
+func (m *Manager) Cleanup() {
+	if memory, ok := m.store.(*MemoryStore); ok {
+		memory.Cleanup()
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := time.Now()
+	for id, sess := range m.issued {
+		if now.After(sess.ExpiresAt) {
+			delete(m.issued, id)
+		}
+	}
+	for id, expiresAt := range m.revoked {
+		if now.After(expiresAt) {
+			delete(m.revoked, id)
+		}
+	}
+}
+
+func (m *Manager) persist(ctx context.Context, sess *Session) error {
+	sealed, err := m.seal(sess)
+	if err != nil {
+		return err
+	}
+	if err := m.store.Set(ctx, sess.ID, sealed, sess.ExpiresAt); err != nil {
+		return err
+	}
+	if sess.UserID == "" {
+		return nil
+	}
+	// No session of this manager outlives a full ttl from now
+	keepUntil := time.Now().Add(m.ttl)
+	if sess.ExpiresAt.After(keepUntil) {
+		keepUntil = sess.ExpiresAt
+	}
+	return m.store.AddUserSession(ctx, userKey(sess.UserID), sess.ID, sess.CreatedAt, keepUntil)
+}
+
+// userKey is what the store indexes a user's sessions under, so it never
+// sees the user ID either.
+func userKey(userID string) string {
+	sum := sha256.Sum256([]byte(userID))
+	return hex.EncodeToString(sum[:16])
+}
+
+func (m *Manager) seal(sess *Session) ([]byte, error) {
+	plaintext, err := json.Marshal(sess)
+	if err != nil {
+		return nil, err
+	}
+	return m.keys.Encrypt(plaintext)
+}
+
+func (m *Manager) open(sealed []byte) (*Session, error) {
+	plaintext, primary, err := m.keys.Decrypt(sealed)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+
+	var sess Session
+	if err := json.Unmarshal(plaintext, &sess); err != nil {
+		return nil, ErrNotFound
+	}
+	if sess.IsExpired() {
+		return nil, ErrNotFound
+	}
+	sess.stale = !primary
+	return &sess, nil
+}
+
+func (m *Manager) track(sess *Session) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	metadata := *sess
+	metadata.Values = nil
+	m.issued[sess.ID] = metadata
+}
+
+func (m *Manager) isRevoked(id string) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	_, revoked := m.revoked[id]
+	return revoked
+}
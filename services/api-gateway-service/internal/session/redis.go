@@ -0,0 +1,121 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStore shares sessions between gateway replicas. Each session is a
+// key with a TTL; a set indexes live IDs for the admin listing, and a
+// sorted set per user, scored by creation time, the sessions the per-user
+// limit counts.
+type RedisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+func NewRedisStore(client *redis.Client, prefix string) *RedisStore {
+	return &RedisStore{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+func (r *RedisStore) key(id string) string {
+	return r.prefix + "session:" + id
+}
+
+func (r *RedisStore) indexKey() string {
+	return r.prefix + "sessions"
+}
+
+func (r *RedisStore) userKey(user string) string {
+	return r.prefix + "sessions:user:" + user
+}
+
+func (r *RedisStore) Get(ctx context.Context, id string) ([]byte, error) {
+	payload, err := r.client.Get(ctx, r.key(id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotFound
+	}
+	return payload, err
+}
+
+func (r *RedisStore) Set(ctx context.Context, id string, payload []byte, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return r.Delete(ctx, id)
+	}
+
+	pipe := r.client.TxPipeline()
+	pipe.Set(ctx, r.key(id), payload, ttl)
+	pipe.SAdd(ctx, r.indexKey(), id)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (r *RedisStore) Delete(ctx context.Context, id string) error {
+	pipe := r.client.TxPipeline()
+	pipe.Del(ctx, r.key(id))
+	pipe.SRem(ctx, r.indexKey(), id)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// IDs returns live session IDs, pruning index entries whose session key has
+// already expired.
+func (r *RedisStore) IDs(ctx context.Context) ([]string, error) {
+	members, err := r.client.SMembers(ctx, r.indexKey()).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(members))
+	for _, id := range members {
+		exists, err := r.client.Exists(ctx, r.key(id)).Result()
+		if err != nil {
+			return nil, err
+		}
+		if exists == 0 {
+			r.client.SRem(ctx, r.indexKey(), id)
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// AddUserSession indexes id under user, expiring the index at keepUntil.
+func (r *RedisStore) AddUserSession(ctx context.Context, user, id string, createdAt, keepUntil time.Time) error {
+	pipe := r.client.TxPipeline()
+	pipe.ZAdd(ctx, r.userKey(user), redis.Z{Score: float64(createdAt.UnixNano()), Member: id})
+	pipe.ExpireAt(ctx, r.userKey(user), keepUntil)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// UserSessions returns the live sessions of user, oldest first, pruning
+// index entries whose session key has already expired or was deleted.
+func (r *RedisStore) UserSessions(ctx context.Context, user string) ([]string, error) {
+	members, err := r.client.ZRange(ctx, r.userKey(user), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(members))
+	for _, id := range members {
+		exists, err := r.client.Exists(ctx, r.key(id)).Result()
+		if err != nil {
+			return nil, err
+		}
+		if exists == 0 {
+			r.client.ZRem(ctx, r.userKey(user), id)
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
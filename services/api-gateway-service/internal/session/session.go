@@ -0,0 +1,128 @@
+package session
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+)
+
+var ErrNotFound = errors.New("session: not found")
+
+// Values stored by the BFF token relay
+const (
+	ValueAccessToken  = "access_token"
+	ValueRefreshToken = "refresh_token"
+	ValueAccessExpiry = "access_expires_at"
+)
+
+// Values stored by the OIDC login. The oidc_ values only live between
+// the redirect to the identity provider and its callback.
+const (
+	ValueIDToken      = "id_token"
+	ValueEmail        = "email"
+	ValueName         = "name"
+	ValueOIDCState    = "oidc_state"
+	ValueOIDCNonce    = "oidc_nonce"
+	ValueOIDCVerifier = "oidc_verifier"
+	ValueOIDCReturnTo = "oidc_return_to"
+)
+
+// Values stored by session auth logins, besides ValueEmail and ValueName.
+// ValueSource marks the session as one SessionAuth may accept.
+const (
+	ValueScopes = "scopes"
+	ValueSource = "source"
+
+	SourceSessionLogin = "session_login"
+)
+
+type Session struct {
+	ID        string            `json:"id"`
+	UserID    string            `json:"user_id,omitempty"`
+	Values    map[string]string `json:"values,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+	ExpiresAt time.Time         `json:"expires_at"`
+
+	// stale is set when the session was decrypted with a retired key
+	stale bool
+}
+
+func New(ttl time.Duration) (*Session, error) {
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	return &Session{
+		ID:        id,
+		Values:    make(map[string]string),
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}, nil
+}
+
+func (s *Session) IsExpired() bool {
+	return time.Now().After(s.ExpiresAt)
+}
+
+// Handle identifies the session in admin APIs without exposing the session
+// ID, which is a bearer credential.
+func (s *Session) Handle() string {
+	sum := sha256.Sum256([]byte(s.ID))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Stale reports whether the session is still sealed with a retired key and
+// should be saved again to re-seal it with the current one.
+func (s *Session) Stale() bool {
+	return s.stale
+}
+
+func (s *Session) Get(key string) string {
+	return s.Values[key]
+}
+
+func (s *Session) Delete(key string) {
+	delete(s.Values, key)
+}
+
+func (s *Session) Set(key, value string) {
+	if s.Values == nil {
+		s.Values = make(map[string]string)
+	}
+	s.Values[key] = value
+}
+
+// SetTokens records the token pair relayed on behalf of the session owner.
+// An empty refresh token keeps the previously stored one.
+func (s *Session) SetTokens(accessToken, refreshToken string, expiresIn int) {
+	s.Set(ValueAccessToken, accessToken)
+	if refreshToken != "" {
+		s.Set(ValueRefreshToken, refreshToken)
+	}
+	expiry := time.Now().Add(time.Duration(expiresIn) * time.Second)
+	s.Set(ValueAccessExpiry, strconv.FormatInt(expiry.Unix(), 10))
+}
+
+// AccessTokenExpiry returns when the stored access token expires, or the
+// zero time when unknown.
+func (s *Session) AccessTokenExpiry() time.Time {
+	seconds, err := strconv.ParseInt(s.Get(ValueAccessExpiry), 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(seconds, 0)
+}
+
+func newID() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
@@ -0,0 +1,147 @@
+package session
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Store persists sealed session payloads server-side. Payloads are already
+// encrypted by the Manager, so stores never see session contents.
+type Store interface {
+	Get(ctx context.Context, id string) ([]byte, error)
+	Set(ctx context.Context, id string, payload []byte, expiresAt time.Time) error
+	Delete(ctx context.Context, id string) error
+	IDs(ctx context.Context) ([]string, error)
+	// AddUserSession indexes session id under user, an opaque key the
+	// Manager derives from the user ID, for the per-user limit. The index
+	// is kept at least until keepUntil.
+	AddUserSession(ctx context.Context, user, id string, createdAt, keepUntil time.Time) error
+	// UserSessions returns the live sessions indexed under user, oldest
+	// first.
+	UserSessions(ctx context.Context, user string) ([]string, error)
+}
+
+type MemoryStore struct {
+	sessions map[string]memoryEntry
+	// users maps a user key to its sessions and their creation times
+	users map[string]map[string]time.Time
+	mutex sync.RWMutex
+}
+
+type memoryEntry struct {
+	payload   []byte
+	expiresAt time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		sessions: make(map[string]memoryEntry),
+		users:    make(map[string]map[string]time.Time),
+	}
+}
+
+func (m *MemoryStore) Get(ctx context.Context, id string) ([]byte, error) {
+	m.mutex.RLock()
+	entry, exists := m.sessions[id]
+	m.mutex.RUnlock()
+
+	if !exists || time.Now().After(entry.expiresAt) {
+		return nil, ErrNotFound
+	}
+	return entry.payload, nil
+}
+
+func (m *MemoryStore) Set(ctx context.Context, id string, payload []byte, expiresAt time.Time) error {
+	m.mutex.Lock()
+	m.sessions[id] = memoryEntry{payload: payload, expiresAt: expiresAt}
+	m.mutex.Unlock()
+	return nil
+}
+
+func (m *MemoryStore) Delete(ctx context.Context, id string) error {
+	m.mutex.Lock()
+	delete(m.sessions, id)
+	m.mutex.Unlock()
+	return nil
+}
+
+func (m *MemoryStore) IDs(ctx context.Context) ([]string, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	now := time.Now()
+	ids := make([]string, 0, len(m.sessions))
+	for id, entry := range m.sessions {
+		if now.Before(entry.expiresAt) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (m *MemoryStore) AddUserSession(ctx context.Context, user, id string, createdAt, keepUntil time.Time) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.users[user] == nil {
+		m.users[user] = make(map[string]time.Time)
+	}
+	m.users[user][id] = createdAt
+	return nil
+}
+
+// UserSessions returns the live sessions of user, pruning those that
+// ended.
+func (m *MemoryStore) UserSessions(ctx context.Context, user string) ([]string, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := time.Now()
+	sessions := m.users[user]
+	ids := make([]string, 0, len(sessions))
+	for id := range sessions {
+		if entry, exists := m.sessions[id]; !exists || now.After(entry.expiresAt) {
+			delete(sessions, id)
+			continue
+		}
+		ids = append(ids, id)
+	}
+	if len(sessions) == 0 {
+		delete(m.users, user)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if !sessions[ids[i]].Equal(sessions[ids[j]]) {
+			return sessions[ids[i]].Before(sessions[ids[j]])
+		}
+		return ids[i] < ids[j]
+	})
+	return ids, nil
+}
+
+// Cleanup drops expired sessions and returns how many were removed.
+func (m *MemoryStore) Cleanup() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	removed := 0
+	now := time.Now()
+	for id, entry := range m.sessions {
+		if now.After(entry.expiresAt) {
+			delete(m.sessions, id)
+			removed++
+		}
+	}
+	for user, sessions := range m.users {
+		for id := range sessions {
+			if _, exists := m.sessions[id]; !exists {
+				delete(sessions, id)
+			}
+		}
+		if len(sessions) == 0 {
+			delete(m.users, user)
+		}
+	}
+	return removed
+}
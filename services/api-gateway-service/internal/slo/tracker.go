@@ -0,0 +1,273 @@
+// Package slo tracks service level objectives of routes and services from
+// the gateway's vantage point: how many requests failed or were slow over
+// a rolling window, how much of the error budget is left, and how fast it
+// is burning.
+package slo
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gateway/internal/models"
+	"gateway/internal/registry"
+)
+
+// Compliance over an SLO's window is kept in windowSlots slots that expire
+// one by one. Burn rates over the last hour come from a ring of one-minute
+// slots, so a fast burn shows up within minutes however long the window.
+const (
+	windowSlots = 240
+	recentSlots = 60
+	recentStep  = time.Minute
+)
+
+const (
+	ScopeRoute   = "route"
+	ScopeService = "service"
+
+	ObjectiveAvailability = "availability"
+	ObjectiveLatency      = "latency"
+)
+
+type key struct {
+	scope, name string
+}
+
+type counts struct {
+	total, errors, slow int64
+}
+
+func (c *counts) add(other counts) {
+	c.total += other.total
+	c.errors += other.errors
+	c.slow += other.slow
+}
+
+type slot struct {
+	start time.Time
+	counts
+}
+
+// ring counts requests in slots of step, covering len(slots) steps.
+type ring struct {
+	step  time.Duration
+	slots []slot
+}
+
+func newRing(span time.Duration, slots int) ring {
+	step := span / time.Duration(slots)
+	if step <= 0 {
+		step = 1
+	}
+	return ring{step: step, slots: make([]slot, slots)}
+}
+
+func (r *ring) add(now time.Time, c counts) {
+	start := now.Truncate(r.step)
+	s := &r.slots[int(start.UnixNano()/int64(r.step))%len(r.slots)]
+	if !s.start.Equal(start) {
+		*s = slot{start: start}
+	}
+	s.add(c)
+}
+
+// sum adds up the slots that started within span before now.
+func (r *ring) sum(now time.Time, span time.Duration) counts {
+	var total counts
+	for _, s := range r.slots {
+		if !s.start.IsZero() && now.Sub(s.start) < span {
+			total.add(s.counts)
+		}
+	}
+	return total
+}
+
+type tracked struct {
+	config models.SLOConfig
+	window ring
+	recent ring
+}
+
+func newTracked(config models.SLOConfig) *tracked {
+	return &tracked{
+		config: config,
+		window: newRing(config.Window, windowSlots),
+		recent: newRing(recentSlots*recentStep, recentSlots),
+	}
+}
+
+// Tracker counts the requests of routes and services with an SLO.
+type Tracker struct {
+	registry *registry.ServiceRegistry
+	tracked  map[key]*tracked
+	mutex    sync.Mutex
+}
+
+func NewTracker(serviceRegistry *registry.ServiceRegistry) *Tracker {
+	return &Tracker{registry: serviceRegistry, tracked: make(map[key]*tracked)}
+}
+
+// Record counts a request to route, served by service, that was answered
+// with status after elapsed, against the SLOs of both. A 5xx fails the
+// availability objective and a slower answer than the latency one fails
+// that.
+func (t *Tracker) Record(route *models.RouteConfig, service *models.ServiceConfig, status int, elapsed time.Duration) {
+	if (route == nil || route.SLO == nil) && (service == nil || service.SLO == nil) {
+		return
+	}
+	now := time.Now()
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if route != nil && route.SLO != nil {
+		t.record(key{ScopeRoute, route.Path}, route.SLO.Defaults(), now, status, elapsed)
+	}
+	if service != nil && service.SLO != nil {
+		t.record(key{ScopeService, service.Name}, service.SLO.Defaults(), now, status, elapsed)
+	}
+}
+
+func (t *Tracker) record(k key, config models.SLOConfig, now time.Time, status int, elapsed time.Duration) {
+	tr, exists := t.tracked[k]
+	// Changed objectives start counting afresh
+	if !exists || tr.config != config {
+		tr = newTracked(config)
+		t.tracked[k] = tr
+	}
+	c := counts{total: 1}
+	if status >= 500 {
+		c.errors = 1
+	}
+	if config.Latency > 0 && elapsed > config.Latency {
+		c.slow = 1
+	}
+	tr.window.add(now, c)
+	tr.recent.add(now, c)
+}
+
+// Status is how a route or service is doing against its SLO.
+type Status struct {
+	Scope      string      `json:"scope"`
+	Name       string      `json:"name"`
+	Window     string      `json:"window"`
+	Requests   int64       `json:"requests"`
+	Objectives []Objective `json:"objectives"`
+}
+
+// Objective is the compliance with one objective over the window. Burn
+// rates are how many times faster than sustainable the error budget is
+// being spent: 1 spends exactly the budget over the window. The remaining
+// budget goes negative once it is overspent.
+type Objective struct {
+	Type                 string  `json:"type"`
+	Target               float64 `json:"target"`
+	Threshold            string  `json:"threshold,omitempty"`
+	Compliance           float64 `json:"compliance"`
+	Met                  bool    `json:"met"`
+	ErrorBudgetRemaining float64 `json:"error_budget_remaining"`
+	BurnRate             float64 `json:"burn_rate"`
+	BurnRate1h           float64 `json:"burn_rate_1h"`
+	BurnRate5m           float64 `json:"burn_rate_5m"`
+}
+
+// Statuses reports every SLO in the current configuration, including
+// those of routes and services that were not called within their window.
+func (t *Tracker) Statuses() []Status {
+	configs := make(map[key]models.SLOConfig)
+	for _, route := range t.registry.GetRoutes() {
+		if route.SLO != nil {
+			configs[key{ScopeRoute, route.Path}] = route.SLO.Defaults()
+		}
+	}
+	for name, service := range t.registry.GetAllServices() {
+		if service.SLO != nil {
+			configs[key{ScopeService, name}] = service.SLO.Defaults()
+		}
+	}
+
+	now := time.Now()
+	statuses := make([]Status, 0, len(configs))
+	t.mutex.Lock()
+	for k, config := range configs {
+		var window, hour, fiveMinutes counts
+		if tr, exists := t.tracked[k]; exists && tr.config == config {
+			window = tr.window.sum(now, config.Window)
+			hour = tr.recent.sum(now, time.Hour)
+			fiveMinutes = tr.recent.sum(now, 5*time.Minute)
+		}
+		status := Status{
+			Scope:      k.scope,
+			Name:       k.name,
+			Window:     shortDuration(config.Window),
+			Requests:   window.total,
+			Objectives: make([]Objective, 0, 2),
+		}
+		if config.Availability > 0 {
+			errors := func(c counts) int64 { return c.errors }
+			status.Objectives = append(status.Objectives, objective(ObjectiveAvailability, config.Availability, window, hour, fiveMinutes, errors))
+		}
+		if config.Latency > 0 {
+			slow := func(c counts) int64 { return c.slow }
+			latency := objective(ObjectiveLatency, config.LatencyTarget, window, hour, fiveMinutes, slow)
+			latency.Threshold = shortDuration(config.Latency)
+			status.Objectives = append(status.Objectives, latency)
+		}
+		statuses = append(statuses, status)
+	}
+	t.mutex.Unlock()
+
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].Scope != statuses[j].Scope {
+			return statuses[i].Scope < statuses[j].Scope
+		}
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
+
+func objective(kind string, target float64, window, hour, fiveMinutes counts, bad func(counts) int64) Objective {
+	o := Objective{
+		Type:                 kind,
+		Target:               target,
+		Compliance:           1,
+		ErrorBudgetRemaining: 1,
+		BurnRate:             burnRate(window, target, bad),
+		BurnRate1h:           burnRate(hour, target, bad),
+		BurnRate5m:           burnRate(fiveMinutes, target, bad),
+	}
+	if window.total > 0 {
+		o.Compliance = 1 - float64(bad(window))/float64(window.total)
+		o.ErrorBudgetRemaining = 1 - o.BurnRate
+	}
+	o.Met = o.Compliance >= target
+	o.Compliance = round(o.Compliance)
+	o.ErrorBudgetRemaining = round(o.ErrorBudgetRemaining)
+	return o
+}
+
+func burnRate(c counts, target float64, bad func(counts) int64) float64 {
+	if c.total == 0 {
+		return 0
+	}
+	return round(float64(bad(c)) / float64(c.total) / (1 - target))
+}
+
+// round drops the floating point noise of dividing by 1 - target.
+func round(value float64) float64 {
+	return math.Round(value*1e6) / 1e6
+}
+
+// shortDuration formats d without trailing zero units: 24h rather than
+// 24h0m0s.
+func shortDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
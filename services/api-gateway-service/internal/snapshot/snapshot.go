@@ -0,0 +1,277 @@
+package snapshot
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gateway/internal/circuit"
+	"gateway/internal/config"
+	"gateway/internal/logging"
+	"gateway/internal/maintenance"
+	"gateway/internal/models"
+	"gateway/internal/registry"
+	"gateway/internal/store"
+)
+
+// Version is the format of the snapshots Export takes. Import refuses
+// other versions.
+const Version = 1
+
+// ErrVersion is returned for snapshots of another version.
+var ErrVersion = errors.New("unsupported snapshot version")
+
+// Snapshot is the state of a gateway, to move it to another environment or
+// restore it after a loss.
+type Snapshot struct {
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exported_at"`
+	// Config is the configuration the gateway ran with, its services,
+	// routes and policies, with secrets masked. It is for comparing
+	// environments and rebuilding config files: Import leaves the
+	// configuration alone.
+	Config     interface{} `json:"config"`
+	ConfigHash string      `json:"config_hash"`
+	// Services and Routes are those added through the admin API, as they
+	// were defined there
+	Services  map[string]interface{} `json:"services"`
+	Routes    []interface{}          `json:"routes"`
+	Overrides Overrides              `json:"overrides"`
+}
+
+// Overrides are the changes operators made to a running gateway.
+type Overrides struct {
+	Maintenance maintenance.Status       `json:"maintenance"`
+	Traffic     []registry.TrafficStatus `json:"traffic"`
+	Logging     logging.Status           `json:"logging"`
+	Circuits    []Circuit                `json:"circuits"`
+}
+
+// Circuit is a circuit opened by hand, until Until or, without it, until
+// it is reset.
+type Circuit struct {
+	Service string     `json:"service"`
+	Route   string     `json:"route,omitempty"`
+	Until   *time.Time `json:"until,omitempty"`
+}
+
+// Result tells what Import applied. Warnings list the overrides it could
+// not apply, and why.
+type Result struct {
+	Services     int      `json:"services"`
+	Routes       int      `json:"routes"`
+	ConfigHash   string   `json:"config_hash"`
+	ConfigEquals bool     `json:"config_equals"`
+	Warnings     []string `json:"warnings"`
+}
+
+// Gateway is what snapshots are taken of and applied to. Breakers is nil
+// when circuit breakers are off.
+type Gateway struct {
+	Manager        *config.Manager
+	Registry       *registry.ServiceRegistry
+	Routing        *store.RuntimeRouting
+	Maintenance    *maintenance.Mode
+	Logging        *logging.Overrides
+	MaxLogOverride time.Duration
+	Breakers       *circuit.Breakers
+}
+
+// Export takes a snapshot of the gateway. Services and routes keep their
+// secrets, as importing them needs them.
+func (g *Gateway) Export() Snapshot {
+	current := g.Manager.GetConfig()
+	services, routes := g.Routing.Definitions()
+	snapshot := Snapshot{
+		Version:    Version,
+		ExportedAt: time.Now(),
+		Config:     config.Redact(current, g.Manager.SecretPaths()...),
+		ConfigHash: config.Hash(current),
+		Services:   services,
+		Routes:     routes,
+		Overrides: Overrides{
+			Maintenance: g.Maintenance.Status(),
+			Traffic:     g.Registry.HeldServices(),
+			Logging:     g.Logging.Status(),
+			Circuits:    make([]Circuit, 0),
+		},
+	}
+	if g.Breakers != nil {
+		for _, status := range g.Breakers.Circuits() {
+			if !status.Forced {
+				continue
+			}
+			forced := Circuit{Service: status.ServiceName, Route: status.Route}
+			if !status.NextRetry.IsZero() {
+				until := status.NextRetry
+				forced.Until = &until
+			}
+			snapshot.Overrides.Circuits = append(snapshot.Overrides.Circuits, forced)
+		}
+	}
+	return snapshot
+}
+
+// Import applies snapshot: its services and routes replace those added
+// through the admin API, in one step, and then its overrides replace the
+// gateway's. If the services or routes are refused nothing changes;
+// overrides that no longer apply, such as for a service that is gone, are
+// skipped with a warning.
+func (g *Gateway) Import(snapshot Snapshot) (Result, error) {
+	if snapshot.Version != Version {
+		return Result{}, fmt.Errorf("%w %d, expected %d", ErrVersion, snapshot.Version, Version)
+	}
+	if err := g.Routing.Replace(snapshot.Services, snapshot.Routes); err != nil {
+		return Result{}, err
+	}
+
+	hash := config.Hash(g.Manager.GetConfig())
+	result := Result{
+		Services:     len(snapshot.Services),
+		Routes:       len(snapshot.Routes),
+		ConfigHash:   hash,
+		ConfigEquals: hash == snapshot.ConfigHash,
+		Warnings:     make([]string, 0),
+	}
+	warn := func(format string, args ...interface{}) {
+		result.Warnings = append(result.Warnings, fmt.Sprintf(format, args...))
+	}
+	g.importMaintenance(snapshot.Overrides.Maintenance, warn)
+	g.importTraffic(snapshot.Overrides.Traffic, warn)
+	g.importLogging(snapshot.Overrides.Logging, warn)
+	g.importCircuits(snapshot.Overrides.Circuits, warn)
+	return result, nil
+}
+
+func (g *Gateway) importMaintenance(status maintenance.Status, warn func(string, ...interface{})) {
+	if !status.Enabled {
+		g.Maintenance.Disable()
+		return
+	}
+	var retryAfter time.Duration
+	if status.RetryAfter != "" {
+		var err error
+		if retryAfter, err = time.ParseDuration(status.RetryAfter); err != nil {
+			warn("maintenance retry_after %q is not a duration, using the configured one", status.RetryAfter)
+		}
+	}
+	g.Maintenance.Enable(status.Message, retryAfter)
+}
+
+// importTraffic disables and drains the services held in the snapshot and
+// puts the others back in traffic.
+func (g *Gateway) importTraffic(held []registry.TrafficStatus, warn func(string, ...interface{})) {
+	holds := make(map[string]string, len(held))
+	for _, status := range held {
+		holds[status.Service] = status.State
+	}
+	for _, status := range g.Registry.HeldServices() {
+		if _, kept := holds[status.Service]; !kept {
+			g.Registry.EnableService(status.Service)
+		}
+	}
+	for _, status := range held {
+		var ok bool
+		switch status.State {
+		case registry.TrafficDisabled:
+			_, ok = g.Registry.DisableService(status.Service)
+		case registry.TrafficDraining, registry.TrafficDrained:
+			_, ok = g.Registry.DrainService(status.Service)
+		default:
+			warn("service %s has unknown traffic state %q", status.Service, status.State)
+			continue
+		}
+		if !ok {
+			warn("service %s is not registered, it was %s", status.Service, status.State)
+		}
+	}
+}
+
+// importLogging sets the snapshot's logging override for the time it had
+// left, at most the configured maximum.
+func (g *Gateway) importLogging(status logging.Status, warn func(string, ...interface{})) {
+	if status.Until == nil || !time.Now().Before(*status.Until) {
+		g.Logging.Reset()
+		return
+	}
+	level := ""
+	if status.Level != status.ConfiguredLevel {
+		if !models.ValidLogLevel(status.Level) {
+			warn("logging level %q is not a log level", status.Level)
+		} else {
+			level = status.Level
+		}
+	}
+	routes := make([]string, 0, len(status.DebugRoutes))
+	for _, route := range status.DebugRoutes {
+		if g.routeExists(route) {
+			routes = append(routes, route)
+		} else {
+			warn("no route %s to debug", route)
+		}
+	}
+	if level == "" && len(routes) == 0 {
+		g.Logging.Reset()
+		return
+	}
+	until := *status.Until
+	if latest := time.Now().Add(g.MaxLogOverride); until.After(latest) {
+		until = latest
+	}
+	g.Logging.Set(level, routes, until)
+}
+
+// importCircuits opens the circuits opened by hand in the snapshot that
+// are not due to close yet. Circuits opened here already are left alone.
+func (g *Gateway) importCircuits(circuits []Circuit, warn func(string, ...interface{})) {
+	if len(circuits) == 0 {
+		return
+	}
+	if g.Breakers == nil {
+		warn("circuit breakers are off, %d circuits opened by hand are not", len(circuits))
+		return
+	}
+	for _, forced := range circuits {
+		var until time.Time
+		if forced.Until != nil {
+			if !time.Now().Before(*forced.Until) {
+				continue
+			}
+			until = *forced.Until
+		}
+		service, exists := g.Registry.GetService(forced.Service)
+		if !exists {
+			warn("service %s is not registered, its circuit is not opened", forced.Service)
+			continue
+		}
+		var route *models.RouteConfig
+		if forced.Route != "" {
+			if route = g.circuitRoute(service.Name, forced.Route); route == nil {
+				warn("route %s of service %s has no circuit of its own, it is not opened", forced.Route, service.Name)
+				continue
+			}
+		}
+		circuitRoute, settings := g.Breakers.SettingsFor(service, route)
+		g.Breakers.ForceOpen(service.Name, circuitRoute, settings, until)
+	}
+}
+
+// circuitRoute finds the route with path of service that has a circuit of
+// its own.
+func (g *Gateway) circuitRoute(service, path string) *models.RouteConfig {
+	for _, route := range g.Registry.GetRoutes() {
+		if route.ServiceName == service && route.Path == path && route.CircuitBreaker != nil {
+			return &route
+		}
+	}
+	return nil
+}
+
+func (g *Gateway) routeExists(path string) bool {
+	for _, route := range g.Registry.GetRoutes() {
+		if route.Path == path {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"gateway/internal/models"
+)
+
+// ConsulClient reads Consul KV through the agent's HTTP API.
+type ConsulClient struct {
+	address    string
+	token      string
+	datacenter string
+	timeout    time.Duration
+	client     *http.Client
+	watcher    *http.Client
+}
+
+func NewConsulClient(config models.ConsulConfig) (*ConsulClient, error) {
+	address := strings.TrimSuffix(config.Address, "/")
+	if address == "" {
+		return nil, fmt.Errorf("consul: no address configured")
+	}
+	if !strings.HasPrefix(address, "http://") && !strings.HasPrefix(address, "https://") {
+		address = "http://" + address
+	}
+
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+
+	return &ConsulClient{
+		address:    address,
+		token:      config.Token,
+		datacenter: config.Datacenter,
+		timeout:    timeout,
+		client:     &http.Client{Timeout: timeout},
+		// Blocking queries are bounded by their wait and context instead
+		watcher: &http.Client{},
+	}, nil
+}
+
+// Get returns the value stored at key, or nil when the key does not exist,
+// along with the index the read was served at. With a non-zero index it is
+// a blocking query: it returns once the key changes past index or wait
+// elapses, whichever comes first.
+func (c *ConsulClient) Get(ctx context.Context, key string, index uint64, wait time.Duration) ([]byte, uint64, error) {
+	query := url.Values{"raw": {""}}
+	if c.datacenter != "" {
+		query.Set("dc", c.datacenter)
+	}
+	client := c.client
+	if index > 0 {
+		query.Set("index", strconv.FormatUint(index, 10))
+		query.Set("wait", fmt.Sprintf("%ds", int(wait.Seconds())))
+		client = c.watcher
+		var cancel context.CancelFunc
+		// Consul adds up to wait/16 of jitter to the wait
+		ctx, cancel = context.WithTimeout(ctx, wait+wait/16+c.timeout)
+		defer cancel()
+	}
+
+	endpoint := c.address + "/v1/kv/" + strings.TrimPrefix(key, "/") + "?" + query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if c.token != "" {
+		req.Header.Set("X-Consul-Token", c.token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("consul: %w", err)
+	}
+	defer resp.Body.Close()
+
+	current, _ := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	switch resp.StatusCode {
+	case http.StatusOK:
+		value, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, 0, fmt.Errorf("consul: failed to read %s: %w", key, err)
+		}
+		return value, current, nil
+	case http.StatusNotFound:
+		return nil, current, nil
+	default:
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, 0, fmt.Errorf("consul: reading %s returned %d: %s", key, resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+}
@@ -0,0 +1,377 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gateway/internal/models"
+)
+
+// EtcdClient talks to etcd through its v3 JSON gateway so the gateway does
+// not need to pull in the gRPC client.
+type EtcdClient struct {
+	endpoints []string
+	username  string
+	password  string
+	client    *http.Client
+	watcher   *http.Client
+
+	mutex   sync.RWMutex
+	current int
+	token   string
+}
+
+type KeyValue struct {
+	Key         string
+	Value       []byte
+	ModRevision int64
+}
+
+type WatchEvent struct {
+	Type string
+	KV   KeyValue
+}
+
+type etcdKV struct {
+	Key         string `json:"key"`
+	Value       string `json:"value"`
+	ModRevision string `json:"mod_revision"`
+}
+
+type etcdHeader struct {
+	Revision string `json:"revision"`
+}
+
+type etcdRangeResponse struct {
+	Header etcdHeader `json:"header"`
+	Kvs    []etcdKV   `json:"kvs"`
+}
+
+type etcdWatchResponse struct {
+	Result struct {
+		Header   etcdHeader `json:"header"`
+		Created  bool       `json:"created"`
+		Canceled bool       `json:"canceled"`
+		Events   []struct {
+			Type string `json:"type"`
+			KV   etcdKV `json:"kv"`
+		} `json:"events"`
+	} `json:"result"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func NewEtcdClient(config models.EtcdConfig) (*EtcdClient, error) {
+	if len(config.Endpoints) == 0 {
+		return nil, fmt.Errorf("etcd: no endpoints configured")
+	}
+
+	endpoints := make([]string, 0, len(config.Endpoints))
+	for _, endpoint := range config.Endpoints {
+		endpoint = strings.TrimSuffix(endpoint, "/")
+		if !strings.HasPrefix(endpoint, "http://") && !strings.HasPrefix(endpoint, "https://") {
+			endpoint = "http://" + endpoint
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+
+	timeout := config.DialTimeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+
+	return &EtcdClient{
+		endpoints: endpoints,
+		username:  config.Username,
+		password:  config.Password,
+		client:    &http.Client{Timeout: timeout},
+		// Watch streams are long-lived and bounded by their context instead
+		watcher: &http.Client{},
+	}, nil
+}
+
+// Get returns the value stored at key, or nil when the key does not exist.
+func (c *EtcdClient) Get(ctx context.Context, key string) (*KeyValue, int64, error) {
+	kvs, revision, err := c.rangeRequest(ctx, map[string]string{
+		"key": encodeKey(key),
+	})
+	if err != nil || len(kvs) == 0 {
+		return nil, revision, err
+	}
+	return &kvs[0], revision, nil
+}
+
+// GetPrefix returns all keys under prefix sorted by key, along with the
+// store revision the read was served at.
+func (c *EtcdClient) GetPrefix(ctx context.Context, prefix string) ([]KeyValue, int64, error) {
+	return c.rangeRequest(ctx, map[string]string{
+		"key":       encodeKey(prefix),
+		"range_end": encodeKey(prefixEnd(prefix)),
+	})
+}
+
+func (c *EtcdClient) Put(ctx context.Context, key string, value []byte) error {
+	body, err := c.post(ctx, c.client, "/v3/kv/put", map[string]string{
+		"key":   encodeKey(key),
+		"value": base64.StdEncoding.EncodeToString(value),
+	})
+	if err != nil {
+		return err
+	}
+	return body.Close()
+}
+
+func (c *EtcdClient) Delete(ctx context.Context, key string) error {
+	body, err := c.post(ctx, c.client, "/v3/kv/deleterange", map[string]string{
+		"key": encodeKey(key),
+	})
+	if err != nil {
+		return err
+	}
+	return body.Close()
+}
+
+// WatchPrefix streams change events for all keys under prefix starting at
+// startRevision. It blocks until the context is cancelled or the stream
+// breaks; callers are expected to resync and watch again on error.
+func (c *EtcdClient) WatchPrefix(ctx context.Context, prefix string, startRevision int64, handler func([]WatchEvent)) error {
+	return c.watch(ctx, map[string]interface{}{
+		"key":            encodeKey(prefix),
+		"range_end":      encodeKey(prefixEnd(prefix)),
+		"start_revision": strconv.FormatInt(startRevision, 10),
+	}, handler)
+}
+
+// WatchKey is WatchPrefix for the single key.
+func (c *EtcdClient) WatchKey(ctx context.Context, key string, startRevision int64, handler func([]WatchEvent)) error {
+	return c.watch(ctx, map[string]interface{}{
+		"key":            encodeKey(key),
+		"start_revision": strconv.FormatInt(startRevision, 10),
+	}, handler)
+}
+
+func (c *EtcdClient) watch(ctx context.Context, create map[string]interface{}, handler func([]WatchEvent)) error {
+	request := map[string]interface{}{"create_request": create}
+
+	body, err := c.post(ctx, c.watcher, "/v3/watch", request)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	decoder := json.NewDecoder(body)
+	for {
+		var message etcdWatchResponse
+		if err := decoder.Decode(&message); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("etcd watch stream: %w", err)
+		}
+		if message.Error != nil {
+			return fmt.Errorf("etcd watch: %s", message.Error.Message)
+		}
+		if message.Result.Canceled {
+			return fmt.Errorf("etcd watch cancelled by server")
+		}
+		if len(message.Result.Events) == 0 {
+			continue
+		}
+
+		events := make([]WatchEvent, 0, len(message.Result.Events))
+		for _, event := range message.Result.Events {
+			kv, err := decodeKV(event.KV)
+			if err != nil {
+				return err
+			}
+			eventType := event.Type
+			if eventType == "" {
+				eventType = "PUT"
+			}
+			events = append(events, WatchEvent{Type: eventType, KV: kv})
+		}
+		handler(events)
+	}
+}
+
+func (c *EtcdClient) rangeRequest(ctx context.Context, request map[string]string) ([]KeyValue, int64, error) {
+	body, err := c.post(ctx, c.client, "/v3/kv/range", request)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer body.Close()
+
+	var response etcdRangeResponse
+	if err := json.NewDecoder(body).Decode(&response); err != nil {
+		return nil, 0, fmt.Errorf("etcd: failed to decode range response: %w", err)
+	}
+
+	revision, _ := strconv.ParseInt(response.Header.Revision, 10, 64)
+	kvs := make([]KeyValue, 0, len(response.Kvs))
+	for _, raw := range response.Kvs {
+		kv, err := decodeKV(raw)
+		if err != nil {
+			return nil, 0, err
+		}
+		kvs = append(kvs, kv)
+	}
+	return kvs, revision, nil
+}
+
+// post sends a JSON request to the first reachable endpoint, authenticating
+// first when credentials are configured.
+func (c *EtcdClient) post(ctx context.Context, client *http.Client, path string, payload interface{}) (io.ReadCloser, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < len(c.endpoints); attempt++ {
+		endpoint := c.endpoint()
+
+		token, err := c.authToken(ctx, endpoint)
+		if err != nil {
+			lastErr = err
+			c.rotateEndpoint()
+			continue
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint+path, bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", token)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			lastErr = err
+			c.rotateEndpoint()
+			continue
+		}
+
+		if resp.StatusCode == http.StatusUnauthorized {
+			resp.Body.Close()
+			c.clearToken()
+			lastErr = fmt.Errorf("etcd: unauthorized")
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+			resp.Body.Close()
+			return nil, fmt.Errorf("etcd: %s returned %d: %s", path, resp.StatusCode, strings.TrimSpace(string(message)))
+		}
+
+		return resp.Body, nil
+	}
+
+	return nil, fmt.Errorf("etcd: all endpoints failed: %w", lastErr)
+}
+
+func (c *EtcdClient) authToken(ctx context.Context, endpoint string) (string, error) {
+	if c.username == "" {
+		return "", nil
+	}
+
+	c.mutex.RLock()
+	token := c.token
+	c.mutex.RUnlock()
+	if token != "" {
+		return token, nil
+	}
+
+	data, _ := json.Marshal(map[string]string{"name": c.username, "password": c.password})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint+"/v3/auth/authenticate", bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("etcd: authentication failed with status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("etcd: failed to decode auth response: %w", err)
+	}
+
+	c.mutex.Lock()
+	c.token = result.Token
+	c.mutex.Unlock()
+	return result.Token, nil
+}
+
+func (c *EtcdClient) endpoint() string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.endpoints[c.current]
+}
+
+func (c *EtcdClient) rotateEndpoint() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.current = (c.current + 1) % len(c.endpoints)
+	c.token = ""
+}
+
+func (c *EtcdClient) clearToken() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.token = ""
+}
+
+func decodeKV(raw etcdKV) (KeyValue, error) {
+	key, err := base64.StdEncoding.DecodeString(raw.Key)
+	if err != nil {
+		return KeyValue{}, fmt.Errorf("etcd: invalid key encoding: %w", err)
+	}
+	value, err := base64.StdEncoding.DecodeString(raw.Value)
+	if err != nil {
+		return KeyValue{}, fmt.Errorf("etcd: invalid value encoding for %s: %w", key, err)
+	}
+	revision, _ := strconv.ParseInt(raw.ModRevision, 10, 64)
+
+	return KeyValue{Key: string(key), Value: value, ModRevision: revision}, nil
+}
+
+func encodeKey(key string) string {
+	return base64.StdEncoding.EncodeToString([]byte(key))
+}
+
+// prefixEnd returns the smallest key greater than every key with the given
+// prefix, which is how etcd expresses prefix ranges.
+func prefixEnd(prefix string) string {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return string(end[:i+1])
+		}
+	}
+	return "\x00"
+}
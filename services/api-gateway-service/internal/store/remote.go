@@ -0,0 +1,177 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"gateway/internal/config"
+	"gateway/internal/models"
+)
+
+// consulWait bounds each Consul blocking query.
+const consulWait = 5 * time.Minute
+
+// NewRemoteSource returns the source of the remote configuration document
+// of cfg.
+func NewRemoteSource(cfg *models.GatewayConfig) (config.RemoteSource, error) {
+	switch cfg.RemoteConfig.Provider {
+	case models.RemoteConfigEtcd:
+		client, err := NewEtcdClient(cfg.Etcd)
+		if err != nil {
+			return nil, err
+		}
+		return NewEtcdConfigSource(client, cfg.RemoteConfig.Key), nil
+	case models.RemoteConfigConsul:
+		client, err := NewConsulClient(cfg.RemoteConfig.Consul)
+		if err != nil {
+			return nil, err
+		}
+		return NewConsulConfigSource(client, cfg.RemoteConfig.Key), nil
+	default:
+		return nil, fmt.Errorf("unknown remote config provider: %s", cfg.RemoteConfig.Provider)
+	}
+}
+
+// EtcdConfigSource serves a configuration document stored at an etcd key.
+type EtcdConfigSource struct {
+	client *EtcdClient
+	key    string
+
+	mutex    sync.Mutex
+	revision int64
+}
+
+func NewEtcdConfigSource(client *EtcdClient, key string) *EtcdConfigSource {
+	return &EtcdConfigSource{client: client, key: key}
+}
+
+func (s *EtcdConfigSource) String() string {
+	return "etcd key " + s.key
+}
+
+func (s *EtcdConfigSource) Fetch(ctx context.Context) ([]byte, error) {
+	kv, revision, err := s.client.Get(ctx, s.key)
+	if err != nil {
+		return nil, err
+	}
+	s.mutex.Lock()
+	if revision > s.revision {
+		s.revision = revision
+	}
+	s.mutex.Unlock()
+	if kv == nil {
+		return nil, nil
+	}
+	return kv.Value, nil
+}
+
+// Watch follows the key from the revision of the last fetch, so a change
+// made between the fetch and the watch is not missed.
+func (s *EtcdConfigSource) Watch(ctx context.Context, changed func()) {
+	watchWithRetry(ctx, s.String(), changed, func() error {
+		s.mutex.Lock()
+		startRevision := s.revision + 1
+		s.mutex.Unlock()
+		return s.client.WatchKey(ctx, s.key, startRevision, func([]WatchEvent) {
+			changed()
+		})
+	})
+}
+
+// ConsulConfigSource serves a configuration document stored at a Consul
+// KV key.
+type ConsulConfigSource struct {
+	client *ConsulClient
+	key    string
+
+	mutex sync.Mutex
+	value []byte
+	index uint64
+}
+
+func NewConsulConfigSource(client *ConsulClient, key string) *ConsulConfigSource {
+	return &ConsulConfigSource{client: client, key: key}
+}
+
+func (s *ConsulConfigSource) String() string {
+	return "consul key " + s.key
+}
+
+func (s *ConsulConfigSource) Fetch(ctx context.Context) ([]byte, error) {
+	value, index, err := s.client.Get(ctx, s.key, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	s.mutex.Lock()
+	s.value, s.index = value, index
+	s.mutex.Unlock()
+	return value, nil
+}
+
+// Watch runs blocking queries on the key from the index of the last fetch.
+// Consul may answer them without a change, so only a value different from
+// the one last seen counts.
+func (s *ConsulConfigSource) Watch(ctx context.Context, changed func()) {
+	watchWithRetry(ctx, s.String(), changed, func() error {
+		for ctx.Err() == nil {
+			s.mutex.Lock()
+			index := s.index
+			s.mutex.Unlock()
+			// Index 0 would not block
+			if index == 0 {
+				index = 1
+			}
+
+			value, next, err := s.client.Get(ctx, s.key, index, consulWait)
+			if err != nil {
+				return err
+			}
+			s.mutex.Lock()
+			// An index going backwards means Consul's was reset
+			if next < s.index {
+				next = 0
+			}
+			s.index = next
+			updated := !bytes.Equal(value, s.value)
+			s.value = value
+			s.mutex.Unlock()
+			if updated {
+				changed()
+			}
+		}
+		return ctx.Err()
+	})
+}
+
+// watchWithRetry runs watch until ctx is cancelled, backing off when it
+// fails. Changes may have been missed while the store was unreachable, so
+// changed is called after every reconnect.
+func watchWithRetry(ctx context.Context, name string, changed func(), watch func() error) {
+	backoff := time.Second
+	for ctx.Err() == nil {
+		started := time.Now()
+		err := watch()
+		if ctx.Err() != nil {
+			return
+		}
+		// A watch that held for a while was not failing repeatedly
+		if time.Since(started) > time.Minute {
+			backoff = time.Second
+		}
+
+		log.Printf("Watch on %s interrupted: %v (retrying in %s)", name, err, backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+		changed()
+	}
+}
@@ -0,0 +1,308 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"gateway/internal/config"
+	"gateway/internal/models"
+	"gateway/internal/registry"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RoutingStore keeps the service registry in sync with services and routes
+// stored under an etcd prefix:
+//
+//	<prefix>/services/<name>  service definition (YAML or JSON)
+//	<prefix>/routes/<id>      route definition (YAML or JSON), matched in key order
+//
+// Entries are validated as those of the config file are. Services and
+// routes of the config file and other sources are left untouched: etcd
+// services whose name another source registered, and routes overlapping
+// another source's, are skipped. Only entries owned by the store are
+// added, replaced or removed on change.
+type RoutingStore struct {
+	client   *EtcdClient
+	prefix   string
+	registry *registry.ServiceRegistry
+	manager  *config.Manager
+
+	mutex    sync.Mutex
+	services map[string]models.ServiceConfig
+	routes   []models.RouteConfig
+	revision int64
+
+	reloads *config.ReloadStats
+}
+
+// NewRoutingStore syncs serviceRegistry with the entries under prefix,
+// validated against the configuration of manager.
+func NewRoutingStore(client *EtcdClient, prefix string, serviceRegistry *registry.ServiceRegistry, manager *config.Manager) *RoutingStore {
+	return &RoutingStore{
+		client:   client,
+		prefix:   strings.TrimSuffix(prefix, "/"),
+		registry: serviceRegistry,
+		manager:  manager,
+		services: make(map[string]models.ServiceConfig),
+		reloads:  config.NewReloadStats("etcd"),
+	}
+}
+
+// ReloadStats tracks the syncs of the routing table.
+func (s *RoutingStore) ReloadStats() *config.ReloadStats {
+	return s.reloads
+}
+
+func (s *RoutingStore) servicesPrefix() string {
+	return s.prefix + "/services/"
+}
+
+func (s *RoutingStore) routesPrefix() string {
+	return s.prefix + "/routes/"
+}
+
+// Sync reads the full routing table from etcd and applies it to the registry.
+func (s *RoutingStore) Sync(ctx context.Context) error {
+	serviceKVs, _, err := s.client.GetPrefix(ctx, s.servicesPrefix())
+	if err != nil {
+		err = fmt.Errorf("failed to load services from etcd: %w", err)
+		s.reloads.Failed(config.ReloadLoad, err)
+		return err
+	}
+	routeKVs, revision, err := s.client.GetPrefix(ctx, s.routesPrefix())
+	if err != nil {
+		err = fmt.Errorf("failed to load routes from etcd: %w", err)
+		s.reloads.Failed(config.ReloadLoad, err)
+		return err
+	}
+
+	// Invalid entries are skipped; the rest of the table still applies
+	var rejected []string
+	current := s.manager.GetConfig()
+	takenServices, takenRoutes := s.taken()
+
+	services := make(map[string]models.ServiceConfig, len(serviceKVs))
+	for _, kv := range serviceKVs {
+		name := strings.TrimPrefix(kv.Key, s.servicesPrefix())
+		if takenServices[name] {
+			log.Printf("Skipping etcd service %s: service %s is registered by another source", kv.Key, name)
+			rejected = append(rejected, kv.Key)
+			continue
+		}
+		service, err := decodeService(name, kv.Value)
+		if err == nil {
+			err = config.ValidateService(name, service, current.CircuitBreaker)
+		}
+		if err != nil {
+			log.Printf("Skipping etcd service %s: %v", kv.Key, err)
+			rejected = append(rejected, kv.Key)
+			continue
+		}
+		services[name] = service
+	}
+
+	// Routes are checked against the services they will be registered
+	// with: those of other sources and the ones just loaded
+	candidate := *current
+	candidate.Services = s.registry.GetAllServices()
+	s.mutex.Lock()
+	for name := range s.services {
+		delete(candidate.Services, name)
+	}
+	s.mutex.Unlock()
+	for name, service := range services {
+		candidate.Services[name] = service
+	}
+
+	routes := make([]models.RouteConfig, 0, len(routeKVs))
+	for _, kv := range routeKVs {
+		route, err := decodeRoute(kv.Value)
+		if err == nil {
+			err = checkTakenRoute(route, takenRoutes)
+		}
+		if err == nil {
+			err = config.ValidateRoute("route", route, &candidate)
+		}
+		if err != nil {
+			log.Printf("Skipping etcd route %s: %v", kv.Key, err)
+			rejected = append(rejected, kv.Key)
+			continue
+		}
+		routes = append(routes, route)
+	}
+
+	diff, err := s.apply(services, routes, revision)
+	if err != nil {
+		err = fmt.Errorf("failed to apply etcd routing table: %w", err)
+		s.reloads.Failed(config.ReloadValidation, err)
+		return err
+	}
+	var rejectedErr error
+	if len(rejected) > 0 {
+		rejectedErr = fmt.Errorf("skipped invalid etcd entries: %s", strings.Join(rejected, ", "))
+	}
+	s.reloads.Applied(diff.Size(), config.Hash(struct {
+		Services map[string]models.ServiceConfig
+		Routes   []models.RouteConfig
+	}{services, routes}), rejectedErr)
+	return nil
+}
+
+// Watch keeps the registry updated until ctx is cancelled, resyncing the
+// whole table whenever anything under the prefix changes.
+func (s *RoutingStore) Watch(ctx context.Context) {
+	backoff := time.Second
+
+	for ctx.Err() == nil {
+		s.mutex.Lock()
+		startRevision := s.revision + 1
+		s.mutex.Unlock()
+
+		err := s.client.WatchPrefix(ctx, s.prefix+"/", startRevision, func(events []WatchEvent) {
+			if err := s.Sync(ctx); err != nil {
+				log.Printf("Failed to resync routing table from etcd: %v", err)
+			}
+		})
+		if ctx.Err() != nil {
+			return
+		}
+
+		log.Printf("etcd watch interrupted: %v (retrying in %s)", err, backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+
+		// Changes may have been missed while disconnected
+		if err := s.Sync(ctx); err != nil {
+			log.Printf("Failed to resync routing table from etcd: %v", err)
+		} else {
+			backoff = time.Second
+		}
+	}
+}
+
+// taken returns the service names and routes registered by other sources,
+// which etcd entries must not replace or shadow.
+func (s *RoutingStore) taken() (map[string]bool, []models.RouteConfig) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	services := make(map[string]bool)
+	for name := range s.registry.GetAllServices() {
+		if _, owned := s.services[name]; !owned {
+			services[name] = true
+		}
+	}
+	owned := make(map[string]int, len(s.routes))
+	for _, route := range s.routes {
+		owned[route.Path+" "+route.ServiceName]++
+	}
+	var routes []models.RouteConfig
+	for _, route := range s.registry.GetRoutes() {
+		if key := route.Path + " " + route.ServiceName; owned[key] > 0 {
+			owned[key]--
+			continue
+		}
+		routes = append(routes, route)
+	}
+	return services, routes
+}
+
+// checkTakenRoute fails when route overlaps one of taken. etcd routes are
+// matched ahead of other sources', so an overlapping one would take
+// requests from them.
+func checkTakenRoute(route models.RouteConfig, taken []models.RouteConfig) error {
+	var conflicts []string
+	for _, existing := range taken {
+		if registry.RoutesOverlap(route, existing) {
+			conflicts = append(conflicts, routeName(existing.Method, existing.Path))
+		}
+	}
+	if len(conflicts) > 0 {
+		return fmt.Errorf("overlaps %s of another source", strings.Join(conflicts, ", "))
+	}
+	return nil
+}
+
+// apply swaps the services and routes owned by the store for those loaded,
+// in one step, leaving other sources' entries in place.
+func (s *RoutingStore) apply(services map[string]models.ServiceConfig, routes []models.RouteConfig, revision int64) (config.Diff, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	diff := config.DiffRouting(s.services, services, s.routes, routes)
+	if err := s.registry.ReplaceRouting(s.services, services, s.routes, routes); err != nil {
+		return config.Diff{}, err
+	}
+
+	for name, previous := range s.services {
+		current, exists := services[name]
+		if !exists {
+			log.Printf("Removed etcd service: %s", name)
+		} else if !reflect.DeepEqual(previous, current) {
+			log.Printf("Updated etcd service: %s at %s", name, current.URL)
+		}
+	}
+	for name, service := range services {
+		if _, exists := s.services[name]; !exists {
+			log.Printf("Registered etcd service: %s at %s", name, service.URL)
+		}
+	}
+	if !reflect.DeepEqual(s.routes, routes) {
+		log.Printf("Applied %d etcd routes (revision %d)", len(routes), revision)
+	}
+
+	s.services = services
+	s.routes = routes
+	if revision > s.revision {
+		s.revision = revision
+	}
+	return diff, nil
+}
+
+func decodeService(name string, data []byte) (models.ServiceConfig, error) {
+	service := models.ServiceConfig{
+		Enabled: true,
+	}
+	if err := yaml.Unmarshal(data, &service); err != nil {
+		return service, fmt.Errorf("invalid service definition: %w", err)
+	}
+
+	// The key is the canonical name routes refer to
+	service.Name = name
+	if service.URL == "" {
+		return service, fmt.Errorf("service has empty URL")
+	}
+	if service.Timeout <= 0 {
+		service.Timeout = 30 * time.Second
+	}
+	return service, nil
+}
+
+func decodeRoute(data []byte) (models.RouteConfig, error) {
+	var route models.RouteConfig
+	if err := yaml.Unmarshal(data, &route); err != nil {
+		return route, fmt.Errorf("invalid route definition: %w", err)
+	}
+	if route.Path == "" {
+		return route, fmt.Errorf("route has empty path")
+	}
+	if route.ServiceName == "" {
+		return route, fmt.Errorf("route has empty service name")
+	}
+	if route.Method == "" {
+		route.Method = "*"
+	}
+	return route, nil
+}
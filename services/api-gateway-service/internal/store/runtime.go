@@ -0,0 +1,638 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"gateway/internal/config"
+	"gateway/internal/models"
+	"gateway/internal/registry"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	ErrServiceExists   = errors.New("service already exists")
+	ErrServiceNotFound = errors.New("service not found")
+	ErrRouteNotFound   = errors.New("route not found")
+	// ErrNotRuntime is returned for services and routes of the config
+	// file, etcd or self-registration, which the admin API leaves alone.
+	ErrNotRuntime     = errors.New("not managed through the admin API")
+	ErrInvalidService = errors.New("invalid service definition")
+	ErrInvalidRoute   = errors.New("invalid route definition")
+	// ErrRoutingRefused is returned when the routing table would be left
+	// inconsistent, as when routes still use a service being removed.
+	ErrRoutingRefused = errors.New("routing table refused the change")
+)
+
+// serviceName matches the names services can be given, which routes and
+// config keys can refer to.
+var serviceName = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+// RouteConflictError lists the routes a new route overlaps.
+type RouteConflictError struct {
+	Route     string
+	Conflicts []string
+}
+
+func (e *RouteConflictError) Error() string {
+	return fmt.Sprintf("route %s overlaps %s", e.Route, strings.Join(e.Conflicts, ", "))
+}
+
+// RuntimeRouting holds the services and routes added through the admin
+// API. They are registered next to those of the config file, etcd and
+// self-registration, and with a file they are saved to it on every change
+// and registered again on startup. Services and routes are defined in
+// YAML or JSON as in the config file; services are registered under the
+// name they are given, and routes are told apart by method and path.
+type RuntimeRouting struct {
+	registry *registry.ServiceRegistry
+	manager  *config.Manager
+	path     string
+
+	mutex       sync.Mutex
+	services    map[string]models.ServiceConfig
+	definitions map[string]interface{}
+	// Routes and their definitions, in the order they were added
+	routes           []models.RouteConfig
+	routeDefinitions []interface{}
+}
+
+// runtimeDocument is the file RuntimeRouting saves to, shaped like the
+// config file.
+type runtimeDocument struct {
+	Services map[string]interface{} `yaml:"services,omitempty"`
+	Routes   []interface{}          `yaml:"routes,omitempty"`
+}
+
+// NewRuntimeRouting manages services in serviceRegistry, validated against
+// the configuration of manager. With an empty path nothing is saved.
+func NewRuntimeRouting(serviceRegistry *registry.ServiceRegistry, manager *config.Manager, path string) *RuntimeRouting {
+	return &RuntimeRouting{
+		registry:    serviceRegistry,
+		manager:     manager,
+		path:        path,
+		services:    make(map[string]models.ServiceConfig),
+		definitions: make(map[string]interface{}),
+	}
+}
+
+// Load registers the services saved in the file; a missing file has none.
+// Saved services that are invalid, or that another source registered in
+// the meantime, are skipped and logged.
+func (r *RuntimeRouting) Load() error {
+	if r.path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(r.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var saved runtimeDocument
+	if err := yaml.Unmarshal(data, &saved); err != nil {
+		return fmt.Errorf("parsing %s: %w", r.path, err)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	names := make([]string, 0, len(saved.Services))
+	for name := range saved.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, exists := r.registry.GetService(name); exists {
+			log.Printf("Skipping saved service %s: %v", name, ErrServiceExists)
+			continue
+		}
+		definition, err := yaml.Marshal(saved.Services[name])
+		if err == nil {
+			err = r.put(name, definition)
+		}
+		if err != nil {
+			log.Printf("Skipping saved service %s: %v", name, err)
+		}
+	}
+
+	var routes []models.RouteConfig
+	var definitions []interface{}
+	for _, saved := range saved.Routes {
+		definition, err := yaml.Marshal(saved)
+		if err != nil {
+			log.Printf("Skipping saved route: %v", err)
+			continue
+		}
+		route, document, err := r.decodeRoute(definition)
+		if err == nil {
+			err = r.checkConflicts(route, nil, routes)
+		}
+		if err != nil {
+			log.Printf("Skipping saved route: %v", err)
+			continue
+		}
+		routes = append(routes, route)
+		definitions = append(definitions, document)
+	}
+	if err := r.applyRoutes(routes, definitions); err != nil {
+		log.Printf("Skipping saved routes: %v", err)
+	}
+	return nil
+}
+
+// Get returns the service name if it is managed through the admin API.
+func (r *RuntimeRouting) Get(name string) (models.ServiceConfig, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	service, managed := r.services[name]
+	return service, managed
+}
+
+// Create adds the service name, defined by definition. It fails with
+// ErrServiceExists when any source has registered name already.
+func (r *RuntimeRouting) Create(name string, definition []byte) (models.ServiceConfig, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.registry.GetService(name); exists {
+		return models.ServiceConfig{}, ErrServiceExists
+	}
+	if err := r.put(name, definition); err != nil {
+		return models.ServiceConfig{}, err
+	}
+	if err := r.save(); err != nil {
+		r.remove(name, nil)
+		return models.ServiceConfig{}, err
+	}
+	return r.services[name], nil
+}
+
+// Put adds the service name or replaces it in a single step; requests
+// already routed to it carry on with the previous definition, and its
+// health is kept unless its URL changed. It returns the service it
+// replaced, if any.
+func (r *RuntimeRouting) Put(name string, definition []byte) (*models.ServiceConfig, models.ServiceConfig, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	previous, managed := r.services[name]
+	if _, exists := r.registry.GetService(name); exists && !managed {
+		return nil, models.ServiceConfig{}, ErrNotRuntime
+	}
+	previousDefinition := r.definitions[name]
+	if err := r.put(name, definition); err != nil {
+		return nil, models.ServiceConfig{}, err
+	}
+	if err := r.save(); err != nil {
+		if managed {
+			r.restore(name, previous, previousDefinition)
+		} else {
+			r.remove(name, nil)
+		}
+		return nil, models.ServiceConfig{}, err
+	}
+	if !managed {
+		return nil, r.services[name], nil
+	}
+	return &previous, r.services[name], nil
+}
+
+// Delete removes the service name. It fails with ErrRoutingRefused while
+// routes still send requests to it.
+func (r *RuntimeRouting) Delete(name string) (models.ServiceConfig, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	service, managed := r.services[name]
+	if !managed {
+		if _, exists := r.registry.GetService(name); exists {
+			return models.ServiceConfig{}, ErrNotRuntime
+		}
+		return models.ServiceConfig{}, ErrServiceNotFound
+	}
+	// The config file may have taken the service over since
+	if _, configured := r.manager.GetConfig().Services[name]; configured {
+		return models.ServiceConfig{}, ErrNotRuntime
+	}
+	definition := r.definitions[name]
+	if err := r.remove(name, nil); err != nil {
+		return models.ServiceConfig{}, err
+	}
+	if err := r.save(); err != nil {
+		r.restore(name, service, definition)
+		return models.ServiceConfig{}, err
+	}
+	return service, nil
+}
+
+// put validates definition and registers it as the service name, in
+// place of the one the admin API manages under name, if any. The caller
+// holds the lock.
+func (r *RuntimeRouting) put(name string, definition []byte) error {
+	service, document, err := r.decodeServiceDefinition(name, definition)
+	if err != nil {
+		return err
+	}
+
+	previous := map[string]models.ServiceConfig{}
+	if existing, managed := r.services[name]; managed {
+		previous[name] = existing
+	}
+	if err := r.registry.ReplaceRouting(previous, map[string]models.ServiceConfig{name: service}, nil, nil); err != nil {
+		return fmt.Errorf("%w: %v", ErrRoutingRefused, err)
+	}
+	r.services[name] = service
+	r.definitions[name] = document
+	return nil
+}
+
+// decodeServiceDefinition validates definition as the service name
+// against the configuration.
+func (r *RuntimeRouting) decodeServiceDefinition(name string, definition []byte) (models.ServiceConfig, interface{}, error) {
+	if !serviceName.MatchString(name) {
+		return models.ServiceConfig{}, nil, fmt.Errorf("%w: name must be lowercase letters, digits, '.', '_' and '-'", ErrInvalidService)
+	}
+	current := r.manager.GetConfig()
+	if _, configured := current.Services[name]; configured {
+		return models.ServiceConfig{}, nil, ErrNotRuntime
+	}
+
+	if violations := config.CheckServiceSchema("definition", definition); len(violations) > 0 {
+		return models.ServiceConfig{}, nil, fmt.Errorf("%w: %w", ErrInvalidService, &config.SchemaError{Violations: violations})
+	}
+	var document interface{}
+	if err := yaml.Unmarshal(definition, &document); err != nil {
+		return models.ServiceConfig{}, nil, fmt.Errorf("%w: %v", ErrInvalidService, err)
+	}
+	if _, isMap := document.(map[string]interface{}); !isMap {
+		return models.ServiceConfig{}, nil, fmt.Errorf("%w: expected a mapping of settings", ErrInvalidService)
+	}
+	service, err := decodeService(name, definition)
+	if err != nil {
+		return models.ServiceConfig{}, nil, fmt.Errorf("%w: %v", ErrInvalidService, err)
+	}
+	if err := config.ValidateService(name, service, current.CircuitBreaker); err != nil {
+		return models.ServiceConfig{}, nil, fmt.Errorf("%w: %v", ErrInvalidService, err)
+	}
+	return service, document, nil
+}
+
+// remove unregisters the service name, putting replacement in its place
+// when set. The caller holds the lock.
+func (r *RuntimeRouting) remove(name string, replacement *models.ServiceConfig) error {
+	current := map[string]models.ServiceConfig{}
+	if replacement != nil {
+		current[name] = *replacement
+	}
+	if err := r.registry.ReplaceRouting(map[string]models.ServiceConfig{name: r.services[name]}, current, nil, nil); err != nil {
+		return fmt.Errorf("%w: %v", ErrRoutingRefused, err)
+	}
+	delete(r.services, name)
+	delete(r.definitions, name)
+	return nil
+}
+
+// restore puts back service after a change that could not be saved.
+func (r *RuntimeRouting) restore(name string, service models.ServiceConfig, definition interface{}) {
+	if _, managed := r.services[name]; managed {
+		if err := r.remove(name, &service); err != nil {
+			log.Printf("Failed to restore service %s: %v", name, err)
+			return
+		}
+	} else if err := r.registry.ReplaceRouting(nil, map[string]models.ServiceConfig{name: service}, nil, nil); err != nil {
+		log.Printf("Failed to restore service %s: %v", name, err)
+		return
+	}
+	r.services[name] = service
+	r.definitions[name] = definition
+}
+
+// CreateRoute adds a route. It fails with a *RouteConflictError when the
+// route overlaps another.
+func (r *RuntimeRouting) CreateRoute(definition []byte) (models.RouteConfig, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	route, document, err := r.decodeRoute(definition)
+	if err != nil {
+		return models.RouteConfig{}, err
+	}
+	if err := r.checkConflicts(route, nil, nil); err != nil {
+		return models.RouteConfig{}, err
+	}
+	if err := r.changeRoutes(append(r.copyRoutes(), route), append(r.copyRouteDefinitions(), document)); err != nil {
+		return models.RouteConfig{}, err
+	}
+	return route, nil
+}
+
+// PutRoute adds a route or replaces the one added through the admin API
+// with the same method and path, in a single step. It returns the route
+// it replaced, if any.
+func (r *RuntimeRouting) PutRoute(definition []byte) (*models.RouteConfig, models.RouteConfig, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	route, document, err := r.decodeRoute(definition)
+	if err != nil {
+		return nil, models.RouteConfig{}, err
+	}
+	index := r.findRoute(route.Method, route.Path)
+	if index < 0 {
+		if r.registered(route.Method, route.Path) {
+			return nil, models.RouteConfig{}, ErrNotRuntime
+		}
+		if err := r.checkConflicts(route, nil, nil); err != nil {
+			return nil, models.RouteConfig{}, err
+		}
+		if err := r.changeRoutes(append(r.copyRoutes(), route), append(r.copyRouteDefinitions(), document)); err != nil {
+			return nil, models.RouteConfig{}, err
+		}
+		return nil, route, nil
+	}
+
+	previous := r.routes[index]
+	if err := r.checkConflicts(route, &previous, nil); err != nil {
+		return nil, models.RouteConfig{}, err
+	}
+	routes, definitions := r.copyRoutes(), r.copyRouteDefinitions()
+	routes[index], definitions[index] = route, document
+	if err := r.changeRoutes(routes, definitions); err != nil {
+		return nil, models.RouteConfig{}, err
+	}
+	return &previous, route, nil
+}
+
+// DeleteRoute removes the route added through the admin API with method,
+// "*" when empty, and path.
+func (r *RuntimeRouting) DeleteRoute(method, path string) (models.RouteConfig, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	method = routeMethod(method)
+	index := r.findRoute(method, path)
+	if index < 0 {
+		if r.registered(method, path) {
+			return models.RouteConfig{}, ErrNotRuntime
+		}
+		return models.RouteConfig{}, ErrRouteNotFound
+	}
+	route := r.routes[index]
+	routes := append(r.copyRoutes()[:index], r.routes[index+1:]...)
+	definitions := append(r.copyRouteDefinitions()[:index], r.routeDefinitions[index+1:]...)
+	if err := r.changeRoutes(routes, definitions); err != nil {
+		return models.RouteConfig{}, err
+	}
+	return route, nil
+}
+
+// decodeRoute validates the route definition against the configuration
+// and the services registered, from any source.
+func (r *RuntimeRouting) decodeRoute(definition []byte) (models.RouteConfig, interface{}, error) {
+	return r.decodeRouteFor(definition, r.registry.GetAllServices())
+}
+
+// decodeRouteFor validates the route definition against the configuration
+// and services.
+func (r *RuntimeRouting) decodeRouteFor(definition []byte, services map[string]models.ServiceConfig) (models.RouteConfig, interface{}, error) {
+	var route models.RouteConfig
+	if violations := config.CheckRouteSchema("definition", definition); len(violations) > 0 {
+		return route, nil, fmt.Errorf("%w: %w", ErrInvalidRoute, &config.SchemaError{Violations: violations})
+	}
+	var document interface{}
+	if err := yaml.Unmarshal(definition, &document); err != nil {
+		return route, nil, fmt.Errorf("%w: %v", ErrInvalidRoute, err)
+	}
+	if _, isMap := document.(map[string]interface{}); !isMap {
+		return route, nil, fmt.Errorf("%w: expected a mapping of settings", ErrInvalidRoute)
+	}
+	if err := yaml.Unmarshal(definition, &route); err != nil {
+		return route, nil, fmt.Errorf("%w: %v", ErrInvalidRoute, err)
+	}
+	route.Method = routeMethod(route.Method)
+
+	candidate := *r.manager.GetConfig()
+	candidate.Services = services
+	if err := config.ValidateRoute("route "+routeName(route.Method, route.Path), route, &candidate); err != nil {
+		return route, nil, fmt.Errorf("%w: %v", ErrInvalidRoute, err)
+	}
+	return route, document, nil
+}
+
+// Definitions returns the services and routes managed through the admin
+// API as they were defined, in the shape Replace takes.
+func (r *RuntimeRouting) Definitions() (map[string]interface{}, []interface{}) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	services := make(map[string]interface{}, len(r.definitions))
+	for name, definition := range r.definitions {
+		services[name] = definition
+	}
+	return services, r.copyRouteDefinitions()
+}
+
+// Replace puts services and routes, defined as Definitions returns them,
+// in place of all those managed through the admin API, in one step and
+// saved. When one of them is invalid, taken by another source or refused
+// by the routing table, nothing changes.
+func (r *RuntimeRouting) Replace(services map[string]interface{}, routes []interface{}) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	// Routes are checked against the services they will be registered with
+	candidates := r.registry.GetAllServices()
+	for name := range r.services {
+		delete(candidates, name)
+	}
+	replacements := make(map[string]models.ServiceConfig, len(services))
+	definitions := make(map[string]interface{}, len(services))
+	for name, saved := range services {
+		if _, exists := candidates[name]; exists {
+			return fmt.Errorf("service %s: %w", name, ErrNotRuntime)
+		}
+		definition, err := yaml.Marshal(saved)
+		if err != nil {
+			return fmt.Errorf("service %s: %w: %v", name, ErrInvalidService, err)
+		}
+		service, document, err := r.decodeServiceDefinition(name, definition)
+		if err != nil {
+			return fmt.Errorf("service %s: %w", name, err)
+		}
+		replacements[name], definitions[name] = service, document
+	}
+	for name, service := range replacements {
+		candidates[name] = service
+	}
+
+	var replacementRoutes []models.RouteConfig
+	var routeDefinitions []interface{}
+	for _, saved := range routes {
+		definition, err := yaml.Marshal(saved)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidRoute, err)
+		}
+		route, document, err := r.decodeRouteFor(definition, candidates)
+		if err != nil {
+			return err
+		}
+		var conflicts []string
+		for _, existing := range r.registry.GetRoutes() {
+			if r.findRoute(existing.Method, existing.Path) < 0 && registry.RoutesOverlap(route, existing) {
+				conflicts = append(conflicts, routeName(existing.Method, existing.Path))
+			}
+		}
+		for _, existing := range replacementRoutes {
+			if registry.RoutesOverlap(route, existing) {
+				conflicts = append(conflicts, routeName(existing.Method, existing.Path))
+			}
+		}
+		if len(conflicts) > 0 {
+			return &RouteConflictError{Route: routeName(route.Method, route.Path), Conflicts: conflicts}
+		}
+		replacementRoutes = append(replacementRoutes, route)
+		routeDefinitions = append(routeDefinitions, document)
+	}
+
+	if err := r.registry.ReplaceRouting(r.services, replacements, r.routes, replacementRoutes); err != nil {
+		return fmt.Errorf("%w: %v", ErrRoutingRefused, err)
+	}
+	previous, previousDefinitions := r.services, r.definitions
+	previousRoutes, previousRouteDefinitions := r.routes, r.routeDefinitions
+	r.services, r.definitions = replacements, definitions
+	r.routes, r.routeDefinitions = replacementRoutes, routeDefinitions
+	if err := r.save(); err != nil {
+		if err := r.registry.ReplaceRouting(replacements, previous, replacementRoutes, previousRoutes); err != nil {
+			log.Printf("Failed to restore services and routes: %v", err)
+		}
+		r.services, r.definitions = previous, previousDefinitions
+		r.routes, r.routeDefinitions = previousRoutes, previousRouteDefinitions
+		return err
+	}
+	return nil
+}
+
+// checkConflicts fails when route overlaps a route of the registry other
+// than except, or one of pending. The caller holds the lock.
+func (r *RuntimeRouting) checkConflicts(route models.RouteConfig, except *models.RouteConfig, pending []models.RouteConfig) error {
+	var conflicts []string
+	for _, existing := range r.registry.RouteConflicts(route, except) {
+		conflicts = append(conflicts, routeName(existing.Method, existing.Path))
+	}
+	for _, existing := range pending {
+		if registry.RoutesOverlap(route, existing) {
+			conflicts = append(conflicts, routeName(existing.Method, existing.Path))
+		}
+	}
+	if len(conflicts) > 0 {
+		return &RouteConflictError{Route: routeName(route.Method, route.Path), Conflicts: conflicts}
+	}
+	return nil
+}
+
+// changeRoutes swaps the managed routes for routes and saves them, or
+// leaves everything as it was. The caller holds the lock.
+func (r *RuntimeRouting) changeRoutes(routes []models.RouteConfig, definitions []interface{}) error {
+	previous, previousDefinitions := r.routes, r.routeDefinitions
+	if err := r.applyRoutes(routes, definitions); err != nil {
+		return err
+	}
+	if err := r.save(); err != nil {
+		if err := r.applyRoutes(previous, previousDefinitions); err != nil {
+			log.Printf("Failed to restore routes: %v", err)
+		}
+		return err
+	}
+	return nil
+}
+
+// applyRoutes swaps the managed routes for routes in the registry, in one
+// step. The caller holds the lock.
+func (r *RuntimeRouting) applyRoutes(routes []models.RouteConfig, definitions []interface{}) error {
+	if err := r.registry.ReplaceRouting(nil, nil, r.routes, routes); err != nil {
+		return fmt.Errorf("%w: %v", ErrRoutingRefused, err)
+	}
+	r.routes, r.routeDefinitions = routes, definitions
+	return nil
+}
+
+func (r *RuntimeRouting) findRoute(method, path string) int {
+	for i, route := range r.routes {
+		if route.Method == method && route.Path == path {
+			return i
+		}
+	}
+	return -1
+}
+
+// registered tells whether a route with method and path is in the
+// registry, from any source.
+func (r *RuntimeRouting) registered(method, path string) bool {
+	for _, route := range r.registry.GetRoutes() {
+		if route.Method == method && route.Path == path {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *RuntimeRouting) copyRoutes() []models.RouteConfig {
+	return append([]models.RouteConfig(nil), r.routes...)
+}
+
+func (r *RuntimeRouting) copyRouteDefinitions() []interface{} {
+	return append([]interface{}(nil), r.routeDefinitions...)
+}
+
+func routeMethod(method string) string {
+	if method == "" {
+		return "*"
+	}
+	return strings.ToUpper(method)
+}
+
+func routeName(method, path string) string {
+	return method + " " + path
+}
+
+// save writes the managed services to the file, replacing it in one step
+// so a crash never leaves half a file. The caller holds the lock.
+func (r *RuntimeRouting) save() error {
+	if r.path == "" {
+		return nil
+	}
+	data, err := yaml.Marshal(runtimeDocument{Services: r.definitions, Routes: r.routeDefinitions})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return fmt.Errorf("failed to save %s: %w", r.path, err)
+	}
+	// Definitions may carry credentials in headers
+	temp, err := os.CreateTemp(filepath.Dir(r.path), filepath.Base(r.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to save %s: %w", r.path, err)
+	}
+	defer os.Remove(temp.Name())
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		return fmt.Errorf("failed to save %s: %w", r.path, err)
+	}
+	if err := temp.Close(); err != nil {
+		return fmt.Errorf("failed to save %s: %w", r.path, err)
+	}
+	if err := os.Rename(temp.Name(), r.path); err != nil {
+		return fmt.Errorf("failed to save %s: %w", r.path, err)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+
+	"gateway/internal/models"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// NewACME returns the manager that obtains and renews certificates for the
+// configured domains, and only those, keeping them in the cache directory.
+// Using it means accepting the certificate authority's terms of service.
+func NewACME(config models.ACMEConfig) *autocert.Manager {
+	manager := &autocert.Manager{
+		Prompt:      autocert.AcceptTOS,
+		Cache:       autocert.DirCache(config.CacheDir),
+		HostPolicy:  autocert.HostWhitelist(config.Domains...),
+		Email:       config.Email,
+		RenewBefore: config.RenewBefore,
+	}
+	if config.DirectoryURL != "" {
+		manager.Client = &acme.Client{DirectoryURL: config.DirectoryURL}
+	}
+	return manager
+}
+
+// ACMEServer builds the listener's TLS configuration like Server, serving
+// the certificates manager obtains and answering TLS-ALPN-01 challenges.
+func ACMEServer(config models.TLSConfig, manager *autocert.Manager) (*tls.Config, error) {
+	tlsConfig, err := server(config, manager.GetCertificate)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig.NextProtos = []string{"h2", "http/1.1", acme.ALPNProto}
+	return tlsConfig, nil
+}
@@ -0,0 +1,162 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"gateway/internal/models"
+)
+
+// Versions are the TLS versions min_version takes.
+var Versions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Server builds the listener's TLS configuration, serving certificate.
+// Client certificates are verified against the client CA when a client
+// presents one, or on every connection with client_auth required.
+func Server(config models.TLSConfig, certificate *Certificate) (*tls.Config, error) {
+	return server(config, certificate.Get)
+}
+
+func server(config models.TLSConfig, getCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error)) (*tls.Config, error) {
+	minVersion, err := MinVersion(config.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+	cipherSuites, err := CipherSuites(config.CipherSuites)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		MinVersion:     minVersion,
+		CipherSuites:   cipherSuites,
+		GetCertificate: getCertificate,
+	}
+	if config.ClientCAFile == "" {
+		return tlsConfig, nil
+	}
+
+	pem, err := os.ReadFile(config.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CA: %w", err)
+	}
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in client CA file %s", config.ClientCAFile)
+	}
+	tlsConfig.ClientCAs = clientCAs
+	tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	if config.ClientAuth == models.ClientAuthRequired {
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
+// MinVersion returns the TLS version named by version, 1.2 when empty.
+func MinVersion(version string) (uint16, error) {
+	if version == "" {
+		return tls.VersionTLS12, nil
+	}
+	if id, ok := Versions[version]; ok {
+		return id, nil
+	}
+	return 0, fmt.Errorf("unsupported TLS version %q, expected 1.0, 1.1, 1.2 or 1.3", version)
+}
+
+// CipherSuites returns the IDs of the cipher suites named, such as
+// TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, or nil for Go's defaults when
+// none are. Suites with known weaknesses are refused. They only apply up
+// to TLS 1.2: TLS 1.3 suites are not configurable.
+func CipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	known := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+	insecure := make(map[string]bool)
+	for _, suite := range tls.InsecureCipherSuites() {
+		insecure[suite.Name] = true
+	}
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		name = strings.ToUpper(strings.TrimSpace(name))
+		if insecure[name] {
+			return nil, fmt.Errorf("cipher suite %s is insecure", name)
+		}
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown cipher suite %s", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// Certificate is the listener's certificate. One loaded from files is
+// replaced by Reload, so a renewed certificate is served to new
+// connections without a restart.
+type Certificate struct {
+	config  models.TLSConfig
+	current atomic.Pointer[tls.Certificate]
+}
+
+// LoadCertificate loads the certificate of config, from its files or from
+// the PEM it holds.
+func LoadCertificate(config models.TLSConfig) (*Certificate, error) {
+	certificate := &Certificate{config: config}
+	if err := certificate.Reload(); err != nil {
+		return nil, err
+	}
+	return certificate, nil
+}
+
+// Reload reads the certificate again. If it cannot be loaded, the current
+// one stays in use.
+func (c *Certificate) Reload() error {
+	var loaded tls.Certificate
+	var err error
+	if c.config.Cert != "" {
+		loaded, err = tls.X509KeyPair([]byte(c.config.Cert), []byte(c.config.Key))
+	} else {
+		loaded, err = tls.LoadX509KeyPair(c.config.CertFile, c.config.KeyFile)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load server certificate: %w", err)
+	}
+	if loaded.Leaf == nil {
+		if loaded.Leaf, err = x509.ParseCertificate(loaded.Certificate[0]); err != nil {
+			return fmt.Errorf("failed to parse server certificate: %w", err)
+		}
+	}
+	c.current.Store(&loaded)
+	return nil
+}
+
+// Files are the files the certificate is loaded from, none when the
+// config holds the PEM itself.
+func (c *Certificate) Files() []string {
+	if c.config.Cert != "" {
+		return nil
+	}
+	return []string{c.config.CertFile, c.config.KeyFile}
+}
+
+// Leaf is the certificate served, for its subject and expiry.
+func (c *Certificate) Leaf() *x509.Certificate {
+	return c.current.Load().Leaf
+}
+
+// Get serves the current certificate to every handshake.
+func (c *Certificate) Get(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return c.current.Load(), nil
+}
@@ -0,0 +1,101 @@
+// Package traffic counts the requests the gateway answers. The counters are
+// spread over shards, each on a cache line of its own, so requests served
+// on different cores do not contend on the same words.
+package traffic
+
+import (
+	"math/rand"
+	"net/http"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+type shard struct {
+	total        int64
+	success      int64
+	clientErrors int64
+	serverErrors int64
+	// nanos is the response time of all requests summed
+	nanos int64
+	_     [24]byte
+}
+
+// Counters tallies requests by outcome and their response times.
+type Counters struct {
+	shards []shard
+	mask   uint32
+}
+
+// NewCounters creates counters with a shard per processor, rounded up to a
+// power of two.
+func NewCounters() *Counters {
+	n := 1
+	for n < runtime.GOMAXPROCS(0) {
+		n <<= 1
+	}
+	return &Counters{shards: make([]shard, n), mask: uint32(n - 1)}
+}
+
+// Record counts a request answered with status after elapsed.
+func (c *Counters) Record(status int, elapsed time.Duration) {
+	s := &c.shards[rand.Uint32()&c.mask]
+	atomic.AddInt64(&s.total, 1)
+	switch {
+	case status >= http.StatusInternalServerError:
+		atomic.AddInt64(&s.serverErrors, 1)
+	case status >= http.StatusBadRequest:
+		atomic.AddInt64(&s.clientErrors, 1)
+	default:
+		atomic.AddInt64(&s.success, 1)
+	}
+	atomic.AddInt64(&s.nanos, int64(elapsed))
+}
+
+// Snapshot is the counts summed over the shards. Requests recorded while it
+// is taken may be in some of its counts and not yet in others.
+type Snapshot struct {
+	Total        int64
+	Success      int64
+	ClientErrors int64
+	ServerErrors int64
+	ResponseTime time.Duration
+}
+
+// Errors are the requests answered with a 4xx or 5xx status.
+func (s Snapshot) Errors() int64 {
+	return s.ClientErrors + s.ServerErrors
+}
+
+// AverageResponseTime is the mean response time in milliseconds.
+func (s Snapshot) AverageResponseTime() float64 {
+	if s.Total == 0 {
+		return 0
+	}
+	return float64(s.ResponseTime.Nanoseconds()) / 1e6 / float64(s.Total)
+}
+
+func (c *Counters) Snapshot() Snapshot {
+	var snapshot Snapshot
+	for i := range c.shards {
+		s := &c.shards[i]
+		snapshot.Total += atomic.LoadInt64(&s.total)
+		snapshot.Success += atomic.LoadInt64(&s.success)
+		snapshot.ClientErrors += atomic.LoadInt64(&s.clientErrors)
+		snapshot.ServerErrors += atomic.LoadInt64(&s.serverErrors)
+		snapshot.ResponseTime += time.Duration(atomic.LoadInt64(&s.nanos))
+	}
+	return snapshot
+}
+
+func (c *Counters) Stats() map[string]interface{} {
+	snapshot := c.Snapshot()
+	return map[string]interface{}{
+		"total":             snapshot.Total,
+		"success":           snapshot.Success,
+		"errors":            snapshot.Errors(),
+		"client_errors":     snapshot.ClientErrors,
+		"server_errors":     snapshot.ServerErrors,
+		"avg_response_time": snapshot.AverageResponseTime(),
+	}
+}
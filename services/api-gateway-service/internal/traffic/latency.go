@@ -0,0 +1,188 @@
+package traffic
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Latencies are kept for DefaultLatencyWindow, in DefaultLatencySlots
+// slots that expire one by one.
+const (
+	DefaultLatencyWindow = 5 * time.Minute
+	DefaultLatencySlots  = 5
+)
+
+// Histogram buckets grow by a quarter of a doubling, from 1ms to about 65s,
+// so percentiles are accurate to within 19%. Slower requests share the
+// last bucket.
+const (
+	bucketsPerDoubling = 4
+	latencyBuckets     = 16*bucketsPerDoubling + 1
+)
+
+// Latencies keeps a latency histogram per route over a sliding window, to
+// find the routes that got slow without an external APM.
+type Latencies struct {
+	window time.Duration
+	slot   time.Duration
+	slots  []latencySlot
+	mutex  sync.Mutex
+}
+
+type latencySlot struct {
+	start  time.Time
+	routes map[string]*histogram
+}
+
+type histogram struct {
+	service string
+	buckets [latencyBuckets]int64
+	count   int64
+	sum     time.Duration
+	max     time.Duration
+}
+
+// NewLatencies keeps latencies for window, split into slots of equal
+// length.
+func NewLatencies(window time.Duration, slots int) *Latencies {
+	return &Latencies{
+		window: window,
+		slot:   window / time.Duration(slots),
+		slots:  make([]latencySlot, slots),
+	}
+}
+
+// Window is how far back the latencies go.
+func (l *Latencies) Window() time.Duration {
+	return l.window
+}
+
+// Record counts a request to route, served by service, that took elapsed.
+func (l *Latencies) Record(route, service string, elapsed time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	start := time.Now().Truncate(l.slot)
+	slot := &l.slots[int(start.UnixNano()/int64(l.slot))%len(l.slots)]
+	if !slot.start.Equal(start) {
+		slot.start = start
+		slot.routes = make(map[string]*histogram)
+	}
+	h, exists := slot.routes[route]
+	if !exists {
+		h = &histogram{service: service}
+		slot.routes[route] = h
+	}
+	h.buckets[bucket(elapsed)]++
+	h.count++
+	h.sum += elapsed
+	if elapsed > h.max {
+		h.max = elapsed
+	}
+}
+
+func bucket(elapsed time.Duration) int {
+	if elapsed <= time.Millisecond {
+		return 0
+	}
+	i := int(math.Ceil(math.Log2(float64(elapsed)/float64(time.Millisecond)) * bucketsPerDoubling))
+	if i >= latencyBuckets {
+		return latencyBuckets - 1
+	}
+	return i
+}
+
+// bucketBound is the highest latency counted in bucket i.
+func bucketBound(i int) time.Duration {
+	return time.Duration(float64(time.Millisecond) * math.Exp2(float64(i)/bucketsPerDoubling))
+}
+
+// RouteLatency is the latency distribution of one route's requests within
+// the window, in milliseconds.
+type RouteLatency struct {
+	Route    string  `json:"route"`
+	Service  string  `json:"service"`
+	Requests int64   `json:"requests"`
+	Mean     float64 `json:"mean_ms"`
+	P50      float64 `json:"p50_ms"`
+	P95      float64 `json:"p95_ms"`
+	P99      float64 `json:"p99_ms"`
+	Max      float64 `json:"max_ms"`
+}
+
+// Slowest returns up to limit routes by their p99 latency within the
+// window, slowest first. A limit of zero or less returns all of them.
+func (l *Latencies) Slowest(limit int) []RouteLatency {
+	l.mutex.Lock()
+	merged := make(map[string]*histogram)
+	now := time.Now()
+	for i := range l.slots {
+		slot := &l.slots[i]
+		if slot.routes == nil || now.Sub(slot.start) >= l.window {
+			continue
+		}
+		for route, h := range slot.routes {
+			m, exists := merged[route]
+			if !exists {
+				m = &histogram{service: h.service}
+				merged[route] = m
+			}
+			for b, count := range h.buckets {
+				m.buckets[b] += count
+			}
+			m.count += h.count
+			m.sum += h.sum
+			if h.max > m.max {
+				m.max = h.max
+			}
+		}
+	}
+	l.mutex.Unlock()
+
+	routes := make([]RouteLatency, 0, len(merged))
+	for route, h := range merged {
+		routes = append(routes, RouteLatency{
+			Route:    route,
+			Service:  h.service,
+			Requests: h.count,
+			Mean:     milliseconds(h.sum / time.Duration(h.count)),
+			P50:      milliseconds(h.percentile(0.50)),
+			P95:      milliseconds(h.percentile(0.95)),
+			P99:      milliseconds(h.percentile(0.99)),
+			Max:      milliseconds(h.max),
+		})
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].P99 != routes[j].P99 {
+			return routes[i].P99 > routes[j].P99
+		}
+		return routes[i].Route < routes[j].Route
+	})
+	if limit > 0 && len(routes) > limit {
+		routes = routes[:limit]
+	}
+	return routes
+}
+
+// percentile returns the upper bound of the bucket holding the quantile q,
+// or the slowest request if that is lower.
+func (h *histogram) percentile(q float64) time.Duration {
+	rank := int64(math.Ceil(q * float64(h.count)))
+	var seen int64
+	for i, count := range h.buckets {
+		seen += count
+		if seen >= rank {
+			if bound := bucketBound(i); i < latencyBuckets-1 && bound < h.max {
+				return bound
+			}
+			return h.max
+		}
+	}
+	return h.max
+}
+
+func milliseconds(d time.Duration) float64 {
+	return math.Round(float64(d)/float64(time.Microsecond)) / 1000
+}
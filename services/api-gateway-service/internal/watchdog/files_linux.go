@@ -0,0 +1,26 @@
+//go:build linux
+
+package watchdog
+
+import (
+	"os"
+	"syscall"
+)
+
+// openFiles counts the process's open file descriptors.
+func openFiles() int {
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		return -1
+	}
+	// One of them is the descriptor reading the directory
+	return len(entries) - 1
+}
+
+func fileLimit() int {
+	var limit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &limit); err != nil {
+		return 0
+	}
+	return int(limit.Cur)
+}
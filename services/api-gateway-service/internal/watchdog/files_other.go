@@ -0,0 +1,12 @@
+//go:build !linux
+
+package watchdog
+
+// Open file descriptors are only counted on Linux.
+func openFiles() int {
+	return -1
+}
+
+func fileLimit() int {
+	return 0
+}
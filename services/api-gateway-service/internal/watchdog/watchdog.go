@@ -0,0 +1,184 @@
+package watchdog
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gateway/internal/models"
+)
+
+// Watchdog samples goroutines, open file descriptors and connections so
+// leaks show up before they take the gateway down. When a sample exceeds
+// its threshold a warning is logged together with a goroutine dump, at
+// most once per DumpInterval.
+type Watchdog struct {
+	config models.WatchdogConfig
+
+	inbound  int64
+	upstream int64
+
+	mutex      sync.RWMutex
+	goroutines int
+	openFiles  int
+	fileLimit  int
+	lastDump   time.Time
+	warnings   int64
+}
+
+func New(config models.WatchdogConfig) *Watchdog {
+	return &Watchdog{
+		config:    config,
+		openFiles: -1,
+		fileLimit: fileLimit(),
+	}
+}
+
+// TrackConnState is an http.Server ConnState hook counting open client
+// connections.
+func (w *Watchdog) TrackConnState(conn net.Conn, state http.ConnState) {
+	switch state {
+	case http.StateNew:
+		atomic.AddInt64(&w.inbound, 1)
+	case http.StateClosed, http.StateHijacked:
+		atomic.AddInt64(&w.inbound, -1)
+	}
+}
+
+// DialFunc is the signature of http.Transport.DialContext.
+type DialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// CountDials wraps an upstream dialer so open upstream connections are
+// counted until they are closed.
+func (w *Watchdog) CountDials(dial DialFunc) DialFunc {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		conn, err := dial(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+		atomic.AddInt64(&w.upstream, 1)
+		return &countedConn{Conn: conn, open: &w.upstream}, nil
+	}
+}
+
+func (w *Watchdog) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.config.Interval)
+	defer ticker.Stop()
+
+	w.Check()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.Check()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Check takes a sample and warns about every threshold it exceeds.
+func (w *Watchdog) Check() {
+	goroutines := runtime.NumGoroutine()
+	files := openFiles()
+
+	w.mutex.Lock()
+	w.goroutines, w.openFiles = goroutines, files
+	w.mutex.Unlock()
+
+	exceeded := make([]string, 0)
+	if w.config.MaxGoroutines > 0 && goroutines > w.config.MaxGoroutines {
+		exceeded = append(exceeded, fmt.Sprintf("goroutines %d > %d", goroutines, w.config.MaxGoroutines))
+	}
+	if limit := w.maxOpenFiles(); limit > 0 && files > limit {
+		exceeded = append(exceeded, fmt.Sprintf("open files %d > %d", files, limit))
+	}
+	if inbound := atomic.LoadInt64(&w.inbound); w.config.MaxConnections > 0 && inbound > int64(w.config.MaxConnections) {
+		exceeded = append(exceeded, fmt.Sprintf("client connections %d > %d", inbound, w.config.MaxConnections))
+	}
+	if upstream := atomic.LoadInt64(&w.upstream); w.config.MaxUpstreamConnections > 0 && upstream > int64(w.config.MaxUpstreamConnections) {
+		exceeded = append(exceeded, fmt.Sprintf("upstream connections %d > %d", upstream, w.config.MaxUpstreamConnections))
+	}
+	if len(exceeded) == 0 {
+		return
+	}
+
+	atomic.AddInt64(&w.warnings, 1)
+	log.Printf("WARNING: watchdog thresholds exceeded, possible leak: %v", exceeded)
+	w.dump()
+}
+
+func (w *Watchdog) Stats() map[string]interface{} {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+
+	return map[string]interface{}{
+		"goroutines":           w.goroutines,
+		"open_files":           w.openFiles,
+		"open_files_limit":     w.fileLimit,
+		"client_connections":   atomic.LoadInt64(&w.inbound),
+		"upstream_connections": atomic.LoadInt64(&w.upstream),
+		"warnings":             atomic.LoadInt64(&w.warnings),
+	}
+}
+
+// maxOpenFiles is the configured threshold, or 80% of the process limit
+// when none is set.
+func (w *Watchdog) maxOpenFiles() int {
+	if w.config.MaxOpenFiles > 0 {
+		return w.config.MaxOpenFiles
+	}
+	return w.fileLimit * 8 / 10
+}
+
+// dump writes the goroutine profile, with identical stacks grouped and
+// counted, to DumpDir or else to the log.
+func (w *Watchdog) dump() {
+	w.mutex.Lock()
+	if !w.lastDump.IsZero() && time.Since(w.lastDump) < w.config.DumpInterval {
+		w.mutex.Unlock()
+		return
+	}
+	w.lastDump = time.Now()
+	w.mutex.Unlock()
+
+	var profile bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&profile, 1); err != nil {
+		log.Printf("Watchdog goroutine dump failed: %v", err)
+		return
+	}
+
+	if w.config.DumpDir == "" {
+		log.Printf("Goroutine dump:\n%s", profile.String())
+		return
+	}
+	path := filepath.Join(w.config.DumpDir, fmt.Sprintf("goroutines-%s.txt", time.Now().Format("20060102-150405")))
+	if err := os.WriteFile(path, profile.Bytes(), 0o644); err != nil {
+		log.Printf("Watchdog could not write goroutine dump: %v", err)
+		return
+	}
+	log.Printf("Goroutine dump written to %s", path)
+}
+
+type countedConn struct {
+	net.Conn
+	open   *int64
+	closed int32
+}
+
+func (c *countedConn) Close() error {
+	if atomic.CompareAndSwapInt32(&c.closed, 0, 1) {
+		atomic.AddInt64(c.open, -1)
+	}
+	return c.Conn.Close()
+}
@@ -0,0 +1,31 @@
+//go:build linux
+
+package workers
+
+import (
+	"context"
+	"net"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// Listen opens a TCP listener on address. With reusePort, other processes
+// may listen on the same address and the kernel spreads connections
+// across them.
+func Listen(address string, reusePort bool) (net.Listener, error) {
+	config := net.ListenConfig{}
+	if reusePort {
+		config.Control = func(network, address string, conn syscall.RawConn) error {
+			var sockErr error
+			err := conn.Control(func(fd uintptr) {
+				sockErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+			})
+			if err != nil {
+				return err
+			}
+			return sockErr
+		}
+	}
+	return config.Listen(context.Background(), "tcp", address)
+}
@@ -0,0 +1,17 @@
+//go:build !linux
+
+package workers
+
+import (
+	"errors"
+	"net"
+)
+
+// Listen opens a TCP listener on address. Sharing it with reusePort is only
+// supported on Linux.
+func Listen(address string, reusePort bool) (net.Listener, error) {
+	if reusePort {
+		return nil, errors.New("SO_REUSEPORT workers are only supported on linux")
+	}
+	return net.Listen("tcp", address)
+}
@@ -0,0 +1,129 @@
+package workers
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// WorkerEnv numbers a worker process, from 1
+	WorkerEnv = "GATEWAY_WORKER_ID"
+	// CoordinatorEnv is the socket of the supervisor's rate limiter
+	CoordinatorEnv = "GATEWAY_RATE_LIMIT_SOCKET"
+)
+
+// ID returns the number of the worker this process is, or 0 when it is
+// not a worker.
+func ID() int {
+	id, _ := strconv.Atoi(os.Getenv(WorkerEnv))
+	return id
+}
+
+// crashWindow is how long a worker must have run for its exit not to
+// count as a crash loop; workers that exit sooner are restarted after
+// restartDelay.
+const (
+	crashWindow  = 10 * time.Second
+	restartDelay = time.Second
+)
+
+// Supervise runs count copies of this program as workers, with env added to
+// their environment, and restarts any that exit until ctx is done. Workers
+// are then sent SIGTERM and waited for. Signals received on forward, such
+// as SIGHUP, are passed on to every running worker.
+func Supervise(ctx context.Context, count int, env []string, forward <-chan os.Signal) {
+	running := &processes{byID: make(map[int]*os.Process)}
+	go func() {
+		for {
+			select {
+			case sig := <-forward:
+				running.signal(sig)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for id := 1; id <= count; id++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			supervise(ctx, id, env, running)
+		}(id)
+	}
+	wg.Wait()
+}
+
+// processes are the running workers, by ID.
+type processes struct {
+	mutex sync.Mutex
+	byID  map[int]*os.Process
+}
+
+func (p *processes) set(id int, process *os.Process) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if process == nil {
+		delete(p.byID, id)
+		return
+	}
+	p.byID[id] = process
+}
+
+func (p *processes) signal(sig os.Signal) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for id, process := range p.byID {
+		if err := process.Signal(sig); err != nil {
+			log.Printf("Failed to pass %s on to worker %d: %v", sig, id, err)
+		}
+	}
+}
+
+func supervise(ctx context.Context, id int, env []string, running *processes) {
+	for {
+		started := time.Now()
+		err := run(ctx, id, env, running)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Worker %d exited: %v; restarting", id, err)
+
+		if time.Since(started) < crashWindow {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(restartDelay):
+			}
+		}
+	}
+}
+
+func run(ctx context.Context, id int, env []string, running *processes) error {
+	cmd := exec.Command(os.Args[0], os.Args[1:]...)
+	cmd.Env = append(append(os.Environ(), env...), WorkerEnv+"="+strconv.Itoa(id))
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	running.set(id, cmd.Process)
+	defer running.set(id, nil)
+
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+	select {
+	case err := <-exited:
+		return err
+	case <-ctx.Done():
+		cmd.Process.Signal(syscall.SIGTERM)
+		return <-exited
+	}
+}
@@ -0,0 +1,202 @@
+// Package gateway is the routing and proxy engine of the API gateway as a
+// library, for edge services that want the gateway's routing, proxying and
+// middleware pipeline with their own wiring:
+//
+//	engine := gateway.New()
+//	engine.AddService(gateway.ServiceConfig{Name: "products", URL: "http://products:8080", Timeout: 5 * time.Second})
+//	engine.AddRoute(*gateway.NewRouteConfig("/api/products/*", "products"))
+//	engine.Use(requestID, authenticate)
+//	http.ListenAndServe(":8080", engine.Handler())
+//
+// Routes match the full request path, so they live under the engine's
+// prefix, /api unless WithPrefix says otherwise.
+package gateway
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"gateway/internal/models"
+	"gateway/internal/proxy"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultPrefix is where proxied routes are mounted.
+const DefaultPrefix = "/api"
+
+type (
+	ServiceConfig = models.ServiceConfig
+	RouteConfig   = models.RouteConfig
+)
+
+// NewServiceConfig and NewRouteConfig fill in the same defaults as the
+// gateway's configuration file.
+var (
+	NewServiceConfig = models.NewServiceConfig
+	NewRouteConfig   = models.NewRouteConfig
+)
+
+// Gateway routes requests under its prefix to the registered services
+// through the middleware added with Use.
+type Gateway struct {
+	registry *registry.ServiceRegistry
+	proxy    *proxy.Proxy
+	router   *gin.Engine
+	prefix   string
+
+	mutex      sync.Mutex
+	middleware []gin.HandlerFunc
+	mounted    bool
+}
+
+type Option func(*Gateway)
+
+// WithPrefix mounts proxied routes under prefix instead of /api.
+func WithPrefix(prefix string) Option {
+	return func(g *Gateway) {
+		g.prefix = "/" + strings.Trim(prefix, "/")
+	}
+}
+
+// WithRouter mounts proxied routes on an existing router, next to its own
+// endpoints. The router's global middleware is left to its owner;
+// otherwise the engine creates a router that recovers from panics.
+func WithRouter(router *gin.Engine) Option {
+	return func(g *Gateway) {
+		g.router = router
+	}
+}
+
+// WithRegistry routes with a registry that is shared with other
+// components, such as a dynamic routing store.
+func WithRegistry(serviceRegistry *registry.ServiceRegistry) Option {
+	return func(g *Gateway) {
+		g.registry = serviceRegistry
+	}
+}
+
+func New(options ...Option) *Gateway {
+	g := &Gateway{prefix: DefaultPrefix}
+	for _, option := range options {
+		option(g)
+	}
+	if g.registry == nil {
+		g.registry = registry.NewServiceRegistry()
+	}
+	if g.router == nil {
+		g.router = gin.New()
+		g.router.Use(gin.Recovery())
+	}
+	g.proxy = proxy.New(g.registry)
+	return g
+}
+
+// AddService registers service, replacing any service of the same name.
+func (g *Gateway) AddService(service ServiceConfig) error {
+	if service.Name == "" {
+		return fmt.Errorf("service has empty name")
+	}
+	target, err := url.Parse(service.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return fmt.Errorf("service %s has invalid URL: %s", service.Name, service.URL)
+	}
+	if service.Timeout < 0 {
+		return fmt.Errorf("service %s timeout must not be negative", service.Name)
+	}
+	g.registry.RegisterService(service)
+	return nil
+}
+
+// AddRoute registers route. Its services must have been added first.
+func (g *Gateway) AddRoute(route RouteConfig) error {
+	if route.Path == "" {
+		return fmt.Errorf("route has empty path")
+	}
+	if !route.IsMigration() && !strings.HasPrefix(route.Path, g.prefix+"/") {
+		return fmt.Errorf("route %s is outside the gateway prefix %s", route.Path, g.prefix)
+	}
+
+	var services []string
+	switch {
+	case route.IsMigration():
+	case route.IsComposite():
+		for _, leg := range route.Composite.Legs {
+			services = append(services, leg.ServiceName)
+		}
+	default:
+		services = append(services, route.ServiceName)
+		if route.Canary != nil {
+			services = append(services, route.Canary.ServiceName)
+		}
+	}
+	for _, name := range services {
+		if _, exists := g.registry.GetService(name); !exists {
+			return fmt.Errorf("route %s references non-existent service: %s", route.Path, name)
+		}
+	}
+	g.registry.RegisterRoute(route)
+	return nil
+}
+
+// Use appends middleware to the pipeline of proxied requests, in order. It
+// must be called before Handler.
+func (g *Gateway) Use(middleware ...gin.HandlerFunc) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if g.mounted {
+		panic("gateway: Use called after Handler")
+	}
+	g.middleware = append(g.middleware, middleware...)
+}
+
+// Handler mounts the proxy behind the middleware pipeline, the first time
+// it is called, and returns the router. Requests that fail anywhere in the
+// pipeline are counted in the proxy's Terminations.
+func (g *Gateway) Handler() http.Handler {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if !g.mounted {
+		group := g.router.Group(g.prefix)
+		group.Use(g.proxy.TrackTerminations)
+		group.Use(g.middleware...)
+		group.Any("/*proxyPath", g.proxy.Handle)
+		g.mounted = true
+	}
+	return g.router
+}
+
+// StartHealthChecking probes the registered services every interval, or as
+// often as each service asks, until Stop.
+func (g *Gateway) StartHealthChecking(interval time.Duration) {
+	g.registry.StartHealthChecking(interval)
+}
+
+// Stop stops health checking.
+func (g *Gateway) Stop() {
+	g.registry.StopHealthChecking()
+}
+
+// Registry exposes the registry behind the engine.
+func (g *Gateway) Registry() *registry.ServiceRegistry {
+	return g.registry
+}
+
+// TerminationReason returns why a request ended with a 4xx or 5xx status,
+// such as "rate_limited" or "upstream_timeout", or "" if it did not.
+// Middleware can annotate logs or trace spans with it after c.Next.
+func TerminationReason(c *gin.Context) string {
+	return proxy.Termination(c)
+}
+
+// Proxy exposes the proxy behind the engine, to configure it further.
+func (g *Gateway) Proxy() *proxy.Proxy {
+	return g.proxy
+}
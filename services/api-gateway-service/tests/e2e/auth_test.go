@@ -0,0 +1,42 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthentication(t *testing.T) {
+	gateway := StartGateway(t, gatewayBinary, "gateway.yaml", mocks)
+
+	bearer := func(token string) map[string]string {
+		return map[string]string{"Authorization": "Bearer " + token, "X-User-ID": "1"}
+	}
+
+	resp := gateway.Get(t, "/api/orders/1")
+	assert.Equal(t, http.StatusUnauthorized, resp.Status)
+	assert.Contains(t, resp.Header.Get("WWW-Authenticate"), "Bearer")
+
+	assert.Equal(t, http.StatusUnauthorized, gateway.Do(t, http.MethodGet, "/api/orders/1", bearer("bogus"), nil).Status)
+	assert.Equal(t, http.StatusForbidden, gateway.Do(t, http.MethodGet, "/api/orders/1", bearer(ForbiddenToken), nil).Status)
+
+	// Identity headers come from the token, never from the client
+	resp = gateway.Do(t, http.MethodGet, "/api/orders/1", bearer(ValidToken), nil)
+	require.Equal(t, http.StatusOK, resp.Status)
+	e := decodeEcho(t, resp)
+	assert.Equal(t, "42", e.Headers["X-User-Id"])
+	assert.Equal(t, "john@example.com", e.Headers["X-User-Email"])
+
+	// Optional routes serve anonymous clients and identify known ones
+	resp = gateway.Do(t, http.MethodGet, "/api/products/1", map[string]string{"X-User-ID": "1"}, nil)
+	require.Equal(t, http.StatusOK, resp.Status)
+	assert.Empty(t, decodeEcho(t, resp).Headers["X-User-Id"])
+
+	resp = gateway.Do(t, http.MethodGet, "/api/products/1", bearer(ValidToken), nil)
+	require.Equal(t, http.StatusOK, resp.Status)
+	assert.Equal(t, "42", decodeEcho(t, resp).Headers["X-User-Id"])
+}
@@ -0,0 +1,365 @@
+//go:build e2e
+
+// Package e2e runs the real gateway binary against mock upstreams. The
+// mocks run in containers from docker-compose.yml, or as local processes
+// with E2E_MOCKS=local for machines without Docker.
+//
+//	go test -tags e2e ./tests/e2e/
+package e2e
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"text/template"
+	"time"
+)
+
+// Tokens the mock auth service accepts and refuses.
+const (
+	ValidToken     = "valid-token"
+	ForbiddenToken = "forbidden-token"
+)
+
+const composeProject = "gateway-e2e"
+
+// mockPorts are the host ports the mock upstreams are published on.
+var mockPorts = map[string]int{
+	"orders":   18081,
+	"products": 18082,
+	"auth":     18083,
+}
+
+var client = &http.Client{
+	Timeout: 10 * time.Second,
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// Mocks are the running mock upstreams.
+type Mocks struct {
+	URLs map[string]string
+	stop func()
+}
+
+// StartMocks starts the mock upstreams and waits until they answer.
+func StartMocks(workDir string) (*Mocks, error) {
+	mocks := &Mocks{URLs: make(map[string]string)}
+	for name, port := range mockPorts {
+		mocks.URLs[name] = fmt.Sprintf("http://127.0.0.1:%d", port)
+	}
+
+	var err error
+	if os.Getenv("E2E_MOCKS") == "local" {
+		mocks.stop, err = startLocalMocks(workDir)
+	} else {
+		mocks.stop, err = startDockerMocks()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for name, url := range mocks.URLs {
+		if err := waitFor(url+"/health", 60*time.Second); err != nil {
+			mocks.Stop()
+			return nil, fmt.Errorf("mock %s did not start: %w", name, err)
+		}
+	}
+	return mocks, nil
+}
+
+func (m *Mocks) Stop() {
+	if m.stop != nil {
+		m.stop()
+	}
+}
+
+// SetHealth makes a mock's /health endpoint pass or fail.
+func (m *Mocks) SetHealth(t *testing.T, name string, up bool) {
+	state := "down"
+	if up {
+		state = "up"
+	}
+	resp, err := client.Post(m.URLs[name]+"/admin/health/"+state, "", nil)
+	if err != nil {
+		t.Fatalf("set health of mock %s: %v", name, err)
+	}
+	resp.Body.Close()
+}
+
+func startDockerMocks() (func(), error) {
+	if _, err := exec.LookPath("docker"); err != nil {
+		return nil, fmt.Errorf("docker not found; run with E2E_MOCKS=local to start the mocks as local processes")
+	}
+	compose := func(args ...string) *exec.Cmd {
+		cmd := exec.Command("docker", append([]string{"compose", "-f", "docker-compose.yml", "-p", composeProject}, args...)...)
+		cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+		return cmd
+	}
+	if err := compose("up", "-d", "--build").Run(); err != nil {
+		return nil, fmt.Errorf("docker compose up: %w", err)
+	}
+	return func() { compose("down", "--remove-orphans").Run() }, nil
+}
+
+func startLocalMocks(workDir string) (func(), error) {
+	binary := filepath.Join(workDir, "mockserver")
+	if err := build(binary, "gateway/tests/e2e/mockserver"); err != nil {
+		return nil, err
+	}
+
+	processes := make([]*exec.Cmd, 0, len(mockPorts))
+	stop := func() {
+		for _, cmd := range processes {
+			cmd.Process.Kill()
+			cmd.Wait()
+		}
+	}
+	for name, port := range mockPorts {
+		cmd := exec.Command(binary, "-name", name, "-addr", fmt.Sprintf("127.0.0.1:%d", port))
+		if err := cmd.Start(); err != nil {
+			stop()
+			return nil, fmt.Errorf("start mock %s: %w", name, err)
+		}
+		processes = append(processes, cmd)
+	}
+	return stop, nil
+}
+
+// BuildGateway compiles the gateway binary into dir.
+func BuildGateway(dir string) (string, error) {
+	binary := filepath.Join(dir, "gateway")
+	return binary, build(binary, "gateway/cmd/gateway")
+}
+
+func build(output, pkg string) error {
+	cmd := exec.Command("go", "build", "-o", output, pkg)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("build %s: %w", pkg, err)
+	}
+	return nil
+}
+
+// Gateway is a running gateway process.
+type Gateway struct {
+	URL    string
+	Dir    string
+	cmd    *exec.Cmd
+	output *syncBuffer
+	exited chan struct{}
+}
+
+// FixtureData is what fixture templates are rendered with.
+type FixtureData struct {
+	Port  int
+	Mocks map[string]string
+}
+
+// StartGateway renders fixtures/<fixture> into the config of a fresh
+// gateway process and waits until it is live. The process is stopped when
+// the test ends; its output is logged if the test failed.
+func StartGateway(t *testing.T, binary, fixture string, mocks *Mocks) *Gateway {
+	t.Helper()
+
+	port, err := freePort()
+	if err != nil {
+		t.Fatalf("find free port: %v", err)
+	}
+
+	dir := t.TempDir()
+	tmpl, err := template.ParseFiles(filepath.Join("fixtures", fixture))
+	if err != nil {
+		t.Fatalf("parse fixture %s: %v", fixture, err)
+	}
+	var config bytes.Buffer
+	if err := tmpl.Execute(&config, FixtureData{Port: port, Mocks: mocks.URLs}); err != nil {
+		t.Fatalf("render fixture %s: %v", fixture, err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "config"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "config", "config.yaml"), config.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	gateway := &Gateway{
+		URL:    fmt.Sprintf("http://127.0.0.1:%d", port),
+		Dir:    dir,
+		output: &syncBuffer{},
+		exited: make(chan struct{}),
+	}
+	gateway.cmd = exec.Command(binary)
+	gateway.cmd.Dir = dir
+	gateway.cmd.Env = gatewayEnv()
+	gateway.cmd.Stdout, gateway.cmd.Stderr = gateway.output, gateway.output
+	if err := gateway.cmd.Start(); err != nil {
+		t.Fatalf("start gateway: %v", err)
+	}
+	go func() {
+		gateway.cmd.Wait()
+		close(gateway.exited)
+	}()
+	t.Cleanup(func() {
+		gateway.Stop()
+		if t.Failed() {
+			t.Logf("gateway output:\n%s", gateway.output.String())
+		}
+	})
+
+	deadline := time.Now().Add(15 * time.Second)
+	for {
+		resp, err := client.Get(gateway.URL + "/health/live")
+		if err == nil {
+			resp.Body.Close()
+			return gateway
+		}
+		select {
+		case <-gateway.exited:
+			t.Fatalf("gateway exited during startup")
+		default:
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("gateway did not start: %v", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// Stop shuts the gateway down gracefully, killing it if that hangs.
+func (g *Gateway) Stop() {
+	select {
+	case <-g.exited:
+		return
+	default:
+	}
+	g.cmd.Process.Signal(syscall.SIGTERM)
+	select {
+	case <-g.exited:
+	case <-time.After(10 * time.Second):
+		g.cmd.Process.Kill()
+		<-g.exited
+	}
+}
+
+// Output is everything the gateway has logged so far.
+func (g *Gateway) Output() string {
+	return g.output.String()
+}
+
+// Response is a gateway response with its body read.
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// Do sends a request through the gateway. header values are set on the
+// request; body may be nil.
+func (g *Gateway) Do(t *testing.T, method, path string, header map[string]string, body io.Reader) *Response {
+	t.Helper()
+
+	req, err := http.NewRequest(method, g.URL+path, body)
+	if err != nil {
+		t.Fatalf("build request: %v", err)
+	}
+	for name, value := range header {
+		req.Header.Set(name, value)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read %s %s: %v", method, path, err)
+	}
+	return &Response{Status: resp.StatusCode, Header: resp.Header, Body: data}
+}
+
+func (g *Gateway) Get(t *testing.T, path string) *Response {
+	t.Helper()
+	return g.Do(t, http.MethodGet, path, nil, nil)
+}
+
+// gatewayEnv is the test's environment without GATEWAY_ variables, so a
+// developer's settings do not leak into the fixtures.
+func gatewayEnv() []string {
+	env := make([]string, 0)
+	for _, entry := range os.Environ() {
+		if !strings.HasPrefix(entry, "GATEWAY_") {
+			env = append(env, entry)
+		}
+	}
+	sort.Strings(env)
+	return env
+}
+
+func waitFor(url string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		resp, err := client.Get(url)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return nil
+			}
+			err = fmt.Errorf("status %d", resp.StatusCode)
+		}
+		if time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// Eventually polls condition until it holds or timeout passes.
+func Eventually(t *testing.T, timeout time.Duration, condition func() bool, message string) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out after %s: %s", timeout, message)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func freePort() (int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
+
+type syncBuffer struct {
+	mutex  sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.String()
+}
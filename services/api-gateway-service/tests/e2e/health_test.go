@@ -0,0 +1,25 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestUpstreamHealth(t *testing.T) {
+	gateway := StartGateway(t, gatewayBinary, "gateway.yaml", mocks)
+
+	ready := func() int {
+		return gateway.Get(t, "/health/ready").Status
+	}
+	Eventually(t, 10*time.Second, func() bool { return ready() == http.StatusOK }, "all upstreams become healthy")
+
+	mocks.SetHealth(t, "orders", false)
+	t.Cleanup(func() { mocks.SetHealth(t, "orders", true) })
+	Eventually(t, 10*time.Second, func() bool { return ready() == http.StatusServiceUnavailable }, "failing orders health check is noticed")
+
+	mocks.SetHealth(t, "orders", true)
+	Eventually(t, 10*time.Second, func() bool { return ready() == http.StatusOK }, "orders recovers")
+}
@@ -0,0 +1,31 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiting(t *testing.T) {
+	gateway := StartGateway(t, gatewayBinary, "rate_limit.yaml", mocks)
+
+	statuses := make(map[int]int)
+	var retryAfter string
+	for i := 0; i < 10; i++ {
+		resp := gateway.Get(t, "/api/products/1")
+		statuses[resp.Status]++
+		if resp.Status == http.StatusTooManyRequests {
+			retryAfter = resp.Header.Get("Retry-After")
+		}
+	}
+
+	assert.Equal(t, 5, statuses[http.StatusOK])
+	assert.Equal(t, 5, statuses[http.StatusTooManyRequests])
+	assert.NotEmpty(t, retryAfter)
+
+	// Probes are never limited
+	assert.Equal(t, http.StatusOK, gateway.Get(t, "/health").Status)
+}
@@ -0,0 +1,39 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+var (
+	gatewayBinary string
+	mocks         *Mocks
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(run(m))
+}
+
+func run(m *testing.M) int {
+	workDir, err := os.MkdirTemp("", "gateway-e2e")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer os.RemoveAll(workDir)
+
+	if gatewayBinary, err = BuildGateway(workDir); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if mocks, err = StartMocks(workDir); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer mocks.Stop()
+
+	return m.Run()
+}
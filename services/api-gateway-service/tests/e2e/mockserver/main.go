@@ -0,0 +1,136 @@
+// Command mockserver is an httpbin-like upstream for the end-to-end
+// suite. Every instance can stand in for any service and answers:
+//
+//	/anything/...         echoes the request as JSON
+//	/status/{code}        responds with the status code
+//	/delay/{duration}     responds after the delay, e.g. /delay/2s
+//	/auth/verify          verifies bearer tokens like the auth service
+//	/health               200, or 503 after POST /admin/health/down
+//	/admin/health/{up|down}
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Tokens the /auth/verify endpoint knows about.
+const (
+	ValidToken     = "valid-token"
+	ForbiddenToken = "forbidden-token"
+)
+
+type server struct {
+	name string
+	down atomic.Bool
+}
+
+func main() {
+	addr := flag.String("addr", envOr("MOCK_ADDR", ":8080"), "listen address")
+	name := flag.String("name", envOr("MOCK_NAME", "mock"), "name reported in responses")
+	flag.Parse()
+
+	s := &server{name: *name}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", s.health)
+	mux.HandleFunc("/admin/health/", s.setHealth)
+	mux.HandleFunc("/auth/verify", s.verify)
+	mux.HandleFunc("/status/", s.status)
+	mux.HandleFunc("/delay/", s.delay)
+	mux.HandleFunc("/", s.echo)
+
+	log.Printf("mock %s listening on %s", *name, *addr)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
+
+func (s *server) health(w http.ResponseWriter, r *http.Request) {
+	if s.down.Load() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "healthy", "service": s.name})
+}
+
+func (s *server) setHealth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	s.down.Store(strings.TrimPrefix(r.URL.Path, "/admin/health/") == "down")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *server) verify(w http.ResponseWriter, r *http.Request) {
+	switch r.Header.Get("Authorization") {
+	case "Bearer " + ValidToken:
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"valid":      true,
+			"user_id":    42,
+			"email":      "john@example.com",
+			"token_data": map[string]string{"scope": "orders:read"},
+		})
+	case "Bearer " + ForbiddenToken:
+		w.WriteHeader(http.StatusForbidden)
+	default:
+		w.WriteHeader(http.StatusUnauthorized)
+	}
+}
+
+func (s *server) status(w http.ResponseWriter, r *http.Request) {
+	code, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/status/"))
+	if err != nil || code < 100 || code > 599 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(code)
+}
+
+func (s *server) delay(w http.ResponseWriter, r *http.Request) {
+	delay, err := time.ParseDuration(strings.TrimPrefix(r.URL.Path, "/delay/"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	select {
+	case <-time.After(delay):
+		s.echo(w, r)
+	case <-r.Context().Done():
+	}
+}
+
+func (s *server) echo(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	headers := make(map[string]string, len(r.Header))
+	for name := range r.Header {
+		headers[name] = r.Header.Get(name)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"service": s.name,
+		"method":  r.Method,
+		"path":    r.URL.Path,
+		"query":   r.URL.RawQuery,
+		"headers": headers,
+		"body":    string(body),
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,66 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type echo struct {
+	Service string            `json:"service"`
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Query   string            `json:"query"`
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
+}
+
+func decodeEcho(t *testing.T, resp *Response) echo {
+	t.Helper()
+	var e echo
+	require.NoError(t, json.Unmarshal(resp.Body, &e), string(resp.Body))
+	return e
+}
+
+func TestProxying(t *testing.T) {
+	gateway := StartGateway(t, gatewayBinary, "gateway.yaml", mocks)
+
+	resp := gateway.Get(t, "/api/products/42?expand=reviews")
+	require.Equal(t, http.StatusOK, resp.Status)
+	e := decodeEcho(t, resp)
+	assert.Equal(t, "products", e.Service)
+	assert.Equal(t, "/api/products/42", e.Path)
+	assert.Equal(t, "expand=reviews", e.Query)
+	assert.Equal(t, "127.0.0.1", e.Headers["X-Forwarded-For"])
+
+	resp = gateway.Do(t, http.MethodPost, "/api/bin/anything/items", map[string]string{"Content-Type": "application/json"},
+		strings.NewReader(`{"sku": "A-1"}`))
+	require.Equal(t, http.StatusOK, resp.Status)
+	e = decodeEcho(t, resp)
+	assert.Equal(t, "POST", e.Method)
+	assert.Equal(t, "/anything/items", e.Path, "strip_prefix removes the route prefix")
+	assert.JSONEq(t, `{"sku": "A-1"}`, e.Body)
+
+	// Upstream errors pass through, slow upstreams time out
+	assert.Equal(t, http.StatusServiceUnavailable, gateway.Get(t, "/api/bin/status/503").Status)
+	assert.Equal(t, http.StatusGatewayTimeout, gateway.Get(t, "/api/bin/delay/3s").Status)
+
+	assert.Equal(t, http.StatusNotFound, gateway.Get(t, "/api/unknown").Status)
+}
+
+func TestProbesAndMetrics(t *testing.T) {
+	gateway := StartGateway(t, gatewayBinary, "gateway.yaml", mocks)
+
+	assert.Equal(t, http.StatusOK, gateway.Get(t, "/health").Status)
+
+	resp := gateway.Get(t, "/metrics")
+	assert.Equal(t, http.StatusOK, resp.Status)
+	assert.Contains(t, string(resp.Body), "gateway_goroutines ")
+	assert.Contains(t, string(resp.Body), `gateway_service_up{service="orders"`)
+}
@@ -0,0 +1,377 @@
+package integration
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"gateway/internal/auth"
+	"gateway/internal/config"
+	"gateway/internal/middleware"
+	"gateway/internal/models"
+	"gateway/internal/proxy"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newAccessLogRouter(format string, output io.Writer, quiet bool) *gin.Engine {
+	router := gin.New()
+	if format != "" {
+		router.Use(middleware.AccessLog(models.LoggingConfig{Format: format}, nil, output, func() bool { return quiet }))
+	}
+	router.Use(func(c *gin.Context) {
+		if c.GetHeader("Authorization") != "" {
+			c.Set(middleware.IdentityKey, &auth.Identity{UserID: "user-42"})
+		}
+	})
+	router.GET("/api/orders/:id", func(c *gin.Context) {
+		c.String(http.StatusOK, "order")
+	})
+	router.POST("/api/orders", func(c *gin.Context) {
+		c.Error(io.ErrUnexpectedEOF)
+		c.String(http.StatusBadRequest, "bad")
+	})
+	return router
+}
+
+func sampleLogEntry() *models.RequestLogEntry {
+	entry := models.NewRequestLogEntry("550e8400-e29b-41d4-a716-446655440000", http.MethodGet, "/api/orders/\"7\"", "192.168.1.100")
+	entry.SetService("orders")
+	entry.SetUser("user-42")
+	entry.SetResponse(http.StatusOK, 45200*time.Microsecond, 1024)
+	entry.AddHeader("User-Agent", "ios/3.1\n")
+	entry.AddHeader("Authorization", "Bearer secret")
+	return entry
+}
+
+func TestJSONAccessLog(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("Lines are structured JSON", func(t *testing.T) {
+		var output bytes.Buffer
+		router := newAccessLogRouter("json", &output, false)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/orders/7", nil)
+		req.Header.Set(middleware.CorrelationIDHeader, "corr-1")
+		req.Header.Set("Authorization", "Bearer token")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/orders", strings.NewReader(`{"id": 7}`)))
+
+		lines := strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n")
+		require.Len(t, lines, 2)
+
+		var first map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(lines[0]), &first))
+		assert.Equal(t, "corr-1", first["correlation_id"])
+		assert.Equal(t, "GET", first["method"])
+		assert.Equal(t, "/api/orders/7", first["path"])
+		assert.Equal(t, "user-42", first["user_id"])
+		assert.Equal(t, float64(200), first["status_code"])
+		assert.Equal(t, float64(5), first["response_size"])
+		assert.NotContains(t, first, "error")
+		_, err := time.Parse(time.RFC3339, first["timestamp"].(string))
+		assert.NoError(t, err)
+
+		var second map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(lines[1]), &second))
+		assert.Equal(t, float64(400), second["status_code"])
+		assert.Equal(t, float64(9), second["request_size"])
+		assert.Equal(t, io.ErrUnexpectedEOF.Error(), second["error"])
+		assert.NotContains(t, second, "user_id")
+	})
+
+	t.Run("Proxied requests name their service", func(t *testing.T) {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		defer upstream.Close()
+		serviceRegistry := registry.NewServiceRegistry()
+		serviceRegistry.RegisterService(*models.NewServiceConfig("orders", upstream.URL, time.Second))
+		serviceRegistry.RegisterRoute(*models.NewRouteConfig("/api/orders/*", "orders"))
+
+		var output bytes.Buffer
+		router := gin.New()
+		router.Use(middleware.AccessLog(models.LoggingConfig{Format: "json"}, nil, &output, func() bool { return false }))
+		router.Any("/api/*path", proxy.New(serviceRegistry).Handle)
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/orders/7", nil))
+
+		assert.Contains(t, output.String(), `"service_name":"orders"`)
+	})
+
+	t.Run("Quiet mode keeps only failures", func(t *testing.T) {
+		var output bytes.Buffer
+		router := newAccessLogRouter("json", &output, true)
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/orders/7", nil))
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/orders", nil))
+
+		assert.Equal(t, 1, strings.Count(output.String(), "\n"))
+		assert.Contains(t, output.String(), `"status_code":400`)
+	})
+
+	t.Run("Lines carry the level of their status", func(t *testing.T) {
+		var output bytes.Buffer
+		router := newAccessLogRouter("json", &output, false)
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/orders/7", nil))
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/orders", nil))
+
+		lines := strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n")
+		require.Len(t, lines, 2)
+		assert.Contains(t, lines[0], `"level":"info"`)
+		assert.Contains(t, lines[1], `"level":"warn"`)
+		assert.Equal(t, models.LogLevelError, models.StatusLogLevel(http.StatusBadGateway))
+	})
+
+	t.Run("Lines below the configured level are left out", func(t *testing.T) {
+		for level, expected := range map[string]int{"debug": 2, "info": 2, "warn": 1, "error": 0} {
+			for _, format := range []string{"json", "text"} {
+				var output bytes.Buffer
+				router := gin.New()
+				router.Use(middleware.AccessLog(models.LoggingConfig{Format: format, Level: level}, nil, &output, func() bool { return false }))
+				router.GET("/api/orders/:id", func(c *gin.Context) {
+					c.String(http.StatusOK, "order")
+				})
+				router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/orders/7", nil))
+				router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing", nil))
+				assert.Equal(t, expected, strings.Count(output.String(), "\n"), "%s at %s", format, level)
+			}
+		}
+	})
+
+	t.Run("Text format keeps gin's layout", func(t *testing.T) {
+		var output bytes.Buffer
+		router := newAccessLogRouter("text", &output, false)
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/orders/7", nil))
+		assert.True(t, strings.HasPrefix(output.String(), "[GIN] "))
+	})
+
+	t.Run("Entries encode as encoding/json would read them", func(t *testing.T) {
+		var decoded struct {
+			Path     string             `json:"path"`
+			Duration float64            `json:"duration"`
+			Service  string             `json:"service_name"`
+			Headers  []models.LogHeader `json:"headers"`
+		}
+		require.NoError(t, json.Unmarshal(sampleLogEntry().AppendJSON(nil), &decoded))
+		assert.Equal(t, "/api/orders/\"7\"", decoded.Path)
+		assert.Equal(t, 45.2, decoded.Duration)
+		assert.Equal(t, "orders", decoded.Service)
+		assert.Equal(t, []models.LogHeader{{Name: "User-Agent", Value: "ios/3.1\n"}}, decoded.Headers)
+	})
+}
+
+// reusedWriter is a ResponseWriter that allocates nothing per request.
+type reusedWriter struct {
+	header http.Header
+	status int
+}
+
+func (w *reusedWriter) Header() http.Header         { return w.header }
+func (w *reusedWriter) Write(p []byte) (int, error) { return len(p), nil }
+func (w *reusedWriter) WriteHeader(status int)      { w.status = status }
+
+func TestAccessLogAllocations(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("Encoding a reused entry does not allocate", func(t *testing.T) {
+		entry := sampleLogEntry()
+		buf := make([]byte, 0, 512)
+		allocs := testing.AllocsPerRun(1000, func() {
+			buf = entry.AppendJSON(buf[:0])
+		})
+		assert.Equal(t, float64(0), allocs)
+	})
+
+	t.Run("The middleware adds no allocations per request", func(t *testing.T) {
+		requestAllocs := func(router *gin.Engine) float64 {
+			req := httptest.NewRequest(http.MethodGet, "/api/orders/7", nil)
+			req.Header.Set(middleware.CorrelationIDHeader, "corr-1")
+			writer := &reusedWriter{header: make(http.Header)}
+			return testing.AllocsPerRun(1000, func() {
+				router.ServeHTTP(writer, req)
+			})
+		}
+		base := requestAllocs(newAccessLogRouter("", nil, false))
+		logged := requestAllocs(newAccessLogRouter("json", io.Discard, false))
+		assert.Equal(t, base, logged)
+	})
+}
+
+func BenchmarkAccessLogLine(b *testing.B) {
+	entry := sampleLogEntry()
+	buf := make([]byte, 0, 512)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf = entry.AppendJSON(buf[:0])
+	}
+}
+
+func BenchmarkJSONAccessLogMiddleware(b *testing.B) {
+	gin.SetMode(gin.TestMode)
+	router := newAccessLogRouter("json", io.Discard, false)
+	req := httptest.NewRequest(http.MethodGet, "/api/orders/7", nil)
+	writer := &reusedWriter{header: make(http.Header)}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		router.ServeHTTP(writer, req)
+	}
+}
+
+func TestAccessLogSampling(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	sampling := &models.LogSampling{
+		Rules: []models.LogSamplingRule{
+			{Path: "/api/orders/*", Status: "2xx", Rate: 1},
+			{Status: "2xx", Rate: 0.01},
+			{Status: "404", Rate: 0},
+		},
+		SlowThreshold: 50 * time.Millisecond,
+	}
+
+	t.Run("The first matching rule decides", func(t *testing.T) {
+		never := func() float64 { return 0.5 }
+		always := func() float64 { return 0.001 }
+		assert.True(t, sampling.Sample("/api/orders/7", http.StatusOK, time.Millisecond, never))
+		assert.False(t, sampling.Sample("/api/products/7", http.StatusOK, time.Millisecond, never))
+		assert.True(t, sampling.Sample("/api/products/7", http.StatusOK, time.Millisecond, always))
+		assert.False(t, sampling.Sample("/api/products/7", http.StatusNotFound, time.Millisecond, always))
+		assert.True(t, sampling.Sample("/api/products/7", http.StatusBadGateway, time.Millisecond, never))
+	})
+
+	t.Run("Slow requests are always logged", func(t *testing.T) {
+		never := func() float64 { return 0.5 }
+		assert.True(t, sampling.Sample("/api/products/7", http.StatusOK, 50*time.Millisecond, never))
+		assert.True(t, sampling.Sample("/api/products/7", http.StatusNotFound, time.Second, never))
+	})
+
+	t.Run("The middleware leaves sampled out requests unlogged", func(t *testing.T) {
+		for _, format := range []string{"json", "text"} {
+			var output bytes.Buffer
+			router := gin.New()
+			config := models.LoggingConfig{Format: format, Sampling: &models.LogSampling{
+				Rules:         []models.LogSamplingRule{{Status: "2xx", Rate: 0}},
+				SlowThreshold: 20 * time.Millisecond,
+			}}
+			router.Use(middleware.AccessLog(config, nil, &output, func() bool { return false }))
+			router.GET("/fast", func(c *gin.Context) { c.Status(http.StatusOK) })
+			router.GET("/slow", func(c *gin.Context) {
+				time.Sleep(25 * time.Millisecond)
+				c.Status(http.StatusOK)
+			})
+			for _, path := range []string{"/fast", "/slow", "/missing"} {
+				router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+			}
+
+			assert.Equal(t, 2, strings.Count(output.String(), "\n"), format)
+			assert.NotContains(t, output.String(), "/fast", format)
+		}
+	})
+
+	t.Run("Rules are validated", func(t *testing.T) {
+		load := func(t *testing.T, rule string) error {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			require.NoError(t, os.WriteFile(path, []byte(`
+logging:
+  sampling:
+    slow_threshold: "1s"
+    rules:
+      - `+rule+`
+`), 0o600))
+			manager := config.NewManager()
+			require.NoError(t, manager.LoadConfig(path))
+			return manager.ValidateConfig()
+		}
+
+		assert.NoError(t, load(t, `{path: "/api/*", status: "2xx", rate: 0.01}`))
+		assert.NoError(t, load(t, `{status: "404", rate: 1}`))
+		assert.ErrorContains(t, load(t, `{status: "2xx", rate: 1.5}`), "rate must be between 0 and 1")
+		assert.ErrorContains(t, load(t, `{status: "6xx", rate: 0.5}`), "invalid status")
+	})
+}
+
+func TestAccessLogFormats(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newRouter := func(config models.LoggingConfig, output io.Writer) *gin.Engine {
+		router := gin.New()
+		router.Use(middleware.AccessLog(config, nil, output, func() bool { return false }))
+		router.Use(func(c *gin.Context) {
+			if c.GetHeader("Authorization") != "" {
+				c.Set(middleware.IdentityKey, &auth.Identity{UserID: "user 42"})
+			}
+		})
+		router.GET("/api/orders/:id", func(c *gin.Context) {
+			c.String(http.StatusOK, "order")
+		})
+		router.DELETE("/api/orders/:id", func(c *gin.Context) {
+			c.Status(http.StatusNoContent)
+		})
+		return router
+	}
+
+	t.Run("Combined format matches Apache's", func(t *testing.T) {
+		var output bytes.Buffer
+		router := newRouter(models.LoggingConfig{Format: models.LogFormatCombined}, &output)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/orders/7?expand=items", nil)
+		req.RemoteAddr = "192.168.1.100:40000"
+		req.Header.Set("Authorization", "Bearer token")
+		req.Header.Set("Referer", "https://shop.example.com/")
+		req.Header.Set("User-Agent", "curl/8.0 \"evil\"\n")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+		req = httptest.NewRequest(http.MethodDelete, "/api/orders/7", nil)
+		req.RemoteAddr = "192.168.1.100:40000"
+		router.ServeHTTP(httptest.NewRecorder(), req)
+
+		lines := strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n")
+		require.Len(t, lines, 2)
+		pattern := regexp.MustCompile(`^192\.168\.1\.100 - user%2042 \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] ` +
+			`"GET /api/orders/7\?expand=items HTTP/1\.1" 200 5 "https://shop\.example\.com/" "curl/8\.0 \\"evil\\"\\x0a"$`)
+		assert.Regexp(t, pattern, lines[0])
+		assert.True(t, strings.HasPrefix(lines[1], "192.168.1.100 - - ["), lines[1])
+		assert.True(t, strings.HasSuffix(lines[1], `"DELETE /api/orders/7 HTTP/1.1" 204 - "" ""`), lines[1])
+	})
+
+	t.Run("Custom format executes its template on the entry", func(t *testing.T) {
+		var output bytes.Buffer
+		config := models.LoggingConfig{
+			Format:   models.LogFormatCustom,
+			Template: `{{.Method}} {{.Path}} status={{.StatusCode}} user={{or .UserID "-"}} ms={{ms .Duration}} id={{quote .CorrelationID}}`,
+		}
+		router := newRouter(config, &output)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/orders/7", nil)
+		req.Header.Set(middleware.CorrelationIDHeader, "corr-1")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+
+		line := output.String()
+		assert.Regexp(t, `^GET /api/orders/7 status=200 user=- ms=[\d.]+ id="corr-1"\n$`, line)
+	})
+
+	t.Run("Formats and templates are validated", func(t *testing.T) {
+		load := func(t *testing.T, logging string) error {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			require.NoError(t, os.WriteFile(path, []byte("logging:\n"+logging), 0o600))
+			manager := config.NewManager()
+			require.NoError(t, manager.LoadConfig(path))
+			return manager.ValidateConfig()
+		}
+
+		assert.NoError(t, load(t, `  format: "combined"`))
+		assert.NoError(t, load(t, "  format: \"custom\"\n  template: '{{.ClientIP}} {{.StatusCode}}'"))
+		assert.ErrorContains(t, load(t, `  format: "custom"`), "requires a template")
+		assert.ErrorContains(t, load(t, "  format: \"json\"\n  template: '{{.Path}}'"), "requires the custom format")
+		assert.ErrorContains(t, load(t, "  format: \"custom\"\n  template: '{{.Referer}}'"), "invalid logging template")
+		assert.ErrorContains(t, load(t, "  format: \"custom\"\n  template: '{{.Path'"), "invalid logging template")
+		assert.ErrorContains(t, load(t, `  format: "xml"`), "unsupported logging format")
+	})
+}
@@ -0,0 +1,108 @@
+package integration
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gateway/internal/config"
+	"gateway/internal/models"
+	"gateway/internal/tlsconfig"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/acme"
+)
+
+func TestACMECertificates(t *testing.T) {
+	ca := newTestCA(t, "ACME CA")
+	cacheDir := t.TempDir()
+	acmeConfig := models.ACMEConfig{
+		Enabled:  true,
+		Domains:  []string{"api.example.test"},
+		CacheDir: cacheDir,
+		// Renewals of the cached certificate fail, no CA is contacted
+		DirectoryURL: "http://127.0.0.1:1/directory",
+		HTTPPort:     80,
+	}
+
+	// A certificate obtained before, as the cache keeps it: key then chain
+	cert := ca.issue(t, "api.example.test", []string{"api.example.test"}, nil)
+	keyDER, err := x509.MarshalECPrivateKey(cert.PrivateKey.(*ecdsa.PrivateKey))
+	require.NoError(t, err)
+	var cached bytes.Buffer
+	require.NoError(t, pem.Encode(&cached, &pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	require.NoError(t, pem.Encode(&cached, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}))
+	require.NoError(t, os.WriteFile(filepath.Join(cacheDir, "api.example.test"), cached.Bytes(), 0o600))
+
+	certificates := tlsconfig.NewACME(acmeConfig)
+	tlsConfig, err := tlsconfig.ACMEServer(models.TLSConfig{ACME: acmeConfig}, certificates)
+	require.NoError(t, err)
+	assert.Contains(t, tlsConfig.NextProtos, acme.ALPNProto, "TLS-ALPN-01 challenges are answered on the listener")
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := &http.Server{TLSConfig: tlsConfig, Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})}
+	go server.ServeTLS(listener, "", "")
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	get := func(serverName string) (*http.Response, error) {
+		transport := &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, ServerName: serverName}}
+		return (&http.Client{Transport: transport}).Get("https://" + listener.Addr().String())
+	}
+
+	t.Run("Certificates in the cache are served", func(t *testing.T) {
+		resp, err := get("api.example.test")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "api.example.test", resp.TLS.PeerCertificates[0].Subject.CommonName)
+	})
+
+	t.Run("Only configured domains get certificates", func(t *testing.T) {
+		_, err := get("other.example.test")
+		assert.Error(t, err)
+	})
+
+	t.Run("The HTTP port redirects to HTTPS", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		certificates.HTTPHandler(nil).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://api.example.test/api/orders?page=2", nil))
+		assert.Equal(t, http.StatusFound, w.Code)
+		assert.Equal(t, "https://api.example.test/api/orders?page=2", w.Header().Get("Location"))
+
+		w = httptest.NewRecorder()
+		certificates.HTTPHandler(nil).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://api.example.test/.well-known/acme-challenge/unknown", nil))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Invalid settings are rejected", func(t *testing.T) {
+		for name, settings := range map[string]string{
+			"acme needs domains":                     "",
+			"without wildcards":                      `domains: ["*.example.test"]`,
+			"http_port must differ":                  "domains: [\"api.example.test\"]\n      http_port: 8000",
+			"takes either acme or a certificate":     "domains: [\"api.example.test\"]\n    cert_file: server.crt\n    key_file: server.key",
+			"directory_url \"staging\" is not a URL": "domains: [\"api.example.test\"]\n      directory_url: staging",
+		} {
+			content := reloadBaseConfig + "server:\n  tls:\n    acme:\n      enabled: true\n      " + settings + "\n"
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+			manager := config.NewManager()
+			require.NoError(t, manager.LoadConfig(path))
+			err := manager.ValidateConfig()
+			require.Error(t, err, name)
+			assert.Contains(t, err.Error(), name)
+		}
+	})
+}
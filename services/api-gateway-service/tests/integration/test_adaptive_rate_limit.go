@@ -0,0 +1,148 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gateway/internal/models"
+	"gateway/internal/proxy"
+	"gateway/internal/ratelimit"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdaptiveRouteRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var failing atomic.Bool
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	serviceRegistry := registry.NewServiceRegistry()
+	serviceRegistry.RegisterService(*models.NewServiceConfig("search", upstream.URL, time.Second))
+	serviceRegistry.RegisterService(*models.NewServiceConfig("reports", upstream.URL, time.Second))
+	search := models.NewRouteConfig("/api/search/*", "search")
+	search.RateLimit = &models.RouteRateLimit{
+		Requests: 100,
+		Window:   time.Second,
+		Adaptive: &models.AdaptiveLimit{
+			MaxErrorRate: 0.2,
+			Window:       100 * time.Millisecond,
+			MinRequests:  5,
+			Increase:     0.25,
+			MinFactor:    0.25,
+		},
+	}
+	serviceRegistry.RegisterRoute(*search)
+	reports := models.NewRouteConfig("/api/reports/*", "reports")
+	reports.RateLimit = &models.RouteRateLimit{Requests: 2, Window: time.Minute}
+	serviceRegistry.RegisterRoute(*reports)
+
+	proxyHandler := proxy.New(serviceRegistry)
+	router := gin.New()
+	router.Any("/api/*path", proxyHandler.Handle)
+
+	send := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+	window := func() {
+		for i := 0; i < 10; i++ {
+			send("/api/search/q")
+		}
+		time.Sleep(110 * time.Millisecond)
+	}
+	status := func(route string) ratelimit.RouteLimitStatus {
+		for _, status := range proxyHandler.RouteLimits().Routes() {
+			if status.Route == route {
+				return status
+			}
+		}
+		t.Fatalf("no limit state for %s", route)
+		return ratelimit.RouteLimitStatus{}
+	}
+
+	t.Run("Requests beyond a route's limit are rejected", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, send("/api/reports/daily").Code)
+		assert.Equal(t, http.StatusOK, send("/api/reports/daily").Code)
+
+		w := send("/api/reports/daily")
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.NotEmpty(t, w.Header().Get("Retry-After"))
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, "route_limit", body["reason"])
+		assert.Equal(t, int64(1), status("/api/reports/*").Rejected)
+		assert.False(t, status("/api/reports/*").Adaptive)
+	})
+
+	t.Run("A failing upstream tightens the limit", func(t *testing.T) {
+		failing.Store(true)
+		window()
+		send("/api/search/q")
+		assert.Equal(t, 0.5, status("/api/search/*").Factor)
+		assert.Equal(t, 50.0, status("/api/search/*").Effective)
+
+		window()
+		send("/api/search/q")
+		current := status("/api/search/*")
+		assert.Equal(t, 0.25, current.Factor)
+		assert.Equal(t, int64(2), current.Tightened)
+		assert.Greater(t, current.LastErrorRate, 0.2)
+	})
+
+	t.Run("The tightened limit is enforced", func(t *testing.T) {
+		// A quarter of the burst is left for the window
+		rejected := 0
+		for i := 0; i < 40; i++ {
+			if send("/api/search/q").Code == http.StatusTooManyRequests {
+				rejected++
+			}
+		}
+		assert.Greater(t, rejected, 0)
+	})
+
+	t.Run("The limit relaxes as the upstream recovers", func(t *testing.T) {
+		failing.Store(false)
+		time.Sleep(110 * time.Millisecond)
+		// The window above still saw failures
+		window()
+		assert.Equal(t, 0.25, status("/api/search/*").Factor)
+		send("/api/search/q")
+		assert.Equal(t, 0.5, status("/api/search/*").Factor)
+		assert.Equal(t, int64(2), status("/api/search/*").Tightened)
+
+		// Idle windows count as healthy
+		time.Sleep(250 * time.Millisecond)
+		send("/api/search/q")
+		assert.Equal(t, 1.0, status("/api/search/*").Factor)
+	})
+}
+
+func TestLimiterScale(t *testing.T) {
+	limiter := ratelimit.NewLimiter(1, 10)
+	limiter.Scale(0.2)
+
+	assert.True(t, limiter.Take("client").Allowed)
+	quota := limiter.Take("client")
+	assert.True(t, quota.Allowed)
+	assert.Equal(t, 2, quota.Limit)
+	assert.False(t, limiter.Take("client").Allowed)
+
+	limiter.Scale(1)
+	assert.Equal(t, 10, limiter.Take("other").Limit)
+}
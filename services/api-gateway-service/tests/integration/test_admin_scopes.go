@@ -0,0 +1,105 @@
+package integration
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gateway/internal/config"
+	"gateway/internal/middleware"
+	"gateway/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminTokenScopes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	adminConfig := models.AdminConfig{
+		Token: "root-token",
+		Tokens: []models.AdminToken{
+			{Name: "dashboard", Token: "dashboard-token", Scopes: []models.AdminScope{models.AdminScopeRead}},
+			{Name: "deploy", Token: "deploy-token", Scopes: []models.AdminScope{models.AdminScopeMutate}},
+			{Name: "forensics", Token: "forensics-token", Scopes: []models.AdminScope{models.AdminScopeRead, models.AdminScopeSecrets}},
+		},
+	}
+
+	router := gin.New()
+	admin := router.Group("/gateway")
+	admin.Use(middleware.AdminAuth(adminConfig))
+	admin.GET("/migrations", func(c *gin.Context) { c.Status(http.StatusOK) })
+	admin.DELETE("/migrations", func(c *gin.Context) { c.Status(http.StatusOK) })
+	failures := admin.Group("/failures", middleware.RequireAdminScope(models.AdminScopeSecrets))
+	failures.GET("", func(c *gin.Context) { c.Status(http.StatusOK) })
+	failures.DELETE("", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	send := func(method, path, token string) int {
+		req := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set(middleware.AdminTokenHeader, token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	cases := []struct {
+		token, method, path string
+		expected            int
+	}{
+		{"", http.MethodGet, "/gateway/migrations", http.StatusUnauthorized},
+		{"wrong", http.MethodGet, "/gateway/migrations", http.StatusUnauthorized},
+		{"dashboard-token", http.MethodGet, "/gateway/migrations", http.StatusOK},
+		{"dashboard-token", http.MethodDelete, "/gateway/migrations", http.StatusForbidden},
+		{"dashboard-token", http.MethodGet, "/gateway/failures", http.StatusForbidden},
+		{"deploy-token", http.MethodGet, "/gateway/migrations", http.StatusOK},
+		{"deploy-token", http.MethodDelete, "/gateway/migrations", http.StatusOK},
+		{"deploy-token", http.MethodGet, "/gateway/failures", http.StatusForbidden},
+		{"forensics-token", http.MethodGet, "/gateway/failures", http.StatusOK},
+		{"forensics-token", http.MethodDelete, "/gateway/failures", http.StatusForbidden},
+		{"root-token", http.MethodDelete, "/gateway/failures", http.StatusOK},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.expected, send(tc.method, tc.path, tc.token), "%s %s with %q", tc.method, tc.path, tc.token)
+	}
+
+	t.Run("Without tokens the admin API is disabled", func(t *testing.T) {
+		router := gin.New()
+		router.GET("/gateway/migrations", middleware.AdminAuth(models.AdminConfig{}), func(c *gin.Context) {})
+		req := httptest.NewRequest(http.MethodGet, "/gateway/migrations", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Scoped tokens load from the config file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(`
+admin:
+  tokens:
+    - name: dashboard
+      token: dashboard-token
+      scopes: ["read"]
+`), 0o600))
+		manager := config.NewManager()
+		require.NoError(t, manager.LoadConfig(path))
+		require.NoError(t, manager.ValidateConfig())
+		assert.Equal(t, []models.AdminToken{{Name: "dashboard", Token: "dashboard-token", Scopes: []models.AdminScope{models.AdminScopeRead}}},
+			manager.GetConfig().Admin.Tokens)
+
+		require.NoError(t, os.WriteFile(path, []byte(`
+admin:
+  tokens:
+    - name: dashboard
+      token: dashboard-token
+      scopes: ["write"]
+`), 0o600))
+		manager = config.NewManager()
+		require.NoError(t, manager.LoadConfig(path))
+		assert.ErrorContains(t, manager.ValidateConfig(), "unsupported scope")
+	})
+}
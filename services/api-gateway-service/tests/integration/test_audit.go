@@ -0,0 +1,141 @@
+package integration
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gateway/internal/audit"
+	"gateway/internal/circuit"
+	"gateway/internal/handlers"
+	"gateway/internal/middleware"
+	"gateway/internal/models"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminAudit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	adminConfig := models.AdminConfig{
+		Token: "root-token",
+		Tokens: []models.AdminToken{
+			{Name: "dashboard", Token: "dashboard-token", Scopes: []models.AdminScope{models.AdminScopeRead}},
+			{Name: "deploy", Token: "deploy-token", Scopes: []models.AdminScope{models.AdminScopeMutate}},
+		},
+	}
+	serviceRegistry := registry.NewServiceRegistry()
+	serviceRegistry.RegisterService(*models.NewServiceConfig("orders", "http://localhost:1", time.Second))
+	breakers := circuit.NewBreakers(*models.NewCircuitBreakerSettings(3, time.Minute, 30*time.Second, 0.6), nil)
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+	auditLog, err := audit.Open(path, 100)
+	require.NoError(t, err)
+
+	router := gin.New()
+	admin := router.Group("/gateway")
+	admin.Use(middleware.AdminAuth(adminConfig), middleware.Audit(auditLog))
+	handlers.NewAuditHandler(auditLog).Register(admin.Group("/audit"))
+	handlers.NewCircuitBreakersHandler(breakers, serviceRegistry).Register(admin.Group("/circuit-breakers"))
+
+	send := func(method, target, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		req.Header.Set(middleware.AdminTokenHeader, token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	list := func(query string) []models.AuditRecord {
+		w := send(http.MethodGet, "/gateway/audit"+query, "dashboard-token")
+		require.Equal(t, http.StatusOK, w.Code)
+		var body struct {
+			Records []models.AuditRecord `json:"records"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return body.Records
+	}
+
+	t.Run("Changes are recorded with who made them and what changed", func(t *testing.T) {
+		require.Equal(t, http.StatusOK, send(http.MethodPost, "/gateway/circuit-breakers/open?service=orders", "deploy-token").Code)
+		require.Equal(t, http.StatusOK, send(http.MethodPost, "/gateway/circuit-breakers/reset?service=orders", "root-token").Code)
+
+		records := list("")
+		require.Len(t, records, 2)
+		opened := records[0]
+		assert.Equal(t, int64(1), opened.ID)
+		assert.Equal(t, "deploy", opened.Actor)
+		assert.Equal(t, http.MethodPost, opened.Method)
+		assert.Equal(t, "/gateway/circuit-breakers/open", opened.Path)
+		assert.Equal(t, "service=orders", opened.Query)
+		assert.Equal(t, http.StatusOK, opened.Status)
+		assert.WithinDuration(t, time.Now(), opened.Timestamp, time.Minute)
+
+		var before, after map[string]interface{}
+		require.NoError(t, json.Unmarshal(opened.Before, &before))
+		require.NoError(t, json.Unmarshal(opened.After, &after))
+		assert.Equal(t, string(models.CircuitClosed), before["state"])
+		assert.Equal(t, string(models.CircuitOpen), after["state"])
+
+		assert.Equal(t, "admin", records[1].Actor)
+		assert.Equal(t, opened.Hash, records[1].PreviousHash)
+		assert.NoError(t, audit.Verify(records))
+	})
+
+	t.Run("Failed changes and reads", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, send(http.MethodPost, "/gateway/circuit-breakers/reset?service=missing", "deploy-token").Code)
+		assert.Equal(t, http.StatusForbidden, send(http.MethodPost, "/gateway/circuit-breakers/reset?service=orders", "dashboard-token").Code)
+		send(http.MethodGet, "/gateway/circuit-breakers", "dashboard-token")
+
+		// Only the change that got past the admin token's scopes is recorded
+		records := list("?after=2")
+		require.Len(t, records, 1)
+		assert.Equal(t, http.StatusNotFound, records[0].Status)
+		assert.Empty(t, records[0].After)
+	})
+
+	t.Run("Filtering", func(t *testing.T) {
+		assert.Len(t, list("?actor=deploy"), 2)
+		assert.Len(t, list("?limit=1"), 1)
+		assert.Equal(t, http.StatusBadRequest, send(http.MethodGet, "/gateway/audit?limit=0", "dashboard-token").Code)
+		assert.Equal(t, http.StatusNotFound, send(http.MethodDelete, "/gateway/audit", "root-token").Code)
+	})
+
+	t.Run("The audit file survives restarts", func(t *testing.T) {
+		require.NoError(t, auditLog.Close())
+
+		reopened, err := audit.Open(path, 100)
+		require.NoError(t, err)
+		defer reopened.Close()
+		assert.Len(t, reopened.List(0, "", 0), 3)
+
+		record, err := reopened.Append(models.AuditRecord{Actor: "deploy"})
+		require.NoError(t, err)
+		assert.Equal(t, int64(4), record.ID)
+
+		file, err := os.Open(path)
+		require.NoError(t, err)
+		defer file.Close()
+		var lines []models.AuditRecord
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			var line models.AuditRecord
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+			lines = append(lines, line)
+		}
+		require.Len(t, lines, 4)
+		assert.NoError(t, audit.Verify(lines))
+
+		lines[1].Actor = "someone else"
+		assert.Error(t, audit.Verify(lines))
+		lines = append(lines[:1], lines[2:]...)
+		assert.Error(t, audit.Verify(lines))
+	})
+}
@@ -0,0 +1,101 @@
+package integration
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"gateway/internal/models"
+	"gateway/internal/proxy"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceBulkhead(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	unblock := make(chan struct{})
+	arrived := make(chan struct{}, 10)
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		arrived <- struct{}{}
+		<-unblock
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer slow.Close()
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer fast.Close()
+
+	serviceRegistry := registry.NewServiceRegistry()
+	reports := models.NewServiceConfig("reports", slow.URL, 5*time.Second)
+	reports.MaxInFlight = 2
+	reports.QueueTimeout = 50 * time.Millisecond
+	serviceRegistry.RegisterService(*reports)
+	serviceRegistry.RegisterService(*models.NewServiceConfig("products", fast.URL, time.Second))
+	serviceRegistry.RegisterRoute(*models.NewRouteConfig("/api/reports/*", "reports"))
+	serviceRegistry.RegisterRoute(*models.NewRouteConfig("/api/products/*", "products"))
+
+	proxyHandler := proxy.New(serviceRegistry)
+	router := gin.New()
+	router.Any("/api/*path", proxyHandler.Handle)
+
+	send := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	var wg sync.WaitGroup
+	statuses := make(chan int, 2)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			statuses <- send("/api/reports/daily").Code
+		}()
+	}
+	for i := 0; i < 2; i++ {
+		<-arrived
+	}
+
+	t.Run("Requests beyond the cap are turned away after queueing", func(t *testing.T) {
+		start := time.Now()
+		w := send("/api/reports/daily")
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, "1", w.Header().Get("Retry-After"))
+		assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	})
+
+	t.Run("Other services are unaffected", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, send("/api/products/1").Code)
+	})
+
+	t.Run("Queued requests get a slot once one frees up", func(t *testing.T) {
+		reports.QueueTimeout = 2 * time.Second
+		serviceRegistry.RegisterService(*reports)
+
+		queued := make(chan int, 1)
+		go func() { queued <- send("/api/reports/weekly").Code }()
+		require.Eventually(t, func() bool {
+			stats := proxyHandler.Bulkhead().Stats()["reports"].(map[string]interface{})
+			return stats["queued"] == int64(2)
+		}, time.Second, 5*time.Millisecond)
+
+		close(unblock)
+		wg.Wait()
+		assert.Equal(t, http.StatusOK, <-statuses)
+		assert.Equal(t, http.StatusOK, <-statuses)
+		assert.Equal(t, http.StatusOK, <-queued)
+	})
+
+	stats := proxyHandler.Bulkhead().Stats()["reports"].(map[string]interface{})
+	assert.Equal(t, 2, stats["max_in_flight"])
+	assert.Equal(t, 0, stats["in_flight"])
+	assert.Equal(t, int64(1), stats["rejected"])
+}
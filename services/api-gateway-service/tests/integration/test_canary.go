@@ -0,0 +1,140 @@
+package integration
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"gateway/internal/handlers"
+	"gateway/internal/models"
+	"gateway/internal/proxy"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanaryRollback(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	stable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Version", "stable")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer stable.Close()
+	canary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Version", "canary")
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer canary.Close()
+
+	serviceRegistry := registry.NewServiceRegistry()
+	serviceRegistry.RegisterService(*models.NewServiceConfig("orders", stable.URL, time.Second))
+	serviceRegistry.RegisterService(*models.NewServiceConfig("orders-v2", canary.URL, time.Second))
+	route := models.NewRouteConfig("/api/orders/*", "orders")
+	route.Canary = &models.CanaryConfig{
+		ServiceName: "orders-v2",
+		Weight:      50,
+		Rollback: &models.CanaryRollback{
+			MaxErrorRateIncrease: 0.2,
+			Window:               time.Minute,
+			MinRequests:          5,
+		},
+	}
+	serviceRegistry.RegisterRoute(*route)
+
+	var mutex sync.Mutex
+	var rollbacks []models.HealthEvent
+	serviceRegistry.OnHealthEvent(func(event models.HealthEvent) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if event.Type == models.EventCanaryRolledBack {
+			rollbacks = append(rollbacks, event)
+		}
+	})
+
+	router := gin.New()
+	handlers.NewCanariesHandler(serviceRegistry).Register(router.Group("/gateway/canaries"))
+	router.Any("/api/*path", proxy.New(serviceRegistry).Handle)
+
+	versions := func(n int) map[string]int {
+		seen := make(map[string]int)
+		for i := 0; i < n; i++ {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/orders/1", nil))
+			seen[w.Header().Get("X-Version")]++
+		}
+		return seen
+	}
+
+	t.Run("A failing canary is rolled back", func(t *testing.T) {
+		seen := versions(200)
+		assert.Positive(t, seen["canary"])
+		assert.Positive(t, seen["stable"])
+
+		mutex.Lock()
+		require.Len(t, rollbacks, 1)
+		assert.Equal(t, "orders-v2", rollbacks[0].Service)
+		assert.Contains(t, rollbacks[0].Reason, "/api/orders/*")
+		mutex.Unlock()
+
+		canaries := serviceRegistry.Canaries()
+		require.Len(t, canaries, 1)
+		assert.True(t, canaries[0].RolledBack)
+		assert.Equal(t, 50, canaries[0].Weight)
+		assert.Equal(t, 0, canaries[0].EffectiveWeight)
+	})
+
+	t.Run("Rolled back canaries get no traffic", func(t *testing.T) {
+		assert.Equal(t, map[string]int{"stable": 50}, versions(50))
+	})
+
+	t.Run("Canaries can be resumed", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/gateway/canaries/resume?route=/api/orders/*", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.False(t, serviceRegistry.Canaries()[0].RolledBack)
+		assert.Positive(t, versions(50)["canary"])
+
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/gateway/canaries/resume?route=/api/cart/*", nil))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestHealthyCanaryKeepsItsWeight(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	serviceRegistry := registry.NewServiceRegistry()
+	serviceRegistry.RegisterService(*models.NewServiceConfig("orders", upstream.URL, time.Second))
+	serviceRegistry.RegisterService(*models.NewServiceConfig("orders-v2", upstream.URL, time.Second))
+	route := models.NewRouteConfig("/api/orders/*", "orders")
+	route.Canary = &models.CanaryConfig{
+		ServiceName: "orders-v2",
+		Weight:      100,
+		Rollback:    &models.CanaryRollback{MaxErrorRateIncrease: 0.1},
+	}
+	serviceRegistry.RegisterRoute(*route)
+
+	router := gin.New()
+	router.Any("/api/*path", proxy.New(serviceRegistry).Handle)
+	for i := 0; i < 30; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/orders/1", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	canaries := serviceRegistry.Canaries()
+	require.Len(t, canaries, 1)
+	assert.False(t, canaries[0].RolledBack)
+	assert.Equal(t, 30, canaries[0].CanaryRequests)
+	assert.Equal(t, 0, canaries[0].StableRequests)
+}
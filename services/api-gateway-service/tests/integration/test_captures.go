@@ -0,0 +1,125 @@
+package integration
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gateway/internal/forensics"
+	"gateway/internal/handlers"
+	"gateway/internal/middleware"
+	"gateway/internal/models"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestCaptures(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	serviceRegistry := registry.NewServiceRegistry()
+	serviceRegistry.RegisterService(*models.NewServiceConfig("orders", "http://localhost:1", time.Second))
+	serviceRegistry.RegisterService(*models.NewServiceConfig("products", "http://localhost:1", time.Second))
+	serviceRegistry.RegisterRoute(*models.NewRouteConfig("/api/orders/*", "orders"))
+	serviceRegistry.RegisterRoute(*models.NewRouteConfig("/api/products/*", "products"))
+
+	captures := forensics.NewCaptures(10)
+	config := models.ForensicsConfig{MaxBodySize: 8, RedactHeaders: []string{"X-Api-Key"}}
+	router := gin.New()
+	router.Use(middleware.CaptureRequests(captures, serviceRegistry, config))
+	handlers.NewCapturesHandler(captures, serviceRegistry, time.Hour).Register(router.Group("/gateway/debug/captures"))
+	router.POST("/api/*path", func(c *gin.Context) {
+		io.Copy(io.Discard, c.Request.Body)
+		c.Header("Set-Cookie", "session=abc")
+		c.String(http.StatusCreated, "created order 42")
+	})
+
+	send := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		req.Header.Set("Cookie", "session=abc")
+		req.Header.Set("X-Api-Key", "key-1")
+		req.Header.Set("X-Request-Source", "checkout")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	list := func() (sessions []models.CaptureSession, requests []models.RecordedRequest) {
+		w := send(http.MethodGet, "/gateway/debug/captures", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		var body struct {
+			Captures []models.CaptureSession  `json:"captures"`
+			Requests []models.RecordedRequest `json:"requests"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return body.Captures, body.Requests
+	}
+
+	t.Run("Nothing is captured until a capture starts", func(t *testing.T) {
+		send(http.MethodPost, "/api/orders/1", "{}")
+		sessions, requests := list()
+		assert.Empty(t, sessions)
+		assert.Empty(t, requests)
+	})
+
+	t.Run("Captured requests are redacted and truncated", func(t *testing.T) {
+		require.Equal(t, http.StatusOK, send(http.MethodPost, "/gateway/debug/captures/start?route=/api/orders/*&duration=1m", "").Code)
+		send(http.MethodPost, "/api/orders/1", `{"item":"book"}`)
+		send(http.MethodPost, "/api/products/1", "{}")
+
+		sessions, requests := list()
+		require.Len(t, sessions, 1)
+		assert.Equal(t, "/api/orders/*", sessions[0].Route)
+		assert.WithinDuration(t, time.Now().Add(time.Minute), sessions[0].Until, 5*time.Second)
+
+		require.Len(t, requests, 1)
+		captured := requests[0]
+		assert.Equal(t, "/api/orders/1", captured.Path)
+		assert.Equal(t, "/api/orders/*", captured.Route)
+		assert.Equal(t, "orders", captured.Service)
+		assert.Equal(t, http.StatusCreated, captured.Status)
+		assert.Equal(t, "[REDACTED]", captured.RequestHeaders.Get("Authorization"))
+		assert.Equal(t, "[REDACTED]", captured.RequestHeaders.Get("Cookie"))
+		assert.Equal(t, "[REDACTED]", captured.RequestHeaders.Get("X-Api-Key"))
+		assert.Equal(t, "checkout", captured.RequestHeaders.Get("X-Request-Source"))
+		assert.Equal(t, "[REDACTED]", captured.ResponseHeaders.Get("Set-Cookie"))
+		assert.Equal(t, `{"item":`, captured.RequestBody)
+		assert.True(t, captured.RequestBodyTruncated)
+		assert.Equal(t, "created ", captured.ResponseBody)
+		assert.True(t, captured.ResponseBodyTruncated)
+	})
+
+	t.Run("Captures stop when asked and when they expire", func(t *testing.T) {
+		require.Equal(t, http.StatusOK, send(http.MethodPost, "/gateway/debug/captures/stop?route=/api/orders/*", "").Code)
+		assert.Equal(t, http.StatusNotFound, send(http.MethodPost, "/gateway/debug/captures/stop?route=/api/orders/*", "").Code)
+		send(http.MethodPost, "/api/orders/2", "{}")
+		_, requests := list()
+		assert.Len(t, requests, 1)
+
+		captures.Start("/api/products/*", time.Now().Add(50*time.Millisecond))
+		send(http.MethodPost, "/api/products/1", "{}")
+		time.Sleep(100 * time.Millisecond)
+		send(http.MethodPost, "/api/products/2", "{}")
+		sessions, requests := list()
+		assert.Empty(t, sessions)
+		require.Len(t, requests, 2)
+		assert.Equal(t, "/api/products/1", requests[0].Path)
+	})
+
+	t.Run("Captures are bounded", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, send(http.MethodPost, "/gateway/debug/captures/start?route=/api/unknown/*", "").Code)
+		assert.Equal(t, http.StatusBadRequest, send(http.MethodPost, "/gateway/debug/captures/start?route=/api/orders/*&duration=soon", "").Code)
+
+		w := send(http.MethodPost, "/gateway/debug/captures/start?route=/api/orders/*&duration=48h", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		var session models.CaptureSession
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &session))
+		assert.WithinDuration(t, time.Now().Add(time.Hour), session.Until, 5*time.Second)
+	})
+}
@@ -1,138 +1,412 @@
 package integration
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"gateway/internal/circuit"
+	"gateway/internal/config"
+	"gateway/internal/middleware"
+	"gateway/internal/models"
+	"gateway/pkg/gateway"
+
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestCircuitBreakerFunctionality(t *testing.T) {
 	gin.SetMode(gin.TestMode)
-	router := gin.New()
 
-	// Mock circuit breaker middleware that should fail until implemented
-	router.Use(func(c *gin.Context) {
-		// TODO: Implement actual circuit breaker middleware
-		c.JSON(http.StatusNotImplemented, gin.H{"error": "circuit breaker not implemented"})
-		c.Abort()
-	})
+	var status int32 = http.StatusOK
+	var hits int32
+	var gate chan struct{}
+	var gateMutex sync.Mutex
+	arrived := make(chan struct{}, 10)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		gateMutex.Lock()
+		wait := gate
+		gateMutex.Unlock()
+		if wait != nil {
+			arrived <- struct{}{}
+			<-wait
+		}
+		if r.URL.Path == "/api/orders/slow" {
+			select {
+			case <-time.After(time.Second):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		w.WriteHeader(int(atomic.LoadInt32(&status)))
+	}))
+	defer upstream.Close()
 
-	router.GET("/api/orders/*proxyPath", func(c *gin.Context) {
-		// This would normally proxy to order service
-		c.JSON(http.StatusOK, gin.H{"orders": []interface{}{}})
+	var events []models.HealthEvent
+	var eventsMutex sync.Mutex
+	breakers := circuit.NewBreakers(*models.NewCircuitBreakerSettings(3, time.Minute, 100*time.Millisecond, 0.6), func(event models.HealthEvent) {
+		eventsMutex.Lock()
+		defer eventsMutex.Unlock()
+		events = append(events, event)
 	})
 
-	tests := []struct {
-		name           string
-		simulateFailure bool
-		requestCount   int
-		expectedStatus int
-		description    string
-	}{
-		{
-			name:           "Circuit breaker closed state allows requests",
-			simulateFailure: false,
-			requestCount:   5,
-			expectedStatus: http.StatusOK,
-			description:    "When service is healthy, circuit should be closed",
-		},
-		{
-			name:           "Circuit breaker opens after failure threshold",
-			simulateFailure: true,
-			requestCount:   10,
-			expectedStatus: http.StatusServiceUnavailable,
-			description:    "After repeated failures, circuit should open",
-		},
-		{
-			name:           "Circuit breaker half-open allows limited requests",
-			simulateFailure: false,
-			requestCount:   1,
-			expectedStatus: http.StatusOK,
-			description:    "In half-open state, should allow probe requests",
-		},
+	engine := gateway.New()
+	require.NoError(t, engine.AddService(*gateway.NewServiceConfig("orders", upstream.URL, 2*time.Second)))
+	require.NoError(t, engine.AddRoute(*gateway.NewRouteConfig("/api/orders/*", "orders")))
+	engine.Use(middleware.CircuitBreaker(breakers, engine.Registry()))
+	handler := engine.Handler()
+
+	get := func(ctx context.Context) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/orders/list", nil).WithContext(ctx))
+		return w
+	}
+	state := func() models.CircuitState {
+		return breakers.Circuits()[0].State
 	}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			for i := 0; i < tt.requestCount; i++ {
-				req, err := http.NewRequest("GET", "/api/orders/list", nil)
-				assert.NoError(t, err)
+	t.Run("Circuit breaker closed state allows requests", func(t *testing.T) {
+		atomic.StoreInt32(&status, http.StatusNotFound)
+		for i := 0; i < 5; i++ {
+			assert.Equal(t, http.StatusNotFound, get(context.Background()).Code)
+		}
+		// Abandoned requests say nothing about the service
+		atomic.StoreInt32(&status, http.StatusOK)
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(20*time.Millisecond, cancel)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/orders/slow", nil).WithContext(ctx))
+		assert.Equal(t, 499, w.Code)
+
+		assert.Equal(t, models.CircuitClosed, state())
+		assert.Equal(t, 5, breakers.Circuits()[0].SuccessCount)
+		assert.Equal(t, 0, breakers.Circuits()[0].FailureCount)
+	})
 
-				w := httptest.NewRecorder()
-				router.ServeHTTP(w, req)
+	t.Run("Circuit breaker opens after failure threshold", func(t *testing.T) {
+		atomic.StoreInt32(&status, http.StatusServiceUnavailable)
+		// 7 failures of 12 outcomes stay below 0.6, the 8th reaches it
+		for i := 0; i < 7; i++ {
+			assert.Equal(t, http.StatusServiceUnavailable, get(context.Background()).Code)
+			assert.Equal(t, models.CircuitClosed, state())
+		}
+		assert.Equal(t, http.StatusServiceUnavailable, get(context.Background()).Code)
+		require.Equal(t, models.CircuitOpen, state())
 
-				// This should fail until circuit breaker is implemented
-				// For now, expecting 501 Not Implemented
-				assert.Equal(t, http.StatusNotImplemented, w.Code)
+		before := atomic.LoadInt32(&hits)
+		w := get(context.Background())
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, "1", w.Header().Get("Retry-After"))
+		assert.Contains(t, w.Body.String(), "Service orders is failing")
+		assert.Equal(t, before, atomic.LoadInt32(&hits))
+		assert.Equal(t, int64(1), breakers.Circuits()[0].Rejected)
+	})
 
-				// Small delay between requests
-				if i < tt.requestCount-1 {
-					time.Sleep(10 * time.Millisecond)
-				}
-			}
-		})
-	}
+	t.Run("Circuit breaker half-open allows limited requests", func(t *testing.T) {
+		atomic.StoreInt32(&status, http.StatusOK)
+		time.Sleep(150 * time.Millisecond)
+
+		gateMutex.Lock()
+		gate = make(chan struct{})
+		gateMutex.Unlock()
+		var wg sync.WaitGroup
+		codes := make([]int, 3)
+		for i := range codes {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				codes[i] = get(context.Background()).Code
+			}(i)
+		}
+		for i := 0; i < 3; i++ {
+			<-arrived
+		}
+
+		// All probes are out; the rest wait for their verdict
+		w := get(context.Background())
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, models.CircuitHalfOpen, state())
+
+		gateMutex.Lock()
+		close(gate)
+		gate = nil
+		gateMutex.Unlock()
+		wg.Wait()
+		assert.Equal(t, []int{http.StatusOK, http.StatusOK, http.StatusOK}, codes)
+		assert.Equal(t, models.CircuitClosed, state())
+		assert.Equal(t, http.StatusOK, get(context.Background()).Code)
+	})
+
+	t.Run("A failing probe opens the circuit again", func(t *testing.T) {
+		atomic.StoreInt32(&status, http.StatusBadGateway)
+		for i := 0; i < 3; i++ {
+			get(context.Background())
+		}
+		require.Equal(t, models.CircuitOpen, state())
+		time.Sleep(150 * time.Millisecond)
+
+		assert.Equal(t, http.StatusBadGateway, get(context.Background()).Code)
+		assert.Equal(t, models.CircuitOpen, state())
+		assert.Equal(t, int64(3), breakers.Circuits()[0].Opened)
+	})
+
+	eventsMutex.Lock()
+	defer eventsMutex.Unlock()
+	require.Len(t, events, 3)
+	assert.Equal(t, models.EventCircuitOpened, events[0].Type)
+	assert.Equal(t, models.EventCircuitClosed, events[1].Type)
+	assert.Equal(t, models.EventCircuitOpened, events[2].Type)
+	assert.Equal(t, "orders circuit opened: failure rate reached the threshold", events[0].Summary())
 }
 
 func TestCircuitBreakerStateTransitions(t *testing.T) {
-	gin.SetMode(gin.TestMode)
-	router := gin.New()
+	settings := *models.NewCircuitBreakerSettings(2, 50*time.Millisecond, 50*time.Millisecond, 0.5)
+
+	t.Run("Circuit breaker closed state", func(t *testing.T) {
+		state := models.NewCircuitBreakerState("orders", settings)
+		require.True(t, state.CanRequest())
+		state.RecordFailure()
+		assert.Equal(t, models.CircuitClosed, state.State)
 
-	// Mock circuit breaker middleware
-	router.Use(func(c *gin.Context) {
-		// TODO: Implement actual circuit breaker with state tracking
-		c.JSON(http.StatusNotImplemented, gin.H{"error": "circuit breaker not implemented"})
-		c.Abort()
+		// Outcomes are counted per interval
+		time.Sleep(60 * time.Millisecond)
+		require.True(t, state.CanRequest())
+		state.RecordSuccess()
+		assert.Equal(t, 0, state.FailureCount)
+		assert.Equal(t, 1, state.SuccessCount)
 	})
 
-	router.GET("/api/test-service/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"status": "healthy"})
+	t.Run("Circuit breaker open state", func(t *testing.T) {
+		state := models.NewCircuitBreakerState("orders", settings)
+		state.RecordSuccess()
+		state.RecordFailure()
+		assert.Equal(t, models.CircuitOpen, state.State)
+		assert.False(t, state.CanRequest())
+		assert.Positive(t, state.RetryAfter())
 	})
 
-	states := []string{"closed", "open", "half_open"}
+	t.Run("Circuit breaker half_open state", func(t *testing.T) {
+		state := models.NewCircuitBreakerState("orders", settings)
+		state.RecordFailure()
+		state.RecordFailure()
+		require.Equal(t, models.CircuitOpen, state.State)
+		time.Sleep(60 * time.Millisecond)
 
-	for _, state := range states {
-		t.Run("Circuit breaker "+state+" state", func(t *testing.T) {
-			req, err := http.NewRequest("GET", "/api/test-service/health", nil)
-			assert.NoError(t, err)
+		assert.True(t, state.CanRequest())
+		assert.Equal(t, models.CircuitHalfOpen, state.State)
+		assert.True(t, state.CanRequest())
+		assert.False(t, state.CanRequest())
 
-			w := httptest.NewRecorder()
-			router.ServeHTTP(w, req)
+		// A released probe frees its slot
+		state.Release()
+		assert.True(t, state.CanRequest())
+		state.RecordSuccess()
+		state.RecordSuccess()
+		assert.Equal(t, models.CircuitClosed, state.State)
+	})
+}
 
-			// This should fail until circuit breaker is implemented
-			assert.Equal(t, http.StatusNotImplemented, w.Code)
+func TestCircuitBreakerMetrics(t *testing.T) {
+	settings := *models.NewCircuitBreakerSettings(1, time.Minute, time.Minute, 0.5)
+	breakers := circuit.NewBreakers(settings, nil)
 
-			// When implemented, should check circuit breaker state
-			// and return appropriate response based on state
-		})
-	}
+	t.Run("Circuit breaker metrics should be available", func(t *testing.T) {
+		require.True(t, func() bool { ok, _ := breakers.Allow("payments", "", settings); return ok }())
+		breakers.Record("payments", "", true, time.Millisecond)
+		allowed, retryAfter := breakers.Allow("payments", "", settings)
+		assert.False(t, allowed)
+		assert.InDelta(t, time.Minute, retryAfter, float64(time.Second))
+		require.True(t, func() bool { ok, _ := breakers.Allow("orders", "", settings); return ok }())
+		breakers.Record("orders", "", false, time.Millisecond)
+
+		stats := breakers.Stats()
+		assert.Equal(t, 1, stats["open"])
+		circuits := stats["circuits"].([]circuit.CircuitStatus)
+		require.Len(t, circuits, 2)
+		assert.Equal(t, "orders", circuits[0].ServiceName)
+		assert.Equal(t, models.CircuitOpen, circuits[1].State)
+		assert.Equal(t, int64(1), circuits[1].Rejected)
+	})
 }
 
-func TestCircuitBreakerMetrics(t *testing.T) {
+func TestCircuitBreakerOverrides(t *testing.T) {
 	gin.SetMode(gin.TestMode)
-	router := gin.New()
 
-	// Mock metrics endpoint
-	router.GET("/gateway/metrics", func(c *gin.Context) {
-		// TODO: Implement actual metrics with circuit breaker data
-		c.JSON(http.StatusNotImplemented, gin.H{"error": "metrics not implemented"})
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(routeOverride string) {
+		require.NoError(t, os.WriteFile(path, []byte(`
+circuit_breaker:
+  max_requests: 5
+  interval: "1m"
+  timeout: "30s"
+  failure_threshold: 0.6
+services:
+  payments:
+    name: "payments"
+    url: "http://payments:8080"
+    timeout: "5s"
+    circuit_breaker:
+      max_requests: 2
+      timeout: "2m"
+  products:
+    name: "products"
+    url: "http://products:8080"
+    timeout: "5s"
+routes:
+  - path: "/api/payments/*"
+    service_name: "payments"
+  - path: "/api/products/search"
+    service_name: "products"
+`+routeOverride+`
+  - path: "/api/products/*"
+    service_name: "products"
+`), 0o600))
+	}
+
+	t.Run("Services and routes override the global settings", func(t *testing.T) {
+		write(`    circuit_breaker:
+      failure_threshold: 0.2`)
+		manager := config.NewManager()
+		require.NoError(t, manager.LoadConfig(path))
+		require.NoError(t, manager.ValidateConfig())
+		cfg := manager.GetConfig()
+
+		payments := cfg.Services["payments"].CircuitBreaker.Apply(cfg.CircuitBreaker)
+		assert.Equal(t, uint32(2), payments.MaxRequests)
+		assert.Equal(t, 2*time.Minute, payments.Timeout)
+		assert.Equal(t, time.Minute, payments.Interval)
+		assert.Equal(t, 0.6, payments.FailureThreshold)
+		assert.Nil(t, cfg.Services["products"].CircuitBreaker)
+		assert.Equal(t, 0.2, cfg.Routes[1].CircuitBreaker.Apply(cfg.CircuitBreaker).FailureThreshold)
 	})
 
-	t.Run("Circuit breaker metrics should be available", func(t *testing.T) {
-		req, err := http.NewRequest("GET", "/gateway/metrics", nil)
-		assert.NoError(t, err)
+	t.Run("Invalid overrides are rejected", func(t *testing.T) {
+		write(`    circuit_breaker:
+      failure_threshold: 1.5`)
+		manager := config.NewManager()
+		require.NoError(t, manager.LoadConfig(path))
+		assert.ErrorContains(t, manager.ValidateConfig(), "route 1: circuit_breaker failure_threshold must be between 0 and 1")
+	})
 
-		w := httptest.NewRecorder()
-		router.ServeHTTP(w, req)
+	t.Run("A route with overrides has a circuit of its own", func(t *testing.T) {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer upstream.Close()
 
-		// This should fail until metrics are implemented
-		assert.Equal(t, http.StatusNotImplemented, w.Code)
+		breakers := circuit.NewBreakers(*models.NewCircuitBreakerSettings(5, time.Minute, time.Minute, 0.6), nil)
+		engine := gateway.New()
+		require.NoError(t, engine.AddService(*gateway.NewServiceConfig("products", upstream.URL, 2*time.Second)))
+		search := gateway.NewRouteConfig("/api/products/search", "products")
+		search.CircuitBreaker = &models.CircuitBreakerOverride{MaxRequests: 1, Timeout: time.Hour}
+		require.NoError(t, engine.AddRoute(*search))
+		require.NoError(t, engine.AddRoute(*gateway.NewRouteConfig("/api/products/*", "products")))
+		engine.Use(middleware.CircuitBreaker(breakers, engine.Registry()))
+		handler := engine.Handler()
+		get := func(path string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+			return w
+		}
+
+		// One failure trips the search route; the rest of the service stays
+		// up until five requests were counted
+		assert.Equal(t, http.StatusInternalServerError, get("/api/products/search").Code)
+		w := get("/api/products/search")
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, "3600", w.Header().Get("Retry-After"))
+		for i := 0; i < 4; i++ {
+			assert.Equal(t, http.StatusInternalServerError, get("/api/products/1").Code)
+		}
 
-		// When implemented, should include circuit breaker states
-		// and failure counts in the metrics response
+		circuits := breakers.Circuits()
+		require.Len(t, circuits, 2)
+		assert.Equal(t, "", circuits[0].Route)
+		assert.Equal(t, models.CircuitClosed, circuits[0].State)
+		assert.Equal(t, "/api/products/search", circuits[1].Route)
+		assert.Equal(t, models.CircuitOpen, circuits[1].State)
+		assert.Equal(t, time.Hour, circuits[1].Settings.Timeout)
 	})
-}
\ No newline at end of file
+}
+
+func TestCircuitBreakerStrategies(t *testing.T) {
+	t.Run("A sliding window forgets outcomes one bucket at a time", func(t *testing.T) {
+		settings := *models.NewCircuitBreakerSettings(2, 200*time.Millisecond, time.Minute, 0.5)
+		fixed := models.NewCircuitBreakerState("orders", settings)
+		settings.Strategy = models.CircuitSlidingWindow
+		sliding := models.NewCircuitBreakerState("orders", settings)
+
+		for _, state := range []*models.CircuitBreakerState{fixed, sliding} {
+			for i := 0; i < 3; i++ {
+				state.RecordSuccess()
+			}
+		}
+		time.Sleep(120 * time.Millisecond)
+		fixed.RecordFailure()
+		sliding.RecordFailure()
+		time.Sleep(100 * time.Millisecond)
+
+		// The fixed window started over and counts one failure; the sliding
+		// one dropped the successes but still has the earlier failure
+		fixed.RecordFailure()
+		sliding.RecordFailure()
+		assert.Equal(t, models.CircuitClosed, fixed.State)
+		assert.Equal(t, 1, fixed.FailureCount)
+		assert.Equal(t, models.CircuitOpen, sliding.State)
+		assert.Equal(t, 2, sliding.FailureCount)
+		assert.Equal(t, 0, sliding.SuccessCount)
+	})
+
+	t.Run("Slow calls count as failures", func(t *testing.T) {
+		settings := *models.NewCircuitBreakerSettings(2, time.Minute, time.Minute, 0.5)
+		settings.SlowCallDuration = 50 * time.Millisecond
+		var events []models.HealthEvent
+		breakers := circuit.NewBreakers(settings, func(event models.HealthEvent) {
+			events = append(events, event)
+		})
+
+		for _, elapsed := range []time.Duration{10 * time.Millisecond, 80 * time.Millisecond} {
+			allowed, _ := breakers.Allow("payments", "", settings)
+			require.True(t, allowed)
+			breakers.Record("payments", "", false, elapsed)
+		}
+		status := breakers.Circuits()[0]
+		assert.Equal(t, models.CircuitOpen, status.State)
+		assert.Equal(t, 1, status.SlowCallCount)
+		require.Len(t, events, 1)
+		assert.Equal(t, "payments circuit opened: failure and slow call rate reached the threshold", events[0].Summary())
+	})
+
+	t.Run("Strategies are validated per service", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		load := func(override string) error {
+			require.NoError(t, os.WriteFile(path, []byte(`
+circuit_breaker:
+  interval: "1m"
+services:
+  payments:
+    name: "payments"
+    url: "http://payments:8080"
+    timeout: "5s"
+    circuit_breaker:
+`+override+`
+`), 0o600))
+			manager := config.NewManager()
+			require.NoError(t, manager.LoadConfig(path))
+			return manager.ValidateConfig()
+		}
+
+		require.NoError(t, load(`      strategy: "sliding_window"
+      slow_call_duration: "2s"`))
+		assert.ErrorContains(t, load(`      strategy: "consecutive"`), "service payments: circuit_breaker: unsupported circuit breaker strategy: consecutive")
+		assert.ErrorContains(t, load(`      slow_call_duration: "-1s"`), "circuit_breaker durations must not be negative")
+	})
+}
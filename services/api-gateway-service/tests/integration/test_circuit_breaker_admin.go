@@ -0,0 +1,129 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gateway/internal/circuit"
+	"gateway/internal/handlers"
+	"gateway/internal/middleware"
+	"gateway/internal/models"
+	"gateway/pkg/gateway"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCircuitBreakerAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	var events []models.HealthEvent
+	breakers := circuit.NewBreakers(*models.NewCircuitBreakerSettings(3, time.Minute, 30*time.Second, 0.6), func(event models.HealthEvent) {
+		events = append(events, event)
+	})
+	router := gin.New()
+	engine := gateway.New(gateway.WithRouter(router))
+	require.NoError(t, engine.AddService(*gateway.NewServiceConfig("orders", upstream.URL, time.Second)))
+	require.NoError(t, engine.AddService(*gateway.NewServiceConfig("payments", upstream.URL, time.Second)))
+	checkout := gateway.NewRouteConfig("/api/orders/checkout", "orders")
+	checkout.CircuitBreaker = &models.CircuitBreakerOverride{Timeout: time.Minute}
+	require.NoError(t, engine.AddRoute(*checkout))
+	require.NoError(t, engine.AddRoute(*gateway.NewRouteConfig("/api/orders/*", "orders")))
+	engine.Use(middleware.CircuitBreaker(breakers, engine.Registry()))
+	handlers.NewCircuitBreakersHandler(breakers, engine.Registry()).Register(router.Group("/gateway/circuit-breakers"))
+	handler := engine.Handler()
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+	admin := func(method, target string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return w.Code, body
+	}
+
+	t.Run("Lists every service's circuit", func(t *testing.T) {
+		require.Equal(t, http.StatusOK, get("/api/orders/1").Code)
+		code, body := admin(http.MethodGet, "/gateway/circuit-breakers")
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, float64(2), body["total"])
+		circuits := body["circuits"].([]interface{})
+		orders := circuits[0].(map[string]interface{})
+		assert.Equal(t, "orders", orders["service_name"])
+		assert.Equal(t, float64(1), orders["success_count"])
+		// payments was never called, and is listed closed
+		payments := circuits[1].(map[string]interface{})
+		assert.Equal(t, "payments", payments["service_name"])
+		assert.Equal(t, "closed", payments["state"])
+
+		_, body = admin(http.MethodGet, "/gateway/circuit-breakers?service=payments")
+		assert.Equal(t, float64(1), body["total"])
+	})
+
+	t.Run("Forces a circuit open until it is reset", func(t *testing.T) {
+		code, body := admin(http.MethodPost, "/gateway/circuit-breakers/open?service=orders")
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "open", body["state"])
+		assert.Equal(t, true, body["forced"])
+
+		w := get("/api/orders/1")
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, "30", w.Header().Get("Retry-After"))
+		// The checkout route has a circuit of its own
+		assert.Equal(t, http.StatusOK, get("/api/orders/checkout").Code)
+
+		code, body = admin(http.MethodPost, "/gateway/circuit-breakers/reset?service=orders")
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "closed", body["state"])
+		assert.Nil(t, body["forced"])
+		assert.Equal(t, http.StatusOK, get("/api/orders/1").Code)
+
+		require.Len(t, events, 2)
+		assert.Equal(t, "orders circuit opened: forced open", events[0].Summary())
+		assert.Equal(t, "orders circuit closed: reset", events[1].Summary())
+	})
+
+	t.Run("Forces a route's circuit open for a while", func(t *testing.T) {
+		code, body := admin(http.MethodPost, "/gateway/circuit-breakers/open?service=orders&route=/api/orders/checkout&duration=100ms")
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "/api/orders/checkout", body["route"])
+		assert.Equal(t, http.StatusServiceUnavailable, get("/api/orders/checkout").Code)
+		assert.Equal(t, http.StatusOK, get("/api/orders/1").Code)
+
+		// Past the duration, probes decide as after any other opening
+		time.Sleep(150 * time.Millisecond)
+		assert.Equal(t, http.StatusOK, get("/api/orders/checkout").Code)
+		status, _ := breakers.Circuit("orders", "/api/orders/checkout")
+		assert.Equal(t, models.CircuitHalfOpen, status.State)
+		assert.False(t, status.Forced)
+	})
+
+	t.Run("Rejects unknown circuits and bad durations", func(t *testing.T) {
+		code, _ := admin(http.MethodPost, "/gateway/circuit-breakers/open")
+		assert.Equal(t, http.StatusBadRequest, code)
+		code, _ = admin(http.MethodPost, "/gateway/circuit-breakers/open?service=inventory")
+		assert.Equal(t, http.StatusNotFound, code)
+		code, _ = admin(http.MethodPost, "/gateway/circuit-breakers/reset?service=orders&route=/api/orders/*")
+		assert.Equal(t, http.StatusNotFound, code)
+		code, _ = admin(http.MethodPost, "/gateway/circuit-breakers/open?service=orders&duration=soon")
+		assert.Equal(t, http.StatusBadRequest, code)
+
+		// Resetting a circuit that never tripped is harmless
+		code, body := admin(http.MethodPost, "/gateway/circuit-breakers/reset?service=payments")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "closed", body["state"])
+	})
+}
@@ -0,0 +1,163 @@
+package integration
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gateway/internal/circuit"
+	"gateway/internal/config"
+	"gateway/internal/middleware"
+	"gateway/internal/models"
+	"gateway/internal/session"
+	"gateway/pkg/gateway"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCircuitBreakerFallbacks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var status int32 = http.StatusOK
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(int(atomic.LoadInt32(&status)))
+		w.Write([]byte(`{"path":"` + r.URL.Path + `"}`))
+	}))
+	defer failing.Close()
+	readonly := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"mode":"read-only"}`))
+	}))
+	defer readonly.Close()
+
+	breakers := circuit.NewBreakers(*models.NewCircuitBreakerSettings(1, time.Minute, time.Minute, 0.3), nil)
+	engine := gateway.New()
+	require.NoError(t, engine.AddService(*gateway.NewServiceConfig("catalog", failing.URL, time.Second)))
+	require.NoError(t, engine.AddService(*gateway.NewServiceConfig("orders", failing.URL, time.Second)))
+	require.NoError(t, engine.AddService(*gateway.NewServiceConfig("orders-readonly", readonly.URL, time.Second)))
+	featured := gateway.NewRouteConfig("/api/catalog/featured", "catalog")
+	featured.Fallback = &models.FallbackConfig{Mode: models.FallbackStatic, Body: `{"items":[]}`}
+	require.NoError(t, engine.AddRoute(*featured))
+	items := gateway.NewRouteConfig("/api/catalog/*", "catalog")
+	items.Fallback = &models.FallbackConfig{Mode: models.FallbackLastGood}
+	require.NoError(t, engine.AddRoute(*items))
+	orders := gateway.NewRouteConfig("/api/orders/*", "orders")
+	orders.Fallback = &models.FallbackConfig{Mode: models.FallbackService, ServiceName: "orders-readonly"}
+	require.NoError(t, engine.AddRoute(*orders))
+	key, _ := session.GenerateKey()
+	keyring, _ := session.NewKeyring([]string{key})
+	sessions := session.NewManager(session.NewMemoryStore(), keyring, session.CookieOptions{Name: "gw_session"}, time.Hour)
+	sess, err := sessions.New()
+	require.NoError(t, err)
+	sess.UserID = "alice"
+	sess.Set(session.ValueSource, session.SourceSessionLogin)
+	saved := httptest.NewRecorder()
+	require.NoError(t, sessions.Save(saved, httptest.NewRequest(http.MethodPost, "/session/login", nil), sess))
+	sessionCookie := saved.Result().Cookies()[0]
+	sessionHeader := sessionCookie.Name + "=" + sessionCookie.Value
+	// The session cookie is stripped before the breaker sees the request
+	engine.Use(middleware.SessionAuth(sessions, engine.Registry(), models.SessionAuthConfig{Enabled: true}))
+	engine.Use(middleware.CircuitBreaker(breakers, engine.Registry()))
+	handler := engine.Handler()
+
+	get := func(path string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	// Good responses are kept while the circuit is closed, but not those
+	// for a particular user
+	require.Equal(t, http.StatusOK, get("/api/catalog/items/1").Code)
+	require.Equal(t, http.StatusOK, get("/api/catalog/items/2", "Authorization", "Bearer token").Code)
+	require.Equal(t, http.StatusOK, get("/api/catalog/items/3", "Cookie", sessionHeader).Code)
+	atomic.StoreInt32(&status, http.StatusInternalServerError)
+	for i := 0; i < 3 && breakers.Circuits()[0].State != models.CircuitOpen; i++ {
+		require.Equal(t, http.StatusInternalServerError, get("/api/catalog/items/4").Code)
+	}
+	require.Equal(t, models.CircuitOpen, breakers.Circuits()[0].State)
+
+	t.Run("Last good responses are served while the circuit is open", func(t *testing.T) {
+		w := get("/api/catalog/items/1")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "last_good", w.Header().Get(middleware.FallbackHeader))
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		assert.JSONEq(t, `{"path":"/api/catalog/items/1"}`, w.Body.String())
+
+		assert.Equal(t, http.StatusServiceUnavailable, get("/api/catalog/items/2").Code)
+		assert.Equal(t, http.StatusServiceUnavailable, get("/api/catalog/items/1", "Authorization", "Bearer token").Code)
+	})
+
+	t.Run("Responses of session users are neither kept nor replayed", func(t *testing.T) {
+		assert.Equal(t, http.StatusServiceUnavailable, get("/api/catalog/items/3").Code)
+		assert.Equal(t, http.StatusServiceUnavailable, get("/api/catalog/items/1", "Cookie", sessionHeader).Code)
+	})
+
+	t.Run("Static fallbacks answer with their body", func(t *testing.T) {
+		w := get("/api/catalog/featured")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "static", w.Header().Get(middleware.FallbackHeader))
+		assert.JSONEq(t, `{"items":[]}`, w.Body.String())
+	})
+
+	t.Run("Degraded-mode services take over the route", func(t *testing.T) {
+		require.Equal(t, http.StatusInternalServerError, get("/api/orders/1").Code)
+		w := get("/api/orders/1")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "service", w.Header().Get(middleware.FallbackHeader))
+		assert.JSONEq(t, `{"mode":"read-only"}`, w.Body.String())
+
+		circuits := breakers.Circuits()
+		require.Len(t, circuits, 2)
+		assert.Equal(t, "orders", circuits[1].ServiceName)
+		assert.Equal(t, int64(1), circuits[1].Rejected)
+	})
+}
+
+func TestFallbackValidation(t *testing.T) {
+	load := func(t *testing.T, fallback string) error {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(`
+services:
+  orders:
+    name: "orders"
+    url: "http://orders:8080"
+    timeout: "5s"
+  orders-readonly:
+    name: "orders-readonly"
+    url: "http://orders-readonly:8080"
+    timeout: "5s"
+routes:
+  - path: "/api/orders/*"
+    service_name: "orders"
+    fallback:
+`+fallback+`
+`), 0o600))
+		manager := config.NewManager()
+		require.NoError(t, manager.LoadConfig(path))
+		return manager.ValidateConfig()
+	}
+
+	assert.NoError(t, load(t, `      mode: "static"
+      status: 503
+      body: '{"message": "Orders are read-only for now"}'`))
+	assert.NoError(t, load(t, `      mode: "service"
+      service_name: "orders-readonly"`))
+	assert.NoError(t, load(t, `      mode: "last_good"
+      max_age: "1h"`))
+	assert.ErrorContains(t, load(t, `      mode: "static"
+      body: "not json"`), "static fallback body must be JSON")
+	assert.ErrorContains(t, load(t, `      mode: "service"
+      service_name: "inventory"`), "fallback references non-existent service: inventory")
+	assert.ErrorContains(t, load(t, `      mode: "redirect"`), "unsupported fallback mode: redirect")
+}
@@ -0,0 +1,183 @@
+package integration
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gateway/internal/auth"
+	"gateway/internal/middleware"
+	"gateway/internal/models"
+	"gateway/internal/registry"
+	"gateway/internal/tlsconfig"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCA{cert: cert, key: key}
+}
+
+// issue returns a certificate signed by the CA, for a server with the
+// given IP or a client with the given DNS SAN.
+func (ca *testCA) issue(t *testing.T, commonName string, dnsNames []string, ip net.IP) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     dnsNames,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+	}
+	if ip != nil {
+		template.IPAddresses = []net.IP{ip}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600))
+}
+
+func TestClientCertificateAuthentication(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	ca := newTestCA(t, "Partner CA")
+	serverCert := ca.issue(t, "gateway", nil, net.ParseIP("127.0.0.1"))
+	acme := ca.issue(t, "acme", []string{"acme.partners.example.com"}, nil)
+	globex := ca.issue(t, "globex", []string{"globex.partners.example.com"}, nil)
+	initech := ca.issue(t, "initech", []string{"initech.partners.example.com"}, nil)
+	rogue := newTestCA(t, "Rogue CA").issue(t, "acme", []string{"acme.partners.example.com"}, nil)
+
+	dir := t.TempDir()
+	keyDER, err := x509.MarshalPKCS8PrivateKey(serverCert.PrivateKey)
+	require.NoError(t, err)
+	writePEM(t, filepath.Join(dir, "server.crt"), "CERTIFICATE", serverCert.Certificate[0])
+	writePEM(t, filepath.Join(dir, "server.key"), "PRIVATE KEY", keyDER)
+	writePEM(t, filepath.Join(dir, "ca.crt"), "CERTIFICATE", ca.cert.Raw)
+
+	serviceRegistry := registry.NewServiceRegistry()
+	serviceRegistry.RegisterService(*models.NewServiceConfig("partners", "http://partners", time.Second))
+	partners := models.NewRouteConfig("/api/partners/*", "partners")
+	partners.AuthRequired = true
+	partners.ClientCert = &models.ClientCertPolicy{Identities: []models.CertIdentity{
+		{SAN: "acme.partners.example.com", UserID: "partner-acme", Scopes: []string{"orders:read"}},
+		{Fingerprint: "SHA256:" + models.CertFingerprint(globex.Leaf), UserID: "partner-globex"},
+	}}
+	serviceRegistry.RegisterRoute(*partners)
+
+	router := gin.New()
+	router.Use(middleware.ClientCertAuth(serviceRegistry))
+	router.Use(middleware.Authenticate(auth.NewClient("http://127.0.0.1:1", time.Second), serviceRegistry, models.AuthConfig{}))
+	router.Use(middleware.PropagateIdentity(map[string]string{"user_id": "X-User-ID", "scopes": "X-Scopes", "cert_subject": "X-Client-Subject"}))
+	router.GET("/api/*path", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"user":    c.GetHeader("X-User-ID"),
+			"scopes":  c.GetHeader("X-Scopes"),
+			"subject": c.GetHeader("X-Client-Subject"),
+		})
+	})
+
+	serverTLS := models.TLSConfig{
+		CertFile:     filepath.Join(dir, "server.crt"),
+		KeyFile:      filepath.Join(dir, "server.key"),
+		ClientCAFile: filepath.Join(dir, "ca.crt"),
+	}
+	certificate, err := tlsconfig.LoadCertificate(serverTLS)
+	require.NoError(t, err)
+	tlsConfig, err := tlsconfig.Server(serverTLS, certificate)
+	require.NoError(t, err)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := &http.Server{Handler: router, TLSConfig: tlsConfig}
+	go server.ServeTLS(listener, "", "")
+	defer server.Close()
+	url := "https://" + listener.Addr().String()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	send := func(cert *tls.Certificate) (*http.Response, error) {
+		config := &tls.Config{RootCAs: roots}
+		if cert != nil {
+			config.Certificates = []tls.Certificate{*cert}
+		}
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+		return client.Get(url + "/api/partners/orders")
+	}
+
+	resp, err := send(&acme)
+	require.NoError(t, err)
+	var body map[string]string
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "the certificate replaces the bearer token")
+	assert.Equal(t, map[string]string{"user": "partner-acme", "scopes": "orders:read", "subject": "CN=acme"}, body)
+
+	resp, err = send(&globex)
+	require.NoError(t, err)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	resp.Body.Close()
+	assert.Equal(t, "partner-globex", body["user"], "matched by fingerprint")
+
+	// Trusted but unknown certificates are refused, missing ones challenged
+	resp, err = send(&initech)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp, err = send(nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// Certificates from other CAs fail the handshake
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs: roots,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return &rogue, nil
+		},
+	}}}
+	_, err = client.Get(url + "/api/partners/orders")
+	assert.Error(t, err)
+}
@@ -0,0 +1,197 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gateway/internal/models"
+	"gateway/internal/proxy"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newCompositeBackend(body string, delay time.Duration, calls *int64) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(calls, 1)
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+}
+
+func TestCompositeRouteBudgets(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var userCalls, recommendationCalls, orderCalls int64
+	users := newCompositeBackend(`{"name": "Jane"}`, 0, &userCalls)
+	defer users.Close()
+	recommendations := newCompositeBackend(`["lamp"]`, 350*time.Millisecond, &recommendationCalls)
+	defer recommendations.Close()
+	orders := newCompositeBackend(`[{"id": 1}]`, 0, &orderCalls)
+	defer orders.Close()
+
+	newRouter := func(split models.BudgetSplit) *gin.Engine {
+		serviceRegistry := registry.NewServiceRegistry()
+		serviceRegistry.RegisterService(*models.NewServiceConfig("users", users.URL, 5*time.Second))
+		serviceRegistry.RegisterService(*models.NewServiceConfig("recommendations", recommendations.URL, 5*time.Second))
+		serviceRegistry.RegisterService(*models.NewServiceConfig("orders", orders.URL, 5*time.Second))
+
+		dashboard := models.NewRouteConfig("/api/dashboard", "")
+		dashboard.Timeout = 600 * time.Millisecond
+		dashboard.Composite = &models.CompositeConfig{
+			Split: split,
+			Legs: []models.CompositeLeg{
+				{Name: "user", ServiceName: "users", Path: "/users/me"},
+				{Name: "recommendations", ServiceName: "recommendations", Path: "/recommendations", Weight: 3},
+				{Name: "orders", ServiceName: "orders", Path: "/orders", Stage: 1},
+			},
+		}
+		serviceRegistry.RegisterRoute(*dashboard)
+		assert.NoError(t, serviceRegistry.ValidateConfiguration())
+
+		router := gin.New()
+		router.Any("/api/*proxyPath", proxy.New(serviceRegistry).Handle)
+		return router
+	}
+
+	t.Run("Weighted split gives the slow stage room", func(t *testing.T) {
+		for _, split := range []models.BudgetSplit{models.SplitWeighted, models.SplitRolling} {
+			req, _ := http.NewRequest("GET", "/api/dashboard", nil)
+			w := httptest.NewRecorder()
+			newRouter(split).ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code, string(split))
+			assert.JSONEq(t, `{"user": {"name": "Jane"}, "recommendations": ["lamp"], "orders": [{"id": 1}]}`, w.Body.String())
+		}
+	})
+
+	t.Run("Equal split times the slow leg out before it eats the budget", func(t *testing.T) {
+		atomic.StoreInt64(&orderCalls, 0)
+		req, _ := http.NewRequest("GET", "/api/dashboard", nil)
+		w := httptest.NewRecorder()
+		start := time.Now()
+		newRouter(models.SplitEqual).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+		assert.Less(t, time.Since(start), 350*time.Millisecond)
+		assert.Contains(t, w.Body.String(), "recommendations")
+		// The later stage is not fired once the route cannot succeed
+		assert.Equal(t, int64(0), atomic.LoadInt64(&orderCalls))
+	})
+}
+
+func TestCompositeMergeStrategies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var calls int64
+	profile := newCompositeBackend(`{"name": "Jane"}`, 0, &calls)
+	defer profile.Close()
+	featured := newCompositeBackend(`[{"sku": "A"}, {"sku": "B"}]`, 0, &calls)
+	defer featured.Close()
+	sale := newCompositeBackend(`[{"sku": "C"}]`, 0, &calls)
+	defer sale.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer broken.Close()
+
+	serviceRegistry := registry.NewServiceRegistry()
+	for name, url := range map[string]string{"profile": profile.URL, "featured": featured.URL, "sale": sale.URL, "broken": broken.URL} {
+		serviceRegistry.RegisterService(*models.NewServiceConfig(name, url, time.Second))
+	}
+	register := func(path string, composite models.CompositeConfig) {
+		route := models.NewRouteConfig(path, "")
+		route.Composite = &composite
+		serviceRegistry.RegisterRoute(*route)
+	}
+	register("/api/products", models.CompositeConfig{
+		Merge: models.MergeConcat,
+		Legs: []models.CompositeLeg{
+			{Name: "featured", ServiceName: "featured", Path: "/"},
+			{Name: "sale", ServiceName: "sale", Path: "/"},
+		},
+	})
+	register("/api/profile", models.CompositeConfig{
+		Merge: models.MergeFirstSuccess,
+		Legs: []models.CompositeLeg{
+			{Name: "primary", ServiceName: "broken", Path: "/"},
+			{Name: "replica", ServiceName: "profile", Path: "/", Stage: 1},
+			{Name: "cache", ServiceName: "profile", Path: "/", Stage: 2},
+		},
+	})
+	register("/api/home", models.CompositeConfig{
+		PartialResults: true,
+		Legs: []models.CompositeLeg{
+			{Name: "user", ServiceName: "profile", Path: "/", Required: true},
+			{Name: "recommendations", ServiceName: "broken", Path: "/"},
+			{Name: "featured", ServiceName: "featured", Path: "/", Stage: 1},
+		},
+	})
+	register("/api/checkout", models.CompositeConfig{
+		PartialResults: true,
+		Legs: []models.CompositeLeg{
+			{Name: "cart", ServiceName: "broken", Path: "/", Required: true},
+			{Name: "user", ServiceName: "profile", Path: "/"},
+		},
+	})
+
+	router := gin.New()
+	router.Any("/api/*proxyPath", proxy.New(serviceRegistry).Handle)
+	get := func(path string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Concat keeps declaration order", func(t *testing.T) {
+		w := get("/api/products")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `[{"sku":"A"},{"sku":"B"},{"sku":"C"}]`, w.Body.String())
+	})
+
+	t.Run("First success falls back and skips the rest", func(t *testing.T) {
+		atomic.StoreInt64(&calls, 0)
+		w := get("/api/profile")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"name": "Jane"}`, w.Body.String())
+		assert.Equal(t, int64(1), atomic.LoadInt64(&calls))
+	})
+
+	t.Run("Partial results carry per-leg status", func(t *testing.T) {
+		w := get("/api/home")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "true", w.Header().Get(proxy.PartialResponseHeader))
+
+		var response struct {
+			Data    json.RawMessage `json:"data"`
+			Partial bool            `json:"partial"`
+			Legs    map[string]struct {
+				Status string `json:"status"`
+				Code   int    `json:"code"`
+			} `json:"legs"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.True(t, response.Partial)
+		assert.Equal(t, `{"user":{"name": "Jane"},"recommendations":null,"featured":[{"sku": "A"}, {"sku": "B"}]}`, string(response.Data))
+		assert.Equal(t, "ok", response.Legs["user"].Status)
+		assert.Equal(t, "error", response.Legs["recommendations"].Status)
+		assert.Equal(t, http.StatusInternalServerError, response.Legs["recommendations"].Code)
+		assert.Equal(t, "ok", response.Legs["featured"].Status)
+	})
+
+	t.Run("Required legs still fail the response", func(t *testing.T) {
+		w := get("/api/checkout")
+		assert.Equal(t, http.StatusBadGateway, w.Code)
+	})
+}
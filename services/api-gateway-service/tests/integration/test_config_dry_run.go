@@ -0,0 +1,117 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gateway/internal/config"
+	"gateway/internal/handlers"
+	"gateway/internal/models"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigDryRun(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(reloadBaseConfig), 0o600))
+	manager := config.NewManager()
+	require.NoError(t, manager.LoadConfig(path))
+	require.NoError(t, manager.ValidateConfig())
+
+	serviceRegistry := registry.NewServiceRegistry()
+	cfg := manager.GetConfig()
+	require.NoError(t, serviceRegistry.ReplaceRouting(nil, cfg.Services, nil, cfg.Routes))
+
+	router := gin.New()
+	handlers.NewConfigHandler(manager, cfg, serviceRegistry).Register(router.Group("/gateway/config"))
+	dryRun := func(t *testing.T, candidate string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/gateway/config/dry-run", strings.NewReader(candidate)))
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return w.Code, body
+	}
+
+	t.Run("Valid candidates report their impact", func(t *testing.T) {
+		status, body := dryRun(t, `
+services:
+  orders:
+    name: "orders"
+    url: "http://orders:8008"
+    timeout: "5s"
+  payments:
+    name: "payments"
+    url: "http://payments:8009"
+    timeout: "5s"
+routes:
+  - path: "/api/orders/*"
+    service_name: "orders"
+  - path: "/api/invoices/*"
+    service_name: "orders"
+  - path: "/api/payments/*"
+    service_name: "payments"
+rate_limit:
+  requests: 50
+`)
+		require.Equal(t, http.StatusOK, status, body)
+		assert.Equal(t, true, body["valid"])
+		changes := body["changes"].(map[string]interface{})
+		assert.ElementsMatch(t, []interface{}{"* /api/invoices/*", "* /api/payments/*"}, changes["routes_added"])
+		assert.Equal(t, []interface{}{"payments"}, changes["services_added"])
+		assert.Equal(t, float64(4), body["total_changes"])
+		assert.Equal(t, []interface{}{"orders", "payments"}, body["services_affected"])
+		assert.Equal(t, []interface{}{"rate_limit"}, body["restart_required"])
+		assert.NotEqual(t, manager.ReloadStats().Stats()["config_hash"], body["config_hash"])
+	})
+
+	t.Run("Nothing is applied", func(t *testing.T) {
+		assert.Len(t, manager.GetConfig().Routes, 1)
+		assert.Len(t, serviceRegistry.GetRoutes(), 1)
+		_, exists := serviceRegistry.GetService("payments")
+		assert.False(t, exists)
+		assert.Equal(t, 1, manager.History().Current())
+		assert.Equal(t, int64(0), manager.ReloadStats().Stats()["attempts"])
+	})
+
+	t.Run("Failures report the stage that rejected the candidate", func(t *testing.T) {
+		_, body := dryRun(t, "routes: [")
+		assert.Equal(t, false, body["valid"])
+		assert.Equal(t, config.ReloadLoad, body["stage"])
+
+		_, body = dryRun(t, reloadBaseConfig+`
+  - path: "/api/payments/*"
+    service_name: "payments"
+`)
+		assert.Equal(t, false, body["valid"])
+		assert.Equal(t, config.ReloadValidation, body["stage"])
+		assert.Contains(t, body["message"], "payments")
+
+		// A route registered elsewhere still needs the orders service
+		serviceRegistry.RegisterRoute(models.RouteConfig{Path: "/api/etcd/*", Method: "*", ServiceName: "orders"})
+		_, body = dryRun(t, `
+services:
+  billing:
+    name: "billing"
+    url: "http://billing:8010"
+    timeout: "5s"
+`)
+		assert.Equal(t, false, body["valid"])
+		assert.Equal(t, "routing", body["stage"])
+		assert.Contains(t, body["message"], "non-existent service: orders")
+	})
+
+	t.Run("A candidate is required", func(t *testing.T) {
+		status, _ := dryRun(t, "  \n")
+		assert.Equal(t, http.StatusBadRequest, status)
+	})
+}
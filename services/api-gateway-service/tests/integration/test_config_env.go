@@ -0,0 +1,95 @@
+package integration
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gateway/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigFromEnvironment(t *testing.T) {
+	// An empty file stands in for a deployment without one
+	empty := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(empty, nil, 0o600))
+
+	t.Run("Services and routes can be set one setting at a time", func(t *testing.T) {
+		t.Setenv("GATEWAY_SERVER_READ_TIMEOUT", "12s")
+		t.Setenv("GATEWAY_SERVICES_ORDERS_NAME", "orders")
+		t.Setenv("GATEWAY_SERVICES_ORDERS_URL", "http://orders:8008")
+		t.Setenv("GATEWAY_SERVICES_ORDERS_TIMEOUT", "5s")
+		t.Setenv("GATEWAY_SERVICES_ORDERS_HEALTH_PATH", "/ready")
+		t.Setenv("GATEWAY_SERVICES_ORDER_HISTORY_NAME", "order-history")
+		t.Setenv("GATEWAY_SERVICES_ORDER_HISTORY_URL", "http://history:8010")
+		t.Setenv("GATEWAY_SERVICES_ORDER_HISTORY_TIMEOUT", "5s")
+		t.Setenv("GATEWAY_ROUTES_0_PATH", "/api/orders/*")
+		t.Setenv("GATEWAY_ROUTES_0_SERVICE_NAME", "orders")
+		t.Setenv("GATEWAY_ROUTES_1_PATH", "/api/history/*")
+		t.Setenv("GATEWAY_ROUTES_1_SERVICE_NAME", "order_history")
+		t.Setenv("GATEWAY_ETCD_ENDPOINTS", "etcd-1:2379, etcd-2:2379")
+
+		manager := config.NewManager()
+		require.NoError(t, manager.LoadConfig(empty))
+		require.NoError(t, manager.ValidateConfig())
+		cfg := manager.GetConfig()
+
+		assert.Equal(t, 12*time.Second, cfg.Server.ReadTimeout)
+		require.Contains(t, cfg.Services, "orders")
+		assert.Equal(t, "http://orders:8008", cfg.Services["orders"].URL)
+		assert.Equal(t, 5*time.Second, cfg.Services["orders"].Timeout)
+		assert.Equal(t, "/ready", cfg.Services["orders"].HealthPath)
+		assert.Equal(t, "http://history:8010", cfg.Services["order_history"].URL)
+		require.Len(t, cfg.Routes, 2)
+		assert.Equal(t, "/api/orders/*", cfg.Routes[0].Path)
+		assert.Equal(t, "order_history", cfg.Routes[1].ServiceName)
+		assert.Equal(t, []string{"etcd-1:2379", "etcd-2:2379"}, cfg.Etcd.Endpoints)
+	})
+
+	t.Run("Maps and lists can be set as JSON", func(t *testing.T) {
+		t.Setenv("GATEWAY_SERVICES", `{"Payments": {"name": "payments", "url": "http://payments:8009", "timeout": "30s"}}`)
+		t.Setenv("GATEWAY_SERVICES_PAYMENTS_TIMEOUT", "10s")
+		t.Setenv("GATEWAY_ROUTES", `[{"path": "/api/payments/*", "service_name": "payments"}]`)
+
+		manager := config.NewManager()
+		require.NoError(t, manager.LoadConfig(empty))
+		require.NoError(t, manager.ValidateConfig())
+		cfg := manager.GetConfig()
+
+		assert.Equal(t, "http://payments:8009", cfg.Services["payments"].URL)
+		assert.Equal(t, 10*time.Second, cfg.Services["payments"].Timeout)
+		require.Len(t, cfg.Routes, 1)
+		assert.Equal(t, "payments", cfg.Routes[0].ServiceName)
+	})
+
+	t.Run("Variables override the file's entries", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(reloadBaseConfig), 0o600))
+		t.Setenv("GATEWAY_SERVICES_ORDERS_URL", "http://orders-canary:8008")
+
+		manager := config.NewManager()
+		require.NoError(t, manager.LoadConfig(path))
+		orders := manager.GetConfig().Services["orders"]
+		assert.Equal(t, "http://orders-canary:8008", orders.URL)
+		assert.Equal(t, "orders", orders.Name)
+		assert.Equal(t, 5*time.Second, orders.Timeout)
+		assert.Len(t, manager.GetConfig().Routes, 1)
+	})
+
+	t.Run("List entries are numbered from 0", func(t *testing.T) {
+		t.Setenv("GATEWAY_ROUTES_1_PATH", "/api/orders/*")
+		err := config.NewManager().LoadConfig(empty)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "GATEWAY_ROUTES_0 is not set")
+	})
+
+	t.Run("Invalid JSON fails the load", func(t *testing.T) {
+		t.Setenv("GATEWAY_SERVICES", `{"orders": `)
+		err := config.NewManager().LoadConfig(empty)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "GATEWAY_SERVICES must be JSON")
+	})
+}
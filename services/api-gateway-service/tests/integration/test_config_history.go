@@ -0,0 +1,131 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gateway/internal/config"
+	"gateway/internal/handlers"
+	"gateway/internal/models"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigHistoryRollback(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(content string) {
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	}
+	withInvoices := reloadBaseConfig + `
+  - path: "/api/invoices/*"
+    service_name: "orders"
+`
+	write(reloadBaseConfig + "config_history:\n  size: 3\n")
+
+	manager := config.NewManager()
+	require.NoError(t, manager.LoadConfig(path))
+	require.NoError(t, manager.ValidateConfig())
+
+	serviceRegistry := registry.NewServiceRegistry()
+	cfg := manager.GetConfig()
+	require.NoError(t, serviceRegistry.ReplaceRouting(nil, cfg.Services, nil, cfg.Routes))
+	apply := func(previous, current *models.GatewayConfig) error {
+		return serviceRegistry.ReplaceRouting(previous.Services, current.Services, previous.Routes, current.Routes)
+	}
+
+	router := gin.New()
+	handlers.NewConfigHandler(manager, cfg, serviceRegistry).Register(router.Group("/gateway/config"))
+	request := func(method, target string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return w.Code, body
+	}
+
+	// Version 2 adds a route that version 3 loses again by mistake
+	write(withInvoices + "config_history:\n  size: 3\n")
+	_, err := manager.ReloadWith(apply)
+	require.NoError(t, err)
+	write(reloadBaseConfig + "config_history:\n  size: 3\n")
+	_, err = manager.ReloadWith(apply)
+	require.NoError(t, err)
+	require.Len(t, serviceRegistry.GetRoutes(), 1)
+
+	t.Run("Applied versions are listed newest first", func(t *testing.T) {
+		status, body := request(http.MethodGet, "/gateway/config/history")
+		require.Equal(t, http.StatusOK, status)
+		assert.Equal(t, float64(3), body["current"])
+		versions := body["versions"].([]interface{})
+		require.Len(t, versions, 3)
+
+		newest := versions[0].(map[string]interface{})
+		assert.Equal(t, float64(3), newest["version"])
+		assert.Equal(t, config.TriggerReload, newest["trigger"])
+		assert.Equal(t, float64(1), newest["changes"])
+		assert.Equal(t, manager.ReloadStats().Stats()["config_hash"], newest["hash"])
+		assert.Equal(t, config.TriggerLoad, versions[2].(map[string]interface{})["trigger"])
+	})
+
+	t.Run("Rolling back restores the routes as a new version", func(t *testing.T) {
+		status, body := request(http.MethodPost, "/gateway/config/rollback/2")
+		require.Equal(t, http.StatusOK, status)
+		version := body["version"].(map[string]interface{})
+		assert.Equal(t, float64(4), version["version"])
+		assert.Equal(t, config.TriggerRollback, version["trigger"])
+		assert.Equal(t, float64(2), version["rollback_of"])
+		assert.Equal(t, []interface{}{"* /api/invoices/*"}, body["changes"].(map[string]interface{})["routes_added"])
+
+		assert.Len(t, serviceRegistry.GetRoutes(), 2)
+		assert.Len(t, manager.GetConfig().Routes, 2)
+		versions := manager.History().Versions()
+		assert.Equal(t, versions[2].Hash, versions[0].Hash)
+	})
+
+	t.Run("Only the last versions are kept", func(t *testing.T) {
+		status, _ := request(http.MethodPost, "/gateway/config/rollback/1")
+		assert.Equal(t, http.StatusNotFound, status)
+		status, _ = request(http.MethodPost, "/gateway/config/rollback/latest")
+		assert.Equal(t, http.StatusBadRequest, status)
+		assert.Len(t, manager.History().Versions(), 3)
+	})
+
+	t.Run("Rejected rollbacks leave everything in place", func(t *testing.T) {
+		write(`
+services:
+  billing:
+    name: "billing"
+    url: "http://billing:8010"
+    timeout: "5s"
+routes:
+  - path: "/api/billing/*"
+    service_name: "billing"
+config_history:
+  size: 3
+`)
+		_, err := manager.ReloadWith(apply)
+		require.NoError(t, err)
+		// A route from elsewhere, like etcd, now needs the billing service
+		// that version 4 does not have
+		serviceRegistry.RegisterRoute(models.RouteConfig{Path: "/api/etcd/*", Method: "*", ServiceName: "billing"})
+		current := manager.History().Current()
+
+		status, body := request(http.MethodPost, "/gateway/config/rollback/4")
+		assert.Equal(t, http.StatusConflict, status)
+		assert.Contains(t, body["message"], "non-existent service: billing")
+		assert.Equal(t, current, manager.History().Current())
+		_, exists := manager.GetConfig().Services["billing"]
+		assert.True(t, exists)
+		_, exists = serviceRegistry.GetService("billing")
+		assert.True(t, exists)
+	})
+}
@@ -0,0 +1,231 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gateway/internal/config"
+	"gateway/internal/models"
+	"gateway/internal/registry"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const reloadBaseConfig = `
+services:
+  orders:
+    name: "orders"
+    url: "http://orders:8008"
+    timeout: "5s"
+routes:
+  - path: "/api/orders/*"
+    service_name: "orders"
+`
+
+func TestConfigReloadMetrics(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(content string) {
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	}
+	write(reloadBaseConfig)
+
+	manager := config.NewManager()
+	require.NoError(t, manager.LoadConfig(path))
+	require.NoError(t, manager.ValidateConfig())
+	stats := manager.ReloadStats().Stats()
+	initialHash := stats["config_hash"].(string)
+	assert.NotEmpty(t, initialHash)
+	assert.Equal(t, int64(0), stats["attempts"])
+
+	t.Run("Applied reloads report their diff and the new hash", func(t *testing.T) {
+		write(reloadBaseConfig + `
+  - path: "/api/invoices/*"
+    service_name: "orders"
+rate_limit:
+  requests: 50
+`)
+		diff, err := manager.Reload()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"* /api/invoices/*"}, diff.RoutesAdded)
+		assert.Equal(t, []string{"rate_limit"}, diff.Sections)
+		assert.Len(t, manager.GetConfig().Routes, 2)
+
+		stats := manager.ReloadStats().Stats()
+		assert.Equal(t, int64(1), stats["successes"])
+		assert.Equal(t, 2, stats["last_diff_size"])
+		assert.NotEqual(t, initialHash, stats["config_hash"])
+		assert.Contains(t, stats, "last_success")
+	})
+
+	t.Run("Broken files are not applied", func(t *testing.T) {
+		activeHash := manager.ReloadStats().Stats()["config_hash"]
+
+		write("services: [")
+		_, err := manager.Reload()
+		require.Error(t, err)
+
+		write(reloadBaseConfig + `
+server:
+  port: 80
+`)
+		_, err = manager.Reload()
+		require.ErrorContains(t, err, "invalid server port")
+
+		assert.Equal(t, 8000, manager.GetConfig().Server.Port)
+		assert.Len(t, manager.GetConfig().Routes, 2)
+		stats := manager.ReloadStats().Stats()
+		assert.Equal(t, int64(3), stats["attempts"])
+		assert.Equal(t, int64(1), stats["load_failures"])
+		assert.Equal(t, int64(1), stats["validation_failures"])
+		assert.Equal(t, activeHash, stats["config_hash"])
+		assert.Contains(t, stats["last_error"], "invalid server port")
+	})
+}
+
+const hotReloadConfig = `
+services:
+  orders:
+    name: "orders"
+    url: "http://orders:8008"
+    timeout: "5s"
+    enabled: true
+routes:
+  - path: "/api/orders/*"
+    service_name: "orders"
+`
+
+func TestHotConfigReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(content string) {
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	}
+	write(hotReloadConfig)
+
+	manager := config.NewManager()
+	require.NoError(t, manager.LoadConfig(path))
+	require.NoError(t, manager.ValidateConfig())
+	assert.Equal(t, path, manager.ConfigFile())
+
+	serviceRegistry := registry.NewServiceRegistry()
+	for name, service := range manager.GetConfig().Services {
+		service.Name = name
+		serviceRegistry.RegisterService(service)
+	}
+	for _, route := range manager.GetConfig().Routes {
+		serviceRegistry.RegisterRoute(route)
+	}
+	// Entries of other sources, such as self-registration, are left alone
+	serviceRegistry.RegisterService(*models.NewServiceConfig("inventory", "http://inventory:8010", time.Second))
+	serviceRegistry.RegisterRoute(*models.NewRouteConfig("/api/inventory/*", "inventory"))
+
+	apply := func(previous, current *models.GatewayConfig) error {
+		return serviceRegistry.ReplaceRouting(previous.Services, current.Services, previous.Routes, current.Routes)
+	}
+
+	t.Run("Services and routes are swapped in the registry", func(t *testing.T) {
+		write(`
+services:
+  orders:
+    name: "orders"
+    url: "http://orders:8008"
+    timeout: "10s"
+    enabled: true
+  invoices:
+    name: "invoices"
+    url: "http://invoices:8009"
+    timeout: "5s"
+    enabled: true
+routes:
+  - path: "/api/invoices/*"
+    service_name: "invoices"
+  - path: "/api/orders/*"
+    service_name: "orders"
+`)
+		_, err := manager.ReloadWith(apply)
+		require.NoError(t, err)
+
+		route, service := serviceRegistry.FindRoute(http.MethodGet, "/api/invoices/1")
+		require.NotNil(t, route)
+		assert.Equal(t, "invoices", service.Name)
+		_, service = serviceRegistry.FindRoute(http.MethodGet, "/api/orders/1")
+		require.NotNil(t, service)
+		assert.Equal(t, 10*time.Second, service.Timeout)
+		_, service = serviceRegistry.FindRoute(http.MethodGet, "/api/inventory/1")
+		require.NotNil(t, service)
+		assert.Equal(t, "inventory", service.Name)
+
+		routes := serviceRegistry.GetRoutes()
+		require.Len(t, routes, 3)
+		assert.Equal(t, "/api/invoices/*", routes[0].Path)
+		assert.Equal(t, "/api/inventory/*", routes[2].Path)
+	})
+
+	t.Run("Rejected configs leave the registry and config as they were", func(t *testing.T) {
+		write(hotReloadConfig + `
+  - path: "/api/stock/*"
+    service_name: "orders"
+`)
+		_, err := manager.ReloadWith(func(previous, current *models.GatewayConfig) error {
+			return errors.New("route rejected")
+		})
+		require.ErrorContains(t, err, "route rejected")
+		assert.Len(t, manager.GetConfig().Routes, 2)
+		assert.Len(t, serviceRegistry.GetRoutes(), 3)
+		assert.Equal(t, int64(1), manager.ReloadStats().Stats()["validation_failures"])
+
+		// The file takes over inventory, then removing it while the
+		// self-registered route still uses it is refused
+		write(`
+services:
+  inventory:
+    name: "inventory"
+    url: "http://inventory:8010"
+    timeout: "5s"
+    enabled: true
+routes: []
+`)
+		_, err = manager.ReloadWith(apply)
+		require.NoError(t, err)
+		assert.Len(t, serviceRegistry.GetRoutes(), 1)
+		_, exists := serviceRegistry.GetService("orders")
+		assert.False(t, exists)
+
+		previous := manager.GetConfig()
+		err = serviceRegistry.ReplaceRouting(previous.Services, nil, previous.Routes, nil)
+		assert.ErrorContains(t, err, "non-existent service: inventory")
+		_, exists = serviceRegistry.GetService("inventory")
+		assert.True(t, exists)
+	})
+
+	t.Run("Changes to the file are picked up", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		reloads := make(chan struct{}, 4)
+		go config.WatchFiles(ctx, func() []string { return []string{path} }, 50*time.Millisecond, func() { reloads <- struct{}{} })
+		time.Sleep(100 * time.Millisecond)
+
+		// An editor saving by renaming a new file over the old one
+		replacement := path + ".tmp"
+		require.NoError(t, os.WriteFile(replacement, []byte(hotReloadConfig), 0o600))
+		require.NoError(t, os.Rename(replacement, path))
+		select {
+		case <-reloads:
+		case <-time.After(3 * time.Second):
+			t.Fatal("file change was not noticed")
+		}
+
+		// Saving the same content again is not a change
+		write(hotReloadConfig)
+		select {
+		case <-reloads:
+			t.Fatal("unchanged file triggered a reload")
+		case <-time.After(300 * time.Millisecond):
+		}
+	})
+}
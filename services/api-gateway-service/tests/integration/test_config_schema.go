@@ -0,0 +1,114 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gateway/internal/config"
+	"gateway/internal/handlers"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigSchema(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("The schema describes the config models", func(t *testing.T) {
+		schema := config.GatewaySchema()
+		assert.Equal(t, false, schema.AdditionalProperties)
+		assert.Contains(t, schema.Properties, "include")
+
+		server := schema.Properties["server"]
+		assert.Equal(t, "integer", server.Properties["port"].Type)
+		assert.Equal(t, "string", server.Properties["read_timeout"].Type)
+		assert.NotEmpty(t, server.Properties["read_timeout"].Pattern)
+
+		services := schema.Properties["services"]
+		assert.Equal(t, "object", services.Type)
+		service, ok := services.AdditionalProperties.(*config.Schema)
+		require.True(t, ok)
+		assert.Equal(t, "boolean", service.Properties["enabled"].Type)
+		assert.Equal(t, "array", schema.Properties["routes"].Type)
+	})
+
+	t.Run("The shipped config matches the schema", func(t *testing.T) {
+		data, err := os.ReadFile("../../config/config.yaml")
+		require.NoError(t, err)
+		assert.Empty(t, config.CheckSchema("config.yaml", data))
+	})
+
+	t.Run("Every problem in every file is reported with its line", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "config.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(`include: routes.yaml
+server:
+  prot: 8000
+  read_timeout: 30
+services:
+  Orders:
+    name: "orders"
+    url: "http://orders:8008"
+    enabled: maybe
+    timeout: ~
+`), 0o600))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "routes.yaml"), []byte(`routes:
+  - path: "/api/orders/*"
+    service_name: "orders"
+    methods: [GET]
+  - "/api/cart/*"
+`), 0o600))
+
+		err := config.NewManager().LoadConfig(path)
+		var schemaErr *config.SchemaError
+		require.ErrorAs(t, err, &schemaErr)
+
+		reported := make([]string, len(schemaErr.Violations))
+		for i, violation := range schemaErr.Violations {
+			reported[i] = violation.String()
+		}
+		routes := filepath.Join(dir, "routes.yaml")
+		assert.Equal(t, []string{
+			path + `:3:3: server.prot: unknown setting "prot", did you mean "port"?`,
+			path + `:4:17: server.read_timeout: duration 30 needs a unit, like "30s"`,
+			path + `:9:14: services.Orders.enabled: expected true or false, got "maybe"`,
+			routes + `:4:5: routes.0.methods: unknown setting "methods", did you mean "method"?`,
+			routes + `:5:5: routes.1: expected a mapping of settings, got "/api/cart/*"`,
+		}, reported)
+		assert.Contains(t, err.Error(), "5 problem(s)")
+	})
+
+	t.Run("Dry runs list the violations", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(reloadBaseConfig), 0o600))
+		manager := config.NewManager()
+		require.NoError(t, manager.LoadConfig(path))
+
+		router := gin.New()
+		handlers.NewConfigHandler(manager, manager.GetConfig(), nil).Register(router.Group("/gateway/config"))
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/gateway/config/dry-run", strings.NewReader(reloadBaseConfig+"rate_limt:\n  requests: 50\n")))
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, false, body["valid"])
+		assert.Equal(t, config.ReloadLoad, body["stage"])
+		violations := body["violations"].([]interface{})
+		require.Len(t, violations, 1)
+		violation := violations[0].(map[string]interface{})
+		assert.Equal(t, "candidate", violation["file"])
+		assert.Equal(t, float64(10), violation["line"])
+		assert.Equal(t, "rate_limt", violation["path"])
+
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/gateway/config/schema", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"read_timeout"`)
+	})
+}
@@ -0,0 +1,138 @@
+package integration
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gateway/internal/anomaly"
+	"gateway/internal/models"
+	"gateway/internal/proxy"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContentAnomalies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var broken atomic.Bool
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if broken.Load() {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte("<html><body>Service temporarily unavailable</body></html>"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":1,"name":"Widget","price":9.99}`))
+	}))
+	defer upstream.Close()
+
+	serviceRegistry := registry.NewServiceRegistry()
+	serviceRegistry.RegisterService(*models.NewServiceConfig("products", upstream.URL, time.Second))
+	serviceRegistry.RegisterRoute(*models.NewRouteConfig("/api/products/*", "products"))
+
+	var mutex sync.Mutex
+	var events []models.HealthEvent
+	detector := anomaly.NewDetector(models.AnomalyConfig{
+		Enabled:       true,
+		SampleRate:    1,
+		Window:        100 * time.Millisecond,
+		MinSamples:    5,
+		MismatchRatio: 0.5,
+		SizeFactor:    5,
+		MaxBodySize:   1024,
+	}, func(event models.HealthEvent) {
+		mutex.Lock()
+		events = append(events, event)
+		mutex.Unlock()
+	})
+
+	proxyHandler := proxy.New(serviceRegistry)
+	proxyHandler.SetAnomalyDetector(detector)
+	router := gin.New()
+	router.Any("/api/*path", proxyHandler.Handle)
+
+	// window sends a window's worth of requests and waits for it to end
+	window := func() {
+		for i := 0; i < 6; i++ {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/products/1", nil))
+			require.Equal(t, http.StatusOK, w.Code)
+		}
+		time.Sleep(110 * time.Millisecond)
+	}
+	recorded := func() []models.HealthEvent {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]models.HealthEvent(nil), events...)
+	}
+
+	t.Run("The baseline is learned from usual responses", func(t *testing.T) {
+		window()
+		window()
+		window()
+
+		routes := detector.Routes()
+		require.Len(t, routes, 1)
+		assert.Equal(t, "/api/products/*", routes[0].Route)
+		assert.Equal(t, "products", routes[0].Service)
+		assert.False(t, routes[0].Learning)
+		assert.False(t, routes[0].Anomalous)
+		assert.Equal(t, "application/json {id,name,price}", routes[0].Usual)
+		assert.Empty(t, recorded())
+	})
+
+	t.Run("HTML served with a 200 is flagged", func(t *testing.T) {
+		broken.Store(true)
+		window()
+		window()
+
+		events := recorded()
+		require.Len(t, events, 1)
+		assert.Equal(t, models.EventContentAnomaly, events[0].Type)
+		assert.Equal(t, "products", events[0].Service)
+		assert.Contains(t, events[0].Reason, "text/html")
+		assert.Contains(t, events[0].Reason, "usually application/json {id,name,price}")
+
+		routes := detector.Routes()
+		assert.True(t, routes[0].Anomalous)
+		assert.Equal(t, int64(1), routes[0].Anomalies)
+		// Anomalous windows do not become the new normal
+		assert.Equal(t, "application/json {id,name,price}", routes[0].Usual)
+	})
+
+	t.Run("Usual responses recover the route", func(t *testing.T) {
+		broken.Store(false)
+		window()
+		window()
+
+		events := recorded()
+		require.Len(t, events, 2)
+		assert.Equal(t, models.EventContentRecovered, events[1].Type)
+		assert.False(t, detector.Routes()[0].Anomalous)
+		assert.Equal(t, 0, detector.Stats()["anomalous"])
+	})
+}
+
+func TestResponseSignature(t *testing.T) {
+	header := func(contentType string) http.Header {
+		return http.Header{"Content-Type": {contentType}}
+	}
+
+	assert.Equal(t, "application/json {a,b}", anomaly.Signature(header("application/json; charset=utf-8"), []byte(`{"b":1,"a":[2]}`), true))
+	assert.Equal(t, "application/json array", anomaly.Signature(header("application/json"), []byte(`[{"a":1}]`), true))
+	assert.Equal(t, "application/json invalid", anomaly.Signature(header("application/json"), []byte(`<html>`), true))
+	assert.Equal(t, "application/json", anomaly.Signature(header("application/json"), []byte(`{"a":`), false))
+	assert.Equal(t, "text/html", anomaly.Signature(header("text/html; charset=utf-8"), []byte(`<html>`), true))
+	assert.Equal(t, "none", anomaly.Signature(http.Header{}, nil, true))
+
+	gzipped := header("application/json")
+	gzipped.Set("Content-Encoding", "gzip")
+	assert.Equal(t, "application/json gzip", anomaly.Signature(gzipped, []byte{0x1f, 0x8b}, true))
+}
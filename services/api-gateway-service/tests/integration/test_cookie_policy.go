@@ -0,0 +1,61 @@
+package integration
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gateway/internal/models"
+	"gateway/internal/proxy"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetCookieRewriting(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	legacy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Set-Cookie", "cart=abc123; Path=/cart; Domain=legacy.internal; Max-Age=3600")
+		w.Header().Add("Set-Cookie", "prefs=dark; path=/; SameSite=Strict; Secure")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer legacy.Close()
+
+	serviceRegistry := registry.NewServiceRegistry()
+	serviceRegistry.RegisterService(*models.NewServiceConfig("cart", legacy.URL, time.Second))
+	enabled := true
+	rewritten := models.NewRouteConfig("/api/cart/*", "cart")
+	rewritten.CookiePolicy = &models.CookiePolicy{
+		SameSite: "none",
+		HTTPOnly: &enabled,
+		Path:     "/api/cart",
+		HostOnly: true,
+	}
+	serviceRegistry.RegisterRoute(*rewritten)
+	serviceRegistry.RegisterRoute(*models.NewRouteConfig("/api/legacy-cart/*", "cart"))
+
+	router := gin.New()
+	router.Any("/api/*proxyPath", proxy.New(serviceRegistry).Handle)
+
+	get := func(path string) []string {
+		req, _ := http.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		return w.Header().Values("Set-Cookie")
+	}
+
+	assert.Equal(t, []string{
+		"cart=abc123; Max-Age=3600; Path=/api/cart; Secure; HttpOnly; SameSite=None",
+		"prefs=dark; Path=/api/cart; Secure; HttpOnly; SameSite=None",
+	}, get("/api/cart/items"))
+
+	// Routes without a policy pass cookies through untouched
+	assert.Equal(t, []string{
+		"cart=abc123; Path=/cart; Domain=legacy.internal; Max-Age=3600",
+		"prefs=dark; path=/; SameSite=Strict; Secure",
+	}, get("/api/legacy-cart/items"))
+}
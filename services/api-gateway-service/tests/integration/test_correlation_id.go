@@ -0,0 +1,102 @@
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"gateway/internal/auth"
+	"gateway/internal/middleware"
+	"gateway/internal/models"
+	"gateway/internal/proxy"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCorrelationID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	received := make(chan string, 1)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get(middleware.CorrelationIDHeader)
+		// Some services echo the ID back
+		w.Header().Set(middleware.CorrelationIDHeader, r.Header.Get(middleware.CorrelationIDHeader))
+		w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+
+	serviceRegistry := registry.NewServiceRegistry()
+	serviceRegistry.RegisterService(*models.NewServiceConfig("products", upstream.URL, time.Second))
+	serviceRegistry.RegisterRoute(*models.NewRouteConfig("/api/products/*", "products"))
+
+	router := gin.New()
+	router.Use(middleware.CorrelationID())
+	router.Any("/api/*path", proxy.New(serviceRegistry).Handle)
+
+	request := func(path, id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if id != "" {
+			req.Header.Set(middleware.CorrelationIDHeader, id)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Requests without an ID are given one", func(t *testing.T) {
+		w := request("/api/products/1", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		id := w.Header().Get(middleware.CorrelationIDHeader)
+		assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`), id)
+		assert.Equal(t, id, <-received)
+		assert.Len(t, w.Header().Values(middleware.CorrelationIDHeader), 1)
+
+		assert.NotEqual(t, id, request("/api/products/1", "").Header().Get(middleware.CorrelationIDHeader))
+		<-received
+	})
+
+	t.Run("A client's ID is kept and forwarded", func(t *testing.T) {
+		w := request("/api/products/1", "checkout-7f3a")
+		assert.Equal(t, "checkout-7f3a", w.Header().Get(middleware.CorrelationIDHeader))
+		assert.Equal(t, "checkout-7f3a", <-received)
+	})
+
+	t.Run("Unusable IDs are replaced", func(t *testing.T) {
+		for _, id := range []string{strings.Repeat("a", 129), "two words"} {
+			w := request("/api/products/1", id)
+			replaced := w.Header().Get(middleware.CorrelationIDHeader)
+			assert.NotEqual(t, id, replaced)
+			assert.Len(t, replaced, 36)
+			assert.Equal(t, replaced, <-received)
+		}
+	})
+
+	t.Run("Error bodies carry the ID", func(t *testing.T) {
+		w := request("/api/unknown", "lost-42")
+		require.Equal(t, http.StatusNotFound, w.Code)
+		var body map[string]string
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, "lost-42", body["correlation_id"])
+	})
+
+	t.Run("Calls to the auth service carry the ID", func(t *testing.T) {
+		authService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			received <- r.Header.Get(middleware.CorrelationIDHeader)
+			w.WriteHeader(http.StatusUnauthorized)
+		}))
+		defer authService.Close()
+
+		ctx := models.WithCorrelationID(context.Background(), "login-9")
+		_, err := auth.NewClient(authService.URL, time.Second).Verify(ctx, "token")
+		assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+		assert.Equal(t, "login-9", <-received)
+	})
+}
@@ -0,0 +1,125 @@
+package integration
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gateway/internal/config"
+	"gateway/internal/middleware"
+	"gateway/internal/models"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCORSPolicy(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	credentials := true
+	serviceRegistry := registry.NewServiceRegistry()
+	serviceRegistry.RegisterService(*models.NewServiceConfig("orders", "http://orders:8080", time.Second))
+	serviceRegistry.RegisterService(*models.NewServiceConfig("account", "http://account:8080", time.Second))
+	serviceRegistry.RegisterRoute(*models.NewRouteConfig("/api/orders/*", "orders"))
+	account := models.NewRouteConfig("/api/account/*", "account")
+	account.CORS = &models.CORSOverride{
+		AllowedOrigins:   []string{"https://account.example.com"},
+		AllowCredentials: &credentials,
+		MaxAge:           10 * time.Minute,
+	}
+	serviceRegistry.RegisterRoute(*account)
+
+	cors := models.NewDefaultGatewayConfig().CORS
+	cors.AllowedOrigins = []string{"https://shop.example.com", "https://*.shop.example.com"}
+	router := gin.New()
+	router.Use(middleware.CORS(cors, serviceRegistry))
+	router.Any("/api/*path", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	send := func(method, path, origin string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Allowed origins are echoed back", func(t *testing.T) {
+		for _, origin := range []string{"https://shop.example.com", "https://eu.shop.example.com"} {
+			w := send(http.MethodGet, "/api/orders/1", origin, nil)
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, origin, w.Header().Get("Access-Control-Allow-Origin"))
+			assert.Equal(t, "X-Correlation-ID", w.Header().Get("Access-Control-Expose-Headers"))
+			assert.Equal(t, "Origin", w.Header().Get("Vary"))
+			assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+		}
+	})
+
+	t.Run("Other origins get no CORS headers", func(t *testing.T) {
+		for _, origin := range []string{"https://evil.example.com", "https://shop.example.com.evil.net", "http://shop.example.com"} {
+			w := send(http.MethodGet, "/api/orders/1", origin, nil)
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"), origin)
+		}
+	})
+
+	t.Run("Preflights are answered by the gateway", func(t *testing.T) {
+		w := send(http.MethodOptions, "/api/orders/1", "https://shop.example.com", map[string]string{"Access-Control-Request-Method": "DELETE"})
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Equal(t, "GET, POST, PUT, DELETE, OPTIONS", w.Header().Get("Access-Control-Allow-Methods"))
+		assert.Contains(t, w.Header().Get("Access-Control-Allow-Headers"), "Authorization")
+		assert.Empty(t, w.Header().Get("Access-Control-Max-Age"))
+
+		w = send(http.MethodOptions, "/api/orders/1", "https://evil.example.com", map[string]string{"Access-Control-Request-Method": "DELETE"})
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Methods"))
+	})
+
+	t.Run("Routes override the policy", func(t *testing.T) {
+		w := send(http.MethodOptions, "/api/account/me", "https://account.example.com", map[string]string{"Access-Control-Request-Method": "GET"})
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Equal(t, "https://account.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+		assert.Equal(t, "600", w.Header().Get("Access-Control-Max-Age"))
+
+		w = send(http.MethodGet, "/api/account/me", "https://shop.example.com", nil)
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("Any origin is allowed with a wildcard", func(t *testing.T) {
+		router := gin.New()
+		router.Use(middleware.CORS(models.NewDefaultGatewayConfig().CORS, serviceRegistry))
+		router.GET("/api/*path", func(c *gin.Context) { c.Status(http.StatusOK) })
+		req := httptest.NewRequest(http.MethodGet, "/api/orders/1", nil)
+		req.Header.Set("Origin", "https://anywhere.example.org")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Empty(t, w.Header().Get("Vary"))
+	})
+
+	t.Run("Invalid policies are rejected", func(t *testing.T) {
+		for name, content := range map[string]string{
+			"cors allow_credentials":              reloadBaseConfig + "cors:\n  allow_credentials: true\n",
+			"route 0 cors allow_credentials":      reloadBaseConfig + "    cors:\n      allow_credentials: true\n",
+			"cors allowed_origins entry \"shop\"": reloadBaseConfig + "cors:\n  allowed_origins: [\"shop\"]\n",
+		} {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+			manager := config.NewManager()
+			require.NoError(t, manager.LoadConfig(path))
+			err := manager.ValidateConfig()
+			require.Error(t, err, name)
+			assert.Contains(t, err.Error(), name)
+		}
+	})
+}
@@ -0,0 +1,123 @@
+package integration
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gateway/internal/config"
+	"gateway/internal/middleware"
+	"gateway/internal/models"
+	"gateway/internal/ratelimit"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCostWeightedRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	serviceRegistry := registry.NewServiceRegistry()
+	serviceRegistry.RegisterService(*models.NewServiceConfig("products", "http://products:8080", time.Second))
+	search := models.NewRouteConfig("/api/search/*", "products")
+	search.Cost = 5
+	serviceRegistry.RegisterRoute(*search)
+	serviceRegistry.RegisterRoute(*models.NewRouteConfig("/api/products/*", "products"))
+
+	policy := *models.NewRateLimitPolicy("test", 10, time.Hour, 10)
+	router := gin.New()
+	router.Use(middleware.RouteCost(serviceRegistry))
+	router.Use(middleware.RateLimit(policy, ratelimit.NewLimiter(policy.GetRate(), policy.Burst), nil))
+	router.Any("/api/*path", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	request := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	t.Run("Expensive routes drain the caller's bucket faster", func(t *testing.T) {
+		w := request("/api/products/1")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "9", w.Header().Get("X-RateLimit-Remaining"))
+		assert.Empty(t, w.Header().Get(middleware.RateLimitCostHeader))
+
+		w = request("/api/search/shoes")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "4", w.Header().Get("X-RateLimit-Remaining"))
+		assert.Equal(t, "5", w.Header().Get(middleware.RateLimitCostHeader))
+
+		// Four tokens are left: not enough for a search, plenty for reads
+		w = request("/api/search/boots")
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, "4", w.Header().Get("X-RateLimit-Remaining"))
+		for i := 0; i < 4; i++ {
+			assert.Equal(t, http.StatusOK, request("/api/products/1").Code)
+		}
+		assert.Equal(t, http.StatusTooManyRequests, request("/api/products/1").Code)
+	})
+
+	t.Run("A costly request waits for all of its tokens", func(t *testing.T) {
+		limiter := ratelimit.NewLimiter(1, 10)
+		assert.True(t, limiter.TakeN("client", 8).Allowed)
+		quota := limiter.TakeN("client", 5)
+		assert.False(t, quota.Allowed)
+		assert.Equal(t, 2, quota.Remaining)
+		assert.InDelta(t, 3*time.Second, quota.RetryAfter, float64(50*time.Millisecond))
+	})
+
+	t.Run("Workers pass the cost to the coordinator", func(t *testing.T) {
+		socket := filepath.Join(t.TempDir(), "ratelimit.sock")
+		listener, err := net.Listen("unix", socket)
+		require.NoError(t, err)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go ratelimit.ServeCoordinator(ctx, listener, ratelimit.NewLimiter(0.001, 10))
+
+		client := ratelimit.NewClient(socket, ratelimit.NewLimiter(0.001, 10))
+		quota := client.TakeN("203.0.113.7", 6)
+		assert.True(t, quota.Allowed)
+		assert.Equal(t, 4, quota.Remaining)
+		assert.False(t, client.TakeN("203.0.113.7", 6).Allowed)
+		assert.Equal(t, 3, client.Take("203.0.113.7").Remaining)
+		assert.Equal(t, int64(0), client.Stats()["coordinator_errors"])
+	})
+}
+
+func TestRouteCostValidation(t *testing.T) {
+	load := func(t *testing.T, cost string) error {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(`
+rate_limit:
+  enabled: true
+  requests: 10
+  window: "1s"
+  burst: 20
+services:
+  products:
+    name: "products"
+    url: "http://products:8080"
+    timeout: "5s"
+routes:
+  - path: "/api/search/*"
+    service_name: "products"
+    cost: `+cost+`
+`), 0o600))
+		manager := config.NewManager()
+		require.NoError(t, manager.LoadConfig(path))
+		return manager.ValidateConfig()
+	}
+
+	assert.NoError(t, load(t, "20"))
+	assert.ErrorContains(t, load(t, "21"), "exceeds the rate_limit burst")
+	assert.ErrorContains(t, load(t, "-1"), "cost must not be negative")
+}
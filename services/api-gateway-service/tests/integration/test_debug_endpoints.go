@@ -0,0 +1,97 @@
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gateway/internal/dialer"
+	"gateway/internal/handlers"
+	"gateway/internal/middleware"
+	"gateway/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebugEndpoints(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer upstream.Close()
+	address := strings.TrimPrefix(upstream.URL, "http://")
+	upstreamDialer := dialer.New(models.DialConfig{ConnectTimeout: time.Second}, nil)
+
+	adminConfig := models.AdminConfig{
+		Token: "root-token",
+		Tokens: []models.AdminToken{
+			{Name: "dashboard", Token: "dashboard-token", Scopes: []models.AdminScope{models.AdminScopeRead}},
+		},
+	}
+	router := gin.New()
+	debugHandler := handlers.NewDebugHandler(upstreamDialer.Connections)
+	debugHandler.Register(router.Group("/gateway/debug", middleware.AdminAuth(adminConfig)))
+	debugHandler.RegisterProfiles(router.Group("/debug/pprof", middleware.AdminAuth(adminConfig), middleware.RequireAdminScope(models.AdminScopeSecrets)))
+
+	get := func(path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if token != "" {
+			req.Header.Set(middleware.AdminTokenHeader, token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Runtime statistics include open upstream connections", func(t *testing.T) {
+		first, err := upstreamDialer.DialContext(context.Background(), "tcp", address)
+		require.NoError(t, err)
+		second, err := upstreamDialer.DialContext(context.Background(), "tcp", address)
+		require.NoError(t, err)
+		_, err = upstreamDialer.DialContext(context.Background(), "tcp", "127.0.0.1:1")
+		require.Error(t, err)
+
+		w := get("/gateway/debug/runtime", "dashboard-token")
+		require.Equal(t, http.StatusOK, w.Code)
+		var body struct {
+			Goroutines  int                    `json:"goroutines"`
+			Memory      map[string]int64       `json:"memory"`
+			GC          map[string]interface{} `json:"gc"`
+			Connections map[string]int64       `json:"upstream_connections"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Greater(t, body.Goroutines, 0)
+		assert.Greater(t, body.Memory["heap_alloc_bytes"], int64(0))
+		assert.Contains(t, body.GC, "pause_total_ms")
+		assert.Equal(t, map[string]int64{address: 2}, body.Connections)
+
+		first.Close()
+		first.Close()
+		assert.Equal(t, map[string]int64{address: 1}, upstreamDialer.Connections())
+		second.Close()
+		assert.Empty(t, upstreamDialer.Connections())
+	})
+
+	t.Run("Profiles need the secrets scope", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, get("/debug/pprof/", "").Code)
+		assert.Equal(t, http.StatusForbidden, get("/debug/pprof/", "dashboard-token").Code)
+		assert.Equal(t, http.StatusUnauthorized, get("/gateway/debug/runtime", "").Code)
+
+		w := get("/debug/pprof/", "root-token")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "goroutine")
+
+		w = get("/debug/pprof/goroutine?debug=1", "root-token")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "goroutine profile")
+
+		w = get("/debug/pprof/cmdline", "root-token")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotEmpty(t, w.Body.String())
+	})
+}
@@ -0,0 +1,89 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gateway/internal/devauth"
+	"gateway/internal/handlers"
+	"gateway/internal/middleware"
+	"gateway/internal/models"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDevAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	issuer, err := devauth.New(nil)
+	require.NoError(t, err)
+
+	serviceRegistry := registry.NewServiceRegistry()
+	serviceRegistry.RegisterService(*models.NewServiceConfig("orders", "http://orders", time.Second))
+	orders := models.NewRouteConfig("/api/orders/*", "orders")
+	orders.AuthRequired = true
+	serviceRegistry.RegisterRoute(*orders)
+
+	router := gin.New()
+	handlers.NewDevAuthHandler(issuer).Register(router.Group("/dev/auth"))
+	api := router.Group("/api")
+	api.Use(middleware.Authenticate(issuer, serviceRegistry, models.AuthConfig{}))
+	api.Use(middleware.PropagateIdentity(map[string]string{"user_id": "X-User-ID", "scopes": "X-Scopes", "tenant": "X-Tenant"}))
+	api.GET("/*path", func(c *gin.Context) {
+		c.String(http.StatusOK, "%s|%s|%s", c.GetHeader("X-User-ID"), c.GetHeader("X-Scopes"), c.GetHeader("X-Tenant"))
+	})
+
+	issue := func(body string) string {
+		req, _ := http.NewRequest("POST", "/dev/auth/token", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var resp struct {
+			AccessToken string `json:"access_token"`
+			TokenType   string `json:"token_type"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, "Bearer", resp.TokenType)
+		return resp.AccessToken
+	}
+	send := func(method, path, token string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	token := issue(`{"user_id": "alice", "scopes": ["orders:read"], "claims": {"tenant": "acme"}}`)
+	w := send("GET", "/api/orders/1", token)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "alice|orders:read|acme", w.Body.String())
+
+	// The verify endpoint speaks the auth service's protocol
+	w = send("GET", "/dev/auth/verify", token)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"valid": true, "user_id": "alice", "email": "", "token_data": {"scope": "orders:read", "tenant": "acme"}}`, w.Body.String())
+
+	// An empty request gets the default dev user
+	w = send("GET", "/api/orders/1", issue(""))
+	assert.Equal(t, "dev-user||", w.Body.String())
+
+	// Tampered, foreign and expired tokens are rejected
+	assert.Equal(t, http.StatusUnauthorized, send("GET", "/api/orders/1", token[:len(token)-2]+"xx").Code)
+	other, _ := devauth.New([]byte("another secret"))
+	foreign, _, _ := other.Issue(devauth.Claims{Subject: "alice"}, time.Hour)
+	assert.Equal(t, http.StatusUnauthorized, send("GET", "/api/orders/1", foreign).Code)
+	expired, _, _ := issuer.Issue(devauth.Claims{Subject: "alice"}, -time.Second)
+	assert.Equal(t, http.StatusUnauthorized, send("GET", "/api/orders/1", expired).Code)
+	assert.Equal(t, http.StatusUnauthorized, send("GET", "/dev/auth/verify", expired).Code)
+}
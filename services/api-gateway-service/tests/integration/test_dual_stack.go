@@ -0,0 +1,92 @@
+package integration
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"gateway/internal/dialer"
+	"gateway/internal/models"
+	"gateway/internal/proxy"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// staticResolver answers every lookup with the same addresses.
+type staticResolver []net.IPAddr
+
+func (r staticResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	return r, nil
+}
+
+func TestDualStackDialing(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	listener, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skip("IPv6 loopback unavailable")
+	}
+	upstream := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	upstream.Listener = listener
+	upstream.Start()
+	defer upstream.Close()
+	port := strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)
+
+	// The IPv4 address is TEST-NET-1, which never answers
+	resolver := staticResolver{{IP: net.ParseIP("192.0.2.1")}, {IP: net.ParseIP("::1")}}
+	upstreamDialer := dialer.New(models.DialConfig{
+		HappyEyeballs:  true,
+		AttemptDelay:   50 * time.Millisecond,
+		ConnectTimeout: 5 * time.Second,
+	}, resolver)
+
+	serviceRegistry := registry.NewServiceRegistry()
+	preferV4 := models.NewServiceConfig("legacy", "http://upstream.internal:"+port, 5*time.Second)
+	preferV4.IPPreference = models.IPPreferenceIPv4
+	serviceRegistry.RegisterService(*preferV4)
+	serviceRegistry.RegisterService(*models.NewServiceConfig("modern", "http://upstream.internal:"+port, 5*time.Second))
+	serviceRegistry.RegisterRoute(*models.NewRouteConfig("/api/legacy/*", "legacy"))
+	serviceRegistry.RegisterRoute(*models.NewRouteConfig("/api/modern/*", "modern"))
+
+	proxyHandler := proxy.New(serviceRegistry)
+	proxyHandler.Transport().DialContext = upstreamDialer.DialContext
+	proxyHandler.Transport().DisableKeepAlives = true
+	router := gin.New()
+	router.Any("/api/*path", proxyHandler.Handle)
+
+	t.Run("Auto prefers IPv6", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/modern/ping", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, int64(0), upstreamDialer.Stats()["fallbacks"])
+	})
+
+	t.Run("Unreachable preferred family falls back quickly", func(t *testing.T) {
+		start := time.Now()
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/legacy/ping", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Less(t, time.Since(start), time.Second)
+		assert.Equal(t, int64(1), upstreamDialer.Stats()["fallbacks"])
+	})
+
+	t.Run("Every address failing is a dial error", func(t *testing.T) {
+		failing := dialer.New(models.DialConfig{
+			HappyEyeballs:  true,
+			AttemptDelay:   50 * time.Millisecond,
+			ConnectTimeout: 200 * time.Millisecond,
+		}, staticResolver{{IP: net.ParseIP("192.0.2.1")}, {IP: net.ParseIP("192.0.2.2")}})
+		_, err := failing.DialContext(context.Background(), "tcp", "upstream.internal:"+port)
+		require.Error(t, err)
+		assert.Equal(t, int64(1), failing.Stats()["failures"])
+	})
+}
@@ -0,0 +1,185 @@
+package integration
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+
+	"gateway/internal/config"
+	"gateway/internal/models"
+	"gateway/internal/registry"
+	"gateway/internal/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeEtcd serves the range requests of etcd's JSON gateway from a map.
+// Watches are held open without events; tests sync explicitly.
+type fakeEtcd struct {
+	mutex    sync.Mutex
+	kvs      map[string]string
+	revision int64
+}
+
+func (f *fakeEtcd) put(key, value string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.kvs[key] = value
+	f.revision++
+}
+
+func (f *fakeEtcd) delete(key string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	delete(f.kvs, key)
+	f.revision++
+}
+
+func (f *fakeEtcd) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/v3/watch" {
+		<-r.Context().Done()
+		return
+	}
+	var request struct {
+		Key      string `json:"key"`
+		RangeEnd string `json:"range_end"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	start, _ := base64.StdEncoding.DecodeString(request.Key)
+	end, _ := base64.StdEncoding.DecodeString(request.RangeEnd)
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	keys := make([]string, 0, len(f.kvs))
+	for key := range f.kvs {
+		if key >= string(start) && key < string(end) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	kvs := make([]map[string]string, 0, len(keys))
+	for _, key := range keys {
+		kvs = append(kvs, map[string]string{
+			"key":   base64.StdEncoding.EncodeToString([]byte(key)),
+			"value": base64.StdEncoding.EncodeToString([]byte(f.kvs[key])),
+		})
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"header": map[string]string{"revision": strconv.FormatInt(f.revision, 10)},
+		"kvs":    kvs,
+	})
+}
+
+func TestEtcdRouting(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+services:
+  orders:
+    url: "http://orders:8008"
+    timeout: "5s"
+    enabled: true
+routes:
+  - path: "/api/orders/*"
+    service_name: "orders"
+`), 0o600))
+	manager := config.NewManager()
+	require.NoError(t, manager.LoadConfig(path))
+	cfg := manager.GetConfig()
+
+	serviceRegistry := registry.NewServiceRegistry()
+	for name, service := range cfg.Services {
+		service.Name = name
+		serviceRegistry.RegisterService(service)
+	}
+	for _, route := range cfg.Routes {
+		serviceRegistry.RegisterRoute(route)
+	}
+
+	etcd := &fakeEtcd{kvs: make(map[string]string)}
+	server := httptest.NewServer(etcd)
+	defer server.Close()
+	client, err := store.NewEtcdClient(models.EtcdConfig{Endpoints: []string{server.URL}})
+	require.NoError(t, err)
+	routing := store.NewRoutingStore(client, "/gateway", serviceRegistry, manager)
+	ctx := context.Background()
+
+	etcd.put("/gateway/services/billing", "url: http://billing:8080\ntimeout: 5s\n")
+	etcd.put("/gateway/routes/010-billing", `{"path": "/api/billing/*", "service_name": "billing"}`)
+	etcd.put("/gateway/routes/020-invoices", `{"path": "/api/invoices/*", "service_name": "orders"}`)
+	require.NoError(t, routing.Sync(ctx))
+
+	t.Run("Valid entries are registered", func(t *testing.T) {
+		route, service := serviceRegistry.FindRoute(http.MethodGet, "/api/billing/42")
+		require.NotNil(t, route)
+		assert.Equal(t, "http://billing:8080", service.URL)
+		route, _ = serviceRegistry.FindRoute(http.MethodGet, "/api/invoices/7")
+		require.NotNil(t, route, "etcd routes may use services of the config file")
+	})
+
+	t.Run("Invalid entries are skipped", func(t *testing.T) {
+		etcd.put("/gateway/services/slow", "url: http://slow:8080\nhealth_interval: 10ms\n")
+		etcd.put("/gateway/routes/030-hooks", `{"path": "/api/hooks/*", "service_name": "billing", "signature": {"header": "X-Signature", "algorithm": "md5", "secret_ref": "env://HOOK_SECRET"}}`)
+		etcd.put("/gateway/routes/040-partners", `{"path": "/api/partners/*", "service_name": "billing", "auth_provider": "unknown"}`)
+		etcd.put("/gateway/routes/050-ledger", `{"path": "/api/ledger/*", "service_name": "ledger"}`)
+		require.NoError(t, routing.Sync(ctx))
+
+		_, exists := serviceRegistry.GetService("slow")
+		assert.False(t, exists)
+		for _, path := range []string{"/api/hooks/1", "/api/partners/1", "/api/ledger/1"} {
+			route, _ := serviceRegistry.FindRoute(http.MethodPost, path)
+			assert.Nil(t, route, path)
+		}
+		route, _ := serviceRegistry.FindRoute(http.MethodGet, "/api/billing/42")
+		assert.NotNil(t, route, "valid entries still apply")
+
+		stats := routing.ReloadStats().Stats()
+		assert.Contains(t, stats["last_error"], "/gateway/routes/030-hooks")
+	})
+
+	t.Run("Entries of the config file are not taken over", func(t *testing.T) {
+		etcd.put("/gateway/services/orders", "url: http://attacker:8080\ntimeout: 5s\n")
+		etcd.put("/gateway/routes/005-orders", `{"path": "/api/orders/*", "service_name": "billing"}`)
+		etcd.put("/gateway/routes/006-api", `{"path": "/api/*", "service_name": "billing"}`)
+		require.NoError(t, routing.Sync(ctx))
+
+		service, exists := serviceRegistry.GetService("orders")
+		require.True(t, exists)
+		assert.Equal(t, "http://orders:8008", service.URL)
+		route, service := serviceRegistry.FindRoute(http.MethodGet, "/api/orders/1")
+		require.NotNil(t, route)
+		assert.Equal(t, "orders", service.Name)
+		route, _ = serviceRegistry.FindRoute(http.MethodGet, "/api/other")
+		assert.Nil(t, route)
+	})
+
+	t.Run("Removed entries leave the config file's in place", func(t *testing.T) {
+		for key := range map[string]bool{
+			"/gateway/services/orders": true, "/gateway/routes/005-orders": true, "/gateway/routes/006-api": true,
+			"/gateway/routes/010-billing": true, "/gateway/routes/020-invoices": true,
+		} {
+			etcd.delete(key)
+		}
+		require.NoError(t, routing.Sync(ctx))
+
+		route, _ := serviceRegistry.FindRoute(http.MethodGet, "/api/billing/42")
+		assert.Nil(t, route)
+		route, _ = serviceRegistry.FindRoute(http.MethodGet, "/api/invoices/7")
+		assert.Nil(t, route)
+		route, service := serviceRegistry.FindRoute(http.MethodGet, "/api/orders/1")
+		require.NotNil(t, route)
+		assert.Equal(t, "http://orders:8008", service.URL)
+		assert.Len(t, serviceRegistry.GetRoutes(), 1)
+	})
+}
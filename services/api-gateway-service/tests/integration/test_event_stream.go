@@ -0,0 +1,206 @@
+package integration
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gateway/internal/config"
+	"gateway/internal/events"
+	"gateway/internal/handlers"
+	"gateway/internal/middleware"
+	"gateway/internal/models"
+	"gateway/internal/ratelimit"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type streamedEvent struct {
+	name  string
+	id    string
+	event models.HealthEvent
+}
+
+// readStream parses server-sent events off body onto a channel until the
+// body is closed.
+func readStream(body *bufio.Reader) <-chan streamedEvent {
+	received := make(chan streamedEvent, 16)
+	go func() {
+		defer close(received)
+		var current streamedEvent
+		for {
+			line, err := body.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\n")
+			switch {
+			case line == "":
+				if current.name != "" {
+					received <- current
+				}
+				current = streamedEvent{}
+			case strings.HasPrefix(line, "event:"):
+				current.name = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+			case strings.HasPrefix(line, "id:"):
+				current.id = strings.TrimSpace(strings.TrimPrefix(line, "id:"))
+			case strings.HasPrefix(line, "data:"):
+				json.Unmarshal([]byte(strings.TrimPrefix(line, "data:")), &current.event)
+			}
+		}
+	}()
+	return received
+}
+
+func TestEventStream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	serviceRegistry := registry.NewServiceRegistry()
+	serviceRegistry.RegisterService(*models.NewServiceConfig("orders", "http://127.0.0.1:1", time.Second))
+	serviceRegistry.RegisterRoute(models.RouteConfig{Path: "/api/orders/*", Method: "*", ServiceName: "orders"})
+
+	eventLog := events.NewLog(10)
+	stream := events.NewStream(3)
+	reloads := config.NewReloadStats("etcd")
+	reloads.OnReload(stream.Publish)
+
+	policy := *models.NewRateLimitPolicy("test", 1, time.Minute, 1)
+	router := gin.New()
+	router.Use(middleware.StreamRateLimits(stream, serviceRegistry))
+	adminAPI := router.Group("/gateway")
+	adminAPI.Use(middleware.AdminAuth(models.AdminConfig{Token: "admin-token"}))
+	handlers.NewEventsHandler(eventLog, stream).Register(adminAPI.Group("/events", middleware.RequireAdminScope(models.AdminScopeRead)))
+	api := router.Group("/api", middleware.RateLimit(policy, ratelimit.NewLimiter(policy.GetRate(), policy.Burst), nil))
+	api.GET("/orders/:id", func(c *gin.Context) {
+		c.String(http.StatusOK, "order")
+	})
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	open := func(t *testing.T, query, token string) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/gateway/events/stream"+query, nil)
+		require.NoError(t, err)
+		if token != "" {
+			req.Header.Set(middleware.AdminTokenHeader, token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		return resp
+	}
+	subscribe := func(t *testing.T, query string) (<-chan streamedEvent, func()) {
+		resp := open(t, query, "admin-token")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+		body := bufio.NewReader(resp.Body)
+		// The stream opens with a comment once subscribed
+		_, err := body.ReadString('\n')
+		require.NoError(t, err)
+		return readStream(body), func() { resp.Body.Close() }
+	}
+	next := func(t *testing.T, received <-chan streamedEvent) streamedEvent {
+		select {
+		case event := <-received:
+			return event
+		case <-time.After(3 * time.Second):
+			t.Fatal("no event was streamed")
+			return streamedEvent{}
+		}
+	}
+
+	t.Run("Streams need an admin token", func(t *testing.T) {
+		for _, token := range []string{"", "wrong"} {
+			resp := open(t, "", token)
+			resp.Body.Close()
+			assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, "token %q", token)
+		}
+		assert.Equal(t, 0, stream.Subscribers())
+	})
+
+	t.Run("Health events are streamed with their log id", func(t *testing.T) {
+		received, closeStream := subscribe(t, "")
+		defer closeStream()
+
+		event := eventLog.Append(models.HealthEvent{
+			Type: models.EventStatusChanged, Service: "orders",
+			From: models.ServiceHealthy, To: models.ServiceUnhealthy, Timestamp: time.Now(),
+		})
+		stream.Publish(event)
+
+		streamed := next(t, received)
+		assert.Equal(t, "status_changed", streamed.name)
+		assert.Equal(t, "1", streamed.id)
+		assert.Equal(t, "orders", streamed.event.Service)
+		assert.Equal(t, models.ServiceUnhealthy, streamed.event.To)
+	})
+
+	t.Run("Rate limited requests and reloads are streamed by type", func(t *testing.T) {
+		limited, closeLimited := subscribe(t, "?types=rate_limited")
+		defer closeLimited()
+		reloaded, closeReloaded := subscribe(t, "?types=config_reloaded,config_reload_failed")
+		defer closeReloaded()
+
+		for i := 0; i < 2; i++ {
+			resp, err := http.Get(server.URL + "/api/orders/7")
+			require.NoError(t, err)
+			resp.Body.Close()
+		}
+		reloads.Failed(config.ReloadLoad, errors.New("connection refused"))
+
+		streamed := next(t, limited)
+		assert.Equal(t, "rate_limited", streamed.name)
+		assert.Empty(t, streamed.id)
+		assert.Equal(t, "orders", streamed.event.Service)
+		assert.Contains(t, streamed.event.Reason, "GET /api/orders/7")
+
+		streamed = next(t, reloaded)
+		assert.Equal(t, "config_reload_failed", streamed.name)
+		assert.Equal(t, "etcd load: connection refused", streamed.event.Reason)
+
+		reloads.Applied(2, "abc", nil)
+		streamed = next(t, reloaded)
+		assert.Equal(t, "config_reloaded", streamed.name)
+		assert.Equal(t, "2 change(s) from etcd", streamed.event.Reason)
+
+		select {
+		case event := <-limited:
+			t.Fatalf("unexpected %s event on the rate limit stream", event.name)
+		default:
+		}
+	})
+
+	t.Run("Closed streams are unsubscribed", func(t *testing.T) {
+		assert.Eventually(t, func() bool {
+			return stream.Subscribers() == 0
+		}, 3*time.Second, 20*time.Millisecond)
+	})
+
+	t.Run("Unknown event types are rejected", func(t *testing.T) {
+		resp := open(t, "?types=status_changed,bogus", "admin-token")
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("Subscribers beyond the limit are turned away", func(t *testing.T) {
+		_, closeFirst := subscribe(t, "")
+		defer closeFirst()
+		_, closeSecond := subscribe(t, "")
+		defer closeSecond()
+		_, closeThird := subscribe(t, "")
+		defer closeThird()
+
+		resp := open(t, "", "admin-token")
+		resp.Body.Close()
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		assert.Equal(t, 3, stream.Subscribers())
+	})
+}
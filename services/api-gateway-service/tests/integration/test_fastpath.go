@@ -0,0 +1,69 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gateway/internal/fastpath"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProbeFastPath(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// A pipeline so overloaded it turns everything away
+	var pipelineCalls int64
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		atomic.AddInt64(&pipelineCalls, 1)
+		c.AbortWithStatus(http.StatusTooManyRequests)
+	})
+
+	startedAt := time.Now().Add(-time.Hour)
+	probes := fastpath.New(router)
+	probes.Handle("/health", fastpath.Health("1.2.3", startedAt))
+	probes.Handle("/health/live", fastpath.Live)
+	probes.Handle("/metrics", fastpath.Metrics(startedAt, func() []fastpath.Metric {
+		return []fastpath.Metric{
+			{Name: "gateway_service_up", Help: "Service health.", Labels: map[string]string{"service": "orders"}, Value: 1},
+			{Name: "gateway_service_up", Help: "Service health.", Labels: map[string]string{"service": "payments"}, Value: 0},
+		}
+	}))
+
+	get := func(method, path string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, nil)
+		w := httptest.NewRecorder()
+		probes.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("GET", "/health")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var health map[string]string
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &health))
+	assert.Equal(t, "healthy", health["status"])
+	assert.Equal(t, "1.2.3", health["version"])
+	assert.Equal(t, "1h0m0s", health["uptime"])
+
+	assert.Equal(t, http.StatusOK, get("HEAD", "/health/live").Code)
+
+	w = get("GET", "/metrics")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/plain")
+	assert.Contains(t, w.Body.String(), "# TYPE gateway_service_up gauge\ngateway_service_up{service=\"orders\"} 1\ngateway_service_up{service=\"payments\"} 0\n")
+	assert.Contains(t, w.Body.String(), "gateway_uptime_seconds ")
+
+	assert.Equal(t, int64(0), atomic.LoadInt64(&pipelineCalls))
+
+	// Everything else, including other methods on probe paths, takes the
+	// normal pipeline
+	assert.Equal(t, http.StatusTooManyRequests, get("POST", "/health").Code)
+	assert.Equal(t, http.StatusTooManyRequests, get("GET", "/health/ready").Code)
+	assert.Equal(t, int64(2), atomic.LoadInt64(&pipelineCalls))
+}
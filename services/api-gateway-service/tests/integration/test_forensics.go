@@ -0,0 +1,76 @@
+package integration
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gateway/internal/forensics"
+	"gateway/internal/handlers"
+	"gateway/internal/middleware"
+	"gateway/internal/models"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFailedRequestRecording(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	recorder := forensics.NewRecorder(2)
+	router := gin.New()
+	router.Use(middleware.RecordFailures(recorder, registry.NewServiceRegistry(), models.ForensicsConfig{MaxBodySize: 8}))
+	router.Use(gin.Recovery())
+	handlers.NewFailuresHandler(recorder).Register(router.Group("/gateway/failures"))
+
+	router.POST("/ok", func(c *gin.Context) {
+		io.Copy(io.Discard, c.Request.Body)
+		c.String(http.StatusOK, "fine")
+	})
+	router.POST("/unavailable", func(c *gin.Context) {
+		io.Copy(io.Discard, c.Request.Body)
+		c.String(http.StatusServiceUnavailable, "upstream down for maintenance")
+	})
+	router.GET("/panic", func(c *gin.Context) {
+		panic("boom")
+	})
+
+	send := func(method, path, body string) {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	send("POST", "/ok", "{}")
+	send("POST", "/unavailable", `{"order": 1234}`)
+	send("GET", "/panic", "")
+
+	req, _ := http.NewRequest("GET", "/gateway/failures", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var response struct {
+		Requests []models.RecordedRequest `json:"requests"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	if assert.Len(t, response.Requests, 2) {
+		panicked, unavailable := response.Requests[0], response.Requests[1]
+		assert.Equal(t, http.StatusInternalServerError, panicked.Status)
+		assert.Equal(t, "/panic", panicked.Path)
+
+		assert.Equal(t, http.StatusServiceUnavailable, unavailable.Status)
+		assert.Equal(t, `{"order"`, unavailable.RequestBody)
+		assert.True(t, unavailable.RequestBodyTruncated)
+		assert.Equal(t, "upstream", unavailable.ResponseBody)
+		assert.True(t, unavailable.ResponseBodyTruncated)
+		assert.Equal(t, "[REDACTED]", unavailable.RequestHeaders.Get("Authorization"))
+	}
+
+	// The buffer only keeps the newest requests
+	send("GET", "/panic", "")
+	assert.Len(t, recorder.List(0, http.StatusServiceUnavailable, 0), 0)
+}
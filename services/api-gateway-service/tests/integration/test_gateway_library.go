@@ -0,0 +1,93 @@
+package integration
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gateway/pkg/gateway"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGatewayLibrary(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Upstream-Path", r.URL.Path)
+		w.Header().Set("X-Seen-Trace", r.Header.Get("X-Trace"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	t.Run("Services and routes are validated", func(t *testing.T) {
+		engine := gateway.New()
+		assert.Error(t, engine.AddService(gateway.ServiceConfig{Name: "", URL: upstream.URL}))
+		assert.Error(t, engine.AddService(gateway.ServiceConfig{Name: "products", URL: "products:8080"}))
+		require.NoError(t, engine.AddService(*gateway.NewServiceConfig("products", upstream.URL, time.Second)))
+
+		assert.Error(t, engine.AddRoute(*gateway.NewRouteConfig("/api/orders/*", "orders")))
+		assert.Error(t, engine.AddRoute(*gateway.NewRouteConfig("/products/*", "products")))
+		assert.NoError(t, engine.AddRoute(*gateway.NewRouteConfig("/api/products/*", "products")))
+	})
+
+	t.Run("Requests go through the middleware in order", func(t *testing.T) {
+		engine := gateway.New()
+		require.NoError(t, engine.AddService(*gateway.NewServiceConfig("products", upstream.URL, time.Second)))
+		require.NoError(t, engine.AddRoute(*gateway.NewRouteConfig("/api/products/*", "products")))
+
+		engine.Use(func(c *gin.Context) {
+			c.Request.Header.Set("X-Trace", "first")
+		}, func(c *gin.Context) {
+			c.Request.Header.Set("X-Trace", c.Request.Header.Get("X-Trace")+",second")
+		})
+		engine.Use(func(c *gin.Context) {
+			if c.Request.Header.Get("X-Block") != "" {
+				c.AbortWithStatus(http.StatusForbidden)
+			}
+		})
+		handler := engine.Handler()
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/products/1", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "/api/products/1", w.Header().Get("X-Upstream-Path"))
+		assert.Equal(t, "first,second", w.Header().Get("X-Seen-Trace"))
+
+		req := httptest.NewRequest(http.MethodGet, "/api/products/1", nil)
+		req.Header.Set("X-Block", "1")
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/orders/1", nil))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		assert.Panics(t, func() { engine.Use(func(c *gin.Context) {}) })
+	})
+
+	t.Run("The engine mounts on an existing router under its prefix", func(t *testing.T) {
+		router := gin.New()
+		router.GET("/status", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+
+		engine := gateway.New(gateway.WithRouter(router), gateway.WithPrefix("edge"))
+		require.NoError(t, engine.AddService(*gateway.NewServiceConfig("products", upstream.URL, time.Second)))
+		route := gateway.NewRouteConfig("/edge/products/*", "products")
+		route.StripPrefix = true
+		require.NoError(t, engine.AddRoute(*route))
+		handler := engine.Handler()
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/edge/products/1", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "/1", w.Header().Get("X-Upstream-Path"))
+
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/status", nil))
+		assert.Equal(t, "ok", w.Body.String())
+	})
+}
@@ -0,0 +1,136 @@
+package integration
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gateway/internal/config"
+	"gateway/internal/geoip"
+	"gateway/internal/middleware"
+	"gateway/internal/models"
+	"gateway/internal/proxy"
+	"gateway/pkg/gateway"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countries locates clients by address, as a database would
+type countries map[string]string
+
+func (c countries) Country(ip net.IP) (string, bool) {
+	country, ok := c[ip.String()]
+	return country, ok
+}
+
+func TestGeoIPAccess(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	locator := countries{"198.51.100.1": "DE", "198.51.100.2": "FR", "198.51.100.3": "US", "198.51.100.4": "RU"}
+	counts := geoip.NewCounts()
+
+	engine := gateway.New()
+	require.NoError(t, engine.AddService(*gateway.NewServiceConfig("payments", upstream.URL, time.Second)))
+	require.NoError(t, engine.AddService(*gateway.NewServiceConfig("catalog", upstream.URL, time.Second)))
+	payments := gateway.NewRouteConfig("/api/payments/*", "payments")
+	payments.GeoAccess = &models.GeoAccessConfig{Allow: []string{"DE", "fr"}}
+	require.NoError(t, engine.AddRoute(*payments))
+	catalog := gateway.NewRouteConfig("/api/catalog/*", "catalog")
+	catalog.GeoAccess = &models.GeoAccessConfig{Deny: []string{"RU"}}
+	require.NoError(t, engine.AddRoute(*catalog))
+	checkout := gateway.NewRouteConfig("/api/checkout/*", "payments")
+	checkout.GeoAccess = &models.GeoAccessConfig{Allow: []string{"DE"}, AllowUnknown: true}
+	require.NoError(t, engine.AddRoute(*checkout))
+	var seen, country string
+	engine.Use(func(c *gin.Context) {
+		c.Next()
+		seen = gateway.TerminationReason(c)
+		country = c.GetString(geoip.CountryKey)
+	})
+	engine.Use(middleware.GeoIP(locator, counts, engine.Registry()))
+	handler := engine.Handler()
+
+	send := func(path, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = remoteAddr
+		seen = ""
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Allowed countries reach the route", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, send("/api/payments/charge", "198.51.100.1:41000").Code)
+		assert.Equal(t, "DE", country)
+		assert.Equal(t, http.StatusOK, send("/api/payments/charge", "198.51.100.2:41000").Code)
+
+		w := send("/api/payments/charge", "198.51.100.3:41000")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), "country is not allowed")
+		assert.Equal(t, proxy.TerminationGeoDenied, seen)
+	})
+
+	t.Run("Denied countries are kept out", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, send("/api/catalog/items", "198.51.100.3:41000").Code)
+		assert.Equal(t, http.StatusForbidden, send("/api/catalog/items", "198.51.100.4:41000").Code)
+		assert.Equal(t, proxy.TerminationGeoDenied, seen)
+	})
+
+	t.Run("Unknown countries only pass deny lists", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, send("/api/payments/charge", "10.0.0.5:41000").Code)
+		assert.Equal(t, "", country)
+		assert.Equal(t, http.StatusOK, send("/api/catalog/items", "10.0.0.5:41000").Code)
+		assert.Equal(t, http.StatusOK, send("/api/checkout/cart", "10.0.0.5:41000").Code)
+	})
+
+	t.Run("Requests are counted by country", func(t *testing.T) {
+		byCountry := counts.Stats()["by_country"].(map[string]int64)
+		assert.Equal(t, int64(1), byCountry["DE"])
+		assert.Equal(t, int64(2), byCountry["US"])
+		assert.Equal(t, int64(3), byCountry[geoip.Unknown])
+
+		results := make(map[string]int64)
+		for _, count := range counts.Counts() {
+			if count.Country == "US" {
+				results[count.Result] = count.Count
+			}
+		}
+		assert.Equal(t, map[string]int64{"success": 1, "client_error": 1}, results)
+	})
+
+	t.Run("Files that are not databases are refused", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "GeoLite2-Country.mmdb")
+		require.NoError(t, os.WriteFile(path, []byte("not a database"), 0o600))
+		_, err := geoip.Open(path)
+		assert.Error(t, err)
+	})
+
+	t.Run("Invalid settings are rejected", func(t *testing.T) {
+		geoIP := "geoip:\n  enabled: true\n  database: GeoLite2-Country.mmdb\n"
+		for name, content := range map[string]string{
+			"geoip database is required":             reloadBaseConfig + "    geo_access:\n      allow: [\"DE\"]\ngeoip:\n  enabled: true\n",
+			"route 0 geo_access needs geoip":         reloadBaseConfig + "    geo_access:\n      allow: [\"DE\"]\n",
+			"route 0 geo_access allow entry \"DEU\"": reloadBaseConfig + "    geo_access:\n      allow: [\"DEU\"]\n" + geoIP,
+			"route 0 geo_access needs allow or deny": reloadBaseConfig + "    geo_access:\n      allow_unknown: true\n" + geoIP,
+		} {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+			manager := config.NewManager()
+			require.NoError(t, manager.LoadConfig(path))
+			err := manager.ValidateConfig()
+			require.Error(t, err, name)
+			assert.Contains(t, err.Error(), name)
+		}
+	})
+}
@@ -0,0 +1,91 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gateway/internal/handlers"
+	"gateway/internal/models"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManualHealthCheck(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var status atomic.Int32
+	status.Store(http.StatusServiceUnavailable)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(status.Load()))
+		w.Write([]byte(`{"status": "starting"}`))
+	}))
+	defer upstream.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	serviceRegistry := registry.NewServiceRegistry()
+	serviceRegistry.RegisterService(*models.NewServiceConfig("orders", upstream.URL, time.Second))
+	ready := models.NewServiceConfig("ready", upstream.URL, time.Second)
+	ready.HealthExpectedBody = `"status": "ok"`
+	serviceRegistry.RegisterService(*ready)
+	serviceRegistry.RegisterService(*models.NewServiceConfig("ledger", closed.URL, time.Second))
+
+	router := gin.New()
+	handlers.NewHealthCheckHandler(serviceRegistry).Register(router.Group("/gateway/services"))
+	check := func(name string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/gateway/services/"+name+"/healthcheck", nil))
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return w.Code, body
+	}
+
+	t.Run("A failing probe is reported with its cause", func(t *testing.T) {
+		code, body := check("orders")
+		require.Equal(t, http.StatusOK, code)
+		probe := body["probe"].(map[string]interface{})
+		assert.Equal(t, false, probe["passed"])
+		assert.Equal(t, float64(http.StatusServiceUnavailable), probe["status_code"])
+		assert.Equal(t, "unexpected status 503", probe["error"])
+		assert.Equal(t, upstream.URL+"/health", probe["url"])
+		assert.Equal(t, string(models.ServiceUnhealthy), body["status"])
+	})
+
+	t.Run("A fix is confirmed right away", func(t *testing.T) {
+		status.Store(http.StatusOK)
+		code, body := check("orders")
+		require.Equal(t, http.StatusOK, code)
+		probe := body["probe"].(map[string]interface{})
+		assert.Equal(t, true, probe["passed"])
+		assert.NotContains(t, probe, "error")
+		assert.GreaterOrEqual(t, probe["response_time"], float64(0))
+		assert.Equal(t, string(models.ServiceUnhealthy), body["previous_status"])
+		assert.Equal(t, string(models.ServiceHealthy), body["status"])
+
+		service, _ := serviceRegistry.GetService("orders")
+		assert.Equal(t, models.ServiceHealthy, service.Status)
+	})
+
+	t.Run("Body mismatches and connection errors are explained", func(t *testing.T) {
+		_, body := check("ready")
+		assert.Equal(t, `response does not contain "\"status\": \"ok\""`, body["probe"].(map[string]interface{})["error"])
+
+		_, body = check("ledger")
+		probe := body["probe"].(map[string]interface{})
+		assert.Equal(t, false, probe["passed"])
+		assert.NotContains(t, probe, "status_code")
+		assert.Contains(t, probe["error"], "connect")
+	})
+
+	t.Run("Unknown services are not found", func(t *testing.T) {
+		code, _ := check("billing")
+		assert.Equal(t, http.StatusNotFound, code)
+	})
+}
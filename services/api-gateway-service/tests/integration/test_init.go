@@ -0,0 +1,77 @@
+package integration
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"gateway/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGatewayInit(t *testing.T) {
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "gateway")
+	build := exec.Command("go", "build", "-o", binary, "gateway/cmd/gateway")
+	output, err := build.CombinedOutput()
+	require.NoError(t, err, string(output))
+
+	initConfig := func(args ...string) (string, error) {
+		cmd := exec.Command(binary, append([]string{"init"}, args...)...)
+		cmd.Dir = dir
+		output, err := cmd.CombinedOutput()
+		return string(output), err
+	}
+	validate := func(t *testing.T, path string) *config.Manager {
+		manager := config.NewManager()
+		require.NoError(t, manager.LoadConfig(path))
+		require.NoError(t, manager.ValidateConfig())
+		return manager
+	}
+
+	path := filepath.Join(dir, "config", "config.yaml")
+
+	t.Run("The generated config is valid", func(t *testing.T) {
+		output, err := initConfig()
+		require.NoError(t, err, output)
+		assert.Contains(t, output, "Wrote config/config.yaml")
+
+		cfg := validate(t, path).GetConfig()
+		for _, name := range []string{"auth", "products", "cart", "orders"} {
+			service, exists := cfg.Services[name]
+			if assert.True(t, exists, name) {
+				assert.NotContains(t, service.URL, "localhost")
+			}
+		}
+		assert.NotEmpty(t, cfg.Routes)
+	})
+
+	t.Run("An existing file is not overwritten", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(path, []byte("# hand-edited\n"), 0o644))
+
+		output, err := initConfig()
+		assert.Error(t, err)
+		assert.Contains(t, output, "already exists; use -force to overwrite it")
+		content, _ := os.ReadFile(path)
+		assert.Equal(t, "# hand-edited\n", string(content))
+	})
+
+	t.Run("-force overwrites it", func(t *testing.T) {
+		output, err := initConfig("-force", "-local", "-port", "9000")
+		require.NoError(t, err, output)
+
+		cfg := validate(t, path).GetConfig()
+		assert.Equal(t, 9000, cfg.Server.Port)
+		assert.Equal(t, "http://localhost:8008", cfg.Services["orders"].URL)
+	})
+
+	t.Run("Another path can be chosen", func(t *testing.T) {
+		other := filepath.Join(dir, "envs", "staging.yaml")
+		output, err := initConfig("-o", other)
+		require.NoError(t, err, output)
+		validate(t, other)
+	})
+}
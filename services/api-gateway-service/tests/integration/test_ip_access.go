@@ -0,0 +1,120 @@
+package integration
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gateway/internal/config"
+	"gateway/internal/ipaccess"
+	"gateway/internal/middleware"
+	"gateway/internal/models"
+	"gateway/internal/proxy"
+	"gateway/pkg/gateway"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIPAccessLists(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	policies, err := ipaccess.NewPolicies(models.IPAccessConfig{Deny: []string{"203.0.113.0/24"}})
+	require.NoError(t, err)
+
+	router := gin.New()
+	require.NoError(t, router.SetTrustedProxies([]string{"10.0.0.1"}))
+	engine := gateway.New(gateway.WithRouter(router))
+	require.NoError(t, engine.AddService(*gateway.NewServiceConfig("backoffice", upstream.URL, time.Second)))
+	require.NoError(t, engine.AddService(*gateway.NewServiceConfig("orders", upstream.URL, time.Second)))
+	admin := gateway.NewRouteConfig("/api/admin/*", "backoffice")
+	admin.IPAccess = &models.IPAccessConfig{Allow: []string{"192.168.10.0/24", "2001:db8::/32"}}
+	require.NoError(t, engine.AddRoute(*admin))
+	require.NoError(t, engine.AddRoute(*gateway.NewRouteConfig("/api/orders/*", "orders")))
+	var seen string
+	engine.Use(func(c *gin.Context) {
+		c.Next()
+		seen = gateway.TerminationReason(c)
+	})
+	engine.Use(middleware.IPAccess(policies, engine.Registry()))
+	handler := engine.Handler()
+
+	send := func(path, remoteAddr, forwardedFor string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = remoteAddr
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		seen = ""
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Route allow lists keep other clients out", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, send("/api/admin/users", "192.168.10.4:41000", "").Code)
+		assert.Equal(t, http.StatusOK, send("/api/admin/users", "[2001:db8::7]:41000", "").Code)
+
+		w := send("/api/admin/users", "198.51.100.9:41000", "")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), "not allowed")
+		assert.Equal(t, proxy.TerminationIPDenied, seen)
+
+		assert.Equal(t, http.StatusOK, send("/api/orders/1", "198.51.100.9:41000", "").Code)
+	})
+
+	t.Run("The gateway deny list applies to every route", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, send("/api/orders/1", "203.0.113.5:41000", "").Code)
+		assert.Equal(t, proxy.TerminationIPDenied, seen)
+	})
+
+	t.Run("Forwarded addresses count only from trusted proxies", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, send("/api/admin/users", "10.0.0.1:41000", "192.168.10.4").Code)
+		assert.Equal(t, http.StatusForbidden, send("/api/orders/1", "10.0.0.1:41000", "203.0.113.5").Code)
+
+		assert.Equal(t, http.StatusForbidden, send("/api/admin/users", "198.51.100.9:41000", "192.168.10.4").Code)
+		assert.Equal(t, http.StatusOK, send("/api/orders/1", "198.51.100.9:41000", "203.0.113.5").Code)
+	})
+
+	t.Run("Changed route lists replace the old ones", func(t *testing.T) {
+		route := func(allow ...string) *models.RouteConfig {
+			route := models.NewRouteConfig("/api/reports/*", "backoffice")
+			route.IPAccess = &models.IPAccessConfig{Allow: allow}
+			return route
+		}
+
+		_, ok := policies.Check("192.168.10.4", route("192.168.10.0/24"))
+		assert.True(t, ok)
+		_, ok = policies.Check("192.168.10.4", route("172.16.0.0/12"))
+		assert.False(t, ok, "the route's new allow list applies")
+		_, ok = policies.Check("172.16.3.1", route("172.16.0.0/12"))
+		assert.True(t, ok)
+		_, ok = policies.Check("172.16.3.1", route("192.168.10.0/24"))
+		assert.False(t, ok, "and so does a reverted one")
+	})
+
+	t.Run("Invalid lists are rejected", func(t *testing.T) {
+		for name, content := range map[string]string{
+			"ip_access deny":          reloadBaseConfig + "ip_access:\n  deny: [\"10.1.0.0/33\"]\n",
+			"route 0 ip_access allow": reloadBaseConfig + "    ip_access:\n      allow: [\"office\"]\n",
+			"server trusted_proxies":  reloadBaseConfig + "server:\n  trusted_proxies: [\"10.0.0.300\"]\n",
+		} {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+			manager := config.NewManager()
+			require.NoError(t, manager.LoadConfig(path))
+			err := manager.ValidateConfig()
+			require.Error(t, err, name)
+			assert.Contains(t, err.Error(), name)
+		}
+	})
+}
@@ -0,0 +1,115 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gateway/internal/handlers"
+	"gateway/internal/middleware"
+	"gateway/internal/models"
+	"gateway/internal/registry"
+	"gateway/internal/traffic"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouteLatencies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("Percentiles come from each route's distribution", func(t *testing.T) {
+		latencies := traffic.NewLatencies(time.Minute, 6)
+		for i := 1; i <= 100; i++ {
+			latencies.Record("/api/orders/*", "orders", time.Duration(i)*time.Millisecond)
+		}
+		latencies.Record("/api/products/*", "products", 2*time.Millisecond)
+
+		routes := latencies.Slowest(0)
+		require.Len(t, routes, 2)
+		orders := routes[0]
+		assert.Equal(t, "/api/orders/*", orders.Route)
+		assert.Equal(t, "orders", orders.Service)
+		assert.Equal(t, int64(100), orders.Requests)
+		assert.Equal(t, 50.5, orders.Mean)
+		assert.Equal(t, 100.0, orders.Max)
+		// Buckets are within 19% of the true percentiles
+		assert.InDelta(t, 50, orders.P50, 50*0.19)
+		assert.InDelta(t, 95, orders.P95, 95*0.19)
+		assert.InDelta(t, 99, orders.P99, 99*0.19)
+		assert.LessOrEqual(t, orders.P99, orders.Max)
+
+		assert.Equal(t, 2.0, routes[1].P99)
+	})
+
+	t.Run("Only the slowest routes are listed", func(t *testing.T) {
+		latencies := traffic.NewLatencies(time.Minute, 6)
+		latencies.Record("/fast", "a", time.Millisecond)
+		latencies.Record("/slow", "b", 300*time.Millisecond)
+		latencies.Record("/medium", "c", 40*time.Millisecond)
+
+		routes := latencies.Slowest(2)
+		require.Len(t, routes, 2)
+		assert.Equal(t, "/slow", routes[0].Route)
+		assert.Equal(t, "/medium", routes[1].Route)
+	})
+
+	t.Run("Requests older than the window are forgotten", func(t *testing.T) {
+		latencies := traffic.NewLatencies(200*time.Millisecond, 2)
+		latencies.Record("/old", "a", time.Millisecond)
+		time.Sleep(300 * time.Millisecond)
+		latencies.Record("/new", "a", time.Millisecond)
+
+		routes := latencies.Slowest(0)
+		require.Len(t, routes, 1)
+		assert.Equal(t, "/new", routes[0].Route)
+	})
+
+	serviceRegistry := registry.NewServiceRegistry()
+	serviceRegistry.RegisterService(*models.NewServiceConfig("reports", "http://localhost:1", time.Second))
+	serviceRegistry.RegisterRoute(*models.NewRouteConfig("/api/reports/*", "reports"))
+
+	latencies := traffic.NewLatencies(time.Minute, 6)
+	router := gin.New()
+	handlers.NewLatencyHandler(latencies).Register(router.Group("/gateway/metrics/latency"))
+	proxied := router.Group("/api", middleware.TrackLatency(latencies, serviceRegistry))
+	proxied.GET("/*path", func(c *gin.Context) {
+		time.Sleep(20 * time.Millisecond)
+		c.Status(http.StatusOK)
+	})
+
+	request := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	t.Run("Requests are recorded by route", func(t *testing.T) {
+		request("/api/reports/daily")
+		request("/api/reports/weekly")
+		request("/api/unrouted")
+
+		w := request("/gateway/metrics/latency?limit=5")
+		require.Equal(t, http.StatusOK, w.Code)
+		var body struct {
+			Window string                 `json:"window"`
+			Routes []traffic.RouteLatency `json:"routes"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, "1m0s", body.Window)
+		require.Len(t, body.Routes, 1)
+		assert.Equal(t, "/api/reports/*", body.Routes[0].Route)
+		assert.Equal(t, "reports", body.Routes[0].Service)
+		assert.Equal(t, int64(2), body.Routes[0].Requests)
+		assert.GreaterOrEqual(t, body.Routes[0].P50, 20.0)
+	})
+
+	t.Run("Bad limits are rejected", func(t *testing.T) {
+		for _, limit := range []string{"0", "-1", "many"} {
+			assert.Equal(t, http.StatusBadRequest, request("/gateway/metrics/latency?limit="+limit).Code)
+		}
+	})
+}
@@ -0,0 +1,156 @@
+package integration
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gateway/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLayeredConfig(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		return path
+	}
+
+	path := write("config.yaml", `
+include:
+  - "services/*.yaml"
+  - "routes.yaml"
+server:
+  port: 9000
+rate_limit:
+  requests: 100
+routes:
+  - path: "/api/health"
+    service_name: "orders"
+`)
+	write("services/orders.yaml", `
+services:
+  orders:
+    name: "orders"
+    url: "http://orders:8008"
+    timeout: "5s"
+`)
+	write("services/payments.yaml", `
+services:
+  payments:
+    name: "payments"
+    url: "http://payments:8009"
+    timeout: "5s"
+`)
+	write("routes.yaml", `
+routes:
+  - path: "/api/orders/*"
+    service_name: "orders"
+  - path: "/api/payments/*"
+    service_name: "payments"
+`)
+	write("config.staging.yaml", `
+rate_limit:
+  requests: 10
+services:
+  payments:
+    url: "http://payments.staging:8009"
+`)
+
+	t.Run("Included files are merged in order", func(t *testing.T) {
+		manager := config.NewManager()
+		require.NoError(t, manager.LoadConfig(path))
+		require.NoError(t, manager.ValidateConfig())
+
+		cfg := manager.GetConfig()
+		assert.Equal(t, 9000, cfg.Server.Port)
+		assert.Equal(t, 100, cfg.RateLimit.Requests)
+		require.Len(t, cfg.Services, 2)
+		assert.Equal(t, "http://payments:8009", cfg.Services["payments"].URL)
+		assert.Equal(t, 5*time.Second, cfg.Services["orders"].Timeout)
+
+		var paths []string
+		for _, route := range cfg.Routes {
+			paths = append(paths, route.Path)
+		}
+		assert.Equal(t, []string{"/api/health", "/api/orders/*", "/api/payments/*"}, paths)
+		assert.Equal(t, []string{
+			path,
+			filepath.Join(dir, "services/orders.yaml"),
+			filepath.Join(dir, "services/payments.yaml"),
+			filepath.Join(dir, "routes.yaml"),
+		}, manager.ConfigFiles())
+	})
+
+	t.Run("The environment overlay overrides the base", func(t *testing.T) {
+		t.Setenv(config.EnvironmentVar, "staging")
+		manager := config.NewManager()
+		require.NoError(t, manager.LoadConfig(path))
+
+		cfg := manager.GetConfig()
+		assert.Equal(t, 10, cfg.RateLimit.Requests)
+		assert.Equal(t, 9000, cfg.Server.Port)
+		assert.Equal(t, "http://payments.staging:8009", cfg.Services["payments"].URL)
+		assert.Equal(t, "payments", cfg.Services["payments"].Name)
+		assert.Len(t, cfg.Routes, 3)
+		assert.Contains(t, manager.ConfigFiles(), filepath.Join(dir, "config.staging.yaml"))
+	})
+
+	t.Run("An environment without an overlay uses the base", func(t *testing.T) {
+		t.Setenv(config.EnvironmentVar, "production")
+		manager := config.NewManager()
+		require.NoError(t, manager.LoadConfig(path))
+		assert.Equal(t, 100, manager.GetConfig().RateLimit.Requests)
+	})
+
+	t.Run("Changes to included files are watched", func(t *testing.T) {
+		manager := config.NewManager()
+		require.NoError(t, manager.LoadConfig(path))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		reloads := make(chan struct{}, 4)
+		go config.WatchFiles(ctx, manager.ConfigFiles, 50*time.Millisecond, func() { reloads <- struct{}{} })
+		time.Sleep(100 * time.Millisecond)
+
+		write("services/orders.yaml", `
+services:
+  orders:
+    name: "orders"
+    url: "http://orders:8010"
+    timeout: "5s"
+`)
+		select {
+		case <-reloads:
+		case <-time.After(3 * time.Second):
+			t.Fatal("change to an included file was not noticed")
+		}
+	})
+
+	t.Run("Included files may not disagree", func(t *testing.T) {
+		conflict := write("conflict/config.yaml", `
+include: ["a.yaml", "b.yaml"]
+`)
+		write("conflict/a.yaml", "rate_limit:\n  requests: 10\n")
+		write("conflict/b.yaml", "rate_limit:\n  requests: 20\n")
+		err := config.NewManager().LoadConfig(conflict)
+		assert.ErrorContains(t, err, "rate_limit.requests is set to different values")
+	})
+
+	t.Run("Include cycles and missing files are rejected", func(t *testing.T) {
+		cycle := write("cycle/config.yaml", `include: "other.yaml"`)
+		write("cycle/other.yaml", `include: "config.yaml"`)
+		err := config.NewManager().LoadConfig(cycle)
+		assert.ErrorContains(t, err, "included more than once")
+
+		missing := write("missing/config.yaml", `include: "routes/*.yaml"`)
+		err = config.NewManager().LoadConfig(missing)
+		assert.ErrorContains(t, err, "matches no files")
+	})
+}
@@ -0,0 +1,64 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gateway/internal/i18n"
+	"gateway/internal/proxy"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalizedErrorMessages(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	catalog, err := i18n.LoadDir("../../config/locales")
+	assert.NoError(t, err)
+	localizer := i18n.NewLocalizer(catalog, "en")
+
+	router := gin.New()
+	router.Use(i18n.Middleware(localizer))
+	router.Any("/api/*proxyPath", proxy.New(registry.NewServiceRegistry()).Handle)
+
+	tests := []struct {
+		acceptLanguage string
+		locale         string
+		message        string
+	}{
+		{"", "en", "No route found for GET /api/unknown"},
+		{"es-MX,es;q=0.9,en;q=0.8", "es", "No se encontró ninguna ruta para GET /api/unknown"},
+		{"de-DE, fr;q=0.7", "fr", "Aucune route trouvée pour GET /api/unknown"},
+		{"ja", "en", "No route found for GET /api/unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.acceptLanguage, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/api/unknown", nil)
+			if tt.acceptLanguage != "" {
+				req.Header.Set("Accept-Language", tt.acceptLanguage)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusNotFound, w.Code)
+			assert.Equal(t, tt.locale, w.Header().Get("Content-Language"))
+
+			var response map[string]interface{}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, "Route not found", response["error"])
+			assert.Equal(t, tt.message, response["message"])
+		})
+	}
+
+	t.Run("Missing translations fall back to English", func(t *testing.T) {
+		partial := i18n.NewLocalizer(i18n.MapCatalog{"it": {}}, "en")
+		message, locale := partial.Message(partial.Negotiate("it"), i18n.InvalidToken)
+		assert.Equal(t, "Invalid or expired token", message)
+		assert.Equal(t, "en", locale)
+	})
+}
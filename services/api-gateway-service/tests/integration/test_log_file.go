@@ -0,0 +1,95 @@
+package integration
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gateway/internal/logfile"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogFileRotation(t *testing.T) {
+	// Files rotate at 1 MB; sixteen of these lines fill one
+	line := append(bytes.Repeat([]byte("x"), 64<<10-1), '\n')
+	write := func(t *testing.T, w *logfile.Writer, lines int) {
+		for i := 0; i < lines; i++ {
+			n, err := w.Write(line)
+			require.NoError(t, err)
+			require.Equal(t, len(line), n)
+		}
+	}
+	size := func(t *testing.T, path string) int64 {
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		return info.Size()
+	}
+
+	t.Run("A full file is rotated without splitting lines", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "gateway.log")
+		w, err := logfile.Open(path, 1, 3)
+		require.NoError(t, err)
+		defer w.Close()
+
+		write(t, w, 20)
+		assert.Equal(t, int64(16*len(line)), size(t, path+".1"))
+		assert.Equal(t, int64(4*len(line)), size(t, path))
+		assert.NoFileExists(t, path+".2")
+	})
+
+	t.Run("Only max_backups rotated files are kept", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "gateway.log")
+		w, err := logfile.Open(path, 1, 2)
+		require.NoError(t, err)
+		defer w.Close()
+
+		write(t, w, 16*4+1)
+		assert.FileExists(t, path)
+		assert.FileExists(t, path+".1")
+		assert.FileExists(t, path+".2")
+		assert.NoFileExists(t, path+".3")
+	})
+
+	t.Run("Zero max_backups keeps every rotated file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "gateway.log")
+		w, err := logfile.Open(path, 1, 0)
+		require.NoError(t, err)
+		defer w.Close()
+
+		write(t, w, 16*4+1)
+		for _, suffix := range []string{".1", ".2", ".3", ".4"} {
+			assert.FileExists(t, path+suffix)
+		}
+	})
+
+	t.Run("A reopened file counts what it already holds", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "gateway.log")
+		w, err := logfile.Open(path, 1, 1)
+		require.NoError(t, err)
+		write(t, w, 10)
+		require.NoError(t, w.Close())
+
+		w, err = logfile.Open(path, 1, 1)
+		require.NoError(t, err)
+		defer w.Close()
+		write(t, w, 7)
+		assert.Equal(t, int64(16*len(line)), size(t, path+".1"))
+		assert.Equal(t, int64(len(line)), size(t, path))
+	})
+
+	t.Run("Writes after Close fail", func(t *testing.T) {
+		w, err := logfile.Open(filepath.Join(t.TempDir(), "gateway.log"), 1, 1)
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		_, err = w.Write(line)
+		assert.ErrorIs(t, err, os.ErrClosed)
+	})
+
+	t.Run("Unwritable paths fail to open", func(t *testing.T) {
+		_, err := logfile.Open(filepath.Join(t.TempDir(), "missing", "gateway.log"), 1, 1)
+		assert.Error(t, err)
+	})
+}
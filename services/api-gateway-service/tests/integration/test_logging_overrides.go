@@ -0,0 +1,118 @@
+package integration
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gateway/internal/handlers"
+	"gateway/internal/logging"
+	"gateway/internal/middleware"
+	"gateway/internal/models"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoggingOverrides(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	serviceRegistry := registry.NewServiceRegistry()
+	serviceRegistry.RegisterService(*models.NewServiceConfig("orders", "http://orders:8080", time.Second))
+	serviceRegistry.RegisterService(*models.NewServiceConfig("products", "http://products:8080", time.Second))
+	serviceRegistry.RegisterRoute(*models.NewRouteConfig("/api/orders/*", "orders"))
+	serviceRegistry.RegisterRoute(*models.NewRouteConfig("/api/products/*", "products"))
+
+	var output bytes.Buffer
+	overrides := logging.NewOverrides(models.LogLevelError)
+	router := gin.New()
+	router.Use(middleware.AccessLog(models.LoggingConfig{Format: "json", Level: models.LogLevelError}, overrides, &output, func() bool { return false }))
+	router.Use(middleware.DebugRouteLogs(overrides, serviceRegistry))
+	router.GET("/api/*path", func(c *gin.Context) { c.Status(http.StatusOK) })
+	handlers.NewLoggingHandler(overrides, serviceRegistry, time.Hour).Register(router.Group("/gateway/logging"))
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		req.Header.Set("X-Trace", "checkout")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	logged := func(path string) string {
+		output.Reset()
+		send(http.MethodGet, path, "")
+		return output.String()
+	}
+	status := func(w *httptest.ResponseRecorder) logging.Status {
+		var status logging.Status
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+		return status
+	}
+
+	assert.Empty(t, logged("/api/products/1"))
+
+	t.Run("The level is changed without a reload", func(t *testing.T) {
+		w := send(http.MethodPut, "/gateway/logging", `{"level": "info", "duration": "10m"}`)
+		require.Equal(t, http.StatusOK, w.Code)
+		override := status(w)
+		assert.Equal(t, "info", override.Level)
+		assert.Equal(t, "error", override.ConfiguredLevel)
+		require.NotNil(t, override.Until)
+		assert.WithinDuration(t, time.Now().Add(10*time.Minute), *override.Until, time.Minute)
+
+		assert.Contains(t, logged("/api/products/1"), `"path":"/api/products/1"`)
+	})
+
+	t.Run("Debug routes log every request with its headers", func(t *testing.T) {
+		w := send(http.MethodPut, "/gateway/logging", `{"debug_routes": ["/api/orders/*"]}`)
+		require.Equal(t, http.StatusOK, w.Code)
+		override := status(w)
+		assert.Equal(t, "error", override.Level)
+		assert.Equal(t, []string{"/api/orders/*"}, override.DebugRoutes)
+		assert.WithinDuration(t, time.Now().Add(logging.DefaultOverrideDuration), *override.Until, time.Minute)
+
+		line := logged("/api/orders/1")
+		assert.Contains(t, line, `{"name":"X-Trace","value":"checkout"}`)
+		assert.NotContains(t, line, "secret")
+		assert.Empty(t, logged("/api/products/1"))
+	})
+
+	t.Run("Overrides revert on their own", func(t *testing.T) {
+		w := send(http.MethodPut, "/gateway/logging", `{"level": "debug", "debug_routes": ["/api/orders/*"], "duration": "30ms"}`)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.NotEmpty(t, logged("/api/products/1"))
+
+		assert.Eventually(t, func() bool { return overrides.Level() == models.LogLevelError }, time.Second, 5*time.Millisecond)
+		override := status(send(http.MethodGet, "/gateway/logging", ""))
+		assert.Empty(t, override.DebugRoutes)
+		assert.Nil(t, override.Until)
+		assert.Empty(t, logged("/api/orders/1"))
+	})
+
+	t.Run("Overrides can be ended early", func(t *testing.T) {
+		send(http.MethodPut, "/gateway/logging", `{"level": "info"}`)
+		w := send(http.MethodDelete, "/gateway/logging", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "error", status(w).Level)
+		assert.Empty(t, logged("/api/products/1"))
+	})
+
+	t.Run("Invalid overrides are refused", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, send(http.MethodPut, "/gateway/logging", `{}`).Code)
+		assert.Equal(t, http.StatusBadRequest, send(http.MethodPut, "/gateway/logging", `{"level": "verbose"}`).Code)
+		assert.Equal(t, http.StatusBadRequest, send(http.MethodPut, "/gateway/logging", `{"level": "info", "duration": "-1m"}`).Code)
+		assert.Equal(t, http.StatusNotFound, send(http.MethodPut, "/gateway/logging", `{"debug_routes": ["/api/billing/*"]}`).Code)
+
+		w := send(http.MethodPut, "/gateway/logging", `{"level": "info", "duration": "48h"}`)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.WithinDuration(t, time.Now().Add(time.Hour), *status(w).Until, time.Minute)
+		send(http.MethodDelete, "/gateway/logging", "")
+	})
+}
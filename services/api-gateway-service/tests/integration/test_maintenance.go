@@ -0,0 +1,144 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gateway/internal/config"
+	"gateway/internal/handlers"
+	"gateway/internal/maintenance"
+	"gateway/internal/middleware"
+	"gateway/internal/models"
+	"gateway/internal/proxy"
+	"gateway/pkg/gateway"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaintenanceMode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	mode, err := maintenance.New(models.MaintenanceConfig{
+		ContentType: "application/json",
+		RetryAfter:  90 * time.Second,
+		BypassIPs:   []string{"10.1.0.0/16", "192.0.2.7"},
+	})
+	require.NoError(t, err)
+
+	router := gin.New()
+	router.GET("/health", func(c *gin.Context) { c.Status(http.StatusOK) })
+	handlers.NewMaintenanceHandler(mode).Register(router.Group("/gateway/maintenance"))
+	engine := gateway.New(gateway.WithRouter(router))
+	require.NoError(t, engine.AddService(*gateway.NewServiceConfig("orders", upstream.URL, time.Second)))
+	require.NoError(t, engine.AddRoute(*gateway.NewRouteConfig("/api/orders/*", "orders")))
+	var seen string
+	engine.Use(func(c *gin.Context) {
+		c.Next()
+		seen = gateway.TerminationReason(c)
+	})
+	engine.Use(middleware.Maintenance(mode))
+	handler := engine.Handler()
+
+	send := func(method, path, clientIP, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if clientIP != "" {
+			req.RemoteAddr = clientIP + ":41000"
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+	var status maintenance.Status
+
+	assert.Equal(t, http.StatusOK, send(http.MethodGet, "/api/orders/1", "", "").Code)
+
+	t.Run("Proxied routes answer 503 during maintenance", func(t *testing.T) {
+		w := send(http.MethodPost, "/gateway/maintenance/enable", "", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+		assert.True(t, status.Enabled)
+		assert.NotNil(t, status.Since)
+
+		seen = ""
+		w = send(http.MethodGet, "/api/orders/1", "", "")
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, "90", w.Header().Get("Retry-After"))
+		assert.Equal(t, proxy.TerminationMaintenance, seen)
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, true, body["maintenance"])
+		assert.Equal(t, "The service is down for maintenance, try again later", body["message"])
+
+		assert.Equal(t, http.StatusOK, send(http.MethodGet, "/health", "", "").Code)
+		assert.Equal(t, http.StatusOK, send(http.MethodGet, "/gateway/maintenance", "", "").Code)
+	})
+
+	t.Run("Bypass addresses are proxied as usual", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, send(http.MethodGet, "/api/orders/1", "10.1.4.2", "").Code)
+		assert.Equal(t, http.StatusOK, send(http.MethodGet, "/api/orders/1", "192.0.2.7", "").Code)
+		assert.Equal(t, http.StatusServiceUnavailable, send(http.MethodGet, "/api/orders/1", "192.0.2.8", "").Code)
+	})
+
+	t.Run("A maintenance window can carry its own message", func(t *testing.T) {
+		w := send(http.MethodPost, "/gateway/maintenance/enable", "", `{"message": "Back at 14:00 UTC", "retry_after": "30m"}`)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		w = send(http.MethodGet, "/api/orders/1", "", "")
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, "1800", w.Header().Get("Retry-After"))
+		assert.Contains(t, w.Body.String(), "Back at 14:00 UTC")
+
+		w = send(http.MethodPost, "/gateway/maintenance/enable", "", `{"retry_after": "soon"}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Disabling maintenance restores traffic", func(t *testing.T) {
+		w := send(http.MethodPost, "/gateway/maintenance/disable", "", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+		assert.False(t, status.Enabled)
+		assert.Equal(t, http.StatusOK, send(http.MethodGet, "/api/orders/1", "", "").Code)
+	})
+
+	t.Run("The configured body is sent as is", func(t *testing.T) {
+		page, err := maintenance.New(models.MaintenanceConfig{
+			Enabled:     true,
+			Body:        "<h1>Down for maintenance</h1>",
+			ContentType: "text/html; charset=utf-8",
+		})
+		require.NoError(t, err)
+		router := gin.New()
+		router.Use(middleware.Maintenance(page))
+		router.GET("/api/orders/1", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/orders/1", nil))
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Equal(t, "<h1>Down for maintenance</h1>", w.Body.String())
+		assert.Empty(t, w.Header().Get("Retry-After"))
+	})
+
+	t.Run("Invalid bypass addresses are rejected", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(reloadBaseConfig+"maintenance:\n  bypass_ips: [\"10.1.0.0/33\"]\n"), 0o600))
+		manager := config.NewManager()
+		require.NoError(t, manager.LoadConfig(path))
+		err := manager.ValidateConfig()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "maintenance bypass_ips")
+	})
+}
@@ -0,0 +1,83 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gateway/internal/handlers"
+	"gateway/internal/middleware"
+	"gateway/internal/migration"
+	"gateway/internal/models"
+	"gateway/internal/proxy"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrationRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer upstream.Close()
+
+	serviceRegistry := registry.NewServiceRegistry()
+	serviceRegistry.RegisterService(*models.NewServiceConfig("checkout", upstream.URL, time.Second))
+	serviceRegistry.RegisterRoute(*models.NewRouteConfig("/api/checkout/*", "checkout"))
+	serviceRegistry.RegisterRoute(models.RouteConfig{Path: "/api/cart/*", Method: "*", MigrateTo: "/api/checkout/*"})
+	serviceRegistry.RegisterRoute(models.RouteConfig{Path: "/api/basket/*", Method: "*", MigrateTo: "/api/checkout/*", MigrationMode: models.MigrationRewrite})
+	assert.NoError(t, serviceRegistry.ValidateConfiguration())
+
+	tracker := migration.NewTracker()
+	router := gin.New()
+	handlers.NewMigrationsHandler(tracker).Register(router.Group("/gateway/migrations"))
+	api := router.Group("/api")
+	api.Use(middleware.Migrations(serviceRegistry, tracker))
+	api.Any("/*proxyPath", proxy.New(serviceRegistry).Handle)
+
+	send := func(path, userAgent string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", path, nil)
+		req.Header.Set("User-Agent", userAgent)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("/api/cart/items?id=7", "storefront/1.2")
+	assert.Equal(t, http.StatusPermanentRedirect, w.Code)
+	assert.Equal(t, "/api/checkout/items?id=7", w.Header().Get("Location"))
+	assert.Equal(t, "true", w.Header().Get("Deprecation"))
+	send("/api/cart/items", "storefront/1.2")
+
+	w = send("/api/basket/items", "ios/3.0")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "/api/checkout/items", w.Body.String())
+
+	w = send("/api/checkout/items", "storefront/2.0")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Deprecation"))
+
+	req, _ := http.NewRequest("GET", "/gateway/migrations", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var response struct {
+		Migrations []migration.RouteTraffic `json:"migrations"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	if assert.Len(t, response.Migrations, 2) {
+		basket, cart := response.Migrations[0], response.Migrations[1]
+		assert.Equal(t, int64(1), basket.Requests)
+		assert.Equal(t, models.MigrationRewrite, basket.Mode)
+		assert.Equal(t, int64(2), cart.Requests)
+		if assert.Len(t, cart.Clients, 1) {
+			assert.Equal(t, "storefront/1.2", cart.Clients[0].UserAgent)
+			assert.Equal(t, int64(2), cart.Clients[0].Requests)
+		}
+	}
+}
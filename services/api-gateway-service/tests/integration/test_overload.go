@@ -0,0 +1,184 @@
+package integration
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gateway/internal/middleware"
+	"gateway/internal/models"
+	"gateway/internal/overload"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func overloadConfig() models.OverloadConfig {
+	return models.OverloadConfig{
+		Enabled:                  true,
+		CheckInterval:            time.Second,
+		CPUThreshold:             0.9,
+		MemoryThreshold:          0.85,
+		CoolDown:                 50 * time.Millisecond,
+		HealthIntervalMultiplier: 4,
+		QuietLogs:                true,
+	}
+}
+
+func TestOverloadMonitor(t *testing.T) {
+	t.Run("Either resource over its threshold is overload", func(t *testing.T) {
+		for name, reading := range map[string][2]float64{
+			"cpu at threshold": {0.9, 0},
+			"cpu over":         {0.99, 0.5},
+			"memory over":      {0.1, 0.9},
+		} {
+			monitor := overload.NewMonitor(overloadConfig())
+			monitor.Observe(reading[0], reading[1])
+			assert.True(t, monitor.Overloaded(), name)
+		}
+
+		monitor := overload.NewMonitor(overloadConfig())
+		monitor.Observe(0.89, 0.84)
+		assert.False(t, monitor.Overloaded())
+		assert.Equal(t, 0.89, monitor.Stats()["cpu_usage"])
+		assert.Equal(t, 0.84, monitor.Stats()["memory_usage"])
+	})
+
+	t.Run("Overload ends only after the cool-down", func(t *testing.T) {
+		monitor := overload.NewMonitor(overloadConfig())
+		var changes []bool
+		monitor.OnChange(func(overloaded bool) { changes = append(changes, overloaded) })
+
+		monitor.Observe(0.95, 0)
+		monitor.Observe(0.5, 0)
+		assert.True(t, monitor.Overloaded(), "one calm reading is not enough")
+
+		time.Sleep(30 * time.Millisecond)
+		monitor.Observe(0.95, 0)
+		time.Sleep(30 * time.Millisecond)
+		monitor.Observe(0.5, 0)
+		assert.True(t, monitor.Overloaded(), "renewed pressure restarts the cool-down")
+
+		time.Sleep(60 * time.Millisecond)
+		monitor.Observe(0.5, 0)
+		assert.False(t, monitor.Overloaded())
+		assert.Equal(t, []bool{true, false}, changes, "listeners hear of each change once")
+	})
+
+	t.Run("Runtime metrics are sampled", func(t *testing.T) {
+		config := overloadConfig()
+		config.MemoryLimit = 1 << 10
+		monitor := overload.NewMonitor(config)
+		monitor.Check()
+		assert.True(t, monitor.Overloaded(), "the heap is far above a 1KiB limit")
+
+		var nilMonitor *overload.Monitor
+		assert.False(t, nilMonitor.Overloaded())
+	})
+}
+
+func TestOverloadShedsBackgroundWork(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var probes int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			atomic.AddInt32(&probes, 1)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	// Wired as main.go does
+	config := overloadConfig()
+	monitor := overload.NewMonitor(config)
+	serviceRegistry := registry.NewServiceRegistry()
+	monitor.OnChange(func(overloaded bool) {
+		if overloaded {
+			serviceRegistry.SetHealthIntervalScale(config.HealthIntervalMultiplier)
+		} else {
+			serviceRegistry.SetHealthIntervalScale(1)
+		}
+	})
+	service := *models.NewServiceConfig("orders", upstream.URL, time.Second)
+	service.HealthInterval = time.Second
+	serviceRegistry.RegisterService(service)
+
+	var outputMutex sync.Mutex
+	var output bytes.Buffer
+	router := gin.New()
+	router.Use(middleware.AccessLog(models.LoggingConfig{Format: "json"}, nil, lockedWriter{&outputMutex, &output}, func() bool {
+		return config.QuietLogs && monitor.Overloaded()
+	}))
+	router.GET("/api/orders", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/api/missing", func(c *gin.Context) { c.Status(http.StatusNotFound) })
+	send := func(path string) int {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code
+	}
+	logged := func() string {
+		outputMutex.Lock()
+		defer outputMutex.Unlock()
+		lines := output.String()
+		output.Reset()
+		return lines
+	}
+
+	monitor.Observe(0.95, 0)
+	serviceRegistry.StartHealthChecking(time.Hour)
+	defer serviceRegistry.StopHealthChecking()
+
+	t.Run("While overloaded requests are served but only failures logged", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, send("/api/orders"))
+		assert.Equal(t, http.StatusNotFound, send("/api/missing"))
+		lines := logged()
+		assert.NotContains(t, lines, "/api/orders")
+		assert.Contains(t, lines, "/api/missing")
+	})
+
+	t.Run("While overloaded health checks are stretched", func(t *testing.T) {
+		time.Sleep(2500 * time.Millisecond)
+		assert.LessOrEqual(t, atomic.LoadInt32(&probes), int32(1))
+	})
+
+	t.Run("Once calm everything resumes", func(t *testing.T) {
+		monitor.Observe(0.1, 0)
+		time.Sleep(60 * time.Millisecond)
+		monitor.Observe(0.1, 0)
+		assert.False(t, monitor.Overloaded())
+
+		assert.Equal(t, http.StatusOK, send("/api/orders"))
+		assert.True(t, strings.Contains(logged(), "/api/orders"))
+
+		before := atomic.LoadInt32(&probes)
+		time.Sleep(4500 * time.Millisecond)
+		assert.GreaterOrEqual(t, atomic.LoadInt32(&probes)-before, int32(2))
+	})
+
+	t.Run("Without quiet_logs every request is still logged", func(t *testing.T) {
+		config.QuietLogs = false
+		monitor.Observe(0.95, 0)
+		assert.True(t, monitor.Overloaded())
+		assert.Equal(t, http.StatusOK, send("/api/orders"))
+		assert.Contains(t, logged(), "/api/orders")
+	})
+}
+
+// lockedWriter serializes writes from request goroutines
+type lockedWriter struct {
+	mutex  *sync.Mutex
+	output *bytes.Buffer
+}
+
+func (w lockedWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.output.Write(p)
+}
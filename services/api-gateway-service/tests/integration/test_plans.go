@@ -0,0 +1,124 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gateway/internal/auth"
+	"gateway/internal/middleware"
+	"gateway/internal/models"
+	"gateway/internal/plans"
+	"gateway/internal/quota"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsagePlans(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("ACME_API_KEY", "acme-key")
+
+	config := models.PlansConfig{
+		Enabled: true,
+		Claim:   "plan",
+		Default: "free",
+		Tiers: map[string]models.PlanConfig{
+			"free":     {RateLimit: &models.PlanRateLimit{Requests: 2, Window: time.Minute, Burst: 2}},
+			"pro":      {Daily: 3},
+			"internal": {},
+		},
+		APIKeys: []models.PlanAPIKey{{Name: "acme", KeyRef: "env:ACME_API_KEY", Plan: "pro"}},
+	}
+	usagePlans, err := plans.New(config, models.QuotaConfig{Header: "X-API-Key"}, quota.NewMemoryStore())
+	require.NoError(t, err)
+
+	router := gin.New()
+	// Stands in for authentication
+	router.Use(func(c *gin.Context) {
+		if user := c.GetHeader("X-Test-User"); user != "" {
+			c.Set(middleware.IdentityKey, &auth.Identity{
+				UserID: user,
+				Claims: map[string]string{"plan": c.GetHeader("X-Test-Plan")},
+			})
+		}
+	})
+	router.Use(middleware.Plans(usagePlans))
+	router.GET("/api/products", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"products": []string{}})
+	})
+
+	send := func(headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/products", nil)
+		req.RemoteAddr = "10.0.0.1:40000"
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Anonymous clients are on the default plan", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			w := send(nil)
+			require.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "free", w.Header().Get(middleware.PlanHeader))
+			assert.Equal(t, "2", w.Header().Get("X-RateLimit-Limit"))
+		}
+		assert.Equal(t, http.StatusTooManyRequests, send(nil).Code)
+	})
+
+	t.Run("Unlisted API keys do not get fresh limits", func(t *testing.T) {
+		w := send(map[string]string{"X-API-Key": "made-up"})
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, "free", w.Header().Get(middleware.PlanHeader))
+	})
+
+	t.Run("Users are on the plan of their claim", func(t *testing.T) {
+		for i := 0; i < 5; i++ {
+			w := send(map[string]string{"X-Test-User": "u1", "X-Test-Plan": "internal"})
+			require.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "internal", w.Header().Get(middleware.PlanHeader))
+			assert.Empty(t, w.Header().Get("X-RateLimit-Limit"))
+			assert.Empty(t, w.Header().Get("X-Quota-Limit"))
+		}
+
+		w := send(map[string]string{"X-Test-User": "u2", "X-Test-Plan": "enterprise"})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "free", w.Header().Get(middleware.PlanHeader))
+	})
+
+	t.Run("Listed API keys use their plan's quota", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			w := send(map[string]string{"X-API-Key": "acme-key"})
+			require.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "pro", w.Header().Get(middleware.PlanHeader))
+		}
+		w := send(map[string]string{"X-API-Key": "acme-key"})
+		require.Equal(t, http.StatusTooManyRequests, w.Code)
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, "quota_exhausted", body["reason"])
+
+		// A pro user has a quota of their own
+		w = send(map[string]string{"X-Test-User": "u3", "X-Test-Plan": "pro"})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "2", w.Header().Get("X-Quota-Remaining"))
+	})
+
+	stats := usagePlans.Stats()
+	assert.Equal(t, int64(5), stats["internal"].(map[string]interface{})["requests"])
+}
+
+func TestUsagePlanAPIKeysMustResolve(t *testing.T) {
+	config := models.PlansConfig{
+		Tiers:   map[string]models.PlanConfig{"pro": {}},
+		APIKeys: []models.PlanAPIKey{{Name: "acme", KeyRef: "env:UNSET_PLAN_API_KEY", Plan: "pro"}},
+	}
+	_, err := plans.New(config, models.QuotaConfig{Header: "X-API-Key"}, quota.NewMemoryStore())
+	assert.ErrorContains(t, err, "api key acme")
+}
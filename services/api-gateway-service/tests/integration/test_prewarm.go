@@ -0,0 +1,98 @@
+package integration
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gateway/internal/models"
+	"gateway/internal/proxy"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectionPrewarming(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// Proxied requests wait for each other, so each needs a connection of
+	// its own
+	var connections, probes int32
+	arrived := make(chan struct{}, 3)
+	release := make(chan struct{})
+	upstream := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ready" {
+			atomic.AddInt32(&probes, 1)
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		arrived <- struct{}{}
+		select {
+		case <-release:
+		case <-time.After(time.Second):
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	upstream.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&connections, 1)
+		}
+	}
+	upstream.Start()
+	defer upstream.Close()
+
+	serviceRegistry := registry.NewServiceRegistry()
+	warm := *models.NewServiceConfig("inventory", upstream.URL, time.Second)
+	warm.HealthPath = "/ready"
+	warm.PrewarmConnections = 3
+	serviceRegistry.RegisterService(warm)
+	serviceRegistry.RegisterRoute(*models.NewRouteConfig("/api/inventory/*", "inventory"))
+	cold := *models.NewServiceConfig("reports", upstream.URL, time.Second)
+	serviceRegistry.RegisterService(cold)
+
+	proxyHandler := proxy.New(serviceRegistry)
+	router := gin.New()
+	router.Any("/api/*path", proxyHandler.Handle)
+
+	t.Run("Idle connections are opened up front", func(t *testing.T) {
+		assert.Equal(t, 3, proxyHandler.Prewarm(context.Background()))
+		assert.Equal(t, int32(3), atomic.LoadInt32(&probes))
+		assert.Equal(t, int32(3), atomic.LoadInt32(&connections), "one connection per probe, none for services without prewarm_connections")
+	})
+
+	t.Run("Requests reuse the warmed connections", func(t *testing.T) {
+		var wg sync.WaitGroup
+		statuses := make(chan int, 3)
+		for i := 0; i < 3; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/inventory/items", nil))
+				statuses <- w.Code
+			}()
+		}
+		for i := 0; i < 3; i++ {
+			select {
+			case <-arrived:
+			case <-time.After(time.Second):
+				require.Fail(t, "proxied requests did not reach the upstream")
+			}
+		}
+		close(release)
+		wg.Wait()
+		close(statuses)
+
+		for status := range statuses {
+			assert.Equal(t, http.StatusOK, status)
+		}
+		assert.Equal(t, int32(3), atomic.LoadInt32(&connections), "no new connections were dialed")
+	})
+}
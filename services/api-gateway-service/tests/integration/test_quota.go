@@ -0,0 +1,121 @@
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"gateway/internal/middleware"
+	"gateway/internal/models"
+	"gateway/internal/quota"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type failingQuotaStore struct{}
+
+func (failingQuotaStore) Increment(ctx context.Context, key string, delta int64, expiresAt time.Time) (int64, error) {
+	return 0, errors.New("connection refused")
+}
+
+func quotaRouter(quotas *quota.Quotas) *gin.Engine {
+	router := gin.New()
+	router.Use(middleware.Quota(quotas))
+	router.GET("/api/products", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"products": []string{}})
+	})
+	return router
+}
+
+func sendWithKey(router *gin.Engine, apiKey string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/api/products", nil)
+	if apiKey != "" {
+		req.Header.Set("X-API-Key", apiKey)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestAPIKeyQuotas(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store := quota.NewMemoryStore()
+	config := models.QuotaConfig{Enabled: true, Header: "X-API-Key", Daily: 3, Monthly: 4}
+	quotas := quota.New(config, store)
+	router := quotaRouter(quotas)
+
+	t.Run("Responses report the binding allowance", func(t *testing.T) {
+		for remaining := 2; remaining >= 0; remaining-- {
+			w := sendWithKey(router, "key-a")
+			require.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "daily", w.Header().Get("X-Quota-Period"))
+			assert.Equal(t, "3", w.Header().Get("X-Quota-Limit"))
+			assert.Equal(t, strconv.Itoa(remaining), w.Header().Get("X-Quota-Remaining"))
+
+			reset, err := strconv.Atoi(w.Header().Get("X-Quota-Reset"))
+			require.NoError(t, err)
+			assert.LessOrEqual(t, reset, 24*60*60)
+			assert.Positive(t, reset)
+		}
+	})
+
+	t.Run("An exhausted quota is rejected until it renews", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			w := sendWithKey(router, "key-a")
+			require.Equal(t, http.StatusTooManyRequests, w.Code)
+			assert.Equal(t, "0", w.Header().Get("X-Quota-Remaining"))
+			assert.Equal(t, w.Header().Get("X-Quota-Reset"), w.Header().Get("Retry-After"))
+
+			var body map[string]interface{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Equal(t, "quota_exhausted", body["reason"])
+			assert.Equal(t, "daily", body["period"])
+			assert.Contains(t, body["message"], "3 requests")
+		}
+	})
+
+	t.Run("Rejected requests do not use up other periods", func(t *testing.T) {
+		monthly := quota.New(models.QuotaConfig{Header: "X-API-Key", Monthly: 4}, store)
+		w := sendWithKey(quotaRouter(monthly), "key-a")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "monthly", w.Header().Get("X-Quota-Period"))
+		assert.Equal(t, "0", w.Header().Get("X-Quota-Remaining"))
+	})
+
+	t.Run("Keys have their own allowances", func(t *testing.T) {
+		w := sendWithKey(router, "key-b")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "2", w.Header().Get("X-Quota-Remaining"))
+	})
+
+	t.Run("Requests without a key are not counted", func(t *testing.T) {
+		w := sendWithKey(router, "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("X-Quota-Limit"))
+	})
+
+	stats := quotas.Stats()
+	assert.Equal(t, int64(4), stats["allowed"])
+	assert.Equal(t, int64(3), stats["exhausted"])
+}
+
+func TestQuotaStoreOutageFailsOpen(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	quotas := quota.New(models.QuotaConfig{Header: "X-API-Key", Daily: 1}, failingQuotaStore{})
+	router := quotaRouter(quotas)
+	for i := 0; i < 3; i++ {
+		w := sendWithKey(router, "key-a")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("X-Quota-Limit"))
+	}
+	assert.Equal(t, int64(3), quotas.Stats()["store_errors"])
+}
@@ -7,6 +7,11 @@ import (
 	"testing"
 	"time"
 
+	"gateway/internal/auth"
+	"gateway/internal/middleware"
+	"gateway/internal/models"
+	"gateway/internal/ratelimit"
+
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 )
@@ -105,36 +110,148 @@ func TestRateLimitHeaders(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
 
-	// Mock rate limiting middleware
-	router.Use(func(c *gin.Context) {
-		// TODO: Implement actual rate limiting with proper headers
-		c.JSON(http.StatusNotImplemented, gin.H{"error": "rate limiting not implemented"})
-		c.Abort()
-	})
+	policy := *models.NewRateLimitPolicy("test", 2, time.Second, 3)
+	router.Use(middleware.RateLimit(policy, ratelimit.NewLimiter(policy.GetRate(), policy.Burst), nil))
 
 	router.GET("/test", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"message": "success"})
 	})
 
-	t.Run("Rate limit headers should be present", func(t *testing.T) {
+	send := func() *httptest.ResponseRecorder {
 		req, err := http.NewRequest("GET", "/test", nil)
 		assert.NoError(t, err)
+		req.RemoteAddr = "10.0.0.1:40000"
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Rate limit headers should be present", func(t *testing.T) {
+		for i := 2; i >= 0; i-- {
+			w := send()
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "3", w.Header().Get("X-RateLimit-Limit"))
+			assert.Equal(t, strconv.Itoa(i), w.Header().Get("X-RateLimit-Remaining"))
+			assert.NotEmpty(t, w.Header().Get("X-RateLimit-Reset"))
+		}
+	})
+
+	t.Run("Rejections carry Retry-After", func(t *testing.T) {
+		w := send()
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, "3", w.Header().Get("X-RateLimit-Limit"))
+		assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+		// Three tokens at two per second
+		assert.Equal(t, "2", w.Header().Get("X-RateLimit-Reset"))
+		assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	})
+}
+
+func TestAdaptiveClientThrottling(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	policy := *models.NewRateLimitPolicy("test", 10, time.Second, 10)
+	policy.Penalty = models.PenaltyConfig{
+		Enabled:        true,
+		Threshold:      3,
+		HalfLife:       time.Minute,
+		RateFactor:     0.1,
+		TarpitDelay:    50 * time.Millisecond,
+		MaxTarpitDelay: 100 * time.Millisecond,
+	}
+	penalties := ratelimit.NewPenaltyBox(policy)
+
+	router := gin.New()
+	router.Use(middleware.RateLimit(policy, ratelimit.NewLimiter(policy.GetRate(), policy.Burst), penalties))
+	router.GET("/test", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "success"})
+	})
+	router.GET("/upstream-limited", func(c *gin.Context) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "slow down"})
+	})
 
+	send := func(path, userAgent string) (*httptest.ResponseRecorder, time.Duration) {
+		req, _ := http.NewRequest("GET", path, nil)
+		req.Header.Set("X-Forwarded-For", "10.0.0.1")
+		req.Header.Set("User-Agent", userAgent)
 		w := httptest.NewRecorder()
+		start := time.Now()
 		router.ServeHTTP(w, req)
+		return w, time.Since(start)
+	}
+
+	// Upstream 429s count as strikes until the client is penalized
+	for i := 0; i < 3; i++ {
+		w, _ := send("/upstream-limited", "retry-loop")
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	}
+	assert.Equal(t, 1, penalties.Stats()["penalized_clients"])
+
+	// A penalized client gets a tenth of the burst, and tarpitted rejections
+	w, _ := send("/test", "retry-loop")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w, elapsed := send("/test", "retry-loop")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+	assert.GreaterOrEqual(t, elapsed, 50*time.Millisecond)
+
+	// Another client behind the same IP keeps the normal limit
+	for i := 0; i < 5; i++ {
+		w, elapsed := send("/test", "browser")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Less(t, elapsed, 50*time.Millisecond)
+	}
+}
 
-		// When implemented, should include rate limit headers
-		expectedHeaders := []string{
-			"X-RateLimit-Limit",
-			"X-RateLimit-Remaining",
-			"X-RateLimit-Reset",
+func TestPerUserRateLimiting(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	policy := *models.NewRateLimitPolicy("test", 3, time.Minute, 3)
+	policy.Scope = models.ScopePerUser
+
+	router := gin.New()
+	// Stands in for authentication, which per_user limits run after
+	router.Use(func(c *gin.Context) {
+		if user := c.GetHeader("X-Test-User"); user != "" {
+			c.Set(middleware.IdentityKey, &auth.Identity{UserID: user})
 		}
+	})
+	router.Use(middleware.RateLimit(policy, ratelimit.NewLimiter(policy.GetRate(), policy.Burst), nil))
+	router.GET("/test", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
 
-		// For now, these headers won't be present until implementation
-		for _, header := range expectedHeaders {
-			value := w.Header().Get(header)
-			// When implemented, these should not be empty
-			_ = value // Placeholder for future assertion
+	send := func(ip, user string) int {
+		req, _ := http.NewRequest("GET", "/test", nil)
+		req.RemoteAddr = ip + ":40000"
+		if user != "" {
+			req.Header.Set("X-Test-User", user)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	t.Run("Users behind one NAT have their own limits", func(t *testing.T) {
+		for _, user := range []string{"alice", "bob"} {
+			for i := 0; i < 3; i++ {
+				assert.Equal(t, http.StatusOK, send("203.0.113.7", user), user)
+			}
 		}
+		assert.Equal(t, http.StatusTooManyRequests, send("203.0.113.7", "alice"))
 	})
-}
\ No newline at end of file
+
+	t.Run("Rotating addresses does not reset a user's limit", func(t *testing.T) {
+		assert.Equal(t, http.StatusTooManyRequests, send("198.51.100.1", "bob"))
+		assert.Equal(t, http.StatusTooManyRequests, send("198.51.100.2", "bob"))
+	})
+
+	t.Run("Anonymous requests are limited by IP", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, http.StatusOK, send("203.0.113.7", ""))
+		}
+		assert.Equal(t, http.StatusTooManyRequests, send("203.0.113.7", ""))
+		assert.Equal(t, http.StatusOK, send("203.0.113.8", ""))
+	})
+}
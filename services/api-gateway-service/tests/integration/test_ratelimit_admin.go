@@ -0,0 +1,135 @@
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gateway/internal/handlers"
+	"gateway/internal/middleware"
+	"gateway/internal/models"
+	"gateway/internal/ratelimit"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimitAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	policy := *models.NewRateLimitPolicy("test", 2, time.Minute, 2)
+	policy.Penalty = models.PenaltyConfig{
+		Enabled:    true,
+		Threshold:  2,
+		HalfLife:   time.Minute,
+		RateFactor: 0.5,
+	}
+	limiter := ratelimit.NewLimiter(policy.GetRate(), policy.Burst)
+	penalties := ratelimit.NewPenaltyBox(policy)
+
+	router := gin.New()
+	handlers.NewRateLimitsHandler(limiter, penalties).Register(router.Group("/gateway/ratelimits"))
+	api := router.Group("/api", middleware.RateLimit(policy, limiter, penalties))
+	api.GET("/orders", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	send := func(ip string) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/orders", nil)
+		req.RemoteAddr = ip + ":40000"
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	admin := func(method, target string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return w.Code, body
+	}
+
+	for i := 0; i < 4; i++ {
+		send("203.0.113.7")
+	}
+	send("198.51.100.1")
+	require.Equal(t, 1, penalties.Stats()["penalized_clients"])
+
+	t.Run("Active buckets are listed, the most blocked first", func(t *testing.T) {
+		code, body := admin(http.MethodGet, "/gateway/ratelimits")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, 2.0, body["total"])
+		buckets := body["buckets"].([]interface{})
+		first := buckets[0].(map[string]interface{})
+		assert.Equal(t, "203.0.113.7", first["key"])
+		assert.Equal(t, 2.0, first["blocked"])
+		assert.Equal(t, 0.0, first["remaining"])
+		assert.Equal(t, 2.0, first["limit"])
+		assert.NotEmpty(t, first["last_seen"])
+
+		_, body = admin(http.MethodGet, "/gateway/ratelimits?blocked=true")
+		assert.Equal(t, 1.0, body["total"])
+		_, body = admin(http.MethodGet, "/gateway/ratelimits?key=198.51")
+		assert.Equal(t, 1.0, body["total"])
+		_, body = admin(http.MethodGet, "/gateway/ratelimits?limit=1")
+		assert.Equal(t, 2.0, body["total"])
+		assert.Len(t, body["buckets"], 1)
+
+		code, _ = admin(http.MethodGet, "/gateway/ratelimits?limit=0")
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+
+	t.Run("Resetting a key unblocks the client", func(t *testing.T) {
+		assert.Equal(t, http.StatusTooManyRequests, send("203.0.113.7"))
+
+		code, body := admin(http.MethodDelete, "/gateway/ratelimits?key=203.0.113.7")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, true, body["reset"])
+		assert.Equal(t, 1.0, body["penalties_forgiven"])
+		assert.Equal(t, 0, penalties.Stats()["penalized_clients"])
+
+		assert.Equal(t, http.StatusOK, send("203.0.113.7"))
+		assert.Equal(t, http.StatusOK, send("203.0.113.7"))
+	})
+
+	t.Run("Unknown or missing keys are refused", func(t *testing.T) {
+		code, _ := admin(http.MethodDelete, "/gateway/ratelimits?key=192.0.2.1")
+		assert.Equal(t, http.StatusNotFound, code)
+		code, _ = admin(http.MethodDelete, "/gateway/ratelimits")
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+
+	t.Run("Workers inspect and reset the coordinator's buckets", func(t *testing.T) {
+		socket := filepath.Join(t.TempDir(), "ratelimit.sock")
+		listener, err := net.Listen("unix", socket)
+		require.NoError(t, err)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		shared := ratelimit.NewLimiter(0.001, 1)
+		go ratelimit.ServeCoordinator(ctx, listener, shared)
+
+		client := ratelimit.NewClient(socket, ratelimit.NewLimiter(0.001, 1))
+		client.Take("user:alice")
+		client.Take("user:alice")
+
+		buckets, err := client.Buckets()
+		require.NoError(t, err)
+		require.Len(t, buckets, 1)
+		assert.Equal(t, "user:alice", buckets[0].Key)
+		assert.Equal(t, int64(1), buckets[0].Blocked)
+
+		existed, err := client.Reset("user:alice")
+		require.NoError(t, err)
+		assert.True(t, existed)
+		existed, err = client.Reset("user:alice")
+		require.NoError(t, err)
+		assert.False(t, existed)
+		assert.True(t, client.Take("user:alice").Allowed)
+	})
+}
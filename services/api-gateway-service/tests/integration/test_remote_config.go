@@ -0,0 +1,170 @@
+package integration
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"gateway/internal/config"
+	"gateway/internal/models"
+	"gateway/internal/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeConsul serves one KV key, with blocking queries, the way a Consul
+// agent does.
+type fakeConsul struct {
+	mutex   sync.Mutex
+	value   []byte
+	index   uint64
+	updated chan struct{}
+}
+
+func newFakeConsul(value string) *fakeConsul {
+	consul := &fakeConsul{updated: make(chan struct{}), index: 1}
+	if value != "" {
+		consul.value = []byte(value)
+	}
+	return consul
+}
+
+func (f *fakeConsul) set(value string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.value = []byte(value)
+	f.index++
+	close(f.updated)
+	f.updated = make(chan struct{})
+}
+
+func (f *fakeConsul) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/v1/kv/gateway/config" || r.Header.Get("X-Consul-Token") != "consul-token" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	index, _ := strconv.ParseUint(r.URL.Query().Get("index"), 10, 64)
+	f.mutex.Lock()
+	if index > 0 && index >= f.index {
+		updated := f.updated
+		f.mutex.Unlock()
+		select {
+		case <-updated:
+		case <-time.After(2 * time.Second):
+		case <-r.Context().Done():
+			return
+		}
+		f.mutex.Lock()
+	}
+	value, current := f.value, f.index
+	f.mutex.Unlock()
+
+	w.Header().Set("X-Consul-Index", strconv.FormatUint(current, 10))
+	if value == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Write(value)
+}
+
+func TestRemoteConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(reloadBaseConfig+`
+rate_limit:
+  requests: 100
+`), 0o600))
+
+	consul := newFakeConsul(`
+rate_limit:
+  requests: 10
+services:
+  payments:
+    name: "payments"
+    url: "http://payments:8009"
+    timeout: "5s"
+routes:
+  - path: "/api/orders/*"
+    service_name: "orders"
+  - path: "/api/payments/*"
+    service_name: "payments"
+`)
+	server := httptest.NewServer(consul)
+	defer server.Close()
+
+	newSource := func(address string) config.RemoteSource {
+		client, err := store.NewConsulClient(models.ConsulConfig{Address: address, Token: "consul-token", Timeout: time.Second})
+		require.NoError(t, err)
+		return store.NewConsulConfigSource(client, "/gateway/config")
+	}
+
+	manager := config.NewManager()
+	require.NoError(t, manager.LoadConfig(path))
+	source := newSource(server.URL)
+
+	t.Run("The remote document is merged over the file", func(t *testing.T) {
+		require.NoError(t, manager.UseRemote(source))
+
+		cfg := manager.GetConfig()
+		assert.Equal(t, 10, cfg.RateLimit.Requests)
+		require.Len(t, cfg.Services, 2)
+		assert.Equal(t, "http://orders:8008", cfg.Services["orders"].URL)
+		assert.Equal(t, 5*time.Second, cfg.Services["payments"].Timeout)
+		// Lists replace the file's
+		assert.Len(t, cfg.Routes, 2)
+		assert.Equal(t, int64(0), manager.ReloadStats().Stats()["attempts"])
+	})
+
+	t.Run("Changes are watched and reloaded", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		changes := make(chan struct{}, 4)
+		go source.Watch(ctx, func() { changes <- struct{}{} })
+
+		consul.set(`
+rate_limit:
+  requests: 20
+`)
+		select {
+		case <-changes:
+		case <-time.After(3 * time.Second):
+			t.Fatal("remote change was not noticed")
+		}
+
+		diff, err := manager.Reload()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"rate_limit"}, diff.Sections)
+		assert.Equal(t, []string{"payments"}, diff.ServicesRemoved)
+		assert.Equal(t, 20, manager.GetConfig().RateLimit.Requests)
+		assert.Len(t, manager.GetConfig().Routes, 1)
+	})
+
+	t.Run("An unreachable store keeps the configuration", func(t *testing.T) {
+		offline := config.NewManager()
+		require.NoError(t, offline.LoadConfig(path))
+
+		err := offline.UseRemote(newSource("http://127.0.0.1:1"))
+		assert.ErrorContains(t, err, "failed to read remote config from consul key /gateway/config")
+		assert.Equal(t, 100, offline.GetConfig().RateLimit.Requests)
+
+		_, err = offline.Reload()
+		assert.Error(t, err)
+		assert.Equal(t, int64(1), offline.ReloadStats().Stats()["load_failures"])
+	})
+
+	t.Run("A missing key leaves the file as it is", func(t *testing.T) {
+		empty := httptest.NewServer(newFakeConsul(""))
+		defer empty.Close()
+
+		manager := config.NewManager()
+		require.NoError(t, manager.LoadConfig(path))
+		require.NoError(t, manager.UseRemote(newSource(empty.URL)))
+		assert.Equal(t, 100, manager.GetConfig().RateLimit.Requests)
+	})
+}
@@ -0,0 +1,83 @@
+package integration
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"gateway/internal/middleware"
+	"gateway/internal/traffic"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestCounters(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	counters := traffic.NewCounters()
+	router := gin.New()
+	router.Use(middleware.CountRequests(counters))
+	router.Use(gin.Recovery())
+	router.GET("/ok", func(c *gin.Context) {
+		time.Sleep(10 * time.Millisecond)
+		c.Status(http.StatusOK)
+	})
+	router.GET("/missing", func(c *gin.Context) {
+		c.Status(http.StatusNotFound)
+	})
+	router.GET("/panic", func(c *gin.Context) {
+		panic("boom")
+	})
+
+	request := func(path string) {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	t.Run("Requests are tallied by outcome", func(t *testing.T) {
+		request("/ok")
+		request("/ok")
+		request("/missing")
+		request("/panic")
+
+		snapshot := counters.Snapshot()
+		assert.Equal(t, int64(4), snapshot.Total)
+		assert.Equal(t, int64(2), snapshot.Success)
+		assert.Equal(t, int64(1), snapshot.ClientErrors)
+		assert.Equal(t, int64(1), snapshot.ServerErrors)
+		assert.Equal(t, int64(2), snapshot.Errors())
+		assert.GreaterOrEqual(t, snapshot.ResponseTime, 20*time.Millisecond)
+
+		stats := counters.Stats()
+		assert.Equal(t, int64(4), stats["total"])
+		assert.Equal(t, int64(2), stats["errors"])
+		assert.Greater(t, stats["avg_response_time"].(float64), 5.0)
+	})
+
+	t.Run("Concurrent requests are all counted", func(t *testing.T) {
+		concurrent := traffic.NewCounters()
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 1000; j++ {
+					concurrent.Record(http.StatusOK, time.Millisecond)
+				}
+			}()
+		}
+		wg.Wait()
+
+		snapshot := concurrent.Snapshot()
+		assert.Equal(t, int64(8000), snapshot.Total)
+		assert.Equal(t, int64(8000), snapshot.Success)
+		assert.Equal(t, 8*time.Second, snapshot.ResponseTime)
+		assert.Equal(t, 1.0, snapshot.AverageResponseTime())
+	})
+
+	t.Run("No requests average to zero", func(t *testing.T) {
+		assert.Equal(t, 0.0, traffic.NewCounters().Snapshot().AverageResponseTime())
+	})
+}
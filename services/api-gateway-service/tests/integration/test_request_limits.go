@@ -0,0 +1,116 @@
+package integration
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gateway/internal/config"
+	"gateway/internal/middleware"
+	"gateway/internal/models"
+	"gateway/internal/proxy"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestLimits(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	limits := models.RequestLimits{MaxHeaderBytes: 1024, MaxHeaderLength: 256, MaxHeaders: 10, MaxURLLength: 64}
+	var seen string
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Next()
+		seen = proxy.Termination(c)
+	})
+	router.Use(middleware.RequestLimits(limits))
+	router.GET("/api/*path", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	send := func(target string, headers map[string]string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		seen = ""
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var body map[string]interface{}
+		if w.Code != http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		}
+		return w, body
+	}
+
+	t.Run("Requests within the limits pass", func(t *testing.T) {
+		w, _ := send("/api/orders?page=2", map[string]string{"Authorization": "Bearer " + strings.Repeat("t", 200)})
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Long URLs get a 414", func(t *testing.T) {
+		w, body := send("/api/orders?filter="+strings.Repeat("x", 64), nil)
+		assert.Equal(t, http.StatusRequestURITooLong, w.Code)
+		assert.Equal(t, "max_url_length", body["limit"])
+		assert.Equal(t, float64(64), body["max"])
+		assert.Equal(t, "The request URL is too long", body["message"])
+		assert.Equal(t, proxy.TerminationURLTooLong, seen)
+	})
+
+	t.Run("A long header gets a 431 naming it", func(t *testing.T) {
+		w, body := send("/api/orders", map[string]string{"Cookie": strings.Repeat("c", 256)})
+		assert.Equal(t, http.StatusRequestHeaderFieldsTooLarge, w.Code)
+		assert.Equal(t, "max_header_length", body["limit"])
+		assert.Equal(t, "Cookie", body["header"])
+		assert.Equal(t, proxy.TerminationHeadersTooLarge, seen)
+	})
+
+	t.Run("Too many headers get a 431", func(t *testing.T) {
+		headers := make(map[string]string)
+		for i := 0; i < 10; i++ {
+			headers[fmt.Sprintf("X-Flood-%d", i)] = "1"
+		}
+		w, body := send("/api/orders", headers)
+		assert.Equal(t, http.StatusRequestHeaderFieldsTooLarge, w.Code)
+		assert.Equal(t, "max_headers", body["limit"])
+	})
+
+	t.Run("Headers too large together get a 431", func(t *testing.T) {
+		headers := make(map[string]string)
+		for i := 0; i < 5; i++ {
+			headers[fmt.Sprintf("X-Large-%d", i)] = strings.Repeat("v", 220)
+		}
+		w, body := send("/api/orders", headers)
+		assert.Equal(t, http.StatusRequestHeaderFieldsTooLarge, w.Code)
+		assert.Equal(t, "max_header_bytes", body["limit"])
+		assert.Equal(t, float64(1024), body["max"])
+	})
+
+	t.Run("Zero leaves a limit off", func(t *testing.T) {
+		router := gin.New()
+		router.Use(middleware.RequestLimits(models.RequestLimits{}))
+		router.GET("/api/*path", func(c *gin.Context) { c.Status(http.StatusOK) })
+		req := httptest.NewRequest(http.MethodGet, "/api/orders?filter="+strings.Repeat("x", 10000), nil)
+		req.Header.Set("Cookie", strings.Repeat("c", 10000))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Negative limits are rejected", func(t *testing.T) {
+		content := reloadBaseConfig + "server:\n  limits:\n    max_headers: -1\n"
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		manager := config.NewManager()
+		require.NoError(t, manager.LoadConfig(path))
+		assert.Equal(t, 64<<10, manager.GetConfig().Server.Limits.MaxHeaderBytes)
+		err := manager.ValidateConfig()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "server limits max_headers must not be negative")
+	})
+}
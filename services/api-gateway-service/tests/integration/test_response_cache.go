@@ -0,0 +1,154 @@
+package integration
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gateway/internal/cache"
+	"gateway/internal/middleware"
+	"gateway/internal/models"
+	"gateway/internal/proxy"
+	"gateway/internal/registry"
+	"gateway/internal/session"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseCacheWarmup(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var upstreamHits int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&upstreamHits, 1)
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/api/products/private" {
+			w.Header().Set("Cache-Control", "private")
+		}
+		w.Write([]byte(`{"products": []}`))
+	}))
+	defer upstream.Close()
+
+	serviceRegistry := registry.NewServiceRegistry()
+	serviceRegistry.RegisterService(*models.NewServiceConfig("products", upstream.URL, time.Second))
+	route := models.NewRouteConfig("/api/products/*", "products")
+	route.CacheTTL = time.Minute
+	serviceRegistry.RegisterRoute(*route)
+
+	responseCache := cache.New(100)
+	router := gin.New()
+	api := router.Group("/api")
+	api.Use(middleware.ResponseCache(responseCache, serviceRegistry, 1<<20))
+	api.Any("/*proxyPath", proxy.New(serviceRegistry).Handle)
+
+	get := func(path string, headers map[string]string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", path, nil)
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Warmup fills the cache before the first request", func(t *testing.T) {
+		warmer := cache.NewWarmer(router, models.WarmupConfig{
+			Paths:       []string{"/api/products/list?page=1", "/api/products/featured"},
+			Concurrency: 2,
+		})
+		warmed, failed := warmer.Run(context.Background())
+		assert.Equal(t, 2, warmed)
+		assert.Equal(t, 0, failed)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&upstreamHits))
+
+		w := get("/api/products/list?page=1", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "HIT", w.Header().Get(middleware.CacheStatusHeader))
+		assert.JSONEq(t, `{"products": []}`, w.Body.String())
+		assert.Equal(t, int32(2), atomic.LoadInt32(&upstreamHits))
+	})
+
+	t.Run("Warmup refreshes existing entries", func(t *testing.T) {
+		warmer := cache.NewWarmer(router, models.WarmupConfig{
+			Paths:       []string{"/api/products/featured"},
+			Concurrency: 1,
+		})
+		warmer.Run(context.Background())
+		assert.Equal(t, int32(3), atomic.LoadInt32(&upstreamHits))
+	})
+
+	t.Run("Credentialed and private responses bypass the cache", func(t *testing.T) {
+		w := get("/api/products/list?page=1", map[string]string{"Authorization": "Bearer token"})
+		assert.Empty(t, w.Header().Get(middleware.CacheStatusHeader))
+
+		get("/api/products/private", nil)
+		w = get("/api/products/private", nil)
+		assert.Equal(t, "MISS", w.Header().Get(middleware.CacheStatusHeader))
+	})
+}
+
+func TestResponseCacheSkipsSessionUsers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"user": "` + r.Header.Get("X-User-ID") + `"}`))
+	}))
+	defer upstream.Close()
+
+	serviceRegistry := registry.NewServiceRegistry()
+	serviceRegistry.RegisterService(*models.NewServiceConfig("profile", upstream.URL, time.Second))
+	route := models.NewRouteConfig("/api/profile/*", "profile")
+	route.AuthMode = models.AuthModeOptional
+	route.CacheTTL = time.Minute
+	serviceRegistry.RegisterRoute(*route)
+
+	key, _ := session.GenerateKey()
+	keyring, _ := session.NewKeyring([]string{key})
+	sessions := session.NewManager(session.NewMemoryStore(), keyring, session.CookieOptions{Name: "gw_session"}, time.Hour)
+	sess, err := sessions.New()
+	require.NoError(t, err)
+	sess.UserID = "alice"
+	sess.Set(session.ValueSource, session.SourceSessionLogin)
+	saved := httptest.NewRecorder()
+	require.NoError(t, sessions.Save(saved, httptest.NewRequest("POST", "/session/login", nil), sess))
+	sessionCookie := saved.Result().Cookies()[0]
+
+	// As main.go orders them: the session is stripped and turned into
+	// identity headers before the cache sees the request
+	router := gin.New()
+	router.Use(middleware.SessionAuth(sessions, serviceRegistry, models.SessionAuthConfig{Enabled: true}))
+	router.Use(middleware.PropagateIdentity(map[string]string{"user_id": "X-User-ID"}))
+	router.Use(middleware.ResponseCache(cache.New(100), serviceRegistry, 1<<20))
+	router.Any("/api/*proxyPath", proxy.New(serviceRegistry).Handle)
+
+	get := func(cookie *http.Cookie, extra ...*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/profile/me", nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		for _, c := range extra {
+			req.AddCookie(c)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get(sessionCookie)
+	assert.JSONEq(t, `{"user": "alice"}`, w.Body.String())
+	assert.Empty(t, w.Header().Get(middleware.CacheStatusHeader), "identified requests bypass the cache")
+
+	w = get(nil)
+	assert.JSONEq(t, `{"user": ""}`, w.Body.String(), "anonymous callers never get another user's response")
+	assert.Equal(t, "MISS", w.Header().Get(middleware.CacheStatusHeader))
+
+	w = get(&http.Cookie{Name: "theme", Value: "dark"})
+	assert.Empty(t, w.Header().Get(middleware.CacheStatusHeader), "requests with cookies bypass the cache")
+	assert.Equal(t, "HIT", get(nil).Header().Get(middleware.CacheStatusHeader))
+}
@@ -0,0 +1,125 @@
+package integration
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gateway/internal/circuit"
+	"gateway/internal/middleware"
+	"gateway/internal/models"
+	"gateway/internal/proxy"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetriesWithCircuitBreaker(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var hits, failures int32
+	var onHit func()
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		if onHit != nil {
+			onHit()
+		}
+		if atomic.AddInt32(&failures, -1) >= 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+
+	serviceRegistry := registry.NewServiceRegistry()
+	orders := models.NewServiceConfig("orders", upstream.URL, 2*time.Second)
+	orders.Retry = &models.RetryPolicy{MaxRetries: 2, Backoff: time.Millisecond}
+	serviceRegistry.RegisterService(*orders)
+	serviceRegistry.RegisterRoute(*models.NewRouteConfig("/api/orders/*", "orders"))
+
+	breakers := circuit.NewBreakers(*models.NewCircuitBreakerSettings(3, time.Minute, time.Minute, 0.5), nil)
+	proxyHandler := proxy.New(serviceRegistry)
+	router := gin.New()
+	router.Use(middleware.CircuitBreaker(breakers, serviceRegistry))
+	router.Any("/api/*path", proxyHandler.Handle)
+
+	request := func(method string) *httptest.ResponseRecorder {
+		var body *strings.Reader
+		if method == http.MethodPost {
+			body = strings.NewReader(`{"item":1}`)
+		} else {
+			body = strings.NewReader("")
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, "/api/orders/1", body))
+		return w
+	}
+	reset := func(failing int32) {
+		atomic.StoreInt32(&hits, 0)
+		atomic.StoreInt32(&failures, failing)
+		breakers.Reset("orders", "")
+	}
+
+	t.Run("A request recovered by a retry counts as one success", func(t *testing.T) {
+		reset(1)
+		w := request(http.MethodGet)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "1", w.Header().Get(proxy.RetriesHeader))
+		assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+
+		status, ok := breakers.Circuit("orders", "")
+		require.True(t, ok)
+		assert.Equal(t, 0, status.FailureCount)
+		assert.Equal(t, 1, status.SuccessCount)
+	})
+
+	t.Run("Retried failures count once toward the breaker", func(t *testing.T) {
+		reset(100)
+		w := request(http.MethodGet)
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, "2", w.Header().Get(proxy.RetriesHeader))
+		assert.Equal(t, int32(3), atomic.LoadInt32(&hits))
+
+		status, _ := breakers.Circuit("orders", "")
+		assert.Equal(t, 1, status.FailureCount)
+		assert.Equal(t, models.CircuitClosed, status.State)
+	})
+
+	t.Run("No retries go out once the circuit is open", func(t *testing.T) {
+		reset(100)
+		onHit = func() {
+			breakers.ForceOpen("orders", "", breakers.Settings(), time.Time{})
+		}
+		defer func() { onHit = nil }()
+
+		w := request(http.MethodGet)
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Empty(t, w.Header().Get(proxy.RetriesHeader))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+
+		// Requests that come later are turned away by the circuit itself
+		onHit = nil
+		w = request(http.MethodGet)
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.NotEmpty(t, w.Header().Get("Retry-After"))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+	})
+
+	t.Run("Requests with a body are not retried", func(t *testing.T) {
+		reset(1)
+		assert.Equal(t, http.StatusServiceUnavailable, request(http.MethodPost).Code)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+	})
+
+	t.Run("Retries are counted by outcome", func(t *testing.T) {
+		counts := proxyHandler.Retries().Counts()
+		require.Len(t, counts, 1)
+		assert.Equal(t, proxy.RetryCount{Service: "orders", Retried: 3, Recovered: 1, Exhausted: 1, Blocked: 1}, counts[0])
+	})
+}
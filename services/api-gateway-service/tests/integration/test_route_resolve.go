@@ -0,0 +1,124 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gateway/internal/circuit"
+	"gateway/internal/handlers"
+	"gateway/internal/maintenance"
+	"gateway/internal/models"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouteResolve(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	serviceRegistry := registry.NewServiceRegistry()
+	serviceRegistry.RegisterService(*models.NewServiceConfig("orders", "http://orders:8080", 5*time.Second))
+	serviceRegistry.RegisterService(*models.NewServiceConfig("reports", "http://reports:8080", 5*time.Second))
+	legacy := models.NewServiceConfig("legacy", "http://legacy:8080", time.Second)
+	legacy.Enabled = false
+	serviceRegistry.RegisterService(*legacy)
+
+	orders := models.NewRouteConfig("/api/orders/*", "orders")
+	orders.StripPrefix = true
+	orders.AuthRequired = true
+	orders.Cost = 3
+	orders.CircuitBreaker = &models.CircuitBreakerOverride{FailureThreshold: 2}
+	serviceRegistry.RegisterRoute(*models.NewRouteConfig("/api/orders/*", "legacy"))
+	serviceRegistry.RegisterRoute(*orders)
+	reports := models.NewRouteConfig("/api/reports/*", "reports")
+	reports.Method = http.MethodGet
+	serviceRegistry.RegisterRoute(*reports)
+	cart := models.NewRouteConfig("/api/cart/*", "")
+	cart.MigrateTo = "/api/orders/cart/*"
+	cart.MigrationMode = models.MigrationRewrite
+	serviceRegistry.RegisterRoute(*cart)
+	basket := models.NewRouteConfig("/api/basket/*", "")
+	basket.MigrateTo = "/api/orders/cart/*"
+	serviceRegistry.RegisterRoute(*basket)
+
+	cfg := models.NewDefaultGatewayConfig()
+	cfg.RateLimit.Enabled = true
+	breakers := circuit.NewBreakers(cfg.CircuitBreaker, nil)
+	mode, err := maintenance.New(models.MaintenanceConfig{})
+	require.NoError(t, err)
+
+	router := gin.New()
+	handlers.NewResolveHandler(serviceRegistry, cfg, breakers, mode).Register(router.Group("/gateway/routes"))
+	resolve := func(query string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/gateway/routes/resolve?"+query, nil))
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return w.Code, body
+	}
+
+	t.Run("Matched requests show the route, upstream and policies", func(t *testing.T) {
+		code, body := resolve("method=post&path=/api/orders/123")
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "POST", body["method"])
+		assert.Equal(t, true, body["matched"])
+
+		service := body["service"].(map[string]interface{})
+		assert.Equal(t, "orders", service["name"])
+		assert.Equal(t, "serving", service["traffic"])
+		upstream := body["upstream"].(map[string]interface{})
+		assert.Equal(t, "/123", upstream["path"])
+		assert.Equal(t, "http://orders:8080/123", upstream["url"])
+
+		policies := body["policies"].(map[string]interface{})
+		assert.Equal(t, "required", policies["auth"].(map[string]interface{})["mode"])
+		assert.Equal(t, float64(3), policies["rate_limit"].(map[string]interface{})["cost"])
+		breaker := policies["circuit_breaker"].(map[string]interface{})
+		assert.Equal(t, "/api/orders/*", breaker["route"])
+		assert.Equal(t, "closed", breaker["state"])
+		assert.Equal(t, float64(2), breaker["settings"].(map[string]interface{})["failure_threshold"])
+	})
+
+	t.Run("Skipped routes are listed with the reason", func(t *testing.T) {
+		_, body := resolve("path=/api/orders/123")
+		skipped := body["skipped"].([]interface{})
+		require.Len(t, skipped, 1)
+		assert.Equal(t, "legacy", skipped[0].(map[string]interface{})["service"])
+		assert.Contains(t, skipped[0].(map[string]interface{})["reason"], "disabled")
+
+		_, body = resolve("method=DELETE&path=/api/reports/daily")
+		assert.Equal(t, false, body["matched"])
+		skipped = body["skipped"].([]interface{})
+		require.Len(t, skipped, 1)
+		assert.Contains(t, skipped[0].(map[string]interface{})["reason"], "method DELETE is not GET")
+	})
+
+	t.Run("Migrations are followed or reported", func(t *testing.T) {
+		_, body := resolve("path=/api/cart/items")
+		migration := body["migration"].(map[string]interface{})
+		assert.Equal(t, "/api/orders/cart/items", migration["to"])
+		assert.Equal(t, "rewrite", migration["mode"])
+		assert.Equal(t, true, body["matched"])
+		assert.Equal(t, "/cart/items", body["upstream"].(map[string]interface{})["path"])
+
+		_, body = resolve("path=/api/basket/items")
+		assert.Equal(t, "redirect", body["migration"].(map[string]interface{})["mode"])
+		assert.Equal(t, false, body["matched"])
+		assert.Nil(t, body["route"])
+	})
+
+	t.Run("Unmatched and invalid requests", func(t *testing.T) {
+		code, body := resolve("path=/api/billing/1")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, false, body["matched"])
+		assert.Empty(t, body["skipped"])
+
+		code, _ = resolve("method=GET")
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+}
@@ -0,0 +1,112 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gateway/internal/circuit"
+	"gateway/internal/middleware"
+	"gateway/internal/models"
+	"gateway/internal/proxy"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouteTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(200 * time.Millisecond):
+		case <-r.Context().Done():
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+
+	serviceRegistry := registry.NewServiceRegistry()
+	serviceRegistry.RegisterService(*models.NewServiceConfig("products", upstream.URL, 2*time.Second))
+	serviceRegistry.RegisterService(*models.NewServiceConfig("reports", upstream.URL, 50*time.Millisecond))
+	feed := models.NewRouteConfig("/api/feed/*", "products")
+	feed.Timeout = 50 * time.Millisecond
+	serviceRegistry.RegisterRoute(*feed)
+	slow := models.NewRouteConfig("/api/slow/*", "products")
+	slow.Timeout = 50 * time.Millisecond
+	serviceRegistry.RegisterRoute(*slow)
+	reports := models.NewRouteConfig("/api/reports/*", "reports")
+	reports.Timeout = time.Second
+	serviceRegistry.RegisterRoute(*reports)
+	serviceRegistry.RegisterRoute(*models.NewRouteConfig("/api/products/*", "products"))
+
+	breakers := circuit.NewBreakers(*models.NewCircuitBreakerSettings(3, time.Minute, time.Minute, 0.5), nil)
+	router := gin.New()
+	router.Use(middleware.RouteTimeout(serviceRegistry))
+	// Stands in for middleware that gives up on the deadline without
+	// answering
+	router.Use(func(c *gin.Context) {
+		if c.Request.URL.Path == "/api/slow/report" {
+			<-c.Request.Context().Done()
+			c.Abort()
+		}
+	})
+	router.Use(middleware.CircuitBreaker(breakers, serviceRegistry))
+	router.Any("/api/*path", proxy.New(serviceRegistry).Handle)
+
+	request := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set(middleware.CorrelationIDHeader, "req-42")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("The upstream request is cancelled at the route's timeout", func(t *testing.T) {
+		start := time.Now()
+		w := request("/api/feed/latest")
+		assert.Less(t, time.Since(start), 150*time.Millisecond)
+		require.Equal(t, http.StatusGatewayTimeout, w.Code)
+		assert.Equal(t, proxy.TimeoutReasonRoute, w.Header().Get(proxy.TimeoutReasonHeader))
+
+		var body map[string]string
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, proxy.TimeoutReasonRoute, body["reason"])
+		assert.Equal(t, "50ms", body["timeout"])
+		assert.Equal(t, "req-42", body["correlation_id"])
+		assert.Equal(t, "Route /api/feed/* did not complete within 50ms", body["message"])
+	})
+
+	t.Run("Requests held up before the proxy are answered for", func(t *testing.T) {
+		w := request("/api/slow/report")
+		require.Equal(t, http.StatusGatewayTimeout, w.Code)
+		assert.Equal(t, proxy.TimeoutReasonRoute, w.Header().Get(proxy.TimeoutReasonHeader))
+	})
+
+	t.Run("A service timeout inside the route's is the service's", func(t *testing.T) {
+		w := request("/api/reports/daily")
+		require.Equal(t, http.StatusGatewayTimeout, w.Code)
+		assert.Equal(t, proxy.TimeoutReasonService, w.Header().Get(proxy.TimeoutReasonHeader))
+	})
+
+	t.Run("Routes without a timeout are left alone", func(t *testing.T) {
+		w := request("/api/products/1")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get(proxy.TimeoutReasonHeader))
+	})
+
+	t.Run("Route timeouts do not count against the service", func(t *testing.T) {
+		for i := 0; i < 5; i++ {
+			assert.Equal(t, http.StatusGatewayTimeout, request("/api/feed/latest").Code)
+		}
+		status, ok := breakers.Circuit("products", "")
+		require.True(t, ok)
+		assert.Equal(t, models.CircuitClosed, status.State)
+		assert.Zero(t, status.FailureCount)
+	})
+}
@@ -0,0 +1,148 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gateway/internal/config"
+	"gateway/internal/handlers"
+	"gateway/internal/registry"
+	"gateway/internal/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const runtimeRoutesConfig = `
+services:
+  orders:
+    name: "orders"
+    url: "http://orders:8008"
+    timeout: "5s"
+    enabled: true
+routes:
+  - path: "/api/orders/*"
+    service_name: "orders"
+`
+
+func TestRuntimeRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(runtimeRoutesConfig), 0o600))
+	manager := config.NewManager()
+	require.NoError(t, manager.LoadConfig(configPath))
+	cfg := manager.GetConfig()
+
+	serviceRegistry := registry.NewServiceRegistry()
+	require.NoError(t, serviceRegistry.ReplaceRouting(nil, cfg.Services, nil, cfg.Routes))
+	routingFile := filepath.Join(dir, "routing.yaml")
+	runtime := store.NewRuntimeRouting(serviceRegistry, manager, routingFile)
+	_, err := runtime.Create("inventory", []byte(`{"url": "http://inventory:8012"}`))
+	require.NoError(t, err)
+
+	router := gin.New()
+	handlers.NewServicesHandler(runtime).Register(router.Group("/gateway/services"))
+	handlers.NewRoutesHandler(runtime).Register(router.Group("/gateway/routes"))
+	send := func(method, target, definition string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(definition)))
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return w.Code, body
+	}
+
+	t.Run("Routes are added to the running gateway", func(t *testing.T) {
+		status, body := send(http.MethodPost, "/gateway/routes", `{"path": "/api/inventory/*", "service_name": "inventory"}`)
+		require.Equal(t, http.StatusCreated, status, body)
+
+		route, service := serviceRegistry.FindRoute(http.MethodGet, "/api/inventory/42")
+		require.NotNil(t, route)
+		assert.Equal(t, "inventory", service.Name)
+		assert.Equal(t, "*", route.Method)
+
+		// Routes with different methods on one path do not overlap
+		status, body = send(http.MethodPost, "/gateway/routes", `{"path": "/api/stock", "method": "get", "service_name": "inventory"}`)
+		require.Equal(t, http.StatusCreated, status, body)
+		status, body = send(http.MethodPost, "/gateway/routes", `{"path": "/api/stock", "method": "POST", "service_name": "orders"}`)
+		require.Equal(t, http.StatusCreated, status, body)
+	})
+
+	t.Run("Overlapping routes are refused", func(t *testing.T) {
+		for _, candidate := range []struct{ definition, conflict string }{
+			{`{"path": "/api/orders/special", "service_name": "inventory"}`, "* /api/orders/*"},
+			{`{"path": "/api/*", "method": "DELETE", "service_name": "inventory"}`, "* /api/orders/*"},
+			{`{"path": "/api/inventory/*", "service_name": "inventory"}`, "* /api/inventory/*"},
+			{`{"path": "/api/stock", "service_name": "inventory"}`, "GET /api/stock"},
+		} {
+			status, body := send(http.MethodPost, "/gateway/routes", candidate.definition)
+			assert.Equal(t, http.StatusConflict, status, candidate.definition)
+			assert.Contains(t, body["conflicts"], candidate.conflict)
+		}
+		assert.Len(t, serviceRegistry.GetRoutes(), 4)
+	})
+
+	t.Run("Definitions are validated", func(t *testing.T) {
+		status, body := send(http.MethodPost, "/gateway/routes", `{"path": "/api/billing/*", "service_name": "billing"}`)
+		assert.Equal(t, http.StatusBadRequest, status)
+		assert.Contains(t, body["message"], "non-existent service: billing")
+
+		status, body = send(http.MethodPost, "/gateway/routes", "path: /api/billing/*\nservice: inventory\n")
+		assert.Equal(t, http.StatusBadRequest, status)
+		assert.Len(t, body["violations"], 1)
+	})
+
+	t.Run("A route is replaced in a single step", func(t *testing.T) {
+		status, body := send(http.MethodPut, "/gateway/routes", `{"path": "/api/inventory/*", "service_name": "inventory", "timeout": "3s"}`)
+		require.Equal(t, http.StatusOK, status, body)
+		assert.Equal(t, false, body["created"])
+
+		route, _ := serviceRegistry.FindRoute(http.MethodGet, "/api/inventory/42")
+		require.NotNil(t, route)
+		assert.Equal(t, 3*time.Second, route.Timeout)
+		assert.Len(t, serviceRegistry.GetRoutes(), 4)
+
+		status, _ = send(http.MethodPut, "/gateway/routes", `{"path": "/api/orders/*", "service_name": "inventory"}`)
+		assert.Equal(t, http.StatusConflict, status)
+		route, service := serviceRegistry.FindRoute(http.MethodGet, "/api/orders/1")
+		require.NotNil(t, route)
+		assert.Equal(t, "orders", service.Name)
+	})
+
+	t.Run("Routes are removed by method and path", func(t *testing.T) {
+		status, _ := send(http.MethodDelete, "/gateway/services/inventory", "")
+		assert.Equal(t, http.StatusConflict, status)
+
+		status, _ = send(http.MethodDelete, "/gateway/routes?path=/api/orders/*", "")
+		assert.Equal(t, http.StatusConflict, status)
+		status, _ = send(http.MethodDelete, "/gateway/routes?path=/api/unknown", "")
+		assert.Equal(t, http.StatusNotFound, status)
+		status, _ = send(http.MethodDelete, "/gateway/routes?method=GET&path=/api/stock", "")
+		assert.Equal(t, http.StatusOK, status)
+
+		route, _ := serviceRegistry.FindRoute(http.MethodGet, "/api/stock")
+		assert.Nil(t, route)
+		route, _ = serviceRegistry.FindRoute(http.MethodPost, "/api/stock")
+		assert.NotNil(t, route)
+	})
+
+	t.Run("Routes are saved and registered again on startup", func(t *testing.T) {
+		restarted := registry.NewServiceRegistry()
+		require.NoError(t, restarted.ReplaceRouting(nil, cfg.Services, nil, cfg.Routes))
+		require.NoError(t, store.NewRuntimeRouting(restarted, manager, routingFile).Load())
+
+		assert.Len(t, restarted.GetRoutes(), 3)
+		route, service := restarted.FindRoute(http.MethodGet, "/api/inventory/1")
+		require.NotNil(t, route)
+		assert.Equal(t, "inventory", service.Name)
+		assert.Equal(t, 3*time.Second, route.Timeout)
+	})
+}
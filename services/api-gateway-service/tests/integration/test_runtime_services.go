@@ -0,0 +1,155 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gateway/internal/audit"
+	"gateway/internal/config"
+	"gateway/internal/handlers"
+	"gateway/internal/middleware"
+	"gateway/internal/models"
+	"gateway/internal/registry"
+	"gateway/internal/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuntimeServices(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(reloadBaseConfig), 0o600))
+	manager := config.NewManager()
+	require.NoError(t, manager.LoadConfig(configPath))
+	cfg := manager.GetConfig()
+
+	serviceRegistry := registry.NewServiceRegistry()
+	require.NoError(t, serviceRegistry.ReplaceRouting(nil, cfg.Services, nil, cfg.Routes))
+	routingFile := filepath.Join(dir, "state", "routing.yaml")
+	runtime := store.NewRuntimeRouting(serviceRegistry, manager, routingFile)
+	require.NoError(t, runtime.Load())
+
+	auditLog := audit.NewLog(100)
+	router := gin.New()
+	admin := router.Group("/gateway")
+	admin.Use(middleware.Audit(auditLog))
+	handlers.NewServicesHandler(runtime).Register(admin.Group("/services"))
+
+	send := func(method, name, definition string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, "/gateway/services/"+name, strings.NewReader(definition)))
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return w.Code, body
+	}
+
+	t.Run("Services are added to the running gateway", func(t *testing.T) {
+		status, body := send(http.MethodPost, "inventory", `{"url": "http://inventory:8012", "timeout": "5s", "headers": {"Authorization": "Bearer s3cret"}}`)
+		require.Equal(t, http.StatusCreated, status, body)
+		assert.Equal(t, true, body["created"])
+
+		service, exists := serviceRegistry.GetService("inventory")
+		require.True(t, exists)
+		assert.Equal(t, "http://inventory:8012", service.URL)
+		assert.Equal(t, 5*time.Second, service.Timeout)
+		assert.True(t, service.Enabled)
+
+		serviceRegistry.RegisterRoute(models.RouteConfig{Path: "/api/inventory/*", Method: "*", ServiceName: "inventory"})
+		_, routed := serviceRegistry.FindRoute(http.MethodGet, "/api/inventory/42")
+		require.NotNil(t, routed)
+		assert.Equal(t, "inventory", routed.Name)
+
+		records := auditLog.List(0, "", 0)
+		require.NotEmpty(t, records)
+		last := records[len(records)-1]
+		assert.Equal(t, http.StatusCreated, last.Status)
+		assert.Contains(t, string(last.After), "inventory:8012")
+		assert.NotContains(t, string(last.After), "s3cret")
+	})
+
+	t.Run("Definitions are validated", func(t *testing.T) {
+		status, body := send(http.MethodPost, "search", "url: http://search:8013\ntimout: 5s\n")
+		assert.Equal(t, http.StatusBadRequest, status)
+		violations := body["violations"].([]interface{})
+		require.Len(t, violations, 1)
+		assert.Contains(t, violations[0].(map[string]interface{})["message"], `did you mean "timeout"`)
+
+		status, _ = send(http.MethodPost, "search", `{"url": "http://search:8013", "health_interval": "10ms"}`)
+		assert.Equal(t, http.StatusBadRequest, status)
+		status, _ = send(http.MethodPost, "Search", `{"url": "http://search:8013"}`)
+		assert.Equal(t, http.StatusBadRequest, status)
+		status, _ = send(http.MethodPost, "search", "")
+		assert.Equal(t, http.StatusBadRequest, status)
+		_, exists := serviceRegistry.GetService("search")
+		assert.False(t, exists)
+	})
+
+	t.Run("Services of other sources are left alone", func(t *testing.T) {
+		status, _ := send(http.MethodPost, "inventory", `{"url": "http://inventory:8012"}`)
+		assert.Equal(t, http.StatusConflict, status)
+		status, _ = send(http.MethodPut, "orders", `{"url": "http://elsewhere:8008"}`)
+		assert.Equal(t, http.StatusConflict, status)
+		status, _ = send(http.MethodDelete, "orders", "")
+		assert.Equal(t, http.StatusConflict, status)
+		status, _ = send(http.MethodDelete, "unknown", "")
+		assert.Equal(t, http.StatusNotFound, status)
+
+		orders, _ := serviceRegistry.GetService("orders")
+		assert.Equal(t, "http://orders:8008", orders.URL)
+	})
+
+	t.Run("Services added here can be replaced", func(t *testing.T) {
+		status, body := send(http.MethodPut, "inventory", `{"url": "http://inventory-v2:8012", "timeout": "8s"}`)
+		require.Equal(t, http.StatusOK, status, body)
+		assert.Equal(t, false, body["created"])
+		service, _ := serviceRegistry.GetService("inventory")
+		assert.Equal(t, "http://inventory-v2:8012", service.URL)
+		assert.Equal(t, 8*time.Second, service.Timeout)
+
+		records := auditLog.List(0, "", 0)
+		last := records[len(records)-1]
+		assert.Contains(t, string(last.Before), "inventory:8012")
+		assert.Contains(t, string(last.After), "inventory-v2:8012")
+
+		status, body = send(http.MethodPut, "billing", `{"url": "http://billing:8014"}`)
+		assert.Equal(t, http.StatusCreated, status)
+		assert.Equal(t, true, body["created"])
+	})
+
+	t.Run("Services in use by routes are not deleted", func(t *testing.T) {
+		status, body := send(http.MethodDelete, "inventory", "")
+		assert.Equal(t, http.StatusConflict, status)
+		assert.Contains(t, body["message"], "inventory")
+
+		serviceRegistry.RemoveRoute("/api/inventory/*", "inventory")
+		status, _ = send(http.MethodDelete, "inventory", "")
+		assert.Equal(t, http.StatusOK, status)
+		_, exists := serviceRegistry.GetService("inventory")
+		assert.False(t, exists)
+	})
+
+	t.Run("Services are saved and registered again on startup", func(t *testing.T) {
+		saved, err := os.ReadFile(routingFile)
+		require.NoError(t, err)
+		assert.Contains(t, string(saved), "billing")
+		assert.NotContains(t, string(saved), "inventory")
+
+		restarted := registry.NewServiceRegistry()
+		require.NoError(t, restarted.ReplaceRouting(nil, cfg.Services, nil, cfg.Routes))
+		require.NoError(t, store.NewRuntimeRouting(restarted, manager, routingFile).Load())
+		billing, exists := restarted.GetService("billing")
+		require.True(t, exists)
+		assert.Equal(t, "http://billing:8014", billing.URL)
+		assert.Equal(t, 30*time.Second, billing.Timeout)
+	})
+}
@@ -0,0 +1,143 @@
+package integration
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"gateway/internal/config"
+	"gateway/internal/secrets"
+	"gateway/internal/tlsconfig"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecretReferences(t *testing.T) {
+	ca := newTestCA(t, "Gateway Test CA")
+	serverCert := ca.issue(t, "gateway", []string{"gateway.internal"}, nil)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(serverCert.PrivateKey)
+	require.NoError(t, err)
+	certPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: serverCert.Certificate[0]}))
+	keyPEM := string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}))
+
+	var vaultReads atomic.Int32
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "vault-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.URL.Path != "/v1/secret/data/gateway" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		vaultReads.Add(1)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{
+				"data":     map[string]interface{}{"tls_cert": certPEM, "tls_key": keyPEM},
+				"metadata": map[string]interface{}{"version": 3},
+			},
+		})
+	}))
+	defer vault.Close()
+	t.Cleanup(func() { secrets.UseVault(nil) })
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "redis_password"), []byte("s3cret\n"), 0o600))
+	t.Setenv("TEST_VAULT_TOKEN", "vault-token")
+	// The tenant ID is the secret the dump must not leak; it shares no
+	// text with config keys
+	tenant := "tenant-7c1f9e"
+	t.Setenv("TEST_TENANT", tenant)
+
+	path := filepath.Join(dir, "config.yaml")
+	writeConfig := func(redisPassword string) {
+		require.NoError(t, os.WriteFile(path, []byte(`
+server:
+  tls:
+    cert: "vault://secret/data/gateway#tls_cert"
+    key: "vault://secret/data/gateway#tls_key"
+secrets:
+  vault:
+    address: "`+vault.URL+`"
+    token: "env://TEST_VAULT_TOKEN"
+redis:
+  password: "`+redisPassword+`"
+services:
+  orders:
+    name: "orders"
+    url: "http://orders:8008"
+    timeout: "5s"
+    headers:
+      x-tenant: "env://TEST_TENANT"
+routes:
+  - path: "/api/orders/*"
+    service_name: "orders"
+`), 0o600))
+	}
+	writeConfig("file://" + filepath.Join(dir, "redis_password"))
+
+	manager := config.NewManager()
+	require.NoError(t, manager.LoadConfig(path))
+	require.NoError(t, manager.ValidateConfig())
+	cfg := manager.GetConfig()
+
+	t.Run("References are replaced with their secrets", func(t *testing.T) {
+		assert.Equal(t, "s3cret", cfg.Redis.Password)
+		assert.Equal(t, "vault-token", cfg.Secrets.Vault.Token)
+		assert.Equal(t, tenant, cfg.Services["orders"].Headers["x-tenant"])
+		assert.Equal(t, keyPEM, cfg.Server.TLS.Key)
+
+		certificate, err := tlsconfig.LoadCertificate(cfg.Server.TLS)
+		require.NoError(t, err)
+		assert.NotNil(t, certificate.Leaf())
+		assert.Empty(t, certificate.Files(), "certificates held in the config have no files to watch")
+
+		// Both fields came from one read
+		assert.Equal(t, int32(1), vaultReads.Load())
+	})
+
+	t.Run("References resolved on use can point into Vault", func(t *testing.T) {
+		secret, err := secrets.Resolve("vault://secret/data/gateway#tls_cert")
+		require.NoError(t, err)
+		assert.Equal(t, certPEM, secret)
+
+		_, err = secrets.Resolve("vault://secret/data/gateway#missing")
+		assert.ErrorContains(t, err, "has no field missing")
+		// The secret is read once more and then cached
+		assert.Equal(t, int32(2), vaultReads.Load())
+
+		secret, err = secrets.Resolve("env://TEST_TENANT")
+		require.NoError(t, err)
+		assert.Equal(t, tenant, secret)
+	})
+
+	t.Run("Resolved values are redacted in the config dump", func(t *testing.T) {
+		assert.ElementsMatch(t, []string{
+			"secrets.vault.token", "server.tls.cert", "server.tls.key",
+			"services.orders.headers.x-tenant", "redis.password",
+		}, manager.SecretPaths())
+
+		data, err := json.Marshal(config.Redact(cfg, manager.SecretPaths()...))
+		require.NoError(t, err)
+		dump := string(data)
+		assert.NotContains(t, dump, tenant)
+		assert.NotContains(t, dump, "BEGIN CERTIFICATE")
+		assert.Contains(t, dump, `"x-tenant":"[REDACTED]"`)
+	})
+
+	t.Run("Unresolvable references fail the load", func(t *testing.T) {
+		writeConfig("env://TEST_UNSET_REDIS_PASSWORD")
+		_, err := manager.Reload()
+		assert.ErrorContains(t, err, "failed to resolve redis.password")
+		assert.Equal(t, "s3cret", manager.GetConfig().Redis.Password)
+		assert.False(t, strings.Contains(err.Error(), "vault-token"))
+	})
+}
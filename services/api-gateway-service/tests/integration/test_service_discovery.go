@@ -1,14 +1,29 @@
 package integration
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"gateway/internal/config"
+	"gateway/internal/events"
+	"gateway/internal/handlers"
+	"gateway/internal/models"
+	"gateway/internal/proxy"
+	"gateway/internal/registry"
+
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestServiceDiscoveryAndHealthChecks(t *testing.T) {
@@ -136,29 +151,88 @@ func TestHealthCheckPropagation(t *testing.T) {
 func TestServiceHealthCheckInterval(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
-	// This test verifies that health checks run periodically
-	// For now, it just ensures the structure exists
+	var failing int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&failing) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
 
-	router := gin.New()
+	serviceRegistry := registry.NewServiceRegistry()
+	service := *models.NewServiceConfig("inventory", upstream.URL, time.Second)
+	service.HealthInterval = time.Second
+	serviceRegistry.RegisterService(service)
 
-	// Mock metrics endpoint to show health check timing
+	router := gin.New()
 	router.GET("/gateway/metrics", func(c *gin.Context) {
-		// TODO: Implement metrics showing last health check times
-		c.JSON(http.StatusNotImplemented, gin.H{"error": "metrics not implemented"})
+		c.JSON(http.StatusOK, gin.H{"health_checks": serviceRegistry.HealthChecks()})
 	})
-
-	t.Run("Health checks should run periodically", func(t *testing.T) {
-		req, err := http.NewRequest("GET", "/gateway/metrics", nil)
-		assert.NoError(t, err)
-
+	healthChecks := func(t *testing.T) registry.HealthCheckStats {
+		req, _ := http.NewRequest("GET", "/gateway/metrics", nil)
 		w := httptest.NewRecorder()
 		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
 
-		// This should fail until implementation
-		assert.Equal(t, http.StatusNotImplemented, w.Code)
+		var body struct {
+			HealthChecks []registry.HealthCheckStats `json:"health_checks"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		if !assert.Len(t, body.HealthChecks, 1) {
+			return registry.HealthCheckStats{}
+		}
+		return body.HealthChecks[0]
+	}
 
-		// When implemented, should show evidence of periodic health checks
-		// in the metrics data
+	t.Run("An unchecked service has no last check", func(t *testing.T) {
+		stats := healthChecks(t)
+		assert.Equal(t, "inventory", stats.Service)
+		assert.Nil(t, stats.LastChecked)
+		assert.Zero(t, stats.Checks)
+	})
+
+	serviceRegistry.StartHealthChecking(time.Hour)
+	defer serviceRegistry.StopHealthChecking()
+
+	t.Run("Health checks run periodically", func(t *testing.T) {
+		var first time.Time
+		assert.Eventually(t, func() bool {
+			stats := healthChecks(t)
+			if stats.LastChecked != nil && first.IsZero() {
+				first = *stats.LastChecked
+			}
+			return stats.Checks >= 2
+		}, 5*time.Second, 50*time.Millisecond)
+
+		stats := healthChecks(t)
+		if assert.NotNil(t, stats.LastChecked) {
+			assert.True(t, stats.LastChecked.After(first))
+		}
+		assert.Greater(t, stats.ResponseTime, 0.0)
+		assert.Zero(t, stats.FailedChecks)
+		assert.Zero(t, stats.ConsecutiveFailures)
+		assert.Equal(t, int64(1), stats.Transitions[models.ServiceHealthy])
+	})
+
+	t.Run("Failures and transitions are counted", func(t *testing.T) {
+		atomic.StoreInt32(&failing, 1)
+		assert.Eventually(t, func() bool {
+			return healthChecks(t).ConsecutiveFailures >= 2
+		}, 5*time.Second, 50*time.Millisecond)
+
+		stats := healthChecks(t)
+		assert.Equal(t, models.ServiceUnhealthy, stats.Status)
+		assert.Equal(t, int64(stats.ConsecutiveFailures), stats.FailedChecks)
+		assert.Equal(t, int64(1), stats.Transitions[models.ServiceUnhealthy])
+		assert.Equal(t, int64(1), stats.Transitions[models.ServiceHealthy])
+
+		atomic.StoreInt32(&failing, 0)
+		assert.Eventually(t, func() bool {
+			return healthChecks(t).Transitions[models.ServiceHealthy] == 2
+		}, 5*time.Second, 50*time.Millisecond)
+		assert.Zero(t, healthChecks(t).ConsecutiveFailures)
 	})
 }
 
@@ -184,4 +258,424 @@ func TestServiceDiscoveryUpdates(t *testing.T) {
 
 		// When implemented, should allow dynamic service registration updates
 	})
-}
\ No newline at end of file
+}
+
+func TestSelfRegistrationLifecycle(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(reloadBaseConfig), 0o600))
+	manager := config.NewManager()
+	require.NoError(t, manager.LoadConfig(path))
+
+	serviceRegistry := registry.NewServiceRegistry()
+	orders := *models.NewServiceConfig("orders", "http://orders:8008", time.Second)
+	serviceRegistry.RegisterService(orders)
+	serviceRegistry.RegisterRoute(*models.NewRouteConfig("/api/orders/*", "orders"))
+
+	router := gin.New()
+	handlers.NewRegistrationHandler(serviceRegistry, manager, models.RegistrationConfig{
+		Enabled:    true,
+		Token:      "secret",
+		DefaultTTL: 50 * time.Millisecond,
+		MaxTTL:     time.Second,
+	}).Register(router.Group("/gateway/register"))
+
+	send := func(method, path string, body interface{}, token string) *httptest.ResponseRecorder {
+		var payload []byte
+		if body != nil {
+			payload, _ = json.Marshal(body)
+		}
+		req, err := http.NewRequest(method, path, bytes.NewReader(payload))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set(handlers.RegistrationTokenHeader, token)
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	registration := map[string]interface{}{
+		"name": "inventory",
+		"url":  "http://inventory:9000",
+		"routes": []map[string]interface{}{
+			{"path": "/api/inventory/*"},
+		},
+	}
+
+	t.Run("Registration requires the shared token", func(t *testing.T) {
+		w := send("POST", "/gateway/register", registration, "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		w = send("POST", "/gateway/register", registration, "wrong")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Without a token every call is refused", func(t *testing.T) {
+		open := gin.New()
+		handlers.NewRegistrationHandler(serviceRegistry, manager, models.RegistrationConfig{
+			Enabled: true, DefaultTTL: time.Second, MaxTTL: time.Second,
+		}).Register(open.Group("/gateway/register"))
+		req := httptest.NewRequest("GET", "/gateway/register", nil)
+		w := httptest.NewRecorder()
+		open.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		content := reloadBaseConfig + "registration:\n  enabled: true\n"
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		tokenless := config.NewManager()
+		require.NoError(t, tokenless.LoadConfig(path))
+		err := tokenless.ValidateConfig()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "registration token is required")
+	})
+
+	t.Run("Overlapping routes are refused", func(t *testing.T) {
+		for _, routes := range [][]map[string]interface{}{
+			{{"path": "/api/orders/*"}},
+			{{"path": "/api/*"}},
+			{{"path": "/api/stock/*"}, {"path": "/api/stock/items"}},
+		} {
+			w := send("POST", "/gateway/register", map[string]interface{}{
+				"name": "stock", "url": "http://stock:9000", "routes": routes,
+			}, "secret")
+			assert.Equal(t, http.StatusConflict, w.Code)
+			assert.Contains(t, w.Body.String(), "conflicts")
+		}
+		_, exists := serviceRegistry.GetService("stock")
+		assert.False(t, exists)
+		_, service := serviceRegistry.FindRoute("GET", "/api/orders/1")
+		assert.Equal(t, "orders", service.Name)
+	})
+
+	t.Run("Invalid routes are refused", func(t *testing.T) {
+		w := send("POST", "/gateway/register", map[string]interface{}{
+			"name": "stock", "url": "http://stock:9000",
+			"routes": []map[string]interface{}{{"path": "/api/stock/*", "auth_required": true, "auth_provider": "unknown"}},
+		}, "secret")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "unknown")
+		_, exists := serviceRegistry.GetService("stock")
+		assert.False(t, exists)
+	})
+
+	t.Run("Registered service and routes become routable", func(t *testing.T) {
+		w := send("POST", "/gateway/register", registration, "secret")
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		route, service := serviceRegistry.FindRoute("GET", "/api/inventory/items")
+		assert.NotNil(t, route)
+		assert.NotNil(t, service)
+
+		// Registering again replaces the service's own routes
+		w = send("POST", "/gateway/register", registration, "secret")
+		assert.Equal(t, http.StatusCreated, w.Code)
+	})
+
+	t.Run("Heartbeats keep the registration alive", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			time.Sleep(30 * time.Millisecond)
+			w := send("PUT", "/gateway/register/inventory/heartbeat", nil, "secret")
+			assert.Equal(t, http.StatusOK, w.Code)
+			serviceRegistry.ExpireLeases()
+		}
+
+		_, exists := serviceRegistry.GetService("inventory")
+		assert.True(t, exists)
+	})
+
+	t.Run("Missed heartbeats remove the service and its routes", func(t *testing.T) {
+		time.Sleep(80 * time.Millisecond)
+		assert.Equal(t, []string{"inventory"}, serviceRegistry.ExpireLeases())
+
+		_, exists := serviceRegistry.GetService("inventory")
+		assert.False(t, exists)
+		route, _ := serviceRegistry.FindRoute("GET", "/api/inventory/items")
+		assert.Nil(t, route)
+
+		w := send("PUT", "/gateway/register/inventory/heartbeat", nil, "secret")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestPerServiceHealthCheckConfig(t *testing.T) {
+	var probes int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead && r.URL.Path == "/status" {
+			atomic.AddInt32(&probes, 1)
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte(`{"state": "ready"}`))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer upstream.Close()
+
+	serviceRegistry := registry.NewServiceRegistry()
+
+	ready := *models.NewServiceConfig("ready", upstream.URL, time.Second)
+	ready.HealthPath = "/status"
+	ready.HealthInterval = time.Second
+	ready.HealthExpectedStatus = []int{http.StatusAccepted}
+	ready.HealthExpectedBody = `"ready"`
+	serviceRegistry.RegisterService(ready)
+
+	wrongBody := ready
+	wrongBody.Name = "wrong-body"
+	wrongBody.HealthExpectedBody = "degraded"
+	serviceRegistry.RegisterService(wrongBody)
+
+	wrongMethod := ready
+	wrongMethod.Name = "wrong-method"
+	wrongMethod.HealthMethod = http.MethodHead
+	serviceRegistry.RegisterService(wrongMethod)
+
+	// The default interval is far away, so repeated probes prove the
+	// per-service interval is used
+	serviceRegistry.StartHealthChecking(time.Hour)
+	defer serviceRegistry.StopHealthChecking()
+
+	time.Sleep(2500 * time.Millisecond)
+
+	services := serviceRegistry.GetAllServices()
+	assert.Equal(t, models.ServiceHealthy, services["ready"].Status)
+	assert.Equal(t, models.ServiceUnhealthy, services["wrong-body"].Status)
+	assert.Equal(t, models.ServiceUnhealthy, services["wrong-method"].Status)
+	assert.GreaterOrEqual(t, atomic.LoadInt32(&probes), int32(4))
+}
+
+func TestPassiveHealthFromProxyTraffic(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health":
+			w.WriteHeader(http.StatusOK)
+		case "/orders":
+			assert.Equal(t, "gateway", r.Header.Get("X-Upstream-Tag"))
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer upstream.Close()
+
+	serviceRegistry := registry.NewServiceRegistry()
+	serviceRegistry.SetHealthCheckConfig(models.HealthCheckConfig{
+		Passive: models.PassiveHealthConfig{
+			Enabled:      true,
+			Window:       time.Minute,
+			MinRequests:  5,
+			FailureRatio: 0.5,
+		},
+	})
+
+	service := *models.NewServiceConfig("orders", upstream.URL, time.Second)
+	service.Headers["X-Upstream-Tag"] = "gateway"
+	serviceRegistry.RegisterService(service)
+	route := models.NewRouteConfig("/api/orders/*", "orders")
+	route.StripPrefix = true
+	serviceRegistry.RegisterRoute(*route)
+
+	serviceRegistry.StartHealthChecking(time.Hour)
+	defer serviceRegistry.StopHealthChecking()
+	time.Sleep(200 * time.Millisecond)
+
+	services := serviceRegistry.GetAllServices()
+	assert.Equal(t, models.ServiceHealthy, services["orders"].Status)
+
+	router := gin.New()
+	router.Any("/api/*proxyPath", proxy.New(serviceRegistry).Handle)
+
+	for i := 0; i < 5; i++ {
+		req, _ := http.NewRequest("GET", "/api/orders/orders", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	}
+
+	services = serviceRegistry.GetAllServices()
+	assert.Equal(t, models.ServiceDegraded, services["orders"].Status)
+}
+
+func TestHealthEventsAndWebhooks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var failing int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&failing) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	delivered := make(chan map[string]string, 4)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]string
+		json.NewDecoder(r.Body).Decode(&payload)
+		delivered <- payload
+	}))
+	defer receiver.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	eventLog := events.NewLog(10)
+	notifier := events.NewNotifier([]models.WebhookConfig{{URL: receiver.URL, Format: models.WebhookSlack}})
+	go notifier.Run(ctx)
+
+	serviceRegistry := registry.NewServiceRegistry()
+	serviceRegistry.OnHealthEvent(func(event models.HealthEvent) {
+		event = eventLog.Append(event)
+		if event.From != models.ServiceUnknown {
+			notifier.Notify(event)
+		}
+	})
+
+	service := *models.NewServiceConfig("cart", upstream.URL, time.Second)
+	service.HealthInterval = time.Second
+	serviceRegistry.RegisterService(service)
+	serviceRegistry.StartHealthChecking(time.Hour)
+	defer serviceRegistry.StopHealthChecking()
+
+	time.Sleep(200 * time.Millisecond)
+	atomic.StoreInt32(&failing, 1)
+
+	select {
+	case payload := <-delivered:
+		assert.Contains(t, payload["text"], "cart is now unhealthy (was healthy)")
+		assert.Contains(t, payload["text"], "returned 503")
+	case <-time.After(3 * time.Second):
+		t.Fatal("webhook was not called")
+	}
+
+	router := gin.New()
+	handlers.NewEventsHandler(eventLog, nil).Register(router.Group("/gateway/events"))
+
+	req, _ := http.NewRequest("GET", "/gateway/events?service=cart", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Events []models.HealthEvent `json:"events"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	if assert.Len(t, response.Events, 2) {
+		assert.Equal(t, models.ServiceUnknown, response.Events[0].From)
+		assert.Equal(t, models.ServiceHealthy, response.Events[0].To)
+		assert.Equal(t, models.ServiceUnhealthy, response.Events[1].To)
+		assert.Equal(t, int64(2), response.Events[1].ID)
+	}
+}
+
+func TestHealthCheckConcurrencyLimit(t *testing.T) {
+	var inFlight, maxInFlight int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			observed := atomic.LoadInt32(&maxInFlight)
+			if current <= observed || atomic.CompareAndSwapInt32(&maxInFlight, observed, current) {
+				break
+			}
+		}
+		time.Sleep(100 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	serviceRegistry := registry.NewServiceRegistry()
+	serviceRegistry.SetHealthCheckConfig(models.HealthCheckConfig{
+		MaxConcurrency: 3,
+	})
+	for i := 0; i < 12; i++ {
+		serviceRegistry.RegisterService(*models.NewServiceConfig(fmt.Sprintf("svc-%d", i), upstream.URL, time.Second))
+	}
+
+	serviceRegistry.StartHealthChecking(time.Hour)
+	defer serviceRegistry.StopHealthChecking()
+
+	// 12 probes of 100ms on 3 workers need about 400ms
+	time.Sleep(700 * time.Millisecond)
+
+	assert.Equal(t, int32(3), atomic.LoadInt32(&maxInFlight))
+	for name, service := range serviceRegistry.GetAllServices() {
+		assert.Equal(t, models.ServiceHealthy, service.Status, name)
+	}
+}
+
+func TestLatencySLATracking(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(60 * time.Millisecond)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	serviceRegistry := registry.NewServiceRegistry()
+	serviceRegistry.SetHealthCheckConfig(models.HealthCheckConfig{
+		SLA: models.LatencySLAConfig{
+			Window:      time.Minute,
+			MinRequests: 4,
+			AlertRatio:  0.5,
+		},
+	})
+
+	var events []models.HealthEvent
+	var mutex sync.Mutex
+	serviceRegistry.OnHealthEvent(func(event models.HealthEvent) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		events = append(events, event)
+	})
+
+	service := *models.NewServiceConfig("search", upstream.URL, time.Second)
+	service.LatencySLA = 30 * time.Millisecond
+	serviceRegistry.RegisterService(service)
+	route := models.NewRouteConfig("/api/search/*", "search")
+	route.StripPrefix = true
+	serviceRegistry.RegisterRoute(*route)
+
+	router := gin.New()
+	router.Any("/api/*proxyPath", proxy.New(serviceRegistry).Handle)
+
+	send := func(path string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/api/search"+path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("/fast")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get(proxy.SLAHeader))
+
+	w = send("/slow")
+	assert.Equal(t, "exceeded", w.Header().Get(proxy.SLAHeader))
+	assert.NotEmpty(t, w.Header().Get(proxy.LatencyHeader))
+
+	// Two of three responses were fast: below min_requests, no alert yet
+	send("/slow")
+	assert.False(t, serviceRegistry.GetAllServices()["search"].SLABreached)
+
+	send("/fast")
+	stats := serviceRegistry.GetAllServices()["search"]
+	assert.True(t, stats.SLABreached)
+	assert.InDelta(t, 0.5, stats.SLAViolationRate, 0.01)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, models.EventSLABreached, events[0].Type)
+		assert.Equal(t, "search", events[0].Service)
+	}
+}
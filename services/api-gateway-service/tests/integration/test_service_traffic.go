@@ -0,0 +1,120 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gateway/internal/handlers"
+	"gateway/internal/models"
+	"gateway/internal/proxy"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceDisableAndDrain(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	unblock := make(chan struct{})
+	arrived := make(chan struct{}, 10)
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		arrived <- struct{}{}
+		<-unblock
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer slow.Close()
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer fast.Close()
+
+	serviceRegistry := registry.NewServiceRegistry()
+	serviceRegistry.RegisterService(*models.NewServiceConfig("reports", slow.URL, 5*time.Second))
+	serviceRegistry.RegisterService(*models.NewServiceConfig("products", fast.URL, time.Second))
+	serviceRegistry.RegisterRoute(*models.NewRouteConfig("/api/reports/*", "reports"))
+	serviceRegistry.RegisterRoute(*models.NewRouteConfig("/api/products/*", "products"))
+
+	router := gin.New()
+	router.Any("/api/*path", proxy.New(serviceRegistry).Handle)
+	handlers.NewTrafficHandler(serviceRegistry).Register(router.Group("/gateway/services"))
+	send := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+	traffic := func(w *httptest.ResponseRecorder) registry.TrafficStatus {
+		var status registry.TrafficStatus
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+		return status
+	}
+
+	t.Run("Disabled services get no new requests", func(t *testing.T) {
+		w := send(http.MethodPost, "/gateway/services/products/disable")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, registry.TrafficDisabled, traffic(w).State)
+
+		w = send(http.MethodGet, "/api/products/1")
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Contains(t, w.Body.String(), "Service products is unavailable")
+
+		w = send(http.MethodPost, "/gateway/services/products/enable")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, registry.TrafficServing, traffic(w).State)
+		assert.Equal(t, http.StatusOK, send(http.MethodGet, "/api/products/1").Code)
+	})
+
+	t.Run("Draining services finish the requests in flight", func(t *testing.T) {
+		done := make(chan int, 2)
+		for i := 0; i < 2; i++ {
+			go func() { done <- send(http.MethodGet, "/api/reports/daily").Code }()
+			<-arrived
+		}
+
+		w := send(http.MethodPost, "/gateway/services/reports/drain")
+		require.Equal(t, http.StatusAccepted, w.Code)
+		status := traffic(w)
+		assert.Equal(t, registry.TrafficDraining, status.State)
+		assert.Equal(t, 2, status.InFlight)
+
+		assert.Equal(t, http.StatusServiceUnavailable, send(http.MethodGet, "/api/reports/weekly").Code)
+		assert.Equal(t, http.StatusOK, send(http.MethodGet, "/api/products/1").Code)
+
+		// The wait runs out while the requests are still in flight
+		w = send(http.MethodPost, "/gateway/services/reports/drain?wait=20ms")
+		assert.Equal(t, http.StatusAccepted, w.Code)
+
+		drained := make(chan *httptest.ResponseRecorder, 1)
+		go func() { drained <- send(http.MethodPost, "/gateway/services/reports/drain?wait=5s") }()
+		close(unblock)
+		assert.Equal(t, http.StatusOK, <-done)
+		assert.Equal(t, http.StatusOK, <-done)
+
+		w = <-drained
+		require.Equal(t, http.StatusOK, w.Code)
+		status = traffic(w)
+		assert.Equal(t, registry.TrafficDrained, status.State)
+		assert.Equal(t, 0, status.InFlight)
+		require.NotNil(t, status.IdleSince)
+
+		assert.Equal(t, registry.TrafficDrained, traffic(send(http.MethodGet, "/gateway/services/reports/traffic")).State)
+	})
+
+	t.Run("Holds survive the service being replaced", func(t *testing.T) {
+		serviceRegistry.RegisterService(*models.NewServiceConfig("reports", slow.URL, 10*time.Second))
+		assert.Equal(t, http.StatusServiceUnavailable, send(http.MethodGet, "/api/reports/daily").Code)
+
+		send(http.MethodPost, "/gateway/services/reports/enable")
+		assert.Equal(t, http.StatusOK, send(http.MethodGet, "/api/reports/daily").Code)
+	})
+
+	t.Run("Unknown services and waits are refused", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, send(http.MethodPost, "/gateway/services/billing/drain").Code)
+		assert.Equal(t, http.StatusNotFound, send(http.MethodGet, "/gateway/services/billing/traffic").Code)
+		assert.Equal(t, http.StatusBadRequest, send(http.MethodPost, "/gateway/services/reports/drain?wait=1h").Code)
+	})
+}
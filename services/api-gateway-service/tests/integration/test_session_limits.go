@@ -0,0 +1,331 @@
+package integration
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gateway/internal/config"
+	"gateway/internal/session"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRedis speaks enough RESP2 for the session store: strings, sets,
+// sorted sets and MULTI/EXEC. Expiry is kept but only checked on reads.
+type fakeRedis struct {
+	mutex   sync.Mutex
+	strings map[string]string
+	sets    map[string]map[string]bool
+	zsets   map[string]map[string]float64
+	expires map[string]time.Time
+}
+
+func newFakeRedis(t *testing.T) *redis.Client {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	f := &fakeRedis{
+		strings: make(map[string]string),
+		sets:    make(map[string]map[string]bool),
+		zsets:   make(map[string]map[string]float64),
+		expires: make(map[string]time.Time),
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+
+	client := redis.NewClient(&redis.Options{Addr: listener.Addr().String()})
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	var queued [][]string
+	inMulti := false
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+		name := strings.ToUpper(args[0])
+		switch {
+		case name == "MULTI":
+			inMulti, queued = true, nil
+			io.WriteString(conn, "+OK\r\n")
+		case name == "EXEC":
+			replies := make([]string, 0, len(queued))
+			for _, command := range queued {
+				replies = append(replies, f.run(command))
+			}
+			inMulti = false
+			io.WriteString(conn, "*"+strconv.Itoa(len(replies))+"\r\n"+strings.Join(replies, ""))
+		case inMulti:
+			queued = append(queued, args)
+			io.WriteString(conn, "+QUEUED\r\n")
+		default:
+			io.WriteString(conn, f.run(args))
+		}
+	}
+}
+
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, count)
+	for i := range args {
+		header, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		length, err := strconv.Atoi(strings.TrimSpace(header[1:]))
+		if err != nil {
+			return nil, err
+		}
+		arg := make([]byte, length+2)
+		if _, err := io.ReadFull(reader, arg); err != nil {
+			return nil, err
+		}
+		args[i] = string(arg[:length])
+	}
+	return args, nil
+}
+
+func bulk(value string) string {
+	return "$" + strconv.Itoa(len(value)) + "\r\n" + value + "\r\n"
+}
+
+func integer(n int) string {
+	return ":" + strconv.Itoa(n) + "\r\n"
+}
+
+func array(values []string) string {
+	reply := "*" + strconv.Itoa(len(values)) + "\r\n"
+	for _, value := range values {
+		reply += bulk(value)
+	}
+	return reply
+}
+
+func (f *fakeRedis) expire(key string) {
+	if at, ok := f.expires[key]; ok && !time.Now().Before(at) {
+		delete(f.strings, key)
+		delete(f.sets, key)
+		delete(f.zsets, key)
+		delete(f.expires, key)
+	}
+}
+
+func (f *fakeRedis) run(args []string) string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if len(args) > 1 {
+		f.expire(args[1])
+	}
+
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return "+PONG\r\n"
+	case "GET":
+		value, ok := f.strings[args[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return bulk(value)
+	case "SET":
+		f.strings[args[1]] = args[2]
+		delete(f.expires, args[1])
+		if len(args) == 5 {
+			n, _ := strconv.Atoi(args[4])
+			unit := time.Second
+			if strings.ToUpper(args[3]) == "PX" {
+				unit = time.Millisecond
+			}
+			f.expires[args[1]] = time.Now().Add(time.Duration(n) * unit)
+		}
+		return "+OK\r\n"
+	case "DEL":
+		_, existed := f.strings[args[1]]
+		delete(f.strings, args[1])
+		delete(f.expires, args[1])
+		if existed {
+			return integer(1)
+		}
+		return integer(0)
+	case "EXISTS":
+		if _, ok := f.strings[args[1]]; ok {
+			return integer(1)
+		}
+		return integer(0)
+	case "SADD":
+		if f.sets[args[1]] == nil {
+			f.sets[args[1]] = make(map[string]bool)
+		}
+		f.sets[args[1]][args[2]] = true
+		return integer(1)
+	case "SREM":
+		delete(f.sets[args[1]], args[2])
+		return integer(1)
+	case "SMEMBERS":
+		members := make([]string, 0, len(f.sets[args[1]]))
+		for member := range f.sets[args[1]] {
+			members = append(members, member)
+		}
+		return array(members)
+	case "ZADD":
+		if f.zsets[args[1]] == nil {
+			f.zsets[args[1]] = make(map[string]float64)
+		}
+		score, _ := strconv.ParseFloat(args[2], 64)
+		f.zsets[args[1]][args[3]] = score
+		return integer(1)
+	case "ZREM":
+		delete(f.zsets[args[1]], args[2])
+		return integer(1)
+	case "ZRANGE":
+		zset := f.zsets[args[1]]
+		members := make([]string, 0, len(zset))
+		for member := range zset {
+			members = append(members, member)
+		}
+		sort.Slice(members, func(i, j int) bool {
+			if zset[members[i]] != zset[members[j]] {
+				return zset[members[i]] < zset[members[j]]
+			}
+			return members[i] < members[j]
+		})
+		return array(members)
+	case "EXPIREAT", "PEXPIREAT":
+		n, _ := strconv.ParseInt(args[2], 10, 64)
+		at := time.Unix(n, 0)
+		if strings.ToUpper(args[0]) == "PEXPIREAT" {
+			at = time.UnixMilli(n)
+		}
+		f.expires[args[1]] = at
+		return integer(1)
+	default:
+		return fmt.Sprintf("-ERR unknown command '%s'\r\n", args[0])
+	}
+}
+
+func TestSessionUserLimit(t *testing.T) {
+	ctx := context.Background()
+	key, _ := session.GenerateKey()
+	keyring, _ := session.NewKeyring([]string{key})
+	cookie := session.CookieOptions{Name: "gw_session"}
+
+	// login saves a session for user and applies the limit, as the login
+	// handlers do
+	login := func(t *testing.T, manager *session.Manager, user string, createdAt time.Time) *session.Session {
+		sess, err := manager.New()
+		require.NoError(t, err)
+		sess.UserID = user
+		sess.CreatedAt = createdAt
+		req := httptest.NewRequest(http.MethodPost, "/auth/login", nil)
+		require.NoError(t, manager.Save(httptest.NewRecorder(), req, sess))
+		_, err = manager.EnforceUserLimit(ctx, sess)
+		require.NoError(t, err)
+		return sess
+	}
+	alive := func(manager *session.Manager, sess *session.Session) bool {
+		_, err := manager.Get(ctx, sess.ID)
+		return err == nil
+	}
+
+	t.Run("The oldest sessions are ended first", func(t *testing.T) {
+		manager := session.NewManager(session.NewMemoryStore(), keyring, cookie, time.Hour)
+		manager.SetMaxPerUser(2)
+
+		start := time.Now().Add(-time.Minute)
+		first := login(t, manager, "alice", start)
+		second := login(t, manager, "alice", start.Add(time.Second))
+		other := login(t, manager, "bob", start.Add(2*time.Second))
+		assert.True(t, alive(manager, first))
+
+		third := login(t, manager, "alice", start.Add(3*time.Second))
+		assert.False(t, alive(manager, first))
+		assert.True(t, alive(manager, second))
+		assert.True(t, alive(manager, third))
+		assert.True(t, alive(manager, other), "other users' sessions are not counted")
+
+		// A login older than the rest still keeps its own session
+		late := login(t, manager, "alice", start.Add(-time.Second))
+		assert.True(t, alive(manager, late))
+		assert.False(t, alive(manager, second))
+		assert.True(t, alive(manager, third))
+	})
+
+	t.Run("Replicas sharing Redis share the limit", func(t *testing.T) {
+		client := newFakeRedis(t)
+		replicaA := session.NewManager(session.NewRedisStore(client, "gw:"), keyring, cookie, time.Hour)
+		replicaB := session.NewManager(session.NewRedisStore(client, "gw:"), keyring, cookie, time.Hour)
+		replicaA.SetMaxPerUser(2)
+		replicaB.SetMaxPerUser(2)
+
+		start := time.Now().Add(-time.Minute)
+		first := login(t, replicaA, "alice", start)
+		second := login(t, replicaB, "alice", start.Add(time.Second))
+		third := login(t, replicaA, "alice", start.Add(2*time.Second))
+		assert.False(t, alive(replicaB, first))
+		assert.True(t, alive(replicaA, second))
+
+		fourth := login(t, replicaB, "alice", start.Add(3*time.Second))
+		assert.False(t, alive(replicaA, second))
+		assert.True(t, alive(replicaA, third))
+		assert.True(t, alive(replicaA, fourth))
+
+		// Logging out frees a slot without ending the other session
+		require.NoError(t, replicaA.Invalidate(ctx, third.ID))
+		fifth := login(t, replicaB, "alice", start.Add(4*time.Second))
+		assert.True(t, alive(replicaA, fourth))
+		assert.True(t, alive(replicaA, fifth))
+	})
+
+	t.Run("Cookie sessions cannot be limited", func(t *testing.T) {
+		manager := session.NewManager(nil, keyring, cookie, time.Hour)
+		manager.SetMaxPerUser(2)
+		sess, err := manager.New()
+		require.NoError(t, err)
+		sess.UserID = "alice"
+		_, err = manager.EnforceUserLimit(ctx, sess)
+		assert.ErrorIs(t, err, session.ErrNoUserLimit)
+
+		content := reloadBaseConfig + "session:\n  store: cookie\n  max_per_user: 3\n"
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		configManager := config.NewManager()
+		require.NoError(t, configManager.LoadConfig(path))
+		err = configManager.ValidateConfig()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "session max_per_user needs the memory or redis store")
+	})
+}
@@ -0,0 +1,134 @@
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gateway/internal/handlers"
+	"gateway/internal/middleware"
+	"gateway/internal/session"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionKeyRotation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+
+	oldKey, _ := session.GenerateKey()
+	newKey, _ := session.GenerateKey()
+	keyring := func(keys ...string) *session.Keyring {
+		ring, err := session.NewKeyring(keys)
+		require.NoError(t, err)
+		return ring
+	}
+	before := keyring(oldKey)
+	rotated := keyring(newKey, oldKey)
+	retired := keyring(newKey)
+	cookie := session.CookieOptions{Name: "gw_session"}
+
+	// save stores a session for user with manager and returns its cookie
+	save := func(t *testing.T, manager *session.Manager, user string) (*session.Session, *http.Cookie) {
+		sess, err := manager.New()
+		require.NoError(t, err)
+		sess.UserID = user
+		sess.Set("cart", "3 items")
+		w := httptest.NewRecorder()
+		require.NoError(t, manager.Save(w, httptest.NewRequest(http.MethodPost, "/login", nil), sess))
+		cookies := w.Result().Cookies()
+		require.Len(t, cookies, 1)
+		return sess, cookies[0]
+	}
+
+	t.Run("Server-side sessions are re-sealed when read", func(t *testing.T) {
+		store := session.NewMemoryStore()
+		sess, _ := save(t, session.NewManager(store, before, cookie, time.Hour), "alice")
+
+		_, err := session.NewManager(store, retired, cookie, time.Hour).Get(ctx, sess.ID)
+		assert.ErrorIs(t, err, session.ErrNotFound, "the retired key alone cannot open it yet")
+
+		loaded, err := session.NewManager(store, rotated, cookie, time.Hour).Get(ctx, sess.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "3 items", loaded.Get("cart"))
+		assert.False(t, loaded.Stale())
+
+		loaded, err = session.NewManager(store, retired, cookie, time.Hour).Get(ctx, sess.ID)
+		require.NoError(t, err, "the stored session now uses the new key")
+		assert.Equal(t, "alice", loaded.UserID)
+
+		_, err = session.NewManager(store, before, cookie, time.Hour).Get(ctx, sess.ID)
+		assert.ErrorIs(t, err, session.ErrNotFound, "the old key no longer opens it")
+	})
+
+	t.Run("Cookie sessions are re-sealed on the way through", func(t *testing.T) {
+		_, oldCookie := save(t, session.NewManager(nil, before, cookie, time.Hour), "alice")
+
+		var seen *session.Session
+		router := gin.New()
+		router.Use(middleware.LoadSession(session.NewManager(nil, rotated, cookie, time.Hour)))
+		router.GET("/api/cart", func(c *gin.Context) {
+			seen, _ = middleware.SessionFromContext(c)
+			c.Status(http.StatusOK)
+		})
+		req := httptest.NewRequest(http.MethodGet, "/api/cart", nil)
+		req.AddCookie(oldCookie)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		require.NotNil(t, seen, "sessions sealed with the old key still open")
+		assert.Equal(t, "3 items", seen.Get("cart"))
+		cookies := w.Result().Cookies()
+		require.Len(t, cookies, 1, "the session is sealed again")
+		assert.NotEqual(t, oldCookie.Value, cookies[0].Value)
+
+		open := func(ring *session.Keyring, c *http.Cookie) error {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.AddCookie(c)
+			_, err := session.NewManager(nil, ring, cookie, time.Hour).Load(req)
+			return err
+		}
+		assert.NoError(t, open(retired, cookies[0]))
+		assert.ErrorIs(t, open(retired, oldCookie), session.ErrNotFound)
+		assert.ErrorIs(t, open(before, cookies[0]), session.ErrNotFound)
+	})
+
+	t.Run("The sessions admin API lists and ends old sessions", func(t *testing.T) {
+		store := session.NewMemoryStore()
+		old := session.NewManager(store, before, cookie, time.Hour)
+		sess, _ := save(t, old, "alice")
+		save(t, old, "bob")
+
+		list := func(manager *session.Manager) []map[string]interface{} {
+			router := gin.New()
+			handlers.NewSessionHandler(manager).Register(router.Group("/gateway/sessions"))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/gateway/sessions", nil))
+			require.Equal(t, http.StatusOK, w.Code)
+			var body struct {
+				Sessions []map[string]interface{} `json:"sessions"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			return body.Sessions
+		}
+
+		assert.Empty(t, list(session.NewManager(store, retired, cookie, time.Hour)))
+		manager := session.NewManager(store, rotated, cookie, time.Hour)
+		assert.Len(t, list(manager), 2)
+		assert.Len(t, list(session.NewManager(store, retired, cookie, time.Hour)), 2, "listing re-sealed them")
+
+		router := gin.New()
+		handlers.NewSessionHandler(manager).Register(router.Group("/gateway/sessions"))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/gateway/sessions/"+sess.Handle(), nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		remaining := list(manager)
+		require.Len(t, remaining, 1)
+		assert.Equal(t, "bob", remaining[0]["user_id"])
+	})
+}
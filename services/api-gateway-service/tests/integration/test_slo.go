@@ -0,0 +1,137 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gateway/internal/handlers"
+	"gateway/internal/middleware"
+	"gateway/internal/models"
+	"gateway/internal/registry"
+	"gateway/internal/slo"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSLOTracking(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	serviceRegistry := registry.NewServiceRegistry()
+	orders := models.NewServiceConfig("orders", "http://localhost:1", time.Second)
+	orders.SLO = &models.SLOConfig{Availability: 0.9}
+	serviceRegistry.RegisterService(*orders)
+	serviceRegistry.RegisterService(*models.NewServiceConfig("reports", "http://localhost:1", time.Second))
+	checkout := models.NewRouteConfig("/api/orders/checkout", "orders")
+	checkout.SLO = &models.SLOConfig{Availability: 0.99, Latency: 20 * time.Millisecond, LatencyTarget: 0.5, Window: time.Hour}
+	serviceRegistry.RegisterRoute(*checkout)
+	serviceRegistry.RegisterRoute(*models.NewRouteConfig("/api/orders/*", "orders"))
+	serviceRegistry.RegisterRoute(*models.NewRouteConfig("/api/reports/*", "reports"))
+
+	tracker := slo.NewTracker(serviceRegistry)
+	router := gin.New()
+	handlers.NewSLOHandler(tracker).Register(router.Group("/gateway/slo"))
+	api := router.Group("/api", middleware.TrackSLOs(tracker, serviceRegistry))
+	api.GET("/*path", func(c *gin.Context) {
+		if c.Query("slow") != "" {
+			time.Sleep(30 * time.Millisecond)
+		}
+		if c.Query("fail") != "" {
+			c.Status(http.StatusBadGateway)
+			return
+		}
+		if c.Query("invalid") != "" {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+
+	request := func(target string) {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	}
+	list := func(query string) []slo.Status {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/gateway/slo"+query, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		var body struct {
+			SLOs []slo.Status `json:"slos"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return body.SLOs
+	}
+
+	t.Run("SLOs without traffic are met", func(t *testing.T) {
+		statuses := list("")
+		require.Len(t, statuses, 2)
+		assert.Equal(t, slo.ScopeRoute, statuses[0].Scope)
+		assert.Equal(t, "/api/orders/checkout", statuses[0].Name)
+		assert.Equal(t, "1h", statuses[0].Window)
+		assert.Equal(t, "orders", statuses[1].Name)
+		assert.Equal(t, "24h", statuses[1].Window)
+		for _, status := range statuses {
+			for _, objective := range status.Objectives {
+				assert.True(t, objective.Met)
+				assert.Equal(t, 1.0, objective.ErrorBudgetRemaining)
+			}
+		}
+	})
+
+	t.Run("Compliance, budget and burn rates", func(t *testing.T) {
+		for i := 0; i < 7; i++ {
+			request("/api/orders/checkout")
+		}
+		request("/api/orders/checkout?invalid=1")
+		request("/api/orders/checkout?slow=1")
+		request("/api/orders/checkout?fail=1&slow=1")
+		request("/api/orders/1")
+		request("/api/reports/1?fail=1")
+
+		statuses := list("?scope=route")
+		require.Len(t, statuses, 1)
+		route := statuses[0]
+		assert.Equal(t, int64(10), route.Requests)
+		require.Len(t, route.Objectives, 2)
+
+		availability := route.Objectives[0]
+		assert.Equal(t, slo.ObjectiveAvailability, availability.Type)
+		assert.Equal(t, 0.99, availability.Target)
+		assert.InDelta(t, 0.9, availability.Compliance, 1e-9)
+		assert.False(t, availability.Met)
+		// One failure in ten spends the budget of one in a hundred ten times
+		assert.InDelta(t, 10, availability.BurnRate, 1e-9)
+		assert.InDelta(t, 10, availability.BurnRate1h, 1e-9)
+		assert.InDelta(t, 10, availability.BurnRate5m, 1e-9)
+		assert.InDelta(t, -9, availability.ErrorBudgetRemaining, 1e-9)
+
+		latency := route.Objectives[1]
+		assert.Equal(t, slo.ObjectiveLatency, latency.Type)
+		assert.Equal(t, "20ms", latency.Threshold)
+		assert.InDelta(t, 0.8, latency.Compliance, 1e-9)
+		assert.True(t, latency.Met)
+		assert.InDelta(t, 0.4, latency.BurnRate, 1e-9)
+		assert.InDelta(t, 0.6, latency.ErrorBudgetRemaining, 1e-9)
+
+		service := list("?scope=service")[0]
+		assert.Equal(t, int64(11), service.Requests)
+		require.Len(t, service.Objectives, 1)
+		assert.InDelta(t, 10.0/11, service.Objectives[0].Compliance, 1e-6)
+		assert.True(t, service.Objectives[0].Met)
+	})
+
+	t.Run("Bad scopes are rejected", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/gateway/slo?scope=team", nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("A latency objective defaults its target", func(t *testing.T) {
+		config := models.SLOConfig{Latency: time.Second}.Defaults()
+		assert.Equal(t, models.DefaultSLOLatencyTarget, config.LatencyTarget)
+		assert.Equal(t, models.DefaultSLOWindow, config.Window)
+	})
+}
@@ -0,0 +1,157 @@
+package integration
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gateway/internal/circuit"
+	"gateway/internal/config"
+	"gateway/internal/handlers"
+	"gateway/internal/logging"
+	"gateway/internal/maintenance"
+	"gateway/internal/models"
+	"gateway/internal/registry"
+	"gateway/internal/snapshot"
+	"gateway/internal/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSnapshotGateway sets up a gateway with runtimeRoutesConfig and an
+// admin API serving snapshots, services and routes.
+func newSnapshotGateway(t *testing.T) (*snapshot.Gateway, func(method, target, body string) *httptest.ResponseRecorder) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(runtimeRoutesConfig), 0o600))
+	manager := config.NewManager()
+	require.NoError(t, manager.LoadConfig(configPath))
+	cfg := manager.GetConfig()
+
+	serviceRegistry := registry.NewServiceRegistry()
+	require.NoError(t, serviceRegistry.ReplaceRouting(nil, cfg.Services, nil, cfg.Routes))
+	mode, err := maintenance.New(cfg.Maintenance)
+	require.NoError(t, err)
+	gateway := &snapshot.Gateway{
+		Manager:        manager,
+		Registry:       serviceRegistry,
+		Routing:        store.NewRuntimeRouting(serviceRegistry, manager, filepath.Join(dir, "routing.yaml")),
+		Maintenance:    mode,
+		Logging:        logging.NewOverrides(cfg.Logging.Level),
+		MaxLogOverride: cfg.Logging.MaxOverride,
+		Breakers:       circuit.NewBreakers(cfg.CircuitBreaker, nil),
+	}
+
+	router := gin.New()
+	handlers.NewSnapshotHandler(gateway).Register(router.Group("/gateway"))
+	handlers.NewServicesHandler(gateway.Routing).Register(router.Group("/gateway/services"))
+	handlers.NewRoutesHandler(gateway.Routing).Register(router.Group("/gateway/routes"))
+	return gateway, func(method, target, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return w
+	}
+}
+
+func TestSnapshotExportImport(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	source, sendSource := newSnapshotGateway(t)
+	require.Equal(t, http.StatusCreated, sendSource(http.MethodPost, "/gateway/services/inventory", `{"url": "http://inventory:8012", "headers": {"X-Api-Secret": "s3cret"}}`).Code)
+	require.Equal(t, http.StatusCreated, sendSource(http.MethodPost, "/gateway/routes", `{"path": "/api/inventory/*", "service_name": "inventory"}`).Code)
+	source.Registry.DisableService("orders")
+	source.Maintenance.Enable("Back at 14:00 UTC", 30*time.Minute)
+	source.Logging.Set(models.LogLevelDebug, []string{"/api/inventory/*"}, time.Now().Add(10*time.Minute))
+	orders, _ := source.Registry.GetService("orders")
+	_, settings := source.Breakers.SettingsFor(orders, nil)
+	source.Breakers.ForceOpen("orders", "", settings, time.Time{})
+
+	w := sendSource(http.MethodGet, "/gateway/export?download=true", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "attachment")
+	exported := w.Body.Bytes()
+
+	var taken snapshot.Snapshot
+	require.NoError(t, json.Unmarshal(exported, &taken))
+	assert.Equal(t, snapshot.Version, taken.Version)
+	assert.Contains(t, taken.Services, "inventory")
+	assert.Contains(t, string(exported), "s3cret", "imported services need their credentials")
+	require.Len(t, taken.Overrides.Circuits, 1)
+	assert.Equal(t, "orders", taken.Overrides.Circuits[0].Service)
+
+	target, sendTarget := newSnapshotGateway(t)
+	require.Equal(t, http.StatusCreated, sendTarget(http.MethodPost, "/gateway/services/legacy", `{"url": "http://legacy:8013"}`).Code)
+
+	t.Run("Importing puts services, routes and overrides in place", func(t *testing.T) {
+		w := sendTarget(http.MethodPost, "/gateway/import", string(exported))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var result snapshot.Result
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+		assert.Equal(t, 1, result.Services)
+		assert.Equal(t, 1, result.Routes)
+		assert.True(t, result.ConfigEquals)
+		assert.Empty(t, result.Warnings)
+
+		route, service := target.Registry.FindRoute(http.MethodGet, "/api/inventory/7")
+		require.NotNil(t, route)
+		assert.Equal(t, "s3cret", service.Headers["X-Api-Secret"])
+		_, exists := target.Registry.GetService("legacy")
+		assert.False(t, exists, "services added here before the import are replaced")
+
+		traffic, _ := target.Registry.Traffic("orders")
+		assert.Equal(t, registry.TrafficDisabled, traffic.State)
+		status := target.Maintenance.Status()
+		assert.True(t, status.Enabled)
+		assert.Equal(t, "Back at 14:00 UTC", status.Message)
+		assert.Equal(t, models.LogLevelDebug, target.Logging.Level())
+		assert.True(t, target.Logging.Debugs("/api/inventory/*"))
+		assert.False(t, target.Breakers.Closed("orders", ""))
+	})
+
+	t.Run("A snapshot without overrides clears them", func(t *testing.T) {
+		empty, sendEmpty := newSnapshotGateway(t)
+		require.NotNil(t, empty)
+		w := sendTarget(http.MethodPost, "/gateway/import", sendEmpty(http.MethodGet, "/gateway/export", "").Body.String())
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		assert.Empty(t, target.Registry.HeldServices())
+		assert.False(t, target.Maintenance.Status().Enabled)
+		assert.Equal(t, "info", target.Logging.Level())
+		route, _ := target.Registry.FindRoute(http.MethodGet, "/api/inventory/7")
+		assert.Nil(t, route)
+	})
+
+	t.Run("Refused snapshots change nothing", func(t *testing.T) {
+		require.Equal(t, http.StatusOK, sendTarget(http.MethodPost, "/gateway/import", string(exported)).Code)
+
+		var conflicting snapshot.Snapshot
+		require.NoError(t, json.Unmarshal(exported, &conflicting))
+		conflicting.Routes = append(conflicting.Routes, map[string]interface{}{"path": "/api/orders/special", "service_name": "inventory"})
+		body, err := json.Marshal(conflicting)
+		require.NoError(t, err)
+		w := sendTarget(http.MethodPost, "/gateway/import", string(body))
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Contains(t, w.Body.String(), "/api/orders/*")
+
+		conflicting.Routes = nil
+		conflicting.Services["orders"] = map[string]interface{}{"url": "http://elsewhere:8008"}
+		body, err = json.Marshal(conflicting)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusConflict, sendTarget(http.MethodPost, "/gateway/import", string(body)).Code)
+
+		route, _ := target.Registry.FindRoute(http.MethodGet, "/api/inventory/7")
+		assert.NotNil(t, route)
+
+		w = sendTarget(http.MethodPost, "/gateway/import", string(bytes.Replace(exported, []byte(`"version":1`), []byte(`"version":2`), 1)))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, http.StatusBadRequest, sendTarget(http.MethodPost, "/gateway/import", "not json").Code)
+	})
+}
@@ -0,0 +1,112 @@
+package integration
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gateway/internal/config"
+	"gateway/internal/models"
+	"gateway/internal/proxy"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStrictRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Upstream-Path", r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	serviceRegistry := registry.NewServiceRegistry()
+	orders := models.NewServiceConfig("orders", upstream.URL, time.Second)
+	orders.StrictRoutes = true
+	serviceRegistry.RegisterService(*orders)
+	serviceRegistry.RegisterService(*models.NewServiceConfig("products", upstream.URL, time.Second))
+
+	getOrder := models.NewRouteConfig("/api/orders/:id", "orders")
+	getOrder.Method = http.MethodGet
+	serviceRegistry.RegisterRoute(*getOrder)
+	createOrder := models.NewRouteConfig("/api/orders", "orders")
+	createOrder.Method = http.MethodPost
+	serviceRegistry.RegisterRoute(*createOrder)
+	serviceRegistry.RegisterRoute(*models.NewRouteConfig("/api/products/*", "products"))
+	require.NoError(t, serviceRegistry.ValidateConfiguration())
+
+	router := gin.New()
+	router.Any("/api/*path", proxy.New(serviceRegistry).Handle)
+
+	cases := []struct {
+		method string
+		path   string
+		status int
+	}{
+		{http.MethodGet, "/api/orders/42", http.StatusOK},
+		{http.MethodPost, "/api/orders", http.StatusOK},
+		{http.MethodGet, "/api/orders/42/internal/audit", http.StatusNotFound},
+		{http.MethodGet, "/api/orders/", http.StatusNotFound},
+		{http.MethodGet, "/api/orders", http.StatusNotFound},
+		{http.MethodDelete, "/api/orders/42", http.StatusNotFound},
+		{http.MethodPost, "/api/orders/admin", http.StatusNotFound},
+		{http.MethodGet, "/api/products/7/reviews", http.StatusOK},
+	}
+	for _, tc := range cases {
+		t.Run(tc.method+" "+tc.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, nil))
+			assert.Equal(t, tc.status, w.Code)
+			if tc.status == http.StatusOK {
+				assert.Equal(t, tc.path, w.Header().Get("X-Upstream-Path"))
+			}
+		})
+	}
+
+	t.Run("Wildcard routes to strict services are rejected", func(t *testing.T) {
+		serviceRegistry.RegisterRoute(*models.NewRouteConfig("/api/orders/*", "orders"))
+		assert.ErrorContains(t, serviceRegistry.ValidateConfiguration(), "strict service orders")
+	})
+}
+
+func TestStrictRouteValidation(t *testing.T) {
+	write := func(t *testing.T, route string) *config.Manager {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(`
+services:
+  orders:
+    name: "orders"
+    url: "http://orders:8080"
+    timeout: "5s"
+    strict_routes: true
+routes:
+`+route), 0o600))
+		manager := config.NewManager()
+		require.NoError(t, manager.LoadConfig(path))
+		return manager
+	}
+
+	assert.NoError(t, write(t, `  - path: "/api/orders/:id"
+    method: "GET"
+    service_name: "orders"
+`).ValidateConfig())
+	assert.ErrorContains(t, write(t, `  - path: "/api/orders/:id"
+    service_name: "orders"
+`).ValidateConfig(), "needs a method")
+	assert.ErrorContains(t, write(t, `  - path: "/api/orders/*"
+    method: "GET"
+    service_name: "orders"
+`).ValidateConfig(), "cannot use a /* path")
+	assert.ErrorContains(t, write(t, `  - path: "/api/orders/:id"
+    method: "GET"
+    service_name: "orders"
+    strip_prefix: true
+`).ValidateConfig(), "strip_prefix")
+}
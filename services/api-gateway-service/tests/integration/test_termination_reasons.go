@@ -0,0 +1,151 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gateway/internal/middleware"
+	"gateway/internal/models"
+	"gateway/internal/proxy"
+	"gateway/internal/ratelimit"
+	"gateway/pkg/gateway"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTerminationReasons(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		case "/broken":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "/slow":
+			select {
+			case <-time.After(time.Second):
+			case <-r.Context().Done():
+			}
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer upstream.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	var output bytes.Buffer
+	router := gin.New()
+	router.Use(middleware.AccessLog(models.LoggingConfig{Format: "json"}, nil, &output, func() bool { return false }))
+
+	engine := gateway.New(gateway.WithRouter(router))
+	require.NoError(t, engine.AddService(*gateway.NewServiceConfig("orders", upstream.URL, 100*time.Millisecond)))
+	require.NoError(t, engine.AddService(*gateway.NewServiceConfig("ledger", closed.URL, time.Second)))
+	orders := gateway.NewRouteConfig("/api/orders/*", "orders")
+	orders.StripPrefix = true
+	orders.MaxWait = time.Second
+	require.NoError(t, engine.AddRoute(*orders))
+	limited := gateway.NewRouteConfig("/api/exports/*", "orders")
+	limited.RateLimit = &models.RouteRateLimit{Requests: 1, Window: time.Minute}
+	require.NoError(t, engine.AddRoute(*limited))
+	require.NoError(t, engine.AddRoute(*gateway.NewRouteConfig("/api/ledger/*", "ledger")))
+
+	var seen string
+	engine.Use(func(c *gin.Context) {
+		c.Next()
+		seen = gateway.TerminationReason(c)
+	})
+	handler := engine.Handler()
+
+	send := func(req *http.Request) int {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+	get := func(path string) int {
+		return send(httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	t.Run("Each failure is put down to its cause", func(t *testing.T) {
+		cases := []struct {
+			path   string
+			status int
+			reason string
+		}{
+			{"/api/orders/1", http.StatusOK, ""},
+			{"/api/orders/missing", http.StatusNotFound, proxy.TerminationUpstream4xx},
+			{"/api/orders/broken", http.StatusServiceUnavailable, proxy.TerminationUpstream5xx},
+			{"/api/orders/slow", http.StatusGatewayTimeout, proxy.TerminationUpstreamTimeout},
+			{"/api/ledger/1", http.StatusBadGateway, proxy.TerminationUpstreamUnreachable},
+			{"/api/invoices/1", http.StatusNotFound, proxy.TerminationRouteNotFound},
+			{"/api/exports/1", http.StatusOK, ""},
+			{"/api/exports/2", http.StatusTooManyRequests, proxy.TerminationRateLimited},
+		}
+		for _, tc := range cases {
+			assert.Equal(t, tc.status, get(tc.path), tc.path)
+			assert.Equal(t, tc.reason, seen, tc.path)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/api/orders/slow", nil)
+		req.Header.Set(proxy.MaxWaitHeader, "20ms")
+		assert.Equal(t, http.StatusGatewayTimeout, send(req))
+		assert.Equal(t, proxy.TerminationGatewayTimeout, seen)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(20*time.Millisecond, cancel)
+		assert.Equal(t, 499, send(httptest.NewRequest(http.MethodGet, "/api/orders/slow", nil).WithContext(ctx)))
+		assert.Equal(t, proxy.TerminationClientCancel, seen)
+	})
+
+	t.Run("Terminations are counted per reason and service", func(t *testing.T) {
+		counts := engine.Proxy().Terminations().Counts()
+		require.Len(t, counts, 8)
+		assert.Equal(t, proxy.TerminationCount{Reason: proxy.TerminationClientCancel, Service: "orders", Count: 1}, counts[0])
+		assert.Equal(t, proxy.TerminationCount{Reason: proxy.TerminationRateLimited, Service: "orders", Count: 1}, counts[2])
+		assert.Equal(t, proxy.TerminationCount{Reason: proxy.TerminationRouteNotFound, Count: 1}, counts[3])
+
+		stats := engine.Proxy().Terminations().Stats()
+		assert.Equal(t, int64(8), stats["total"])
+	})
+
+	t.Run("Access log lines carry the reason", func(t *testing.T) {
+		lines := strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n")
+		require.Len(t, lines, 10)
+
+		var first, failed map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(lines[0]), &first))
+		require.NoError(t, json.Unmarshal([]byte(lines[3]), &failed))
+		assert.NotContains(t, first, "termination_reason")
+		assert.Equal(t, proxy.TerminationUpstreamTimeout, failed["termination_reason"])
+	})
+
+	t.Run("Gateway rejections are told apart from the upstream's", func(t *testing.T) {
+		policy := *models.NewRateLimitPolicy("test", 1, time.Minute, 1)
+		limiter := ratelimit.NewLimiter(policy.GetRate(), policy.Burst)
+
+		var output bytes.Buffer
+		router := gin.New()
+		router.Use(middleware.AccessLog(models.LoggingConfig{Format: "text"}, nil, &output, func() bool { return false }))
+		router.Use(middleware.RateLimit(policy, limiter, nil))
+		router.GET("/api/orders", func(c *gin.Context) {
+			c.Status(http.StatusUnauthorized)
+		})
+
+		for i := 0; i < 2; i++ {
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/orders", nil))
+		}
+		lines := strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n")
+		require.Len(t, lines, 2)
+		assert.Contains(t, lines[0], "| "+proxy.TerminationUnauthorized)
+		assert.Contains(t, lines[1], "| "+proxy.TerminationRateLimited)
+	})
+}
@@ -0,0 +1,87 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gateway/internal/models"
+	"gateway/internal/proxy"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientTimeBudget(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(200 * time.Millisecond):
+		case <-r.Context().Done():
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+
+	serviceRegistry := registry.NewServiceRegistry()
+	serviceRegistry.RegisterService(*models.NewServiceConfig("products", upstream.URL, 2*time.Second))
+	serviceRegistry.RegisterService(*models.NewServiceConfig("reports", upstream.URL, 100*time.Millisecond))
+	feed := models.NewRouteConfig("/api/feed/*", "products")
+	feed.MaxWait = time.Second
+	serviceRegistry.RegisterRoute(*feed)
+	serviceRegistry.RegisterRoute(*models.NewRouteConfig("/api/products/*", "products"))
+	serviceRegistry.RegisterRoute(*models.NewRouteConfig("/api/reports/*", "reports"))
+
+	router := gin.New()
+	router.Any("/api/*path", proxy.New(serviceRegistry).Handle)
+
+	request := func(path, maxWait string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if maxWait != "" {
+			req.Header.Set(proxy.MaxWaitHeader, maxWait)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("A short budget times out with its reason", func(t *testing.T) {
+		for _, maxWait := range []string{"50", "50ms"} {
+			start := time.Now()
+			w := request("/api/feed/latest", maxWait)
+			assert.Less(t, time.Since(start), 150*time.Millisecond)
+			require.Equal(t, http.StatusGatewayTimeout, w.Code)
+			assert.Equal(t, proxy.TimeoutReasonClientBudget, w.Header().Get(proxy.TimeoutReasonHeader))
+
+			var body map[string]string
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Equal(t, proxy.TimeoutReasonClientBudget, body["reason"])
+			assert.Equal(t, "Service products did not respond within the requested 50ms", body["message"])
+		}
+	})
+
+	t.Run("Budgets are capped by the route's max_wait", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, request("/api/feed/latest", "60000").Code)
+		assert.Equal(t, http.StatusOK, request("/api/feed/latest", "1h").Code)
+		assert.Equal(t, http.StatusOK, request("/api/feed/latest", "").Code)
+	})
+
+	t.Run("Routes without max_wait and unusable values ignore the header", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, request("/api/products/latest", "50").Code)
+		assert.Equal(t, http.StatusOK, request("/api/feed/latest", "soon").Code)
+		assert.Equal(t, http.StatusOK, request("/api/feed/latest", "-5").Code)
+	})
+
+	t.Run("Service timeouts report their own reason", func(t *testing.T) {
+		w := request("/api/reports/daily", "")
+		require.Equal(t, http.StatusGatewayTimeout, w.Code)
+		assert.Equal(t, proxy.TimeoutReasonService, w.Header().Get(proxy.TimeoutReasonHeader))
+		assert.Contains(t, w.Body.String(), `"reason":"service_timeout"`)
+	})
+}
@@ -0,0 +1,124 @@
+package integration
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gateway/internal/config"
+	"gateway/internal/models"
+	"gateway/internal/tlsconfig"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTLSTermination(t *testing.T) {
+	ca := newTestCA(t, "Gateway CA")
+	dir := t.TempDir()
+	serverTLS := models.TLSConfig{
+		CertFile:     filepath.Join(dir, "server.crt"),
+		KeyFile:      filepath.Join(dir, "server.key"),
+		MinVersion:   "1.2",
+		CipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"},
+	}
+	install := func(commonName string) {
+		cert := ca.issue(t, commonName, nil, net.ParseIP("127.0.0.1"))
+		keyDER, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+		require.NoError(t, err)
+		writePEM(t, serverTLS.KeyFile, "PRIVATE KEY", keyDER)
+		writePEM(t, serverTLS.CertFile, "CERTIFICATE", cert.Certificate[0])
+	}
+	install("gateway-1")
+
+	certificate, err := tlsconfig.LoadCertificate(serverTLS)
+	require.NoError(t, err)
+	tlsConfig, err := tlsconfig.Server(serverTLS, certificate)
+	require.NoError(t, err)
+	// Served like the gateway's listener: httptest would add a certificate
+	// of its own
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := &http.Server{TLSConfig: tlsConfig, Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})}
+	go server.ServeTLS(listener, "", "")
+	defer server.Close()
+	url := "https://" + listener.Addr().String()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	// served connects anew and returns the common name of the certificate
+	// the gateway presents
+	served := func(client *tls.Config) (string, error) {
+		client.RootCAs = roots
+		transport := &http.Transport{TLSClientConfig: client, DisableKeepAlives: true}
+		resp, err := (&http.Client{Transport: transport}).Get(url)
+		if err != nil {
+			return "", err
+		}
+		resp.Body.Close()
+		return resp.TLS.PeerCertificates[0].Subject.CommonName, nil
+	}
+
+	name, err := served(&tls.Config{})
+	require.NoError(t, err)
+	assert.Equal(t, "gateway-1", name)
+
+	t.Run("Only the configured versions and suites are negotiated", func(t *testing.T) {
+		_, err := served(&tls.Config{MaxVersion: tls.VersionTLS11})
+		assert.Error(t, err)
+		_, err = served(&tls.Config{MaxVersion: tls.VersionTLS12, CipherSuites: []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384}})
+		assert.Error(t, err)
+		_, err = served(&tls.Config{MaxVersion: tls.VersionTLS12})
+		assert.NoError(t, err)
+	})
+
+	t.Run("Renewed certificates are served without a restart", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go config.WatchFiles(ctx, certificate.Files, 20*time.Millisecond, func() { _ = certificate.Reload() })
+		time.Sleep(50 * time.Millisecond)
+
+		install("gateway-2")
+		assert.Eventually(t, func() bool {
+			name, err := served(&tls.Config{})
+			return err == nil && name == "gateway-2"
+		}, 2*time.Second, 20*time.Millisecond)
+	})
+
+	t.Run("A broken certificate keeps the previous one in use", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(serverTLS.CertFile, []byte("not a certificate"), 0o600))
+		assert.Error(t, certificate.Reload())
+		name, err := served(&tls.Config{})
+		require.NoError(t, err)
+		assert.Equal(t, "gateway-2", name)
+	})
+
+	t.Run("Invalid settings are rejected", func(t *testing.T) {
+		for name, tls := range map[string]string{
+			"server tls min_version":   `min_version: "1.4"`,
+			"unknown cipher suite":     `cipher_suites: ["TLS_FAST"]`,
+			"is insecure":              `cipher_suites: ["TLS_RSA_WITH_RC4_128_SHA"]`,
+			"needs both cert_file and": `min_version: "1.3"`,
+		} {
+			content := reloadBaseConfig + "server:\n  tls:\n    " + tls + "\n"
+			if name != "needs both cert_file and" {
+				content += "    cert_file: " + serverTLS.CertFile + "\n    key_file: " + serverTLS.KeyFile + "\n"
+			}
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+			manager := config.NewManager()
+			require.NoError(t, manager.LoadConfig(path))
+			err := manager.ValidateConfig()
+			require.Error(t, err, name)
+			assert.Contains(t, err.Error(), name)
+		}
+	})
+}
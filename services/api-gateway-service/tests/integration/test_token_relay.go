@@ -0,0 +1,135 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gateway/internal/auth"
+	"gateway/internal/middleware"
+	"gateway/internal/models"
+	"gateway/internal/session"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenRelayRefresh(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// The auth service hands out numbered tokens and accepts each refresh
+	// token once, as a rotating identity provider does
+	var refreshes int32
+	var spentMutex sync.Mutex
+	spent := make(map[string]bool)
+	authService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		spentMutex.Lock()
+		reused := spent[body["refresh_token"]]
+		spent[body["refresh_token"]] = true
+		spentMutex.Unlock()
+		if reused {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		time.Sleep(50 * time.Millisecond)
+		n := strconv.Itoa(int(atomic.AddInt32(&refreshes, 1)))
+		json.NewEncoder(w).Encode(auth.TokenResponse{AccessToken: "access-" + n, RefreshToken: "refresh-" + n, ExpiresIn: 300})
+	}))
+	defer authService.Close()
+
+	key, _ := session.GenerateKey()
+	keyring, _ := session.NewKeyring([]string{key})
+	cookie := session.CookieOptions{Name: "gw_session"}
+	client := auth.NewClient(authService.URL, time.Second)
+	config := models.BFFConfig{Enabled: true, RefreshBefore: 30 * time.Second}
+
+	newRouter := func(sessions *session.Manager) *gin.Engine {
+		router := gin.New()
+		router.Use(middleware.TokenRelay(sessions, client, config))
+		router.GET("/api/*path", func(c *gin.Context) {
+			c.String(http.StatusOK, c.GetHeader("Authorization"))
+		})
+		return router
+	}
+	// login stores a session whose access token expires in expiresIn
+	login := func(t *testing.T, sessions *session.Manager, expiresIn int) *http.Cookie {
+		sess, err := sessions.New()
+		require.NoError(t, err)
+		sess.SetTokens("access-0", "refresh-0", expiresIn)
+		w := httptest.NewRecorder()
+		require.NoError(t, sessions.Save(w, httptest.NewRequest(http.MethodPost, "/bff/login", nil), sess))
+		cookies := w.Result().Cookies()
+		require.Len(t, cookies, 1)
+		return cookies[0]
+	}
+	send := func(router *gin.Engine, sessionCookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/orders", nil)
+		req.AddCookie(sessionCookie)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	reset := func() {
+		atomic.StoreInt32(&refreshes, 0)
+		spentMutex.Lock()
+		spent = make(map[string]bool)
+		spentMutex.Unlock()
+	}
+
+	t.Run("Fresh tokens are relayed as they are", func(t *testing.T) {
+		reset()
+		sessions := session.NewManager(session.NewMemoryStore(), keyring, cookie, time.Hour)
+		w := send(newRouter(sessions), login(t, sessions, 300))
+		assert.Equal(t, "Bearer access-0", w.Body.String())
+		assert.Equal(t, int32(0), atomic.LoadInt32(&refreshes))
+	})
+
+	t.Run("Expiring tokens are refreshed and saved", func(t *testing.T) {
+		reset()
+		sessions := session.NewManager(session.NewMemoryStore(), keyring, cookie, time.Hour)
+		router := newRouter(sessions)
+		sessionCookie := login(t, sessions, 10)
+
+		assert.Equal(t, "Bearer access-1", send(router, sessionCookie).Body.String())
+		assert.Equal(t, "Bearer access-1", send(router, sessionCookie).Body.String())
+		assert.Equal(t, int32(1), atomic.LoadInt32(&refreshes))
+
+		sess, err := sessions.Get(httptest.NewRequest(http.MethodGet, "/", nil).Context(), sessionCookie.Value)
+		require.NoError(t, err)
+		assert.Equal(t, "refresh-1", sess.Get(session.ValueRefreshToken))
+	})
+
+	for name, store := range map[string]session.Store{"server-side": session.NewMemoryStore(), "cookie": nil} {
+		t.Run("Concurrent requests share one refresh with "+name+" sessions", func(t *testing.T) {
+			reset()
+			sessions := session.NewManager(store, keyring, cookie, time.Hour)
+			router := newRouter(sessions)
+			sessionCookie := login(t, sessions, 10)
+
+			var wg sync.WaitGroup
+			results := make([]string, 8)
+			for i := range results {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					results[i] = send(router, sessionCookie).Body.String()
+				}(i)
+			}
+			wg.Wait()
+
+			assert.Equal(t, int32(1), atomic.LoadInt32(&refreshes))
+			for _, result := range results {
+				assert.Equal(t, "Bearer access-1", result)
+			}
+		})
+	}
+}
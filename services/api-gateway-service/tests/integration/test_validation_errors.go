@@ -0,0 +1,79 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gateway/internal/models"
+	"gateway/internal/proxy"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidationErrorNormalization(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		switch r.URL.Path {
+		case "/fastapi":
+			w.Write([]byte(`{"detail": [{"loc": ["body", "items", 0, "sku"], "msg": "field required", "type": "value_error.missing"}]}`))
+		case "/express":
+			w.Write([]byte(`{"error": "validation_error", "message": "Request validation failed", "errors": [{"field": "channel", "message": "Channel must be one of: email, sms, in_app", "value": "fax"}]}`))
+		default:
+			w.Write([]byte(`{"errors": {"email": ["is invalid", "is taken"]}}`))
+		}
+	}))
+	defer upstream.Close()
+
+	serviceRegistry := registry.NewServiceRegistry()
+	serviceRegistry.RegisterService(*models.NewServiceConfig("orders", upstream.URL, time.Second))
+	legacy := *models.NewServiceConfig("legacy", upstream.URL, time.Second)
+	legacy.ValidationErrors = models.ValidationErrorsPassthrough
+	serviceRegistry.RegisterService(legacy)
+	for _, name := range []string{"orders", "legacy"} {
+		route := models.NewRouteConfig("/api/"+name+"/*", name)
+		route.StripPrefix = true
+		serviceRegistry.RegisterRoute(*route)
+	}
+
+	proxyHandler := proxy.New(serviceRegistry)
+	proxyHandler.SetValidationErrorMode(models.ValidationErrorsNormalize)
+	router := gin.New()
+	router.Any("/api/*proxyPath", proxyHandler.Handle)
+
+	send := func(path string) (*httptest.ResponseRecorder, proxy.Problem) {
+		req, _ := http.NewRequest("POST", path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var problem proxy.Problem
+		json.Unmarshal(w.Body.Bytes(), &problem)
+		return w, problem
+	}
+
+	w, problem := send("/api/orders/fastapi")
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	assert.Equal(t, http.StatusUnprocessableEntity, problem.Status)
+	assert.Equal(t, "orders", problem.Service)
+	assert.Equal(t, []proxy.FieldError{{Field: "items.0.sku", Message: "field required", Code: "value_error.missing"}}, problem.Errors)
+
+	_, problem = send("/api/orders/express")
+	assert.Equal(t, "Request validation failed", problem.Detail)
+	assert.Equal(t, []proxy.FieldError{{Field: "channel", Message: "Channel must be one of: email, sms, in_app"}}, problem.Errors)
+
+	_, problem = send("/api/orders/rails")
+	assert.Equal(t, []proxy.FieldError{{Field: "email", Message: "is invalid"}, {Field: "email", Message: "is taken"}}, problem.Errors)
+
+	// Services can opt out and keep their own error shape
+	w, _ = send("/api/legacy/express")
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), `"error": "validation_error"`)
+}
@@ -0,0 +1,85 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gateway/internal/models"
+	"gateway/internal/watchdog"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLeakWatchdog(t *testing.T) {
+	dumpDir := t.TempDir()
+	leakWatchdog := watchdog.New(models.WatchdogConfig{
+		Enabled:                true,
+		Interval:               time.Hour,
+		MaxGoroutines:          1,
+		MaxUpstreamConnections: 1,
+		DumpInterval:           time.Hour,
+		DumpDir:                dumpDir,
+	})
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	// Client connections are counted through the server's ConnState hook
+	upstream := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	upstream.Config.ConnState = leakWatchdog.TrackConnState
+	upstream.Start()
+	defer upstream.Close()
+
+	// Upstream connections are counted until closed
+	dialer := &net.Dialer{}
+	dial := leakWatchdog.CountDials(dialer.DialContext)
+	first, err := dial(context.Background(), "tcp", upstream.Listener.Addr().String())
+	require.NoError(t, err)
+	second, err := dial(context.Background(), "tcp", upstream.Listener.Addr().String())
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		return leakWatchdog.Stats()["client_connections"] == int64(2)
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, int64(2), leakWatchdog.Stats()["upstream_connections"])
+
+	leakWatchdog.Check()
+	stats := leakWatchdog.Stats()
+	assert.Equal(t, int64(1), stats["warnings"])
+	assert.Greater(t, stats["goroutines"], 1)
+	assert.Greater(t, stats["open_files"], 0)
+	assert.Contains(t, logs.String(), "goroutines")
+	assert.Contains(t, logs.String(), "upstream connections 2 > 1")
+
+	dumps, _ := filepath.Glob(filepath.Join(dumpDir, "goroutines-*.txt"))
+	require.Len(t, dumps, 1)
+	dump, _ := os.ReadFile(dumps[0])
+	assert.Contains(t, string(dump), "goroutine profile:")
+
+	// Further warnings within dump_interval do not dump again
+	leakWatchdog.Check()
+	assert.Equal(t, int64(2), leakWatchdog.Stats()["warnings"])
+	dumps, _ = filepath.Glob(filepath.Join(dumpDir, "goroutines-*.txt"))
+	assert.Len(t, dumps, 1)
+
+	// Closing twice only counts once
+	first.Close()
+	first.Close()
+	second.Close()
+	assert.Equal(t, int64(0), leakWatchdog.Stats()["upstream_connections"])
+	assert.Eventually(t, func() bool {
+		return leakWatchdog.Stats()["client_connections"] == int64(0)
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,100 @@
+package integration
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"gateway/internal/middleware"
+	"gateway/internal/models"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func sign(secret, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestWebhookSignatureVerification(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Setenv("GITHUB_WEBHOOK_SECRET", "gh-secret")
+	secretFile := filepath.Join(t.TempDir(), "stripe")
+	assert.NoError(t, os.WriteFile(secretFile, []byte("whsec_123\n"), 0o600))
+
+	serviceRegistry := registry.NewServiceRegistry()
+	serviceRegistry.RegisterService(*models.NewServiceConfig("payments", "http://payments", time.Second))
+	github := models.NewRouteConfig("/api/webhooks/github", "payments")
+	github.Signature = &models.SignatureConfig{
+		Header:    "X-Hub-Signature-256",
+		SecretRef: "env:GITHUB_WEBHOOK_SECRET",
+		Prefix:    "sha256=",
+	}
+	serviceRegistry.RegisterRoute(*github)
+	stripe := models.NewRouteConfig("/api/webhooks/stripe", "payments")
+	stripe.Signature = &models.SignatureConfig{
+		Header:    "Stripe-Signature",
+		SecretRef: "file:" + secretFile,
+		Scheme:    models.SignatureStripe,
+	}
+	serviceRegistry.RegisterRoute(*stripe)
+	serviceRegistry.RegisterRoute(*models.NewRouteConfig("/api/payments/*", "payments"))
+
+	router := gin.New()
+	router.Use(middleware.VerifySignatures(serviceRegistry))
+	router.POST("/api/*path", func(c *gin.Context) {
+		// Upstreams still get the full body
+		body, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusOK, string(body))
+	})
+
+	body := `{"action": "paid", "order_id": 17}`
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	stale := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+
+	tests := []struct {
+		name           string
+		path           string
+		header         string
+		value          string
+		expectedStatus int
+	}{
+		{"GitHub style signature", "/api/webhooks/github", "X-Hub-Signature-256", "sha256=" + sign("gh-secret", body), http.StatusOK},
+		{"Wrong secret", "/api/webhooks/github", "X-Hub-Signature-256", "sha256=" + sign("guess", body), http.StatusUnauthorized},
+		{"Missing prefix", "/api/webhooks/github", "X-Hub-Signature-256", sign("gh-secret", body), http.StatusUnauthorized},
+		{"Missing header", "/api/webhooks/github", "", "", http.StatusUnauthorized},
+		{"Stripe style signature", "/api/webhooks/stripe", "Stripe-Signature", "t=" + now + ",v1=" + sign("whsec_123", now+"."+body), http.StatusOK},
+		{"Stripe rotation sends two signatures", "/api/webhooks/stripe", "Stripe-Signature", "t=" + now + ",v1=" + sign("old", now+"."+body) + ",v1=" + sign("whsec_123", now+"."+body), http.StatusOK},
+		{"Replayed Stripe delivery", "/api/webhooks/stripe", "Stripe-Signature", "t=" + stale + ",v1=" + sign("whsec_123", stale+"."+body), http.StatusUnauthorized},
+		{"Unsigned routes are untouched", "/api/payments/refund", "", "", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", tt.path, strings.NewReader(body))
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus == http.StatusOK {
+				assert.Equal(t, body, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package integration
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gateway/internal/models"
+	"gateway/internal/proxy"
+	"gateway/internal/registry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// newEchoWebSocketServer accepts upgrades and echoes every frame back
+// unmasked.
+func newEchoWebSocketServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sum := sha1.Sum([]byte(r.Header.Get("Sec-WebSocket-Key") + websocketGUID))
+		conn, rw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+		rw.WriteString("Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n")
+		rw.Flush()
+
+		for {
+			opcode, payload, err := readFrame(rw.Reader)
+			if err != nil {
+				return
+			}
+			conn.Write(frame(opcode, payload, false))
+		}
+	}))
+}
+
+// dialWebSocket upgrades a connection to the gateway and returns it with
+// the handshake's status.
+func dialWebSocket(t *testing.T, gateway *httptest.Server, path string) (net.Conn, *bufio.Reader, int) {
+	conn, err := net.Dial("tcp", gateway.Listener.Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	io.WriteString(conn, "GET "+path+" HTTP/1.1\r\nHost: gateway\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n")
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	require.NoError(t, err)
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		resp.Body.Close()
+	}
+	return conn, reader, resp.StatusCode
+}
+
+func frame(opcode byte, payload []byte, masked bool) []byte {
+	out := []byte{0x80 | opcode}
+	maskBit := byte(0)
+	if masked {
+		maskBit = 0x80
+	}
+	switch {
+	case len(payload) < 126:
+		out = append(out, maskBit|byte(len(payload)))
+	case len(payload) <= 0xffff:
+		out = append(out, maskBit|126)
+		out = binary.BigEndian.AppendUint16(out, uint16(len(payload)))
+	default:
+		out = append(out, maskBit|127)
+		out = binary.BigEndian.AppendUint64(out, uint64(len(payload)))
+	}
+	if !masked {
+		return append(out, payload...)
+	}
+	key := []byte{1, 2, 3, 4}
+	out = append(out, key...)
+	for i, b := range payload {
+		out = append(out, b^key[i%4])
+	}
+	return out
+}
+
+func readFrame(reader *bufio.Reader) (byte, []byte, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return 0, nil, err
+	}
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		extended := make([]byte, 2)
+		if _, err := io.ReadFull(reader, extended); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(extended))
+	case 127:
+		extended := make([]byte, 8)
+		if _, err := io.ReadFull(reader, extended); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(extended)
+	}
+	var key []byte
+	if header[1]&0x80 != 0 {
+		key = make([]byte, 4)
+		if _, err := io.ReadFull(reader, key); err != nil {
+			return 0, nil, err
+		}
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		if key != nil {
+			payload[i] ^= key[i%4]
+		}
+	}
+	return header[0] & 0x0f, payload, nil
+}
+
+func TestWebSocketLimits(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	upstream := newEchoWebSocketServer()
+	defer upstream.Close()
+
+	serviceRegistry := registry.NewServiceRegistry()
+	serviceRegistry.RegisterService(*models.NewServiceConfig("notifications", upstream.URL, 50*time.Millisecond))
+	route := models.NewRouteConfig("/api/notifications/*", "notifications")
+	route.WebSocket = &models.WebSocketPolicy{
+		MaxConnections:          3,
+		MaxConnectionsPerClient: 2,
+		MaxMessageSize:          1024,
+		IdleTimeout:             300 * time.Millisecond,
+	}
+	serviceRegistry.RegisterRoute(*route)
+
+	proxyHandler := proxy.New(serviceRegistry)
+	router := gin.New()
+	router.Any("/api/*path", proxyHandler.Handle)
+	gateway := httptest.NewServer(router)
+	defer gateway.Close()
+
+	t.Run("Messages are relayed past the service timeout", func(t *testing.T) {
+		conn, reader, status := dialWebSocket(t, gateway, "/api/notifications/ws")
+		require.Equal(t, http.StatusSwitchingProtocols, status)
+
+		time.Sleep(100 * time.Millisecond)
+		conn.Write(frame(0x1, []byte("hello"), true))
+		opcode, payload, err := readFrame(reader)
+		require.NoError(t, err)
+		assert.Equal(t, byte(0x1), opcode)
+		assert.Equal(t, "hello", string(payload))
+
+		// Fragments count towards one message
+		conn.Write([]byte{0x02, 0x80 | 126, 0x02, 0x00, 0, 0, 0, 0})
+		conn.Write(make([]byte, 512))
+		conn.Write(frame(0x0, make([]byte, 513), true))
+		for opcode != 0x8 {
+			opcode, payload, err = readFrame(reader)
+			require.NoError(t, err)
+		}
+		assert.Equal(t, "websocket message too big", string(payload[2:]))
+		assert.Equal(t, uint16(1009), binary.BigEndian.Uint16(payload[:2]))
+	})
+
+	t.Run("Idle sockets are closed", func(t *testing.T) {
+		_, reader, status := dialWebSocket(t, gateway, "/api/notifications/ws")
+		require.Equal(t, http.StatusSwitchingProtocols, status)
+
+		opcode, payload, err := readFrame(reader)
+		require.NoError(t, err)
+		assert.Equal(t, byte(0x8), opcode)
+		assert.Equal(t, uint16(1001), binary.BigEndian.Uint16(payload[:2]))
+	})
+
+	t.Run("Connections are capped per client and per route", func(t *testing.T) {
+		assert.Eventually(t, func() bool {
+			return proxyHandler.WebSockets().Stats()["active"] == 0
+		}, time.Second, 10*time.Millisecond)
+
+		for i := 0; i < 2; i++ {
+			_, _, status := dialWebSocket(t, gateway, "/api/notifications/ws")
+			require.Equal(t, http.StatusSwitchingProtocols, status)
+		}
+		_, _, status := dialWebSocket(t, gateway, "/api/notifications/ws")
+		assert.Equal(t, http.StatusTooManyRequests, status)
+
+		// Another client may still connect until the route is full
+		other := &models.WebSocketPolicy{MaxConnections: 3}
+		ok, _ := proxyHandler.WebSockets().Acquire("/api/notifications/*", "10.0.0.2", other)
+		assert.True(t, ok)
+		ok, perClient := proxyHandler.WebSockets().Acquire("/api/notifications/*", "10.0.0.3", other)
+		assert.False(t, ok)
+		assert.False(t, perClient)
+		proxyHandler.WebSockets().Release("/api/notifications/*", "10.0.0.2")
+	})
+
+	stats := proxyHandler.WebSockets().Stats()
+	assert.Equal(t, int64(1), stats["closed_too_big"])
+	assert.GreaterOrEqual(t, stats["closed_idle"], int64(1))
+	assert.Equal(t, int64(2), stats["rejected"])
+}
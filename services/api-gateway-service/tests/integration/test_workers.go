@@ -0,0 +1,88 @@
+package integration
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"gateway/internal/ratelimit"
+	"gateway/internal/workers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimitCoordinator(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "ratelimit.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	served := make(chan error, 1)
+	go func() { served <- ratelimit.ServeCoordinator(ctx, listener, ratelimit.NewLimiter(0.001, 3)) }()
+
+	// Two workers, each holding a share of the limit as fallback
+	first := ratelimit.NewClient(socket, ratelimit.NewLimiter(0.001, 2))
+	second := ratelimit.NewClient(socket, ratelimit.NewLimiter(0.001, 2))
+
+	t.Run("Workers count against the same buckets", func(t *testing.T) {
+		assert.True(t, first.Take("203.0.113.7").Allowed)
+		assert.True(t, second.Take("203.0.113.7").Allowed)
+		quota := first.Take("203.0.113.7")
+		assert.True(t, quota.Allowed)
+		assert.Equal(t, 3, quota.Limit)
+		assert.Equal(t, 0, quota.Remaining)
+
+		quota = second.Take("203.0.113.7")
+		assert.False(t, quota.Allowed)
+		assert.Positive(t, quota.RetryAfter)
+
+		// Keys that need quoting survive the trip
+		assert.True(t, second.Take("user:a b\n\"c\"").Allowed)
+
+		assert.Equal(t, int64(1), second.Stats()["blocked_requests"])
+		assert.Equal(t, int64(0), second.Stats()["coordinator_errors"])
+	})
+
+	t.Run("Workers fall back to their share without the coordinator", func(t *testing.T) {
+		cancel()
+		require.NoError(t, <-served)
+		// Pooled connections are closed shortly after the coordinator stops
+		require.Eventually(t, func() bool {
+			first.Take("probe")
+			return first.Stats()["coordinator_errors"].(int64) > 0
+		}, time.Second, 10*time.Millisecond)
+
+		assert.True(t, first.Take("198.51.100.1").Allowed)
+		assert.True(t, first.Take("198.51.100.1").Allowed)
+		quota := first.Take("198.51.100.1")
+		assert.False(t, quota.Allowed)
+		assert.Equal(t, 2, quota.Limit)
+		assert.NotEmpty(t, first.Stats()["last_error"])
+	})
+}
+
+func TestReusePortListeners(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		_, err := workers.Listen("127.0.0.1:0", true)
+		assert.Error(t, err)
+		return
+	}
+
+	first, err := workers.Listen("127.0.0.1:0", true)
+	require.NoError(t, err)
+	defer first.Close()
+
+	// Another worker can listen on the same port
+	second, err := workers.Listen(first.Addr().String(), true)
+	require.NoError(t, err)
+	defer second.Close()
+
+	// Without SO_REUSEPORT the port is taken
+	_, err = workers.Listen(first.Addr().String(), false)
+	assert.Error(t, err)
+}